@@ -2,12 +2,39 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jun/gophdrive/backend/internal/app"
+	"github.com/jun/gophdrive/backend/internal/config"
+	"github.com/jun/gophdrive/backend/internal/logging"
+	"github.com/jun/gophdrive/backend/internal/tracing"
 )
 
 func main() {
-	application := app.NewApp(context.Background())
-	lambda.Start(application.HandleRequest)
+	logging.Setup(os.Getenv("DEV_MODE") == "true")
+
+	ctx := context.Background()
+	if err := tracing.Setup(ctx); err != nil {
+		slog.Warn("Tracing setup failed; continuing without tracing", "error", err)
+	}
+	cfg, err := config.FromEnvironment(ctx)
+	if err != nil {
+		slog.Error("Load configuration failed", "error", err)
+		os.Exit(1)
+	}
+	application := app.NewApp(ctx, cfg)
+	// HandleEvent accepts both REST API and HTTP API payloads.
+	lambda.Start(func(ctx context.Context, event json.RawMessage) (any, error) {
+		resp, err := application.HandleEvent(ctx, event)
+		application.Wait(ctx)
+		// Export spans before Lambda freezes the process until the next
+		// invocation.
+		if flushErr := tracing.Flush(ctx); flushErr != nil {
+			slog.WarnContext(ctx, "Flush traces failed", "error", flushErr)
+		}
+		return resp, err
+	})
 }
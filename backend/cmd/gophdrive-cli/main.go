@@ -0,0 +1,345 @@
+// Command gophdrive-cli reads and edits GophDrive notes from the terminal.
+// It authenticates with a personal access token, created under Settings or
+// with POST /auth/tokens, so notes can be piped through scripts:
+//
+//	export GOPHDRIVE_URL=https://notes.example.com/api
+//	export GOPHDRIVE_TOKEN=gdp_...
+//	gophdrive-cli search invoice
+//	gophdrive-cli get <id> | grep TODO
+//	gophdrive-cli edit <id>
+//	gophdrive-cli sync ~/notes
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/apiclient"
+)
+
+// folderMIMEType marks folders in listings.
+const folderMIMEType = "application/vnd.google-apps.folder"
+
+const usage = `Usage: gophdrive-cli [flags] <command> [arguments]
+
+Commands:
+  list [folderId]            list the notes and folders in a folder
+  search <query>             search notes by name and content
+  get <id>                   print a note's content
+  put [-if-match etag] <id> [file]
+                             replace a note's content with file or stdin
+  create [-folder id] <name> [file]
+                             create a note from file or stdin, printing its ID
+  edit <id>                  edit a note in $VISUAL or $EDITOR
+  sync <dir>                 mirror all notes into dir and push local edits
+
+Flags:
+`
+
+// cli holds what the commands need; tests swap the streams.
+type cli struct {
+	client  *apiclient.Client
+	json    bool
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	getenv  func(string) string
+	command func(name string, args ...string) *exec.Cmd
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv, command: exec.Command}
+	if err := c.run(ctx, os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "gophdrive-cli:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func (c *cli) run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("gophdrive-cli", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprint(c.stderr, usage)
+		flags.PrintDefaults()
+	}
+	baseURL := flags.String("url", c.getenv("GOPHDRIVE_URL"), "API base URL, such as https://notes.example.com/api (GOPHDRIVE_URL)")
+	token := flags.String("token", "", "personal access token (default GOPHDRIVE_TOKEN)")
+	timeout := flags.Duration("timeout", time.Minute, "timeout of each API request")
+	flags.BoolVar(&c.json, "json", false, "print the API's JSON instead of text")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	if *token == "" {
+		*token = c.getenv("GOPHDRIVE_TOKEN")
+	}
+	if *baseURL == "" || *token == "" {
+		return errors.New("set GOPHDRIVE_URL and GOPHDRIVE_TOKEN, or pass -url and -token")
+	}
+	c.client = apiclient.New(*baseURL, *token, &http.Client{Timeout: *timeout})
+
+	cmd, args := flags.Arg(0), flags.Args()[1:]
+	switch cmd {
+	case "list", "ls":
+		return c.list(ctx, args)
+	case "search":
+		return c.search(ctx, args)
+	case "get", "cat":
+		return c.get(ctx, args)
+	case "put":
+		return c.put(ctx, args)
+	case "create":
+		return c.create(ctx, args)
+	case "edit":
+		return c.edit(ctx, args)
+	case "sync":
+		return c.sync(ctx, args)
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// parseArgs parses a command's flags and checks it got between min and max
+// positional arguments.
+func (c *cli) parseArgs(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	fs.SetOutput(c.stderr)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() < min || fs.NArg() > max {
+		return nil, fmt.Errorf("%s: wrong number of arguments; see gophdrive-cli -h", fs.Name())
+	}
+	return fs.Args(), nil
+}
+
+// input returns the content of the file named by args[i], or of stdin if
+// there is no such argument or it is "-".
+func (c *cli) input(args []string, i int) ([]byte, error) {
+	if i < len(args) && args[i] != "-" {
+		return os.ReadFile(args[i])
+	}
+	return io.ReadAll(c.stdin)
+}
+
+func (c *cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printFiles prints one line per file: ID, modification time and name,
+// with a trailing slash on folders.
+func (c *cli) printFiles(files []adapter.FileMetadata) error {
+	if c.json {
+		return c.printJSON(files)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, f := range files {
+		name := f.Name
+		if f.MIMEType == folderMIMEType {
+			name += "/"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.ID, f.ModifiedTime.Local().Format("2006-01-02 15:04"), name)
+	}
+	return w.Flush()
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	args, err := c.parseArgs(flag.NewFlagSet("list", flag.ContinueOnError), args, 0, 1)
+	if err != nil {
+		return err
+	}
+	var folderID string
+	if len(args) > 0 {
+		folderID = args[0]
+	}
+	files, err := c.client.ListNotes(ctx, folderID)
+	if err != nil {
+		return err
+	}
+	return c.printFiles(files)
+}
+
+func (c *cli) search(ctx context.Context, args []string) error {
+	args, err := c.parseArgs(flag.NewFlagSet("search", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	files, err := c.client.Search(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.printFiles(files)
+}
+
+func (c *cli) get(ctx context.Context, args []string) error {
+	args, err := c.parseArgs(flag.NewFlagSet("get", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	note, err := c.client.GetNote(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(note)
+	}
+	if note.Encrypted {
+		return errors.New("the note is encrypted end to end; open it in the web app")
+	}
+	_, err = io.WriteString(c.stdout, note.Content)
+	return err
+}
+
+func (c *cli) put(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("put", flag.ContinueOnError)
+	ifMatch := fs.String("if-match", "", "only save if the note still has this ETag")
+	args, err := c.parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	content, err := c.input(args, 1)
+	if err != nil {
+		return err
+	}
+	file, err := c.client.SaveNote(ctx, args[0], string(content), *ifMatch)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(file)
+	}
+	return nil
+}
+
+func (c *cli) create(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	folder := fs.String("folder", "", "ID of the folder to create the note in (default the base folder)")
+	args, err := c.parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	content, err := c.input(args, 1)
+	if err != nil {
+		return err
+	}
+	name := args[0]
+	if !strings.HasSuffix(name, ".md") {
+		name += ".md"
+	}
+	file, err := c.client.CreateNote(ctx, name, string(content), *folder)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(file)
+	}
+	_, err = fmt.Fprintln(c.stdout, file.ID)
+	return err
+}
+
+// edit opens the note in the user's editor and saves it if it changed. The
+// save fails if the note was changed elsewhere meanwhile; the edited copy
+// is then kept so the work is not lost.
+func (c *cli) edit(ctx context.Context, args []string) error {
+	args, err := c.parseArgs(flag.NewFlagSet("edit", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	note, err := c.client.GetNote(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if note.Encrypted {
+		return errors.New("the note is encrypted end to end; open it in the web app")
+	}
+
+	tmp, err := os.CreateTemp("", "gophdrive-*-"+filepath.Base(strings.TrimSuffix(note.Name, ".md"))+".md")
+	if err != nil {
+		return err
+	}
+	path := tmp.Name()
+	_, err = tmp.WriteString(note.Content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	editor := c.getenv("VISUAL")
+	if editor == "" {
+		editor = c.getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor setting may carry arguments, as in "code --wait".
+	fields := strings.Fields(editor)
+	cmd := c.command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("%s: %w", editor, err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if string(content) == note.Content {
+		os.Remove(path)
+		fmt.Fprintln(c.stderr, "No changes.")
+		return nil
+	}
+	if _, err := c.client.SaveNote(ctx, note.ID, string(content), note.ETag); err != nil {
+		return fmt.Errorf("%w; your edits are in %s", err, path)
+	}
+	os.Remove(path)
+	return nil
+}
+
+func (c *cli) sync(ctx context.Context, args []string) error {
+	args, err := c.parseArgs(flag.NewFlagSet("sync", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	res, err := syncDir(ctx, c.client, args[0], c.stderr)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		if err := c.printJSON(res); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(c.stdout, "%d pulled, %d pushed, %d removed, %d conflicts\n", res.Pulled, res.Pushed, res.Removed, res.Conflicts)
+	}
+	if res.Conflicts > 0 {
+		return fmt.Errorf("%d notes were not synced; see the conflicts above", res.Conflicts)
+	}
+	return nil
+}
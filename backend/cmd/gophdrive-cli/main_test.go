@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/jun/gophdrive/backend/internal/accesstoken"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/apiclient"
+	"github.com/jun/gophdrive/backend/internal/app"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/journal"
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+const testUserID = "user-1"
+
+// newTestServer serves the note, search and sync routes the CLI uses from
+// the real handlers over an in-memory store, and returns the user's storage
+// and an access token for them.
+func newTestServer(t *testing.T) (string, adapter.StorageAdapter, string) {
+	t.Helper()
+	ctx := context.Background()
+	provider := memory.NewProvider(nil, nil)
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+
+	signer := crypto.NewSigner(secret.Static("signing-key"))
+	store := accesstoken.NewStore(nil, "")
+	tok := accesstoken.Token{UserID: testUserID, ID: "t1", ExpiresAt: time.Now().Add(time.Hour)}
+	store.Create(ctx, tok)
+	raw, _ := accesstoken.Sign(ctx, signer, tok)
+	tokens := handler.NewAccessTokenHandler(store, signer, "jwt-secret")
+
+	notes := handler.NewNoteHandler(provider, "jwt-secret")
+	search := handler.NewSearchHandler(provider, "jwt-secret")
+	sync := handler.NewSyncHandler(provider, journal.NewStore(nil, ""), "jwt-secret")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := app.ProxyRequestFromHTTP(r)
+		claims, err := tokens.Authenticate(r.Context(), req)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ctx := handler.WithUserClaims(r.Context(), claims)
+
+		var h func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+		path := strings.TrimPrefix(r.URL.Path, "/api")
+		switch id, isNote := strings.CutPrefix(path, "/notes/"); {
+		case r.Method == "GET" && path == "/notes":
+			h = notes.ListNotes
+		case r.Method == "POST" && path == "/notes":
+			h = notes.CreateNote
+		case r.Method == "GET" && isNote:
+			req.PathParameters = map[string]string{"id": id}
+			h = notes.GetNote
+		case r.Method == "PUT" && isNote:
+			req.PathParameters = map[string]string{"id": id}
+			h = notes.UpdateNote
+		case path == "/search":
+			h = search.Search
+		case path == "/sync/manifest":
+			h = sync.Manifest
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		resp, _ := h(ctx, req)
+		app.WriteProxyResponse(w, resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/api", storage, raw
+}
+
+func newTestCLI(url, token string) (*cli, *bytes.Buffer) {
+	var out bytes.Buffer
+	env := map[string]string{"GOPHDRIVE_URL": url, "GOPHDRIVE_TOKEN": token}
+	return &cli{
+		stdin:   strings.NewReader(""),
+		stdout:  &out,
+		stderr:  &bytes.Buffer{},
+		getenv:  func(k string) string { return env[k] },
+		command: exec.Command,
+	}, &out
+}
+
+func TestCommands(t *testing.T) {
+	url, storage, token := newTestServer(t)
+	ctx := context.Background()
+	note, _ := storage.CreateFile(ctx, "Groceries.md", []byte("- milk\n"), "")
+
+	c, out := newTestCLI(url, token)
+	if err := c.run(ctx, []string{"list"}); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if !strings.Contains(out.String(), note.ID) || !strings.Contains(out.String(), "Groceries") {
+		t.Errorf("list printed %q", out)
+	}
+
+	out.Reset()
+	if err := c.run(ctx, []string{"get", note.ID}); err != nil || out.String() != "- milk\n" {
+		t.Errorf("get = %q, %v", out, err)
+	}
+
+	stale := note.ETag // the memory adapter updates note in place
+	c.stdin = strings.NewReader("- milk\n- eggs\n")
+	if err := c.run(ctx, []string{"put", note.ID}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if f, _ := storage.GetFile(ctx, note.ID); string(f.Content) != "- milk\n- eggs\n" {
+		t.Errorf("content after put = %q", f.Content)
+	}
+	err := c.run(ctx, []string{"put", "-if-match", stale, note.ID})
+	if err == nil || !strings.Contains(err.Error(), "changed on the server") {
+		t.Errorf("put with a stale ETag error = %v", err)
+	}
+
+	out.Reset()
+	c.stdin = strings.NewReader("call the plumber\n")
+	if err := c.run(ctx, []string{"create", "Chores"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	created, err := storage.GetFile(ctx, strings.TrimSpace(out.String()))
+	if err != nil || string(created.Content) != "call the plumber\n" {
+		t.Errorf("created note = %+v, %v", created, err)
+	}
+
+	out.Reset()
+	if err := c.run(ctx, []string{"search", "plumber"}); err != nil || !strings.Contains(out.String(), created.ID) {
+		t.Errorf("search printed %q, %v", out, err)
+	}
+
+	bad, _ := newTestCLI(url, "gdp_not-a-token")
+	if err := bad.run(ctx, []string{"list"}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("list with a bad token error = %v", err)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	url, storage, token := newTestServer(t)
+	ctx := context.Background()
+	note, _ := storage.CreateFile(ctx, "Todo.md", []byte("draft\n"), "")
+
+	c, _ := newTestCLI(url, token)
+	env := c.getenv
+	c.getenv = func(k string) string {
+		if k == "EDITOR" {
+			return "myeditor --wait"
+		}
+		return env(k)
+	}
+	// Stand in for the editor with a script that appends a line.
+	c.command = func(name string, args ...string) *exec.Cmd {
+		if name != "myeditor" || len(args) != 2 || args[0] != "--wait" {
+			t.Errorf("editor command = %s %q", name, args)
+		}
+		return exec.Command("sh", "-c", `echo edited >> "$1"`, "sh", args[len(args)-1])
+	}
+	if err := c.run(ctx, []string{"edit", note.ID}); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if f, _ := storage.GetFile(ctx, note.ID); string(f.Content) != "draft\nedited\n" {
+		t.Errorf("content after edit = %q", f.Content)
+	}
+}
+
+func TestSync(t *testing.T) {
+	url, storage, token := newTestServer(t)
+	ctx := context.Background()
+	a, _ := storage.CreateFile(ctx, "Alpha.md", []byte("a1"), "")
+	b, _ := storage.CreateFile(ctx, "Beta.md", []byte("b1"), "")
+	c, _ := storage.CreateFile(ctx, "Gamma.md", []byte("c1"), "")
+	dir := t.TempDir()
+	cl := apiclient.New(url, token, nil)
+	var log bytes.Buffer
+
+	res, err := syncDir(ctx, cl, dir, &log)
+	if err != nil || res.Pulled != 3 {
+		t.Fatalf("first sync = %+v, %v", res, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "Alpha.md")); string(got) != "a1" {
+		t.Fatalf("Alpha.md = %q", got)
+	}
+
+	// Alpha is edited here, Beta on the server, Gamma in both places.
+	os.WriteFile(filepath.Join(dir, "Alpha.md"), []byte("a2"), 0o644)
+	storage.SaveFile(ctx, b.ID, []byte("b2"), "")
+	os.WriteFile(filepath.Join(dir, "Gamma.md"), []byte("c-local"), 0o644)
+	storage.SaveFile(ctx, c.ID, []byte("c-remote"), "")
+
+	res, err = syncDir(ctx, cl, dir, &log)
+	if err != nil || res != (syncResult{Pulled: 1, Pushed: 1, Conflicts: 1}) {
+		t.Fatalf("second sync = %+v, %v\n%s", res, err, log.String())
+	}
+	if f, _ := storage.GetFile(ctx, a.ID); string(f.Content) != "a2" {
+		t.Errorf("Alpha on the server = %q", f.Content)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "Beta.md")); string(got) != "b2" {
+		t.Errorf("Beta.md = %q", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "Gamma.md")); string(got) != "c-local" {
+		t.Errorf("Gamma.md = %q; the local edit must be kept", got)
+	}
+
+	// A note deleted on the server is removed here unless it was edited.
+	storage.DeleteFile(ctx, b.ID)
+	res, err = syncDir(ctx, cl, dir, &log)
+	if err != nil || res.Removed != 1 {
+		t.Fatalf("third sync = %+v, %v", res, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Beta.md")); !os.IsNotExist(err) {
+		t.Errorf("Beta.md was not removed: %v", err)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "mine.md"), nil, 0o644)
+	s := &syncState{Notes: map[string]*syncedNote{"x": {File: "Plan.md"}}}
+	for name, want := range map[string]string{
+		"../../etc/passwd": "_.._etc_passwd.md",
+		".gophdrive-sync":  "gophdrive-sync.md",
+		"plan.md":          "plan (abcdef12).md",
+		"mine":             "mine (abcdef12).md",
+		"":                 "untitled.md",
+	} {
+		if got := s.fileName(dir, name, "abcdef123456"); got != want {
+			t.Errorf("fileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
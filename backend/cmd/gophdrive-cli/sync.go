@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jun/gophdrive/backend/internal/apiclient"
+)
+
+// stateFile records, in the synced directory, which file holds each note
+// and the version last synced.
+const stateFile = ".gophdrive-sync.json"
+
+// syncState is the content of stateFile, keyed by note ID.
+type syncState struct {
+	Notes map[string]*syncedNote `json:"notes"`
+}
+
+type syncedNote struct {
+	File string `json:"file"`
+	ETag string `json:"etag"`
+	// Hash is the SHA-256 of the content last written or pushed, which
+	// tells whether the file was edited since.
+	Hash string `json:"hash,omitempty"`
+	// Encrypted notes are not downloaded; the server only has ciphertext.
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+// syncResult counts what syncDir did.
+type syncResult struct {
+	Pulled    int `json:"pulled"`
+	Pushed    int `json:"pushed"`
+	Removed   int `json:"removed"`
+	Conflicts int `json:"conflicts"`
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// syncDir mirrors every note into dir as a flat Markdown file and pushes
+// back the files edited since the last sync. A note edited both locally
+// and on the server is left alone and reported as a conflict. Notes are
+// never created or deleted on the server: files the sync does not know are
+// ignored, a deleted file is downloaded again, and a file whose note was
+// deleted is removed unless it has local edits.
+func syncDir(ctx context.Context, c *apiclient.Client, dir string, log io.Writer) (syncResult, error) {
+	var res syncResult
+	state, err := loadState(dir)
+	if err != nil {
+		return res, err
+	}
+	entries, err := c.Manifest(ctx)
+	if err != nil {
+		return res, err
+	}
+	remote := make(map[string]string, len(entries)) // ID to ETag
+	for _, e := range entries {
+		remote[e.ID] = e.ETag
+	}
+
+	// Save after every note, so an interrupted sync does not forget what
+	// it already did.
+	save := func() error { return saveState(dir, state) }
+
+	ids := make([]string, 0, len(state.Notes))
+	for id := range state.Notes {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		n := state.Notes[id]
+		etag, exists := remote[id]
+		if n.Encrypted {
+			if !exists {
+				delete(state.Notes, id)
+			} else if etag == n.ETag {
+				delete(remote, id)
+			}
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, n.File))
+		if errors.Is(err, fs.ErrNotExist) {
+			// Download it again below, unless the note is gone too.
+			delete(state.Notes, id)
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		edited := hashContent(content) != n.Hash
+
+		switch {
+		case !exists && !edited:
+			if err := os.Remove(filepath.Join(dir, n.File)); err != nil {
+				return res, err
+			}
+			delete(state.Notes, id)
+			res.Removed++
+			fmt.Fprintf(log, "removed   %s (deleted on the server)\n", n.File)
+		case !exists:
+			delete(state.Notes, id)
+			res.Conflicts++
+			fmt.Fprintf(log, "conflict  %s: deleted on the server but edited here; the file is no longer synced\n", n.File)
+		case edited && etag == n.ETag:
+			file, err := c.SaveNote(ctx, id, string(content), n.ETag)
+			if errors.Is(err, apiclient.ErrETagMismatch) {
+				res.Conflicts++
+				fmt.Fprintf(log, "conflict  %s: edited here and on the server\n", n.File)
+				break
+			}
+			if err != nil {
+				return res, fmt.Errorf("push %s: %w", n.File, err)
+			}
+			n.ETag, n.Hash = file.ETag, hashContent(content)
+			res.Pushed++
+			fmt.Fprintf(log, "pushed    %s\n", n.File)
+		case edited:
+			res.Conflicts++
+			fmt.Fprintf(log, "conflict  %s: edited here and on the server\n", n.File)
+		case etag != n.ETag:
+			// Pulled below, into the same file.
+			continue
+		}
+		delete(remote, id)
+		if err := save(); err != nil {
+			return res, err
+		}
+	}
+
+	// Pull new notes and notes changed only on the server, in manifest order.
+	for _, e := range entries {
+		if _, ok := remote[e.ID]; !ok {
+			continue
+		}
+		note, err := c.GetNote(ctx, e.ID)
+		if err != nil {
+			return res, fmt.Errorf("pull %s: %w", e.ID, err)
+		}
+		n := state.Notes[e.ID]
+		if n == nil {
+			n = &syncedNote{File: state.fileName(dir, note.Name, note.ID)}
+			state.Notes[e.ID] = n
+		}
+		n.ETag = note.ETag
+		if note.Encrypted {
+			n.Encrypted, n.Hash = true, ""
+			fmt.Fprintf(log, "skipped   %s (encrypted)\n", n.File)
+		} else {
+			n.Encrypted = false
+			if err := os.WriteFile(filepath.Join(dir, n.File), []byte(note.Content), 0o644); err != nil {
+				return res, err
+			}
+			n.Hash = hashContent([]byte(note.Content))
+			res.Pulled++
+			fmt.Fprintf(log, "pulled    %s\n", n.File)
+		}
+		if err := save(); err != nil {
+			return res, err
+		}
+	}
+	return res, save()
+}
+
+// fileName returns a file name for a new note that no synced note or other
+// file in dir uses. Path separators and leading dots are replaced, so notes
+// cannot write outside the directory or over the state file.
+func (s *syncState) fileName(dir, name, id string) string {
+	base := strings.TrimSuffix(name, ".md")
+	base = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, base)
+	base = strings.TrimLeft(base, ".")
+	if strings.TrimSpace(base) == "" {
+		base = "untitled"
+	}
+
+	taken := func(file string) bool {
+		if _, err := os.Lstat(filepath.Join(dir, file)); err == nil {
+			return true
+		}
+		return slices.ContainsFunc(s.files(), func(f string) bool { return strings.EqualFold(f, file) })
+	}
+	file := base + ".md"
+	if taken(file) {
+		file = base + " (" + id[:min(8, len(id))] + ").md"
+	}
+	for i := 2; taken(file); i++ {
+		file = fmt.Sprintf("%s (%s %d).md", base, id[:min(8, len(id))], i)
+	}
+	return file
+}
+
+func (s *syncState) files() []string {
+	files := make([]string, 0, len(s.Notes))
+	for _, n := range s.Notes {
+		files = append(files, n.File)
+	}
+	return files
+}
+
+func loadState(dir string) (*syncState, error) {
+	state := &syncState{Notes: map[string]*syncedNote{}}
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, os.MkdirAll(dir, 0o755)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("read %s: %w", stateFile, err)
+	}
+	if state.Notes == nil {
+		state.Notes = map[string]*syncedNote{}
+	}
+	return state, nil
+}
+
+func saveState(dir string, state *syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, stateFile), data, 0o644)
+}
@@ -0,0 +1,52 @@
+// Command mcp serves GophDrive notes to AI assistants over the Model
+// Context Protocol. Assistants start it as a local stdio server; it calls
+// the GophDrive API with a personal access token, so it can only reach the
+// notes of the token's owner, and revoking the token cuts it off. For
+// example, in an assistant's MCP server settings:
+//
+//	{
+//	  "command": "/path/to/gophdrive-mcp",
+//	  "env": {
+//	    "GOPHDRIVE_URL": "https://notes.example.com/api",
+//	    "GOPHDRIVE_TOKEN": "gdp_..."
+//	  }
+//	}
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/jun/gophdrive/backend/internal/apiclient"
+)
+
+func main() {
+	baseURL := flag.String("url", os.Getenv("GOPHDRIVE_URL"), "API base URL, such as https://notes.example.com/api (GOPHDRIVE_URL)")
+	timeout := flag.Duration("timeout", time.Minute, "timeout of each API request")
+	flag.Parse()
+
+	// stdout carries the protocol, so logs go to stderr, which assistants
+	// usually keep in their MCP logs.
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	token := os.Getenv("GOPHDRIVE_TOKEN")
+	if *baseURL == "" || token == "" {
+		logger.Error("Set GOPHDRIVE_URL and GOPHDRIVE_TOKEN to the API URL and a personal access token")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := apiclient.New(*baseURL, token, &http.Client{Timeout: *timeout})
+	if err := newServer(client, logger).Run(ctx, &mcp.StdioTransport{}); err != nil && ctx.Err() == nil {
+		logger.Error("MCP server failed", "error", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/apiclient"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+// version is reported to clients in the MCP handshake.
+const version = "1.0.0"
+
+// folderMIMEType marks folders in listings.
+const folderMIMEType = "application/vnd.google-apps.folder"
+
+// noteURIPrefix starts the resource URI of a note, followed by its ID.
+const noteURIPrefix = "gophdrive://notes/"
+
+// errEncrypted is returned for end-to-end encrypted notes, whose content
+// the server only has as ciphertext.
+var errEncrypted = errors.New("the note is encrypted end to end; it can only be read in the GophDrive app")
+
+// notes is the part of the API the server uses, implemented by
+// apiclient.Client.
+type notes interface {
+	ListNotes(ctx context.Context, folderID string) ([]adapter.FileMetadata, error)
+	Search(ctx context.Context, q string) ([]adapter.FileMetadata, error)
+	GetNote(ctx context.Context, id string) (*handler.NoteResponse, error)
+	CreateNote(ctx context.Context, name, content, parentID string) (*adapter.FileMetadata, error)
+}
+
+type listInput struct {
+	FolderID string `json:"folderId,omitempty" jsonschema:"ID of the folder to list; the user's base folder if omitted"`
+}
+
+type searchInput struct {
+	Query string `json:"query" jsonschema:"text to find in note names and content"`
+}
+
+type readInput struct {
+	ID string `json:"id" jsonschema:"ID of the note, from list_notes or search_notes"`
+}
+
+type createInput struct {
+	Name     string `json:"name" jsonschema:"title of the note; .md is added if missing"`
+	Content  string `json:"content" jsonschema:"Markdown content of the note"`
+	FolderID string `json:"folderId,omitempty" jsonschema:"ID of the folder to create the note in; the user's base folder if omitted"`
+}
+
+// fileEntry is a note or folder in tool results.
+type fileEntry struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Folder   bool      `json:"folder,omitempty"`
+	Modified time.Time `json:"modified"`
+	URI      string    `json:"uri,omitempty" jsonschema:"resource URI of the note's content"`
+}
+
+type fileList struct {
+	Files []fileEntry `json:"files"`
+}
+
+type noteOutput struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Modified string `json:"modified"`
+	Content  string `json:"content"`
+}
+
+func toEntry(f adapter.FileMetadata) fileEntry {
+	e := fileEntry{ID: f.ID, Name: f.Name, Folder: f.MIMEType == folderMIMEType, Modified: f.ModifiedTime}
+	if !e.Folder {
+		e.URI = noteURIPrefix + f.ID
+	}
+	return e
+}
+
+func toList(files []adapter.FileMetadata) fileList {
+	list := fileList{Files: make([]fileEntry, 0, len(files))}
+	for _, f := range files {
+		list.Files = append(list.Files, toEntry(f))
+	}
+	return list
+}
+
+// newServer creates an MCP server with tools to list, search, read and
+// create the user's notes, and each note as a resource.
+func newServer(api notes, logger *slog.Logger) *mcp.Server {
+	s := mcp.NewServer(&mcp.Implementation{Name: "gophdrive", Title: "GophDrive", Version: version}, &mcp.ServerOptions{
+		Instructions: "GophDrive holds the user's Markdown notes in folders. Find notes with search_notes or list_notes, then read them with read_note. Only create notes the user asked for.",
+		Logger:       logger,
+	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "list_notes",
+		Description: "List the notes and subfolders in a folder.",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in listInput) (*mcp.CallToolResult, fileList, error) {
+		files, err := api.ListNotes(ctx, in.FolderID)
+		if err != nil {
+			return nil, fileList{}, err
+		}
+		return nil, toList(files), nil
+	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "search_notes",
+		Description: "Search all notes by name and content.",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in searchInput) (*mcp.CallToolResult, fileList, error) {
+		if strings.TrimSpace(in.Query) == "" {
+			return nil, fileList{}, errors.New("query must not be empty")
+		}
+		files, err := api.Search(ctx, in.Query)
+		if err != nil {
+			return nil, fileList{}, err
+		}
+		return nil, toList(files), nil
+	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "read_note",
+		Description: "Read the Markdown content of a note.",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in readInput) (*mcp.CallToolResult, noteOutput, error) {
+		note, err := readNote(ctx, api, in.ID)
+		if err != nil {
+			return nil, noteOutput{}, err
+		}
+		return nil, noteOutput{ID: note.ID, Name: note.Name, Modified: note.Modified, Content: note.Content}, nil
+	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "create_note",
+		Description: "Create a new Markdown note. Existing notes are never changed.",
+		Annotations: &mcp.ToolAnnotations{DestructiveHint: new(false)},
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in createInput) (*mcp.CallToolResult, fileEntry, error) {
+		name := strings.TrimSpace(in.Name)
+		if name == "" {
+			return nil, fileEntry{}, errors.New("name must not be empty")
+		}
+		if !strings.HasSuffix(name, ".md") {
+			name += ".md"
+		}
+		file, err := api.CreateNote(ctx, name, in.Content, in.FolderID)
+		if err != nil {
+			return nil, fileEntry{}, err
+		}
+		return nil, toEntry(*file), nil
+	})
+
+	s.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "note",
+		Title:       "GophDrive note",
+		Description: "The Markdown content of a note, by ID.",
+		URITemplate: noteURIPrefix + "{id}",
+		MIMEType:    "text/markdown",
+	}, func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		uri := req.Params.URI
+		id, _ := strings.CutPrefix(uri, noteURIPrefix)
+		note, err := readNote(ctx, api, id)
+		var apiErr *apiclient.Error
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{{URI: uri, MIMEType: "text/markdown", Text: note.Content}},
+		}, nil
+	})
+	return s
+}
+
+// readNote returns note id, or errEncrypted if it cannot be read.
+func readNote(ctx context.Context, api notes, id string) (*handler.NoteResponse, error) {
+	if id == "" || strings.Contains(id, "/") {
+		return nil, errors.New("invalid note ID")
+	}
+	note, err := api.GetNote(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if note.Encrypted {
+		return nil, errEncrypted
+	}
+	return note, nil
+}
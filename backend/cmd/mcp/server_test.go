@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/apiclient"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+// fakeNotes serves notes from a map.
+type fakeNotes struct {
+	notes   map[string]*handler.NoteResponse
+	created []string
+}
+
+func (f *fakeNotes) ListNotes(ctx context.Context, folderID string) ([]adapter.FileMetadata, error) {
+	files := []adapter.FileMetadata{{ID: "folder-1", Name: "Work", MIMEType: folderMIMEType}}
+	for _, n := range f.notes {
+		files = append(files, adapter.FileMetadata{ID: n.ID, Name: n.Name, ModifiedTime: time.Now()})
+	}
+	return files, nil
+}
+
+func (f *fakeNotes) Search(ctx context.Context, q string) ([]adapter.FileMetadata, error) {
+	var files []adapter.FileMetadata
+	for _, n := range f.notes {
+		if strings.Contains(n.Content, q) {
+			files = append(files, adapter.FileMetadata{ID: n.ID, Name: n.Name})
+		}
+	}
+	return files, nil
+}
+
+func (f *fakeNotes) GetNote(ctx context.Context, id string) (*handler.NoteResponse, error) {
+	n, ok := f.notes[id]
+	if !ok {
+		return nil, &apiclient.Error{Status: http.StatusNotFound, ErrorResponse: handler.ErrorResponse{Code: "not_found", Message: "Note not found"}}
+	}
+	return n, nil
+}
+
+func (f *fakeNotes) CreateNote(ctx context.Context, name, content, parentID string) (*adapter.FileMetadata, error) {
+	f.created = append(f.created, name+"|"+content+"|"+parentID)
+	return &adapter.FileMetadata{ID: "new-1", Name: name}, nil
+}
+
+func connect(t *testing.T, api notes) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := newServer(api, nil).Connect(ctx, serverTransport, nil); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "v0"}, nil).Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+// call calls a tool and decodes its structured result into out. It returns
+// the text of a tool error.
+func call(t *testing.T, cs *mcp.ClientSession, name string, args map[string]any, out any) string {
+	t.Helper()
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if res.IsError {
+		return res.Content[0].(*mcp.TextContent).Text
+	}
+	data, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(data, out); err != nil {
+		t.Fatalf("%s result: %v", name, err)
+	}
+	return ""
+}
+
+func TestServer_Tools(t *testing.T) {
+	api := &fakeNotes{notes: map[string]*handler.NoteResponse{
+		"n1": {ID: "n1", Name: "Plan.md", Content: "# Plan\nship it\n"},
+		"n2": {ID: "n2", Name: "Secret.md", Content: "GDENC:...", Encrypted: true},
+	}}
+	cs := connect(t, api)
+
+	var list fileList
+	call(t, cs, "list_notes", map[string]any{}, &list)
+	if len(list.Files) != 3 || !list.Files[0].Folder || list.Files[0].URI != "" {
+		t.Errorf("list_notes = %+v", list.Files)
+	}
+
+	call(t, cs, "search_notes", map[string]any{"query": "ship"}, &list)
+	if len(list.Files) != 1 || list.Files[0].ID != "n1" || list.Files[0].URI != "gophdrive://notes/n1" {
+		t.Errorf("search_notes = %+v", list.Files)
+	}
+
+	var note noteOutput
+	call(t, cs, "read_note", map[string]any{"id": "n1"}, &note)
+	if note.Content != "# Plan\nship it\n" || note.Name != "Plan.md" {
+		t.Errorf("read_note = %+v", note)
+	}
+	if msg := call(t, cs, "read_note", map[string]any{"id": "n2"}, &note); !strings.Contains(msg, "encrypted") {
+		t.Errorf("read_note of an encrypted note error = %q", msg)
+	}
+	if msg := call(t, cs, "read_note", map[string]any{"id": "missing"}, &note); !strings.Contains(msg, "404") {
+		t.Errorf("read_note of a missing note error = %q", msg)
+	}
+
+	var created fileEntry
+	call(t, cs, "create_note", map[string]any{"name": "Ideas", "content": "- one\n", "folderId": "folder-1"}, &created)
+	if created.ID != "new-1" || len(api.created) != 1 || api.created[0] != "Ideas.md|- one\n|folder-1" {
+		t.Errorf("create_note = %+v, created %q", created, api.created)
+	}
+	// Required arguments are checked against the schema.
+	if msg := call(t, cs, "create_note", map[string]any{"name": "x"}, &created); msg == "" || len(api.created) != 1 {
+		t.Errorf("create_note without content error = %q", msg)
+	}
+}
+
+func TestServer_NoteResource(t *testing.T) {
+	cs := connect(t, &fakeNotes{notes: map[string]*handler.NoteResponse{
+		"n1": {ID: "n1", Name: "Plan.md", Content: "# Plan\n"},
+	}})
+	ctx := context.Background()
+
+	res, err := cs.ReadResource(ctx, &mcp.ReadResourceParams{URI: "gophdrive://notes/n1"})
+	if err != nil || res.Contents[0].Text != "# Plan\n" || res.Contents[0].MIMEType != "text/markdown" {
+		t.Fatalf("ReadResource = %+v, %v", res, err)
+	}
+	if _, err := cs.ReadResource(ctx, &mcp.ReadResourceParams{URI: "gophdrive://notes/missing"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("ReadResource of a missing note error = %v", err)
+	}
+}
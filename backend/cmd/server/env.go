@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envFile loads KEY=VALUE settings from a .env file into the process
+// environment. Variables already set when the server started win over the
+// file, as with docker-compose; variables the file set earlier and no longer
+// lists are unset again on reload.
+type envFile struct {
+	path    string
+	base    map[string]bool   // keys present in the real environment
+	applied map[string]string // keys set from the file
+}
+
+func newEnvFile(path string) *envFile {
+	base := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		if k, _, ok := strings.Cut(kv, "="); ok {
+			base[k] = true
+		}
+	}
+	return &envFile{path: path, base: base, applied: make(map[string]string)}
+}
+
+// load (re)applies the file and returns the keys whose value changed. A
+// missing file counts as empty.
+func (e *envFile) load() ([]string, error) {
+	values, err := readEnvFile(e.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var changed []string
+	for k, v := range values {
+		if e.base[k] || e.applied[k] == v {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return changed, fmt.Errorf("set %s: %w", k, err)
+		}
+		e.applied[k] = v
+		changed = append(changed, k)
+	}
+	for k := range e.applied {
+		if _, ok := values[k]; !ok {
+			os.Unsetenv(k)
+			delete(e.applied, k)
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// readEnvFile parses a .env file: blank lines and # comments are skipped,
+// an optional "export " prefix is allowed, and values may be single- or
+// double-quoted (double quotes support Go escapes).
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
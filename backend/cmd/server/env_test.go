@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := "# comment\n\nexport FRONTEND_URL=http://localhost:3000 # trailing\nJWT_SECRET=\"a\\\"b\"\nLOCK_TTL='5m'\nEMPTY=\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"FRONTEND_URL": "http://localhost:3000",
+		"JWT_SECRET":   `a"b`,
+		"LOCK_TTL":     "5m",
+		"EMPTY":        "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readEnvFile = %v, want %v", got, want)
+	}
+}
+
+func TestReadEnvFile_InvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(path, []byte("OK=1\nnot a setting\n"), 0o600)
+	if _, err := readEnvFile(path); err == nil {
+		t.Error("expected an error for a line without '='")
+	}
+}
+
+func TestEnvFile_Reload(t *testing.T) {
+	t.Setenv("GOPHDRIVE_TEST_BASE", "from-env")
+	path := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(path, []byte("GOPHDRIVE_TEST_BASE=from-file\nGOPHDRIVE_TEST_A=1\nGOPHDRIVE_TEST_B=2\n"), 0o600)
+	t.Cleanup(func() {
+		os.Unsetenv("GOPHDRIVE_TEST_A")
+		os.Unsetenv("GOPHDRIVE_TEST_B")
+	})
+
+	env := newEnvFile(path)
+	changed, err := env.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{"GOPHDRIVE_TEST_A", "GOPHDRIVE_TEST_B"}) {
+		t.Errorf("first load changed %v", changed)
+	}
+	if got := os.Getenv("GOPHDRIVE_TEST_BASE"); got != "from-env" {
+		t.Errorf("real environment overridden: %q", got)
+	}
+
+	os.WriteFile(path, []byte("GOPHDRIVE_TEST_A=10\n"), 0o600)
+	changed, _ = env.load()
+	if !reflect.DeepEqual(changed, []string{"GOPHDRIVE_TEST_A", "GOPHDRIVE_TEST_B"}) {
+		t.Errorf("reload changed %v", changed)
+	}
+	if os.Getenv("GOPHDRIVE_TEST_A") != "10" {
+		t.Errorf("GOPHDRIVE_TEST_A = %q, want 10", os.Getenv("GOPHDRIVE_TEST_A"))
+	}
+	if _, ok := os.LookupEnv("GOPHDRIVE_TEST_B"); ok {
+		t.Error("GOPHDRIVE_TEST_B should be unset after it was removed from the file")
+	}
+}
@@ -2,39 +2,88 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
 
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/jun/gophdrive/backend/internal/app"
+	"github.com/jun/gophdrive/backend/internal/config"
+	"github.com/jun/gophdrive/backend/internal/logging"
+	"github.com/jun/gophdrive/backend/internal/tracing"
 )
 
+// maxRequestBytes is API Gateway's payload limit.
+const maxRequestBytes = 10 << 20
+
 func main() {
-	application := app.NewApp(context.Background())
+	envPath := flag.String("env", ".env", "file of KEY=VALUE settings to load")
+	watch := flag.Bool("watch", false, "reload settings when the env file changes")
+	flag.Parse()
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		body, _ := io.ReadAll(r.Body)
+	env := newEnvFile(*envPath)
+	_, envErr := env.load()
+	logging.Setup(os.Getenv("DEV_MODE") == "true")
+	if envErr != nil {
+		slog.Error("Load env file failed", "path", *envPath, "error", envErr)
+		os.Exit(1)
+	}
+	if err := tracing.Setup(context.Background()); err != nil {
+		slog.Warn("Tracing setup failed; continuing without tracing", "error", err)
+	}
+	cfg, err := config.FromEnvironment(context.Background())
+	if err != nil {
+		slog.Error("Load configuration failed", "error", err)
+		os.Exit(1)
+	}
+	fmt.Print("Effective configuration:\n", cfg)
 
-		headers := make(map[string]string)
-		for k, v := range r.Header {
-			headers[k] = v[0]
+	var current atomic.Pointer[app.App]
+	current.Store(app.NewApp(context.Background(), cfg))
+	if *watch {
+		go watchEnv(env, &current)
+	}
+	// Stand in for the EventBridge rule that runs the scheduled jobs on
+	// Lambda.
+	go func() {
+		for range time.Tick(app.ScheduleInterval) {
+			if err := current.Load().RunScheduledJobs(context.Background()); err != nil {
+				slog.Error("Scheduled jobs failed", "error", err)
+			}
 		}
+	}()
 
-		queryParams := make(map[string]string)
-		for k, v := range r.URL.Query() {
-			queryParams[k] = v[0]
-		}
+	http.Handle("/sessions/events", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current.Load().PresenceStream().ServeHTTP(w, r)
+	}))
+	http.Handle("/dav/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current.Load().WebDAV().ServeHTTP(w, r)
+	}))
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		application := current.Load()
 
-		req := events.APIGatewayProxyRequest{
-			Path:                  r.URL.Path,
-			HTTPMethod:            r.Method,
-			Headers:               headers,
-			QueryStringParameters: queryParams,
-			Body:                  string(body),
-			IsBase64Encoded:       false,
+		// Emulate API Gateway: build the same proxy event Lambda receives
+		// (multi-value headers and query, base64 for binary bodies) and
+		// invoke the Lambda entry point rather than the HTTP handler.
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+		req, err := app.ProxyRequestFromHTTP(r)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request Too Long", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		req.RequestContext.RequestID = requestID()
+		req.RequestContext.Stage = "local"
 
 		resp, err := application.HandleRequest(context.Background(), req)
 		if err != nil {
@@ -42,13 +91,60 @@ func main() {
 			return
 		}
 
-		for k, v := range resp.Headers {
-			w.Header().Set(k, v)
+		if err := app.WriteProxyResponse(w, resp); err != nil {
+			slog.ErrorContext(r.Context(), "Write response failed", "error", err)
 		}
-		w.WriteHeader(resp.StatusCode)
-		w.Write([]byte(resp.Body))
 	})
 
-	fmt.Println("Starting local server on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	slog.Info("Starting local server", "addr", ":8080")
+	if err := http.ListenAndServe(":8080", nil); err != nil {
+		slog.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// watchEnv polls the env file and, when it changes, reapplies it and
+// rebuilds the app so new settings take effect without a restart. In-memory
+// state (demo notes, collab relays, presence subscribers) starts over.
+func watchEnv(env *envFile, current *atomic.Pointer[app.App]) {
+	slog.Info("Watching env file for changes", "path", env.path)
+	var lastMod time.Time
+	if info, err := os.Stat(env.path); err == nil {
+		lastMod = info.ModTime()
+	}
+	for range time.Tick(time.Second) {
+		var mod time.Time
+		if info, err := os.Stat(env.path); err == nil {
+			mod = info.ModTime()
+		}
+		if mod.Equal(lastMod) {
+			continue
+		}
+		lastMod = mod
+
+		changed, err := env.load()
+		if err != nil {
+			slog.Error("Reload env file failed", "path", env.path, "error", err)
+			continue
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		slog.Info("Reloading configuration", "changed", changed)
+		cfg, err := config.FromEnvironment(context.Background())
+		if err != nil {
+			// Keep serving with the previous configuration.
+			slog.Error("Reload configuration failed", "error", err)
+			continue
+		}
+		fmt.Print("Effective configuration:\n", cfg)
+		current.Store(app.NewApp(context.Background(), cfg))
+	}
+}
+
+// requestID returns a random ID in place of API Gateway's request ID.
+func requestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
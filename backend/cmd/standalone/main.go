@@ -0,0 +1,84 @@
+// Command standalone serves the GophDrive API over plain net/http, for
+// deployments that run the backend as a container instead of on Lambda.
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/app"
+	"github.com/jun/gophdrive/backend/internal/config"
+	"github.com/jun/gophdrive/backend/internal/logging"
+	"github.com/jun/gophdrive/backend/internal/tracing"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	addr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+
+	logging.Setup(os.Getenv("DEV_MODE") == "true")
+	if err := tracing.Setup(ctx); err != nil {
+		slog.Warn("Tracing setup failed; continuing without tracing", "error", err)
+	}
+	defer tracing.Shutdown(context.Background())
+	cfg, err := config.FromEnvironment(ctx)
+	if err != nil {
+		slog.Error("Load configuration failed", "error", err)
+		os.Exit(1)
+	}
+	application := app.NewApp(ctx, cfg)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           application.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// No EventBridge outside Lambda: run the scheduled jobs on a ticker.
+	go func() {
+		ticker := time.NewTicker(app.ScheduleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := application.RunScheduledJobs(ctx); err != nil {
+					slog.Error("Scheduled jobs failed", "error", err)
+				}
+			}
+		}
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		// Give in-flight requests time to finish; open presence streams are
+		// cut when the deadline passes.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Shutdown failed", "error", err)
+		}
+		// Then let the mirror copies the last requests started finish.
+		application.Wait(shutdownCtx)
+		close(stopped)
+	}()
+
+	slog.Info("Starting server", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
+	<-stopped
+}
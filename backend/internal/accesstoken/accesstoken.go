@@ -0,0 +1,249 @@
+// Package accesstoken keeps the personal access tokens users create for
+// scripts and command-line clients. The token itself is a signed value
+// naming the user and token ID; the store only holds each token's
+// description, and deleting it revokes the token.
+package accesstoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jun/gophdrive/backend/internal/crypto"
+)
+
+// MaxPerUser is how many tokens a user can have at once.
+const MaxPerUser = 20
+
+var (
+	// ErrNotFound is returned for a token that does not exist, was
+	// revoked or has expired.
+	ErrNotFound = errors.New("access token not found")
+	// ErrTooMany is returned when a user already has MaxPerUser tokens.
+	ErrTooMany = errors.New("too many access tokens")
+)
+
+// Token describes a personal access token. Email and UserName are the
+// owner's, copied from their session when the token was created, so
+// requests made with it carry the same identity.
+type Token struct {
+	UserID    string    `json:"-" dynamodbav:"user_id"`
+	ID        string    `json:"id" dynamodbav:"token_id"`
+	Name      string    `json:"name" dynamodbav:"name"`
+	Email     string    `json:"-" dynamodbav:"email,omitempty"`
+	UserName  string    `json:"-" dynamodbav:"user_name,omitempty"`
+	CreatedAt time.Time `json:"createdAt" dynamodbav:"created_at"`
+	// ExpiresAt is also stored as Unix seconds in TTL, for DynamoDB TTL to
+	// remove the token once it has expired.
+	ExpiresAt time.Time `json:"expiresAt" dynamodbav:"expires_time"`
+	TTL       int64     `json:"-" dynamodbav:"expires_at"`
+}
+
+// Store persists tokens in a DynamoDB table keyed by user_id and token_id.
+// If client is nil, it uses an in-memory map (for tests).
+type Store struct {
+	client    *dynamodb.Client
+	tableName string
+
+	// Fallback for tests
+	tokens map[string][]Token // by user ID
+	mu     sync.Mutex
+}
+
+// NewStore creates a new access token Store.
+func NewStore(client *dynamodb.Client, tableName string) *Store {
+	return &Store{
+		client:    client,
+		tableName: tableName,
+		tokens:    make(map[string][]Token),
+	}
+}
+
+func key(userID, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"user_id":  &types.AttributeValueMemberS{Value: userID},
+		"token_id": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+// Create stores t. It returns ErrTooMany if the user already has
+// MaxPerUser tokens.
+func (s *Store) Create(ctx context.Context, t Token) error {
+	existing, err := s.List(ctx, t.UserID)
+	if err != nil {
+		return err
+	}
+	if len(existing) >= MaxPerUser {
+		return ErrTooMany
+	}
+	t.TTL = t.ExpiresAt.Unix()
+
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.tokens[t.UserID] = append(s.tokens[t.UserID], t)
+		return nil
+	}
+
+	item, err := attributevalue.MarshalMap(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access token: %w", err)
+	}
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save access token: %w", err)
+	}
+	return nil
+}
+
+// List returns the user's unexpired tokens, newest first.
+func (s *Store) List(ctx context.Context, userID string) ([]Token, error) {
+	var tokens []Token
+	if s.client == nil {
+		s.mu.Lock()
+		tokens = slices.Clone(s.tokens[userID])
+		s.mu.Unlock()
+	} else {
+		paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+			TableName:              aws.String(s.tableName),
+			KeyConditionExpression: aws.String("user_id = :user_id"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":user_id": &types.AttributeValueMemberS{Value: userID},
+			},
+		})
+		for paginator.HasMorePages() {
+			out, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query access tokens: %w", err)
+			}
+			var page []Token
+			if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal access tokens: %w", err)
+			}
+			tokens = append(tokens, page...)
+		}
+	}
+
+	now := time.Now()
+	tokens = slices.DeleteFunc(tokens, func(t Token) bool { return !t.ExpiresAt.After(now) })
+	slices.SortFunc(tokens, func(a, b Token) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	if tokens == nil {
+		tokens = []Token{}
+	}
+	return tokens, nil
+}
+
+// Get returns the user's token id, or ErrNotFound if it was revoked or has
+// expired.
+func (s *Store) Get(ctx context.Context, userID, id string) (*Token, error) {
+	var t Token
+	if s.client == nil {
+		s.mu.Lock()
+		i := slices.IndexFunc(s.tokens[userID], func(t Token) bool { return t.ID == id })
+		if i >= 0 {
+			t = s.tokens[userID][i]
+		}
+		s.mu.Unlock()
+		if i < 0 {
+			return nil, ErrNotFound
+		}
+	} else {
+		out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(s.tableName),
+			Key:            key(userID, id),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get access token: %w", err)
+		}
+		if out.Item == nil {
+			return nil, ErrNotFound
+		}
+		if err := attributevalue.UnmarshalMap(out.Item, &t); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal access token: %w", err)
+		}
+	}
+
+	if !t.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+// Delete revokes the user's token id. It returns ErrNotFound if the user
+// has no such token.
+func (s *Store) Delete(ctx context.Context, userID, id string) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		i := slices.IndexFunc(s.tokens[userID], func(t Token) bool { return t.ID == id })
+		if i < 0 {
+			return ErrNotFound
+		}
+		s.tokens[userID] = slices.Delete(s.tokens[userID], i, i+1)
+		return nil
+	}
+
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(s.tableName),
+		Key:                 key(userID, id),
+		ConditionExpression: aws.String("attribute_exists(token_id)"),
+	})
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete access token: %w", err)
+	}
+	return nil
+}
+
+// Prefix starts every personal access token, which tells them apart from
+// session JWTs and makes leaked tokens easy to search for.
+const Prefix = "gdp_"
+
+// signPurpose is the crypto.Signer purpose of access tokens.
+const signPurpose = "access-token"
+
+// Sign returns the secret for t, to be shown to its owner once. It carries
+// the user and token ID and expires with t.
+func Sign(ctx context.Context, signer *crypto.Signer, t Token) (string, error) {
+	// Token IDs are UUIDs, so the first ':' ends the ID.
+	signed, err := signer.Sign(ctx, signPurpose, t.ID+":"+t.UserID, time.Until(t.ExpiresAt))
+	if err != nil {
+		return "", err
+	}
+	return Prefix + signed, nil
+}
+
+// Parse returns the user and token ID of a secret made by Sign. It returns
+// crypto.ErrInvalidToken or crypto.ErrTokenExpired when the secret cannot
+// be used; the caller must still check that the token was not revoked.
+func Parse(ctx context.Context, signer *crypto.Signer, secret string) (userID, id string, err error) {
+	signed, ok := strings.CutPrefix(secret, Prefix)
+	if !ok {
+		return "", "", crypto.ErrInvalidToken
+	}
+	data, err := signer.Verify(ctx, signPurpose, signed)
+	if err != nil {
+		return "", "", err
+	}
+	id, userID, ok = strings.Cut(data, ":")
+	if !ok || id == "" || userID == "" {
+		return "", "", crypto.ErrInvalidToken
+	}
+	return userID, id, nil
+}
@@ -0,0 +1,83 @@
+package accesstoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+func TestStore_CreateListDelete(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+	now := time.Now()
+
+	s.Create(ctx, Token{UserID: "user1", ID: "old", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)})
+	s.Create(ctx, Token{UserID: "user1", ID: "new", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	s.Create(ctx, Token{UserID: "user1", ID: "expired", CreatedAt: now, ExpiresAt: now.Add(-time.Second)})
+
+	tokens, err := s.List(ctx, "user1")
+	if err != nil || len(tokens) != 2 || tokens[0].ID != "new" || tokens[1].ID != "old" {
+		t.Fatalf("List = %+v, %v; want new then old", tokens, err)
+	}
+	if tokens, _ := s.List(ctx, "user2"); tokens == nil || len(tokens) != 0 {
+		t.Errorf("List for another user = %#v, want empty", tokens)
+	}
+
+	if _, err := s.Get(ctx, "user1", "expired"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an expired token error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Get(ctx, "user2", "new"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of another user's token error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Delete(ctx, "user1", "new"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "user1", "new"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "user1", "new"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_TooMany(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+	for i := range MaxPerUser {
+		if err := s.Create(ctx, Token{UserID: "user1", ID: fmt.Sprint(i), ExpiresAt: expires}); err != nil {
+			t.Fatalf("Create %d: %v", i, err)
+		}
+	}
+	if err := s.Create(ctx, Token{UserID: "user1", ID: "extra", ExpiresAt: expires}); !errors.Is(err, ErrTooMany) {
+		t.Errorf("Create error = %v, want ErrTooMany", err)
+	}
+}
+
+func TestSignParse(t *testing.T) {
+	signer := crypto.NewSigner(secret.Static("signing-key"))
+	ctx := context.Background()
+	tok := Token{UserID: "user:1", ID: "f47ac10b-58cc-4372-a567-0e02b2c3d479", ExpiresAt: time.Now().Add(time.Hour)}
+
+	raw, err := Sign(ctx, signer, tok)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	userID, id, err := Parse(ctx, signer, raw)
+	if err != nil || userID != tok.UserID || id != tok.ID {
+		t.Errorf("Parse = %q, %q, %v", userID, id, err)
+	}
+
+	if _, _, err := Parse(ctx, signer, raw[len(Prefix):]); !errors.Is(err, crypto.ErrInvalidToken) {
+		t.Errorf("Parse without the prefix error = %v", err)
+	}
+	if _, _, err := Parse(ctx, signer, raw+"x"); !errors.Is(err, crypto.ErrInvalidToken) {
+		t.Errorf("Parse of a tampered token error = %v", err)
+	}
+}
@@ -10,4 +10,17 @@ var (
 
 	// ErrPreconditionFailed is returned when an ETag mismatch occurs.
 	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrLimitExceeded is returned when a write would exceed a storage
+	// limit, such as the demo item count. Its message says which limit and
+	// is shown to users.
+	ErrLimitExceeded = errors.New("storage limit exceeded")
+
+	// ErrEncrypted is returned when an operation needs the plaintext of an
+	// end-to-end encrypted note, which only the client has.
+	ErrEncrypted = errors.New("note is encrypted end to end")
+
+	// ErrNotFolder is returned when a folder-only operation, such as
+	// setting a color, targets a note.
+	ErrNotFolder = errors.New("not a folder")
 )
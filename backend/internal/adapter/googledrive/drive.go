@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,6 +32,88 @@ func fromDriveName(name string) string {
 	return strings.TrimSuffix(name, mdExt)
 }
 
+// encryptedProperty and protectedProperty are the appProperties keys
+// recording that a note is encrypted end to end, and protected with its own
+// passphrase, so listings can report it without downloading the content.
+const (
+	encryptedProperty = "gophdriveEncrypted"
+	protectedProperty = "gophdriveProtected"
+)
+
+func encryptionProperties(content []byte) map[string]string {
+	return map[string]string{
+		encryptedProperty: strconv.FormatBool(adapter.IsEncrypted(content)),
+		protectedProperty: strconv.FormatBool(adapter.IsProtected(content)),
+	}
+}
+
+func isEncrypted(f *drive.File) bool {
+	return f.AppProperties[encryptedProperty] == "true"
+}
+
+// orderProperty is the appProperties key holding a file's position in the
+// starred list.
+const orderProperty = "gophdriveOrder"
+
+func orderIndex(f *drive.File) int {
+	index, _ := strconv.Atoi(f.AppProperties[orderProperty])
+	return index
+}
+
+// archivedProperty is the appProperties key marking an archived file.
+const archivedProperty = "gophdriveArchived"
+
+// iconProperty is the appProperties key holding a folder's icon.
+const iconProperty = "gophdriveIcon"
+
+// propertyPrefix starts the appProperties keys holding the user's custom
+// properties, keeping them apart from GophDrive's own.
+const propertyPrefix = "prop."
+
+func customProperties(f *drive.File) map[string]string {
+	var props map[string]string
+	for k, v := range f.AppProperties {
+		if key, ok := strings.CutPrefix(k, propertyPrefix); ok {
+			if props == nil {
+				props = make(map[string]string)
+			}
+			props[key] = v
+		}
+	}
+	return props
+}
+
+// metadataFields lists the Drive fields toMetadata reads.
+const metadataFields = "id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred, appProperties, folderColorRgb"
+
+// toMetadata converts Drive file metadata. Note names lose their .md
+// extension; folder names are kept as they are.
+func toMetadata(f *drive.File) adapter.FileMetadata {
+	name := f.Name
+	if f.MimeType != "application/vnd.google-apps.folder" {
+		name = fromDriveName(name)
+	}
+	modTime, _ := time.Parse(time.RFC3339, f.ModifiedTime)
+	return adapter.FileMetadata{
+		ID:           f.Id,
+		Name:         name,
+		MIMEType:     f.MimeType,
+		ModifiedTime: modTime,
+		Size:         f.Size,
+		ETag:         f.Md5Checksum,
+		Parents:      f.Parents,
+		Starred:      f.Starred,
+		Encrypted:    isEncrypted(f),
+		Protected:    f.AppProperties[protectedProperty] == "true",
+		OrderIndex:   orderIndex(f),
+		Color:        f.FolderColorRgb,
+		Icon:         f.AppProperties[iconProperty],
+		Properties:   customProperties(f),
+		Archived:     f.AppProperties[archivedProperty] == "true",
+		ContentHash:  f.Md5Checksum,
+	}
+}
+
 // DriveAdapter implements adapter.StorageAdapter for Google Drive.
 type DriveAdapter struct {
 	service      *drive.Service
@@ -42,7 +125,7 @@ type DriveAdapter struct {
 func NewDriveAdapter(ctx context.Context, client *http.Client, baseFolderID string) (*DriveAdapter, error) {
 	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve Drive client: %v", err)
+		return nil, fmt.Errorf("unable to retrieve Drive client: %w", err)
 	}
 	return &DriveAdapter{service: srv, BaseFolderID: baseFolderID}, nil
 }
@@ -60,14 +143,15 @@ func (d *DriveAdapter) ListFiles(ctx context.Context, folderID string) ([]adapte
 
 	q := fmt.Sprintf("'%s' in parents and trashed = false and (name contains '%s' or mimeType = 'application/vnd.google-apps.folder')", targetFolderID, mdExt)
 	// Only fetch necessary fields
-	fields := "nextPageToken, files(id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred)"
+	fields := "nextPageToken, files(" + metadataFields + ")"
 
 	r, err := d.service.Files.List().
 		Q(q).
 		Fields(googleapi.Field(fields)).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to list files: %v", err)
+		return nil, fmt.Errorf("unable to list files: %w", err)
 	}
 
 	files := []adapter.FileMetadata{}
@@ -75,21 +159,7 @@ func (d *DriveAdapter) ListFiles(ctx context.Context, folderID string) ([]adapte
 		if f.MimeType != "application/vnd.google-apps.folder" && !strings.HasSuffix(f.Name, mdExt) {
 			continue
 		}
-		name := f.Name
-		if f.MimeType != "application/vnd.google-apps.folder" {
-			name = fromDriveName(name)
-		}
-		modTime, _ := time.Parse(time.RFC3339, f.ModifiedTime)
-		files = append(files, adapter.FileMetadata{
-			ID:           f.Id,
-			Name:         name,
-			MIMEType:     f.MimeType,
-			ModifiedTime: modTime,
-			Size:         f.Size,
-			ETag:         f.Md5Checksum,
-			Parents:      f.Parents,
-			Starred:      f.Starred,
-		})
+		files = append(files, toMetadata(f))
 	}
 	return files, nil
 }
@@ -111,22 +181,15 @@ func (d *DriveAdapter) CreateFolder(ctx context.Context, name string, parents []
 	}
 
 	res, err := d.service.Files.Create(f).
-		Fields("id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred").
+		Fields(metadataFields).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to create folder: %v", err)
+		return nil, fmt.Errorf("unable to create folder: %w", err)
 	}
 
-	modTime, _ := time.Parse(time.RFC3339, res.ModifiedTime)
-	return &adapter.FileMetadata{
-		ID:           res.Id,
-		Name:         res.Name,
-		MIMEType:     res.MimeType,
-		ModifiedTime: modTime,
-		Size:         res.Size,
-		ETag:         res.Md5Checksum,
-		Parents:      res.Parents,
-	}, nil
+	meta := toMetadata(res)
+	return &meta, nil
 }
 
 // EnsureRootFolder ensures a root folder exists and returns its ID.
@@ -134,9 +197,9 @@ func (d *DriveAdapter) CreateFolder(ctx context.Context, name string, parents []
 func (d *DriveAdapter) EnsureRootFolder(ctx context.Context, name string) (string, error) {
 	// 1. Search for the folder in 'root'
 	q := fmt.Sprintf("name = '%s' and mimeType = 'application/vnd.google-apps.folder' and 'root' in parents and trashed = false", name)
-	r, err := d.service.Files.List().Q(q).Fields("files(id)").Do()
+	r, err := d.service.Files.List().Q(q).Fields("files(id)").Context(ctx).Do()
 	if err != nil {
-		return "", fmt.Errorf("unable to search for root folder: %v", err)
+		return "", fmt.Errorf("unable to search for root folder: %w", err)
 	}
 
 	if len(r.Files) > 0 {
@@ -146,7 +209,7 @@ func (d *DriveAdapter) EnsureRootFolder(ctx context.Context, name string) (strin
 	// 2. Create if not exists
 	folder, err := d.CreateFolder(ctx, name, []string{"root"})
 	if err != nil {
-		return "", fmt.Errorf("unable to create root folder: %v", err)
+		return "", fmt.Errorf("unable to create root folder: %w", err)
 	}
 
 	return folder.ID, nil
@@ -156,29 +219,20 @@ func (d *DriveAdapter) EnsureRootFolder(ctx context.Context, name string) (strin
 func (d *DriveAdapter) ListRootFolders(ctx context.Context) ([]adapter.FileMetadata, error) {
 	// Explicitly list from 'root', ignoring BaseFolderID
 	q := "'root' in parents and mimeType = 'application/vnd.google-apps.folder' and trashed = false"
-	fields := "nextPageToken, files(id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred)"
+	fields := "nextPageToken, files(" + metadataFields + ")"
 
 	r, err := d.service.Files.List().
 		Q(q).
 		Fields(googleapi.Field(fields)).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to list root folders: %v", err)
+		return nil, fmt.Errorf("unable to list root folders: %w", err)
 	}
 
 	files := []adapter.FileMetadata{}
 	for _, f := range r.Files {
-		modTime, _ := time.Parse(time.RFC3339, f.ModifiedTime)
-		files = append(files, adapter.FileMetadata{
-			ID:           f.Id,
-			Name:         f.Name,
-			MIMEType:     f.MimeType,
-			ModifiedTime: modTime,
-			Size:         f.Size,
-			ETag:         f.Md5Checksum,
-			Parents:      f.Parents,
-			Starred:      f.Starred,
-		})
+		files = append(files, toMetadata(f))
 	}
 	return files, nil
 }
@@ -188,58 +242,51 @@ func (d *DriveAdapter) GetFile(ctx context.Context, fileID string) (*adapter.Fil
 	// 1. Get Metadata
 	f, err := d.service.Files.Get(fileID).
 		SupportsAllDrives(true).
-		Fields("id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred").
+		Fields(metadataFields).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to get file metadata: %v", err)
+		if isNotFound(err) {
+			return nil, adapter.ErrNotFound
+		}
+		return nil, fmt.Errorf("unable to get file metadata: %w", err)
 	}
 
 	// 2. Get Content (only if not a folder)
 	var content []byte
 	if f.MimeType != "application/vnd.google-apps.folder" {
-		resp, err := d.service.Files.Get(fileID).Download()
+		resp, err := d.service.Files.Get(fileID).Context(ctx).Download()
 		if err != nil {
-			return nil, fmt.Errorf("unable to download file: %v", err)
+			return nil, fmt.Errorf("unable to download file: %w", err)
 		}
 		defer resp.Body.Close()
 
 		content, err = io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, fmt.Errorf("unable to read file content: %v", err)
+			return nil, fmt.Errorf("unable to read file content: %w", err)
 		}
 	}
 
-	modTime, _ := time.Parse(time.RFC3339, f.ModifiedTime)
-
 	return &adapter.File{
-		FileMetadata: adapter.FileMetadata{
-			ID:           f.Id,
-			Name:         fromDriveName(f.Name),
-			MIMEType:     f.MimeType,
-			ModifiedTime: modTime,
-			Size:         f.Size,
-			ETag:         f.Md5Checksum,
-			Parents:      f.Parents,
-			Starred:      f.Starred,
-		},
-		Content: content,
+		FileMetadata: toMetadata(f),
+		Content:      content,
 	}, nil
 }
 
 // SaveFile updates an existing file's content.
 func (d *DriveAdapter) SaveFile(ctx context.Context, fileID string, content []byte, etag string) (*adapter.FileMetadata, error) {
 	// If etag is provided, use If-Match header for optimistic locking.
-	f := &drive.File{}
+	f := &drive.File{AppProperties: encryptionProperties(content)}
 	call := d.service.Files.Update(fileID, f).
 		Media(bytes.NewReader(content)).
 		SupportsAllDrives(true).
-		Fields("id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred")
+		Fields(metadataFields)
 
 	if etag != "" {
 		call.Header().Set("If-Match", etag)
 	}
 
-	res, err := call.Do()
+	res, err := call.Context(ctx).Do()
 	if err != nil {
 		if isPreconditionFailed(err) {
 			return nil, adapter.ErrPreconditionFailed
@@ -247,19 +294,11 @@ func (d *DriveAdapter) SaveFile(ctx context.Context, fileID string, content []by
 		if isNotFound(err) {
 			return nil, adapter.ErrNotFound
 		}
-		return nil, fmt.Errorf("unable to update file: %v", err)
+		return nil, fmt.Errorf("unable to update file: %w", err)
 	}
 
-	modTime, _ := time.Parse(time.RFC3339, res.ModifiedTime)
-	return &adapter.FileMetadata{
-		ID:           res.Id,
-		Name:         fromDriveName(res.Name),
-		MIMEType:     res.MimeType,
-		ModifiedTime: modTime,
-		Size:         res.Size,
-		ETag:         res.Md5Checksum,
-		Parents:      res.Parents,
-	}, nil
+	meta := toMetadata(res)
+	return &meta, nil
 }
 
 func isPreconditionFailed(err error) bool {
@@ -290,34 +329,28 @@ func (d *DriveAdapter) CreateFile(ctx context.Context, name string, content []by
 	}
 
 	f := &drive.File{
-		Name:    toDriveName(name),
-		Parents: parents,
+		Name:          toDriveName(name),
+		Parents:       parents,
+		AppProperties: encryptionProperties(content),
 	}
 	res, err := d.service.Files.Create(f).
 		Media(bytes.NewReader(content)).
 		SupportsAllDrives(true).
-		Fields("id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred").
+		Fields(metadataFields).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to create file: %v", err)
+		return nil, fmt.Errorf("unable to create file: %w", err)
 	}
 
-	modTime, _ := time.Parse(time.RFC3339, res.ModifiedTime)
-	return &adapter.FileMetadata{
-		ID:           res.Id,
-		Name:         fromDriveName(res.Name),
-		MIMEType:     res.MimeType,
-		ModifiedTime: modTime,
-		Size:         res.Size,
-		ETag:         res.Md5Checksum,
-		Parents:      res.Parents,
-	}, nil
+	meta := toMetadata(res)
+	return &meta, nil
 }
 
 // DeleteFile deletes a file by its ID.
 func (d *DriveAdapter) DeleteFile(ctx context.Context, fileID string) error {
-	if err := d.service.Files.Delete(fileID).SupportsAllDrives(true).Do(); err != nil {
-		return fmt.Errorf("unable to delete file: %v", err)
+	if err := d.service.Files.Delete(fileID).SupportsAllDrives(true).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("unable to delete file: %w", err)
 	}
 	return nil
 }
@@ -325,9 +358,9 @@ func (d *DriveAdapter) DeleteFile(ctx context.Context, fileID string) error {
 // DuplicateFile duplicates a file by its ID.
 func (d *DriveAdapter) DuplicateFile(ctx context.Context, fileID string) (*adapter.FileMetadata, error) {
 	// 1. Get original file to generate new name
-	orig, err := d.service.Files.Get(fileID).SupportsAllDrives(true).Fields("name, parents").Do()
+	orig, err := d.service.Files.Get(fileID).SupportsAllDrives(true).Fields("name, parents").Context(ctx).Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to get file for duplication: %v", err)
+		return nil, fmt.Errorf("unable to get file for duplication: %w", err)
 	}
 
 	newName := fmt.Sprintf("Copy of %s", fromDriveName(orig.Name))
@@ -339,33 +372,26 @@ func (d *DriveAdapter) DuplicateFile(ctx context.Context, fileID string) (*adapt
 	// 2. Copy
 	res, err := d.service.Files.Copy(fileID, f).
 		SupportsAllDrives(true).
-		Fields("id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred").
+		Fields(metadataFields).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to duplicate file: %v", err)
+		return nil, fmt.Errorf("unable to duplicate file: %w", err)
 	}
 
-	modTime, _ := time.Parse(time.RFC3339, res.ModifiedTime)
-	return &adapter.FileMetadata{
-		ID:           res.Id,
-		Name:         fromDriveName(res.Name),
-		MIMEType:     res.MimeType,
-		ModifiedTime: modTime,
-		Size:         res.Size,
-		ETag:         res.Md5Checksum,
-		Parents:      res.Parents,
-	}, nil
+	meta := toMetadata(res)
+	return &meta, nil
 }
 
 // RenameFile renames a file.
 func (d *DriveAdapter) RenameFile(ctx context.Context, fileID string, newName string) (*adapter.FileMetadata, error) {
 	// 1. Get current metadata to check if it's a folder
-	current, err := d.service.Files.Get(fileID).Fields("mimeType").Do()
+	current, err := d.service.Files.Get(fileID).Fields("mimeType").Context(ctx).Do()
 	if err != nil {
 		if isNotFound(err) {
 			return nil, adapter.ErrNotFound
 		}
-		return nil, fmt.Errorf("unable to fetch file metadata for rename: %v", err)
+		return nil, fmt.Errorf("unable to fetch file metadata for rename: %w", err)
 	}
 
 	name := newName
@@ -379,22 +405,15 @@ func (d *DriveAdapter) RenameFile(ctx context.Context, fileID string, newName st
 
 	res, err := d.service.Files.Update(fileID, f).
 		SupportsAllDrives(true).
-		Fields("id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred").
+		Fields(metadataFields).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to rename file: %v", err)
+		return nil, fmt.Errorf("unable to rename file: %w", err)
 	}
 
-	modTime, _ := time.Parse(time.RFC3339, res.ModifiedTime)
-	return &adapter.FileMetadata{
-		ID:           res.Id,
-		Name:         fromDriveName(res.Name),
-		MIMEType:     res.MimeType,
-		ModifiedTime: modTime,
-		Size:         res.Size,
-		ETag:         res.Md5Checksum,
-		Parents:      res.Parents,
-	}, nil
+	meta := toMetadata(res)
+	return &meta, nil
 }
 
 // SetStarred sets the starred status of a file.
@@ -406,23 +425,145 @@ func (d *DriveAdapter) SetStarred(ctx context.Context, fileID string, starred bo
 
 	res, err := d.service.Files.Update(fileID, f).
 		SupportsAllDrives(true).
-		Fields("id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred").
+		Fields(metadataFields).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to update starred status: %v", err)
+		return nil, fmt.Errorf("unable to update starred status: %w", err)
 	}
 
-	modTime, _ := time.Parse(time.RFC3339, res.ModifiedTime)
-	return &adapter.FileMetadata{
-		ID:           res.Id,
-		Name:         fromDriveName(res.Name),
-		MIMEType:     res.MimeType,
-		ModifiedTime: modTime,
-		Size:         res.Size,
-		ETag:         res.Md5Checksum,
-		Parents:      res.Parents,
-		Starred:      res.Starred,
-	}, nil
+	meta := toMetadata(res)
+	return &meta, nil
+}
+
+// SetOrderIndex sets a file's position in the starred list.
+func (d *DriveAdapter) SetOrderIndex(ctx context.Context, fileID string, index int) (*adapter.FileMetadata, error) {
+	// appProperties updates only touch the keys sent.
+	f := &drive.File{AppProperties: map[string]string{orderProperty: strconv.Itoa(index)}}
+
+	res, err := d.service.Files.Update(fileID, f).
+		SupportsAllDrives(true).
+		Fields(metadataFields).
+		Context(ctx).
+		Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, adapter.ErrNotFound
+		}
+		return nil, fmt.Errorf("unable to update order: %w", err)
+	}
+
+	meta := toMetadata(res)
+	return &meta, nil
+}
+
+// SetAppearance sets a folder's color and icon. Clearing the color puts
+// back Drive's default folder color.
+func (d *DriveAdapter) SetAppearance(ctx context.Context, fileID string, color, icon *string) (*adapter.FileMetadata, error) {
+	current, err := d.service.Files.Get(fileID).SupportsAllDrives(true).Fields("mimeType").Context(ctx).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, adapter.ErrNotFound
+		}
+		return nil, fmt.Errorf("unable to get file: %w", err)
+	}
+	if current.MimeType != "application/vnd.google-apps.folder" {
+		return nil, adapter.ErrNotFolder
+	}
+
+	f := &drive.File{}
+	if color != nil {
+		if *color == "" {
+			f.NullFields = append(f.NullFields, "FolderColorRgb")
+		} else {
+			f.FolderColorRgb = *color
+		}
+	}
+	if icon != nil {
+		if *icon == "" {
+			f.NullFields = append(f.NullFields, "AppProperties."+iconProperty)
+		} else {
+			f.AppProperties = map[string]string{iconProperty: *icon}
+		}
+	}
+
+	res, err := d.service.Files.Update(fileID, f).
+		SupportsAllDrives(true).
+		Fields(metadataFields).
+		Context(ctx).
+		Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, adapter.ErrNotFound
+		}
+		return nil, fmt.Errorf("unable to update folder appearance: %w", err)
+	}
+
+	meta := toMetadata(res)
+	return &meta, nil
+}
+
+// SetArchived archives or restores a file.
+func (d *DriveAdapter) SetArchived(ctx context.Context, fileID string, archived bool) (*adapter.FileMetadata, error) {
+	f := &drive.File{}
+	if archived {
+		f.AppProperties = map[string]string{archivedProperty: "true"}
+	} else {
+		f.NullFields = []string{"AppProperties." + archivedProperty}
+	}
+
+	res, err := d.service.Files.Update(fileID, f).
+		SupportsAllDrives(true).
+		Fields(metadataFields).
+		Context(ctx).
+		Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, adapter.ErrNotFound
+		}
+		return nil, fmt.Errorf("unable to update archived status: %w", err)
+	}
+
+	meta := toMetadata(res)
+	return &meta, nil
+}
+
+// SetProperties merges props into a file's custom properties.
+func (d *DriveAdapter) SetProperties(ctx context.Context, fileID string, props map[string]string) (*adapter.FileMetadata, error) {
+	current, err := d.service.Files.Get(fileID).SupportsAllDrives(true).Fields("appProperties").Context(ctx).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, adapter.ErrNotFound
+		}
+		return nil, fmt.Errorf("unable to get file: %w", err)
+	}
+	if _, err := adapter.MergeProperties(customProperties(current), props); err != nil {
+		return nil, err
+	}
+
+	f := &drive.File{AppProperties: make(map[string]string)}
+	for k, v := range props {
+		if v == "" {
+			f.NullFields = append(f.NullFields, "AppProperties."+propertyPrefix+k)
+		} else {
+			f.AppProperties[propertyPrefix+k] = v
+		}
+	}
+
+	res, err := d.service.Files.Update(fileID, f).
+		SupportsAllDrives(true).
+		Fields(metadataFields).
+		Context(ctx).
+		Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, adapter.ErrNotFound
+		}
+		return nil, fmt.Errorf("unable to update properties: %w", err)
+	}
+
+	meta := toMetadata(res)
+	return &meta, nil
 }
 
 // isDescendant checks recursively if targetFolderID is an ancestor of the file.
@@ -448,7 +589,7 @@ func (d *DriveAdapter) isDescendant(ctx context.Context, fileParents []string, t
 		}
 
 		// Fetch parent metadata to get its parents
-		pf, err := d.service.Files.Get(p).Fields("id, parents").Do()
+		pf, err := d.service.Files.Get(p).Fields("id, parents").Context(ctx).Do()
 		if err != nil {
 			cache[p] = false
 			continue
@@ -465,21 +606,41 @@ func (d *DriveAdapter) isDescendant(ctx context.Context, fileParents []string, t
 
 // ListStarred lists all starred files/folders within the base folder.
 func (d *DriveAdapter) ListStarred(ctx context.Context) ([]adapter.FileMetadata, error) {
+	files, err := d.listWhere(ctx, "starred = true")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list starred files: %w", err)
+	}
+	return files, nil
+}
+
+// ListArchived lists all archived notes/folders.
+func (d *DriveAdapter) ListArchived(ctx context.Context) ([]adapter.FileMetadata, error) {
+	files, err := d.listWhere(ctx, fmt.Sprintf("appProperties has { key='%s' and value='true' }", archivedProperty))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list archived files: %w", err)
+	}
+	return files, nil
+}
+
+// listWhere lists the notes and folders under the base folder, at any
+// depth, that match the Drive query cond.
+func (d *DriveAdapter) listWhere(ctx context.Context, cond string) ([]adapter.FileMetadata, error) {
 	targetFolderID := "root"
 	if d.BaseFolderID != "" {
 		targetFolderID = d.BaseFolderID
 	}
 
-	// Search all starred files (API doesn't support recursive 'in parents')
-	q := fmt.Sprintf("starred = true and trashed = false and (name contains '%s' or mimeType = 'application/vnd.google-apps.folder')", mdExt)
-	fields := "nextPageToken, files(id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred)"
+	// Search all matching files (API doesn't support recursive 'in parents')
+	q := fmt.Sprintf("%s and trashed = false and (name contains '%s' or mimeType = 'application/vnd.google-apps.folder')", cond, mdExt)
+	fields := "nextPageToken, files(" + metadataFields + ")"
 
 	r, err := d.service.Files.List().
 		Q(q).
 		Fields(googleapi.Field(fields)).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to list starred files: %v", err)
+		return nil, err
 	}
 
 	ancestorCache := make(map[string]bool)
@@ -493,21 +654,7 @@ func (d *DriveAdapter) ListStarred(ctx context.Context) ([]adapter.FileMetadata,
 			continue
 		}
 
-		name := f.Name
-		if f.MimeType != "application/vnd.google-apps.folder" {
-			name = fromDriveName(name)
-		}
-		modTime, _ := time.Parse(time.RFC3339, f.ModifiedTime)
-		files = append(files, adapter.FileMetadata{
-			ID:           f.Id,
-			Name:         name,
-			MIMEType:     f.MimeType,
-			ModifiedTime: modTime,
-			Size:         f.Size,
-			ETag:         f.Md5Checksum,
-			Parents:      f.Parents,
-			Starred:      f.Starred,
-		})
+		files = append(files, toMetadata(f))
 	}
 	return files, nil
 }
@@ -523,14 +670,15 @@ func (d *DriveAdapter) SearchFiles(ctx context.Context, query string) ([]adapter
 	// Note: We remove the 'in parents' constraint to allow recursive search,
 	// then filter results in memory.
 	q := fmt.Sprintf("fullText contains '%s' and name contains '%s' and mimeType != 'application/vnd.google-apps.folder' and trashed = false", query, mdExt)
-	fields := "nextPageToken, files(id, name, mimeType, modifiedTime, size, md5Checksum, parents, starred)"
+	fields := "nextPageToken, files(" + metadataFields + ")"
 
 	r, err := d.service.Files.List().
 		Q(q).
 		Fields(googleapi.Field(fields)).
+		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to search files: %v", err)
+		return nil, fmt.Errorf("unable to search files: %w", err)
 	}
 
 	ancestorCache := make(map[string]bool)
@@ -539,22 +687,17 @@ func (d *DriveAdapter) SearchFiles(ctx context.Context, query string) ([]adapter
 		if !strings.HasSuffix(f.Name, mdExt) {
 			continue
 		}
+		// Full-text matches in encrypted notes are matches in ciphertext;
+		// only their names are searchable.
+		if isEncrypted(f) && !strings.Contains(strings.ToLower(f.Name), strings.ToLower(query)) {
+			continue
+		}
 		// Recursive check
 		if !d.isDescendant(ctx, f.Parents, targetFolderID, ancestorCache) {
 			continue
 		}
 
-		modTime, _ := time.Parse(time.RFC3339, f.ModifiedTime)
-		files = append(files, adapter.FileMetadata{
-			ID:           f.Id,
-			Name:         fromDriveName(f.Name),
-			MIMEType:     f.MimeType,
-			ModifiedTime: modTime,
-			Size:         f.Size,
-			ETag:         f.Md5Checksum,
-			Parents:      f.Parents,
-			Starred:      f.Starred,
-		})
+		files = append(files, toMetadata(f))
 	}
 	return files, nil
 }
@@ -2,39 +2,106 @@ package googledrive
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
 
 	"github.com/jun/gophdrive/backend/internal/adapter"
 	"github.com/jun/gophdrive/backend/internal/auth"
+	"github.com/jun/gophdrive/backend/internal/metrics"
+	"github.com/jun/gophdrive/backend/internal/tracing"
 )
 
+// adapterTTL bounds how long a cached adapter is reused. Changes made
+// through this process invalidate it at once, but another Lambda container
+// may have stored a new token or base folder in the meantime.
+const adapterTTL = 15 * time.Minute
+
 // Provider implements adapter.StorageProvider for Google Drive.
+//
+// Adapters are cached per user, so that a request does not read the user's
+// token from DynamoDB, decrypt it with KMS and refresh the access token with
+// Google before it can call Drive. A cached adapter is dropped when the
+// user's token or base folder changes, when Google rejects its credentials
+// and after adapterTTL.
 type Provider struct {
 	authService *auth.AuthService
+	now         func() time.Time
+
+	mu       sync.Mutex
+	adapters map[string]cachedAdapter
+}
+
+type cachedAdapter struct {
+	adapter *DriveAdapter
+	created time.Time
 }
 
 // NewProvider creates a new Google Drive provider.
 func NewProvider(authService *auth.AuthService) *Provider {
-	return &Provider{authService: authService}
+	p := &Provider{authService: authService, now: time.Now, adapters: make(map[string]cachedAdapter)}
+	authService.OnTokenChange(p.Invalidate)
+	return p
 }
 
 // GetAdapter returns a DriveAdapter for the given user ID.
 func (p *Provider) GetAdapter(ctx context.Context, userID string) (adapter.StorageAdapter, error) {
-	// Get base folder ID from user token
-	var baseFolderID string
-	if token, err := p.authService.GetUserToken(ctx, userID); err == nil {
-		baseFolderID = token.BaseFolderID
+	p.mu.Lock()
+	cached, ok := p.adapters[userID]
+	p.mu.Unlock()
+	if ok && p.now().Sub(cached.created) < adapterTTL {
+		return cached.adapter, nil
 	}
 
-	client, err := p.authService.GetClient(ctx, userID)
+	token, err := p.authService.GetUserToken(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authenticated client: %w", err)
+	}
+	client, err := p.authService.ClientForToken(ctx, token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get authenticated client: %w", err)
 	}
+	client.Transport = &invalidatingTransport{
+		base:       metrics.Transport(tracing.Transport(client.Transport, "Drive"), metrics.DriveCalls),
+		invalidate: func() { p.Invalidate(userID) },
+	}
 
-	storage, err := NewDriveAdapter(ctx, client, baseFolderID)
+	storage, err := NewDriveAdapter(ctx, client, token.BaseFolderID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create drive adapter: %w", err)
 	}
 
+	p.mu.Lock()
+	p.adapters[userID] = cachedAdapter{adapter: storage, created: p.now()}
+	p.mu.Unlock()
 	return storage, nil
 }
+
+// Invalidate drops the cached adapter for userID, if any.
+func (p *Provider) Invalidate(userID string) {
+	p.mu.Lock()
+	delete(p.adapters, userID)
+	p.mu.Unlock()
+}
+
+// invalidatingTransport calls invalidate when Google rejects the user's
+// credentials: the access token cannot be refreshed (e.g. the refresh token
+// was revoked) or Drive answers 401. The request still fails, but the next
+// one starts from the stored token again.
+type invalidatingTransport struct {
+	base       http.RoundTripper
+	invalidate func()
+}
+
+func (t *invalidatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) || (err == nil && resp.StatusCode == http.StatusUnauthorized) {
+		t.invalidate()
+	}
+	return resp, err
+}
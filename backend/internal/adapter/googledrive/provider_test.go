@@ -0,0 +1,91 @@
+package googledrive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/jun/gophdrive/backend/internal/auth"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+)
+
+func testProvider(t *testing.T) (*Provider, *auth.AuthService) {
+	t.Helper()
+	authService := auth.NewAuthService(&oauth2.Config{ClientID: "test-client-id"}, nil, "test-tokens-table", crypto.NewMockEncryptor())
+	if err := authService.SaveToken(context.Background(), "user-1", &oauth2.Token{RefreshToken: "refresh"}); err != nil {
+		t.Fatalf("SaveToken: %v", err)
+	}
+	return NewProvider(authService), authService
+}
+
+func TestProvider_CachesAdapters(t *testing.T) {
+	p, authService := testProvider(t)
+	ctx := context.Background()
+
+	first, err := p.GetAdapter(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("GetAdapter: %v", err)
+	}
+	if again, _ := p.GetAdapter(ctx, "user-1"); again != first {
+		t.Error("second GetAdapter built a new adapter")
+	}
+
+	if err := authService.UpdateBaseFolderID(ctx, "user-1", "folder-2"); err != nil {
+		t.Fatalf("UpdateBaseFolderID: %v", err)
+	}
+	updated, _ := p.GetAdapter(ctx, "user-1")
+	if updated == first || updated.(*DriveAdapter).BaseFolderID != "folder-2" {
+		t.Error("adapter was not rebuilt after the base folder changed")
+	}
+
+	now := time.Now()
+	p.now = func() time.Time { return now.Add(adapterTTL) }
+	if expired, _ := p.GetAdapter(ctx, "user-1"); expired == updated {
+		t.Error("adapter was reused after adapterTTL")
+	}
+}
+
+func TestProvider_UnknownUser(t *testing.T) {
+	p, _ := testProvider(t)
+	if _, err := p.GetAdapter(context.Background(), "nobody"); err == nil {
+		t.Error("expected an error for a user without a token")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestInvalidatingTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		resp       *http.Response
+		err        error
+		invalidate bool
+	}{
+		{"success", &http.Response{StatusCode: http.StatusOK}, nil, false},
+		{"not found", &http.Response{StatusCode: http.StatusNotFound}, nil, false},
+		{"unauthorized", &http.Response{StatusCode: http.StatusUnauthorized}, nil, true},
+		{"refresh rejected", nil, fmt.Errorf("oauth2: %w", &oauth2.RetrieveError{ErrorCode: "invalid_grant"}), true},
+		{"network error", nil, errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var invalidated bool
+			transport := &invalidatingTransport{
+				base:       roundTripFunc(func(*http.Request) (*http.Response, error) { return tt.resp, tt.err }),
+				invalidate: func() { invalidated = true },
+			}
+			req, _ := http.NewRequest(http.MethodGet, "https://www.googleapis.com/drive/v3/files", nil)
+			transport.RoundTrip(req)
+			if invalidated != tt.invalidate {
+				t.Errorf("invalidated = %v, want %v", invalidated, tt.invalidate)
+			}
+		})
+	}
+}
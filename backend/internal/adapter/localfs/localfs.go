@@ -0,0 +1,414 @@
+// Package localfs stores notes as Markdown files in a directory, one
+// subdirectory per user. A file's ID is its path relative to the user's
+// directory, such as "Work/Plan.md", so the directory can be read, backed
+// up or synced to S3 or Dropbox (e.g. with rclone) without GophDrive.
+//
+// Drive allows several items with the same name in a folder; a file system
+// does not, so a name that is taken gets a " (2)", " (3)"... suffix.
+// Starring, archiving, folder appearance and custom properties are not
+// supported.
+package localfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+const (
+	mdExt          = ".md"
+	folderMIMEType = "application/vnd.google-apps.folder"
+)
+
+// Provider implements adapter.StorageProvider for a directory. Adapters
+// are cached per user, each holding its directory open.
+type Provider struct {
+	dir string
+
+	mu       sync.Mutex
+	adapters map[string]*Adapter
+}
+
+// NewProvider stores notes under dir, which must exist.
+func NewProvider(dir string) *Provider {
+	return &Provider{dir: dir, adapters: make(map[string]*Adapter)}
+}
+
+// GetAdapter returns the adapter for userID's directory, creating it if
+// needed.
+func (p *Provider) GetAdapter(ctx context.Context, userID string) (adapter.StorageAdapter, error) {
+	if userID == "" || userID == "." || userID == ".." || strings.ContainsAny(userID, `/\`) {
+		return nil, fmt.Errorf("invalid user ID %q", userID)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if a, ok := p.adapters[userID]; ok {
+		return a, nil
+	}
+
+	root, err := os.OpenRoot(p.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage directory: %w", err)
+	}
+	defer root.Close()
+	if err := root.Mkdir(userID, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("failed to create user directory: %w", err)
+	}
+	user, err := root.OpenRoot(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user directory: %w", err)
+	}
+	a := &Adapter{root: user}
+	p.adapters[userID] = a
+	return a, nil
+}
+
+// Adapter implements adapter.StorageAdapter for one user's directory. All
+// access goes through an os.Root, so IDs cannot reach outside it.
+type Adapter struct {
+	root *os.Root
+}
+
+var _ adapter.StorageAdapter = (*Adapter)(nil)
+
+// clean checks that id is a relative path inside the directory; "" is the
+// directory itself.
+func clean(id string) (string, error) {
+	if id == "" || id == "root" {
+		return ".", nil
+	}
+	if !fs.ValidPath(id) {
+		return "", fmt.Errorf("%w: invalid file ID %q", adapter.ErrNotFound, id)
+	}
+	return id, nil
+}
+
+// metadata describes the file at p.
+func metadata(p string, info fs.FileInfo, content []byte) adapter.FileMetadata {
+	dir := path.Dir(p)
+	if dir == "." {
+		dir = ""
+	}
+	m := adapter.FileMetadata{
+		ID:           p,
+		Name:         strings.TrimSuffix(info.Name(), mdExt),
+		ModifiedTime: info.ModTime(),
+		Parents:      []string{dir},
+	}
+	if info.IsDir() {
+		m.MIMEType = folderMIMEType
+		m.Name = info.Name()
+		return m
+	}
+	m.MIMEType = "text/markdown"
+	m.Size = info.Size()
+	if content != nil {
+		m.ContentHash = adapter.ContentHash(content)
+		m.ETag = m.ContentHash
+		m.Encrypted = adapter.IsEncrypted(content)
+		m.Protected = adapter.IsProtected(content)
+	}
+	return m
+}
+
+// stat returns the metadata of the file at id, reading notes to hash their
+// content.
+func (a *Adapter) stat(id string) (*adapter.File, error) {
+	p, err := clean(id)
+	if err != nil {
+		return nil, err
+	}
+	info, err := a.root.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, adapter.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var content []byte
+	if !info.IsDir() {
+		if content, err = a.root.ReadFile(p); err != nil {
+			return nil, err
+		}
+	}
+	return &adapter.File{FileMetadata: metadata(p, info, content), Content: content}, nil
+}
+
+// free returns a path for name in dir that is not taken, adding a number
+// to name if needed. ext is appended to the name.
+func (a *Adapter) free(dir, name, ext string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	for n := 1; ; n++ {
+		candidate := name
+		if n > 1 {
+			candidate += " (" + strconv.Itoa(n) + ")"
+		}
+		p := path.Join(dir, candidate+ext)
+		if _, err := a.root.Lstat(p); errors.Is(err, fs.ErrNotExist) {
+			return p, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// write replaces the file at p with content through a temporary file, so
+// a failed write leaves the old content.
+func (a *Adapter) write(p string, content []byte) error {
+	tmp := path.Join(path.Dir(p), ".tmp-"+path.Base(p))
+	if err := a.root.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	if err := a.root.Rename(tmp, p); err != nil {
+		a.root.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (a *Adapter) ListFiles(ctx context.Context, folderID string) ([]adapter.FileMetadata, error) {
+	dir, err := clean(folderID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(a.root.FS(), dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, adapter.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := []adapter.FileMetadata{}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") || !e.IsDir() && !strings.HasSuffix(e.Name(), mdExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		files = append(files, metadata(path.Join(dir, e.Name()), info, nil))
+	}
+	return files, nil
+}
+
+func (a *Adapter) GetFile(ctx context.Context, fileID string) (*adapter.File, error) {
+	return a.stat(fileID)
+}
+
+func (a *Adapter) SaveFile(ctx context.Context, fileID string, content []byte, etag string) (*adapter.FileMetadata, error) {
+	current, err := a.stat(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if current.MIMEType == folderMIMEType {
+		return nil, fmt.Errorf("%s is a folder", fileID)
+	}
+	if etag != "" && etag != current.ETag {
+		return nil, adapter.ErrPreconditionFailed
+	}
+	if err := a.write(current.ID, content); err != nil {
+		return nil, err
+	}
+	f, err := a.stat(current.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &f.FileMetadata, nil
+}
+
+func (a *Adapter) CreateFile(ctx context.Context, name string, content []byte, folderID string) (*adapter.FileMetadata, error) {
+	dir, err := clean(folderID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := a.free(dir, strings.TrimSuffix(name, mdExt), mdExt)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.write(p, content); err != nil {
+		return nil, err
+	}
+	f, err := a.stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return &f.FileMetadata, nil
+}
+
+func (a *Adapter) CreateFolder(ctx context.Context, name string, parents []string) (*adapter.FileMetadata, error) {
+	dir := "."
+	if len(parents) > 0 {
+		var err error
+		if dir, err = clean(parents[0]); err != nil {
+			return nil, err
+		}
+	}
+	p, err := a.free(dir, name, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := a.root.Mkdir(p, 0o700); err != nil {
+		return nil, err
+	}
+	f, err := a.stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return &f.FileMetadata, nil
+}
+
+func (a *Adapter) ListRootFolders(ctx context.Context) ([]adapter.FileMetadata, error) {
+	files, err := a.ListFiles(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	folders := files[:0]
+	for _, f := range files {
+		if f.MIMEType == folderMIMEType {
+			folders = append(folders, f)
+		}
+	}
+	return folders, nil
+}
+
+func (a *Adapter) EnsureRootFolder(ctx context.Context, name string) (string, error) {
+	if f, err := a.stat(name); err == nil && f.MIMEType == folderMIMEType {
+		return f.ID, nil
+	}
+	f, err := a.CreateFolder(ctx, name, nil)
+	if err != nil {
+		return "", err
+	}
+	return f.ID, nil
+}
+
+func (a *Adapter) DeleteFile(ctx context.Context, fileID string) error {
+	p, err := clean(fileID)
+	if err != nil {
+		return err
+	}
+	if p == "." {
+		return errors.New("cannot delete the base folder")
+	}
+	if _, err := a.root.Lstat(p); errors.Is(err, fs.ErrNotExist) {
+		return adapter.ErrNotFound
+	}
+	return a.root.RemoveAll(p)
+}
+
+func (a *Adapter) DuplicateFile(ctx context.Context, fileID string) (*adapter.FileMetadata, error) {
+	f, err := a.stat(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if f.MIMEType == folderMIMEType {
+		return nil, fmt.Errorf("%w: folders cannot be duplicated", errors.ErrUnsupported)
+	}
+	return a.CreateFile(ctx, "Copy of "+f.Name, f.Content, f.Parents[0])
+}
+
+// RenameFile renames the file in its folder. Its ID changes with its path.
+func (a *Adapter) RenameFile(ctx context.Context, fileID string, newName string) (*adapter.FileMetadata, error) {
+	f, err := a.stat(fileID)
+	if err != nil {
+		return nil, err
+	}
+	ext := mdExt
+	if f.MIMEType == folderMIMEType {
+		ext = ""
+	}
+	newName = strings.TrimSuffix(newName, ext)
+	if newName == f.Name {
+		return &f.FileMetadata, nil
+	}
+	p, err := a.free(f.Parents[0], newName, ext)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.root.Rename(f.ID, p); err != nil {
+		return nil, err
+	}
+	renamed, err := a.stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return &renamed.FileMetadata, nil
+}
+
+func unsupported(what string) error {
+	return fmt.Errorf("%w: local storage does not support %s", errors.ErrUnsupported, what)
+}
+
+func (a *Adapter) SetStarred(ctx context.Context, fileID string, starred bool) (*adapter.FileMetadata, error) {
+	return nil, unsupported("starring")
+}
+
+func (a *Adapter) SetOrderIndex(ctx context.Context, fileID string, index int) (*adapter.FileMetadata, error) {
+	return nil, unsupported("starring")
+}
+
+func (a *Adapter) SetAppearance(ctx context.Context, fileID string, color, icon *string) (*adapter.FileMetadata, error) {
+	return nil, unsupported("folder colors and icons")
+}
+
+func (a *Adapter) SetArchived(ctx context.Context, fileID string, archived bool) (*adapter.FileMetadata, error) {
+	return nil, unsupported("archiving")
+}
+
+func (a *Adapter) ListArchived(ctx context.Context) ([]adapter.FileMetadata, error) {
+	return []adapter.FileMetadata{}, nil
+}
+
+func (a *Adapter) SetProperties(ctx context.Context, fileID string, props map[string]string) (*adapter.FileMetadata, error) {
+	return nil, unsupported("properties")
+}
+
+func (a *Adapter) ListStarred(ctx context.Context) ([]adapter.FileMetadata, error) {
+	return []adapter.FileMetadata{}, nil
+}
+
+// SearchFiles returns the notes whose name or content contains query,
+// ignoring case.
+func (a *Adapter) SearchFiles(ctx context.Context, query string) ([]adapter.FileMetadata, error) {
+	query = strings.ToLower(query)
+	files := []adapter.FileMetadata{}
+	err := fs.WalkDir(a.root.FS(), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(p, mdExt) {
+			return ctx.Err()
+		}
+		content, err := a.root.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(d.Name(), mdExt)
+		if strings.Contains(strings.ToLower(name), query) || strings.Contains(strings.ToLower(string(content)), query) {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			files = append(files, metadata(p, info, content))
+		}
+		return ctx.Err()
+	})
+	return files, err
+}
@@ -0,0 +1,84 @@
+package localfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+func TestAdapter(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage, err := NewProvider(dir).GetAdapter(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	folderID, err := storage.EnsureRootFolder(ctx, "Work")
+	if err != nil || folderID != "Work" {
+		t.Fatalf("EnsureRootFolder = %q, %v", folderID, err)
+	}
+	if again, _ := storage.EnsureRootFolder(ctx, "Work"); again != folderID {
+		t.Errorf("second EnsureRootFolder = %q", again)
+	}
+	note, err := storage.CreateFile(ctx, "Plan", []byte("v1"), folderID)
+	if err != nil || note.ID != "Work/Plan.md" || note.Name != "Plan" {
+		t.Fatalf("CreateFile = %+v, %v", note, err)
+	}
+	// A taken name gets a number.
+	twin, _ := storage.CreateFile(ctx, "Plan.md", []byte("other"), folderID)
+	if twin.ID != "Work/Plan (2).md" {
+		t.Errorf("second CreateFile ID = %q", twin.ID)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "u1", "Work", "Plan.md")); string(content) != "v1" {
+		t.Errorf("file content = %q", content)
+	}
+
+	if _, err := storage.SaveFile(ctx, note.ID, []byte("v2"), "stale"); !errors.Is(err, adapter.ErrPreconditionFailed) {
+		t.Errorf("SaveFile with a stale ETag error = %v", err)
+	}
+	saved, err := storage.SaveFile(ctx, note.ID, []byte("v2"), note.ETag)
+	if err != nil || saved.ETag == note.ETag {
+		t.Fatalf("SaveFile = %+v, %v", saved, err)
+	}
+
+	renamed, err := storage.RenameFile(ctx, note.ID, "Roadmap")
+	if err != nil || renamed.ID != "Work/Roadmap.md" {
+		t.Fatalf("RenameFile = %+v, %v", renamed, err)
+	}
+	if f, err := storage.GetFile(ctx, renamed.ID); err != nil || string(f.Content) != "v2" {
+		t.Errorf("GetFile after rename = %+v, %v", f, err)
+	}
+	if found, _ := storage.SearchFiles(ctx, "OTHER"); len(found) != 1 || found[0].ID != twin.ID {
+		t.Errorf("SearchFiles = %+v", found)
+	}
+
+	if err := storage.DeleteFile(ctx, renamed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := storage.ListFiles(ctx, folderID); len(files) != 1 {
+		t.Errorf("ListFiles after delete = %+v", files)
+	}
+	if _, err := storage.GetFile(ctx, renamed.ID); !errors.Is(err, adapter.ErrNotFound) {
+		t.Errorf("GetFile after delete error = %v", err)
+	}
+}
+
+func TestAdapter_StaysInsideDirectory(t *testing.T) {
+	ctx := context.Background()
+	p := NewProvider(t.TempDir())
+	if _, err := p.GetAdapter(ctx, ".."); err == nil {
+		t.Error("GetAdapter(..) succeeded")
+	}
+	storage, _ := p.GetAdapter(ctx, "u1")
+	if _, err := storage.GetFile(ctx, "../u2/Secret.md"); !errors.Is(err, adapter.ErrNotFound) {
+		t.Errorf("GetFile outside the directory error = %v", err)
+	}
+	if _, err := storage.CreateFile(ctx, "../Escape", nil, ""); err == nil {
+		t.Error("CreateFile with a path as its name succeeded")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jun/gophdrive/backend/internal/adapter"
 	"github.com/jun/gophdrive/backend/internal/auth"
+	"github.com/jun/gophdrive/backend/internal/metrics"
 )
 
 const mdExt = ".md"
@@ -33,11 +34,16 @@ func fromMemoryName(name string) string {
 }
 
 func getTableName() *string {
-	name := os.Getenv("FILE_STORE_TABLE")
-	if name == "" {
-		name = "FileStore"
+	return aws.String(TableName())
+}
+
+// TableName returns the DynamoDB table that persists notes, from
+// FILE_STORE_TABLE or "FileStore".
+func TableName() string {
+	if name := os.Getenv("FILE_STORE_TABLE"); name != "" {
+		return name
 	}
-	return aws.String(name)
+	return "FileStore"
 }
 
 // MemoryAdapter implements adapter.StorageAdapter.
@@ -60,6 +66,13 @@ const (
 	maxDemoItemCount   = 50
 )
 
+// demoLimitError records a demo limit rejection and returns an error
+// wrapping adapter.ErrLimitExceeded.
+func demoLimitError(ctx context.Context, format string, args ...any) error {
+	metrics.Add(ctx, metrics.DemoLimitRejections, 1)
+	return fmt.Errorf("%w: %s", adapter.ErrLimitExceeded, fmt.Sprintf(format, args...))
+}
+
 func (m *MemoryAdapter) countUserItems(ctx context.Context) (int, error) {
 	if m.client == nil {
 		m.mu.RLock()
@@ -83,18 +96,93 @@ func (m *MemoryAdapter) countUserItems(ctx context.Context) (int, error) {
 }
 
 type FileItem struct {
-	PK           string    `dynamodbav:"pk"`
-	UserID       string    `dynamodbav:"user_id"`
-	ID           string    `dynamodbav:"id"`
-	Name         string    `dynamodbav:"name"`
-	MIMEType     string    `dynamodbav:"mime_type"`
-	ModifiedTime time.Time `dynamodbav:"modified_time"`
-	Size         int64     `dynamodbav:"size"`
-	ETag         string    `dynamodbav:"etag"`
-	Parents      []string  `dynamodbav:"parents"`
-	Starred      bool      `dynamodbav:"starred"`
-	Content      []byte    `dynamodbav:"content"`
-	TTL          int64     `dynamodbav:"ttl"`
+	PK           string            `dynamodbav:"pk"`
+	UserID       string            `dynamodbav:"user_id"`
+	ID           string            `dynamodbav:"id"`
+	Name         string            `dynamodbav:"name"`
+	MIMEType     string            `dynamodbav:"mime_type"`
+	ModifiedTime time.Time         `dynamodbav:"modified_time"`
+	Size         int64             `dynamodbav:"size"`
+	ETag         string            `dynamodbav:"etag"`
+	Parents      []string          `dynamodbav:"parents"`
+	Starred      bool              `dynamodbav:"starred"`
+	OrderIndex   int               `dynamodbav:"order_index,omitempty"`
+	Color        string            `dynamodbav:"color,omitempty"`
+	Icon         string            `dynamodbav:"icon,omitempty"`
+	Archived     bool              `dynamodbav:"archived,omitempty"`
+	ContentHash  string            `dynamodbav:"content_hash,omitempty"`
+	Properties   map[string]string `dynamodbav:"properties,omitempty"`
+	Content      []byte            `dynamodbav:"content"`
+	TTL          int64             `dynamodbav:"ttl"`
+}
+
+// metadata returns the item's metadata. Note names lose their .md
+// extension; folder names are kept as they are.
+func (item FileItem) metadata() adapter.FileMetadata {
+	name := item.Name
+	if item.MIMEType != "application/vnd.google-apps.folder" {
+		name = fromMemoryName(name)
+	}
+	return adapter.FileMetadata{
+		ID:           item.ID,
+		Name:         name,
+		MIMEType:     item.MIMEType,
+		ModifiedTime: item.ModifiedTime,
+		Size:         item.Size,
+		ETag:         item.ETag,
+		Parents:      item.Parents,
+		Starred:      item.Starred,
+		Encrypted:    adapter.IsEncrypted(item.Content),
+		Protected:    adapter.IsProtected(item.Content),
+		OrderIndex:   item.OrderIndex,
+		Color:        item.Color,
+		Icon:         item.Icon,
+		Properties:   item.Properties,
+		Archived:     item.Archived,
+		ContentHash:  item.ContentHash,
+	}
+}
+
+// putFile writes f to DynamoDB, replacing any earlier version, and sets
+// f.ContentHash. Note names are stored with the .md extension. Items
+// expire an hour after their last write.
+func (m *MemoryAdapter) putFile(ctx context.Context, f *adapter.File) error {
+	name := f.Name
+	if f.MIMEType != "application/vnd.google-apps.folder" {
+		name = toMemoryName(name)
+		f.ContentHash = adapter.ContentHash(f.Content)
+	}
+	item := FileItem{
+		PK:           f.ID,
+		UserID:       m.userID,
+		ID:           f.ID,
+		Name:         name,
+		MIMEType:     f.MIMEType,
+		ModifiedTime: f.ModifiedTime,
+		Size:         f.Size,
+		ETag:         f.ETag,
+		Parents:      f.Parents,
+		Starred:      f.Starred,
+		OrderIndex:   f.OrderIndex,
+		Color:        f.Color,
+		Icon:         f.Icon,
+		Properties:   f.Properties,
+		Archived:     f.Archived,
+		ContentHash:  f.ContentHash,
+		Content:      f.Content,
+		TTL:          time.Now().Add(60 * time.Minute).Unix(),
+	}
+
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: getTableName(),
+		Item:      av,
+	})
+	return err
 }
 
 func NewMemoryAdapter(client *dynamodb.Client, userID string, baseFolderID string) *MemoryAdapter {
@@ -158,20 +246,7 @@ func (m *MemoryAdapter) ListFiles(ctx context.Context, folderID string) ([]adapt
 		}
 
 		if isChild {
-			name := item.Name
-			if item.MIMEType != "application/vnd.google-apps.folder" {
-				name = fromMemoryName(name)
-			}
-			files = append(files, adapter.FileMetadata{
-				ID:           item.ID,
-				Name:         name,
-				MIMEType:     item.MIMEType,
-				ModifiedTime: item.ModifiedTime,
-				Size:         item.Size,
-				ETag:         item.ETag,
-				Parents:      item.Parents,
-				Starred:      item.Starred,
-			})
+			files = append(files, item.metadata())
 		}
 	}
 	return files, nil
@@ -201,23 +276,14 @@ func (m *MemoryAdapter) GetFile(ctx context.Context, fileID string) (*adapter.Fi
 	}
 
 	return &adapter.File{
-		FileMetadata: adapter.FileMetadata{
-			ID:           item.ID,
-			Name:         fromMemoryName(item.Name),
-			MIMEType:     item.MIMEType,
-			ModifiedTime: item.ModifiedTime,
-			Size:         item.Size,
-			ETag:         item.ETag,
-			Parents:      item.Parents,
-			Starred:      item.Starred,
-		},
-		Content: item.Content,
+		FileMetadata: item.metadata(),
+		Content:      item.Content,
 	}, nil
 }
 
 func (m *MemoryAdapter) SaveFile(ctx context.Context, fileID string, content []byte, etag string) (*adapter.FileMetadata, error) {
 	if len(content) > maxDemoContentSize {
-		return nil, fmt.Errorf("content too large (max %d bytes)", maxDemoContentSize)
+		return nil, demoLimitError(ctx, "content too large (max %d bytes)", maxDemoContentSize)
 	}
 
 	if m.client == nil {
@@ -238,31 +304,10 @@ func (m *MemoryAdapter) SaveFile(ctx context.Context, fileID string, content []b
 	f.ModifiedTime = time.Now()
 	f.ETag = uuid.New().String()
 	f.Size = int64(len(content))
+	f.Encrypted = adapter.IsEncrypted(content)
+	f.Protected = adapter.IsProtected(content)
 
-	item := FileItem{
-		PK:           f.ID,
-		UserID:       m.userID,
-		ID:           f.ID,
-		Name:         toMemoryName(f.Name),
-		MIMEType:     f.MIMEType,
-		ModifiedTime: f.ModifiedTime,
-		Size:         f.Size,
-		ETag:         f.ETag,
-		Parents:      f.Parents,
-		Content:      f.Content,
-		TTL:          time.Now().Add(60 * time.Minute).Unix(),
-	}
-
-	av, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: getTableName(),
-		Item:      av,
-	})
-	if err != nil {
+	if err := m.putFile(ctx, f); err != nil {
 		return nil, err
 	}
 
@@ -273,15 +318,15 @@ func (m *MemoryAdapter) SaveFile(ctx context.Context, fileID string, content []b
 
 func (m *MemoryAdapter) CreateFile(ctx context.Context, name string, content []byte, folderID string) (*adapter.FileMetadata, error) {
 	if len(name) > maxDemoTitleLength {
-		return nil, fmt.Errorf("name too long (max %d characters)", maxDemoTitleLength)
+		return nil, demoLimitError(ctx, "name too long (max %d characters)", maxDemoTitleLength)
 	}
 	if len(content) > maxDemoContentSize {
-		return nil, fmt.Errorf("content too large (max %d bytes)", maxDemoContentSize)
+		return nil, demoLimitError(ctx, "content too large (max %d bytes)", maxDemoContentSize)
 	}
 
 	count, _ := m.countUserItems(ctx)
 	if count >= maxDemoItemCount {
-		return nil, fmt.Errorf("item limit reached for demo mode (max %d items)", maxDemoItemCount)
+		return nil, demoLimitError(ctx, "item limit reached for demo mode (max %d items)", maxDemoItemCount)
 	}
 
 	targetFolderID := folderID
@@ -307,34 +352,13 @@ func (m *MemoryAdapter) CreateFile(ctx context.Context, name string, content []b
 			Size:         int64(len(content)),
 			ETag:         uuid.New().String(),
 			Parents:      []string{targetFolderID},
+			Encrypted:    adapter.IsEncrypted(content),
+			Protected:    adapter.IsProtected(content),
 		},
 		Content: content,
 	}
 
-	item := FileItem{
-		PK:           f.ID,
-		UserID:       m.userID,
-		ID:           f.ID,
-		Name:         toMemoryName(f.Name),
-		MIMEType:     f.MIMEType,
-		ModifiedTime: f.ModifiedTime,
-		Size:         f.Size,
-		ETag:         f.ETag,
-		Parents:      f.Parents,
-		Content:      f.Content,
-		TTL:          time.Now().Add(60 * time.Minute).Unix(),
-	}
-
-	av, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: getTableName(),
-		Item:      av,
-	})
-	if err != nil {
+	if err := m.putFile(ctx, f); err != nil {
 		return nil, err
 	}
 
@@ -345,12 +369,12 @@ func (m *MemoryAdapter) CreateFile(ctx context.Context, name string, content []b
 
 func (m *MemoryAdapter) CreateFolder(ctx context.Context, name string, parents []string) (*adapter.FileMetadata, error) {
 	if len(name) > maxDemoTitleLength {
-		return nil, fmt.Errorf("name too long (max %d characters)", maxDemoTitleLength)
+		return nil, demoLimitError(ctx, "name too long (max %d characters)", maxDemoTitleLength)
 	}
 
 	count, _ := m.countUserItems(ctx)
 	if count >= maxDemoItemCount {
-		return nil, fmt.Errorf("item limit reached for demo mode (max %d items)", maxDemoItemCount)
+		return nil, demoLimitError(ctx, "item limit reached for demo mode (max %d items)", maxDemoItemCount)
 	}
 
 	targetParents := parents
@@ -379,30 +403,7 @@ func (m *MemoryAdapter) CreateFolder(ctx context.Context, name string, parents [
 		},
 	}
 
-	item := FileItem{
-		PK:           f.ID,
-		UserID:       m.userID,
-		ID:           f.ID,
-		Name:         f.Name,
-		MIMEType:     f.MIMEType,
-		ModifiedTime: f.ModifiedTime,
-		Size:         f.Size,
-		ETag:         f.ETag,
-		Parents:      f.Parents,
-		Content:      nil,
-		TTL:          time.Now().Add(60 * time.Minute).Unix(),
-	}
-
-	av, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: getTableName(),
-		Item:      av,
-	})
-	if err != nil {
+	if err := m.putFile(ctx, f); err != nil {
 		return nil, err
 	}
 	return &f.FileMetadata, nil
@@ -449,30 +450,7 @@ func (m *MemoryAdapter) createRootFolder(ctx context.Context, name string) (stri
 		},
 	}
 
-	item := FileItem{
-		PK:           f.ID,
-		UserID:       m.userID,
-		ID:           f.ID,
-		Name:         f.Name,
-		MIMEType:     f.MIMEType,
-		ModifiedTime: f.ModifiedTime,
-		Size:         f.Size,
-		ETag:         f.ETag,
-		Parents:      f.Parents,
-		Content:      nil,
-		TTL:          time.Now().Add(60 * time.Minute).Unix(),
-	}
-
-	av, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return "", err
-	}
-
-	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: getTableName(),
-		Item:      av,
-	})
-	if err != nil {
+	if err := m.putFile(ctx, f); err != nil {
 		return "", err
 	}
 	return id, nil
@@ -533,7 +511,7 @@ func (m *MemoryAdapter) DeleteFile(ctx context.Context, fileID string) error {
 func (m *MemoryAdapter) DuplicateFile(ctx context.Context, fileID string) (*adapter.FileMetadata, error) {
 	count, _ := m.countUserItems(ctx)
 	if count >= maxDemoItemCount {
-		return nil, fmt.Errorf("item limit reached for demo mode (max %d items)", maxDemoItemCount)
+		return nil, demoLimitError(ctx, "item limit reached for demo mode (max %d items)", maxDemoItemCount)
 	}
 
 	if m.client == nil {
@@ -560,6 +538,8 @@ func (m *MemoryAdapter) DuplicateFile(ctx context.Context, fileID string) (*adap
 			Size:         orig.Size,
 			ETag:         uuid.New().String(),
 			Parents:      orig.Parents,
+			Encrypted:    orig.Encrypted,
+			Protected:    orig.Protected,
 		},
 		Content: orig.Content, // Shallow copy of content slice is fine for now as we don't modify it in place usually
 	}
@@ -569,30 +549,7 @@ func (m *MemoryAdapter) DuplicateFile(ctx context.Context, fileID string) (*adap
 	copy(newContent, orig.Content)
 	f.Content = newContent
 
-	item := FileItem{
-		PK:           f.ID,
-		UserID:       m.userID,
-		ID:           f.ID,
-		Name:         toMemoryName(f.Name),
-		MIMEType:     f.MIMEType,
-		ModifiedTime: f.ModifiedTime,
-		Size:         f.Size,
-		ETag:         f.ETag,
-		Parents:      f.Parents,
-		Content:      f.Content,
-		TTL:          time.Now().Add(60 * time.Minute).Unix(),
-	}
-
-	av, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: getTableName(),
-		Item:      av,
-	})
-	if err != nil {
+	if err := m.putFile(ctx, f); err != nil {
 		return nil, err
 	}
 
@@ -661,6 +618,9 @@ func (m *MemoryAdapter) saveFileMap(ctx context.Context, fileID string, content
 	f.ModifiedTime = time.Now()
 	f.ETag = uuid.New().String()
 	f.Size = int64(len(content))
+	f.Encrypted = adapter.IsEncrypted(content)
+	f.Protected = adapter.IsProtected(content)
+	f.ContentHash = adapter.ContentHash(content)
 	f.Name = toMemoryName(f.Name)
 	return &f.FileMetadata, nil
 }
@@ -678,6 +638,9 @@ func (m *MemoryAdapter) createFileMap(ctx context.Context, name string, content
 			Size:         int64(len(content)),
 			ETag:         uuid.New().String(),
 			Parents:      []string{folderID},
+			Encrypted:    adapter.IsEncrypted(content),
+			Protected:    adapter.IsProtected(content),
+			ContentHash:  adapter.ContentHash(content),
 		},
 		Content: content,
 	}
@@ -799,6 +762,9 @@ func (m *MemoryAdapter) duplicateFileMap(ctx context.Context, fileID string) (*a
 			Size:         int64(len(newContent)),
 			ETag:         uuid.New().String(),
 			Parents:      orig.Parents,
+			Encrypted:    orig.Encrypted,
+			Protected:    orig.Protected,
+			ContentHash:  orig.ContentHash,
 		},
 		Content: newContent,
 	}
@@ -829,34 +795,13 @@ func (m *MemoryAdapter) duplicateFileDynamo(ctx context.Context, fileID string)
 			Size:         orig.Size,
 			ETag:         uuid.New().String(),
 			Parents:      orig.Parents,
+			Encrypted:    orig.Encrypted,
+			Protected:    orig.Protected,
 		},
 		Content: orig.Content,
 	}
 
-	item := FileItem{
-		PK:           f.ID,
-		UserID:       m.userID,
-		ID:           f.ID,
-		Name:         toMemoryName(f.Name),
-		MIMEType:     f.MIMEType,
-		ModifiedTime: f.ModifiedTime,
-		Size:         f.Size,
-		ETag:         f.ETag,
-		Parents:      f.Parents,
-		Content:      f.Content,
-		TTL:          time.Now().Add(60 * time.Minute).Unix(),
-	}
-
-	av, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: getTableName(),
-		Item:      av,
-	})
-	if err != nil {
+	if err := m.putFile(ctx, f); err != nil {
 		return nil, err
 	}
 
@@ -867,7 +812,7 @@ func (m *MemoryAdapter) duplicateFileDynamo(ctx context.Context, fileID string)
 
 func (m *MemoryAdapter) RenameFile(ctx context.Context, fileID string, newName string) (*adapter.FileMetadata, error) {
 	if len(newName) > maxDemoTitleLength {
-		return nil, fmt.Errorf("name too long (max %d characters)", maxDemoTitleLength)
+		return nil, demoLimitError(ctx, "name too long (max %d characters)", maxDemoTitleLength)
 	}
 
 	if m.client == nil {
@@ -890,31 +835,7 @@ func (m *MemoryAdapter) RenameFile(ctx context.Context, fileID string, newName s
 	// ETag should probably change on rename? Yes.
 	orig.ETag = uuid.New().String()
 
-	item := FileItem{
-		PK:           orig.ID,
-		UserID:       m.userID,
-		ID:           orig.ID,
-		Name:         orig.Name,
-		MIMEType:     orig.MIMEType,
-		ModifiedTime: orig.ModifiedTime,
-		Size:         orig.Size,
-		ETag:         orig.ETag,
-		Parents:      orig.Parents,
-		Starred:      orig.Starred,
-		Content:      orig.Content,
-		TTL:          time.Now().Add(60 * time.Minute).Unix(),
-	}
-
-	av, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: getTableName(),
-		Item:      av,
-	})
-	if err != nil {
+	if err := m.putFile(ctx, orig); err != nil {
 		return nil, err
 	}
 
@@ -937,34 +858,144 @@ func (m *MemoryAdapter) SetStarred(ctx context.Context, fileID string, starred b
 	orig.ModifiedTime = time.Now()
 	orig.ETag = uuid.New().String()
 
-	item := FileItem{
-		PK:           orig.ID,
-		UserID:       m.userID,
-		ID:           orig.ID,
-		Name:         orig.Name,
-		MIMEType:     orig.MIMEType,
-		ModifiedTime: orig.ModifiedTime,
-		Size:         orig.Size,
-		ETag:         orig.ETag,
-		Parents:      orig.Parents,
-		Starred:      orig.Starred,
-		Content:      orig.Content,
-		TTL:          time.Now().Add(60 * time.Minute).Unix(),
+	if err := m.putFile(ctx, orig); err != nil {
+		return nil, err
 	}
 
-	av, err := attributevalue.MarshalMap(item)
+	return &orig.FileMetadata, nil
+}
+
+// SetOrderIndex sets a file's position in the starred list.
+func (m *MemoryAdapter) SetOrderIndex(ctx context.Context, fileID string, index int) (*adapter.FileMetadata, error) {
+	if m.client == nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		f, ok := m.files[fileID]
+		if !ok {
+			return nil, adapter.ErrNotFound
+		}
+		f.OrderIndex = index
+		meta := f.FileMetadata
+		if meta.MIMEType != "application/vnd.google-apps.folder" {
+			meta.Name = fromMemoryName(meta.Name)
+		}
+		return &meta, nil
+	}
+
+	orig, err := m.GetFile(ctx, fileID)
 	if err != nil {
 		return nil, err
 	}
+	orig.OrderIndex = index
+	if err := m.putFile(ctx, orig); err != nil {
+		return nil, err
+	}
+	return &orig.FileMetadata, nil
+}
 
-	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: getTableName(),
-		Item:      av,
-	})
+// SetAppearance sets a folder's color and icon.
+func (m *MemoryAdapter) SetAppearance(ctx context.Context, fileID string, color, icon *string) (*adapter.FileMetadata, error) {
+	apply := func(f *adapter.File) error {
+		if f.MIMEType != "application/vnd.google-apps.folder" {
+			return adapter.ErrNotFolder
+		}
+		if color != nil {
+			f.Color = *color
+		}
+		if icon != nil {
+			f.Icon = *icon
+		}
+		return nil
+	}
+
+	if m.client == nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		f, ok := m.files[fileID]
+		if !ok {
+			return nil, adapter.ErrNotFound
+		}
+		if err := apply(f); err != nil {
+			return nil, err
+		}
+		meta := f.FileMetadata
+		return &meta, nil
+	}
+
+	orig, err := m.GetFile(ctx, fileID)
 	if err != nil {
 		return nil, err
 	}
+	if err := apply(orig); err != nil {
+		return nil, err
+	}
+	if err := m.putFile(ctx, orig); err != nil {
+		return nil, err
+	}
+	return &orig.FileMetadata, nil
+}
+
+// SetArchived archives or restores a file.
+func (m *MemoryAdapter) SetArchived(ctx context.Context, fileID string, archived bool) (*adapter.FileMetadata, error) {
+	if m.client == nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		f, ok := m.files[fileID]
+		if !ok {
+			return nil, adapter.ErrNotFound
+		}
+		f.Archived = archived
+		meta := f.FileMetadata
+		if meta.MIMEType != "application/vnd.google-apps.folder" {
+			meta.Name = fromMemoryName(meta.Name)
+		}
+		return &meta, nil
+	}
 
+	orig, err := m.GetFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	orig.Archived = archived
+	if err := m.putFile(ctx, orig); err != nil {
+		return nil, err
+	}
+	return &orig.FileMetadata, nil
+}
+
+// SetProperties merges props into a file's custom properties.
+func (m *MemoryAdapter) SetProperties(ctx context.Context, fileID string, props map[string]string) (*adapter.FileMetadata, error) {
+	if m.client == nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		f, ok := m.files[fileID]
+		if !ok {
+			return nil, adapter.ErrNotFound
+		}
+		merged, err := adapter.MergeProperties(f.Properties, props)
+		if err != nil {
+			return nil, err
+		}
+		f.Properties = merged
+		meta := f.FileMetadata
+		if meta.MIMEType != "application/vnd.google-apps.folder" {
+			meta.Name = fromMemoryName(meta.Name)
+		}
+		return &meta, nil
+	}
+
+	orig, err := m.GetFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := adapter.MergeProperties(orig.Properties, props)
+	if err != nil {
+		return nil, err
+	}
+	orig.Properties = merged
+	if err := m.putFile(ctx, orig); err != nil {
+		return nil, err
+	}
 	return &orig.FileMetadata, nil
 }
 
@@ -1009,38 +1040,49 @@ type Provider struct {
 	client      *dynamodb.Client
 	authService *auth.AuthService
 	stores      map[string]*MemoryAdapter
+	stale       map[string]bool // users whose base folder may have changed
 	mu          sync.Mutex
 }
 
 func NewProvider(client *dynamodb.Client, authService *auth.AuthService) *Provider {
-	return &Provider{
+	p := &Provider{
 		client:      client,
 		authService: authService,
 		stores:      make(map[string]*MemoryAdapter),
+		stale:       make(map[string]bool),
+	}
+	if authService != nil {
+		authService.OnTokenChange(func(userID string) {
+			p.mu.Lock()
+			p.stale[userID] = true
+			p.mu.Unlock()
+		})
 	}
+	return p
 }
 
 func (p *Provider) GetAdapter(ctx context.Context, userID string) (adapter.StorageAdapter, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if _, ok := p.stores[userID]; !ok {
-		// Fetch BaseFolderID from auth service if available
-		var baseFolderID string
-		if p.authService != nil {
-			if token, err := p.authService.GetUserToken(ctx, userID); err == nil {
-				baseFolderID = token.BaseFolderID
-			}
-		}
-		p.stores[userID] = NewMemoryAdapter(p.client, userID, baseFolderID)
+	store, ok := p.stores[userID]
+	if ok && !p.stale[userID] {
+		return store, nil
 	}
-	// Update BaseFolderID if it changed (simple approach: always update on get?)
-	// For now, let's just update it if we have the service.
+	// Fetch BaseFolderID from auth service if available
+	var baseFolderID string
 	if p.authService != nil {
 		if token, err := p.authService.GetUserToken(ctx, userID); err == nil {
-			p.stores[userID].BaseFolderID = token.BaseFolderID
+			baseFolderID = token.BaseFolderID
 		}
 	}
-	return p.stores[userID], nil
+	if ok {
+		store.BaseFolderID = baseFolderID
+	} else {
+		store = NewMemoryAdapter(p.client, userID, baseFolderID)
+		p.stores[userID] = store
+	}
+	delete(p.stale, userID)
+	return store, nil
 }
 
 // ListRootFolders lists "actual" root folders (parents=[] or parents=["root"])
@@ -1081,16 +1123,7 @@ func (m *MemoryAdapter) ListRootFolders(ctx context.Context) ([]adapter.FileMeta
 				}
 			}
 			if isRoot {
-				files = append(files, adapter.FileMetadata{
-					ID:           item.ID,
-					Name:         item.Name,
-					MIMEType:     item.MIMEType,
-					ModifiedTime: item.ModifiedTime,
-					Size:         item.Size,
-					ETag:         item.ETag,
-					Parents:      item.Parents,
-					Starred:      item.Starred,
-				})
+				files = append(files, item.metadata())
 			}
 		}
 	}
@@ -1141,13 +1174,24 @@ func (m *MemoryAdapter) isDescendant(fileParents []string, targetFolderID string
 }
 
 func (m *MemoryAdapter) ListStarred(ctx context.Context) ([]adapter.FileMetadata, error) {
+	return m.listWhere(ctx, func(f adapter.FileMetadata) bool { return f.Starred })
+}
+
+// ListArchived lists all archived notes/folders.
+func (m *MemoryAdapter) ListArchived(ctx context.Context) ([]adapter.FileMetadata, error) {
+	return m.listWhere(ctx, func(f adapter.FileMetadata) bool { return f.Archived })
+}
+
+// listWhere lists the notes and folders under the base folder, at any
+// depth, for which keep returns true.
+func (m *MemoryAdapter) listWhere(ctx context.Context, keep func(adapter.FileMetadata) bool) ([]adapter.FileMetadata, error) {
 	targetFolderID := "root"
 	if m.BaseFolderID != "" {
 		targetFolderID = m.BaseFolderID
 	}
 
 	if m.client == nil {
-		return m.listStarredMap(ctx)
+		return m.listWhereMap(targetFolderID, keep)
 	}
 
 	// Scan and filter
@@ -1175,37 +1219,18 @@ func (m *MemoryAdapter) ListStarred(ctx context.Context) ([]adapter.FileMetadata
 
 	var files []adapter.FileMetadata
 	for _, item := range items {
-		if item.Starred {
-			if item.MIMEType != "application/vnd.google-apps.folder" && !strings.HasSuffix(item.Name, ".md") {
-				continue
-			}
-			if m.isDescendant(item.Parents, targetFolderID, parentMap) {
-				name := item.Name
-				if item.MIMEType != "application/vnd.google-apps.folder" {
-					name = fromMemoryName(name)
-				}
-				files = append(files, adapter.FileMetadata{
-					ID:           item.ID,
-					Name:         name,
-					MIMEType:     item.MIMEType,
-					ModifiedTime: item.ModifiedTime,
-					Size:         item.Size,
-					ETag:         item.ETag,
-					Parents:      item.Parents,
-					Starred:      item.Starred,
-				})
-			}
+		if item.MIMEType != "application/vnd.google-apps.folder" && !strings.HasSuffix(item.Name, ".md") {
+			continue
+		}
+		meta := item.metadata()
+		if keep(meta) && m.isDescendant(item.Parents, targetFolderID, parentMap) {
+			files = append(files, meta)
 		}
 	}
 	return files, nil
 }
 
-func (m *MemoryAdapter) listStarredMap(ctx context.Context) ([]adapter.FileMetadata, error) {
-	targetFolderID := "root"
-	if m.BaseFolderID != "" {
-		targetFolderID = m.BaseFolderID
-	}
-
+func (m *MemoryAdapter) listWhereMap(targetFolderID string, keep func(adapter.FileMetadata) bool) ([]adapter.FileMetadata, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -1217,17 +1242,15 @@ func (m *MemoryAdapter) listStarredMap(ctx context.Context) ([]adapter.FileMetad
 
 	var files []adapter.FileMetadata
 	for _, f := range m.files {
-		if f.Starred {
-			if f.MIMEType != "application/vnd.google-apps.folder" && !strings.HasSuffix(f.Name, ".md") {
-				continue
-			}
-			if m.isDescendant(f.Parents, targetFolderID, parentMap) {
-				meta := f.FileMetadata
-				if meta.MIMEType != "application/vnd.google-apps.folder" {
-					meta.Name = fromMemoryName(meta.Name)
-				}
-				files = append(files, meta)
+		if f.MIMEType != "application/vnd.google-apps.folder" && !strings.HasSuffix(f.Name, ".md") {
+			continue
+		}
+		if keep(f.FileMetadata) && m.isDescendant(f.Parents, targetFolderID, parentMap) {
+			meta := f.FileMetadata
+			if meta.MIMEType != "application/vnd.google-apps.folder" {
+				meta.Name = fromMemoryName(meta.Name)
 			}
+			files = append(files, meta)
 		}
 	}
 	return files, nil
@@ -1286,25 +1309,12 @@ func (m *MemoryAdapter) SearchFiles(ctx context.Context, query string) ([]adapte
 		match := false
 		if containsIgnoreCase(item.Name, query) {
 			match = true
-		} else if containsIgnoreCase(string(item.Content), query) {
+		} else if !adapter.IsEncrypted(item.Content) && containsIgnoreCase(string(item.Content), query) {
 			match = true
 		}
 
 		if match {
-			name := item.Name
-			if item.MIMEType != "application/vnd.google-apps.folder" {
-				name = fromMemoryName(name)
-			}
-			files = append(files, adapter.FileMetadata{
-				ID:           item.ID,
-				Name:         name,
-				MIMEType:     item.MIMEType,
-				ModifiedTime: item.ModifiedTime,
-				Size:         item.Size,
-				ETag:         item.ETag,
-				Parents:      item.Parents,
-				Starred:      item.Starred,
-			})
+			files = append(files, item.metadata())
 		}
 	}
 	return files, nil
@@ -1342,7 +1352,7 @@ func (m *MemoryAdapter) searchFilesMap(ctx context.Context, query string) ([]ada
 		match := false
 		if containsIgnoreCase(f.Name, query) {
 			match = true
-		} else if containsIgnoreCase(string(f.Content), query) {
+		} else if !f.Encrypted && containsIgnoreCase(string(f.Content), query) {
 			match = true
 		}
 
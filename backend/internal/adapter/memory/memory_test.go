@@ -2,6 +2,8 @@ package memory
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/jun/gophdrive/backend/internal/adapter"
@@ -215,6 +217,130 @@ func TestMemoryAdapter_ListStarred(t *testing.T) {
 	}
 }
 
+func TestMemoryAdapter_SetOrderIndex(t *testing.T) {
+	m := NewMemoryAdapter(nil, "user1", "")
+	ctx := context.Background()
+
+	f, _ := m.CreateFile(ctx, "ordered.md", []byte("content"), "root")
+	m.SetStarred(ctx, f.ID, true)
+
+	meta, err := m.SetOrderIndex(ctx, f.ID, 3)
+	if err != nil {
+		t.Fatalf("SetOrderIndex failed: %v", err)
+	}
+	if meta.OrderIndex != 3 || meta.Name != "ordered" || !meta.Starred {
+		t.Errorf("Unexpected metadata %+v", meta)
+	}
+
+	got, _ := m.GetFile(ctx, f.ID)
+	if got.OrderIndex != 3 || got.ETag != f.ETag {
+		t.Errorf("Expected orderIndex 3 and unchanged ETag, got %d / %s", got.OrderIndex, got.ETag)
+	}
+
+	if _, err := m.SetOrderIndex(ctx, "missing", 1); err != adapter.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryAdapter_SetAppearance(t *testing.T) {
+	m := NewMemoryAdapter(nil, "user1", "")
+	ctx := context.Background()
+
+	folder, _ := m.CreateFolder(ctx, "Projects", []string{"root"})
+	color, icon := "#4986e7", "rocket"
+	meta, err := m.SetAppearance(ctx, folder.ID, &color, &icon)
+	if err != nil {
+		t.Fatalf("SetAppearance failed: %v", err)
+	}
+	if meta.Color != color || meta.Icon != icon {
+		t.Errorf("Unexpected metadata %+v", meta)
+	}
+
+	// Only the icon is cleared; the color stays.
+	empty := ""
+	meta, _ = m.SetAppearance(ctx, folder.ID, nil, &empty)
+	if meta.Color != color || meta.Icon != "" {
+		t.Errorf("Expected color kept and icon cleared, got %+v", meta)
+	}
+
+	note, _ := m.CreateFile(ctx, "note.md", []byte("content"), "root")
+	if _, err := m.SetAppearance(ctx, note.ID, &color, nil); err != adapter.ErrNotFolder {
+		t.Errorf("Expected ErrNotFolder, got %v", err)
+	}
+}
+
+func TestMemoryAdapter_SetProperties(t *testing.T) {
+	m := NewMemoryAdapter(nil, "user1", "")
+	ctx := context.Background()
+
+	f, _ := m.CreateFile(ctx, "task.md", []byte("content"), "root")
+	if _, err := m.SetProperties(ctx, f.ID, map[string]string{"status": "open", "priority": "high"}); err != nil {
+		t.Fatalf("SetProperties failed: %v", err)
+	}
+	meta, err := m.SetProperties(ctx, f.ID, map[string]string{"status": "done", "priority": ""})
+	if err != nil {
+		t.Fatalf("SetProperties failed: %v", err)
+	}
+	if len(meta.Properties) != 1 || meta.Properties["status"] != "done" {
+		t.Errorf("Expected only status=done, got %v", meta.Properties)
+	}
+
+	tooMany := make(map[string]string)
+	for i := range adapter.MaxProperties {
+		tooMany[fmt.Sprintf("key%d", i)] = "x"
+	}
+	if _, err := m.SetProperties(ctx, f.ID, tooMany); !errors.Is(err, adapter.ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded, got %v", err)
+	}
+}
+
+func TestMemoryAdapter_Archive(t *testing.T) {
+	m := NewMemoryAdapter(nil, "user1", "")
+	ctx := context.Background()
+
+	f, _ := m.CreateFile(ctx, "reference.md", []byte("a"), "root")
+	m.CreateFile(ctx, "active.md", []byte("b"), "root")
+
+	meta, err := m.SetArchived(ctx, f.ID, true)
+	if err != nil {
+		t.Fatalf("SetArchived failed: %v", err)
+	}
+	if !meta.Archived || meta.Name != "reference" {
+		t.Errorf("Unexpected metadata %+v", meta)
+	}
+
+	archived, err := m.ListArchived(ctx)
+	if err != nil {
+		t.Fatalf("ListArchived failed: %v", err)
+	}
+	if len(archived) != 1 || archived[0].ID != f.ID {
+		t.Fatalf("Expected the archived note, got %+v", archived)
+	}
+
+	m.SetArchived(ctx, f.ID, false)
+	if archived, _ := m.ListArchived(ctx); len(archived) != 0 {
+		t.Errorf("Expected no archived notes after restoring, got %+v", archived)
+	}
+}
+
+func TestMemoryAdapter_ContentHash(t *testing.T) {
+	m := NewMemoryAdapter(nil, "user1", "")
+	ctx := context.Background()
+
+	f, _ := m.CreateFile(ctx, "hashed.md", []byte("first"), "root")
+	if f.ContentHash != adapter.ContentHash([]byte("first")) {
+		t.Errorf("CreateFile ContentHash = %q", f.ContentHash)
+	}
+	saved, _ := m.SaveFile(ctx, f.ID, []byte("second"), "")
+	if saved.ContentHash != adapter.ContentHash([]byte("second")) {
+		t.Errorf("SaveFile ContentHash = %q", saved.ContentHash)
+	}
+	dup, _ := m.DuplicateFile(ctx, f.ID)
+	if dup.ContentHash != saved.ContentHash {
+		t.Errorf("DuplicateFile ContentHash = %q, want %q", dup.ContentHash, saved.ContentHash)
+	}
+}
+
 func TestMemoryAdapter_SearchFiles(t *testing.T) {
 	m := NewMemoryAdapter(nil, "user1", "")
 	ctx := context.Background()
@@ -239,6 +365,39 @@ func TestMemoryAdapter_SearchFiles(t *testing.T) {
 	}
 }
 
+func TestMemoryAdapter_EncryptedNotes(t *testing.T) {
+	m := NewMemoryAdapter(nil, "user1", "")
+	ctx := context.Background()
+
+	plain, _ := m.CreateFile(ctx, "plain.md", []byte("secret plans"), "root")
+	sealed, _ := m.CreateFile(ctx, "diary.md", []byte(adapter.EncryptedPrefix+"v1:c2VjcmV0:secret"), "root")
+	if plain.Encrypted {
+		t.Error("Expected plain note not to be marked encrypted")
+	}
+	if !sealed.Encrypted {
+		t.Error("Expected sealed note to be marked encrypted")
+	}
+
+	// Ciphertext is never matched, only the name.
+	results, _ := m.SearchFiles(ctx, "secret")
+	if len(results) != 1 || results[0].ID != plain.ID {
+		t.Errorf("Expected only the plain note to match, got %+v", results)
+	}
+	results, _ = m.SearchFiles(ctx, "diary")
+	if len(results) != 1 || !results[0].Encrypted {
+		t.Errorf("Expected the encrypted note to match by name, got %+v", results)
+	}
+
+	// Saving plaintext over it clears the flag.
+	updated, err := m.SaveFile(ctx, sealed.ID, []byte("decrypted"), sealed.ETag)
+	if err != nil {
+		t.Fatalf("SaveFile failed: %v", err)
+	}
+	if updated.Encrypted {
+		t.Error("Expected flag to clear after saving plaintext")
+	}
+}
+
 func TestMemoryAdapter_ListRootFolders(t *testing.T) {
 	m := NewMemoryAdapter(nil, "user1", "")
 	ctx := context.Background()
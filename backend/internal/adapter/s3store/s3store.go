@@ -0,0 +1,481 @@
+// Package s3store stores notes as Markdown objects in an S3 bucket, under
+// one key prefix per user. A file's ID is its key below that prefix, such
+// as "Work/Plan.md", as in localfs, so the bucket can be read or restored
+// without GophDrive. Folders are key prefixes; an empty folder is kept by a
+// "<folder>/" marker object.
+//
+// It is meant as a mirror target (see the mirror package) for deployments
+// without a lasting disk, such as Lambda. Names that are taken get a
+// " (2)", " (3)"... suffix. Starring, archiving, folder appearance and
+// custom properties are not supported, folders cannot be renamed, and
+// SearchFiles only matches names.
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+const (
+	mdExt          = ".md"
+	folderMIMEType = "application/vnd.google-apps.folder"
+)
+
+// S3API is the part of the S3 client the adapter uses; *s3.Client
+// implements it.
+type S3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// Provider implements adapter.StorageProvider for a bucket.
+type Provider struct {
+	client S3API
+	bucket string
+	prefix string
+}
+
+// NewProvider stores notes in bucket under prefix, such as "backup/"; each
+// user's notes are under "<prefix><user ID>/".
+func NewProvider(client S3API, bucket, prefix string) *Provider {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &Provider{client: client, bucket: bucket, prefix: prefix}
+}
+
+// GetAdapter returns the adapter for userID's notes.
+func (p *Provider) GetAdapter(ctx context.Context, userID string) (adapter.StorageAdapter, error) {
+	if userID == "" || userID == "." || userID == ".." || strings.ContainsAny(userID, `/\`) {
+		return nil, fmt.Errorf("invalid user ID %q", userID)
+	}
+	return &Adapter{client: p.client, bucket: p.bucket, base: p.prefix + userID + "/"}, nil
+}
+
+// Adapter implements adapter.StorageAdapter for one user's key prefix.
+type Adapter struct {
+	client S3API
+	bucket string
+	base   string // the user's key prefix, ending in "/"
+}
+
+var _ adapter.StorageAdapter = (*Adapter)(nil)
+
+// clean checks that id is a relative path below the user's prefix; "" is
+// the prefix itself.
+func clean(id string) (string, error) {
+	if id == "" || id == "root" {
+		return "", nil
+	}
+	if !fs.ValidPath(id) || id == "." {
+		return "", fmt.Errorf("%w: invalid file ID %q", adapter.ErrNotFound, id)
+	}
+	return id, nil
+}
+
+// dirPrefix is the key prefix of the items in folder id.
+func (a *Adapter) dirPrefix(id string) string {
+	if id == "" {
+		return a.base
+	}
+	return a.base + id + "/"
+}
+
+func isNotFound(err error) bool {
+	var noKey *types.NoSuchKey
+	var notFound *types.NotFound
+	return errors.As(err, &noKey) || errors.As(err, &notFound)
+}
+
+// metadata describes the note id. Its ETag is the MD5 of its content,
+// which S3 also reports as the object's ETag for single-part uploads.
+func metadata(id string, modified time.Time, size int64, hash string) adapter.FileMetadata {
+	return adapter.FileMetadata{
+		ID:           id,
+		Name:         strings.TrimSuffix(path.Base(id), mdExt),
+		MIMEType:     "text/markdown",
+		ModifiedTime: modified,
+		Size:         size,
+		ETag:         hash,
+		ContentHash:  hash,
+		Parents:      []string{parent(id)},
+	}
+}
+
+func folderMetadata(id string) adapter.FileMetadata {
+	return adapter.FileMetadata{
+		ID:       id,
+		Name:     path.Base(id),
+		MIMEType: folderMIMEType,
+		Parents:  []string{parent(id)},
+	}
+}
+
+func parent(id string) string {
+	if dir := path.Dir(id); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// noteFile returns the note id with its content.
+func noteFile(id string, modified time.Time, content []byte) *adapter.File {
+	f := &adapter.File{
+		FileMetadata: metadata(id, modified, int64(len(content)), adapter.ContentHash(content)),
+		Content:      content,
+	}
+	f.Encrypted = adapter.IsEncrypted(content)
+	f.Protected = adapter.IsProtected(content)
+	return f
+}
+
+// stat returns the file at id, with its content if it is a note.
+func (a *Adapter) stat(ctx context.Context, id string) (*adapter.File, error) {
+	id, err := clean(id)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return &adapter.File{FileMetadata: folderMetadata("")}, nil
+	}
+	if !strings.HasSuffix(id, mdExt) {
+		ok, err := a.folderExists(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, adapter.ErrNotFound
+		}
+		return &adapter.File{FileMetadata: folderMetadata(id)}, nil
+	}
+
+	out, err := a.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(a.bucket), Key: aws.String(a.base + id)})
+	if isNotFound(err) {
+		return nil, adapter.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	content, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, err
+	}
+	return noteFile(id, aws.ToTime(out.LastModified), content), nil
+}
+
+// folderExists reports whether any object, such as its marker, is under
+// folder id.
+func (a *Adapter) folderExists(ctx context.Context, id string) (bool, error) {
+	out, err := a.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(a.bucket),
+		Prefix:  aws.String(a.dirPrefix(id)),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(out.Contents) > 0, nil
+}
+
+// exists reports whether a note or folder is at id.
+func (a *Adapter) exists(ctx context.Context, id string) (bool, error) {
+	if !strings.HasSuffix(id, mdExt) {
+		return a.folderExists(ctx, id)
+	}
+	_, err := a.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(a.bucket), Key: aws.String(a.base + id)})
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// free returns an ID for name in dir that is not taken, adding a number to
+// name if needed. ext is appended to the name.
+func (a *Adapter) free(ctx context.Context, dir, name, ext string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	for n := 1; ; n++ {
+		candidate := name
+		if n > 1 {
+			candidate += " (" + strconv.Itoa(n) + ")"
+		}
+		id := path.Join(dir, candidate+ext)
+		taken, err := a.exists(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return id, nil
+		}
+	}
+}
+
+// write stores content as the note id.
+func (a *Adapter) write(ctx context.Context, id string, content []byte) (*adapter.FileMetadata, error) {
+	_, err := a.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(a.bucket),
+		Key:         aws.String(a.base + id),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String("text/markdown; charset=utf-8"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &noteFile(id, time.Now(), content).FileMetadata, nil
+}
+
+// list returns the items in folder id; all of them below it with
+// recursive set.
+func (a *Adapter) list(ctx context.Context, id string, recursive bool) ([]adapter.FileMetadata, error) {
+	prefix := a.dirPrefix(id)
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(a.bucket), Prefix: aws.String(prefix)}
+	if !recursive {
+		input.Delimiter = aws.String("/")
+	}
+	files := []adapter.FileMetadata{}
+	pages := s3.NewListObjectsV2Paginator(a.client, input)
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.CommonPrefixes {
+			files = append(files, folderMetadata(strings.TrimSuffix(strings.TrimPrefix(aws.ToString(p.Prefix), a.base), "/")))
+		}
+		for _, obj := range page.Contents {
+			key := strings.TrimPrefix(aws.ToString(obj.Key), a.base)
+			if !strings.HasSuffix(key, mdExt) {
+				continue // folder markers
+			}
+			files = append(files, metadata(key, aws.ToTime(obj.LastModified), aws.ToInt64(obj.Size), strings.Trim(aws.ToString(obj.ETag), `"`)))
+		}
+	}
+	return files, nil
+}
+
+func (a *Adapter) ListFiles(ctx context.Context, folderID string) ([]adapter.FileMetadata, error) {
+	dir, err := a.stat(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if dir.MIMEType != folderMIMEType {
+		return nil, adapter.ErrNotFolder
+	}
+	return a.list(ctx, dir.ID, false)
+}
+
+func (a *Adapter) GetFile(ctx context.Context, fileID string) (*adapter.File, error) {
+	return a.stat(ctx, fileID)
+}
+
+func (a *Adapter) SaveFile(ctx context.Context, fileID string, content []byte, etag string) (*adapter.FileMetadata, error) {
+	current, err := a.stat(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if current.MIMEType == folderMIMEType {
+		return nil, fmt.Errorf("%s is a folder", fileID)
+	}
+	if etag != "" && etag != current.ETag {
+		return nil, adapter.ErrPreconditionFailed
+	}
+	return a.write(ctx, current.ID, content)
+}
+
+func (a *Adapter) CreateFile(ctx context.Context, name string, content []byte, folderID string) (*adapter.FileMetadata, error) {
+	dir, err := a.stat(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := a.free(ctx, dir.ID, strings.TrimSuffix(name, mdExt), mdExt)
+	if err != nil {
+		return nil, err
+	}
+	return a.write(ctx, id, content)
+}
+
+func (a *Adapter) CreateFolder(ctx context.Context, name string, parents []string) (*adapter.FileMetadata, error) {
+	var parentID string
+	if len(parents) > 0 {
+		parentID = parents[0]
+	}
+	dir, err := a.stat(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := a.free(ctx, dir.ID, name, "")
+	if err != nil {
+		return nil, err
+	}
+	_, err = a.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(a.dirPrefix(id)),
+		Body:   bytes.NewReader(nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	meta := folderMetadata(id)
+	return &meta, nil
+}
+
+func (a *Adapter) ListRootFolders(ctx context.Context) ([]adapter.FileMetadata, error) {
+	files, err := a.list(ctx, "", false)
+	if err != nil {
+		return nil, err
+	}
+	folders := files[:0]
+	for _, f := range files {
+		if f.MIMEType == folderMIMEType {
+			folders = append(folders, f)
+		}
+	}
+	return folders, nil
+}
+
+func (a *Adapter) EnsureRootFolder(ctx context.Context, name string) (string, error) {
+	if f, err := a.stat(ctx, name); err == nil && f.MIMEType == folderMIMEType {
+		return f.ID, nil
+	}
+	f, err := a.CreateFolder(ctx, name, nil)
+	if err != nil {
+		return "", err
+	}
+	return f.ID, nil
+}
+
+// DeleteFile deletes a note, or a folder with everything in it.
+func (a *Adapter) DeleteFile(ctx context.Context, fileID string) error {
+	f, err := a.stat(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	if f.ID == "" {
+		return errors.New("cannot delete the base folder")
+	}
+	keys := []string{a.base + f.ID}
+	if f.MIMEType == folderMIMEType {
+		items, err := a.list(ctx, f.ID, true)
+		if err != nil {
+			return err
+		}
+		keys = []string{a.dirPrefix(f.ID)}
+		for _, item := range items {
+			keys = append(keys, a.base+item.ID)
+		}
+	}
+	for _, key := range keys {
+		if _, err := a.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(a.bucket), Key: aws.String(key)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Adapter) DuplicateFile(ctx context.Context, fileID string) (*adapter.FileMetadata, error) {
+	f, err := a.stat(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if f.MIMEType == folderMIMEType {
+		return nil, fmt.Errorf("%w: folders cannot be duplicated", errors.ErrUnsupported)
+	}
+	return a.CreateFile(ctx, "Copy of "+f.Name, f.Content, f.Parents[0])
+}
+
+// RenameFile renames a note in its folder. S3 cannot rename an object, so
+// the note is written under its new key and the old one deleted; its ID
+// changes with its key.
+func (a *Adapter) RenameFile(ctx context.Context, fileID string, newName string) (*adapter.FileMetadata, error) {
+	f, err := a.stat(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if f.MIMEType == folderMIMEType {
+		return nil, unsupported("renaming folders")
+	}
+	newName = strings.TrimSuffix(newName, mdExt)
+	if newName == f.Name {
+		return &f.FileMetadata, nil
+	}
+	id, err := a.free(ctx, f.Parents[0], newName, mdExt)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := a.write(ctx, id, f.Content)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(a.bucket), Key: aws.String(a.base + f.ID)}); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+func unsupported(what string) error {
+	return fmt.Errorf("%w: S3 storage does not support %s", errors.ErrUnsupported, what)
+}
+
+func (a *Adapter) SetStarred(ctx context.Context, fileID string, starred bool) (*adapter.FileMetadata, error) {
+	return nil, unsupported("starring")
+}
+
+func (a *Adapter) SetOrderIndex(ctx context.Context, fileID string, index int) (*adapter.FileMetadata, error) {
+	return nil, unsupported("starring")
+}
+
+func (a *Adapter) SetAppearance(ctx context.Context, fileID string, color, icon *string) (*adapter.FileMetadata, error) {
+	return nil, unsupported("folder colors and icons")
+}
+
+func (a *Adapter) SetArchived(ctx context.Context, fileID string, archived bool) (*adapter.FileMetadata, error) {
+	return nil, unsupported("archiving")
+}
+
+func (a *Adapter) ListArchived(ctx context.Context) ([]adapter.FileMetadata, error) {
+	return []adapter.FileMetadata{}, nil
+}
+
+func (a *Adapter) SetProperties(ctx context.Context, fileID string, props map[string]string) (*adapter.FileMetadata, error) {
+	return nil, unsupported("properties")
+}
+
+func (a *Adapter) ListStarred(ctx context.Context) ([]adapter.FileMetadata, error) {
+	return []adapter.FileMetadata{}, nil
+}
+
+// SearchFiles returns the notes whose name contains query, ignoring case.
+// Content is not searched, since that would download every note.
+func (a *Adapter) SearchFiles(ctx context.Context, query string) ([]adapter.FileMetadata, error) {
+	items, err := a.list(ctx, "", true)
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(query)
+	files := []adapter.FileMetadata{}
+	for _, f := range items {
+		if strings.Contains(strings.ToLower(f.Name), query) {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,164 @@
+package s3store
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// fakeBucket stands in for S3 with one bucket's objects by key.
+type fakeBucket map[string][]byte
+
+func (b fakeBucket) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	content, ok := b[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(string(content))), LastModified: aws.Time(time.Now())}, nil
+}
+
+func (b fakeBucket) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	content, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	b[aws.ToString(in.Key)] = content
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (b fakeBucket) HeadObject(_ context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if _, ok := b[aws.ToString(in.Key)]; !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func (b fakeBucket) DeleteObject(_ context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(b, aws.ToString(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (b fakeBucket) ListObjectsV2(_ context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	prefix, delimiter := aws.ToString(in.Prefix), aws.ToString(in.Delimiter)
+	out := &s3.ListObjectsV2Output{}
+	seen := map[string]bool{}
+	for _, key := range slices.Sorted(maps.Keys(b)) {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			if p := prefix + rest[:i+1]; !seen[p] {
+				seen[p] = true
+				out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(p)})
+			}
+			continue
+		}
+		sum := md5.Sum(b[key])
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(int64(len(b[key]))),
+			ETag:         aws.String(`"` + hex.EncodeToString(sum[:]) + `"`),
+			LastModified: aws.Time(time.Now()),
+		})
+		if in.MaxKeys != nil && len(out.Contents) >= int(*in.MaxKeys) {
+			break
+		}
+	}
+	return out, nil
+}
+
+func TestAdapter(t *testing.T) {
+	ctx := context.Background()
+	bucket := fakeBucket{}
+	storage, err := NewProvider(bucket, "notes-bucket", "backup").GetAdapter(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	folderID, err := storage.EnsureRootFolder(ctx, "Work")
+	if err != nil || folderID != "Work" {
+		t.Fatalf("EnsureRootFolder = %q, %v", folderID, err)
+	}
+	if again, _ := storage.EnsureRootFolder(ctx, "Work"); again != folderID {
+		t.Errorf("second EnsureRootFolder = %q", again)
+	}
+	note, err := storage.CreateFile(ctx, "Plan", []byte("v1"), folderID)
+	if err != nil || note.ID != "Work/Plan.md" || note.Name != "Plan" {
+		t.Fatalf("CreateFile = %+v, %v", note, err)
+	}
+	// A taken name gets a number.
+	twin, _ := storage.CreateFile(ctx, "Plan.md", []byte("other"), folderID)
+	if twin.ID != "Work/Plan (2).md" {
+		t.Errorf("second CreateFile ID = %q", twin.ID)
+	}
+	if content := bucket["backup/u1/Work/Plan.md"]; string(content) != "v1" {
+		t.Errorf("object content = %q", content)
+	}
+
+	if _, err := storage.SaveFile(ctx, note.ID, []byte("v2"), "stale"); !errors.Is(err, adapter.ErrPreconditionFailed) {
+		t.Errorf("SaveFile with a stale ETag error = %v", err)
+	}
+	saved, err := storage.SaveFile(ctx, note.ID, []byte("v2"), note.ETag)
+	if err != nil || saved.ETag == note.ETag {
+		t.Fatalf("SaveFile = %+v, %v", saved, err)
+	}
+
+	renamed, err := storage.RenameFile(ctx, note.ID, "Roadmap")
+	if err != nil || renamed.ID != "Work/Roadmap.md" {
+		t.Fatalf("RenameFile = %+v, %v", renamed, err)
+	}
+	if f, err := storage.GetFile(ctx, renamed.ID); err != nil || string(f.Content) != "v2" || f.ETag != saved.ETag {
+		t.Errorf("GetFile after rename = %+v, %v", f, err)
+	}
+	if _, err := storage.GetFile(ctx, note.ID); !errors.Is(err, adapter.ErrNotFound) {
+		t.Errorf("GetFile of the old name error = %v", err)
+	}
+	if found, _ := storage.SearchFiles(ctx, "ROAD"); len(found) != 1 || found[0].ID != renamed.ID {
+		t.Errorf("SearchFiles = %+v", found)
+	}
+	if folders, _ := storage.ListRootFolders(ctx); len(folders) != 1 || folders[0].ID != folderID {
+		t.Errorf("ListRootFolders = %+v", folders)
+	}
+
+	if err := storage.DeleteFile(ctx, renamed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := storage.ListFiles(ctx, folderID); len(files) != 1 || files[0].ID != twin.ID || files[0].ETag != twin.ETag {
+		t.Errorf("ListFiles after delete = %+v", files)
+	}
+	if _, err := storage.GetFile(ctx, renamed.ID); !errors.Is(err, adapter.ErrNotFound) {
+		t.Errorf("GetFile after delete error = %v", err)
+	}
+	if _, err := storage.ListFiles(ctx, "Missing"); !errors.Is(err, adapter.ErrNotFound) {
+		t.Errorf("ListFiles of a missing folder error = %v", err)
+	}
+}
+
+func TestAdapter_StaysInsidePrefix(t *testing.T) {
+	ctx := context.Background()
+	p := NewProvider(fakeBucket{"u2/Secret.md": []byte("secret")}, "notes-bucket", "")
+	if _, err := p.GetAdapter(ctx, ".."); err == nil {
+		t.Error("GetAdapter(..) succeeded")
+	}
+	storage, _ := p.GetAdapter(ctx, "u1")
+	if _, err := storage.GetFile(ctx, "../u2/Secret.md"); !errors.Is(err, adapter.ErrNotFound) {
+		t.Errorf("GetFile outside the prefix error = %v", err)
+	}
+	if _, err := storage.CreateFile(ctx, "../Escape", nil, ""); err == nil {
+		t.Error("CreateFile with a path as its name succeeded")
+	}
+}
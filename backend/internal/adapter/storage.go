@@ -1,10 +1,36 @@
 package adapter
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"maps"
 	"time"
 )
 
+// EncryptedPrefix starts the content of notes that the client encrypted end
+// to end (see SealNote in the core crypto package). The server cannot read
+// such notes; it only stores them.
+const EncryptedPrefix = "gophdrive-e2e:"
+
+// IsEncrypted reports whether content is an end-to-end encrypted note.
+func IsEncrypted(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(EncryptedPrefix))
+}
+
+// ProtectedPrefix starts the content of encrypted notes the user locked with
+// a passphrase of their own (see Keyring.Protect in the core crypto
+// package), rather than the key their other encrypted notes share.
+const ProtectedPrefix = EncryptedPrefix + "protected:"
+
+// IsProtected reports whether content is a passphrase-protected note.
+// Protected notes are also encrypted.
+func IsProtected(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(ProtectedPrefix))
+}
+
 // FileMetadata represents metadata about a file stored in the cloud storage.
 type FileMetadata struct {
 	ID           string    `json:"id"`
@@ -15,6 +41,66 @@ type FileMetadata struct {
 	ETag         string    `json:"etag"`
 	Parents      []string  `json:"parents,omitempty"`
 	Starred      bool      `json:"starred"`
+	// Encrypted is set for notes whose content is encrypted end to end.
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Protected is set for encrypted notes locked with a passphrase of
+	// their own, which the client asks for before opening them.
+	Protected bool `json:"protected,omitempty"`
+	// OrderIndex is the item's position in the user's starred list,
+	// counting from 1. Zero means the user has not placed it.
+	OrderIndex int `json:"orderIndex,omitempty"`
+	// Color and Icon are set on folders the user has styled. Color is an
+	// RGB hex string such as "#4986e7"; Icon is a short emoji or icon name
+	// for the frontend to show.
+	Color string `json:"color,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+	// Properties holds the user's own key-value fields, such as a status
+	// or priority.
+	Properties map[string]string `json:"properties,omitempty"`
+	// Archived notes are kept out of listings and search but stay where
+	// they are, unlike trashed ones.
+	Archived bool `json:"archived,omitempty"`
+	// ContentHash identifies a note's content (see ContentHash). Notes with
+	// the same content have the same hash. It is empty for folders.
+	ContentHash string `json:"contentHash,omitempty"`
+	// CommentCount is the number of review comments on the note. Adapters
+	// leave it unset; the API fills it in from the comment store.
+	CommentCount int `json:"commentCount,omitempty"`
+}
+
+// ContentHash returns the hash reported as FileMetadata.ContentHash: the
+// hex MD5 of content, as Drive computes its md5Checksum.
+func ContentHash(content []byte) string {
+	sum := md5.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// MaxProperties is the most custom properties a file can have. Drive
+// allows an app 30 appProperties per file, some of which GophDrive uses
+// itself.
+const MaxProperties = 20
+
+// MergeProperties returns current with updates applied, where an empty
+// value removes a key. It returns nil if no properties are left.
+func MergeProperties(current, updates map[string]string) (map[string]string, error) {
+	merged := maps.Clone(current)
+	if merged == nil {
+		merged = make(map[string]string, len(updates))
+	}
+	for k, v := range updates {
+		if v == "" {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	if len(merged) > MaxProperties {
+		return nil, fmt.Errorf("%w: a note can have at most %d properties", ErrLimitExceeded, MaxProperties)
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
 }
 
 // File represents a file with its content.
@@ -62,6 +148,27 @@ type StorageAdapter interface {
 	// SetStarred sets the starred status of a file.
 	SetStarred(ctx context.Context, fileID string, starred bool) (*FileMetadata, error)
 
+	// SetOrderIndex sets a file's position in the starred list; 0 clears
+	// it. It does not change the file's content or ETag.
+	SetOrderIndex(ctx context.Context, fileID string, index int) (*FileMetadata, error)
+
+	// SetAppearance sets a folder's color and icon. A nil value is left
+	// as it is and an empty one is cleared. It returns ErrNotFolder for
+	// notes.
+	SetAppearance(ctx context.Context, fileID string, color, icon *string) (*FileMetadata, error)
+
+	// SetArchived archives or restores a file. It does not change the
+	// file's content or ETag.
+	SetArchived(ctx context.Context, fileID string, archived bool) (*FileMetadata, error)
+
+	// ListArchived lists all archived files/folders.
+	ListArchived(ctx context.Context) ([]FileMetadata, error)
+
+	// SetProperties merges props into a file's custom properties. An
+	// empty value removes that key. It returns ErrLimitExceeded if the file
+	// would end up with more than MaxProperties.
+	SetProperties(ctx context.Context, fileID string, props map[string]string) (*FileMetadata, error)
+
 	// ListStarred lists all starred files/folders.
 	ListStarred(ctx context.Context) ([]FileMetadata, error)
 
@@ -0,0 +1,174 @@
+// Package apiclient calls the GophDrive API with a personal access token.
+// The command-line client and the MCP server use it, decoding responses
+// into the backend's own types.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+// ErrETagMismatch is returned when a note was changed since its ETag was
+// read.
+var ErrETagMismatch = errors.New("the note was changed on the server")
+
+// Error is an error response from the API.
+type Error struct {
+	Status int
+	handler.ErrorResponse
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Client calls the GophDrive API with a personal access token.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// New creates a Client for the API at baseURL, such as
+// https://notes.example.com/api. If httpClient is nil,
+// http.DefaultClient is used.
+func New(baseURL, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: baseURL, token: token, http: httpClient}
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response
+// into out, if it is not nil. Error responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body, out any) error {
+	u := strings.TrimSuffix(c.baseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		apiErr := &Error{Status: resp.StatusCode}
+		if json.Unmarshal(data, &apiErr.ErrorResponse) != nil || apiErr.Message == "" {
+			apiErr.Code = "error"
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		if apiErr.Code == handler.CodeETagMismatch {
+			return fmt.Errorf("%w: %v", ErrETagMismatch, apiErr)
+		}
+		return apiErr
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// ListNotes returns the notes and folders in folderID, or in the base
+// folder if it is empty.
+func (c *Client) ListNotes(ctx context.Context, folderID string) ([]adapter.FileMetadata, error) {
+	var query url.Values
+	if folderID != "" {
+		query = url.Values{"folderId": {folderID}}
+	}
+	var files []adapter.FileMetadata
+	err := c.do(ctx, http.MethodGet, "/notes", query, nil, nil, &files)
+	return files, err
+}
+
+// Search returns the notes whose name or content matches q.
+func (c *Client) Search(ctx context.Context, q string) ([]adapter.FileMetadata, error) {
+	var files []adapter.FileMetadata
+	err := c.do(ctx, http.MethodGet, "/search", url.Values{"q": {q}}, nil, nil, &files)
+	return files, err
+}
+
+// GetNote returns note id with its content.
+func (c *Client) GetNote(ctx context.Context, id string) (*handler.NoteResponse, error) {
+	var note handler.NoteResponse
+	if err := c.do(ctx, http.MethodGet, "/notes/"+url.PathEscape(id), nil, nil, nil, &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// SaveNote replaces the content of note id. With an etag, it fails with
+// ErrETagMismatch if the note has changed since; without one, it
+// overwrites whatever is there.
+func (c *Client) SaveNote(ctx context.Context, id, content, etag string) (*adapter.FileMetadata, error) {
+	var header http.Header
+	if etag != "" {
+		header = http.Header{"If-Match": {etag}}
+	}
+	var file adapter.FileMetadata
+	body := map[string]any{"content": content}
+	if err := c.do(ctx, http.MethodPut, "/notes/"+url.PathEscape(id), nil, header, body, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// CreateNote creates a note named name in parentID, or in the base folder
+// if it is empty. The name should end in ".md".
+func (c *Client) CreateNote(ctx context.Context, name, content, parentID string) (*adapter.FileMetadata, error) {
+	var file adapter.FileMetadata
+	body := map[string]any{"name": name, "content": content, "parentId": parentID}
+	if err := c.do(ctx, http.MethodPost, "/notes", nil, nil, body, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Manifest lists every note with its ETag.
+func (c *Client) Manifest(ctx context.Context) ([]handler.ManifestEntry, error) {
+	var entries []handler.ManifestEntry
+	err := c.do(ctx, http.MethodGet, "/sync/manifest", nil, nil, nil, &entries)
+	return entries, err
+}
@@ -0,0 +1,46 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gdp_test" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == http.MethodPut && r.Header.Get("If-Match") == "old":
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`{"code":"etag_mismatch","message":"changed","requestId":"r1"}`))
+		case r.URL.Path == "/api/notes/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"Note not found"}`))
+		default:
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL+"/api/", "gdp_test", nil)
+	ctx := context.Background()
+
+	if _, err := c.SaveNote(ctx, "a", "x", "old"); !errors.Is(err, ErrETagMismatch) {
+		t.Errorf("SaveNote with a stale ETag error = %v", err)
+	}
+
+	_, err := c.GetNote(ctx, "missing")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Code != "not_found" {
+		t.Errorf("GetNote error = %#v", err)
+	}
+
+	// Responses that are not the API's JSON errors keep their text.
+	_, err = c.ListNotes(ctx, "")
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway || apiErr.Message != "bad gateway" {
+		t.Errorf("ListNotes error = %#v", err)
+	}
+}
@@ -1,31 +1,53 @@
 package app
 
 import (
+	"cmp"
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
-	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 
+	"github.com/jun/gophdrive/backend/internal/accesstoken"
 	"github.com/jun/gophdrive/backend/internal/adapter"
 	"github.com/jun/gophdrive/backend/internal/adapter/googledrive"
+	"github.com/jun/gophdrive/backend/internal/adapter/localfs"
 	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/adapter/s3store"
 	"github.com/jun/gophdrive/backend/internal/auth"
+	"github.com/jun/gophdrive/backend/internal/collab"
+	"github.com/jun/gophdrive/backend/internal/comment"
+	"github.com/jun/gophdrive/backend/internal/config"
 	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/draft"
+	"github.com/jun/gophdrive/backend/internal/gitexport"
 	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/journal"
+	"github.com/jun/gophdrive/backend/internal/metrics"
+	"github.com/jun/gophdrive/backend/internal/mirror"
+	"github.com/jun/gophdrive/backend/internal/publish"
+	"github.com/jun/gophdrive/backend/internal/reminder"
 	"github.com/jun/gophdrive/backend/internal/secret"
 	"github.com/jun/gophdrive/backend/internal/session"
+	"github.com/jun/gophdrive/backend/internal/share"
+	"github.com/jun/gophdrive/backend/internal/summary"
+	"github.com/jun/gophdrive/backend/internal/tracing"
+	"github.com/jun/gophdrive/backend/internal/translate"
 )
 
+// tokenKeyPurpose separates the DEV_MODE token key derived from JWT_SECRET
+// from the secret itself.
+const tokenKeyPurpose = "gophdrive refresh token encryption"
+
 // HybridProvider delegates to either Google Drive or Memory provider based on user ID.
 type HybridProvider struct {
 	googleProvider adapter.StorageProvider
@@ -41,104 +63,72 @@ func (h *HybridProvider) GetAdapter(ctx context.Context, userID string) (adapter
 
 // App holds the dependencies for the Lambda function.
 type App struct {
-	authHandler      *handler.AuthHandler
-	noteHandler      *handler.NoteHandler
-	sessionHandler   *handler.SessionHandler
-	syncHandler      *handler.SyncHandler
-	searchHandler    *handler.SearchHandler
-	apiGatewaySecret string
+	authHandler        *handler.AuthHandler
+	noteHandler        *handler.NoteHandler
+	sessionHandler     *handler.SessionHandler
+	syncHandler        *handler.SyncHandler
+	searchHandler      *handler.SearchHandler
+	collabHandler      *handler.CollabHandler // nil on Lambda
+	commentHandler     *handler.CommentHandler
+	draftHandler       *handler.DraftHandler
+	publishHandler     *handler.PublishHandler
+	markdownHandler    *handler.MarkdownHandler
+	publications       *publish.Store
+	reminderHandler    *handler.ReminderHandler
+	reminders          *reminder.Store
+	reminderNotifier   reminder.Notifier         // nil when no channel is configured
+	summaryHandler     *handler.SummaryHandler   // nil unless a model is configured
+	translateHandler   *handler.TranslateHandler // nil unless a model is configured
+	accessTokenHandler *handler.AccessTokenHandler
+	gitExportHandler   *handler.GitExportHandler
+	mirrorHandler      *handler.MirrorHandler
+	mirror             *mirror.Provider // nil unless MIRROR_DIR or MIRROR_S3_BUCKET is set
+	shareHandler       *handler.ShareHandler
+	presenceHandler    *handler.PresenceStreamHandler
+	webdavHandler      *handler.WebDAVHandler
+	webdav             http.Handler // webdavHandler behind httpMiddleware
+	limiter            *rateLimiter // nil unless RATE_LIMIT_PER_MINUTE is set
+	apiGatewaySecret   *secret.Value
+	readiness          []readinessCheck
+	metrics            *metrics.Recorder
+	router             *router
+	serve              HandlerFunc
 }
 
-// NewApp initializes the application dependencies.
-func NewApp(ctx context.Context) *App {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		panic(fmt.Sprintf("unable to load SDK config, %v", err))
-	}
+// NewApp initializes the application dependencies from cfg. Settings are
+// loaded and validated beforehand, e.g. with config.FromEnvironment.
+func NewApp(ctx context.Context, cfg *config.Config) *App {
+	// AWS clients record a span per call.
+	awsCfg := cfg.AWS.Copy()
+	tracing.InstrumentAWS(&awsCfg)
 
 	// DynamoDB Client
-	dynamoClient := dynamodb.NewFromConfig(cfg)
-	if os.Getenv("DEV_MODE") == "true" {
-		fmt.Println("Using In-Memory/DynamoDB Hybrid Storage (DEV_MODE=true)")
+	dynamoClient := dynamodb.NewFromConfig(awsCfg)
+	if cfg.DevMode {
+		slog.Info("Using In-Memory/DynamoDB Hybrid Storage (DEV_MODE=true)")
 	}
 
-	// KMS Client
+	// Token encryption: KMS, or AES-GCM with a local key in DEV_MODE.
 	var kmsService crypto.Encryptor
-	if os.Getenv("DEV_MODE") == "true" {
-		kmsService = crypto.NewMockEncryptor()
-		fmt.Println("Using MockEncryptor (DEV_MODE=true)")
-	} else {
-		kmsClient := kms.NewFromConfig(cfg)
-		kmsKeyID := os.Getenv("KMS_KEY_ID")
-		if kmsKeyID == "" {
-			kmsKeyID = "alias/gophdrive-token-key"
-		}
-		kmsService = crypto.NewKMSService(kmsClient, kmsKeyID)
-	}
-
-	// Auth Service (UserTokens Table)
-	userTokensTable := os.Getenv("USER_TOKENS_TABLE")
-	if userTokensTable == "" {
-		userTokensTable = "UserTokens"
-	}
-
-	// ---------- Secret Resolver ----------
-	var resolver secret.Resolver
-	if os.Getenv("DEV_MODE") == "true" {
-		resolver = secret.NewEnvResolver()
-		fmt.Println("Using EnvResolver (DEV_MODE=true)")
-	} else {
-		resolver = secret.NewSSMResolver(ssm.NewFromConfig(cfg))
-		fmt.Println("Using SSMResolver (SSM Parameter Store)")
-	}
-
-	// Resolve secrets from SSM Parameter Store (or env vars in DEV_MODE)
-	googleClientSecretParam := os.Getenv("GOOGLE_CLIENT_SECRET_PARAM")
-	if googleClientSecretParam == "" {
-		googleClientSecretParam = "/gophdrive/google-client-secret"
-	}
-	googleClientSecret, err := resolver.GetSecret(ctx, googleClientSecretParam)
-	if err != nil {
-		log.Printf("WARNING: failed to resolve GOOGLE_CLIENT_SECRET: %v", err)
-	}
-
-	jwtSecretParam := os.Getenv("JWT_SECRET_PARAM")
-	if jwtSecretParam == "" {
-		jwtSecretParam = "/gophdrive/jwt-secret"
-	}
-	jwtSecret, err := resolver.GetSecret(ctx, jwtSecretParam)
-	if err != nil {
-		log.Printf("WARNING: failed to resolve JWT_SECRET: %v", err)
-		jwtSecret = "default-dev-secret"
-	}
-
-	apiGatewaySecretParam := os.Getenv("API_GATEWAY_SECRET_PARAM")
-	if apiGatewaySecretParam == "" {
-		apiGatewaySecretParam = "/gophdrive/api-gateway-secret"
-	}
-	apiGatewaySecret, err := resolver.GetSecret(ctx, apiGatewaySecretParam)
-	if err != nil {
-		log.Printf("WARNING: failed to resolve API_GATEWAY_SECRET: %v", err)
-	}
-
-	// OAuth2 Config
-	redirectURL := os.Getenv("GOOGLE_REDIRECT_URL")
-	if redirectURL == "" {
-		if os.Getenv("DEV_MODE") == "true" {
-			redirectURL = "http://localhost:8080/auth/callback"
+	if cfg.DevMode {
+		if cfg.TokenEncryptionKey != nil {
+			kmsService = crypto.NewAESEncryptor(*cfg.TokenEncryptionKey)
+			slog.Info("Using AESEncryptor with TOKEN_ENCRYPTION_KEY (DEV_MODE=true)")
 		} else {
-			frontendURL := os.Getenv("FRONTEND_URL")
-			if frontendURL == "" {
-				frontendURL = "http://localhost:3000"
-			}
-			redirectURL = frontendURL + "/api/auth/callback"
+			kmsService = crypto.NewAESEncryptor(crypto.DeriveKey(cfg.JWTSecret, tokenKeyPurpose))
+			slog.Info("Using AESEncryptor with a key derived from JWT_SECRET (DEV_MODE=true)")
 		}
+	} else {
+		// Only sign-in, Drive token refreshes and Git exports use KMS.
+		kmsService = crypto.Lazy(func() crypto.Encryptor {
+			return crypto.NewKMSService(kms.NewFromConfig(awsCfg), cfg.KMSKeyID, cfg.KMSPreviousKeyIDs...)
+		})
 	}
 
+	// OAuth2 Config. The client secret is resolved when first needed.
 	oauthConfig := &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: googleClientSecret,
-		RedirectURL:  redirectURL,
+		ClientID:    cfg.GoogleClientID,
+		RedirectURL: cfg.GoogleRedirectURL,
 		Scopes: []string{
 			"https://www.googleapis.com/auth/drive",
 			"https://www.googleapis.com/auth/userinfo.email",
@@ -146,13 +136,17 @@ func NewApp(ctx context.Context) *App {
 		Endpoint: google.Endpoint,
 	}
 
-	authService := auth.NewAuthService(oauthConfig, dynamoClient, userTokensTable, kmsService)
+	// Auth Service (UserTokens Table)
+	authService := auth.NewAuthService(oauthConfig, dynamoClient, cfg.Tables.UserTokens, kmsService)
+	if cfg.GoogleClientSecret != nil {
+		authService.SetClientSecret(cfg.GoogleClientSecret)
+	}
 	// Storage Provider
 	var storageProvider adapter.StorageProvider
-	if os.Getenv("DEV_MODE") == "true" {
+	if cfg.DevMode {
 		// Use DynamoDB-backed "Memory" provider for persistence in LocalStack
 		storageProvider = memory.NewProvider(dynamoClient, authService)
-		fmt.Println("Using MemoryProvider (DEV_MODE=true) with DynamoDB persistence")
+		slog.Info("Using MemoryProvider (DEV_MODE=true) with DynamoDB persistence")
 	} else {
 		// Production: Hybrid Provider (Google Drive + Demo Memory)
 		storageProvider = &HybridProvider{
@@ -161,209 +155,445 @@ func NewApp(ctx context.Context) *App {
 		}
 	}
 
+	// Trace storage calls; journal writes are traced as DynamoDB calls.
+	storageProvider = tracing.NewStorageProvider(storageProvider)
+
+	// Change Journal (ChangeJournal Table)
+	changeJournal := journal.NewStore(dynamoClient, cfg.Tables.ChangeJournal)
+	storageProvider = journal.NewProvider(storageProvider, changeJournal)
+
+	// Share Store (Shares Table), indexing the items created in shared
+	// folders
+	shares := share.NewStore(dynamoClient, cfg.Tables.Shares)
+	storageProvider = share.NewProvider(storageProvider, shares)
+
+	// Mirror (Mirrors Table), only with a configured directory or bucket
+	var mirrorProvider *mirror.Provider
+	var mirrors *mirror.Store
+	var s3Client *s3.Client
+	if cfg.MirrorDir != "" || cfg.MirrorBucket != "" {
+		var target adapter.StorageProvider
+		if cfg.MirrorBucket != "" {
+			// LocalStack serves buckets by path rather than by host name.
+			s3Client = s3.NewFromConfig(awsCfg, func(o *s3.Options) { o.UsePathStyle = cfg.DevMode })
+			target = s3store.NewProvider(s3Client, cfg.MirrorBucket, cfg.MirrorPrefix)
+			slog.Info("Mirroring notes", "bucket", cfg.MirrorBucket, "prefix", cfg.MirrorPrefix)
+		} else {
+			target = localfs.NewProvider(cfg.MirrorDir)
+			slog.Info("Mirroring notes", "dir", cfg.MirrorDir)
+		}
+		mirrors = mirror.NewStore(dynamoClient, cfg.Tables.Mirrors)
+		mirrorProvider = mirror.NewProvider(storageProvider, target, mirrors)
+		storageProvider = mirrorProvider
+	}
+
+	jwtSecret := cfg.JWTSecret
+
 	// Auth Handler (needs Auth Service and Storage Provider)
 	authHandler := handler.NewAuthHandler(authService, storageProvider, jwtSecret)
+	signingKeys := cfg.SigningKeys
+	if signingKeys == nil {
+		signingKeys = secret.Static(cfg.JWTSecret)
+	}
+	signer := crypto.NewSigner(signingKeys)
+	authHandler.SetSigner(signer)
+
+	// Session Manager (EditingSessions Table)
+	lockPolicy := session.DefaultPolicy()
+	if cfg.LockTTL > 0 {
+		lockPolicy.TTL = cfg.LockTTL
+	}
+	if cfg.LockMaxDuration > 0 {
+		lockPolicy.MaxDuration = cfg.LockMaxDuration
+	}
+	slog.Info("Lock policy", "ttl", lockPolicy.TTL, "max_duration", lockPolicy.MaxDuration)
+	lockManager := session.NewLockManager(dynamoClient, cfg.Tables.EditingSessions, lockPolicy)
+
+	// Comment Store (Comments Table)
+	comments := comment.NewStore(dynamoClient, cfg.Tables.Comments)
+
+	// Draft Store (Drafts Table)
+	drafts := draft.NewStore(dynamoClient, cfg.Tables.Drafts)
+
+	// Publish Store (Publications Table)
+	publications := publish.NewStore(dynamoClient, cfg.Tables.Publications)
+
+	// Reminder Store (Reminders Table)
+	reminders := reminder.NewStore(dynamoClient, cfg.Tables.Reminders)
+
+	// Access Token Store (AccessTokens Table)
+	accessTokens := accesstoken.NewStore(dynamoClient, cfg.Tables.AccessTokens)
+
+	// Git Remote Store (GitRemotes Table)
+	gitRemotes := gitexport.NewStore(dynamoClient, cfg.Tables.GitRemotes, kmsService)
 
 	// Note Handler
 	noteHandler := handler.NewNoteHandler(storageProvider, jwtSecret)
+	noteHandler.EnableComments(comments)
+	noteHandler.EnableDrafts(drafts)
+	noteHandler.EnablePublishing(publications)
+	noteHandler.EnableReminders(reminders)
+	noteHandler.EnableSharing(shares)
+	advisoryLocks := cfg.LockMode == config.LockModeAdvisory
+	if cfg.EnforceEditLocks {
+		if advisoryLocks {
+			slog.Warn("ENFORCE_EDIT_LOCKS is ignored when LOCK_MODE=advisory")
+		} else {
+			noteHandler.EnableLockEnforcement(lockManager)
+			slog.Info("Edit lock enforcement enabled (ENFORCE_EDIT_LOCKS=true)")
+		}
+	}
 
 	// Search Handler
 	searchHandler := handler.NewSearchHandler(storageProvider, jwtSecret)
 
-	// Session Manager (EditingSessions Table)
-	sessionsTable := os.Getenv("EDITING_SESSIONS_TABLE")
-	if sessionsTable == "" {
-		sessionsTable = "EditingSessions"
+	// Session Handler
+	presence := session.NewBroadcaster()
+	sessionHandler := handler.NewSessionHandler(session.NewNotifyingLocker(lockManager, presence), jwtSecret)
+	presenceHandler := handler.NewPresenceStreamHandler(storageProvider, presence, jwtSecret)
+	if advisoryLocks {
+		sessionHandler.EnableAdvisoryLocks()
+		slog.Info("Advisory locking enabled (LOCK_MODE=advisory)")
 	}
-	lockManager := session.NewLockManager(dynamoClient, sessionsTable)
-	sessionHandler := handler.NewSessionHandler(lockManager, jwtSecret)
 
 	// Sync Handler
-	syncHandler := handler.NewSyncHandler(jwtSecret)
-
-	return &App{
-		authHandler:      authHandler,
-		noteHandler:      noteHandler,
-		sessionHandler:   sessionHandler,
-		syncHandler:      syncHandler,
-		searchHandler:    searchHandler,
-		apiGatewaySecret: apiGatewaySecret,
+	syncHandler := handler.NewSyncHandler(storageProvider, changeJournal, jwtSecret)
+
+	// Collab Handler
+	// The relay keeps ops in process memory, so it cannot relay between
+	// Lambda instances and the routes are not served there.
+	var collabHandler *handler.CollabHandler
+	if !cfg.OnLambda {
+		collabHandler = handler.NewCollabHandler(storageProvider, collab.NewMemoryRelay(), jwtSecret)
 	}
-}
 
-// HandleRequest routes API Gateway requests to the appropriate handler.
-func (app *App) HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	path := req.Path
-	method := req.HTTPMethod
+	// Comment Handler
+	commentHandler := handler.NewCommentHandler(storageProvider, comments, jwtSecret)
+
+	// Draft Handler
+	draftHandler := handler.NewDraftHandler(storageProvider, drafts, jwtSecret)
+
+	// Publish Handler
+	publishHandler := handler.NewPublishHandler(storageProvider, publications, signer, jwtSecret)
+
+	// Markdown Handler
+	markdownHandler := handler.NewMarkdownHandler(storageProvider, jwtSecret)
+
+	// Reminder Handler
+	reminderHandler := handler.NewReminderHandler(reminders, signer, jwtSecret)
 
-	fmt.Printf("Request: %s %s\n", method, path)
+	// Access Token Handler
+	accessTokenHandler := handler.NewAccessTokenHandler(accessTokens, signer, jwtSecret)
 
-	// CORS Preflight
-	if method == "OPTIONS" {
-		return corsResponse(events.APIGatewayProxyResponse{StatusCode: 204}), nil
+	// Git Export Handler
+	gitExportHandler := handler.NewGitExportHandler(storageProvider, gitRemotes, gitexport.NewClient(nil), jwtSecret)
+
+	// Mirror Handler
+	mirrorHandler := handler.NewMirrorHandler(mirrors, jwtSecret)
+
+	// Share Handler
+	shareHandler := handler.NewShareHandler(storageProvider, shares, jwtSecret)
+
+	// WebDAV Handler, served only by the net/http server (see Handler)
+	webdavHandler := handler.NewWebDAVHandler(noteHandler, accessTokenHandler, "/dav", cmp.Or(cfg.MaxContentBytes, defaultMaxContentBytes))
+
+	// Summary and Translate Handlers (Amazon Bedrock), only with a
+	// configured model
+	var summaryHandler *handler.SummaryHandler
+	var translateHandler *handler.TranslateHandler
+	if cfg.Summaries.ModelID != "" {
+		model := summary.NewBedrockProvider(bedrockruntime.NewFromConfig(awsCfg), cfg.Summaries.ModelID)
+		summaryHandler = handler.NewSummaryHandler(storageProvider, summary.NewAssistant(model), jwtSecret)
+		translateHandler = handler.NewTranslateHandler(storageProvider, translate.NewModelTranslator(model), jwtSecret)
+		slog.Info("Note summaries, suggestions and translation enabled", "model", cfg.Summaries.ModelID, "per_hour", cfg.Summaries.PerHour)
 	}
 
-	// Security: Verify Request Origin (CloudFront only)
-	// Skip check for OPTIONS (preflight) and if DEV_MODE is true
-	if os.Getenv("DEV_MODE") != "true" {
-		if req.Headers["X-Origin-Verify"] != app.apiGatewaySecret && req.Headers["x-origin-verify"] != app.apiGatewaySecret {
-			fmt.Printf("Security Block: Missing or invalid X-Origin-Verify header\n")
-			return events.APIGatewayProxyResponse{
-				StatusCode: http.StatusForbidden,
-				Body:       "Forbidden: Access denied",
-			}, nil
-		}
+	app := &App{
+		authHandler:        authHandler,
+		noteHandler:        noteHandler,
+		sessionHandler:     sessionHandler,
+		syncHandler:        syncHandler,
+		searchHandler:      searchHandler,
+		collabHandler:      collabHandler,
+		commentHandler:     commentHandler,
+		draftHandler:       draftHandler,
+		publishHandler:     publishHandler,
+		markdownHandler:    markdownHandler,
+		publications:       publications,
+		reminderHandler:    reminderHandler,
+		reminders:          reminders,
+		reminderNotifier:   reminderNotifier(cfg.Reminders),
+		summaryHandler:     summaryHandler,
+		translateHandler:   translateHandler,
+		accessTokenHandler: accessTokenHandler,
+		gitExportHandler:   gitExportHandler,
+		mirrorHandler:      mirrorHandler,
+		mirror:             mirrorProvider,
+		shareHandler:       shareHandler,
+		presenceHandler:    presenceHandler,
+		webdavHandler:      webdavHandler,
+		apiGatewaySecret:   cfg.APIGatewaySecret,
+	}
+	// CloudWatch metrics (EMF on stdout). Off in DEV_MODE to keep local
+	// output readable.
+	if !cfg.DevMode {
+		app.metrics = metrics.New(os.Stdout, metrics.Namespace)
 	}
 
-	// Router logic
-	// Strip /api prefix if present (for CloudFront proxying)
-	if strings.HasPrefix(path, "/api") {
-		path = strings.TrimPrefix(path, "/api")
+	// Readiness checks for GET /readyz. Missing startup secrets only get
+	// this far in DEV_MODE; elsewhere config validation rejects them. The
+	// Google client secret is resolved by its check if no request has
+	// needed it yet.
+	secrets := map[string]string{"JWT_SECRET": ""}
+	if cfg.JWTSecret != config.DevJWTSecret {
+		secrets["JWT_SECRET"] = cfg.JWTSecret
+	}
+	app.readiness = []readinessCheck{
+		tableCheck(dynamoClient, cfg.Tables.UserTokens),
+		tableCheck(dynamoClient, cfg.Tables.EditingSessions),
+		tableCheck(dynamoClient, cfg.Tables.ChangeJournal),
+		tableCheck(dynamoClient, cfg.Tables.Comments),
+		tableCheck(dynamoClient, cfg.Tables.Drafts),
+		tableCheck(dynamoClient, cfg.Tables.Publications),
+		tableCheck(dynamoClient, cfg.Tables.Reminders),
+		tableCheck(dynamoClient, cfg.Tables.AccessTokens),
+		tableCheck(dynamoClient, cfg.Tables.GitRemotes),
+		tableCheck(dynamoClient, cfg.Tables.Shares),
+		tableCheck(dynamoClient, memory.TableName()),
+		settingsCheck("secrets", secrets),
+		secretCheck("GOOGLE_CLIENT_SECRET", cfg.GoogleClientSecret),
+		settingsCheck("oauth", map[string]string{
+			"GOOGLE_CLIENT_ID":    oauthConfig.ClientID,
+			"GOOGLE_REDIRECT_URL": oauthConfig.RedirectURL,
+		}),
+	}
+	switch {
+	case cfg.MirrorBucket != "":
+		app.readiness = append(app.readiness,
+			tableCheck(dynamoClient, cfg.Tables.Mirrors),
+			bucketCheck(s3Client, cfg.MirrorBucket),
+		)
+	case cfg.MirrorDir != "":
+		app.readiness = append(app.readiness,
+			tableCheck(dynamoClient, cfg.Tables.Mirrors),
+			dirCheck("MIRROR_DIR", cfg.MirrorDir),
+		)
+	}
+	if !cfg.DevMode {
+		app.readiness = append(app.readiness,
+			secretCheck("API_GATEWAY_SECRET", cfg.APIGatewaySecret),
+			secretCheck("SIGNING_KEYS", cfg.SigningKeys),
+		)
 	}
 
-	// Helper to ensure PathParameters is initialized
-	if req.PathParameters == nil {
-		req.PathParameters = make(map[string]string)
+	if cfg.RateLimitPerMinute > 0 {
+		app.limiter = newRateLimiter(cfg.RateLimitPerMinute)
+		slog.Info("Rate limiting enabled", "requests_per_minute", cfg.RateLimitPerMinute)
 	}
+	app.router = app.routes(cfg)
+	app.serve = chain(app.router.serve, app.middleware(cfg)...)
+	app.webdav = serveHTTP("/dav", webdavHandler, app.httpMiddleware()...)
+	return app
+}
 
-	// /auth
-	if strings.HasPrefix(path, "/auth") {
-		if path == "/auth/login" && method == "GET" {
-			return corsResponse(must(app.authHandler.Login(ctx, req))), nil
-		}
-		if path == "/auth/callback" && method == "GET" {
-			return corsResponse(must(app.authHandler.Callback(ctx, req))), nil
-		}
-		if path == "/auth/demo-login" && method == "GET" {
-			return corsResponse(must(app.authHandler.DemoLogin(ctx, req))), nil
-		}
-		if path == "/auth/logout" && method == "POST" {
-			return corsResponse(must(app.authHandler.Logout(ctx, req))), nil
-		}
-		if path == "/auth/drive/folders" && method == "GET" {
-			return corsResponse(must(app.authHandler.ListDriveFolders(ctx, req))), nil
-		}
-		if path == "/auth/user" && method == "GET" {
-			return corsResponse(must(app.authHandler.GetUser(ctx, req))), nil
-		}
-		if path == "/auth/user" && method == "PATCH" {
-			return corsResponse(must(app.authHandler.UpdateUser(ctx, req))), nil
-		}
+// middleware returns the chain applied to every request, outermost first.
+// Tracing sits inside logging so request logs carry the trace ID.
+// CORS wraps everything so that rejections still reach the browser, the
+// deadline wraps panic recovery so a panic is never mistaken for a timeout,
+// health endpoints answer before origin verification, and authentication
+// runs before rate limiting so signed-in users are limited per user rather
+// than per IP.
+func (app *App) middleware(cfg *config.Config) []Middleware {
+	mws := []Middleware{logRequests, traceRequests, recordMetrics(app.metrics), withCORS(cfg.CORSAllowedOrigins...), withDeadline(cmp.Or(cfg.RequestTimeout, defaultRequestTimeout)), recoverPanics, handleErrors, healthEndpoints(app.readiness)}
+	// Security: Verify Request Origin (CloudFront only), except in DEV_MODE
+	if !cfg.DevMode {
+		mws = append(mws, verifyOrigin(app.apiGatewaySecret))
 	}
+	// Strip /api prefix if present (for CloudFront proxying)
+	mws = append(mws, stripPrefix("/api"), decodeBody, authenticate(cfg.JWTSecret, app.accessTokenHandler))
 
-	// /notes
-	if strings.HasPrefix(path, "/notes") {
-		if path == "/notes" && method == "GET" {
-			return corsResponse(must(app.noteHandler.ListNotes(ctx, req))), nil
-		}
-		if path == "/notes" && method == "POST" {
-			return corsResponse(must(app.noteHandler.CreateNote(ctx, req))), nil
-		}
-		// /notes/{id}
-		if len(path) > len("/notes/") {
-			// Robust ID extraction
-			pathParts := strings.Split(strings.Trim(path, "/"), "/")
-			id := pathParts[len(pathParts)-1]
-			req.PathParameters["id"] = id
-
-			if method == "GET" {
-				return corsResponse(must(app.noteHandler.GetNote(ctx, req))), nil
-			}
-			if method == "PUT" {
-				return corsResponse(must(app.noteHandler.UpdateNote(ctx, req))), nil
-			}
-			if method == "POST" && strings.HasSuffix(path, "/delete") {
-				// Handle POST /notes/{id}/delete
-				if len(pathParts) >= 2 && pathParts[len(pathParts)-1] == "delete" {
-					req.PathParameters["id"] = pathParts[len(pathParts)-2]
-				}
-				return corsResponse(must(app.noteHandler.DeleteNote(ctx, req))), nil
-			}
-			if method == "POST" && strings.HasSuffix(path, "/copy") {
-				// Handle POST /notes/{id}/copy
-				if len(pathParts) >= 2 && pathParts[len(pathParts)-1] == "copy" {
-					req.PathParameters["id"] = pathParts[len(pathParts)-2]
-				}
-				return corsResponse(must(app.noteHandler.DuplicateNote(ctx, req))), nil
-			}
-
-			if method == "PATCH" {
-				return corsResponse(must(app.noteHandler.PatchNote(ctx, req))), nil
-			}
-
-			if method == "DELETE" {
-				return corsResponse(must(app.noteHandler.DeleteNote(ctx, req))), nil
-			}
-		}
+	if app.limiter != nil {
+		mws = append(mws, rateLimit(app.limiter))
 	}
+	return mws
+}
 
-	// /starred
-	if path == "/starred" && method == "GET" {
-		return corsResponse(must(app.noteHandler.ListStarredNotes(ctx, req))), nil
+// httpMiddleware returns the chain applied to the net/http handlers served
+// outside the routes (see serveHTTP). They answer in their own formats and
+// authenticate callers themselves, so only request logs, tracing, metrics
+// and panic recovery apply, with the rate limit keyed by source IP ahead of
+// their token lookups.
+func (app *App) httpMiddleware() []Middleware {
+	mws := []Middleware{logRequests, traceRequests, recordMetrics(app.metrics), recoverPanics, handleErrors}
+	if app.limiter != nil {
+		mws = append(mws, rateLimit(app.limiter))
 	}
+	return mws
+}
+
+// PresenceStream returns the SSE handler for lock presence events.
+// Only long-running servers can use it; see handler.PresenceStreamHandler.
+func (a *App) PresenceStream() http.Handler {
+	return a.presenceHandler
+}
 
-	// /folders
-	if path == "/folders" && method == "POST" {
-		return corsResponse(must(app.noteHandler.CreateFolder(ctx, req))), nil
+// WebDAV returns the WebDAV handler, which serves notes under /dav behind
+// the request logs, metrics and rate limit. Like the presence stream, only
+// long-running servers can use it.
+func (a *App) WebDAV() http.Handler {
+	return a.webdav
+}
+
+// Wait waits for the mirror copies that requests left running in the
+// background, or until ctx is done. Lambda freezes the process once an
+// invocation returns and a stopping server drops them, so both call Wait
+// first; copies cut short are retried by the scheduled jobs.
+func (app *App) Wait(ctx context.Context) {
+	if app.mirror == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		app.mirror.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
 	}
+}
 
-	// /sessions
-	if strings.HasPrefix(path, "/sessions/") {
-		parts := strings.Split(strings.TrimPrefix(path, "/sessions/"), "/")
-		if len(parts) >= 2 {
-			req.PathParameters["fileId"] = parts[0]
-			action := parts[1]
-
-			if action == "lock" {
-				if method == "POST" {
-					return corsResponse(must(app.sessionHandler.AcquireLock(ctx, req))), nil
-				}
-				if method == "DELETE" {
-					return corsResponse(must(app.sessionHandler.ReleaseLock(ctx, req))), nil
-				}
-			}
-			if action == "heartbeat" && method == "POST" {
-				return corsResponse(must(app.sessionHandler.Heartbeat(ctx, req))), nil
-			}
+// HandleRequest routes API Gateway requests through the middleware chain to
+// the appropriate handler.
+func (app *App) HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return app.serve(ctx, req)
+}
+
+// Default request body limits. API Gateway itself rejects bodies over
+// 10 MB; stopping well short of that fails oversized requests before any
+// Drive or DynamoDB call.
+const (
+	defaultMaxBodyBytes    = 256 << 10
+	defaultMaxContentBytes = 5 << 20
+)
+
+// routes builds the API route table. Routes that upload note content or
+// sync data accept bodies up to MaxContentBytes; the rest MaxBodyBytes.
+func (app *App) routes(cfg *config.Config) *router {
+	r := newRouter()
+	r.maxBody = cmp.Or(cfg.MaxBodyBytes, defaultMaxBodyBytes)
+	maxContent := cmp.Or(cfg.MaxContentBytes, defaultMaxContentBytes)
+
+	// Routes other than sign-in, sign-out and public share pages are
+	// wrapped in requireUser.
+	// /auth
+	r.handle("GET", "/auth/login", app.authHandler.Login)
+	r.handle("GET", "/auth/callback", app.authHandler.Callback)
+	r.handle("GET", "/auth/demo-login", app.authHandler.DemoLogin)
+	r.handle("POST", "/auth/logout", app.authHandler.Logout)
+	r.handle("GET", "/auth/drive/folders", requireUser(app.authHandler.ListDriveFolders))
+	r.handle("GET", "/auth/user", requireUser(app.authHandler.GetUser))
+	r.handle("PATCH", "/auth/user", requireUser(app.authHandler.UpdateUser))
+	r.handle("GET", "/auth/tokens", requireUser(app.accessTokenHandler.ListAccessTokens))
+	r.handle("POST", "/auth/tokens", requireUser(app.accessTokenHandler.CreateAccessToken))
+	r.handle("DELETE", "/auth/tokens/{id}", requireUser(app.accessTokenHandler.DeleteAccessToken))
+	r.handle("POST", "/auth/tokens/{id}/delete", requireUser(app.accessTokenHandler.DeleteAccessToken))
+
+	// /notes
+	r.handle("GET", "/notes", requireUser(app.noteHandler.ListNotes))
+	r.handleWithLimit("POST", "/notes", maxContent, requireUser(app.noteHandler.CreateNote))
+	r.handle("GET", "/notes/by-path", requireUser(app.noteHandler.GetNoteByPath))
+	r.handle("GET", "/notes/{id}", requireUser(app.noteHandler.GetNote))
+	r.handleWithLimit("PUT", "/notes/{id}", maxContent, requireUser(app.noteHandler.UpdateNote))
+	r.handle("PATCH", "/notes/{id}", requireUser(app.noteHandler.PatchNote))
+	r.handle("DELETE", "/notes/{id}", requireUser(app.noteHandler.DeleteNote))
+	r.handle("POST", "/notes/{id}/delete", requireUser(app.noteHandler.DeleteNote))
+	r.handle("POST", "/notes/{id}/copy", requireUser(app.noteHandler.DuplicateNote))
+	r.handleWithLimit("PATCH", "/notes/{id}/delta", maxContent, requireUser(app.noteHandler.PatchNoteDelta))
+	r.handle("GET", "/notes/{id}/board", requireUser(app.noteHandler.GetNoteBoard))
+	r.handle("GET", "/notes/{id}/html", requireUser(app.markdownHandler.GetNoteHTML))
+	r.handle("GET", "/notes/{id}/stats", requireUser(app.markdownHandler.GetNoteStats))
+	r.handle("POST", "/notes/{id}/format", requireUser(app.markdownHandler.FormatNote))
+	r.handle("POST", "/notes/{id}/lint", requireUser(app.markdownHandler.LintNote))
+	r.handle("PATCH", "/notes/{id}/frontmatter", requireUser(app.noteHandler.PatchFrontmatter))
+	r.handle("PATCH", "/notes/{id}/board", requireUser(app.noteHandler.MoveNoteBoardCard))
+	if app.summaryHandler != nil {
+		// Each summary, suggestion or translation invokes the model, so they
+		// share their own, hourly limit on top of RateLimitPerMinute.
+		limit := func(next HandlerFunc) HandlerFunc { return next }
+		if cfg.Summaries.PerHour > 0 {
+			limit = rateLimit(newRateLimiterPer(cfg.Summaries.PerHour, time.Hour))
 		}
+		r.handle("POST", "/notes/{id}/summarize", requireUser(limit(app.summaryHandler.SummarizeNote)))
+		r.handle("POST", "/notes/{id}/suggest", requireUser(limit(app.summaryHandler.SuggestNote)))
+		r.handle("POST", "/notes/{id}/translate", requireUser(limit(app.translateHandler.TranslateNote)))
 	}
+	r.handle("GET", "/notes/{id}/comments", requireUser(app.commentHandler.ListComments))
+	r.handle("POST", "/notes/{id}/comments", requireUser(app.commentHandler.AddComment))
+	r.handle("DELETE", "/notes/{id}/comments/{commentId}", requireUser(app.commentHandler.DeleteComment))
+	r.handle("POST", "/notes/{id}/comments/{commentId}/delete", requireUser(app.commentHandler.DeleteComment))
+	r.handle("GET", "/notes/{id}/draft", requireUser(app.draftHandler.GetDraft))
+	r.handleWithLimit("PUT", "/notes/{id}/draft", maxContent, requireUser(app.draftHandler.SaveDraft))
+	r.handle("DELETE", "/notes/{id}/draft", requireUser(app.draftHandler.DeleteDraft))
+	r.handle("POST", "/notes/{id}/draft/delete", requireUser(app.draftHandler.DeleteDraft))
+	r.handle("GET", "/notes/{id}/publish", requireUser(app.publishHandler.GetPublication))
+	r.handle("POST", "/notes/{id}/publish", requireUser(app.publishHandler.Publish))
+	r.handle("DELETE", "/notes/{id}/publish", requireUser(app.publishHandler.Unpublish))
+	r.handle("POST", "/notes/{id}/unpublish", requireUser(app.publishHandler.Unpublish))
+	r.handle("GET", "/notes/{id}/shares", requireUser(app.shareHandler.ListShares))
+	r.handle("PUT", "/notes/{id}/shares", requireUser(app.shareHandler.PutShare))
+	r.handle("DELETE", "/notes/{id}/shares/{email}", requireUser(app.shareHandler.DeleteShare))
+	r.handle("POST", "/notes/{id}/shares/{email}/delete", requireUser(app.shareHandler.DeleteShare))
+	r.handle("GET", "/public/{token}", app.publishHandler.GetPublicNote)
+	r.handle("GET", "/reminders", requireUser(app.reminderHandler.ListReminders))
+	r.handle("GET", "/shared-with-me", requireUser(app.shareHandler.ListSharedWithMe))
+	r.handle("GET", "/shared-with-me/{id}/notes", requireUser(app.noteHandler.ListSharedFolder))
+	r.handle("GET", "/starred", requireUser(app.noteHandler.ListStarredNotes))
+	r.handle("PATCH", "/starred/order", requireUser(app.noteHandler.ReorderStarred))
+	r.handle("GET", "/archive", requireUser(app.noteHandler.ListArchivedNotes))
+	r.handle("GET", "/duplicates", requireUser(app.noteHandler.ListDuplicates))
+	r.handle("GET", "/tasks", requireUser(app.noteHandler.ListOpenTasks))
+	r.handle("GET", "/tasks/calendar", requireUser(app.reminderHandler.GetCalendarLink))
+	r.handle("GET", "/tasks/calendar.ics", app.reminderHandler.GetCalendarFeed)
+	r.handle("GET", "/boards/{folderId}", requireUser(app.noteHandler.GetBoard))
+	r.handle("PATCH", "/boards/{folderId}/cards/{cardId}", requireUser(app.noteHandler.MoveBoardCard))
+	r.handle("POST", "/folders", requireUser(app.noteHandler.CreateFolder))
+	r.handle("POST", "/templates/{id}/notes", requireUser(app.noteHandler.CreateFromTemplate))
+
+	// /sessions
+	r.handle("GET", "/sessions/mine", requireUser(app.sessionHandler.ListMyLocks))
+	r.handle("POST", "/sessions/{fileId}/lock", requireUser(app.sessionHandler.AcquireLock))
+	r.handle("DELETE", "/sessions/{fileId}/lock", requireUser(app.sessionHandler.ReleaseLock))
+	r.handle("POST", "/sessions/{fileId}/heartbeat", requireUser(app.sessionHandler.Heartbeat))
 
 	// /sync
-	if path == "/sync/check" && method == "POST" {
-		return corsResponse(must(app.syncHandler.CheckConflict(ctx, req))), nil
+	r.handle("POST", "/sync/check", requireUser(app.syncHandler.CheckConflict))
+	r.handle("GET", "/sync/manifest", requireUser(app.syncHandler.Manifest))
+	r.handle("GET", "/sync/changes", requireUser(app.syncHandler.Changes))
+	r.handleWithLimit("POST", "/sync/reconcile", maxContent, requireUser(app.syncHandler.Reconcile))
+	r.handle("GET", "/sync/vault", requireUser(app.noteHandler.GetVault))
+	r.handleWithLimit("POST", "/sync/vault", maxContent, requireUser(app.noteHandler.PushVault))
+	r.handle("GET", "/conflicts", requireUser(app.syncHandler.ListConflicts))
+	r.handleWithLimit("POST", "/conflicts/{id}/resolve", maxContent, requireUser(app.syncHandler.ResolveConflict))
+
+	// /collab
+	if app.collabHandler != nil {
+		r.handleWithLimit("POST", "/collab/{id}/ops", maxContent, requireUser(app.collabHandler.PushOps))
+		r.handle("GET", "/collab/{id}/ops", requireUser(app.collabHandler.PullOps))
 	}
 
 	// /search
-	if path == "/search" && method == "GET" {
-		return corsResponse(must(app.searchHandler.Search(ctx, req))), nil
-	}
+	r.handle("GET", "/search", requireUser(app.searchHandler.Search))
 
-	return corsResponse(events.APIGatewayProxyResponse{
-		StatusCode: http.StatusNotFound,
-		Body:       fmt.Sprintf("Not Found: %s %s", method, path),
-	}), nil
-}
+	// /export
+	r.handle("POST", "/export/git", requireUser(app.gitExportHandler.ExportGit))
+	r.handle("GET", "/export/git/remote", requireUser(app.gitExportHandler.GetGitRemote))
+	r.handle("PUT", "/export/git/remote", requireUser(app.gitExportHandler.PutGitRemote))
+	r.handle("DELETE", "/export/git/remote", requireUser(app.gitExportHandler.DeleteGitRemote))
+	r.handle("POST", "/export/git/remote/delete", requireUser(app.gitExportHandler.DeleteGitRemote))
 
-// corsResponse adds CORS headers to an API Gateway response.
-func corsResponse(resp events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
-	if resp.Headers == nil {
-		resp.Headers = make(map[string]string)
-	}
-	resp.Headers["Access-Control-Allow-Origin"] = os.Getenv("FRONTEND_URL")
-	if resp.Headers["Access-Control-Allow-Origin"] == "" {
-		resp.Headers["Access-Control-Allow-Origin"] = "http://localhost:3000"
-	}
-	resp.Headers["Access-Control-Allow-Credentials"] = "true"
-	resp.Headers["Access-Control-Allow-Methods"] = "GET,POST,PUT,DELETE,OPTIONS,PATCH"
-	resp.Headers["Access-Control-Allow-Headers"] = "Content-Type,Authorization,If-Match"
-	return resp
-}
+	// /mirror
+	r.handle("GET", "/mirror/status", requireUser(app.mirrorHandler.GetMirrorStatus))
 
-// must unwraps a handler response, ignoring the error.
-func must(resp events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
-	if err != nil {
-		fmt.Printf("Handler error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Internal Server Error"}
-	}
-	return resp
+	return r
 }
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// corsOrigins is the CORS allowlist. Entries are exact origins
+// ("https://notes.example.com"), wildcard subdomains
+// ("https://*.example.com", which does not match https://example.com
+// itself) or "*" for any origin, without credentials.
+type corsOrigins []string
+
+// allow returns the Access-Control-Allow-Origin value for a request from
+// origin, or "" if it may not read the response. A request without an
+// Origin header gets the only allowed origin when there is just one, as
+// before the allowlist existed.
+func (o corsOrigins) allow(origin string) string {
+	if origin == "" {
+		if len(o) == 1 && !strings.Contains(o[0], "*.") {
+			return o[0]
+		}
+		return ""
+	}
+	origin = strings.ToLower(origin)
+	for _, pattern := range o {
+		if pattern == "*" {
+			return "*"
+		}
+		if matchOrigin(strings.ToLower(pattern), origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// matchOrigin reports whether origin matches pattern, which may start its
+// host with "*." to match any subdomain.
+func matchOrigin(pattern, origin string) bool {
+	scheme, host, ok := strings.Cut(pattern, "://")
+	if !ok {
+		return false
+	}
+	suffix, wildcard := strings.CutPrefix(host, "*")
+	if !wildcard {
+		return origin == pattern
+	}
+	sub, ok := strings.CutPrefix(origin, scheme+"://")
+	if !ok {
+		return false
+	}
+	sub, ok = strings.CutSuffix(sub, suffix)
+	return ok && sub != "" && !strings.ContainsAny(sub, "/:@")
+}
+
+// withCORS answers preflight requests and adds CORS headers to every
+// response, including errors produced further in. The request's Origin is
+// echoed when it is allowed, so responses vary by Origin.
+func withCORS(allowed ...string) Middleware {
+	origins := corsOrigins(allowed)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			allowOrigin := origins.allow(requestOrigin(req))
+			if req.HTTPMethod == http.MethodOptions {
+				return corsHeaders(events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, allowOrigin), nil
+			}
+			resp, err := next(ctx, req)
+			return corsHeaders(resp, allowOrigin), err
+		}
+	}
+}
+
+func requestOrigin(req events.APIGatewayProxyRequest) string {
+	if origin := req.Headers["Origin"]; origin != "" {
+		return origin
+	}
+	return req.Headers["origin"]
+}
+
+func corsHeaders(resp events.APIGatewayProxyResponse, allowOrigin string) events.APIGatewayProxyResponse {
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]string)
+	}
+	if allowOrigin != "*" {
+		resp.Headers["Vary"] = addVary(resp.Headers["Vary"], "Origin")
+	}
+	if allowOrigin == "" {
+		return resp
+	}
+	resp.Headers["Access-Control-Allow-Origin"] = allowOrigin
+	// Browsers reject credentialed responses allowed for any origin, and
+	// letting every site send cookies would defeat the allowlist, so "*"
+	// only allows requests without credentials.
+	if allowOrigin != "*" {
+		resp.Headers["Access-Control-Allow-Credentials"] = "true"
+	}
+	resp.Headers["Access-Control-Allow-Methods"] = "GET,POST,PUT,DELETE,OPTIONS,PATCH"
+	resp.Headers["Access-Control-Allow-Headers"] = "Content-Type,Authorization,If-Match"
+	return resp
+}
+
+// addVary adds name to a Vary header value unless it is already listed.
+func addVary(vary, name string) string {
+	if vary == "" {
+		return name
+	}
+	for _, v := range strings.Split(vary, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), name) {
+			return vary
+		}
+	}
+	return vary + "," + name
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithCORS(t *testing.T) {
+	h := chain(ok, withCORS("https://example.com"))
+
+	resp, _ := h(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "OPTIONS", Path: "/notes"})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("preflight status = %d, want 204", resp.StatusCode)
+	}
+	resp, _ = h(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/notes"})
+	if resp.StatusCode != http.StatusOK || resp.Headers["Access-Control-Allow-Origin"] != "https://example.com" {
+		t.Errorf("resp = %d %v, want 200 with CORS headers", resp.StatusCode, resp.Headers)
+	}
+}
+
+func TestWithCORS_Allowlist(t *testing.T) {
+	h := chain(ok, withCORS("https://notes.example.com", "https://*.staging.example.com"))
+	tests := []struct {
+		origin, want string
+	}{
+		{"https://notes.example.com", "https://notes.example.com"},
+		{"https://pr-42.staging.example.com", "https://pr-42.staging.example.com"},
+		{"https://a.b.staging.example.com", "https://a.b.staging.example.com"},
+		{"https://staging.example.com", ""},
+		{"http://pr-42.staging.example.com", ""},
+		{"https://evil.com/.staging.example.com", ""},
+		{"https://staging.example.com.evil.com", ""},
+		{"https://other.example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		for _, method := range []string{"OPTIONS", "GET"} {
+			req := events.APIGatewayProxyRequest{HTTPMethod: method, Path: "/notes", Headers: map[string]string{"origin": tt.origin}}
+			resp, _ := h(context.Background(), req)
+			if got := resp.Headers["Access-Control-Allow-Origin"]; got != tt.want {
+				t.Errorf("%s from %q: Allow-Origin = %q, want %q", method, tt.origin, got, tt.want)
+			}
+			if _, ok := resp.Headers["Access-Control-Allow-Credentials"]; ok != (tt.want != "") {
+				t.Errorf("%s from %q: Allow-Credentials present = %v", method, tt.origin, ok)
+			}
+			if resp.Headers["Vary"] != "Origin" {
+				t.Errorf("%s from %q: Vary = %q, want Origin", method, tt.origin, resp.Headers["Vary"])
+			}
+		}
+	}
+}
+
+func TestWithCORS_AnyOrigin(t *testing.T) {
+	h := chain(ok, withCORS("*"))
+	for _, origin := range []string{"https://anywhere.example", ""} {
+		req := events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/notes", Headers: map[string]string{"Origin": origin}}
+		resp, _ := h(context.Background(), req)
+		if resp.Headers["Access-Control-Allow-Origin"] != "*" {
+			t.Errorf("from %q: Allow-Origin = %q, want *", origin, resp.Headers["Access-Control-Allow-Origin"])
+		}
+		if _, ok := resp.Headers["Access-Control-Allow-Credentials"]; ok {
+			t.Errorf("from %q: Allow-Credentials sent with *", origin)
+		}
+	}
+}
+
+func TestWithCORS_KeepsVary(t *testing.T) {
+	varying := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: map[string]string{"Vary": "Cookie"}}, nil
+	}
+	h := chain(varying, withCORS("https://example.com"))
+	resp, _ := h(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Headers: map[string]string{"Origin": "https://example.com"}})
+	if resp.Headers["Vary"] != "Cookie,Origin" {
+		t.Errorf("Vary = %q, want Cookie,Origin", resp.Headers["Vary"])
+	}
+}
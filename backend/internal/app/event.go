@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handlers, the router and the middleware all work on the REST API (payload
+// format 1.0) proxy event, which serves as the app's internal request and
+// response representation. Events from an HTTP API (payload format 2.0) are
+// converted to and from it at the edge, so migrating the API Gateway does not
+// touch any handler.
+
+// HandleHTTPRequest serves an API Gateway HTTP API (payload format 2.0) event.
+func (app *App) HandleHTTPRequest(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	resp, err := app.HandleRequest(ctx, proxyRequestFromV2(req))
+	if err != nil {
+		return events.APIGatewayV2HTTPResponse{}, err
+	}
+	return v2ResponseFromProxy(resp), nil
+}
+
+// HandleEvent serves either payload format, choosing by the event's
+// "version" field, so one Lambda can sit behind a REST API or an HTTP API.
+// EventBridge schedule events run the scheduled jobs instead.
+func (app *App) HandleEvent(ctx context.Context, event json.RawMessage) (any, error) {
+	var probe struct {
+		Version    string `json:"version"`
+		Source     string `json:"source"`
+		DetailType string `json:"detail-type"`
+	}
+	if err := json.Unmarshal(event, &probe); err != nil {
+		return nil, fmt.Errorf("decode event: %w", err)
+	}
+
+	if isScheduledEvent(probe.Source, probe.DetailType) {
+		return nil, app.RunScheduledJobs(ctx)
+	}
+
+	if probe.Version == "2.0" {
+		var req events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(event, &req); err != nil {
+			return nil, fmt.Errorf("decode HTTP API event: %w", err)
+		}
+		return app.HandleHTTPRequest(ctx, req)
+	}
+
+	var req events.APIGatewayProxyRequest
+	if err := json.Unmarshal(event, &req); err != nil {
+		return nil, fmt.Errorf("decode REST API event: %w", err)
+	}
+	return app.HandleRequest(ctx, withoutAcceptEncoding(req))
+}
+
+// withoutAcceptEncoding drops Accept-Encoding so handlers do not gzip
+// responses behind a REST API. It compresses responses itself, and only
+// decodes base64 bodies for requests that accept one of its binary media
+// types, which JSON requests do not.
+func withoutAcceptEncoding(req events.APIGatewayProxyRequest) events.APIGatewayProxyRequest {
+	headers := make(map[string]string, len(req.Headers))
+	for k, v := range req.Headers {
+		if !strings.EqualFold(k, "Accept-Encoding") {
+			headers[k] = v
+		}
+	}
+	multiHeaders := make(map[string][]string, len(req.MultiValueHeaders))
+	for k, v := range req.MultiValueHeaders {
+		if !strings.EqualFold(k, "Accept-Encoding") {
+			multiHeaders[k] = v
+		}
+	}
+	req.Headers, req.MultiValueHeaders = headers, multiHeaders
+	return req
+}
+
+// proxyRequestFromV2 converts an HTTP API event to the internal request.
+// Header names, which HTTP APIs lowercase, are canonicalized as REST APIs
+// deliver them. HTTP APIs join repeated headers with commas; each is kept
+// as a single value, as API Gateway maps them for v1 integrations, since
+// values such as dates and quoted strings may contain commas. The separate
+// cookies array is folded back into a Cookie header.
+func proxyRequestFromV2(req events.APIGatewayV2HTTPRequest) events.APIGatewayProxyRequest {
+	headers := make(map[string]string, len(req.Headers)+1)
+	multiHeaders := make(map[string][]string, len(req.Headers)+1)
+	for k, v := range req.Headers {
+		k = http.CanonicalHeaderKey(k)
+		headers[k] = v
+		multiHeaders[k] = []string{v}
+	}
+	if len(req.Cookies) > 0 {
+		headers["Cookie"] = strings.Join(req.Cookies, "; ")
+		multiHeaders["Cookie"] = []string{headers["Cookie"]}
+	}
+
+	var query map[string]string
+	var multiQuery map[string][]string
+	if values, err := url.ParseQuery(req.RawQueryString); err == nil && len(values) > 0 {
+		query = make(map[string]string, len(values))
+		multiQuery = map[string][]string(values)
+		for k, v := range values {
+			query[k] = v[len(v)-1]
+		}
+	} else if len(req.QueryStringParameters) > 0 {
+		query = req.QueryStringParameters
+	}
+
+	desc := req.RequestContext.HTTP
+	out := events.APIGatewayProxyRequest{
+		Resource:                        req.RouteKey,
+		Path:                            req.RawPath,
+		HTTPMethod:                      desc.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               multiHeaders,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: multiQuery,
+		PathParameters:                  req.PathParameters,
+		StageVariables:                  req.StageVariables,
+		Body:                            req.Body,
+		IsBase64Encoded:                 req.IsBase64Encoded,
+	}
+	out.RequestContext.AccountID = req.RequestContext.AccountID
+	out.RequestContext.RequestID = req.RequestContext.RequestID
+	out.RequestContext.Stage = req.RequestContext.Stage
+	out.RequestContext.APIID = req.RequestContext.APIID
+	out.RequestContext.DomainName = req.RequestContext.DomainName
+	out.RequestContext.HTTPMethod = desc.Method
+	out.RequestContext.Path = desc.Path
+	out.RequestContext.Protocol = desc.Protocol
+	out.RequestContext.Identity.SourceIP = desc.SourceIP
+	out.RequestContext.Identity.UserAgent = desc.UserAgent
+	return out
+}
+
+// v2ResponseFromProxy converts the internal response to an HTTP API
+// response. HTTP APIs only return multiple Set-Cookie headers through the
+// cookies array, so they are moved there.
+func v2ResponseFromProxy(resp events.APIGatewayProxyResponse) events.APIGatewayV2HTTPResponse {
+	out := events.APIGatewayV2HTTPResponse{
+		StatusCode:      resp.StatusCode,
+		Headers:         make(map[string]string, len(resp.Headers)),
+		Body:            resp.Body,
+		IsBase64Encoded: resp.IsBase64Encoded,
+	}
+	for k, v := range resp.Headers {
+		if http.CanonicalHeaderKey(k) == "Set-Cookie" {
+			out.Cookies = append(out.Cookies, v)
+			continue
+		}
+		out.Headers[k] = v
+	}
+	for k, values := range resp.MultiValueHeaders {
+		if http.CanonicalHeaderKey(k) == "Set-Cookie" {
+			out.Cookies = append(out.Cookies, values...)
+			continue
+		}
+		if len(values) > 0 {
+			out.Headers[k] = strings.Join(values, ",")
+		}
+	}
+	return out
+}
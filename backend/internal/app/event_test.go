@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/publish"
+)
+
+func TestProxyRequestFromV2(t *testing.T) {
+	req := events.APIGatewayV2HTTPRequest{
+		Version:         "2.0",
+		RawPath:         "/api/notes/abc",
+		RawQueryString:  "tag=a&tag=b&q=hello%20world",
+		Cookies:         []string{"session_token=t", "theme=dark"},
+		Headers:         map[string]string{"if-match": `"etag"`, "accept": "text/html, application/json"},
+		Body:            "e30=",
+		IsBase64Encoded: true,
+	}
+	req.RequestContext.HTTP.Method = "PUT"
+	req.RequestContext.HTTP.SourceIP = "203.0.113.1"
+
+	got := proxyRequestFromV2(req)
+	if got.HTTPMethod != "PUT" || got.Path != "/api/notes/abc" {
+		t.Errorf("method/path = %s %s", got.HTTPMethod, got.Path)
+	}
+	if got.Headers["If-Match"] != `"etag"` {
+		t.Errorf("If-Match = %q, want canonicalized header", got.Headers["If-Match"])
+	}
+	if got.Headers["Cookie"] != "session_token=t; theme=dark" {
+		t.Errorf("Cookie = %q", got.Headers["Cookie"])
+	}
+	if want := []string{"text/html, application/json"}; !reflect.DeepEqual(got.MultiValueHeaders["Accept"], want) {
+		t.Errorf("Accept = %v, want %v", got.MultiValueHeaders["Accept"], want)
+	}
+	if got.QueryStringParameters["q"] != "hello world" || !reflect.DeepEqual(got.MultiValueQueryStringParameters["tag"], []string{"a", "b"}) {
+		t.Errorf("query = %v / %v", got.QueryStringParameters, got.MultiValueQueryStringParameters)
+	}
+	if !got.IsBase64Encoded || got.Body != "e30=" {
+		t.Errorf("body = %q base64=%v", got.Body, got.IsBase64Encoded)
+	}
+	if got.RequestContext.Identity.SourceIP != "203.0.113.1" {
+		t.Errorf("SourceIP = %q", got.RequestContext.Identity.SourceIP)
+	}
+}
+
+func TestV2ResponseFromProxy_MovesCookies(t *testing.T) {
+	resp := v2ResponseFromProxy(events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusFound,
+		Headers:           map[string]string{"Location": "/"},
+		MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}, "Vary": {"Origin", "Cookie"}},
+	})
+	if !reflect.DeepEqual(resp.Cookies, []string{"a=1", "b=2"}) {
+		t.Errorf("Cookies = %v", resp.Cookies)
+	}
+	if resp.Headers["Location"] != "/" || resp.Headers["Vary"] != "Origin,Cookie" {
+		t.Errorf("Headers = %v", resp.Headers)
+	}
+	if _, ok := resp.Headers["Set-Cookie"]; ok {
+		t.Error("Set-Cookie should only be in Cookies")
+	}
+}
+
+func TestHandleEvent_DetectsPayloadVersion(t *testing.T) {
+	app := &App{}
+	app.serve = func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{
+			StatusCode:        http.StatusOK,
+			Body:              req.HTTPMethod + " " + req.Path,
+			MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1"}},
+		}, nil
+	}
+
+	v1, err := app.HandleEvent(context.Background(), json.RawMessage(`{"httpMethod":"GET","path":"/notes"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, ok := v1.(events.APIGatewayProxyResponse); !ok || resp.Body != "GET /notes" {
+		t.Errorf("v1 response = %#v", v1)
+	}
+
+	v2, err := app.HandleEvent(context.Background(), json.RawMessage(
+		`{"version":"2.0","rawPath":"/notes","requestContext":{"http":{"method":"POST"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, ok := v2.(events.APIGatewayV2HTTPResponse)
+	if !ok || resp.Body != "POST /notes" || !reflect.DeepEqual(resp.Cookies, []string{"a=1"}) {
+		t.Errorf("v2 response = %#v", v2)
+	}
+}
+
+func TestHandleEvent_LeavesCompressionToRESTAPIs(t *testing.T) {
+	app := &App{}
+	app.serve = func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: req.Headers["Accept-Encoding"] + "|" + req.Headers["Accept"]}, nil
+	}
+
+	v1, _ := app.HandleEvent(context.Background(), json.RawMessage(
+		`{"httpMethod":"GET","path":"/sync/manifest","headers":{"accept-encoding":"gzip","Accept":"application/json"},"multiValueHeaders":{"accept-encoding":["gzip"]}}`))
+	if resp := v1.(events.APIGatewayProxyResponse); resp.Body != "|application/json" {
+		t.Errorf("REST API request reached handlers as %q", resp.Body)
+	}
+	v2, _ := app.HandleEvent(context.Background(), json.RawMessage(
+		`{"version":"2.0","rawPath":"/sync/manifest","headers":{"accept-encoding":"gzip"},"requestContext":{"http":{"method":"GET"}}}`))
+	if resp := v2.(events.APIGatewayV2HTTPResponse); resp.Body != "gzip|" {
+		t.Errorf("HTTP API request reached handlers as %q", resp.Body)
+	}
+}
+
+func TestHandleEvent_RunsScheduledJobs(t *testing.T) {
+	app := &App{publications: publish.NewStore(nil, "")}
+	app.serve = func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		t.Error("a scheduled event should not be served as a request")
+		return events.APIGatewayProxyResponse{}, nil
+	}
+
+	out, err := app.HandleEvent(context.Background(), json.RawMessage(
+		`{"source":"aws.events","detail-type":"Scheduled Event","detail":{}}`))
+	if err != nil || out != nil {
+		t.Errorf("HandleEvent = %v, %v; want nil, nil", out, err)
+	}
+}
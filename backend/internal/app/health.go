@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/jun/gophdrive/backend/internal/metrics"
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+// readinessTimeout bounds all readiness checks together.
+const readinessTimeout = 3 * time.Second
+
+// readinessCheck is one dependency reported by GET /readyz.
+type readinessCheck struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// tableDescriber is the subset of *dynamodb.Client used by readiness checks.
+type tableDescriber interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
+// tableCheck reports whether table exists and is reachable.
+func tableCheck(client tableDescriber, table string) readinessCheck {
+	return readinessCheck{
+		name: "dynamodb:" + table,
+		run: func(ctx context.Context) error {
+			_, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+			return err
+		},
+	}
+}
+
+// settingsCheck fails when any of the named settings was empty at startup.
+func settingsCheck(name string, settings map[string]string) readinessCheck {
+	var missing []string
+	for k, v := range settings {
+		if v == "" {
+			missing = append(missing, k)
+		}
+	}
+	sort.Strings(missing)
+	return readinessCheck{
+		name: name,
+		run: func(context.Context) error {
+			if len(missing) > 0 {
+				return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// secretCheck reports whether a secret resolved on first use can be
+// resolved.
+func secretCheck(name string, v *secret.Value) readinessCheck {
+	return readinessCheck{
+		name: "secret:" + name,
+		run: func(ctx context.Context) error {
+			if v == nil {
+				return errors.New("not configured")
+			}
+			_, err := v.Get(ctx)
+			return err
+		},
+	}
+}
+
+// bucketHeader is the subset of *s3.Client used by readiness checks.
+type bucketHeader interface {
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+}
+
+// bucketCheck reports whether bucket exists and is reachable.
+func bucketCheck(client bucketHeader, bucket string) readinessCheck {
+	return readinessCheck{
+		name: "s3:" + bucket,
+		run: func(ctx context.Context) error {
+			_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+			return err
+		},
+	}
+}
+
+// dirCheck reports whether dir exists and is a directory.
+func dirCheck(name, dir string) readinessCheck {
+	return readinessCheck{
+		name: "dir:" + name,
+		run: func(context.Context) error {
+			info, err := os.Stat(dir)
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			return nil
+		},
+	}
+}
+
+// healthEndpoints answers GET /healthz and GET /readyz (with or without the
+// /api prefix) before origin verification and authentication, so load
+// balancers and docker-compose can probe the backend directly.
+//
+// /healthz only reports that the process is serving. /readyz runs checks and
+// returns 503 if any fails; failures are logged but the response only names
+// the failing check.
+func healthEndpoints(checks []readinessCheck) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			if req.HTTPMethod != http.MethodGet {
+				return next(ctx, req)
+			}
+			switch path := strings.TrimPrefix(req.Path, "/api"); path {
+			case "/healthz":
+				metrics.SetDimension(ctx, routeDimension, "GET "+path)
+				return healthResponse(http.StatusOK, map[string]any{"status": "ok"}), nil
+			case "/readyz":
+				metrics.SetDimension(ctx, routeDimension, "GET "+path)
+				return readyResponse(ctx, checks), nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+func readyResponse(ctx context.Context, checks []readinessCheck) events.APIGatewayProxyResponse {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	results := make(map[string]string, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := "ok"
+			if err := c.run(ctx); err != nil {
+				slog.ErrorContext(ctx, "Readiness check failed", "check", c.name, "error", err)
+				result = "failed"
+			}
+			mu.Lock()
+			results[c.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	for _, r := range results {
+		if r != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+	}
+	return healthResponse(code, map[string]any{"status": status, "checks": results})
+}
+
+func healthResponse(code int, body map[string]any) events.APIGatewayProxyResponse {
+	b, _ := json.Marshal(body)
+	return events.APIGatewayProxyResponse{
+		StatusCode: code,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+		},
+		Body: string(b),
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+type fakeTables map[string]bool
+
+func (f fakeTables) DescribeTable(ctx context.Context, in *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	if !f[*in.TableName] {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+	return &dynamodb.DescribeTableOutput{}, nil
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	tables := fakeTables{"UserTokens": true}
+	checks := []readinessCheck{
+		tableCheck(tables, "UserTokens"),
+		settingsCheck("oauth", map[string]string{"GOOGLE_CLIENT_ID": "id"}),
+	}
+	// Health checks must answer before origin verification.
+	h := chain(ok, healthEndpoints(checks), verifyOrigin(secret.Static("secret")))
+
+	for _, path := range []string{"/healthz", "/api/healthz"} {
+		resp, _ := h(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: path})
+		if resp.StatusCode != http.StatusOK || resp.Body != `{"status":"ok"}` {
+			t.Errorf("%s = %d %s", path, resp.StatusCode, resp.Body)
+		}
+	}
+
+	resp, _ := h(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/readyz"})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/readyz = %d %s, want 200", resp.StatusCode, resp.Body)
+	}
+
+	resp, _ = h(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/notes"})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("/notes = %d, want other paths to reach verifyOrigin", resp.StatusCode)
+	}
+}
+
+func TestReadyz_ReportsFailures(t *testing.T) {
+	checks := []readinessCheck{
+		tableCheck(fakeTables{}, "EditingSessions"),
+		settingsCheck("secrets", map[string]string{"JWT_SECRET": "", "GOOGLE_CLIENT_SECRET": "s"}),
+		settingsCheck("oauth", map[string]string{"GOOGLE_CLIENT_ID": "id"}),
+	}
+	resp, _ := healthEndpoints(checks)(ok)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/readyz"})
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", resp.StatusCode)
+	}
+
+	var body struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"dynamodb:EditingSessions": "failed", "secrets": "failed", "oauth": "ok"}
+	if body.Status != "unavailable" || len(body.Checks) != len(want) {
+		t.Fatalf("body = %+v", body)
+	}
+	for k, v := range want {
+		if body.Checks[k] != v {
+			t.Errorf("check %s = %q, want %q", k, body.Checks[k], v)
+		}
+	}
+}
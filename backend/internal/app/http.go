@@ -0,0 +1,218 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jun/gophdrive/backend/internal/logging"
+	"github.com/jun/gophdrive/backend/internal/metrics"
+)
+
+// Handler returns the app as a plain net/http handler, for running without
+// Lambda (see cmd/standalone). Requests go through the same middleware and
+// routes as API Gateway events. The presence stream, which needs a
+// long-lived connection, is served directly, and WebDAV, whose methods API
+// Gateway does not pass on, through serveHTTP.
+func (app *App) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/sessions/events", app.presenceHandler)
+	mux.Handle("/api/sessions/events", app.presenceHandler)
+	mux.Handle("/dav/", app.webdav)
+	mux.Handle("/api/dav/", http.StripPrefix("/api", app.webdav))
+	mux.Handle("/", http.HandlerFunc(app.ServeHTTP))
+	return mux
+}
+
+// ServeHTTP converts r to the internal request, serves it through the
+// middleware chain and writes the response to w.
+func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := ProxyRequestFromHTTP(r)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	resp, err := app.serve(r.Context(), req)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := WriteProxyResponse(w, resp); err != nil {
+		slog.ErrorContext(r.Context(), "Write response failed", "error", err)
+	}
+}
+
+// serveHTTP serves h, a net/http handler outside the routes, behind mws
+// under the route name "<method> <route>". Middleware sees the request
+// without its body, which h reads itself. h writes its response directly;
+// a response middleware answers with instead, such as a 429, is written
+// for it.
+func serveHTTP(route string, h http.Handler, mws ...Middleware) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		serve := chain(func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			name := req.HTTPMethod + " " + route
+			logging.AddAttrs(ctx, slog.String("route", name))
+			metrics.SetDimension(ctx, routeDimension, name)
+			trace.SpanFromContext(ctx).SetName(name)
+			h.ServeHTTP(rec, r.WithContext(ctx))
+			return events.APIGatewayProxyResponse{StatusCode: cmp.Or(rec.status, http.StatusOK)}, nil
+		}, mws...)
+
+		head := *r
+		head.Body = nil
+		req, _ := ProxyRequestFromHTTP(&head) // fails only reading the body
+		resp, err := serve(r.Context(), req)
+		if rec.status != 0 {
+			return
+		}
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if err := WriteProxyResponse(w, resp); err != nil {
+			slog.ErrorContext(r.Context(), "Write response failed", "error", err)
+		}
+	})
+}
+
+// statusRecorder records the status a handler writes.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ProxyRequestFromHTTP builds the internal request from r. Every header and
+// query value is kept in the multi-value maps; the single-value maps hold
+// the last value, as API Gateway does. Bodies that are not valid UTF-8 are
+// base64-encoded with IsBase64Encoded set.
+func ProxyRequestFromHTTP(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return events.APIGatewayProxyRequest{}, fmt.Errorf("read body: %w", err)
+		}
+	}
+
+	headers := make(map[string]string, len(r.Header)+1)
+	multiHeaders := make(map[string][]string, len(r.Header)+1)
+	for k, v := range r.Header {
+		if len(v) > 0 {
+			headers[k] = v[len(v)-1]
+			multiHeaders[k] = v
+		}
+	}
+	// net/http moves Host out of the header map.
+	if r.Host != "" {
+		headers["Host"] = r.Host
+		multiHeaders["Host"] = []string{r.Host}
+	}
+
+	var query map[string]string
+	var multiQuery map[string][]string
+	if values := r.URL.Query(); len(values) > 0 {
+		query = make(map[string]string, len(values))
+		multiQuery = map[string][]string(values)
+		for k, v := range values {
+			query[k] = v[len(v)-1]
+		}
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               multiHeaders,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: multiQuery,
+	}
+	if utf8.Valid(body) {
+		req.Body = string(body)
+	} else {
+		req.Body = base64.StdEncoding.EncodeToString(body)
+		req.IsBase64Encoded = true
+	}
+
+	req.RequestContext.HTTPMethod = r.Method
+	req.RequestContext.Path = r.URL.Path
+	req.RequestContext.Protocol = r.Proto
+	req.RequestContext.RequestTimeEpoch = time.Now().UnixMilli()
+	req.RequestContext.Identity.SourceIP = remoteIP(r.RemoteAddr)
+	req.RequestContext.Identity.UserAgent = r.UserAgent()
+	return req, nil
+}
+
+// WriteProxyResponse writes resp to w, including every value of
+// MultiValueHeaders (e.g. several Set-Cookie headers) and decoding
+// base64-encoded bodies.
+func WriteProxyResponse(w http.ResponseWriter, resp events.APIGatewayProxyResponse) error {
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return fmt.Errorf("decode base64 body: %w", err)
+		}
+		body = decoded
+	}
+
+	// Like API Gateway, merge both header maps, listing a value given in
+	// both only once.
+	h := w.Header()
+	for k, values := range resp.MultiValueHeaders {
+		for _, v := range values {
+			h.Add(k, v)
+		}
+	}
+	for k, v := range resp.Headers {
+		if !slices.Contains(h.Values(k), v) {
+			h.Add(k, v)
+		}
+	}
+
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, err := w.Write(body)
+	return err
+}
+
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
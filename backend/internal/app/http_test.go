@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestProxyRequestFromHTTP(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/notes?tag=a&tag=b", strings.NewReader("hello"))
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+	r.RemoteAddr = "203.0.113.1:5000"
+
+	req, err := ProxyRequestFromHTTP(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.HTTPMethod != "POST" || req.Path != "/api/notes" || req.Body != "hello" || req.IsBase64Encoded {
+		t.Errorf("request = %s %s %q base64=%v", req.HTTPMethod, req.Path, req.Body, req.IsBase64Encoded)
+	}
+	if req.Headers["Accept"] != "application/json" || len(req.MultiValueHeaders["Accept"]) != 2 {
+		t.Errorf("Accept = %q / %v", req.Headers["Accept"], req.MultiValueHeaders["Accept"])
+	}
+	if req.QueryStringParameters["tag"] != "b" || !reflect.DeepEqual(req.MultiValueQueryStringParameters["tag"], []string{"a", "b"}) {
+		t.Errorf("query = %v / %v", req.QueryStringParameters, req.MultiValueQueryStringParameters)
+	}
+	if req.RequestContext.Identity.SourceIP != "203.0.113.1" {
+		t.Errorf("SourceIP = %q", req.RequestContext.Identity.SourceIP)
+	}
+}
+
+func TestProxyRequestFromHTTP_BinaryBody(t *testing.T) {
+	binary := []byte{0xff, 0x00, 0xfe}
+	r := httptest.NewRequest("PUT", "/notes/a", strings.NewReader(string(binary)))
+	req, _ := ProxyRequestFromHTTP(r)
+	if !req.IsBase64Encoded || req.Body != base64.StdEncoding.EncodeToString(binary) {
+		t.Errorf("body = %q base64=%v, want base64-encoded", req.Body, req.IsBase64Encoded)
+	}
+}
+
+func TestWriteProxyResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := WriteProxyResponse(w, events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusCreated,
+		Headers:           map[string]string{"Content-Type": "application/octet-stream", "Set-Cookie": "a=1"},
+		MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}},
+		Body:              base64.StdEncoding.EncodeToString([]byte{0xff, 0x01}),
+		IsBase64Encoded:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201", w.Code)
+	}
+	if got := w.Header().Values("Set-Cookie"); !reflect.DeepEqual(got, []string{"a=1", "b=2"}) {
+		t.Errorf("Set-Cookie = %v, want both cookies once", got)
+	}
+	if got := w.Body.Bytes(); !reflect.DeepEqual(got, []byte{0xff, 0x01}) {
+		t.Errorf("body = %v, want decoded bytes", got)
+	}
+}
+
+func TestServeHTTP_UsesMiddlewareChain(t *testing.T) {
+	app := &App{}
+	app.serve = chain(func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: req.Path}, nil
+	}, withCORS("http://localhost:3000"), stripPrefix("/api"))
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/api/notes", nil))
+	if w.Body.String() != "/notes" || w.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" {
+		t.Errorf("response = %q %v", w.Body.String(), w.Header())
+	}
+}
+
+func TestProxyRequestFromHTTP_BodyLimit(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/notes", strings.NewReader("0123456789"))
+	r.Body = http.MaxBytesReader(w, r.Body, 4)
+
+	_, err := ProxyRequestFromHTTP(r)
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("err = %v, want a wrapped *http.MaxBytesError", err)
+	}
+}
+
+func TestServeHTTP_WrapsHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write(body)
+	})
+	served := serveHTTP("/dav", h, logRequests, rateLimit(newRateLimiter(1)))
+
+	w := httptest.NewRecorder()
+	served.ServeHTTP(w, httptest.NewRequest("PROPFIND", "/dav/", strings.NewReader("<propfind/>")))
+	if w.Code != http.StatusMultiStatus || w.Body.String() != "<propfind/>" {
+		t.Errorf("first response = %d %q, want the handler's", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	served.ServeHTTP(w, httptest.NewRequest("PROPFIND", "/dav/", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("second response = %d %v, want 429 from the rate limit", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,324 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/logging"
+	"github.com/jun/gophdrive/backend/internal/metrics"
+	"github.com/jun/gophdrive/backend/internal/secret"
+	"github.com/jun/gophdrive/backend/internal/tracing"
+)
+
+// Middleware wraps a HandlerFunc with cross-cutting behaviour.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// chain applies mws around h. The first middleware is the outermost, so it
+// sees the request first and the response last.
+func chain(h HandlerFunc, mws ...Middleware) HandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// logRequests attaches request log fields to the context and logs each
+// request with its status and latency. Routing and authentication add the
+// route and hashed user ID to the same fields.
+func logRequests(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		start := time.Now()
+		id := requestID(req)
+		ctx = handler.WithRequestID(ctx, id)
+		ctx = logging.WithRequest(ctx,
+			slog.String("request_id", id),
+			slog.String("method", req.HTTPMethod),
+			slog.String("path", req.Path),
+		)
+		resp, err := next(ctx, req)
+		slog.InfoContext(ctx, "request",
+			slog.Int("status", resp.StatusCode),
+			slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+		)
+		return resp, err
+	}
+}
+
+// traceRequests records a server span per request, continuing the caller's
+// trace (traceparent or X-Ray header) when there is one, and adds the trace
+// ID to the request log fields. The router renames the span after the
+// matched route.
+func traceRequests(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, req.Headers), req.HTTPMethod,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(req.HTTPMethod),
+				semconv.URLPath(req.Path),
+			),
+		)
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			logging.AddAttrs(ctx, slog.String("trace_id", sc.TraceID().String()))
+		}
+
+		resp, err := next(ctx, req)
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+		tracing.End(span, err)
+		return resp, err
+	}
+}
+
+// routeDimension is the metric dimension naming the matched route.
+// Requests that match no route share one value, so arbitrary paths do not
+// create new metrics.
+const (
+	routeDimension = "Route"
+	unmatchedRoute = "unmatched"
+)
+
+// recordMetrics emits request count, latency and error metrics for each
+// request, together with any counts added while serving it.
+func recordMetrics(recorder *metrics.Recorder) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			start := time.Now()
+			ctx = recorder.WithRequest(ctx, routeDimension, unmatchedRoute)
+			if id := req.RequestContext.RequestID; id != "" {
+				metrics.SetProperty(ctx, "RequestId", id)
+			}
+
+			resp, err := next(ctx, req)
+			status := resp.StatusCode
+			if err != nil {
+				status = http.StatusInternalServerError
+			}
+			metrics.Add(ctx, metrics.Requests, 1)
+			metrics.Put(ctx, metrics.Latency, float64(time.Since(start))/float64(time.Millisecond), metrics.Milliseconds)
+			metrics.Add(ctx, metrics.Errors, boolToFloat(status >= 500))
+			metrics.Add(ctx, metrics.ClientErrors, boolToFloat(status >= 400 && status < 500))
+			if err := metrics.Flush(ctx); err != nil {
+				slog.WarnContext(ctx, "Write metrics failed", "error", err)
+			}
+			return resp, err
+		}
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// requestID returns API Gateway's request ID, the caller's X-Request-Id, or
+// a new random ID.
+func requestID(req events.APIGatewayProxyRequest) string {
+	if id := req.RequestContext.RequestID; id != "" {
+		return id
+	}
+	if id := req.Headers["X-Request-Id"]; id != "" {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// recoverPanics turns a handler panic into a 500 JSON error carrying the
+// request ID instead of crashing the process (or, on Lambda, failing the
+// invocation without CORS headers). The stack is logged with the request
+// fields, so the ID the client sees finds it.
+func recoverPanics(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (resp events.APIGatewayProxyResponse, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.ErrorContext(ctx, "handler panic", "panic", r, "stack", string(debug.Stack()))
+				resp, err = handler.InternalError(ctx), nil
+			}
+		}()
+		return next(ctx, req)
+	}
+}
+
+// handleErrors logs handler errors and replaces them with a 500.
+func handleErrors(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			slog.ErrorContext(ctx, "handler error", "error", err)
+			return handler.InternalError(ctx), nil
+		}
+		return resp, nil
+	}
+}
+
+// Request deadlines: each request gets at most the configured timeout and,
+// on Lambda, must end deadlineBuffer before the invocation does, so the 504
+// and its logs, metrics and spans still get out.
+const (
+	defaultRequestTimeout = 29 * time.Second // API Gateway's integration timeout
+	deadlineBuffer        = time.Second
+)
+
+// withDeadline gives the request a deadline, which cancels in-flight Drive,
+// DynamoDB and KMS calls when it passes; handlers walking many notes check
+// it between calls. The handler runs to completion on the request's
+// goroutine, so nothing outlives the response, and a handler that failed
+// because of the deadline is answered with 504.
+func withDeadline(timeout time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			deadline := time.Now().Add(timeout)
+			if d, ok := ctx.Deadline(); ok && d.Add(-deadlineBuffer).Before(deadline) {
+				deadline = d.Add(-deadlineBuffer)
+			}
+			ctx, cancel := context.WithDeadline(ctx, deadline)
+			defer cancel()
+
+			resp, err := next(ctx, req)
+			// A handler that gave up because of the deadline reports it as
+			// whatever error it got; say what happened.
+			if ctx.Err() != nil && (err != nil || resp.StatusCode >= http.StatusInternalServerError) {
+				slog.WarnContext(ctx, "Request deadline exceeded", "error", ctx.Err())
+				return handler.Timeout(ctx), nil
+			}
+			return resp, err
+		}
+	}
+}
+
+// verifyOrigin rejects requests that did not come through CloudFront, which
+// adds the shared X-Origin-Verify secret.
+func verifyOrigin(originSecret *secret.Value) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			if !originVerified(ctx, originSecret, cmp.Or(req.Headers["X-Origin-Verify"], req.Headers["x-origin-verify"])) {
+				slog.WarnContext(ctx, "missing or invalid X-Origin-Verify header")
+				return handler.Error(ctx, http.StatusForbidden, "Forbidden: Access denied"), nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// originVerified reports whether header is the origin secret. When the
+// secret is rotated CloudFront sends the new value before our cache expires,
+// so a mismatch forces a refresh (rate limited by the cache) before the
+// request is rejected.
+func originVerified(ctx context.Context, originSecret *secret.Value, header string) bool {
+	if header == "" {
+		return false
+	}
+	want, err := originSecret.Get(ctx)
+	if err == nil && header == want {
+		return true
+	}
+	want, err = originSecret.Refresh(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to resolve API_GATEWAY_SECRET", "error", err)
+		return false
+	}
+	return header == want
+}
+
+// stripPrefix removes prefix from req.Path, e.g. the /api that CloudFront
+// forwards.
+func stripPrefix(prefix string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			req.Path = strings.TrimPrefix(req.Path, prefix)
+			return next(ctx, req)
+		}
+	}
+}
+
+// decodeBody decodes base64-encoded request bodies, which API Gateway sends
+// for binary media types, so handlers always see the raw body.
+func decodeBody(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if req.IsBase64Encoded {
+			body, err := base64.StdEncoding.DecodeString(req.Body)
+			if err != nil {
+				return handler.Error(ctx, http.StatusBadRequest, "Invalid base64-encoded body"), nil
+			}
+			req.Body = string(body)
+			req.IsBase64Encoded = false
+		}
+		return next(ctx, req)
+	}
+}
+
+// authenticate verifies the session token or, failing that, the personal
+// access token, if any, and stores the claims in the context for
+// requireUser and the handlers. Requests without a valid token pass through
+// unchanged; public routes still need to serve them.
+func authenticate(jwtSecret string, tokens *handler.AccessTokenHandler) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			claims, err := handler.GetUserClaims(req, jwtSecret)
+			if err != nil && tokens != nil {
+				claims, err = tokens.Authenticate(ctx, req)
+			}
+			if err == nil {
+				ctx = handler.WithUserClaims(ctx, claims)
+				logging.AddAttrs(ctx, slog.String("user", logging.HashUserID(claims.UserID)))
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// requireUser rejects requests that authenticate did not identify.
+func requireUser(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if _, ok := handler.UserClaimsFromContext(ctx); !ok {
+			return handler.Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+		}
+		return next(ctx, req)
+	}
+}
+
+// rateLimit rejects callers that exceed limiter with 429 Too Many Requests.
+// Callers are keyed by user when authenticate has run, otherwise by source IP.
+func rateLimit(limiter *rateLimiter) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			if ok, retry := limiter.allow(rateLimitKey(ctx, req)); !ok {
+				resp := handler.Error(ctx, http.StatusTooManyRequests, "Too Many Requests")
+				resp.Headers["Retry-After"] = strconv.Itoa(int(math.Ceil(retry.Seconds())))
+				return resp, nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+func rateLimitKey(ctx context.Context, req events.APIGatewayProxyRequest) string {
+	if claims, ok := handler.UserClaimsFromContext(ctx); ok {
+		return "user:" + claims.UserID
+	}
+	if ip := req.RequestContext.Identity.SourceIP; ip != "" {
+		return "ip:" + ip
+	}
+	return "anonymous"
+}
@@ -0,0 +1,391 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jun/gophdrive/backend/internal/accesstoken"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/logging"
+	"github.com/jun/gophdrive/backend/internal/metrics"
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+func ok(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: req.Path}, nil
+}
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				order = append(order, name)
+				return next(ctx, req)
+			}
+		}
+	}
+	h := chain(ok, tag("outer"), tag("inner"))
+	if _, err := h(context.Background(), events.APIGatewayProxyRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("order = %v, want [outer inner]", order)
+	}
+}
+
+func TestRecoverPanicsAndHandleErrors(t *testing.T) {
+	panicking := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		panic("boom")
+	}
+	failing := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, errors.New("boom")
+	}
+	for name, h := range map[string]HandlerFunc{"panic": panicking, "error": failing} {
+		resp, err := chain(h, withCORS("*"), recoverPanics, handleErrors)(context.Background(), events.APIGatewayProxyRequest{})
+		if err != nil || resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("%s: got %d, %v; want 500, nil", name, resp.StatusCode, err)
+		}
+		if resp.Headers["Access-Control-Allow-Origin"] != "*" {
+			t.Errorf("%s: 500 is missing CORS headers", name)
+		}
+	}
+}
+
+func TestRecoverPanics_ReturnsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logging.New(&buf, false))
+
+	panicking := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		panic("boom")
+	}
+	req := events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/notes"}
+	req.RequestContext.RequestID = "req-1"
+	resp, err := chain(panicking, logRequests, recoverPanics)(context.Background(), req)
+	if err != nil || resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got %d, %v; want 500, nil", resp.StatusCode, err)
+	}
+	if resp.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", resp.Headers["Content-Type"])
+	}
+	var body handler.ErrorResponse
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatalf("body is not JSON: %q", resp.Body)
+	}
+	if body.Code != "internal_error" || body.RequestID != "req-1" {
+		t.Errorf("body = %+v, want internal_error with request ID req-1", body)
+	}
+
+	// The stack is logged under the same request ID.
+	var panicLog map[string]any
+	if err := json.Unmarshal(bytes.SplitN(buf.Bytes(), []byte("\n"), 2)[0], &panicLog); err != nil {
+		t.Fatalf("log output is not JSON: %q", buf.String())
+	}
+	if panicLog["msg"] != "handler panic" || panicLog["request_id"] != "req-1" || panicLog["stack"] == nil {
+		t.Errorf("panic log = %v", panicLog)
+	}
+}
+
+func TestWithDeadline(t *testing.T) {
+	blocking := func(ctx context.Context, _ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		<-ctx.Done()
+		return events.APIGatewayProxyResponse{}, fmt.Errorf("drive call: %w", ctx.Err())
+	}
+	// A handler that finished its work just after the deadline is answered
+	// as it returned.
+	late := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		time.Sleep(40 * time.Millisecond)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+
+	tests := []struct {
+		name    string
+		h       HandlerFunc
+		timeout time.Duration
+		// lambda is how long before the invocation deadline; 0 means none.
+		lambda     time.Duration
+		wantStatus int
+	}{
+		{"fast", ok, time.Second, 0, http.StatusOK},
+		{"honours the deadline", blocking, 20 * time.Millisecond, 0, http.StatusGatewayTimeout},
+		{"finishes late", late, 20 * time.Millisecond, 0, http.StatusOK},
+		{"lambda deadline comes first", blocking, time.Minute, deadlineBuffer + 20*time.Millisecond, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.lambda > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.lambda)
+				defer cancel()
+			}
+			start := time.Now()
+			resp, err := withDeadline(tt.timeout)(tt.h)(ctx, events.APIGatewayProxyRequest{})
+			if err != nil || resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got %d, %v; want %d", resp.StatusCode, err, tt.wantStatus)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("took %s", elapsed)
+			}
+			if tt.wantStatus == http.StatusGatewayTimeout {
+				var body handler.ErrorResponse
+				if err := json.Unmarshal([]byte(resp.Body), &body); err != nil || body.Code != "timeout" {
+					t.Errorf("body = %q, want code timeout", resp.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyOrigin(t *testing.T) {
+	h := chain(ok, verifyOrigin(secret.Static("s3cret")))
+	resp, _ := h(context.Background(), events.APIGatewayProxyRequest{})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("without header: status = %d, want 403", resp.StatusCode)
+	}
+	resp, _ = h(context.Background(), events.APIGatewayProxyRequest{Headers: map[string]string{"x-origin-verify": "s3cret"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("with header: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+// staleResolver serves a cached secret until it is told to refresh.
+type staleResolver struct{ cached, current string }
+
+func (r *staleResolver) GetSecret(context.Context, string) (string, error) { return r.cached, nil }
+
+func (r *staleResolver) Refresh(context.Context, string) (string, error) {
+	r.cached = r.current
+	return r.cached, nil
+}
+
+func TestVerifyOrigin_RotatedSecret(t *testing.T) {
+	// CloudFront already sends the new value; the cache has the old one.
+	resolver := &staleResolver{cached: "old", current: "new"}
+	h := chain(ok, verifyOrigin(secret.NewValue(resolver, "/origin")))
+	withHeader := func(v string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{Headers: map[string]string{"X-Origin-Verify": v}}
+	}
+
+	if resp, _ := h(context.Background(), withHeader("new")); resp.StatusCode != http.StatusOK {
+		t.Errorf("rotated secret: status = %d, want 200", resp.StatusCode)
+	}
+	if resp, _ := h(context.Background(), withHeader("old")); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("previous secret: status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	resp, _ := chain(ok, stripPrefix("/api"))(context.Background(), events.APIGatewayProxyRequest{Path: "/api/notes"})
+	if resp.Body != "/notes" {
+		t.Errorf("path = %q, want /notes", resp.Body)
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	echoBody := func(_ context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: req.Body}, nil
+	}
+	h := decodeBody(echoBody)
+
+	resp, _ := h(context.Background(), events.APIGatewayProxyRequest{Body: "e30=", IsBase64Encoded: true})
+	if resp.StatusCode != http.StatusOK || resp.Body != "{}" {
+		t.Errorf("base64 body: got %d %q, want 200 {}", resp.StatusCode, resp.Body)
+	}
+	resp, _ = h(context.Background(), events.APIGatewayProxyRequest{Body: "{}"})
+	if resp.Body != "{}" {
+		t.Errorf("text body: got %q, want it unchanged", resp.Body)
+	}
+	resp, _ = h(context.Background(), events.APIGatewayProxyRequest{Body: "not base64!", IsBase64Encoded: true})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid base64: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	h := requireUser(ok)
+	resp, _ := h(context.Background(), events.APIGatewayProxyRequest{})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous: status = %d, want 401", resp.StatusCode)
+	}
+	ctx := handler.WithUserClaims(context.Background(), &handler.UserClaims{UserID: "u1"})
+	resp, _ = h(ctx, events.APIGatewayProxyRequest{})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("signed in: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAuthenticate_AccessToken(t *testing.T) {
+	signer := crypto.NewSigner(secret.Static("signing-key"))
+	store := accesstoken.NewStore(nil, "")
+	tok := accesstoken.Token{UserID: "u1", ID: "t1", ExpiresAt: time.Now().Add(time.Hour)}
+	store.Create(context.Background(), tok)
+	raw, _ := accesstoken.Sign(context.Background(), signer, tok)
+
+	h := authenticate("jwt-secret", handler.NewAccessTokenHandler(store, signer, "jwt-secret"))(requireUser(ok))
+	req := events.APIGatewayProxyRequest{Headers: map[string]string{"Authorization": "Bearer " + raw}}
+	if resp, _ := h(context.Background(), req); resp.StatusCode != http.StatusOK {
+		t.Errorf("access token: status = %d, want 200", resp.StatusCode)
+	}
+	store.Delete(context.Background(), "u1", "t1")
+	if resp, _ := h(context.Background(), req); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("revoked access token: status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(2)
+	limiter.now = func() time.Time { return now }
+	h := chain(ok, rateLimit(limiter))
+
+	req := events.APIGatewayProxyRequest{}
+	req.RequestContext.Identity.SourceIP = "203.0.113.1"
+	for i := 0; i < 2; i++ {
+		if resp, _ := h(context.Background(), req); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, resp.StatusCode)
+		}
+	}
+	resp, _ := h(context.Background(), req)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Headers["Retry-After"] != "30" {
+		t.Errorf("over limit: got %d Retry-After=%q, want 429 Retry-After=30", resp.StatusCode, resp.Headers["Retry-After"])
+	}
+
+	// A signed-in user has their own bucket even from the same address.
+	ctx := handler.WithUserClaims(context.Background(), &handler.UserClaims{UserID: "u1"})
+	if resp, _ := h(ctx, req); resp.StatusCode != http.StatusOK {
+		t.Errorf("other key: status = %d, want 200", resp.StatusCode)
+	}
+
+	now = now.Add(30 * time.Second)
+	if resp, _ := h(context.Background(), req); resp.StatusCode != http.StatusOK {
+		t.Errorf("after refill: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestLogRequests_IncludesRequestFields(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logging.New(&buf, false))
+
+	r := newRouter()
+	r.handle("GET", "/notes/{id}", ok)
+	h := chain(r.serve, logRequests, func(next HandlerFunc) HandlerFunc {
+		// Stand-in for authenticate, which needs a signed token.
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			logging.AddAttrs(ctx, slog.String("user", logging.HashUserID("u1")))
+			return next(ctx, req)
+		}
+	})
+	req := events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/notes/abc"}
+	req.RequestContext.RequestID = "req-1"
+	if _, err := h(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("log output is not one JSON record: %q", buf.String())
+	}
+	want := map[string]any{
+		"msg":        "request",
+		"request_id": "req-1",
+		"route":      "GET /notes/{id}",
+		"user":       logging.HashUserID("u1"),
+		"status":     float64(http.StatusOK),
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+	if _, ok := rec["latency_ms"]; !ok {
+		t.Error("missing latency_ms")
+	}
+}
+
+func TestTraceRequests_NamesSpansAfterRoute(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	r := newRouter()
+	r.handle("GET", "/notes/{id}", func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, nil
+	})
+	h := chain(r.serve, traceRequests)
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/notes/abc",
+		Headers:    map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}
+	if _, err := h(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want handler and request spans", len(spans))
+	}
+	handlerSpan, requestSpan := spans[0], spans[1]
+	if requestSpan.Name() != "GET /notes/{id}" || requestSpan.SpanKind() != trace.SpanKindServer {
+		t.Errorf("request span = %q (%v)", requestSpan.Name(), requestSpan.SpanKind())
+	}
+	if requestSpan.Parent().TraceID().String() != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("request span did not continue the caller's trace: parent %v", requestSpan.Parent())
+	}
+	if requestSpan.Status().Code != codes.Error {
+		t.Errorf("request span status = %v, want error for a 500", requestSpan.Status())
+	}
+	if handlerSpan.Name() != "handler GET /notes/{id}" || handlerSpan.Parent().SpanID() != requestSpan.SpanContext().SpanID() {
+		t.Errorf("handler span = %q, parent %v", handlerSpan.Name(), handlerSpan.Parent())
+	}
+}
+
+func TestRecordMetrics_PerRoute(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRouter()
+	r.handle("GET", "/notes/{id}", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		metrics.Add(ctx, metrics.DriveCalls, 2)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound}, nil
+	})
+	h := chain(r.serve, recordMetrics(metrics.New(&buf, "Test")))
+
+	for _, path := range []string{"/notes/abc", "/no/such/route"} {
+		buf.Reset()
+		if _, err := h(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: path}); err != nil {
+			t.Fatal(err)
+		}
+		var rec map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+			t.Fatalf("%s: metrics output is not one JSON record: %q", path, buf.String())
+		}
+		wantRoute := "GET /notes/{id}"
+		if path != "/notes/abc" {
+			wantRoute = unmatchedRoute
+		}
+		if rec[routeDimension] != wantRoute || rec[metrics.Requests] != float64(1) ||
+			rec[metrics.ClientErrors] != float64(1) || rec[metrics.Errors] != float64(0) {
+			t.Errorf("%s: record = %v", path, rec)
+		}
+		if path == "/notes/abc" && rec[metrics.DriveCalls] != float64(2) {
+			t.Errorf("DriveCalls = %v, want 2", rec[metrics.DriveCalls])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is an in-memory token bucket per key. Each Lambda instance
+// keeps its own buckets, so the limit is per instance rather than global;
+// it is meant to blunt bursts from one client, not to meter usage.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*bucket
+	now     func() time.Time
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter allows perMinute requests per key per minute, with bursts
+// of up to perMinute requests.
+func newRateLimiter(perMinute int) *rateLimiter {
+	return newRateLimiterPer(perMinute, time.Minute)
+}
+
+// newRateLimiterPer allows limit requests per key per period, with bursts
+// of up to limit requests.
+func newRateLimiterPer(limit int, period time.Duration) *rateLimiter {
+	return &rateLimiter{
+		rate:    float64(limit) / period.Seconds(),
+		burst:   float64(limit),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token for key. When none is left it returns false and how
+// long until the next token is available.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled completely, at most once per
+// refill period, so idle clients don't accumulate.
+func (l *rateLimiter) sweep(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.swept) < full {
+		return
+	}
+	l.swept = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/logging"
+	"github.com/jun/gophdrive/backend/internal/metrics"
+	"github.com/jun/gophdrive/backend/internal/tracing"
+)
+
+// HandlerFunc is the signature shared by every API handler.
+type HandlerFunc func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// route is one method + pattern registration. Pattern segments written as
+// {name} match any single path segment and are stored in PathParameters.
+type route struct {
+	method   string
+	pattern  string
+	segments []string
+	literals int
+	maxBody  int64 // 0 means the router's maxBody
+	handler  HandlerFunc
+}
+
+// router dispatches requests by method and path pattern.
+type router struct {
+	routes []route
+	// maxBody is the request body limit for routes without their own;
+	// 0 means no limit.
+	maxBody int64
+}
+
+func newRouter() *router {
+	return &router{}
+}
+
+// handle registers h for method and pattern, e.g. "/notes/{id}/copy".
+func (r *router) handle(method, pattern string, h HandlerFunc) {
+	r.handleWithLimit(method, pattern, 0, h)
+}
+
+// handleWithLimit is handle for routes whose request bodies may be up to
+// maxBody bytes rather than the router's default.
+func (r *router) handleWithLimit(method, pattern string, maxBody int64, h HandlerFunc) {
+	segments := splitPath(pattern)
+	literals := 0
+	for _, s := range segments {
+		if !isParam(s) {
+			literals++
+		}
+	}
+	r.routes = append(r.routes, route{method: method, pattern: pattern, segments: segments, literals: literals, maxBody: maxBody, handler: h})
+}
+
+// match returns the route for method and path with its path parameters.
+// When the path matches only under other methods, rt is nil and allowed lists
+// those methods; when nothing matches, both are nil.
+//
+// Among routes matching the same path, the one with the most literal
+// segments wins, so /sessions/mine is preferred over /sessions/{fileId}
+// regardless of registration order.
+func (r *router) match(method, path string) (rt *route, params map[string]string, allowed []string) {
+	segments := splitPath(path)
+	best := -1
+	seen := map[string]bool{}
+	for i := range r.routes {
+		candidate := &r.routes[i]
+		p, ok := candidate.matchPath(segments)
+		if !ok {
+			continue
+		}
+		if candidate.method != method {
+			if !seen[candidate.method] {
+				seen[candidate.method] = true
+				allowed = append(allowed, candidate.method)
+			}
+			continue
+		}
+		if candidate.literals > best {
+			best, rt, params = candidate.literals, candidate, p
+		}
+	}
+	if rt != nil {
+		return rt, params, nil
+	}
+	sort.Strings(allowed)
+	return nil, nil, allowed
+}
+
+// serve routes req by its method and path (already stripped of any /api
+// prefix), merging matched parameters into req.PathParameters.
+func (r *router) serve(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	path := req.Path
+	rt, params, allowed := r.match(req.HTTPMethod, path)
+	if rt == nil {
+		if len(allowed) > 0 {
+			resp := handler.ErrorResponse{
+				Message: fmt.Sprintf("Method Not Allowed: %s %s", req.HTTPMethod, path),
+				Details: map[string][]string{"allowed": allowed},
+			}.Response(ctx, http.StatusMethodNotAllowed)
+			resp.Headers["Allow"] = strings.Join(allowed, ", ")
+			return resp, nil
+		}
+		return handler.Error(ctx, http.StatusNotFound, fmt.Sprintf("Not Found: %s %s", req.HTTPMethod, path)), nil
+	}
+
+	if req.PathParameters == nil {
+		req.PathParameters = make(map[string]string, len(params))
+	}
+	for k, v := range params {
+		req.PathParameters[k] = v
+	}
+	name := rt.method + " " + rt.pattern
+	logging.AddAttrs(ctx, slog.String("route", name))
+	metrics.SetDimension(ctx, routeDimension, name)
+
+	// Name the request span after the route, and time the handler on its
+	// own so middleware overhead stays visible.
+	requestSpan := trace.SpanFromContext(ctx)
+	requestSpan.SetName(name)
+	requestSpan.SetAttributes(semconv.HTTPRoute(rt.pattern))
+
+	maxBody := rt.maxBody
+	if maxBody == 0 {
+		maxBody = r.maxBody
+	}
+	if size := int64(len(req.Body)); maxBody > 0 && size > maxBody {
+		slog.WarnContext(ctx, "Request body too large", "bytes", size, "max_bytes", maxBody)
+		return bodyTooLarge(ctx, size, maxBody), nil
+	}
+
+	ctx, span := tracing.Start(ctx, "handler "+name)
+	resp, err := rt.handler(ctx, req)
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+func (rt route) matchPath(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, s := range rt.segments {
+		if isParam(s) {
+			if segments[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[s[1:len(s)-1]] = segments[i]
+			continue
+		}
+		if s != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func isParam(segment string) bool {
+	return len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}'
+}
+
+// bodyTooLarge returns the 413 response for a body of size bytes on a
+// route that accepts at most maxBody.
+func bodyTooLarge(ctx context.Context, size, maxBody int64) events.APIGatewayProxyResponse {
+	return handler.ErrorResponse{
+		Message: fmt.Sprintf("Request body is %s; this endpoint accepts at most %s", formatBytes(size), formatBytes(maxBody)),
+		Details: map[string]int64{"bytes": size, "maxBytes": maxBody},
+	}.Response(ctx, http.StatusRequestEntityTooLarge)
+}
+
+// formatBytes formats n as bytes, KiB or MiB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+// echo returns a handler that reports its name and the path parameters.
+func echo(name string) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		body := name
+		for _, k := range []string{"id", "fileId"} {
+			if v, ok := req.PathParameters[k]; ok {
+				body += " " + k + "=" + v
+			}
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: body}, nil
+	}
+}
+
+func testRouter() *router {
+	r := newRouter()
+	r.handle("GET", "/notes", echo("list"))
+	r.handle("GET", "/notes/{id}", echo("get"))
+	r.handle("DELETE", "/notes/{id}", echo("delete"))
+	r.handle("POST", "/notes/{id}/copy", echo("copy"))
+	r.handle("POST", "/sessions/{fileId}/lock", echo("lock"))
+	r.handle("GET", "/sessions/{fileId}", echo("session"))
+	r.handle("GET", "/sessions/mine", echo("mine"))
+	return r
+}
+
+func TestRouter_Serve(t *testing.T) {
+	r := testRouter()
+	tests := []struct {
+		method, path string
+		wantStatus   int
+		wantBody     string
+	}{
+		{"GET", "/notes", 200, "list"},
+		{"GET", "/notes/", 200, "list"},
+		{"GET", "/notes/abc", 200, "get id=abc"},
+		{"DELETE", "/notes/abc", 200, "delete id=abc"},
+		{"POST", "/notes/abc/copy", 200, "copy id=abc"},
+		{"POST", "/sessions/f1/lock", 200, "lock fileId=f1"},
+		{"GET", "/sessions/mine", 200, "mine"},
+		{"GET", "/sessions/other", 200, "session fileId=other"},
+		{"GET", "/notes/abc/copy", 405, "Method Not Allowed: GET /notes/abc/copy"},
+		{"GET", "/notes/abc/unknown", 404, "Not Found: GET /notes/abc/unknown"},
+		{"GET", "/nope", 404, "Not Found: GET /nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: tt.path}
+			resp, err := r.serve(context.Background(), req)
+			if err != nil {
+				t.Fatalf("serve error = %v", err)
+			}
+			body := resp.Body
+			if resp.StatusCode >= 400 {
+				var e handler.ErrorResponse
+				if err := json.Unmarshal([]byte(resp.Body), &e); err != nil {
+					t.Fatalf("error body %q is not JSON: %v", resp.Body, err)
+				}
+				body = e.Message
+			}
+			if resp.StatusCode != tt.wantStatus || body != tt.wantBody {
+				t.Errorf("serve = %d %q, want %d %q", resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRouter_MethodNotAllowedListsMethods(t *testing.T) {
+	r := testRouter()
+	req := events.APIGatewayProxyRequest{HTTPMethod: "PUT", Path: "/notes/abc"}
+	resp, _ := r.serve(context.Background(), req)
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want 405", resp.StatusCode)
+	}
+	if got := resp.Headers["Allow"]; got != "DELETE, GET" {
+		t.Errorf("Allow = %q, want %q", got, "DELETE, GET")
+	}
+}
+
+func TestRouter_KeepsExistingPathParameters(t *testing.T) {
+	r := testRouter()
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod:     "GET",
+		Path:           "/notes/abc",
+		PathParameters: map[string]string{"fileId": "kept"},
+	}
+	resp, _ := r.serve(context.Background(), req)
+	if resp.Body != "get id=abc fileId=kept" {
+		t.Errorf("Body = %q, want both parameters", resp.Body)
+	}
+}
+
+func TestRouter_BodyLimit(t *testing.T) {
+	r := newRouter()
+	r.maxBody = 10
+	r.handle("POST", "/notes/{id}/copy", echo("copy"))
+	r.handleWithLimit("PUT", "/notes/{id}", 100, echo("update"))
+
+	tests := []struct {
+		method, path string
+		size         int
+		wantStatus   int
+	}{
+		{"POST", "/notes/a/copy", 10, http.StatusOK},
+		{"POST", "/notes/a/copy", 11, http.StatusRequestEntityTooLarge},
+		{"PUT", "/notes/a", 100, http.StatusOK},
+		{"PUT", "/notes/a", 2048, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: tt.path, Body: strings.Repeat("x", tt.size)}
+		resp, _ := r.serve(context.Background(), req)
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s %s with %d bytes: status = %d, want %d", tt.method, tt.path, tt.size, resp.StatusCode, tt.wantStatus)
+		}
+	}
+
+	req := events.APIGatewayProxyRequest{HTTPMethod: "PUT", Path: "/notes/a", Body: strings.Repeat("x", 2048)}
+	resp, _ := r.serve(context.Background(), req)
+	var body struct {
+		Code    string           `json:"code"`
+		Message string           `json:"message"`
+		Details map[string]int64 `json:"details"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatalf("body %q is not JSON: %v", resp.Body, err)
+	}
+	if body.Code != "payload_too_large" || body.Details["bytes"] != 2048 || body.Details["maxBytes"] != 100 {
+		t.Errorf("body = %+v", body)
+	}
+	if body.Message != "Request body is 2.0 KiB; this endpoint accepts at most 100 bytes" {
+		t.Errorf("message = %q", body.Message)
+	}
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/config"
+	"github.com/jun/gophdrive/backend/internal/reminder"
+)
+
+// ScheduleInterval is how often the scheduled jobs run. On Lambda an
+// EventBridge rule (see infra's compute stack) invokes the function at
+// this rate; the other servers run a ticker.
+const ScheduleInterval = 5 * time.Minute
+
+// RunScheduledJobs runs the periodic jobs once: releasing publications
+// whose scheduled time has passed, sending due task reminders and retrying
+// failed mirror copies. A failed job does not stop the others.
+func (app *App) RunScheduledJobs(ctx context.Context) error {
+	var errs []error
+	now := time.Now()
+
+	released, err := app.publications.Release(ctx, now)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("release scheduled publications: %w", err))
+	}
+	if released > 0 {
+		slog.InfoContext(ctx, "Released scheduled publications", "count", released)
+	}
+
+	if app.reminderNotifier != nil {
+		sent, err := reminder.Send(ctx, app.reminders, app.reminderNotifier, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("send reminders: %w", err))
+		}
+		if sent > 0 {
+			slog.InfoContext(ctx, "Sent reminders", "count", sent)
+		}
+	}
+
+	if app.mirror != nil {
+		copied, err := app.mirror.Reconcile(ctx, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reconcile mirror: %w", err))
+		}
+		if copied > 0 {
+			slog.InfoContext(ctx, "Mirrored notes", "count", copied)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reminderNotifier builds the notifier for the configured reminder
+// channels, or returns nil if there are none.
+func reminderNotifier(settings config.ReminderSettings) reminder.Notifier {
+	var notifiers reminder.Notifiers
+	if settings.WebhookURL != "" {
+		notifiers = append(notifiers, &reminder.WebhookNotifier{URL: settings.WebhookURL})
+	}
+	if settings.SMTPAddr != "" {
+		notifiers = append(notifiers, &reminder.EmailNotifier{
+			Addr:     settings.SMTPAddr,
+			From:     settings.EmailFrom,
+			Username: settings.SMTPUsername,
+			Password: settings.SMTPPassword,
+		})
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return notifiers
+}
+
+// isScheduledEvent reports whether a Lambda event came from an EventBridge
+// schedule rather than API Gateway.
+func isScheduledEvent(source, detailType string) bool {
+	return source == "aws.events" && detailType == "Scheduled Event"
+}
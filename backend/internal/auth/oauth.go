@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
@@ -22,6 +24,8 @@ type AuthService struct {
 	dynamoClient *dynamodb.Client
 	tableName    string
 	kmsService   crypto.Encryptor
+	clientSecret ClientSecret
+	onChange     []func(userID string)
 
 	// In-memory fallback
 	tokens map[string]model.UserToken
@@ -45,6 +49,58 @@ func NewAuthService(oauthConfig *oauth2.Config, dynamoClient *dynamodb.Client, t
 	}
 }
 
+// ClientSecret supplies the OAuth client secret; *secret.Value implements
+// it. Refresh re-fetches a secret that Google rejected, in case it was
+// rotated.
+type ClientSecret interface {
+	Get(ctx context.Context) (string, error)
+	Refresh(ctx context.Context) (string, error)
+}
+
+// SetClientSecret makes s look up the OAuth client secret from cs whenever
+// it exchanges or refreshes a token, instead of using the one in the config
+// it was created with.
+func (s *AuthService) SetClientSecret(cs ClientSecret) {
+	s.clientSecret = cs
+}
+
+// configWithSecret returns the OAuth2 config with the client secret filled
+// in, re-fetching the secret first if refresh is set.
+func (s *AuthService) configWithSecret(ctx context.Context, refresh bool) (*oauth2.Config, error) {
+	if s.clientSecret == nil {
+		return s.oauthConfig, nil
+	}
+	get := s.clientSecret.Get
+	if refresh {
+		get = s.clientSecret.Refresh
+	}
+	secret, err := get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolve OAuth client secret: %w", err)
+	}
+	cfg := *s.oauthConfig
+	cfg.ClientSecret = secret
+	return &cfg, nil
+}
+
+// OnTokenChange registers fn to be called after a user's token or base
+// folder is saved, so that anything built from the old values can be
+// discarded.
+func (s *AuthService) OnTokenChange(fn func(userID string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+func (s *AuthService) notifyChange(userID string) {
+	s.mu.RLock()
+	fns := s.onChange
+	s.mu.RUnlock()
+	for _, fn := range fns {
+		fn(userID)
+	}
+}
+
 // GenerateAuthURL returns the URL to redirect the user to for Google login.
 func (s *AuthService) GenerateAuthURL(state string) string {
 	return s.oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
@@ -52,7 +108,35 @@ func (s *AuthService) GenerateAuthURL(state string) string {
 
 // ExchangeCode exchanges the authorization code for an access token.
 func (s *AuthService) ExchangeCode(ctx context.Context, code string) (*oauth2.Token, error) {
-	return s.oauthConfig.Exchange(ctx, code)
+	cfg, err := s.configWithSecret(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	token, err := cfg.Exchange(ctx, code)
+	if !isInvalidClient(err) || s.clientSecret == nil {
+		return token, err
+	}
+	// The client secret may have been rotated since it was cached. The code
+	// is only spent once Google accepts the client, so retry it.
+	slog.WarnContext(ctx, "Google rejected the OAuth client secret; refreshing it")
+	retry, rerr := s.configWithSecret(ctx, true)
+	if rerr != nil || retry.ClientSecret == cfg.ClientSecret {
+		return nil, err
+	}
+	return retry.Exchange(ctx, code)
+}
+
+// isInvalidClient reports whether err is Google rejecting the client
+// credentials.
+func isInvalidClient(err error) bool {
+	var re *oauth2.RetrieveError
+	return errors.As(err, &re) && re.ErrorCode == "invalid_client"
+}
+
+// refreshTokenContext binds a user's encrypted refresh token to the user,
+// so it cannot be copied to another user's record.
+func refreshTokenContext(userID string) crypto.EncryptionContext {
+	return crypto.EncryptionContext{"user_id": userID, "purpose": "refresh_token"}
 }
 
 // SaveToken encrypts the refresh token and stores it in DynamoDB.
@@ -62,7 +146,7 @@ func (s *AuthService) SaveToken(ctx context.Context, userID string, token *oauth
 	}
 
 	// Encrypt Refresh Token
-	encrypted, err := s.kmsService.Encrypt(ctx, token.RefreshToken)
+	encrypted, err := s.kmsService.Encrypt(ctx, token.RefreshToken, refreshTokenContext(userID))
 	if err != nil {
 		return fmt.Errorf("failed to encrypt refresh token: %w", err)
 	}
@@ -85,6 +169,7 @@ func (s *AuthService) SaveToken(ctx context.Context, userID string, token *oauth
 		s.mu.Lock()
 		s.tokens[userID] = userToken
 		s.mu.Unlock()
+		s.notifyChange(userID)
 		return nil
 	}
 
@@ -101,6 +186,7 @@ func (s *AuthService) SaveToken(ctx context.Context, userID string, token *oauth
 		return fmt.Errorf("failed to save token to DynamoDB: %w", err)
 	}
 
+	s.notifyChange(userID)
 	return nil
 }
 
@@ -148,6 +234,7 @@ func (s *AuthService) UpdateBaseFolderID(ctx context.Context, userID, folderID s
 			s.tokens[userID] = t
 		}
 		s.mu.Unlock()
+		s.notifyChange(userID)
 		return nil
 	}
 
@@ -166,6 +253,45 @@ func (s *AuthService) UpdateBaseFolderID(ctx context.Context, userID, folderID s
 		return fmt.Errorf("failed to update base folder id: %w", err)
 	}
 
+	s.notifyChange(userID)
+	return nil
+}
+
+// reencryptToken stores refreshToken again encrypted under the current key,
+// replacing userToken's copy encrypted under a retired one. It leaves a
+// token that was replaced in the meantime (e.g. by a new sign-in) alone.
+func (s *AuthService) reencryptToken(ctx context.Context, userToken *model.UserToken, refreshToken string) error {
+	encrypted, err := s.kmsService.Encrypt(ctx, refreshToken, refreshTokenContext(userToken.UserID))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+
+	if s.dynamoClient == nil {
+		s.mu.Lock()
+		if t, ok := s.tokens[userToken.UserID]; ok && t.EncryptedRefreshToken == userToken.EncryptedRefreshToken {
+			t.EncryptedRefreshToken = encrypted
+			s.tokens[userToken.UserID] = t
+		}
+		s.mu.Unlock()
+		return nil
+	}
+
+	_, err = s.dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"user_id": &types.AttributeValueMemberS{Value: userToken.UserID},
+		},
+		UpdateExpression:    aws.String("SET encrypted_refresh_token = :new"),
+		ConditionExpression: aws.String("encrypted_refresh_token = :old"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":new": &types.AttributeValueMemberS{Value: encrypted},
+			":old": &types.AttributeValueMemberS{Value: userToken.EncryptedRefreshToken},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionFailed) {
+		return fmt.Errorf("failed to update refresh token: %w", err)
+	}
 	return nil
 }
 
@@ -193,42 +319,28 @@ func (s *AuthService) GetBaseFolderID(ctx context.Context, userID string) (strin
 
 // GetClient returns an authenticated http.Client for the user.
 func (s *AuthService) GetClient(ctx context.Context, userID string) (*http.Client, error) {
-	var userToken model.UserToken
-
-	if s.dynamoClient == nil {
-		s.mu.RLock()
-		t, ok := s.tokens[userID]
-		s.mu.RUnlock()
-		if !ok {
-			return nil, fmt.Errorf("user not found")
-		}
-		userToken = t
-	} else {
-		// Get from DynamoDB
-		out, err := s.dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
-			TableName: aws.String(s.tableName),
-			Key: map[string]types.AttributeValue{
-				"user_id": &types.AttributeValueMemberS{Value: userID},
-			},
-		})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get item from DynamoDB: %w", err)
-		}
-		if out.Item == nil {
-			return nil, fmt.Errorf("user not found")
-		}
-
-		err = attributevalue.UnmarshalMap(out.Item, &userToken)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal user token: %w", err)
-		}
+	userToken, err := s.GetUserToken(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
+	return s.ClientForToken(ctx, userToken)
+}
 
+// ClientForToken returns an http.Client authenticated with userToken's
+// refresh token. The client outlives ctx: it refreshes its access token as
+// needed, so callers may keep it for later requests.
+func (s *AuthService) ClientForToken(ctx context.Context, userToken *model.UserToken) (*http.Client, error) {
 	// Decrypt Refresh Token
-	refreshToken, err := s.kmsService.Decrypt(ctx, userToken.EncryptedRefreshToken)
+	refreshToken, stale, err := crypto.DecryptStale(ctx, s.kmsService, userToken.EncryptedRefreshToken, refreshTokenContext(userToken.UserID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt refresh token: %w", err)
 	}
+	if stale {
+		if err := s.reencryptToken(ctx, userToken, refreshToken); err != nil {
+			// The token still works; the next read tries again.
+			slog.WarnContext(ctx, "Re-encrypting refresh token failed", "user_id", userToken.UserID, "error", err)
+		}
+	}
 
 	// Create Token Source
 	token := &oauth2.Token{
@@ -236,7 +348,11 @@ func (s *AuthService) GetClient(ctx context.Context, userID string) (*http.Clien
 		Expiry:       time.Now().Add(-1 * time.Hour), // Force refresh
 	}
 
-	tokenSource := s.oauthConfig.TokenSource(ctx, token)
+	cfg, err := s.configWithSecret(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	tokenSource := cfg.TokenSource(context.Background(), token)
 
-	return oauth2.NewClient(ctx, tokenSource), nil
+	return oauth2.NewClient(context.Background(), tokenSource), nil
 }
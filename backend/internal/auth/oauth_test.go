@@ -2,6 +2,9 @@ package auth
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -134,6 +137,46 @@ func TestAuthService_GetAuthURL(t *testing.T) {
 	}
 }
 
+// rotatedSecret has a cached client secret that is out of date until it is
+// refreshed.
+type rotatedSecret struct{ cached, current string }
+
+func (s *rotatedSecret) Get(context.Context) (string, error) { return s.cached, nil }
+
+func (s *rotatedSecret) Refresh(context.Context) (string, error) {
+	s.cached = s.current
+	return s.cached, nil
+}
+
+func TestAuthService_ExchangeCode_RefreshesRejectedSecret(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("client_secret") != "new-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"access","token_type":"Bearer"}`))
+	}))
+	defer srv.Close()
+
+	s := NewAuthService(&oauth2.Config{
+		ClientID: "test-client-id",
+		Endpoint: oauth2.Endpoint{TokenURL: srv.URL, AuthStyle: oauth2.AuthStyleInParams},
+	}, nil, "test-tokens-table", crypto.NewMockEncryptor())
+	s.SetClientSecret(&rotatedSecret{cached: "old-secret", current: "new-secret"})
+
+	token, err := s.ExchangeCode(context.Background(), "code")
+	if err != nil {
+		t.Fatalf("ExchangeCode: %v", err)
+	}
+	if token.AccessToken != "access" || attempts != 2 {
+		t.Errorf("token %q after %d attempts, want access after a retry", token.AccessToken, attempts)
+	}
+}
+
 func TestAuthService_SaveToken_EmptyRefreshToken(t *testing.T) {
 	s := testAuthService()
 	ctx := context.Background()
@@ -195,6 +238,51 @@ func TestAuthService_InMemoryTokenStore(t *testing.T) {
 	}
 }
 
+// rotatingEncryptor encrypts with "new:" and still decrypts "old:",
+// reporting such values as stale.
+type rotatingEncryptor struct{}
+
+func (rotatingEncryptor) Encrypt(_ context.Context, plaintext string, _ crypto.EncryptionContext) (string, error) {
+	return "new:" + plaintext, nil
+}
+
+func (e rotatingEncryptor) Decrypt(ctx context.Context, ciphertext string, encCtx crypto.EncryptionContext) (string, error) {
+	plaintext, _, err := e.DecryptStale(ctx, ciphertext, encCtx)
+	return plaintext, err
+}
+
+func (rotatingEncryptor) DecryptStale(_ context.Context, ciphertext string, _ crypto.EncryptionContext) (string, bool, error) {
+	if rest, ok := strings.CutPrefix(ciphertext, "old:"); ok {
+		return rest, true, nil
+	}
+	return strings.TrimPrefix(ciphertext, "new:"), false, nil
+}
+
+func TestAuthService_ClientForToken_ReencryptsStaleToken(t *testing.T) {
+	s := NewAuthService(&oauth2.Config{}, nil, "test-tokens-table", rotatingEncryptor{})
+	ctx := context.Background()
+	s.tokens["user1"] = model.UserToken{UserID: "user1", EncryptedRefreshToken: "old:refresh-1"}
+
+	if _, err := s.GetClient(ctx, "user1"); err != nil {
+		t.Fatalf("GetClient failed: %v", err)
+	}
+	saved, _ := s.GetUserToken(ctx, "user1")
+	if saved.EncryptedRefreshToken != "new:refresh-1" {
+		t.Errorf("Expected token re-encrypted under the new key, got %q", saved.EncryptedRefreshToken)
+	}
+
+	// A token replaced since it was read is left alone.
+	stale := model.UserToken{UserID: "user1", EncryptedRefreshToken: "old:refresh-1"}
+	s.tokens["user1"] = model.UserToken{UserID: "user1", EncryptedRefreshToken: "new:refresh-2"}
+	if _, err := s.ClientForToken(ctx, &stale); err != nil {
+		t.Fatalf("ClientForToken failed: %v", err)
+	}
+	saved, _ = s.GetUserToken(ctx, "user1")
+	if saved.EncryptedRefreshToken != "new:refresh-2" {
+		t.Errorf("Expected newer token to be kept, got %q", saved.EncryptedRefreshToken)
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsSubstring(s, substr))
 }
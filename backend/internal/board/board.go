@@ -0,0 +1,50 @@
+// Package board reads and edits the Markdown behind kanban boards. A
+// folder board's cards are its notes, placed in columns by the status
+// field of their YAML frontmatter and ordered by the order field. A note
+// board's columns are the note's "## " sections and its cards are the
+// list items in them, as in the Obsidian Kanban format.
+package board
+
+import (
+	"strconv"
+
+	"github.com/jun/gophdrive/core/markdown"
+)
+
+// The frontmatter fields that place a note on a folder board.
+const (
+	StatusField = "status"
+	OrderField  = "order"
+)
+
+// Status returns the note's status, or "" if it has none.
+func Status(content []byte) string {
+	status, _ := markdown.FrontmatterField(content, StatusField)
+	return status
+}
+
+// Order returns the note's position within its column. ok is false if the
+// note has no numeric order.
+func Order(content []byte) (order float64, ok bool) {
+	value, _ := markdown.FrontmatterField(content, OrderField)
+	order, err := strconv.ParseFloat(value, 64)
+	return order, err == nil
+}
+
+// Place sets the note's status and order, removing either field when it
+// is "" or nil. Other fields, comments and the body are left unchanged.
+func Place(content []byte, status string, order *float64) ([]byte, error) {
+	var err error
+	if status == "" {
+		content, err = markdown.RemoveFrontmatterField(content, StatusField)
+	} else {
+		content, err = markdown.SetFrontmatterField(content, StatusField, status)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return markdown.RemoveFrontmatterField(content, OrderField)
+	}
+	return markdown.SetFrontmatterField(content, OrderField, *order)
+}
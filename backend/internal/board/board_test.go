@@ -0,0 +1,114 @@
+package board
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPlace(t *testing.T) {
+	order := 1.5
+	tests := []struct {
+		name    string
+		content string
+		status  string
+		order   *float64
+		want    string
+	}{
+		{"adds a block", "# Note\n", "Doing", nil, "---\nstatus: Doing\n---\n# Note\n"},
+		{"replaces in place", "---\ntitle: x\nstatus: Todo\norder: 3\n---\nbody\n", "In review", &order, "---\ntitle: x\nstatus: In review\norder: 1.5\n---\nbody\n"},
+		{"quotes when needed", "---\nstatus: Todo\n---\n", "Done: shipped", nil, "---\nstatus: \"Done: shipped\"\n---\n"},
+		{"drops an empty block", "---\nstatus: Todo\norder: 2\n---\nbody\n", "", nil, "body\n"},
+		{"keeps CRLF", "---\r\nstatus: Todo\r\n---\r\nbody\r\n", "Done", nil, "---\r\nstatus: Done\r\n---\r\nbody\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Place([]byte(tt.content), tt.status, tt.order)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("Place = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusAndOrder(t *testing.T) {
+	content := []byte("---\nstatus: \"In progress\" # moved Monday\norder: 2.5\n---\n")
+	if got := Status(content); got != "In progress" {
+		t.Errorf("Status = %q", got)
+	}
+	if got, ok := Order(content); !ok || got != 2.5 {
+		t.Errorf("Order = %v, %v", got, ok)
+	}
+	if got := Status([]byte("status: Todo\n")); got != "" {
+		t.Errorf("Status without frontmatter = %q, want empty", got)
+	}
+	if _, ok := Order([]byte("---\norder: first\n---\n")); ok {
+		t.Error("Order accepted a non-number")
+	}
+}
+
+const noteBoard = `# Sprint
+
+Intro text.
+
+## Todo
+
+- [ ] write tests
+  with details
+- plan release
+
+## Doing
+- [x] fix login
+
+` + "```" + `
+## not a column
+` + "```" + `
+
+## Done
+`
+
+func TestParse(t *testing.T) {
+	columns := Parse([]byte(noteBoard))
+	if len(columns) != 3 || columns[0].Name != "Todo" || columns[2].Name != "Done" {
+		t.Fatalf("Parse columns = %+v", columns)
+	}
+	todo := columns[0].Cards
+	if len(todo) != 2 || todo[0].Text != "write tests" || todo[1].Text != "plan release" || todo[1].Index != 1 {
+		t.Errorf("Todo cards = %+v", todo)
+	}
+	if doing := columns[1].Cards; len(doing) != 1 || !doing[0].Done || doing[0].Index != 2 {
+		t.Errorf("Doing cards = %+v", doing)
+	}
+	if len(columns[2].Cards) != 0 {
+		t.Errorf("Done cards = %+v, want none", columns[2].Cards)
+	}
+}
+
+func TestMoveCard(t *testing.T) {
+	got, err := MoveCard([]byte(noteBoard), 0, "Done", 0)
+	if err != nil {
+		t.Fatalf("MoveCard: %v", err)
+	}
+	want := noteBoard[:len(noteBoard)-len("## Done\n")] + "## Done\n- [ ] write tests\n  with details\n"
+	want = strings.Replace(want, "- [ ] write tests\n  with details\n- plan release", "- plan release", 1)
+	if string(got) != want {
+		t.Errorf("MoveCard to an empty column =\n%s\nwant\n%s", got, want)
+	}
+
+	got, _ = MoveCard([]byte(noteBoard), 1, "Todo", 0)
+	if columns := Parse(got); columns[0].Cards[0].Text != "plan release" || columns[0].Cards[1].Text != "write tests" {
+		t.Errorf("MoveCard within a column = %+v", columns[0].Cards)
+	}
+
+	got, _ = MoveCard([]byte(noteBoard), 2, "Todo", 1)
+	if columns := Parse(got); len(columns[0].Cards) != 3 || columns[0].Cards[1].Text != "fix login" || len(columns[1].Cards) != 0 {
+		t.Errorf("MoveCard between columns = %+v", columns)
+	}
+
+	if _, err := MoveCard([]byte(noteBoard), 9, "Todo", 0); !errors.Is(err, ErrCardNotFound) {
+		t.Errorf("MoveCard with a bad card error = %v", err)
+	}
+	if _, err := MoveCard([]byte(noteBoard), 0, "Later", 0); !errors.Is(err, ErrColumnNotFound) {
+		t.Errorf("MoveCard with a bad column error = %v", err)
+	}
+}
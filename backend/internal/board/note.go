@@ -0,0 +1,232 @@
+package board
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/jun/gophdrive/core/markdown"
+)
+
+var (
+	// ErrCardNotFound is returned for a card index outside the board.
+	ErrCardNotFound = errors.New("card not found")
+	// ErrColumnNotFound is returned for a column the board does not have.
+	ErrColumnNotFound = errors.New("column not found")
+)
+
+// Column is a "## " section of a note board.
+type Column struct {
+	Name  string `json:"name"`
+	Cards []Card `json:"cards"`
+
+	heading int // line of the note
+}
+
+// Card is a top-level list item in a column. Index counts the note's cards
+// in document order; Done is set for a checked task item.
+type Card struct {
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+	Done  bool   `json:"done,omitempty"`
+
+	start, end int // lines [start, end) of the note
+}
+
+// Parse returns the columns of a note board. Content before the first
+// "## " heading, and lines in a column that are not list items, are not
+// part of the board.
+func Parse(content []byte) []Column {
+	columns, _ := parse(content)
+	return columns
+}
+
+// parse returns the columns and the note split after each newline, which
+// the cards' line ranges index.
+func parse(content []byte) ([]Column, [][]byte) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	columns := []Column{}
+	first := markdown.FrontmatterLines(content)
+
+	var (
+		col   *Column
+		card  *Card
+		fence string
+		index int
+	)
+	closeCard := func(i int) {
+		if card != nil {
+			card.end = i
+			col.Cards = append(col.Cards, *card)
+			card = nil
+		}
+	}
+	for i := first; i < len(lines); i++ {
+		line := strings.TrimRight(string(lines[i]), "\r\n")
+		trimmed := strings.TrimLeft(line, " \t")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			if card != nil && line != trimmed {
+				continue // a code block inside the card
+			}
+			closeCard(i)
+			continue
+		}
+
+		if level, name, ok := heading(line); ok {
+			closeCard(i)
+			col = nil
+			if level == 2 {
+				columns = append(columns, Column{Name: name, Cards: []Card{}, heading: i})
+				col = &columns[len(columns)-1]
+			}
+			continue
+		}
+		if col == nil {
+			continue
+		}
+		if text, ok := listItem(line); ok {
+			closeCard(i)
+			card = &Card{Index: index, start: i}
+			card.Text, card.Done = checkbox(text)
+			index++
+			continue
+		}
+		// Indented lines continue the current card; anything else ends it.
+		if card != nil && line != "" && line != trimmed {
+			continue
+		}
+		closeCard(i)
+	}
+	closeCard(len(lines))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for c := range columns {
+		for k := range columns[c].Cards {
+			columns[c].Cards[k].end = min(columns[c].Cards[k].end, len(lines))
+		}
+	}
+	return columns, lines
+}
+
+// MoveCard moves the card at index to position in the first column named
+// column, counting the column's cards without the moved one. A position
+// past the end appends the card. The card's lines, including nested items,
+// move as they are.
+func MoveCard(content []byte, index int, column string, position int) ([]byte, error) {
+	columns, lines := parse(content)
+	var moved *Card
+	for c := range columns {
+		for k := range columns[c].Cards {
+			if columns[c].Cards[k].Index == index {
+				moved = &columns[c].Cards[k]
+			}
+		}
+	}
+	if moved == nil {
+		return nil, ErrCardNotFound
+	}
+	target := -1
+	for c := range columns {
+		if columns[c].Name == column {
+			target = c
+			break
+		}
+	}
+	if target < 0 {
+		return nil, ErrColumnNotFound
+	}
+
+	newline := []byte("\n")
+	if bytes.Contains(content, []byte("\r\n")) {
+		newline = []byte("\r\n")
+	}
+	block := slices.Clone(lines[moved.start:moved.end])
+	if last := block[len(block)-1]; !bytes.HasSuffix(last, []byte("\n")) {
+		block[len(block)-1] = append(bytes.Clone(last), newline...)
+	}
+
+	// The line the card is inserted before, in the note without the card.
+	var cards []Card
+	for _, c := range columns[target].Cards {
+		if c.Index != index {
+			cards = append(cards, c)
+		}
+	}
+	var at int
+	switch {
+	case position >= 0 && position < len(cards):
+		at = cards[position].start
+	case len(cards) > 0:
+		at = cards[len(cards)-1].end
+	default:
+		// An empty column: after its heading and any blank lines below it.
+		at = columns[target].heading + 1
+		for at < len(lines) && len(bytes.TrimSpace(lines[at])) == 0 {
+			at++
+		}
+	}
+	if at > moved.start {
+		at -= moved.end - moved.start
+	}
+
+	rest := append(append([][]byte{}, lines[:moved.start]...), lines[moved.end:]...)
+	if at > 0 && at == len(rest) && !bytes.HasSuffix(rest[at-1], []byte("\n")) {
+		rest[at-1] = append(bytes.Clone(rest[at-1]), newline...)
+	}
+	var out bytes.Buffer
+	for _, l := range rest[:at] {
+		out.Write(l)
+	}
+	for _, l := range block {
+		out.Write(l)
+	}
+	for _, l := range rest[at:] {
+		out.Write(l)
+	}
+	return out.Bytes(), nil
+}
+
+// heading returns the level and text of an ATX heading line.
+func heading(line string) (level int, text string, ok bool) {
+	level = len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 {
+		return 0, "", false
+	}
+	rest := line[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, "", false
+	}
+	return level, strings.TrimSpace(strings.TrimRight(strings.TrimSpace(rest), "#")), true
+}
+
+// listItem returns the text of an unindented bullet list item.
+func listItem(line string) (string, bool) {
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if text, ok := strings.CutPrefix(line, marker); ok {
+			return strings.TrimSpace(text), true
+		}
+	}
+	return "", false
+}
+
+// checkbox strips a task checkbox from a list item's text.
+func checkbox(text string) (string, bool) {
+	if len(text) >= 3 && text[0] == '[' && text[2] == ']' && (len(text) == 3 || text[3] == ' ') {
+		switch text[1] {
+		case 'x', 'X':
+			return strings.TrimSpace(text[3:]), true
+		case ' ':
+			return strings.TrimSpace(text[3:]), false
+		}
+	}
+	return text, false
+}
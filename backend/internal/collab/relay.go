@@ -0,0 +1,80 @@
+// Package collab relays CRDT operations between collaborators editing the same note.
+// The relay treats operations as opaque JSON; merging happens on the clients.
+package collab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// MaxLogOps bounds the ops a MemoryRelay keeps per note; older ones are
+// dropped.
+const MaxLogOps = 1000
+
+// ErrSeqExpired is returned by Since for a sequence number the log no
+// longer holds, because its ops were dropped or the relay restarted. The
+// client reloads the note and pulls from the latest sequence number.
+var ErrSeqExpired = errors.New("collab sequence number expired")
+
+// Relay stores an append-only operation log per note.
+type Relay interface {
+	// Append adds ops to the note's log and returns the new sequence number.
+	Append(ctx context.Context, noteID string, ops []json.RawMessage) (int, error)
+
+	// Since returns ops appended after seq and the latest sequence number,
+	// or ErrSeqExpired.
+	Since(ctx context.Context, noteID string, seq int) ([]json.RawMessage, int, error)
+}
+
+// MemoryRelay keeps operation logs in process memory. Clients only see each
+// other's ops when they reach the same process, so it suits the local server
+// and single-instance deployments, not Lambda.
+type MemoryRelay struct {
+	mu   sync.Mutex
+	logs map[string]*opLog
+}
+
+// opLog holds the ops of one note after sequence number start.
+type opLog struct {
+	start int
+	ops   []json.RawMessage
+}
+
+// NewMemoryRelay creates an empty MemoryRelay.
+func NewMemoryRelay() *MemoryRelay {
+	return &MemoryRelay{logs: make(map[string]*opLog)}
+}
+
+func (r *MemoryRelay) Append(ctx context.Context, noteID string, ops []json.RawMessage) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	log := r.logs[noteID]
+	if log == nil {
+		log = &opLog{}
+		r.logs[noteID] = log
+	}
+	log.ops = append(log.ops, ops...)
+	if drop := len(log.ops) - MaxLogOps; drop > 0 {
+		log.ops = append([]json.RawMessage(nil), log.ops[drop:]...)
+		log.start += drop
+	}
+	return log.start + len(log.ops), nil
+}
+
+func (r *MemoryRelay) Since(ctx context.Context, noteID string, seq int) ([]json.RawMessage, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	log := r.logs[noteID]
+	if log == nil {
+		log = &opLog{}
+	}
+	latest := log.start + len(log.ops)
+	if seq < log.start || seq > latest {
+		return nil, latest, ErrSeqExpired
+	}
+	ops := make([]json.RawMessage, latest-seq)
+	copy(ops, log.ops[seq-log.start:])
+	return ops, latest, nil
+}
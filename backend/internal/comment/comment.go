@@ -0,0 +1,404 @@
+// Package comment stores threaded review comments on notes, outside the
+// notes themselves so that commenting never edits a note's content.
+package comment
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	// ErrNotFound is returned for a comment, or reply target, that does
+	// not exist or was deleted.
+	ErrNotFound = errors.New("comment not found")
+	// ErrNotAuthor is returned when a user deletes someone else's comment.
+	ErrNotAuthor = errors.New("only the author can delete a comment")
+)
+
+// countID is the sort key of the item holding a note's comment count.
+// It sorts before every comment ID.
+const countID = "#count"
+
+// Comment is a comment on a note. Threads are one level deep: a comment
+// with a ParentID is a reply to the top-level comment with that ID.
+//
+// A top-level comment deleted while it has replies stays as a tombstone,
+// with Deleted set and no body, so the replies keep their thread.
+type Comment struct {
+	NoteID     string    `json:"noteId" dynamodbav:"note_id"`
+	ID         string    `json:"id" dynamodbav:"comment_id"`
+	ParentID   string    `json:"parentId,omitempty" dynamodbav:"parent_id,omitempty"`
+	AuthorID   string    `json:"authorId" dynamodbav:"author_id"`
+	AuthorName string    `json:"authorName,omitempty" dynamodbav:"author_name,omitempty"`
+	Body       string    `json:"body" dynamodbav:"body"`
+	CreatedAt  time.Time `json:"createdAt" dynamodbav:"created_at"`
+	Deleted    bool      `json:"deleted,omitempty" dynamodbav:"deleted,omitempty"`
+}
+
+// Store persists comments in a DynamoDB table keyed by note_id and
+// comment_id. Next to a note's comments, the table keeps an item counting
+// them, so listings can show counts without reading every comment.
+// If client is nil, it uses an in-memory map (for tests).
+type Store struct {
+	client    *dynamodb.Client
+	tableName string
+
+	// Fallback for tests
+	comments map[string][]Comment // by note ID, oldest first
+	mu       sync.Mutex
+}
+
+// NewStore creates a new comment Store.
+func NewStore(client *dynamodb.Client, tableName string) *Store {
+	return &Store{
+		client:    client,
+		tableName: tableName,
+		comments:  make(map[string][]Comment),
+	}
+}
+
+// newID returns a comment ID that sorts by creation time.
+func newID(now time.Time) string {
+	return fmt.Sprintf("%016x-%s", now.UnixNano(), rand.Text()[:8])
+}
+
+// Add stores c as a new comment and returns it with its ID and creation
+// time set. Replying to a reply adds to the same thread. It returns
+// ErrNotFound if the comment replied to does not exist.
+func (s *Store) Add(ctx context.Context, c Comment) (*Comment, error) {
+	if c.ParentID != "" {
+		parent, err := s.get(ctx, c.NoteID, c.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.ParentID != "" {
+			c.ParentID = parent.ParentID
+		}
+	}
+	c.CreatedAt = time.Now().UTC()
+	c.ID = newID(c.CreatedAt)
+	c.Deleted = false
+
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if c.ParentID != "" && !slices.ContainsFunc(s.comments[c.NoteID], func(p Comment) bool { return p.ID == c.ParentID && !p.Deleted }) {
+			return nil, ErrNotFound
+		}
+		s.comments[c.NoteID] = append(s.comments[c.NoteID], c)
+		return &c, nil
+	}
+
+	item, err := attributevalue.MarshalMap(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal comment: %w", err)
+	}
+	writes := []types.TransactWriteItem{
+		{Put: &types.Put{
+			TableName:           aws.String(s.tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(comment_id)"),
+		}},
+		s.countUpdate(c.NoteID, 1),
+	}
+	if c.ParentID != "" {
+		// The thread may have been deleted since it was read.
+		writes = append(writes, types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+			TableName:           aws.String(s.tableName),
+			Key:                 key(c.NoteID, c.ParentID),
+			ConditionExpression: aws.String("attribute_exists(comment_id) AND attribute_not_exists(deleted)"),
+		}})
+	}
+	if err := s.transact(ctx, writes); err != nil {
+		return nil, fmt.Errorf("failed to add comment: %w", err)
+	}
+	return &c, nil
+}
+
+// List returns the comments on noteID, oldest first.
+func (s *Store) List(ctx context.Context, noteID string) ([]Comment, error) {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return slices.Clone(s.comments[noteID]), nil
+	}
+
+	comments := []Comment{}
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String("note_id = :note_id AND comment_id > :count"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":note_id": &types.AttributeValueMemberS{Value: noteID},
+			":count":   &types.AttributeValueMemberS{Value: countID},
+		},
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query comments: %w", err)
+		}
+		var page []Comment
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal comments: %w", err)
+		}
+		comments = append(comments, page...)
+	}
+	return comments, nil
+}
+
+// Delete deletes userID's comment id on noteID. It returns ErrNotFound if
+// there is no such comment and ErrNotAuthor if someone else wrote it.
+func (s *Store) Delete(ctx context.Context, noteID, id, userID string) error {
+	comments, err := s.List(ctx, noteID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(comments, func(c Comment) bool { return c.ID == id && !c.Deleted })
+	if i < 0 {
+		return ErrNotFound
+	}
+	target := comments[i]
+	if target.AuthorID != userID {
+		return ErrNotAuthor
+	}
+	replies := func(parentID string) int {
+		n := 0
+		for _, c := range comments {
+			if c.ParentID == parentID {
+				n++
+			}
+		}
+		return n
+	}
+	// A thread keeps its root while it has replies, and loses a deleted
+	// root with its last reply.
+	tombstone := target.ParentID == "" && replies(target.ID) > 0
+	var deleteParent bool
+	if target.ParentID != "" {
+		p := slices.IndexFunc(comments, func(c Comment) bool { return c.ID == target.ParentID })
+		deleteParent = p >= 0 && comments[p].Deleted && replies(target.ParentID) == 1
+	}
+
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.comments[noteID] = slices.DeleteFunc(s.comments[noteID], func(c Comment) bool {
+			return (c.ID == id && !tombstone) || (c.ID == target.ParentID && deleteParent)
+		})
+		if tombstone {
+			for i, c := range s.comments[noteID] {
+				if c.ID == id {
+					s.comments[noteID][i].Body, s.comments[noteID][i].Deleted = "", true
+				}
+			}
+		}
+		return nil
+	}
+
+	condition := aws.String("author_id = :author_id AND attribute_not_exists(deleted)")
+	values := map[string]types.AttributeValue{":author_id": &types.AttributeValueMemberS{Value: userID}}
+	writes := []types.TransactWriteItem{s.countUpdate(noteID, -1)}
+	if tombstone {
+		values[":true"] = &types.AttributeValueMemberBOOL{Value: true}
+		writes = append(writes, types.TransactWriteItem{Update: &types.Update{
+			TableName:                 aws.String(s.tableName),
+			Key:                       key(noteID, id),
+			UpdateExpression:          aws.String("SET deleted = :true REMOVE body"),
+			ConditionExpression:       condition,
+			ExpressionAttributeValues: values,
+		}})
+	} else {
+		writes = append(writes, types.TransactWriteItem{Delete: &types.Delete{
+			TableName:                 aws.String(s.tableName),
+			Key:                       key(noteID, id),
+			ConditionExpression:       condition,
+			ExpressionAttributeValues: values,
+		}})
+	}
+	if deleteParent {
+		writes = append(writes, types.TransactWriteItem{Delete: &types.Delete{
+			TableName:           aws.String(s.tableName),
+			Key:                 key(noteID, target.ParentID),
+			ConditionExpression: aws.String("attribute_exists(deleted)"),
+		}})
+	}
+	if err := s.transact(ctx, writes); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+	return nil
+}
+
+// Counts returns the number of comments on each of noteIDs that has any.
+// Deleted comments kept as tombstones are not counted.
+func (s *Store) Counts(ctx context.Context, noteIDs []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, id := range noteIDs {
+			for _, c := range s.comments[id] {
+				if !c.Deleted {
+					counts[id]++
+				}
+			}
+		}
+		return counts, nil
+	}
+
+	// BatchGetItem reads at most 100 keys per call.
+	for chunk := range slices.Chunk(noteIDs, 100) {
+		keys := make([]map[string]types.AttributeValue, 0, len(chunk))
+		for _, id := range chunk {
+			keys = append(keys, key(id, countID))
+		}
+		request := map[string]types.KeysAndAttributes{s.tableName: {
+			Keys:                 keys,
+			ProjectionExpression: aws.String("note_id, comment_count"),
+		}}
+		for len(request) > 0 {
+			out, err := s.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: request})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get comment counts: %w", err)
+			}
+			var items []struct {
+				NoteID string `dynamodbav:"note_id"`
+				Count  int    `dynamodbav:"comment_count"`
+			}
+			if err := attributevalue.UnmarshalListOfMaps(out.Responses[s.tableName], &items); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal comment counts: %w", err)
+			}
+			for _, item := range items {
+				if item.Count > 0 {
+					counts[item.NoteID] = item.Count
+				}
+			}
+			request = out.UnprocessedKeys
+		}
+	}
+	return counts, nil
+}
+
+// DeleteNote deletes every comment on noteID, for when the note is deleted.
+func (s *Store) DeleteNote(ctx context.Context, noteID string) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.comments, noteID)
+		return nil
+	}
+
+	var keys []map[string]types.AttributeValue
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String("note_id = :note_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":note_id": &types.AttributeValueMemberS{Value: noteID},
+		},
+		ProjectionExpression: aws.String("note_id, comment_id"),
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query comments: %w", err)
+		}
+		keys = append(keys, out.Items...)
+	}
+
+	// BatchWriteItem writes at most 25 items per call.
+	for chunk := range slices.Chunk(keys, 25) {
+		writes := make([]types.WriteRequest, 0, len(chunk))
+		for _, k := range chunk {
+			writes = append(writes, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: k}})
+		}
+		request := map[string][]types.WriteRequest{s.tableName: writes}
+		for len(request) > 0 {
+			out, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: request})
+			if err != nil {
+				return fmt.Errorf("failed to delete comments: %w", err)
+			}
+			request = out.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+// get returns the live comment id on noteID, or ErrNotFound.
+func (s *Store) get(ctx context.Context, noteID, id string) (*Comment, error) {
+	if id == countID {
+		return nil, ErrNotFound
+	}
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, c := range s.comments[noteID] {
+			if c.ID == id && !c.Deleted {
+				return &c, nil
+			}
+		}
+		return nil, ErrNotFound
+	}
+
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       key(noteID, id),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	if out.Item == nil {
+		return nil, ErrNotFound
+	}
+	var c Comment
+	if err := attributevalue.UnmarshalMap(out.Item, &c); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal comment: %w", err)
+	}
+	if c.Deleted {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
+
+// countUpdate adds delta to the comment count of noteID.
+func (s *Store) countUpdate(noteID string, delta int) types.TransactWriteItem {
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName:        aws.String(s.tableName),
+		Key:              key(noteID, countID),
+		UpdateExpression: aws.String("ADD comment_count :delta"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":delta": &types.AttributeValueMemberN{Value: strconv.Itoa(delta)},
+		},
+	}}
+}
+
+// transact runs writes as one transaction. A failed condition means a
+// comment involved was deleted meanwhile, reported as ErrNotFound.
+func (s *Store) transact(ctx context.Context, writes []types.TransactWriteItem) error {
+	_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: writes})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for _, reason := range canceled.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return ErrNotFound
+			}
+		}
+	}
+	return err
+}
+
+func key(noteID, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"note_id":    &types.AttributeValueMemberS{Value: noteID},
+		"comment_id": &types.AttributeValueMemberS{Value: id},
+	}
+}
@@ -0,0 +1,73 @@
+package comment
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStore_Threads(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+
+	root, _ := s.Add(ctx, Comment{NoteID: "a", AuthorID: "user1", Body: "Typo in the title"})
+	reply, err := s.Add(ctx, Comment{NoteID: "a", ParentID: root.ID, AuthorID: "user2", Body: "Fixed"})
+	if err != nil {
+		t.Fatalf("Add reply: %v", err)
+	}
+	// A reply to a reply joins the same thread.
+	nested, _ := s.Add(ctx, Comment{NoteID: "a", ParentID: reply.ID, AuthorID: "user1", Body: "Thanks"})
+	if nested.ParentID != root.ID {
+		t.Errorf("nested reply ParentID = %q, want %q", nested.ParentID, root.ID)
+	}
+	if _, err := s.Add(ctx, Comment{NoteID: "b", ParentID: root.ID, AuthorID: "user1", Body: "Wrong note"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("reply on another note error = %v, want ErrNotFound", err)
+	}
+
+	comments, _ := s.List(ctx, "a")
+	if len(comments) != 3 || comments[0].ID != root.ID || comments[2].ID != nested.ID {
+		t.Errorf("List = %+v, want the three comments oldest first", comments)
+	}
+	counts, _ := s.Counts(ctx, []string{"a", "b"})
+	if counts["a"] != 3 || counts["b"] != 0 {
+		t.Errorf("Counts = %v", counts)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+
+	root, _ := s.Add(ctx, Comment{NoteID: "a", AuthorID: "user1", Body: "Typo in the title"})
+	reply, _ := s.Add(ctx, Comment{NoteID: "a", ParentID: root.ID, AuthorID: "user2", Body: "Fixed"})
+
+	if err := s.Delete(ctx, "a", root.ID, "user2"); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("Delete by another user error = %v, want ErrNotAuthor", err)
+	}
+
+	// The root has a reply, so it stays as a tombstone.
+	if err := s.Delete(ctx, "a", root.ID, "user1"); err != nil {
+		t.Fatalf("Delete root: %v", err)
+	}
+	comments, _ := s.List(ctx, "a")
+	if len(comments) != 2 || !comments[0].Deleted || comments[0].Body != "" {
+		t.Fatalf("List after deleting the root = %+v, want a tombstone and the reply", comments)
+	}
+	if counts, _ := s.Counts(ctx, []string{"a"}); counts["a"] != 1 {
+		t.Errorf("Counts after deleting the root = %v, want 1", counts)
+	}
+	if _, err := s.Add(ctx, Comment{NoteID: "a", ParentID: root.ID, AuthorID: "user2", Body: "Too late"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("reply to a deleted thread error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "a", root.ID, "user1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+
+	// Deleting the last reply removes the tombstone too.
+	if err := s.Delete(ctx, "a", reply.ID, "user2"); err != nil {
+		t.Fatalf("Delete reply: %v", err)
+	}
+	if comments, _ := s.List(ctx, "a"); len(comments) != 0 {
+		t.Errorf("List after deleting the thread = %+v, want none", comments)
+	}
+}
@@ -0,0 +1,589 @@
+// Package config loads and validates the backend's settings.
+//
+// Settings come from environment variables. Secrets are resolved through a
+// secret.Resolver from the SSM parameter named by <NAME>_PARAM (or, in
+// DEV_MODE, from the <NAME> environment variable). Tests can build a Config
+// directly or call Load with their own getenv and resolver.
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+// DevJWTSecret signs sessions in DEV_MODE when JWT_SECRET is not set.
+// Outside DEV_MODE a missing JWT secret is a configuration error.
+const DevJWTSecret = "default-dev-secret"
+
+// Lock modes accepted in LOCK_MODE.
+const (
+	LockModeExclusive = "exclusive"
+	LockModeAdvisory  = "advisory"
+)
+
+// Config holds every backend setting.
+type Config struct {
+	// AWS is the SDK configuration used to build service clients.
+	AWS aws.Config
+
+	// DevMode enables local development behaviour: in-memory storage,
+	// mock encryption, env-based secrets and no origin verification.
+	DevMode bool
+
+	FrontendURL       string
+	GoogleRedirectURL string
+	GoogleClientID    string
+
+	// CORSAllowedOrigins are the browser origins allowed to call the API,
+	// e.g. a staging and a production frontend. Entries may use a wildcard
+	// subdomain (https://*.example.com) or be "*". Defaults to FrontendURL.
+	CORSAllowedOrigins []string
+
+	// JWTSecret and APIGatewaySecret are needed by every request and are
+	// resolved at startup. GoogleClientSecret is only needed to sign in and
+	// to refresh Drive tokens, so it is resolved on first use.
+	// APIGatewaySecret and GoogleClientSecret are looked up through a cache
+	// that refreshes them every SecretCacheTTL, so rotating them in SSM
+	// needs no redeploy; rotating JWTSecret signs everyone out anyway.
+	JWTSecret          string
+	APIGatewaySecret   *secret.Value // nil in DEV_MODE
+	GoogleClientSecret *secret.Value
+	SecretCacheTTL     time.Duration // 0 means secret.DefaultCacheTTL
+
+	KMSKeyID string
+	// KMSPreviousKeyIDs are retired keys that still decrypt refresh tokens
+	// until they have been re-encrypted under KMSKeyID.
+	KMSPreviousKeyIDs []string
+
+	// SigningKeys keys the tokens signed by crypto.Signer, such as OAuth
+	// state: a comma-separated list whose first entry signs. When nil
+	// (DEV_MODE without a configured value) JWTSecret is used.
+	SigningKeys *secret.Value
+
+	// TokenEncryptionKey encrypts refresh tokens where KMS is not used
+	// (DEV_MODE). When nil a key is derived from JWTSecret.
+	TokenEncryptionKey *crypto.Key
+
+	Tables Tables
+
+	// Reminders configures how due task reminders are sent.
+	Reminders ReminderSettings
+
+	// Summaries configures note summaries, suggestions and translation.
+	Summaries SummarySettings
+
+	// MirrorDir, when set, is a directory that every note written is also
+	// copied to as a backup (see the mirror package). It may be a volume
+	// that is synced to S3 or Dropbox. It cannot be used on Lambda.
+	MirrorDir string
+	// MirrorBucket, when set instead, is an S3 bucket the copies are
+	// written to, under MirrorPrefix. With neither, mirroring is off.
+	MirrorBucket string
+	MirrorPrefix string
+
+	// OnLambda is set when running as a Lambda function, whose disk does
+	// not outlast the execution environment.
+	OnLambda bool
+
+	LockMode         string
+	LockTTL          time.Duration // 0 means session.DefaultPolicy
+	LockMaxDuration  time.Duration // 0 means session.DefaultPolicy
+	EnforceEditLocks bool
+
+	// RequestTimeout bounds each request; on Lambda the invocation deadline
+	// may cut it shorter. 0 means the default.
+	RequestTimeout time.Duration
+
+	// RateLimitPerMinute is the per-user request limit; 0 disables it.
+	RateLimitPerMinute int
+
+	// MaxBodyBytes limits request bodies. Routes that upload note content
+	// or sync data use MaxContentBytes instead. 0 means the default.
+	MaxBodyBytes    int64
+	MaxContentBytes int64
+}
+
+// Tables names the DynamoDB tables. FILE_STORE_TABLE is read by the memory
+// adapter itself.
+type Tables struct {
+	UserTokens      string
+	EditingSessions string
+	ChangeJournal   string
+	Comments        string
+	Drafts          string
+	Publications    string
+	Reminders       string
+	AccessTokens    string
+	GitRemotes      string
+	Mirrors         string
+	Shares          string
+}
+
+// ReminderSettings configures reminder notifications. With neither a
+// webhook nor an SMTP server, reminders are only listed by GET /reminders.
+type ReminderSettings struct {
+	WebhookURL string
+	SMTPAddr   string // host:port
+	EmailFrom  string
+	// SMTPPassword is set when SMTPUsername is, and resolved on first use.
+	SMTPUsername string
+	SMTPPassword *secret.Value
+}
+
+// SummarySettings configures the endpoints that use a language model:
+// POST /notes/{id}/summarize, /suggest and /translate. They are disabled
+// unless ModelID, an Amazon Bedrock model or inference profile ID, is set.
+type SummarySettings struct {
+	ModelID string
+	// PerHour is the per-user limit on those requests together; 0 means no
+	// limit beyond RateLimitPerMinute.
+	PerHour int
+}
+
+// DefaultSummariesPerHour is the per-user summary limit when
+// SUMMARY_LIMIT_PER_HOUR is not set.
+const DefaultSummariesPerHour = 20
+
+// FromEnvironment loads the configuration from the process environment,
+// resolving secrets from SSM, or from environment variables in DEV_MODE.
+func FromEnvironment(ctx context.Context) (*Config, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS SDK config: %w", err)
+	}
+
+	var resolver secret.Resolver
+	if isTrue(os.Getenv("DEV_MODE")) {
+		resolver = secret.NewEnvResolver()
+		slog.Info("Using EnvResolver (DEV_MODE=true)")
+	} else {
+		resolver = secret.NewSSMResolver(ssm.NewFromConfig(awsCfg))
+		slog.Info("Using SSMResolver (SSM Parameter Store)")
+	}
+
+	cfg, err := Load(ctx, os.Getenv, resolver)
+	if err != nil {
+		return nil, err
+	}
+	cfg.AWS = awsCfg
+	return cfg, nil
+}
+
+// Load reads every setting with getenv, resolves secrets with resolver and
+// validates the result. Problems are reported together.
+func Load(ctx context.Context, getenv func(string) string, resolver secret.Resolver) (*Config, error) {
+	var errs []error
+	cfg := &Config{
+		DevMode:        isTrue(getenv("DEV_MODE")),
+		FrontendURL:    orDefault(getenv("FRONTEND_URL"), "http://localhost:3000"),
+		GoogleClientID: getenv("GOOGLE_CLIENT_ID"),
+		KMSKeyID:       orDefault(getenv("KMS_KEY_ID"), "alias/gophdrive-token-key"),
+		Tables: Tables{
+			UserTokens:      orDefault(getenv("USER_TOKENS_TABLE"), "UserTokens"),
+			EditingSessions: orDefault(getenv("EDITING_SESSIONS_TABLE"), "EditingSessions"),
+			ChangeJournal:   orDefault(getenv("CHANGE_JOURNAL_TABLE"), "ChangeJournal"),
+			Comments:        orDefault(getenv("COMMENTS_TABLE"), "Comments"),
+			Drafts:          orDefault(getenv("DRAFTS_TABLE"), "Drafts"),
+			Publications:    orDefault(getenv("PUBLICATIONS_TABLE"), "Publications"),
+			Reminders:       orDefault(getenv("REMINDERS_TABLE"), "Reminders"),
+			AccessTokens:    orDefault(getenv("ACCESS_TOKENS_TABLE"), "AccessTokens"),
+			GitRemotes:      orDefault(getenv("GIT_REMOTES_TABLE"), "GitRemotes"),
+			Mirrors:         orDefault(getenv("MIRRORS_TABLE"), "Mirrors"),
+			Shares:          orDefault(getenv("SHARES_TABLE"), "Shares"),
+		},
+		Reminders: ReminderSettings{
+			WebhookURL:   getenv("REMINDER_WEBHOOK_URL"),
+			SMTPAddr:     getenv("REMINDER_SMTP_ADDR"),
+			EmailFrom:    getenv("REMINDER_EMAIL_FROM"),
+			SMTPUsername: getenv("REMINDER_SMTP_USERNAME"),
+		},
+		Summaries: SummarySettings{
+			ModelID: getenv("SUMMARY_MODEL_ID"),
+			PerHour: DefaultSummariesPerHour,
+		},
+		MirrorDir:        getenv("MIRROR_DIR"),
+		MirrorBucket:     getenv("MIRROR_S3_BUCKET"),
+		MirrorPrefix:     getenv("MIRROR_S3_PREFIX"),
+		OnLambda:         getenv("AWS_LAMBDA_FUNCTION_NAME") != "",
+		LockMode:         orDefault(getenv("LOCK_MODE"), LockModeExclusive),
+		EnforceEditLocks: isTrue(getenv("ENFORCE_EDIT_LOCKS")),
+	}
+
+	cfg.KMSPreviousKeyIDs = splitList(getenv("KMS_PREVIOUS_KEY_IDS"))
+
+	cfg.CORSAllowedOrigins = splitList(getenv("CORS_ALLOWED_ORIGINS"))
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		cfg.CORSAllowedOrigins = []string{cfg.FrontendURL}
+	}
+
+	cfg.GoogleRedirectURL = getenv("GOOGLE_REDIRECT_URL")
+	if cfg.GoogleRedirectURL == "" {
+		if cfg.DevMode {
+			cfg.GoogleRedirectURL = "http://localhost:8080/auth/callback"
+		} else {
+			cfg.GoogleRedirectURL = cfg.FrontendURL + "/api/auth/callback"
+		}
+	}
+
+	durationSetting := func(name string) time.Duration {
+		raw := getenv(name)
+		if raw == "" {
+			if param := getenv(name + "_PARAM"); param != "" {
+				v, err := resolver.GetSecret(ctx, param)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+					return 0
+				}
+				raw = v
+			}
+		}
+		if raw == "" {
+			return 0
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		return d
+	}
+	// Cache secrets so that requests needing one do not each go to SSM and
+	// rotated values are picked up within the TTL.
+	cfg.SecretCacheTTL = durationSetting("SECRET_CACHE_TTL")
+	resolver = secret.NewCachingResolver(resolver, cfg.SecretCacheTTL)
+
+	secretSetting := func(name, param string) string {
+		v, err := resolver.GetSecret(ctx, param)
+		if err != nil {
+			// Validate reports the missing value where it is required.
+			slog.Warn("Failed to resolve secret", "name", name, "error", err)
+		}
+		return v
+	}
+	// Resolve the startup secrets concurrently: on a cold start each SSM
+	// lookup is a round trip.
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		cfg.JWTSecret = secretSetting("JWT_SECRET", orDefault(getenv("JWT_SECRET_PARAM"), "/gophdrive/jwt-secret"))
+	})
+	if !cfg.DevMode {
+		wg.Go(func() {
+			param := orDefault(getenv("API_GATEWAY_SECRET_PARAM"), "/gophdrive/api-gateway-secret")
+			if secretSetting("API_GATEWAY_SECRET", param) != "" {
+				cfg.APIGatewaySecret = secret.NewValue(resolver, param)
+			}
+		})
+	}
+	wg.Wait()
+	cfg.GoogleClientSecret = secret.NewValue(resolver, orDefault(getenv("GOOGLE_CLIENT_SECRET_PARAM"), "/gophdrive/google-client-secret"))
+	if cfg.JWTSecret == "" && cfg.DevMode {
+		cfg.JWTSecret = DevJWTSecret
+	}
+	signingKeysParam := orDefault(getenv("SIGNING_KEYS_PARAM"), "/gophdrive/signing-keys")
+	if !cfg.DevMode {
+		cfg.SigningKeys = secret.NewValue(resolver, signingKeysParam)
+	} else if _, err := resolver.GetSecret(ctx, signingKeysParam); err == nil {
+		cfg.SigningKeys = secret.NewValue(resolver, signingKeysParam)
+	}
+	if cfg.Reminders.SMTPUsername != "" {
+		cfg.Reminders.SMTPPassword = secret.NewValue(resolver, orDefault(getenv("REMINDER_SMTP_PASSWORD_PARAM"), "/gophdrive/reminder-smtp-password"))
+	}
+	if cfg.DevMode {
+		param := orDefault(getenv("TOKEN_ENCRYPTION_KEY_PARAM"), "/gophdrive/token-encryption-key")
+		if raw, err := resolver.GetSecret(ctx, param); err == nil {
+			key, err := crypto.ParseKey(raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("TOKEN_ENCRYPTION_KEY: %w", err))
+			}
+			cfg.TokenEncryptionKey = &key
+		}
+	}
+
+	cfg.LockTTL = durationSetting("LOCK_TTL")
+	cfg.LockMaxDuration = durationSetting("LOCK_MAX_DURATION")
+	cfg.RequestTimeout = durationSetting("REQUEST_TIMEOUT")
+
+	if raw := getenv("RATE_LIMIT_PER_MINUTE"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_PER_MINUTE: %w", err))
+		}
+		cfg.RateLimitPerMinute = n
+	}
+
+	if raw := getenv("SUMMARY_LIMIT_PER_HOUR"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("SUMMARY_LIMIT_PER_HOUR: %w", err))
+		}
+		cfg.Summaries.PerHour = n
+	}
+
+	sizeSetting := func(name string) int64 {
+		raw := getenv(name)
+		if raw == "" {
+			return 0
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		return n
+	}
+	cfg.MaxBodyBytes = sizeSetting("MAX_BODY_BYTES")
+	cfg.MaxContentBytes = sizeSetting("MAX_CONTENT_BYTES")
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+// Validate checks settings that must hold whatever their source. Outside
+// DEV_MODE the OAuth client and every secret are required; the Google client
+// secret can only be checked once it is first used.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.DevMode {
+		if c.JWTSecret == "" {
+			errs = append(errs, errors.New("JWT_SECRET is empty"))
+		}
+	} else {
+		required := []struct{ name, value string }{
+			{"GOOGLE_CLIENT_ID", c.GoogleClientID},
+			{"JWT_SECRET", c.JWTSecret},
+		}
+		for _, r := range required {
+			if r.value == "" {
+				errs = append(errs, fmt.Errorf("%s is required outside DEV_MODE", r.name))
+			}
+		}
+		if c.APIGatewaySecret == nil {
+			errs = append(errs, errors.New("API_GATEWAY_SECRET is required outside DEV_MODE"))
+		}
+		if c.GoogleClientSecret == nil {
+			errs = append(errs, errors.New("GOOGLE_CLIENT_SECRET is required outside DEV_MODE"))
+		}
+		if c.SigningKeys == nil {
+			errs = append(errs, errors.New("SIGNING_KEYS is required outside DEV_MODE"))
+		}
+		if c.JWTSecret == DevJWTSecret {
+			errs = append(errs, errors.New("JWT_SECRET must not be the development default outside DEV_MODE"))
+		}
+	}
+	if c.FrontendURL == "" || c.GoogleRedirectURL == "" {
+		errs = append(errs, errors.New("FRONTEND_URL and GOOGLE_REDIRECT_URL must not be empty"))
+	}
+	for _, o := range c.CORSAllowedOrigins {
+		if err := validateOrigin(o); err != nil {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS: %w", err))
+		}
+	}
+	for _, id := range c.KMSPreviousKeyIDs {
+		if strings.HasPrefix(id, "alias/") {
+			errs = append(errs, fmt.Errorf("KMS_PREVIOUS_KEY_IDS: %q is an alias; use the key ID or ARN", id))
+		}
+	}
+	for _, t := range []string{c.Tables.UserTokens, c.Tables.EditingSessions, c.Tables.ChangeJournal, c.Tables.Comments, c.Tables.Drafts, c.Tables.Publications, c.Tables.Reminders, c.Tables.AccessTokens, c.Tables.GitRemotes, c.Tables.Mirrors, c.Tables.Shares} {
+		if t == "" {
+			errs = append(errs, errors.New("DynamoDB table names must not be empty"))
+			break
+		}
+	}
+	if u := c.Reminders.WebhookURL; u != "" {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("REMINDER_WEBHOOK_URL %q must be an http(s) URL", u))
+		}
+	}
+	if c.Reminders.SMTPAddr != "" && c.Reminders.EmailFrom == "" {
+		errs = append(errs, errors.New("REMINDER_EMAIL_FROM is required with REMINDER_SMTP_ADDR"))
+	}
+	if c.MirrorDir != "" && !filepath.IsAbs(c.MirrorDir) {
+		errs = append(errs, fmt.Errorf("MIRROR_DIR %q must be an absolute path", c.MirrorDir))
+	}
+	if c.MirrorDir != "" && c.MirrorBucket != "" {
+		errs = append(errs, errors.New("set only one of MIRROR_DIR and MIRROR_S3_BUCKET"))
+	}
+	if c.MirrorDir != "" && c.OnLambda {
+		errs = append(errs, errors.New("MIRROR_DIR cannot be used on Lambda, whose disk does not last; set MIRROR_S3_BUCKET instead"))
+	}
+	if c.MirrorPrefix != "" && c.MirrorBucket == "" {
+		errs = append(errs, errors.New("MIRROR_S3_PREFIX requires MIRROR_S3_BUCKET"))
+	}
+	if c.LockMode != LockModeExclusive && c.LockMode != LockModeAdvisory {
+		errs = append(errs, fmt.Errorf("LOCK_MODE %q must be %q or %q", c.LockMode, LockModeExclusive, LockModeAdvisory))
+	}
+	if c.LockTTL < 0 || c.LockMaxDuration < 0 {
+		errs = append(errs, errors.New("LOCK_TTL and LOCK_MAX_DURATION must not be negative"))
+	}
+	if c.RequestTimeout < 0 {
+		errs = append(errs, errors.New("REQUEST_TIMEOUT must not be negative"))
+	}
+	if c.SecretCacheTTL < 0 {
+		errs = append(errs, errors.New("SECRET_CACHE_TTL must not be negative"))
+	}
+	if c.RateLimitPerMinute < 0 {
+		errs = append(errs, errors.New("RATE_LIMIT_PER_MINUTE must not be negative"))
+	}
+	if c.Summaries.PerHour < 0 {
+		errs = append(errs, errors.New("SUMMARY_LIMIT_PER_HOUR must not be negative"))
+	}
+	if c.MaxBodyBytes < 0 || c.MaxContentBytes < 0 {
+		errs = append(errs, errors.New("MAX_BODY_BYTES and MAX_CONTENT_BYTES must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// String lists the effective settings with secrets masked, one per line.
+func (c *Config) String() string {
+	var b strings.Builder
+	line := func(name string, v any) {
+		fmt.Fprintf(&b, "  %-24s %v\n", name, v)
+	}
+	line("DEV_MODE", c.DevMode)
+	line("FRONTEND_URL", c.FrontendURL)
+	line("CORS_ALLOWED_ORIGINS", strings.Join(c.CORSAllowedOrigins, ","))
+	line("GOOGLE_REDIRECT_URL", c.GoogleRedirectURL)
+	line("GOOGLE_CLIENT_ID", orDefault(c.GoogleClientID, "(unset)"))
+	if c.GoogleClientSecret != nil {
+		line("GOOGLE_CLIENT_SECRET", "(resolved on first use)")
+	} else {
+		line("GOOGLE_CLIENT_SECRET", "(unset)")
+	}
+	line("JWT_SECRET", mask(c.JWTSecret))
+	if c.APIGatewaySecret != nil {
+		line("API_GATEWAY_SECRET", "(set)")
+	} else {
+		line("API_GATEWAY_SECRET", "(unset)")
+	}
+	line("SECRET_CACHE_TTL", durationOrDefault(c.SecretCacheTTL))
+	if c.SigningKeys != nil {
+		line("SIGNING_KEYS", "(resolved on first use)")
+	} else {
+		line("SIGNING_KEYS", "(JWT_SECRET)")
+	}
+	if c.DevMode {
+		if c.TokenEncryptionKey != nil {
+			line("TOKEN_ENCRYPTION_KEY", "(set)")
+		} else {
+			line("TOKEN_ENCRYPTION_KEY", "(derived from JWT_SECRET)")
+		}
+	} else {
+		line("KMS_KEY_ID", c.KMSKeyID)
+		line("KMS_PREVIOUS_KEY_IDS", strings.Join(c.KMSPreviousKeyIDs, ","))
+	}
+	line("USER_TOKENS_TABLE", c.Tables.UserTokens)
+	line("EDITING_SESSIONS_TABLE", c.Tables.EditingSessions)
+	line("CHANGE_JOURNAL_TABLE", c.Tables.ChangeJournal)
+	line("COMMENTS_TABLE", c.Tables.Comments)
+	line("DRAFTS_TABLE", c.Tables.Drafts)
+	line("PUBLICATIONS_TABLE", c.Tables.Publications)
+	line("REMINDERS_TABLE", c.Tables.Reminders)
+	line("ACCESS_TOKENS_TABLE", c.Tables.AccessTokens)
+	line("GIT_REMOTES_TABLE", c.Tables.GitRemotes)
+	line("MIRRORS_TABLE", c.Tables.Mirrors)
+	line("SHARES_TABLE", c.Tables.Shares)
+	line("REMINDER_WEBHOOK_URL", orDefault(c.Reminders.WebhookURL, "(unset)"))
+	line("REMINDER_SMTP_ADDR", orDefault(c.Reminders.SMTPAddr, "(unset)"))
+	if c.Reminders.SMTPAddr != "" {
+		line("REMINDER_EMAIL_FROM", c.Reminders.EmailFrom)
+		line("REMINDER_SMTP_USERNAME", orDefault(c.Reminders.SMTPUsername, "(unset)"))
+	}
+	line("SUMMARY_MODEL_ID", orDefault(c.Summaries.ModelID, "(unset)"))
+	if c.Summaries.ModelID != "" {
+		line("SUMMARY_LIMIT_PER_HOUR", c.Summaries.PerHour)
+	}
+	line("MIRROR_DIR", orDefault(c.MirrorDir, "(unset)"))
+	line("MIRROR_S3_BUCKET", orDefault(c.MirrorBucket, "(unset)"))
+	if c.MirrorBucket != "" {
+		line("MIRROR_S3_PREFIX", c.MirrorPrefix)
+	}
+	line("LOCK_MODE", c.LockMode)
+	line("LOCK_TTL", durationOrDefault(c.LockTTL))
+	line("LOCK_MAX_DURATION", durationOrDefault(c.LockMaxDuration))
+	line("ENFORCE_EDIT_LOCKS", c.EnforceEditLocks)
+	line("REQUEST_TIMEOUT", durationOrDefault(c.RequestTimeout))
+	line("RATE_LIMIT_PER_MINUTE", c.RateLimitPerMinute)
+	line("MAX_BODY_BYTES", sizeOrDefault(c.MaxBodyBytes))
+	line("MAX_CONTENT_BYTES", sizeOrDefault(c.MaxContentBytes))
+	return b.String()
+}
+
+// validateOrigin checks that o is "*" or scheme://host[:port], where the
+// host may start with "*." to allow its subdomains.
+func validateOrigin(o string) error {
+	if o == "*" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(o, "://*.", "://wildcard.", 1))
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil || strings.Contains(u.Host, "*") {
+		return fmt.Errorf("%q is not an origin like https://app.example.com or https://*.example.com", o)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated setting, dropping empty entries and
+// trailing slashes.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSuffix(strings.TrimSpace(v), "/"); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func mask(secret string) string {
+	switch {
+	case secret == "":
+		return "(unset)"
+	case secret == DevJWTSecret:
+		return "(development default)"
+	default:
+		return fmt.Sprintf("(set, %d chars)", len(secret))
+	}
+}
+
+func durationOrDefault(d time.Duration) string {
+	if d == 0 {
+		return "(default)"
+	}
+	return d.String()
+}
+
+func sizeOrDefault(n int64) string {
+	if n == 0 {
+		return "(default)"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func isTrue(s string) bool {
+	return s == "true"
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+type fakeResolver map[string]string
+
+func (f fakeResolver) GetSecret(_ context.Context, name string) (string, error) {
+	v, ok := f[name]
+	if !ok {
+		return "", fmt.Errorf("parameter not found: %s", name)
+	}
+	return v, nil
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+var prodSecrets = fakeResolver{
+	"/gophdrive/google-client-secret": "google-secret",
+	"/gophdrive/jwt-secret":           "jwt-secret",
+	"/gophdrive/api-gateway-secret":   "origin-secret",
+	"/custom/lock-ttl":                "90s",
+}
+
+func TestLoad_Production(t *testing.T) {
+	cfg, err := Load(context.Background(), env(map[string]string{
+		"GOOGLE_CLIENT_ID":      "client-id",
+		"FRONTEND_URL":          "https://notes.example.com",
+		"USER_TOKENS_TABLE":     "Tokens",
+		"LOCK_TTL_PARAM":        "/custom/lock-ttl",
+		"LOCK_MODE":             "advisory",
+		"RATE_LIMIT_PER_MINUTE": "120",
+		"MAX_CONTENT_BYTES":     "1048576",
+		"SUMMARY_MODEL_ID":      "anthropic.claude-3-haiku-20240307-v1:0",
+	}), prodSecrets)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.JWTSecret != "jwt-secret" {
+		t.Errorf("JWTSecret = %q", cfg.JWTSecret)
+	}
+	if got, err := cfg.APIGatewaySecret.Get(context.Background()); got != "origin-secret" {
+		t.Errorf("APIGatewaySecret = %q, %v", got, err)
+	}
+	if got, err := cfg.GoogleClientSecret.Get(context.Background()); got != "google-secret" {
+		t.Errorf("GoogleClientSecret = %q, %v", got, err)
+	}
+	if cfg.GoogleRedirectURL != "https://notes.example.com/api/auth/callback" {
+		t.Errorf("GoogleRedirectURL = %q", cfg.GoogleRedirectURL)
+	}
+	if cfg.Tables.UserTokens != "Tokens" || cfg.Tables.EditingSessions != "EditingSessions" || cfg.Tables.AccessTokens != "AccessTokens" {
+		t.Errorf("Tables = %+v", cfg.Tables)
+	}
+	if cfg.LockTTL != 90*time.Second || cfg.LockMode != LockModeAdvisory || cfg.RateLimitPerMinute != 120 {
+		t.Errorf("lock/rate settings = %s %s %d", cfg.LockTTL, cfg.LockMode, cfg.RateLimitPerMinute)
+	}
+	if cfg.MaxBodyBytes != 0 || cfg.MaxContentBytes != 1<<20 {
+		t.Errorf("body limits = %d %d", cfg.MaxBodyBytes, cfg.MaxContentBytes)
+	}
+	if cfg.Summaries.ModelID != "anthropic.claude-3-haiku-20240307-v1:0" || cfg.Summaries.PerHour != DefaultSummariesPerHour {
+		t.Errorf("Summaries = %+v", cfg.Summaries)
+	}
+}
+
+func TestLoad_ProductionFailsFastOnMissingSecrets(t *testing.T) {
+	_, err := Load(context.Background(), env(map[string]string{}), fakeResolver{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"GOOGLE_CLIENT_ID", "JWT_SECRET", "API_GATEWAY_SECRET"} {
+		if !strings.Contains(err.Error(), want+" is required") {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestLoad_GoogleClientSecretResolvedOnFirstUse(t *testing.T) {
+	resolver := &countingResolver{secrets: fakeResolver{
+		"/gophdrive/jwt-secret":         "jwt-secret",
+		"/gophdrive/api-gateway-secret": "origin-secret",
+	}}
+	cfg, err := Load(context.Background(), env(map[string]string{"GOOGLE_CLIENT_ID": "client-id"}), resolver)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if resolver.calls["/gophdrive/google-client-secret"] != 0 {
+		t.Error("Load resolved the Google client secret")
+	}
+
+	// A failed lookup is retried on the next use; a successful one is cached.
+	if _, err := cfg.GoogleClientSecret.Get(context.Background()); err == nil {
+		t.Fatal("expected an error while the parameter is missing")
+	}
+	resolver.secrets["/gophdrive/google-client-secret"] = "google-secret"
+	for range 2 {
+		if got, err := cfg.GoogleClientSecret.Get(context.Background()); got != "google-secret" || err != nil {
+			t.Fatalf("Get = %q, %v", got, err)
+		}
+	}
+	if n := resolver.calls["/gophdrive/google-client-secret"]; n != 2 {
+		t.Errorf("resolved %d times, want 2", n)
+	}
+}
+
+type countingResolver struct {
+	mu      sync.Mutex
+	secrets fakeResolver
+	calls   map[string]int
+}
+
+func (r *countingResolver) GetSecret(ctx context.Context, name string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.calls == nil {
+		r.calls = map[string]int{}
+	}
+	r.calls[name]++
+	return r.secrets.GetSecret(ctx, name)
+}
+
+func TestLoad_DevModeDefaults(t *testing.T) {
+	cfg, err := Load(context.Background(), env(map[string]string{"DEV_MODE": "true"}), fakeResolver{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.JWTSecret != DevJWTSecret {
+		t.Errorf("JWTSecret = %q, want the development default", cfg.JWTSecret)
+	}
+	if cfg.GoogleRedirectURL != "http://localhost:8080/auth/callback" || cfg.FrontendURL != "http://localhost:3000" {
+		t.Errorf("URLs = %q %q", cfg.GoogleRedirectURL, cfg.FrontendURL)
+	}
+	if cfg.LockMode != LockModeExclusive || cfg.LockTTL != 0 {
+		t.Errorf("lock settings = %s %s", cfg.LockMode, cfg.LockTTL)
+	}
+	if len(cfg.CORSAllowedOrigins) != 1 || cfg.CORSAllowedOrigins[0] != "http://localhost:3000" {
+		t.Errorf("CORSAllowedOrigins = %v, want FRONTEND_URL", cfg.CORSAllowedOrigins)
+	}
+	if cfg.TokenEncryptionKey != nil {
+		t.Error("TokenEncryptionKey is set without TOKEN_ENCRYPTION_KEY")
+	}
+	if cfg.SigningKeys != nil {
+		t.Error("SigningKeys is set without SIGNING_KEYS")
+	}
+}
+
+func TestLoad_TokenEncryptionKey(t *testing.T) {
+	key := strings.Repeat("A", 43) + "="
+	cfg, err := Load(context.Background(), env(map[string]string{"DEV_MODE": "true"}), fakeResolver{"/gophdrive/token-encryption-key": key})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.TokenEncryptionKey == nil || *cfg.TokenEncryptionKey != (crypto.Key{}) {
+		t.Errorf("TokenEncryptionKey = %v, want the all-zero key", cfg.TokenEncryptionKey)
+	}
+
+	_, err = Load(context.Background(), env(map[string]string{"DEV_MODE": "true"}), fakeResolver{"/gophdrive/token-encryption-key": "short"})
+	if err == nil || !strings.Contains(err.Error(), "TOKEN_ENCRYPTION_KEY") {
+		t.Errorf("err = %v, want TOKEN_ENCRYPTION_KEY rejected", err)
+	}
+}
+
+func TestLoad_CORSAllowedOrigins(t *testing.T) {
+	cfg, err := Load(context.Background(), env(map[string]string{
+		"DEV_MODE":             "true",
+		"CORS_ALLOWED_ORIGINS": " https://notes.example.com/, https://*.staging.example.com ,",
+	}), fakeResolver{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []string{"https://notes.example.com", "https://*.staging.example.com"}
+	if !slices.Equal(cfg.CORSAllowedOrigins, want) {
+		t.Errorf("CORSAllowedOrigins = %v, want %v", cfg.CORSAllowedOrigins, want)
+	}
+
+	_, err = Load(context.Background(), env(map[string]string{
+		"DEV_MODE":             "true",
+		"CORS_ALLOWED_ORIGINS": "https://notes.example.com/app,notes.example.com",
+	}), fakeResolver{})
+	if err == nil || strings.Count(err.Error(), "CORS_ALLOWED_ORIGINS") != 2 {
+		t.Errorf("Load error = %v, want both origins rejected", err)
+	}
+}
+
+func TestLoad_KMSPreviousKeyIDs(t *testing.T) {
+	vars := map[string]string{
+		"GOOGLE_CLIENT_ID":     "client-id",
+		"KMS_PREVIOUS_KEY_IDS": "1234abcd-12ab-34cd-56ef-1234567890ab, arn:aws:kms:us-east-1:111122223333:key/old",
+	}
+	cfg, err := Load(context.Background(), env(vars), prodSecrets)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []string{"1234abcd-12ab-34cd-56ef-1234567890ab", "arn:aws:kms:us-east-1:111122223333:key/old"}
+	if !slices.Equal(cfg.KMSPreviousKeyIDs, want) {
+		t.Errorf("KMSPreviousKeyIDs = %v, want %v", cfg.KMSPreviousKeyIDs, want)
+	}
+
+	vars["KMS_PREVIOUS_KEY_IDS"] = "alias/gophdrive-token-key-old"
+	if _, err := Load(context.Background(), env(vars), prodSecrets); err == nil || !strings.Contains(err.Error(), "KMS_PREVIOUS_KEY_IDS") {
+		t.Errorf("Load error = %v, want the alias rejected", err)
+	}
+}
+
+func TestLoad_ReminderSettings(t *testing.T) {
+	cfg, err := Load(context.Background(), env(map[string]string{
+		"DEV_MODE":               "true",
+		"REMINDER_WEBHOOK_URL":   "https://hooks.example.com/reminders",
+		"REMINDER_SMTP_ADDR":     "smtp.example.com:587",
+		"REMINDER_EMAIL_FROM":    "reminders@example.com",
+		"REMINDER_SMTP_USERNAME": "mailer",
+	}), fakeResolver{"/gophdrive/reminder-smtp-password": "smtp-secret"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Reminders.WebhookURL != "https://hooks.example.com/reminders" || cfg.Reminders.EmailFrom != "reminders@example.com" {
+		t.Errorf("Reminders = %+v", cfg.Reminders)
+	}
+	if cfg.Reminders.SMTPPassword == nil {
+		t.Fatal("SMTPPassword is not set with REMINDER_SMTP_USERNAME")
+	}
+	if got, _ := cfg.Reminders.SMTPPassword.Get(context.Background()); got != "smtp-secret" {
+		t.Errorf("SMTPPassword = %q", got)
+	}
+	if strings.Contains(cfg.String(), "smtp-secret") {
+		t.Error("String leaks the SMTP password")
+	}
+}
+
+func TestLoad_MirrorSettings(t *testing.T) {
+	lambda := map[string]string{"DEV_MODE": "true", "AWS_LAMBDA_FUNCTION_NAME": "gophdrive-api"}
+
+	lambda["MIRROR_S3_BUCKET"] = "gophdrive-backup"
+	lambda["MIRROR_S3_PREFIX"] = "notes/"
+	cfg, err := Load(context.Background(), env(lambda), fakeResolver{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.OnLambda || cfg.MirrorBucket != "gophdrive-backup" || cfg.MirrorPrefix != "notes/" {
+		t.Errorf("OnLambda = %v, MirrorBucket = %q, MirrorPrefix = %q", cfg.OnLambda, cfg.MirrorBucket, cfg.MirrorPrefix)
+	}
+
+	// A local directory does not outlive a Lambda execution environment.
+	delete(lambda, "MIRROR_S3_BUCKET")
+	delete(lambda, "MIRROR_S3_PREFIX")
+	lambda["MIRROR_DIR"] = "/tmp/backup"
+	if _, err := Load(context.Background(), env(lambda), fakeResolver{}); err == nil || !strings.Contains(err.Error(), "MIRROR_DIR cannot be used on Lambda") {
+		t.Errorf("MIRROR_DIR on Lambda error = %v", err)
+	}
+}
+
+func TestLoad_InvalidValues(t *testing.T) {
+	_, err := Load(context.Background(), env(map[string]string{
+		"DEV_MODE":               "true",
+		"LOCK_TTL":               "soon",
+		"LOCK_MODE":              "strict",
+		"RATE_LIMIT_PER_MINUTE":  "-1",
+		"MAX_BODY_BYTES":         "1MB",
+		"MAX_CONTENT_BYTES":      "-5",
+		"REQUEST_TIMEOUT":        "-1s",
+		"SECRET_CACHE_TTL":       "-1m",
+		"REMINDER_WEBHOOK_URL":   "hooks.example.com",
+		"REMINDER_SMTP_ADDR":     "smtp.example.com:587",
+		"SUMMARY_LIMIT_PER_HOUR": "-2",
+		"MIRROR_DIR":             "backup",
+		"MIRROR_S3_BUCKET":       "gophdrive-backup",
+	}), fakeResolver{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"LOCK_TTL", `LOCK_MODE "strict"`, "RATE_LIMIT_PER_MINUTE", "MAX_BODY_BYTES: ", "MAX_CONTENT_BYTES must not be negative", "REQUEST_TIMEOUT", "SECRET_CACHE_TTL", "REMINDER_WEBHOOK_URL", "REMINDER_EMAIL_FROM", "SUMMARY_LIMIT_PER_HOUR", "MIRROR_DIR", "only one of MIRROR_DIR and MIRROR_S3_BUCKET"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestConfig_StringMasksSecrets(t *testing.T) {
+	cfg := &Config{JWTSecret: "jwt-secret", GoogleClientSecret: secret.Static("google-secret")}
+	s := cfg.String()
+	if strings.Contains(s, "jwt-secret") || strings.Contains(s, "google-secret") {
+		t.Errorf("String leaks a secret:\n%s", s)
+	}
+	if !strings.Contains(s, "(set, 10 chars)") || !strings.Contains(s, "API_GATEWAY_SECRET") {
+		t.Errorf("String = \n%s", s)
+	}
+}
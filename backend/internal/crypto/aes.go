@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// aesPrefix marks AESEncryptor values bound to an encryption context.
+// Values without it were written before contexts existed.
+const aesPrefix = "aes2:"
+
+// Key is an AES-256 key.
+type Key [32]byte
+
+// ParseKey decodes a base64-encoded 32-byte key, e.g. the output of
+// `openssl rand -base64 32`.
+func ParseKey(s string) (Key, error) {
+	var key Key
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return key, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	if len(raw) != len(key) {
+		return key, fmt.Errorf("key is %d bytes, want %d", len(raw), len(key))
+	}
+	copy(key[:], raw)
+	return key, nil
+}
+
+// DeriveKey derives a key for purpose from secret with HKDF-SHA256, so one
+// secret can yield independent keys for different uses.
+func DeriveKey(secret, purpose string) Key {
+	var key Key
+	raw, err := hkdf.Key(sha256.New, []byte(secret), nil, purpose, len(key))
+	if err != nil {
+		// Only possible for lengths HKDF-SHA256 cannot produce.
+		panic(err)
+	}
+	copy(key[:], raw)
+	return key
+}
+
+// AESEncryptor implements Encryptor with AES-256-GCM and a local key, for
+// environments without KMS. Each value gets a random nonce; ciphertexts are
+// "aes2:" + base64(nonce || sealed data || tag), with the encryption context
+// as additional data.
+type AESEncryptor struct {
+	aead cipher.AEAD
+}
+
+// NewAESEncryptor returns an AESEncryptor using key.
+func NewAESEncryptor(key Key) *AESEncryptor {
+	return &AESEncryptor{aead: newAEAD(key)}
+}
+
+// newAEAD returns AES-256-GCM with key that generates a random nonce for
+// each Seal and prepends it to the output.
+func newAEAD(key Key) cipher.AEAD {
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		// aes.NewCipher only rejects invalid key sizes.
+		panic(err)
+	}
+	aead, err := cipher.NewGCMWithRandomNonce(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// Encrypt seals plaintext bound to encCtx and returns it base64-encoded.
+func (e *AESEncryptor) Encrypt(_ context.Context, plaintext string, encCtx EncryptionContext) (string, error) {
+	sealed := e.aead.Seal(nil, nil, []byte(plaintext), encCtx.aad())
+	return aesPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value returned by Encrypt with the same encCtx.
+func (e *AESEncryptor) Decrypt(ctx context.Context, ciphertext string, encCtx EncryptionContext) (string, error) {
+	plaintext, _, err := e.DecryptStale(ctx, ciphertext, encCtx)
+	return plaintext, err
+}
+
+// DecryptStale decrypts ciphertext and reports whether it predates
+// encryption contexts and should be re-encrypted.
+func (e *AESEncryptor) DecryptStale(_ context.Context, ciphertext string, encCtx EncryptionContext) (string, bool, error) {
+	encoded, bound := strings.CutPrefix(ciphertext, aesPrefix)
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+	var aad []byte
+	if bound {
+		aad = encCtx.aad()
+	}
+	plaintext, err := e.aead.Open(nil, nil, sealed, aad)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	return string(plaintext), !bound, nil
+}
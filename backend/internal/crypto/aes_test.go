@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var testContext = EncryptionContext{"user_id": "user1", "purpose": "test"}
+
+func TestAESEncryptor_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	e := NewAESEncryptor(DeriveKey("secret", "test"))
+
+	first, err := e.Encrypt(ctx, "refresh-token", testContext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if strings.Contains(first, "refresh-token") {
+		t.Errorf("ciphertext %q contains the plaintext", first)
+	}
+	second, _ := e.Encrypt(ctx, "refresh-token", testContext)
+	if first == second {
+		t.Error("two encryptions of the same value are identical; nonces are not random")
+	}
+	for _, c := range []string{first, second} {
+		if got, err := e.Decrypt(ctx, c, testContext); got != "refresh-token" || err != nil {
+			t.Errorf("Decrypt = %q, %v", got, err)
+		}
+	}
+}
+
+func TestAESEncryptor_RejectsWrongKeyAndTampering(t *testing.T) {
+	ctx := context.Background()
+	e := NewAESEncryptor(DeriveKey("secret", "test"))
+	c, _ := e.Encrypt(ctx, "refresh-token", testContext)
+
+	if _, err := NewAESEncryptor(DeriveKey("secret", "other")).Decrypt(ctx, c, testContext); err == nil {
+		t.Error("decrypted with a different key")
+	}
+	if _, err := e.Decrypt(ctx, c, EncryptionContext{"user_id": "user2", "purpose": "test"}); err == nil {
+		t.Error("decrypted with a different encryption context")
+	}
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(c, aesPrefix))
+	raw[len(raw)-1] ^= 1
+	if _, err := e.Decrypt(ctx, aesPrefix+base64.StdEncoding.EncodeToString(raw), testContext); err == nil {
+		t.Error("decrypted a tampered ciphertext")
+	}
+}
+
+func TestAESEncryptor_UnboundValuesAreStale(t *testing.T) {
+	ctx := context.Background()
+	key := DeriveKey("secret", "test")
+	e := NewAESEncryptor(key)
+
+	// Written before encryption contexts: no prefix, no additional data.
+	legacy := base64.StdEncoding.EncodeToString(newAEAD(key).Seal(nil, nil, []byte("refresh-token"), nil))
+	got, stale, err := e.DecryptStale(ctx, legacy, testContext)
+	if got != "refresh-token" || !stale || err != nil {
+		t.Errorf("DecryptStale(legacy) = %q, %v, %v", got, stale, err)
+	}
+
+	c, _ := e.Encrypt(ctx, "refresh-token", testContext)
+	if _, stale, err := e.DecryptStale(ctx, c, testContext); stale || err != nil {
+		t.Errorf("DecryptStale(current) stale = %v, %v", stale, err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	valid := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	if _, err := ParseKey(valid); err != nil {
+		t.Errorf("ParseKey(32 bytes): %v", err)
+	}
+	for _, s := range []string{"not base64!", base64.StdEncoding.EncodeToString(make([]byte, 16))} {
+		if _, err := ParseKey(s); err == nil {
+			t.Errorf("ParseKey(%q) succeeded", s)
+		}
+	}
+}
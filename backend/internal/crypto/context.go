@@ -0,0 +1,26 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"maps"
+	"slices"
+)
+
+// EncryptionContext is non-secret data bound to a ciphertext, such as the
+// user it belongs to. Decrypting requires the same context, so a value
+// cannot be moved to another user's record; with KMS it also appears in
+// CloudTrail, showing whose data each call decrypted.
+type EncryptionContext map[string]string
+
+// aad serializes c for use as AEAD additional data: each key and value,
+// length-prefixed, in key order. A nil or empty context gives nil.
+func (c EncryptionContext) aad() []byte {
+	var out []byte
+	for _, k := range slices.Sorted(maps.Keys(c)) {
+		out = binary.BigEndian.AppendUint16(out, uint16(len(k)))
+		out = append(out, k...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(c[k])))
+		out = append(out, c[k]...)
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// envelopePrefix marks values sealed with a data key. Values without
+	// it were encrypted by KMS directly, before envelope encryption.
+	envelopePrefix = "env3:"
+	// envelopePrefixV2 marks envelopes written before they were bound to
+	// an encryption context.
+	envelopePrefixV2 = "env2:"
+	// envelopePrefixV1 marks envelopes written before the KMS key ID was
+	// stored with them.
+	envelopePrefixV1 = "env1:"
+)
+
+// envelope is a parsed sealEnvelope value.
+type envelope struct {
+	keyID   string // ARN of the KMS key that wrapped the data key; empty for env1
+	wrapped []byte // KMS-encrypted data key
+	header  []byte // authenticated as additional data
+	sealed  []byte // nonce || sealed data || tag
+	bound   bool   // the data key and payload are bound to an EncryptionContext
+}
+
+// sealEnvelope encrypts plaintext with the data key key, whose form
+// encrypted under the KMS key keyID with encryption context encCtx is
+// wrappedKey, and returns
+//
+//	"env3:" + base64(header || nonce || sealed data || tag)
+//	header = len(keyID) as uint16 || keyID || len(wrappedKey) as uint16 || wrappedKey
+//
+// The header and encCtx are authenticated as additional data, so neither
+// the key ID nor the wrapped key can be swapped for another, and the value
+// only opens with the same context.
+func sealEnvelope(key Key, keyID string, wrappedKey []byte, plaintext string, encCtx EncryptionContext) string {
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(keyID)))
+	out = append(out, keyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(wrappedKey)))
+	out = append(out, wrappedKey...)
+	out = newAEAD(key).Seal(out, nil, []byte(plaintext), append(out[:len(out):len(out)], encCtx.aad()...))
+	return envelopePrefix + base64.StdEncoding.EncodeToString(out)
+}
+
+// isEnvelope reports whether ciphertext was produced by sealEnvelope.
+func isEnvelope(ciphertext string) bool {
+	for _, prefix := range []string{envelopePrefix, envelopePrefixV2, envelopePrefixV1} {
+		if strings.HasPrefix(ciphertext, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEnvelope splits a sealEnvelope value into its parts. Older
+// envelopes are accepted too: env2 values are not bound to a context, and
+// env1 values also lack the key ID and authenticate only the wrapped key.
+func parseEnvelope(ciphertext string) (envelope, error) {
+	var env envelope
+	encoded, v1 := strings.CutPrefix(ciphertext, envelopePrefixV1)
+	if !v1 {
+		encoded, env.bound = strings.CutPrefix(ciphertext, envelopePrefix)
+		if !env.bound {
+			encoded = strings.TrimPrefix(ciphertext, envelopePrefixV2)
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return env, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+
+	rest := raw
+	if !v1 {
+		var keyID []byte
+		if keyID, rest, err = cutField(rest); err != nil {
+			return env, err
+		}
+		env.keyID = string(keyID)
+	}
+	if env.wrapped, rest, err = cutField(rest); err != nil {
+		return env, err
+	}
+	env.header = raw[:len(raw)-len(rest)]
+	if v1 {
+		env.header = env.wrapped
+	}
+	env.sealed = rest
+	return env, nil
+}
+
+// cutField splits a uint16 length-prefixed field off the front of b.
+func cutField(b []byte) (field, rest []byte, err error) {
+	if len(b) < 2 {
+		return nil, nil, errors.New("ciphertext is truncated")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return nil, nil, errors.New("ciphertext is truncated")
+	}
+	return b[2 : 2+n], b[2+n:], nil
+}
+
+// openEnvelope decrypts the payload of an envelope with the unwrapped data
+// key. encCtx is only checked for bound envelopes.
+func openEnvelope(key Key, env envelope, encCtx EncryptionContext) (string, error) {
+	aad := env.header
+	if env.bound {
+		aad = append(env.header[:len(env.header):len(env.header)], encCtx.aad()...)
+	}
+	plaintext, err := newAEAD(key).Open(nil, nil, env.sealed, aad)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+const testKeyARN = "arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	key := DeriveKey("data key", "test")
+	wrapped := []byte("wrapped-data-key")
+
+	c := sealEnvelope(key, testKeyARN, wrapped, "refresh-token", testContext)
+	if !isEnvelope(c) || strings.Contains(c, "refresh-token") {
+		t.Fatalf("envelope = %q", c)
+	}
+	env, err := parseEnvelope(c)
+	if err != nil {
+		t.Fatalf("parseEnvelope: %v", err)
+	}
+	if env.keyID != testKeyARN || !env.bound {
+		t.Errorf("key ID = %q, bound = %v", env.keyID, env.bound)
+	}
+	if string(env.wrapped) != "wrapped-data-key" {
+		t.Errorf("wrapped key = %q", env.wrapped)
+	}
+	if got, err := openEnvelope(key, env, testContext); got != "refresh-token" || err != nil {
+		t.Errorf("openEnvelope = %q, %v", got, err)
+	}
+
+	// The header and the encryption context are authenticated along with
+	// the payload.
+	swapped := env
+	swapped.header = append([]byte{}, env.header...)
+	swapped.header[len(swapped.header)-1] ^= 1
+	if _, err := openEnvelope(key, swapped, testContext); err == nil {
+		t.Error("opened an envelope with a modified header")
+	}
+	if _, err := openEnvelope(key, env, EncryptionContext{"user_id": "user2", "purpose": "test"}); err == nil {
+		t.Error("opened an envelope with another encryption context")
+	}
+}
+
+func TestEnvelope_V1(t *testing.T) {
+	key := DeriveKey("data key", "test")
+	wrapped := []byte("wrapped-data-key")
+
+	// env1 has no key ID and authenticates only the wrapped key.
+	raw := binary.BigEndian.AppendUint16(nil, uint16(len(wrapped)))
+	raw = append(raw, wrapped...)
+	raw = newAEAD(key).Seal(raw, nil, []byte("refresh-token"), wrapped)
+	c := envelopePrefixV1 + base64.StdEncoding.EncodeToString(raw)
+
+	env, err := parseEnvelope(c)
+	if err != nil {
+		t.Fatalf("parseEnvelope: %v", err)
+	}
+	if env.keyID != "" || env.bound || string(env.wrapped) != "wrapped-data-key" {
+		t.Errorf("envelope = %+v", env)
+	}
+	if got, err := openEnvelope(key, env, testContext); got != "refresh-token" || err != nil {
+		t.Errorf("openEnvelope = %q, %v", got, err)
+	}
+}
+
+func TestParseEnvelope_Malformed(t *testing.T) {
+	for _, c := range []string{"env3:!!!", "env3:", "env3:AAE=", "env3:AAAA/w==", "env2:AAAA/w==", "env1:AP8="} {
+		if _, err := parseEnvelope(c); err == nil {
+			t.Errorf("parseEnvelope(%q) succeeded", c)
+		}
+	}
+	if isEnvelope("AQICAHh...") {
+		t.Error("a direct KMS ciphertext was taken for an envelope")
+	}
+}
@@ -3,65 +3,339 @@ package crypto
 import (
 	"context"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
 )
 
-// Encryptor defines the interface for encryption and decryption.
+// Encryptor defines the interface for encryption and decryption. A value
+// only decrypts with the encryption context it was encrypted with.
 type Encryptor interface {
-	Encrypt(ctx context.Context, plaintext string) (string, error)
-	Decrypt(ctx context.Context, ciphertext string) (string, error)
+	Encrypt(ctx context.Context, plaintext string, encCtx EncryptionContext) (string, error)
+	Decrypt(ctx context.Context, ciphertext string, encCtx EncryptionContext) (string, error)
 }
 
-// KMSService implements Encryptor using AWS KMS.
+// Rotator is implemented by Encryptors whose key or format can change.
+// Values encrypted under a retired key or in an older format still
+// decrypt, but should be re-encrypted so the old key can eventually be
+// disabled and the old format dropped.
+type Rotator interface {
+	// DecryptStale decrypts ciphertext like Decrypt and reports whether it
+	// should be re-encrypted.
+	DecryptStale(ctx context.Context, ciphertext string, encCtx EncryptionContext) (plaintext string, stale bool, err error)
+}
+
+// DecryptStale decrypts ciphertext with e. stale is true when e is a
+// Rotator and the value should be re-encrypted with e.Encrypt.
+func DecryptStale(ctx context.Context, e Encryptor, ciphertext string, encCtx EncryptionContext) (plaintext string, stale bool, err error) {
+	if r, ok := e.(Rotator); ok {
+		return r.DecryptStale(ctx, ciphertext, encCtx)
+	}
+	plaintext, err = e.Decrypt(ctx, ciphertext, encCtx)
+	return plaintext, false, err
+}
+
+const (
+	// dataKeyMaxAge and dataKeyMaxUses bound how long one data key
+	// encrypts new values before KMS is asked for another.
+	dataKeyMaxAge  = 15 * time.Minute
+	dataKeyMaxUses = 10000
+	// maxDataKeys bounds the data keys kept, one per encryption context.
+	maxDataKeys = 1000
+
+	// unwrappedKeyTTL and maxUnwrappedKeys bound the cache of data keys
+	// that KMS unwrapped, so warm instances decrypt without calling KMS.
+	unwrappedKeyTTL  = 15 * time.Minute
+	maxUnwrappedKeys = 1000
+)
+
+// KMSService implements Encryptor with envelope encryption: values are
+// encrypted locally with AES-256-GCM under a data key from KMS
+// GenerateDataKey, and stored together with the KMS-encrypted data key. A
+// data key is reused for many values, so encrypting rarely calls KMS;
+// decrypting calls KMS once to unwrap the data key.
+//
+// Values encrypted by KMS directly, before envelope encryption, still
+// decrypt.
+//
+// Envelopes record the ARN of the KMS key that wrapped their data key.
+// When the key is replaced (e.g. the alias is pointed at a new key), the
+// old one is listed in previousKeyIDs so its values keep decrypting;
+// DecryptStale reports them so they can be re-encrypted under the new key.
+//
+// The encryption context is passed to KMS when a data key is generated and
+// unwrapped, so it is logged in CloudTrail, and authenticated with the
+// payload. Each context therefore gets its own data key.
+//
+// Unwrapped data keys are cached for unwrappedKeyTTL, keyed by the wrapped
+// key together with the KMS key and context KMS checked, so decrypting the
+// same user's token again skips KMS without skipping those checks.
 type KMSService struct {
-	client *kms.Client
-	keyID  string
+	client         KMSAPI
+	keyID          string
+	previousKeyIDs []string
+	now            func() time.Time
+
+	mu        sync.Mutex
+	dataKeys  map[string]*dataKey     // by EncryptionContext.aad
+	unwrapped map[string]unwrappedKey // by unwrapCacheKey
+}
+
+// unwrappedKey is a cached plaintext data key.
+type unwrappedKey struct {
+	key     Key
+	expires time.Time
+}
+
+// dataKey is a data key currently used to encrypt.
+type dataKey struct {
+	key     Key
+	keyID   string // ARN of the KMS key that wrapped it
+	wrapped []byte
+	created time.Time
+	uses    int
+}
+
+// KMSAPI is the part of the KMS client that KMSService uses; *kms.Client
+// implements it.
+type KMSAPI interface {
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
 }
 
 // NewKMSService creates a new KMSService.
 // keyID can be a key ID, key ARN, or alias name (e.g., "alias/gophdrive-token-key").
-func NewKMSService(client *kms.Client, keyID string) *KMSService {
+// previousKeyIDs are keys that may still decrypt but no longer encrypt;
+// they must be key IDs or key ARNs, since aliases move.
+func NewKMSService(client KMSAPI, keyID string, previousKeyIDs ...string) *KMSService {
 	return &KMSService{
-		client: client,
-		keyID:  keyID,
+		client:         client,
+		keyID:          keyID,
+		previousKeyIDs: previousKeyIDs,
+		now:            time.Now,
+		dataKeys:       make(map[string]*dataKey),
+		unwrapped:      make(map[string]unwrappedKey),
 	}
 }
 
-// Encrypt encrypts the plaintext using the configured KMS key.
-// Returns base64 encoded ciphertext.
-func (s *KMSService) Encrypt(ctx context.Context, plaintext string) (string, error) {
-	input := &kms.EncryptInput{
-		KeyId:     aws.String(s.keyID),
-		Plaintext: []byte(plaintext),
+// Encrypt encrypts the plaintext under the current data key for encCtx.
+func (s *KMSService) Encrypt(ctx context.Context, plaintext string, encCtx EncryptionContext) (string, error) {
+	dk, err := s.currentDataKey(ctx, encCtx)
+	if err != nil {
+		return "", err
 	}
+	return sealEnvelope(dk.key, dk.keyID, dk.wrapped, plaintext, encCtx), nil
+}
 
-	result, err := s.client.Encrypt(ctx, input)
+// currentDataKey returns the data key to encrypt with under encCtx,
+// generating a new one when there is none or it has reached dataKeyMaxAge
+// or dataKeyMaxUses.
+func (s *KMSService) currentDataKey(ctx context.Context, encCtx EncryptionContext) (*dataKey, error) {
+	id := string(encCtx.aad())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dk := s.dataKeys[id]; dk != nil && dk.usable(s.now()) {
+		dk.uses++
+		return dk, nil
+	}
+
+	result, err := s.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(s.keyID),
+		KeySpec:           types.DataKeySpecAes256,
+		EncryptionContext: encCtx,
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to encrypt data: %w", err)
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	dk := &dataKey{keyID: aws.ToString(result.KeyId), wrapped: result.CiphertextBlob, created: s.now(), uses: 1}
+	if copy(dk.key[:], result.Plaintext) != len(dk.key) {
+		return nil, fmt.Errorf("data key is %d bytes, want %d", len(result.Plaintext), len(dk.key))
+	}
+	s.storeDataKey(id, dk)
+	// The values it encrypts decrypt without asking KMS to unwrap it.
+	s.storeUnwrapped(unwrapCacheKey(dk.wrapped, s.keyID, encCtx), dk.key)
+	return dk, nil
+}
+
+// usable reports whether dk may encrypt another value at now.
+func (dk *dataKey) usable(now time.Time) bool {
+	return dk.uses < dataKeyMaxUses && now.Sub(dk.created) < dataKeyMaxAge
+}
+
+// storeDataKey caches dk for the context id, first dropping unusable keys
+// and, if maxDataKeys are still cached, the oldest. s.mu must be held.
+func (s *KMSService) storeDataKey(id string, dk *dataKey) {
+	var oldest string
+	for k, v := range s.dataKeys {
+		if !v.usable(s.now()) {
+			delete(s.dataKeys, k)
+		} else if oldest == "" || v.created.Before(s.dataKeys[oldest].created) {
+			oldest = k
+		}
+	}
+	if len(s.dataKeys) >= maxDataKeys {
+		delete(s.dataKeys, oldest)
 	}
+	s.dataKeys[id] = dk
+}
 
-	return base64.StdEncoding.EncodeToString(result.CiphertextBlob), nil
+// Decrypt decrypts a value returned by Encrypt, or a base64 encoded
+// ciphertext from KMS Encrypt.
+func (s *KMSService) Decrypt(ctx context.Context, ciphertext string, encCtx EncryptionContext) (string, error) {
+	plaintext, _, err := s.DecryptStale(ctx, ciphertext, encCtx)
+	return plaintext, err
 }
 
-// Decrypt decrypts the base64 encoded ciphertext using KMS.
-func (s *KMSService) Decrypt(ctx context.Context, ciphertext string) (string, error) {
-	decoded, err := base64.StdEncoding.DecodeString(ciphertext)
+// DecryptStale decrypts ciphertext and reports whether it should be
+// re-encrypted: because it was encrypted under a previous key, or in a
+// format that does not record its key or is not bound to encCtx. Such
+// older values were encrypted without a context and decrypt without one.
+func (s *KMSService) DecryptStale(ctx context.Context, ciphertext string, encCtx EncryptionContext) (string, bool, error) {
+	if !isEnvelope(ciphertext) {
+		decoded, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to decode ciphertext: %w", err)
+		}
+		plaintext, err := s.decryptAnyKey(ctx, decoded)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to decrypt data: %w", err)
+		}
+		return string(plaintext), true, nil
+	}
+
+	env, err := parseEnvelope(ciphertext)
+	if err != nil {
+		return "", false, err
+	}
+	var wrapCtx EncryptionContext
+	if env.bound {
+		wrapCtx = encCtx
+	}
+	// KMS rejects the value if keyID did not wrap it.
+	keyID := s.keyID
+	stale := !env.bound
+	if previous, ok := s.previousKey(env.keyID); ok {
+		keyID = previous
+		stale = true
+	} else if env.keyID == "" {
+		keyID = "" // any permitted key
+	}
+	key, err := s.unwrapDataKey(ctx, env.wrapped, keyID, wrapCtx)
+	if err != nil {
+		return "", false, err
+	}
+	plaintext, err := openEnvelope(key, env, encCtx)
+	return plaintext, stale, err
+}
+
+// unwrapDataKey asks KMS to decrypt the wrapped data key with keyID, or
+// with any permitted key if keyID is empty, and encCtx, unless it was
+// unwrapped or generated within unwrappedKeyTTL.
+func (s *KMSService) unwrapDataKey(ctx context.Context, wrapped []byte, keyID string, encCtx EncryptionContext) (Key, error) {
+	var key Key
+	cacheKey := unwrapCacheKey(wrapped, keyID, encCtx)
+	s.mu.Lock()
+	cached, ok := s.unwrapped[cacheKey]
+	s.mu.Unlock()
+	if ok && s.now().Before(cached.expires) {
+		return cached.key, nil
+	}
+
+	var rawKey []byte
+	var err error
+	if keyID == "" {
+		rawKey, err = s.decryptAnyKey(ctx, wrapped)
+	} else {
+		rawKey, err = s.decryptWithKey(ctx, wrapped, keyID, encCtx)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+		return key, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	if copy(key[:], rawKey) != len(key) {
+		return key, fmt.Errorf("data key is %d bytes, want %d", len(rawKey), len(key))
 	}
+	s.mu.Lock()
+	s.storeUnwrapped(cacheKey, key)
+	s.mu.Unlock()
+	return key, nil
+}
 
-	input := &kms.DecryptInput{
-		CiphertextBlob: decoded,
-		KeyId:          aws.String(s.keyID), // Optional, but good practice
+// unwrapCacheKey identifies a wrapped data key together with the KMS key
+// and encryption context it was unwrapped with.
+func unwrapCacheKey(wrapped []byte, keyID string, encCtx EncryptionContext) string {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(keyID)))
+	b = append(b, keyID...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(wrapped)))
+	b = append(b, wrapped...)
+	return string(append(b, encCtx.aad()...))
+}
+
+// storeUnwrapped caches key under cacheKey, first dropping expired keys
+// and, if maxUnwrappedKeys are still cached, the one expiring soonest.
+// s.mu must be held.
+func (s *KMSService) storeUnwrapped(cacheKey string, key Key) {
+	now := s.now()
+	var oldest string
+	for k, v := range s.unwrapped {
+		if !now.Before(v.expires) {
+			delete(s.unwrapped, k)
+		} else if oldest == "" || v.expires.Before(s.unwrapped[oldest].expires) {
+			oldest = k
+		}
+	}
+	if len(s.unwrapped) >= maxUnwrappedKeys {
+		delete(s.unwrapped, oldest)
 	}
+	s.unwrapped[cacheKey] = unwrappedKey{key: key, expires: now.Add(unwrappedKeyTTL)}
+}
 
-	result, err := s.client.Decrypt(ctx, input)
+// previousKey returns the entry of previousKeyIDs naming the key with ARN
+// keyARN.
+func (s *KMSService) previousKey(keyARN string) (string, bool) {
+	if keyARN == "" {
+		return "", false
+	}
+	for _, id := range s.previousKeyIDs {
+		if id == keyARN || strings.HasSuffix(keyARN, ":key/"+id) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// decryptWithKey decrypts blob with KMS, which fails unless keyID
+// encrypted it with encryption context encCtx.
+func (s *KMSService) decryptWithKey(ctx context.Context, blob []byte, keyID string, encCtx EncryptionContext) ([]byte, error) {
+	result, err := s.client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob:    blob,
+		KeyId:             aws.String(keyID),
+		EncryptionContext: encCtx,
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to decrypt data: %w", err)
+		return nil, err
 	}
+	return result.Plaintext, nil
+}
 
-	return string(result.Plaintext), nil
+// decryptAnyKey decrypts a blob whose key is not recorded, trying the
+// current key and then each previous one. Such blobs predate encryption
+// contexts.
+func (s *KMSService) decryptAnyKey(ctx context.Context, blob []byte) ([]byte, error) {
+	plaintext, err := s.decryptWithKey(ctx, blob, s.keyID, nil)
+	for _, id := range s.previousKeyIDs {
+		var incorrect *types.IncorrectKeyException
+		if !errors.As(err, &incorrect) {
+			break
+		}
+		plaintext, err = s.decryptWithKey(ctx, blob, id, nil)
+	}
+	return plaintext, err
 }
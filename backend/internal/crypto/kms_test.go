@@ -0,0 +1,296 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+const (
+	currentKeyARN  = "arn:aws:kms:us-east-1:111122223333:key/current"
+	previousKeyARN = "arn:aws:kms:us-east-1:111122223333:key/previous"
+	retiredKeyARN  = "arn:aws:kms:us-east-1:111122223333:key/retired"
+)
+
+// fakeKMS implements KMSAPI with local keys. Ciphertext blobs are the key
+// ARN, a NUL, and the plaintext sealed with that key and the encryption
+// context, so like KMS, decrypting needs the same context and fails with
+// IncorrectKeyException when KeyId names another key.
+type fakeKMS struct {
+	keys      map[string]Key    // by ARN
+	aliases   map[string]string // alias name or key ID to ARN
+	generated int
+	decrypted int
+}
+
+func newFakeKMS() *fakeKMS {
+	f := &fakeKMS{keys: map[string]Key{}, aliases: map[string]string{"alias/gophdrive-token-key": currentKeyARN}}
+	for _, arn := range []string{currentKeyARN, previousKeyARN, retiredKeyARN} {
+		f.keys[arn] = DeriveKey(arn, "fake kms")
+		f.aliases[arn[strings.LastIndex(arn, "/")+1:]] = arn
+	}
+	return f
+}
+
+func (f *fakeKMS) resolve(keyID *string) (string, error) {
+	id := aws.ToString(keyID)
+	if arn, ok := f.aliases[id]; ok {
+		return arn, nil
+	}
+	if _, ok := f.keys[id]; ok {
+		return id, nil
+	}
+	return "", &types.NotFoundException{Message: aws.String("key " + id + " not found")}
+}
+
+func (f *fakeKMS) seal(arn string, plaintext []byte, encCtx map[string]string) []byte {
+	blob := append([]byte(arn), 0)
+	return newAEAD(f.keys[arn]).Seal(blob, nil, plaintext, EncryptionContext(encCtx).aad())
+}
+
+func (f *fakeKMS) Encrypt(_ context.Context, in *kms.EncryptInput, _ ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	arn, err := f.resolve(in.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.EncryptOutput{KeyId: aws.String(arn), CiphertextBlob: f.seal(arn, in.Plaintext, in.EncryptionContext)}, nil
+}
+
+func (f *fakeKMS) GenerateDataKey(_ context.Context, in *kms.GenerateDataKeyInput, _ ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	arn, err := f.resolve(in.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	f.generated++
+	plaintext := make([]byte, 32)
+	rand.Read(plaintext)
+	return &kms.GenerateDataKeyOutput{KeyId: aws.String(arn), Plaintext: plaintext, CiphertextBlob: f.seal(arn, plaintext, in.EncryptionContext)}, nil
+}
+
+func (f *fakeKMS) Decrypt(_ context.Context, in *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	f.decrypted++
+	arn, sealed, ok := bytes.Cut(in.CiphertextBlob, []byte{0})
+	key, known := f.keys[string(arn)]
+	if !ok || !known {
+		return nil, &types.InvalidCiphertextException{Message: aws.String("malformed blob")}
+	}
+	if in.KeyId != nil {
+		want, err := f.resolve(in.KeyId)
+		if err != nil {
+			return nil, err
+		}
+		if want != string(arn) {
+			return nil, &types.IncorrectKeyException{Message: aws.String("wrong key")}
+		}
+	}
+	plaintext, err := newAEAD(key).Open(nil, nil, sealed, EncryptionContext(in.EncryptionContext).aad())
+	if err != nil {
+		return nil, &types.InvalidCiphertextException{Message: aws.String("wrong encryption context")}
+	}
+	return &kms.DecryptOutput{KeyId: aws.String(string(arn)), Plaintext: plaintext}, nil
+}
+
+var userContext = EncryptionContext{"user_id": "user1", "purpose": "refresh_token"}
+
+// legacyValues returns ciphertexts of "refresh-token" in the formats that
+// predate env3, encrypted under arn without an encryption context.
+func legacyValues(t *testing.T, f *fakeKMS, arn string) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	direct, _ := f.Encrypt(ctx, &kms.EncryptInput{KeyId: aws.String(arn), Plaintext: []byte("refresh-token")})
+	dk, _ := f.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{KeyId: aws.String(arn)})
+	var key Key
+	copy(key[:], dk.Plaintext)
+	// Without a context, env3 authenticates exactly what env2 did.
+	env2 := envelopePrefixV2 + strings.TrimPrefix(sealEnvelope(key, arn, dk.CiphertextBlob, "refresh-token", nil), envelopePrefix)
+	env1 := binary.BigEndian.AppendUint16(nil, uint16(len(dk.CiphertextBlob)))
+	env1 = append(env1, dk.CiphertextBlob...)
+	env1 = newAEAD(key).Seal(env1, nil, []byte("refresh-token"), dk.CiphertextBlob)
+	return map[string]string{
+		"direct": base64.StdEncoding.EncodeToString(direct.CiphertextBlob),
+		"env1":   envelopePrefixV1 + base64.StdEncoding.EncodeToString(env1),
+		"env2":   env2,
+	}
+}
+
+func TestKMSService_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeKMS()
+	s := NewKMSService(f, "alias/gophdrive-token-key")
+
+	c, err := s.Encrypt(ctx, "refresh-token", userContext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(c, envelopePrefix) || strings.Contains(c, "refresh-token") {
+		t.Fatalf("ciphertext = %q", c)
+	}
+	got, stale, err := s.DecryptStale(ctx, c, userContext)
+	if got != "refresh-token" || stale || err != nil {
+		t.Errorf("DecryptStale = %q, %v, %v", got, stale, err)
+	}
+
+	otherUser := EncryptionContext{"user_id": "user2", "purpose": "refresh_token"}
+	if _, err := s.Decrypt(ctx, c, otherUser); err == nil {
+		t.Error("decrypted with another user's encryption context")
+	}
+}
+
+func TestKMSService_DataKeyReuse(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeKMS()
+	s := NewKMSService(f, "alias/gophdrive-token-key")
+
+	for range 3 {
+		s.Encrypt(ctx, "refresh-token", userContext)
+	}
+	if f.generated != 1 {
+		t.Errorf("GenerateDataKey called %d times for one context, want 1", f.generated)
+	}
+	s.Encrypt(ctx, "refresh-token", EncryptionContext{"user_id": "user2", "purpose": "refresh_token"})
+	if f.generated != 2 {
+		t.Errorf("GenerateDataKey called %d times for two contexts, want 2", f.generated)
+	}
+}
+
+func TestKMSService_UnwrappedKeyCache(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeKMS()
+	writer := NewKMSService(f, "alias/gophdrive-token-key")
+	c, _ := writer.Encrypt(ctx, "refresh-token", userContext)
+	if _, err := writer.Decrypt(ctx, c, userContext); err != nil || f.decrypted != 0 {
+		t.Errorf("Decrypt by the encrypting instance: %v, %d KMS calls, want 0", err, f.decrypted)
+	}
+
+	s := NewKMSService(f, "alias/gophdrive-token-key")
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	for range 3 {
+		if got, err := s.Decrypt(ctx, c, userContext); got != "refresh-token" || err != nil {
+			t.Fatalf("Decrypt = %q, %v", got, err)
+		}
+	}
+	if f.decrypted != 1 {
+		t.Errorf("KMS Decrypt called %d times for one value, want 1", f.decrypted)
+	}
+
+	// A cached key is only used with the context KMS checked.
+	otherUser := EncryptionContext{"user_id": "user2", "purpose": "refresh_token"}
+	if _, err := s.Decrypt(ctx, c, otherUser); err == nil {
+		t.Error("decrypted with another user's encryption context")
+	}
+
+	now = now.Add(unwrappedKeyTTL)
+	s.Decrypt(ctx, c, userContext)
+	if f.decrypted != 3 {
+		t.Errorf("KMS Decrypt called %d times after the cache expired, want 3", f.decrypted)
+	}
+}
+
+func TestKMSService_Decrypt(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeKMS()
+	underPrevious := NewKMSService(f, previousKeyARN)
+
+	values := map[string]string{}
+	for format, c := range legacyValues(t, f, currentKeyARN) {
+		values[format+" current key"] = c
+	}
+	for format, c := range legacyValues(t, f, previousKeyARN) {
+		values[format+" previous key"] = c
+	}
+	values["env3 previous key"], _ = underPrevious.Encrypt(ctx, "refresh-token", userContext)
+	values["env3 retired key"], _ = NewKMSService(f, retiredKeyARN).Encrypt(ctx, "refresh-token", userContext)
+	for format, c := range legacyValues(t, f, retiredKeyARN) {
+		values[format+" retired key"] = c
+	}
+
+	s := NewKMSService(f, "alias/gophdrive-token-key", "previous")
+	tests := []struct {
+		value     string
+		wantStale bool
+		wantErr   bool
+	}{
+		{"direct current key", true, false},
+		{"env1 current key", true, false},
+		{"env2 current key", true, false},
+		{"direct previous key", true, false},
+		{"env1 previous key", true, false},
+		{"env2 previous key", true, false},
+		{"env3 previous key", true, false},
+		{"direct retired key", false, true},
+		{"env1 retired key", false, true},
+		{"env2 retired key", false, true},
+		{"env3 retired key", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, stale, err := s.DecryptStale(ctx, values[tt.value], userContext)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("DecryptStale = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != "refresh-token" || stale != tt.wantStale {
+				t.Errorf("DecryptStale = %q, %v, %v, want stale = %v", got, stale, err, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestKMSService_Errors(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeKMS()
+	s := NewKMSService(f, "alias/gophdrive-token-key")
+	valid, _ := s.Encrypt(ctx, "refresh-token", userContext)
+
+	tests := []struct {
+		name       string
+		ciphertext string
+	}{
+		{"not base64", "!!!"},
+		{"truncated envelope", "env3:AAE="},
+		{"unknown blob", base64.StdEncoding.EncodeToString([]byte("garbage"))},
+		{"tampered payload", valid[:len(valid)-4] + "AAAA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Decrypt(ctx, tt.ciphertext, userContext); err == nil {
+				t.Error("Decrypt succeeded")
+			}
+		})
+	}
+
+	missing := NewKMSService(f, "alias/missing")
+	var notFound *types.NotFoundException
+	if _, err := missing.Encrypt(ctx, "refresh-token", userContext); !errors.As(err, &notFound) {
+		t.Errorf("Encrypt with a missing key error = %v, want NotFoundException", err)
+	}
+}
+
+func TestKMSService_PreviousKey(t *testing.T) {
+	s := NewKMSService(nil, "alias/current", "1234abcd-12ab-34cd-56ef-1234567890ab", "arn:aws:kms:us-east-1:111122223333:key/other")
+
+	if id, ok := s.previousKey(testKeyARN); !ok || id != "1234abcd-12ab-34cd-56ef-1234567890ab" {
+		t.Errorf("previousKey(by key ID) = %q, %v", id, ok)
+	}
+	if _, ok := s.previousKey("arn:aws:kms:us-east-1:111122223333:key/other"); !ok {
+		t.Error("previousKey(by ARN) = false")
+	}
+	for _, arn := range []string{"", "arn:aws:kms:us-east-1:111122223333:key/new", "arn:aws:kms:us-east-1:111122223333:key/xx1234abcd-12ab-34cd-56ef-1234567890ab"} {
+		if _, ok := s.previousKey(arn); ok {
+			t.Errorf("previousKey(%q) = true", arn)
+		}
+	}
+}
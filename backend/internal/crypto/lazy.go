@@ -0,0 +1,29 @@
+package crypto
+
+import (
+	"context"
+	"sync"
+)
+
+// Lazy returns an Encryptor that builds the real one with newEncryptor on
+// first use, so requests that never encrypt or decrypt (everything but
+// sign-in and Drive access) do not pay for creating the client.
+func Lazy(newEncryptor func() Encryptor) Encryptor {
+	return &lazyEncryptor{get: sync.OnceValue(newEncryptor)}
+}
+
+type lazyEncryptor struct {
+	get func() Encryptor
+}
+
+func (l *lazyEncryptor) Encrypt(ctx context.Context, plaintext string, encCtx EncryptionContext) (string, error) {
+	return l.get().Encrypt(ctx, plaintext, encCtx)
+}
+
+func (l *lazyEncryptor) Decrypt(ctx context.Context, ciphertext string, encCtx EncryptionContext) (string, error) {
+	return l.get().Decrypt(ctx, ciphertext, encCtx)
+}
+
+func (l *lazyEncryptor) DecryptStale(ctx context.Context, ciphertext string, encCtx EncryptionContext) (string, bool, error) {
+	return DecryptStale(ctx, l.get(), ciphertext, encCtx)
+}
@@ -10,12 +10,12 @@ func NewMockEncryptor() *MockEncryptor {
 	return &MockEncryptor{}
 }
 
-func (m *MockEncryptor) Encrypt(ctx context.Context, plaintext string) (string, error) {
+func (m *MockEncryptor) Encrypt(ctx context.Context, plaintext string, _ EncryptionContext) (string, error) {
 	// For dev, just return plaintext or prefix it to know it's mocked
 	return "mock:" + plaintext, nil
 }
 
-func (m *MockEncryptor) Decrypt(ctx context.Context, ciphertext string) (string, error) {
+func (m *MockEncryptor) Decrypt(ctx context.Context, ciphertext string, _ EncryptionContext) (string, error) {
 	// Remove prefix
 	if len(ciphertext) > 5 && ciphertext[:5] == "mock:" {
 		return ciphertext[5:], nil
@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for a token that is malformed, was
+	// altered, or was signed for another purpose or with an unknown key.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned for a validly signed token past its
+	// expiry.
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// signerKeyPurpose separates the HMAC keys from other uses of the same
+// secret.
+const signerKeyPurpose = "gophdrive signed token v1"
+
+// SigningKeys supplies the signing secret; *secret.Value implements it.
+// The secret is a comma-separated list of keys: the first signs, all of
+// them verify. To rotate, prepend a new key, and drop the old one once the
+// tokens it signed have expired.
+type SigningKeys interface {
+	Get(ctx context.Context) (string, error)
+	Refresh(ctx context.Context) (string, error)
+}
+
+// Signer issues short-lived tokens that carry a small payload, for values
+// handed to the browser and checked when they come back, such as OAuth
+// state, share links and download URLs. A token is
+//
+//	base64url(expiry as Unix seconds uint64 || data) + "." + base64url(HMAC-SHA256)
+//
+// The MAC also covers a purpose string, so a token issued for one feature
+// is rejected by another.
+type Signer struct {
+	keys SigningKeys
+	now  func() time.Time
+}
+
+// NewSigner returns a Signer keyed by keys.
+func NewSigner(keys SigningKeys) *Signer {
+	return &Signer{keys: keys, now: time.Now}
+}
+
+// Sign returns a token carrying data for purpose that expires after ttl.
+func (s *Signer) Sign(ctx context.Context, purpose, data string, ttl time.Duration) (string, error) {
+	keys, err := s.loadKeys(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	payload := binary.BigEndian.AppendUint64(nil, uint64(s.now().Add(ttl).Unix()))
+	payload = append(payload, data...)
+	mac := sign(keys[0], purpose, payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// Verify returns the data of a token that Sign issued for purpose. It
+// returns ErrInvalidToken or ErrTokenExpired when the token cannot be used.
+func (s *Signer) Verify(ctx context.Context, purpose, token string) (string, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) < 8 {
+		return "", ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	keys, err := s.loadKeys(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	if !anyKeyMatches(keys, purpose, payload, mac) {
+		// The token may be signed with a key added since the secret was
+		// cached, e.g. by another instance that already refreshed it.
+		if keys, err = s.loadKeys(ctx, true); err != nil {
+			return "", err
+		}
+		if !anyKeyMatches(keys, purpose, payload, mac) {
+			return "", ErrInvalidToken
+		}
+	}
+
+	expiry := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if !s.now().Before(expiry) {
+		return "", ErrTokenExpired
+	}
+	return string(payload[8:]), nil
+}
+
+// loadKeys returns the HMAC keys derived from the signing secret,
+// re-fetching it first if refresh is set.
+func (s *Signer) loadKeys(ctx context.Context, refresh bool) ([]Key, error) {
+	get := s.keys.Get
+	if refresh {
+		get = s.keys.Refresh
+	}
+	raw, err := get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolve signing keys: %w", err)
+	}
+	var keys []Key
+	for _, k := range strings.Split(raw, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, DeriveKey(k, signerKeyPurpose))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+	return keys, nil
+}
+
+// sign computes the MAC of payload for purpose.
+func sign(key Key, purpose string, payload []byte) []byte {
+	h := hmac.New(sha256.New, key[:])
+	h.Write([]byte(purpose))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+func anyKeyMatches(keys []Key, purpose string, payload, mac []byte) bool {
+	for _, key := range keys {
+		if hmac.Equal(mac, sign(key, purpose, payload)) {
+			return true
+		}
+	}
+	return false
+}
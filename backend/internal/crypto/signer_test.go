@@ -0,0 +1,111 @@
+package crypto
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rotatingKeys is a SigningKeys whose Refresh picks up next.
+type rotatingKeys struct {
+	current, next string
+	refreshes     int
+}
+
+func (k *rotatingKeys) Get(context.Context) (string, error) { return k.current, nil }
+
+func (k *rotatingKeys) Refresh(context.Context) (string, error) {
+	k.refreshes++
+	if k.next != "" {
+		k.current = k.next
+	}
+	return k.current, nil
+}
+
+func newTestSigner(keys string) (*Signer, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	s := NewSigner(&rotatingKeys{current: keys})
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestSigner_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestSigner("key-1")
+
+	token, err := s.Sign(ctx, "oauth_state", "nonce-123", time.Minute)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if strings.ContainsAny(token, "+/=") {
+		t.Errorf("token %q is not URL-safe", token)
+	}
+	if got, err := s.Verify(ctx, "oauth_state", token); got != "nonce-123" || err != nil {
+		t.Errorf("Verify = %q, %v", got, err)
+	}
+}
+
+func TestSigner_Rejects(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestSigner("key-1")
+	token, _ := s.Sign(ctx, "oauth_state", "nonce-123", time.Minute)
+	payload, mac, _ := strings.Cut(token, ".")
+	other, _ := newTestSigner("key-2")
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		purpose string
+		token   string
+	}{
+		{"other purpose", s, "share_link", token},
+		{"other key", other, "oauth_state", token},
+		{"altered payload", s, "oauth_state", payload[:len(payload)-1] + "A." + mac},
+		{"missing MAC", s, "oauth_state", payload},
+		{"short payload", s, "oauth_state", "AAAA." + mac},
+		{"empty", s, "oauth_state", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.signer.Verify(ctx, tt.purpose, tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	*now = now.Add(time.Minute)
+	if _, err := s.Verify(ctx, "oauth_state", token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Verify after expiry error = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestSigner_Rotation(t *testing.T) {
+	ctx := context.Background()
+	old, _ := newTestSigner("key-1")
+	oldToken, _ := old.Sign(ctx, "oauth_state", "a", time.Minute)
+
+	// After prepending a new key, new tokens use it and old ones verify.
+	s, _ := newTestSigner("key-2, key-1")
+	newToken, _ := s.Sign(ctx, "oauth_state", "b", time.Minute)
+	if _, err := old.Verify(ctx, "oauth_state", newToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("new token verified with the old key only: %v", err)
+	}
+	for token, want := range map[string]string{oldToken: "a", newToken: "b"} {
+		if got, err := s.Verify(ctx, "oauth_state", token); got != want || err != nil {
+			t.Errorf("Verify = %q, %v, want %q", got, err, want)
+		}
+	}
+
+	// An instance with a stale cached secret refreshes it once.
+	keys := &rotatingKeys{current: "key-1", next: "key-2, key-1"}
+	stale := NewSigner(keys)
+	stale.now = s.now
+	if got, err := stale.Verify(ctx, "oauth_state", newToken); got != "b" || err != nil {
+		t.Errorf("Verify with stale keys = %q, %v", got, err)
+	}
+	if keys.refreshes != 1 {
+		t.Errorf("refreshes = %d, want 1", keys.refreshes)
+	}
+}
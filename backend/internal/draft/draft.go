@@ -0,0 +1,161 @@
+// Package draft keeps each user's unsaved edits to a note on the server, so
+// a crashed browser does not lose work. Drafts are separate from the note
+// itself: the user commits a draft by saving the note, or discards it.
+package draft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Retention is how long a draft is kept after its last save before DynamoDB
+// TTL removes it.
+const Retention = 7 * 24 * time.Hour
+
+// MaxContentBytes is the largest draft accepted. DynamoDB items are limited
+// to 400 KB, so drafts are smaller than the largest notes.
+const MaxContentBytes = 350 << 10
+
+var (
+	// ErrNotFound is returned when the user has no draft of a note.
+	ErrNotFound = errors.New("draft not found")
+	// ErrTooLarge is returned for drafts over MaxContentBytes.
+	ErrTooLarge = errors.New("draft too large")
+)
+
+// Draft is a user's unsaved content for a note. BaseETag is the note's ETag
+// when the user started editing, so the client can tell whether the note
+// has changed since.
+type Draft struct {
+	UserID    string    `json:"-" dynamodbav:"user_id"`
+	NoteID    string    `json:"noteId" dynamodbav:"note_id"`
+	Content   string    `json:"content" dynamodbav:"content"`
+	BaseETag  string    `json:"baseEtag,omitempty" dynamodbav:"base_etag,omitempty"`
+	SavedAt   time.Time `json:"savedAt" dynamodbav:"saved_at"`
+	ExpiresAt int64     `json:"-" dynamodbav:"expires_at"`
+}
+
+// Store persists drafts in a DynamoDB table keyed by user_id and note_id,
+// one draft per user and note.
+// If client is nil, it uses an in-memory map (for tests).
+type Store struct {
+	client    *dynamodb.Client
+	tableName string
+
+	// Fallback for tests
+	drafts map[string]Draft // by user ID and note ID
+	mu     sync.Mutex
+}
+
+// NewStore creates a new draft Store.
+func NewStore(client *dynamodb.Client, tableName string) *Store {
+	return &Store{
+		client:    client,
+		tableName: tableName,
+		drafts:    make(map[string]Draft),
+	}
+}
+
+func mapKey(userID, noteID string) string {
+	return userID + "/" + noteID
+}
+
+func key(userID, noteID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"user_id": &types.AttributeValueMemberS{Value: userID},
+		"note_id": &types.AttributeValueMemberS{Value: noteID},
+	}
+}
+
+// Put stores d, replacing the user's earlier draft of the note, and returns
+// it with its save and expiry times set.
+func (s *Store) Put(ctx context.Context, d Draft) (*Draft, error) {
+	if len(d.Content) > MaxContentBytes {
+		return nil, ErrTooLarge
+	}
+	d.SavedAt = time.Now().UTC()
+	d.ExpiresAt = d.SavedAt.Add(Retention).Unix()
+
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.drafts[mapKey(d.UserID, d.NoteID)] = d
+		return &d, nil
+	}
+
+	item, err := attributevalue.MarshalMap(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal draft: %w", err)
+	}
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save draft: %w", err)
+	}
+	return &d, nil
+}
+
+// Get returns the user's draft of noteID, or ErrNotFound. Expired drafts
+// that DynamoDB TTL has not yet removed are not returned.
+func (s *Store) Get(ctx context.Context, userID, noteID string) (*Draft, error) {
+	var d Draft
+	if s.client == nil {
+		s.mu.Lock()
+		found, ok := s.drafts[mapKey(userID, noteID)]
+		s.mu.Unlock()
+		if !ok {
+			return nil, ErrNotFound
+		}
+		d = found
+	} else {
+		out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(s.tableName),
+			Key:            key(userID, noteID),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get draft: %w", err)
+		}
+		if out.Item == nil {
+			return nil, ErrNotFound
+		}
+		if err := attributevalue.UnmarshalMap(out.Item, &d); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal draft: %w", err)
+		}
+	}
+
+	if d.ExpiresAt <= time.Now().Unix() {
+		return nil, ErrNotFound
+	}
+	return &d, nil
+}
+
+// Delete discards the user's draft of noteID. Deleting a draft that does
+// not exist is not an error.
+func (s *Store) Delete(ctx context.Context, userID, noteID string) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.drafts, mapKey(userID, noteID))
+		return nil
+	}
+
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       key(userID, noteID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete draft: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package draft
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStore_PutGetDelete(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "user1", "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Put error = %v, want ErrNotFound", err)
+	}
+
+	s.Put(ctx, Draft{UserID: "user1", NoteID: "a", Content: "first", BaseETag: "v1"})
+	saved, err := s.Put(ctx, Draft{UserID: "user1", NoteID: "a", Content: "second", BaseETag: "v1"})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if saved.SavedAt.IsZero() || saved.ExpiresAt == 0 {
+		t.Errorf("Put did not set times: %+v", saved)
+	}
+
+	d, err := s.Get(ctx, "user1", "a")
+	if err != nil || d.Content != "second" {
+		t.Fatalf("Get = %+v, %v; want the latest draft", d, err)
+	}
+	// Drafts are per user.
+	if _, err := s.Get(ctx, "user2", "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get for another user error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Delete(ctx, "user1", "a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "user1", "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "user1", "a"); err != nil {
+		t.Errorf("second Delete: %v", err)
+	}
+}
+
+func TestStore_TooLarge(t *testing.T) {
+	s := NewStore(nil, "")
+	big := strings.Repeat("x", MaxContentBytes+1)
+	if _, err := s.Put(context.Background(), Draft{UserID: "user1", NoteID: "a", Content: big}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Put error = %v, want ErrTooLarge", err)
+	}
+}
@@ -0,0 +1,119 @@
+package gitexport
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Change actions.
+const (
+	ActionAdd    = "add"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change is a commit of one note. SHA is the blob SHA of the file it
+// replaces or deletes; Content is nil for deletions.
+type Change struct {
+	Path    string
+	Action  string
+	Content []byte
+	SHA     string
+}
+
+// Message returns the commit message for c, such as "Update Work/Plan.md".
+func (c Change) Message() string {
+	verb := map[string]string{ActionAdd: "Add", ActionUpdate: "Update", ActionDelete: "Delete"}[c.Action]
+	return fmt.Sprintf("%s %s\n\nExported from GophDrive.", verb, c.Path)
+}
+
+// BlobSHA returns the SHA Git gives a file with content.
+func BlobSHA(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Diff returns the changes that make the Markdown files among files, which
+// maps paths to blob SHAs, match notes, sorted by path. A note with nil
+// content leaves its file as it is. Files other than Markdown files are
+// never changed.
+func Diff(notes map[string][]byte, files map[string]string) []Change {
+	var changes []Change
+	for p, content := range notes {
+		if content == nil {
+			continue
+		}
+		sha, exists := files[p]
+		switch {
+		case !exists:
+			changes = append(changes, Change{Path: p, Action: ActionAdd, Content: content})
+		case sha != BlobSHA(content):
+			changes = append(changes, Change{Path: p, Action: ActionUpdate, Content: content, SHA: sha})
+		}
+	}
+	for p, sha := range files {
+		if _, ok := notes[p]; !ok && strings.HasSuffix(p, ".md") {
+			changes = append(changes, Change{Path: p, Action: ActionDelete, SHA: sha})
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return cmp.Compare(a.Path, b.Path) })
+	return changes
+}
+
+// Commit is a commit made by Export.
+type Commit struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	SHA    string `json:"sha"`
+}
+
+// Result reports an export. Remaining changes were left for the next
+// export.
+type Result struct {
+	Commits   []Commit `json:"commits"`
+	Unchanged int      `json:"unchanged"`
+	Remaining int      `json:"remaining"`
+}
+
+// Export commits the difference between notes, which maps paths to
+// content, and repo, one commit per note and at most limit commits. On
+// error, the result lists the commits made before it.
+func Export(ctx context.Context, repo Repository, notes map[string][]byte, limit int) (*Result, error) {
+	files, err := repo.Files(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := Diff(notes, files)
+
+	res := &Result{Commits: []Commit{}}
+	for _, content := range notes {
+		if content != nil {
+			res.Unchanged++
+		}
+	}
+	for _, c := range changes {
+		if c.Action != ActionDelete {
+			res.Unchanged--
+		}
+	}
+	for i, c := range changes {
+		if i == limit {
+			res.Remaining = len(changes) - limit
+			break
+		}
+		sha, err := repo.Commit(ctx, c)
+		if err != nil {
+			res.Remaining = len(changes) - i
+			return res, fmt.Errorf("commit %s: %w", c.Path, err)
+		}
+		res.Commits = append(res.Commits, Commit{Path: c.Path, Action: c.Action, SHA: sha})
+	}
+	return res, nil
+}
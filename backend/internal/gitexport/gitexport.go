@@ -0,0 +1,257 @@
+// Package gitexport copies a user's notes to a Git repository they own on
+// GitHub or GitLab, one commit per changed note, as an independent,
+// versioned copy outside Google Drive. Each user configures one remote;
+// its access token is kept encrypted.
+package gitexport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jun/gophdrive/backend/internal/crypto"
+)
+
+// Git hosts.
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+// DefaultBranch is the branch notes are committed to when the remote does
+// not name one.
+const DefaultBranch = "main"
+
+// maxToken bounds the length of a host access token.
+const maxToken = 512
+
+var (
+	// ErrNotConfigured is returned when the user has no remote.
+	ErrNotConfigured = errors.New("no git remote is configured")
+	// ErrRemote is wrapped by errors returned by the Git host, such as a
+	// rejected token or a missing repository. Their text is safe to show.
+	ErrRemote = errors.New("the git host rejected the request")
+)
+
+var (
+	// repositoryPattern matches "owner/name" on GitHub and
+	// "group/subgroup/project" on GitLab.
+	repositoryPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)+$`)
+	branchPattern     = regexp.MustCompile(`^[A-Za-z0-9_./-]{1,255}$`)
+)
+
+// Remote is a user's Git remote. Token is only held in memory; the store
+// keeps it encrypted in EncryptedToken.
+type Remote struct {
+	UserID         string    `json:"-" dynamodbav:"user_id"`
+	Provider       string    `json:"provider" dynamodbav:"provider"`
+	Repository     string    `json:"repository" dynamodbav:"repository"`
+	Branch         string    `json:"branch" dynamodbav:"branch"`
+	Token          string    `json:"-" dynamodbav:"-"`
+	EncryptedToken string    `json:"-" dynamodbav:"encrypted_token"`
+	UpdatedAt      time.Time `json:"updatedAt" dynamodbav:"updated_at"`
+	// LastExportAt and LastCommit describe the last export that committed
+	// anything.
+	LastExportAt *time.Time `json:"lastExportAt,omitempty" dynamodbav:"last_export_at,omitempty"`
+	LastCommit   string     `json:"lastCommit,omitempty" dynamodbav:"last_commit,omitempty"`
+}
+
+// Validate checks the remote's settings, defaulting its branch. The error
+// is safe to show.
+func (r *Remote) Validate() error {
+	if r.Provider != ProviderGitHub && r.Provider != ProviderGitLab {
+		return fmt.Errorf("provider must be %q or %q", ProviderGitHub, ProviderGitLab)
+	}
+	if (r.Provider == ProviderGitHub && strings.Count(r.Repository, "/") != 1) || !repositoryPattern.MatchString(r.Repository) {
+		return errors.New("repository must be a path such as owner/notes")
+	}
+	if r.Branch == "" {
+		r.Branch = DefaultBranch
+	}
+	if !branchPattern.MatchString(r.Branch) || strings.Contains(r.Branch, "..") ||
+		strings.HasPrefix(r.Branch, "/") || strings.HasSuffix(r.Branch, "/") {
+		return errors.New("branch is not a valid branch name")
+	}
+	if len(r.Token) > maxToken {
+		return fmt.Errorf("token must be at most %d characters", maxToken)
+	}
+	return nil
+}
+
+// tokenContext binds a remote's encrypted token to its user.
+func tokenContext(userID string) crypto.EncryptionContext {
+	return crypto.EncryptionContext{"user_id": userID, "purpose": "git_token"}
+}
+
+// Store persists remotes in a DynamoDB table keyed by user_id, encrypting
+// their tokens with enc. If client is nil, it uses an in-memory map (for
+// tests).
+type Store struct {
+	client    *dynamodb.Client
+	tableName string
+	enc       crypto.Encryptor
+
+	// Fallback for tests
+	remotes map[string]Remote
+	mu      sync.Mutex
+}
+
+// NewStore creates a new remote Store.
+func NewStore(client *dynamodb.Client, tableName string, enc crypto.Encryptor) *Store {
+	return &Store{
+		client:    client,
+		tableName: tableName,
+		enc:       enc,
+		remotes:   make(map[string]Remote),
+	}
+}
+
+func key(userID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"user_id": &types.AttributeValueMemberS{Value: userID},
+	}
+}
+
+// Put stores r, replacing the user's remote. r.Token is encrypted if it is
+// set; otherwise r.EncryptedToken is kept.
+func (s *Store) Put(ctx context.Context, r Remote) error {
+	if r.Token != "" {
+		encrypted, err := s.enc.Encrypt(ctx, r.Token, tokenContext(r.UserID))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt git token: %w", err)
+		}
+		r.EncryptedToken = encrypted
+		r.Token = ""
+	}
+	if r.EncryptedToken == "" {
+		return errors.New("git remote has no token")
+	}
+
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.remotes[r.UserID] = r
+		return nil
+	}
+
+	item, err := attributevalue.MarshalMap(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal git remote: %w", err)
+	}
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save git remote: %w", err)
+	}
+	return nil
+}
+
+// Get returns the user's remote without its token, or ErrNotConfigured.
+func (s *Store) Get(ctx context.Context, userID string) (*Remote, error) {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		r, ok := s.remotes[userID]
+		if !ok {
+			return nil, ErrNotConfigured
+		}
+		return &r, nil
+	}
+
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       key(userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git remote: %w", err)
+	}
+	if out.Item == nil {
+		return nil, ErrNotConfigured
+	}
+	var r Remote
+	if err := attributevalue.UnmarshalMap(out.Item, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal git remote: %w", err)
+	}
+	return &r, nil
+}
+
+// DecryptToken sets r.Token from r.EncryptedToken. A token encrypted under
+// a retired key is stored again under the current one.
+func (s *Store) DecryptToken(ctx context.Context, r *Remote) error {
+	token, stale, err := crypto.DecryptStale(ctx, s.enc, r.EncryptedToken, tokenContext(r.UserID))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt git token: %w", err)
+	}
+	r.Token = token
+	if stale {
+		refreshed := *r
+		refreshed.EncryptedToken = ""
+		if err := s.Put(ctx, refreshed); err != nil {
+			slog.WarnContext(ctx, "Re-encrypting git token failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// RecordExport notes that an export committed commit at t. It does nothing
+// if the remote was deleted meanwhile.
+func (s *Store) RecordExport(ctx context.Context, userID, commit string, t time.Time) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r, ok := s.remotes[userID]; ok {
+			r.LastExportAt = &t
+			r.LastCommit = commit
+			s.remotes[userID] = r
+		}
+		return nil
+	}
+
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.tableName),
+		Key:                 key(userID),
+		UpdateExpression:    aws.String("SET last_export_at = :at, last_commit = :commit"),
+		ConditionExpression: aws.String("attribute_exists(user_id)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":at":     &types.AttributeValueMemberS{Value: t.Format(time.RFC3339Nano)},
+			":commit": &types.AttributeValueMemberS{Value: commit},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionFailed) {
+		return fmt.Errorf("failed to record git export: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the user's remote. Deleting a remote that does not exist
+// is not an error.
+func (s *Store) Delete(ctx context.Context, userID string) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.remotes, userID)
+		return nil
+	}
+
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       key(userID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete git remote: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package gitexport
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/crypto"
+)
+
+// fakeHost serves the parts of the GitHub and GitLab APIs that Export
+// uses, for one repository.
+type fakeHost struct {
+	mu       sync.Mutex
+	files    map[string]string // path to content
+	messages []string
+}
+
+func (f *fakeHost) tree() map[string]string {
+	shas := make(map[string]string)
+	for p, content := range f.files {
+		shas[p] = BlobSHA([]byte(content))
+	}
+	return shas
+}
+
+func (f *fakeHost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fail := func(status int, msg string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"message": msg})
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/github/"):
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			fail(http.StatusUnauthorized, "Bad credentials")
+			return
+		}
+		p, _ := strings.CutPrefix(r.URL.Path, "/github/repos/me/notes/")
+		if strings.HasPrefix(p, "git/trees/") {
+			if len(f.files) == 0 {
+				fail(http.StatusConflict, "Git Repository is empty.")
+				return
+			}
+			var tree []map[string]string
+			for path, sha := range f.tree() {
+				tree = append(tree, map[string]string{"path": path, "type": "blob", "sha": sha})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"tree": tree})
+			return
+		}
+		p, _ = strings.CutPrefix(p, "contents/")
+		var input struct {
+			Message, Content, SHA, Branch string
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		if sha, ok := f.tree()[p]; ok != (input.SHA != "") || ok && sha != input.SHA {
+			fail(http.StatusConflict, "sha does not match")
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.files, p)
+		} else {
+			content, _ := base64.StdEncoding.DecodeString(input.Content)
+			f.files[p] = string(content)
+		}
+		f.messages = append(f.messages, input.Message)
+		json.NewEncoder(w).Encode(map[string]any{"commit": map[string]string{"sha": "c" + strconv.Itoa(len(f.messages))}})
+
+	case r.URL.Path == "/gitlab/projects/me/notes/repository/tree":
+		if len(f.files) == 0 {
+			fail(http.StatusNotFound, "404 Tree Not Found")
+			return
+		}
+		// One file per page.
+		var paths []string
+		for p := range f.files {
+			paths = append(paths, p)
+		}
+		slices.Sort(paths)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < len(paths) {
+			w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+		}
+		p := paths[page-1]
+		json.NewEncoder(w).Encode([]map[string]string{{"id": BlobSHA([]byte(f.files[p])), "path": p, "type": "blob"}})
+
+	case r.URL.Path == "/gitlab/projects/me/notes/repository/commits":
+		var input struct {
+			Message string `json:"commit_message"`
+			Actions []struct {
+				Action, Content string
+				Path            string `json:"file_path"`
+			}
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		for _, a := range input.Actions {
+			if _, ok := f.files[a.Path]; ok != (a.Action != "create") {
+				fail(http.StatusBadRequest, "A file with this name already exists")
+				return
+			}
+			if a.Action == "delete" {
+				delete(f.files, a.Path)
+			} else {
+				content, _ := base64.StdEncoding.DecodeString(a.Content)
+				f.files[a.Path] = string(content)
+			}
+		}
+		f.messages = append(f.messages, input.Message)
+		json.NewEncoder(w).Encode(map[string]string{"id": "c" + strconv.Itoa(len(f.messages))})
+
+	default:
+		fail(http.StatusNotFound, "Not Found")
+	}
+}
+
+func newFakeHost(t *testing.T, files map[string]string) (*fakeHost, *Client) {
+	t.Helper()
+	host := &fakeHost{files: files}
+	srv := httptest.NewServer(host)
+	t.Cleanup(srv.Close)
+	c := NewClient(srv.Client())
+	c.GitHubURL = srv.URL + "/github"
+	c.GitLabURL = srv.URL + "/gitlab"
+	return host, c
+}
+
+func TestExport(t *testing.T) {
+	for _, provider := range []string{ProviderGitHub, ProviderGitLab} {
+		t.Run(provider, func(t *testing.T) {
+			ctx := context.Background()
+			host, client := newFakeHost(t, map[string]string{})
+			repo, _ := client.Open(Remote{Provider: provider, Repository: "me/notes", Branch: "main", Token: "secret"})
+
+			notes := map[string][]byte{
+				"Inbox.md":     []byte("todo\n"),
+				"Work/Plan.md": []byte("# Plan\n"),
+			}
+			res, err := Export(ctx, repo, notes, 10)
+			if err != nil || len(res.Commits) != 2 || res.Unchanged != 0 || res.Remaining != 0 {
+				t.Fatalf("first export = %+v, %v", res, err)
+			}
+			if host.files["Work/Plan.md"] != "# Plan\n" || host.messages[0] != "Add Inbox.md\n\nExported from GophDrive." {
+				t.Errorf("repository = %q, messages %q", host.files, host.messages)
+			}
+
+			// Files other than notes are left alone.
+			host.files["README.txt"] = "about"
+			notes["Work/Plan.md"] = []byte("# Plan v2\n")
+			delete(notes, "Inbox.md")
+			notes["New.md"] = []byte("new")
+			res, err = Export(ctx, repo, notes, 2)
+			want := []Commit{{"Inbox.md", ActionDelete, "c3"}, {"New.md", ActionAdd, "c4"}}
+			if err != nil || !slices.Equal(res.Commits, want) || res.Remaining != 1 || res.Unchanged != 0 {
+				t.Fatalf("second export = %+v, %v", res, err)
+			}
+			res, err = Export(ctx, repo, notes, 2)
+			if err != nil || len(res.Commits) != 1 || res.Commits[0].Action != ActionUpdate || res.Unchanged != 1 || res.Remaining != 0 {
+				t.Fatalf("third export = %+v, %v", res, err)
+			}
+			if host.files["README.txt"] != "about" || host.files["Work/Plan.md"] != "# Plan v2\n" || len(host.files) != 3 {
+				t.Errorf("repository = %q", host.files)
+			}
+			if res, _ := Export(ctx, repo, notes, 2); len(res.Commits) != 0 || res.Unchanged != 2 {
+				t.Errorf("export without changes = %+v", res)
+			}
+		})
+	}
+}
+
+func TestExport_RemoteError(t *testing.T) {
+	_, client := newFakeHost(t, map[string]string{"A.md": "a"})
+	repo, _ := client.Open(Remote{Provider: ProviderGitHub, Repository: "me/notes", Branch: "main", Token: "wrong"})
+	_, err := Export(context.Background(), repo, map[string][]byte{"A.md": []byte("b")}, 10)
+	if !errors.Is(err, ErrRemote) || !strings.Contains(err.Error(), "GitHub returned 401: Bad credentials") {
+		t.Errorf("Export with a bad token error = %v", err)
+	}
+}
+
+func TestDiff_KeepsNilContent(t *testing.T) {
+	files := map[string]string{"Twin.md": "sha", "Old.md": "sha"}
+	changes := Diff(map[string][]byte{"Twin.md": nil}, files)
+	if len(changes) != 1 || changes[0].Path != "Old.md" || changes[0].Action != ActionDelete {
+		t.Errorf("Diff = %+v", changes)
+	}
+}
+
+func TestBlobSHA(t *testing.T) {
+	// git hash-object of "hello\n"
+	if got := BlobSHA([]byte("hello\n")); got != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("BlobSHA = %s", got)
+	}
+}
+
+func TestRemote_Validate(t *testing.T) {
+	valid := []Remote{
+		{Provider: ProviderGitHub, Repository: "me/notes"},
+		{Provider: ProviderGitLab, Repository: "group/sub/notes", Branch: "backup/notes"},
+	}
+	for i := range valid {
+		if err := valid[i].Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", valid[i], err)
+		}
+	}
+	if valid[0].Branch != DefaultBranch {
+		t.Errorf("default branch = %q", valid[0].Branch)
+	}
+	invalid := []Remote{
+		{Provider: "bitbucket", Repository: "me/notes"},
+		{Provider: ProviderGitHub, Repository: "group/sub/notes"},
+		{Provider: ProviderGitLab, Repository: "notes"},
+		{Provider: ProviderGitHub, Repository: "me/notes", Branch: "a..b"},
+		{Provider: ProviderGitHub, Repository: "me/notes", Branch: "has space"},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", r)
+		}
+	}
+}
+
+func TestStore_EncryptsToken(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil, "", crypto.NewAESEncryptor(crypto.DeriveKey("test", "git")))
+	if _, err := s.Get(ctx, "u1"); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("Get before Put error = %v", err)
+	}
+	if err := s.Put(ctx, Remote{UserID: "u1", Provider: ProviderGitHub, Repository: "me/notes", Token: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Get(ctx, "u1")
+	if err != nil || r.Token != "" || r.EncryptedToken == "" || strings.Contains(r.EncryptedToken, "secret") {
+		t.Fatalf("Get = %+v, %v", r, err)
+	}
+	if err := s.DecryptToken(ctx, r); err != nil || r.Token != "secret" {
+		t.Errorf("DecryptToken = %q, %v", r.Token, err)
+	}
+
+	// The token only decrypts for its own user.
+	r.UserID = "u2"
+	if err := s.DecryptToken(ctx, r); err == nil {
+		t.Error("another user's token decrypted")
+	}
+}
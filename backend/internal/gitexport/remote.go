@@ -0,0 +1,274 @@
+package gitexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Repository is a branch of a Git repository on a host.
+type Repository interface {
+	// Files returns the blob SHA of each file on the branch by path. A
+	// branch or repository without commits has no files.
+	Files(ctx context.Context) (map[string]string, error)
+	// Commit applies c to the branch as a commit of its own and returns
+	// the commit's SHA.
+	Commit(ctx context.Context, c Change) (string, error)
+}
+
+// Client opens repositories on GitHub and GitLab through their REST APIs.
+// GitHubURL and GitLabURL can point it at GitHub Enterprise, a
+// self-managed GitLab or a test server.
+type Client struct {
+	HTTP      *http.Client
+	GitHubURL string
+	GitLabURL string
+}
+
+// NewClient creates a Client for github.com and gitlab.com. If httpClient
+// is nil, http.DefaultClient is used.
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		HTTP:      httpClient,
+		GitHubURL: "https://api.github.com",
+		GitLabURL: "https://gitlab.com/api/v4",
+	}
+}
+
+// Open returns r's branch. r.Token must be set.
+func (c *Client) Open(r Remote) (Repository, error) {
+	switch r.Provider {
+	case ProviderGitHub:
+		return &github{c: c, repo: r.Repository, branch: r.Branch, token: r.Token}, nil
+	case ProviderGitLab:
+		return &gitlab{c: c, project: url.PathEscape(r.Repository), branch: r.Branch, token: r.Token}, nil
+	}
+	return nil, fmt.Errorf("unknown git provider %q", r.Provider)
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response
+// into out, if it is not nil. Error responses wrap ErrRemote and carry the
+// host's message.
+func (c *Client) do(ctx context.Context, host, method, u string, header http.Header, body, out any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", host, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read %s response: %w", host, err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return resp, fmt.Errorf("%w: %s returned %d: %s", ErrRemote, host, resp.StatusCode, errorMessage(resp.StatusCode, data))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp, fmt.Errorf("decode %s response: %w", host, err)
+		}
+	}
+	return resp, nil
+}
+
+// errorMessage extracts the message of a GitHub or GitLab error response.
+func errorMessage(status int, data []byte) string {
+	var e struct {
+		Message json.RawMessage `json:"message"`
+		Error   string          `json:"error"`
+	}
+	if json.Unmarshal(data, &e) == nil {
+		var s string
+		switch {
+		case json.Unmarshal(e.Message, &s) == nil && s != "":
+			return s
+		case len(e.Message) > 0:
+			return string(e.Message) // GitLab reports validation errors as an object
+		case e.Error != "":
+			return e.Error
+		}
+	}
+	return http.StatusText(status)
+}
+
+// escapePath escapes each segment of a file path for use in a URL.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// github commits through the contents API, which makes one commit per
+// file.
+type github struct {
+	c                   *Client
+	repo, branch, token string
+}
+
+func (g *github) header() http.Header {
+	return http.Header{
+		"Authorization":        {"Bearer " + g.token},
+		"Accept":               {"application/vnd.github+json"},
+		"X-Github-Api-Version": {"2022-11-28"},
+	}
+}
+
+func (g *github) url(p string) string {
+	return strings.TrimSuffix(g.c.GitHubURL, "/") + "/repos/" + g.repo + p
+}
+
+func (g *github) Files(ctx context.Context) (map[string]string, error) {
+	var tree struct {
+		Tree []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+			SHA  string `json:"sha"`
+		} `json:"tree"`
+		Truncated bool `json:"truncated"`
+	}
+	resp, err := g.c.do(ctx, "GitHub", http.MethodGet, g.url("/git/trees/"+url.PathEscape(g.branch)+"?recursive=1"), g.header(), nil, &tree)
+	if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusConflict) {
+		// 404 for a branch that does not exist yet; 409 for an empty
+		// repository. A repository that does not exist is reported when
+		// the first commit fails.
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tree.Truncated {
+		return nil, fmt.Errorf("%w: the repository has too many files to list", ErrRemote)
+	}
+	files := make(map[string]string, len(tree.Tree))
+	for _, e := range tree.Tree {
+		if e.Type == "blob" {
+			files[e.Path] = e.SHA
+		}
+	}
+	return files, nil
+}
+
+func (g *github) Commit(ctx context.Context, c Change) (string, error) {
+	input := map[string]any{
+		"message": c.Message(),
+		"branch":  g.branch,
+	}
+	method := http.MethodPut
+	if c.Action == ActionDelete {
+		method = http.MethodDelete
+	} else {
+		input["content"] = base64.StdEncoding.EncodeToString(c.Content)
+	}
+	if c.SHA != "" {
+		input["sha"] = c.SHA
+	}
+
+	var out struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	if _, err := g.c.do(ctx, "GitHub", method, g.url("/contents/"+escapePath(c.Path)), g.header(), input, &out); err != nil {
+		return "", err
+	}
+	return out.Commit.SHA, nil
+}
+
+// gitlab commits through the commits API, one action per commit.
+type gitlab struct {
+	c                      *Client
+	project, branch, token string
+}
+
+func (g *gitlab) header() http.Header {
+	return http.Header{"Private-Token": {g.token}}
+}
+
+func (g *gitlab) url(p string) string {
+	return strings.TrimSuffix(g.c.GitLabURL, "/") + "/projects/" + g.project + p
+}
+
+func (g *gitlab) Files(ctx context.Context) (map[string]string, error) {
+	files := make(map[string]string)
+	for page := "1"; page != ""; {
+		query := url.Values{"ref": {g.branch}, "recursive": {"true"}, "per_page": {"100"}, "page": {page}}
+		var entries []struct {
+			ID   string `json:"id"`
+			Path string `json:"path"`
+			Type string `json:"type"`
+		}
+		resp, err := g.c.do(ctx, "GitLab", http.MethodGet, g.url("/repository/tree?"+query.Encode()), g.header(), nil, &entries)
+		if resp != nil && resp.StatusCode == http.StatusNotFound && page == "1" {
+			// "Tree Not Found" for an empty repository or a new branch.
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.Type == "blob" {
+				files[e.Path] = e.ID
+			}
+		}
+		page = resp.Header.Get("X-Next-Page")
+		if _, err := strconv.Atoi(page); err != nil {
+			page = ""
+		}
+	}
+	return files, nil
+}
+
+func (g *gitlab) Commit(ctx context.Context, c Change) (string, error) {
+	action := map[string]any{
+		"action":    map[string]string{ActionAdd: "create", ActionUpdate: "update", ActionDelete: "delete"}[c.Action],
+		"file_path": c.Path,
+	}
+	if c.Action != ActionDelete {
+		action["content"] = base64.StdEncoding.EncodeToString(c.Content)
+		action["encoding"] = "base64"
+	}
+	input := map[string]any{
+		"branch":         g.branch,
+		"commit_message": c.Message(),
+		"actions":        []any{action},
+	}
+
+	var out struct {
+		ID string `json:"id"`
+	}
+	if _, err := g.c.do(ctx, "GitLab", http.MethodPost, g.url("/repository/commits"), g.header(), input, &out); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/jun/gophdrive/backend/internal/accesstoken"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+)
+
+const (
+	// defaultAccessTokenDays is how long a new access token lasts when the
+	// request does not say.
+	defaultAccessTokenDays = 90
+	// maxAccessTokenDays is the longest lifetime a token can be given.
+	maxAccessTokenDays = 365
+	// maxAccessTokenName bounds the description of a token.
+	maxAccessTokenName = 64
+)
+
+// AccessTokenHandler manages personal access tokens, which let scripts and
+// the command-line client call the API without a browser session.
+type AccessTokenHandler struct {
+	store     *accesstoken.Store
+	signer    *crypto.Signer
+	jwtSecret string
+}
+
+// NewAccessTokenHandler creates a new AccessTokenHandler.
+func NewAccessTokenHandler(store *accesstoken.Store, signer *crypto.Signer, jwtSecret string) *AccessTokenHandler {
+	return &AccessTokenHandler{store: store, signer: signer, jwtSecret: jwtSecret}
+}
+
+// CreatedAccessToken is the response of CreateAccessToken. Secret is the
+// token itself; it is only ever returned here.
+type CreatedAccessToken struct {
+	accesstoken.Token
+	Secret string `json:"token"`
+}
+
+// CreateAccessToken handles POST /auth/tokens with {name, expiresInDays},
+// returning the new token with 201. Tokens cannot be created with another
+// token, so a leaked token cannot be used to mint more.
+func (h *AccessTokenHandler) CreateAccessToken(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, err := requestUserClaims(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+	if claims.AccessTokenID != "" {
+		return Error(ctx, http.StatusForbidden, "Access tokens can only be created from a signed-in session"), nil
+	}
+
+	var input struct {
+		Name          string `json:"name"`
+		ExpiresInDays int    `json:"expiresInDays"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > maxAccessTokenName {
+		return Error(ctx, http.StatusBadRequest, fmt.Sprintf("Token name must be 1 to %d characters", maxAccessTokenName)), nil
+	}
+	if input.ExpiresInDays == 0 {
+		input.ExpiresInDays = defaultAccessTokenDays
+	}
+	if input.ExpiresInDays < 1 || input.ExpiresInDays > maxAccessTokenDays {
+		return Error(ctx, http.StatusBadRequest, fmt.Sprintf("expiresInDays must be between 1 and %d", maxAccessTokenDays)), nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	t := accesstoken.Token{
+		UserID:    claims.UserID,
+		ID:        uuid.New().String(),
+		Name:      input.Name,
+		Email:     claims.Email,
+		UserName:  claims.Name,
+		CreatedAt: now,
+		ExpiresAt: now.AddDate(0, 0, input.ExpiresInDays),
+	}
+	secret, err := accesstoken.Sign(ctx, h.signer, t)
+	if err != nil {
+		slog.ErrorContext(ctx, "Signing access token failed", "error", err)
+		return InternalError(ctx), nil
+	}
+	if err := h.store.Create(ctx, t); err != nil {
+		return respondError(ctx, "CreateAccessToken", err), nil
+	}
+
+	body, _ := json.Marshal(CreatedAccessToken{Token: t, Secret: secret})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusCreated,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// ListAccessTokens handles GET /auth/tokens, returning the caller's
+// unexpired tokens without their secrets, newest first.
+func (h *AccessTokenHandler) ListAccessTokens(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	tokens, err := h.store.List(ctx, userID)
+	if err != nil {
+		return respondError(ctx, "ListAccessTokens", err), nil
+	}
+
+	body, _ := json.Marshal(tokens)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// DeleteAccessToken handles DELETE /auth/tokens/{id}, revoking the token at
+// once.
+func (h *AccessTokenHandler) DeleteAccessToken(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+	id := req.PathParameters["id"]
+	if id == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing token ID"), nil
+	}
+
+	if err := h.store.Delete(ctx, userID, id); err != nil {
+		return respondError(ctx, "DeleteAccessToken", err), nil
+	}
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, nil
+}
+
+// Authenticate returns the claims of a request that carries a personal
+// access token as its bearer token. It fails for any other request and for
+// tokens that are invalid, expired or revoked.
+func (h *AccessTokenHandler) Authenticate(ctx context.Context, req events.APIGatewayProxyRequest) (*UserClaims, error) {
+	secret, ok := strings.CutPrefix(requestHeader(req, "Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(secret, accesstoken.Prefix) {
+		return nil, errors.New("no access token found")
+	}
+	userID, id, err := accesstoken.Parse(ctx, h.signer, secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access token: %w", err)
+	}
+	t, err := h.store.Get(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("access token %s: %w", id, err)
+	}
+	return &UserClaims{UserID: t.UserID, Email: t.Email, Name: t.UserName, AccessTokenID: t.ID}, nil
+}
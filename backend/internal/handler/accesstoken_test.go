@@ -0,0 +1,69 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/accesstoken"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+func TestAccessTokenHandler(t *testing.T) {
+	h := handler.NewAccessTokenHandler(accesstoken.NewStore(nil, ""), crypto.NewSigner(secret.Static("signing-key")), "test-secret")
+	ctx := context.Background()
+
+	resp, _ := h.CreateAccessToken(ctx, makeRequest("POST", "/auth/tokens", `{"name":"laptop"}`))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var created handler.CreatedAccessToken
+	json.Unmarshal([]byte(resp.Body), &created)
+	if created.Secret == "" || created.Name != "laptop" || created.ExpiresAt.Sub(created.CreatedAt).Hours() != 90*24 {
+		t.Fatalf("Unexpected token %+v", created)
+	}
+
+	// The token authenticates requests as its owner.
+	req := makeRequest("GET", "/notes", "")
+	req.Headers["Authorization"] = "Bearer " + created.Secret
+	claims, err := h.Authenticate(ctx, req)
+	if err != nil || claims.UserID != testUserID || claims.AccessTokenID != created.ID {
+		t.Fatalf("Authenticate = %+v, %v", claims, err)
+	}
+	// but cannot create more tokens.
+	if resp, _ := h.CreateAccessToken(handler.WithUserClaims(ctx, claims), makeRequest("POST", "/auth/tokens", `{"name":"more"}`)); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 creating a token with a token, got %d", resp.StatusCode)
+	}
+	// Session JWTs are not access tokens.
+	if _, err := h.Authenticate(ctx, makeRequest("GET", "/notes", "")); err == nil {
+		t.Error("Authenticate accepted a session JWT")
+	}
+
+	resp, _ = h.ListAccessTokens(ctx, makeRequest("GET", "/auth/tokens", ""))
+	var tokens []map[string]any
+	json.Unmarshal([]byte(resp.Body), &tokens)
+	if len(tokens) != 1 || tokens[0]["id"] != created.ID || tokens[0]["token"] != nil {
+		t.Errorf("Unexpected tokens %v", tokens)
+	}
+
+	del := makeRequest("DELETE", "/auth/tokens/"+created.ID, "")
+	del.PathParameters["id"] = created.ID
+	if resp, _ := h.DeleteAccessToken(ctx, del); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if _, err := h.Authenticate(ctx, req); err == nil {
+		t.Error("Authenticate accepted a revoked token")
+	}
+	if resp, _ := h.DeleteAccessToken(ctx, del); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 deleting twice, got %d", resp.StatusCode)
+	}
+
+	for _, body := range []string{`{"name":""}`, `{"name":"x","expiresInDays":400}`} {
+		if resp, _ := h.CreateAccessToken(ctx, makeRequest("POST", "/auth/tokens", body)); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, resp.StatusCode)
+		}
+	}
+}
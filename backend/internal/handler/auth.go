@@ -2,8 +2,12 @@ package handler
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"time"
@@ -13,6 +17,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jun/gophdrive/backend/internal/adapter"
 	"github.com/jun/gophdrive/backend/internal/auth"
+	"github.com/jun/gophdrive/backend/internal/crypto"
 	xoauth2 "golang.org/x/oauth2"
 	"google.golang.org/api/oauth2/v2"
 	"google.golang.org/api/option"
@@ -23,6 +28,7 @@ type AuthHandler struct {
 	authService     *auth.AuthService
 	storageProvider adapter.StorageProvider
 	jwtSecret       string
+	signer          *crypto.Signer
 }
 
 // NewAuthHandler creates a new AuthHandler.
@@ -30,45 +36,95 @@ func NewAuthHandler(s *auth.AuthService, sp adapter.StorageProvider, jwtSecret s
 	return &AuthHandler{authService: s, storageProvider: sp, jwtSecret: jwtSecret}
 }
 
+// SetSigner makes Login sign the OAuth state and Callback verify it, so a
+// sign-in can only be completed in the browser that started it.
+func (h *AuthHandler) SetSigner(signer *crypto.Signer) {
+	h.signer = signer
+}
+
+const (
+	// oauthStatePurpose is the crypto.Signer purpose of OAuth state tokens.
+	oauthStatePurpose = "oauth_state"
+	// oauthStateTTL is how long the user has to complete Google sign-in.
+	oauthStateTTL = 10 * time.Minute
+	// oauthStateCookie holds the nonce that the state must carry.
+	oauthStateCookie = "oauth_state"
+)
+
 // Login initiates the Google OAuth2 flow.
 func (h *AuthHandler) Login(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// TODO: Generate a secure random state and store it in a cookie to prevent CSRF
-	state := "random-state"
-	url := h.authService.GenerateAuthURL(state)
+	if h.signer == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusFound,
+			Headers: map[string]string{
+				"Location": h.authService.GenerateAuthURL("random-state"),
+			},
+		}, nil
+	}
+
+	// The state carries a nonce that is also set as a cookie; Callback
+	// accepts it only from the browser holding that cookie.
+	nonce := rand.Text()
+	state, err := h.signer.Sign(ctx, oauthStatePurpose, nonce, oauthStateTTL)
+	if err != nil {
+		return respondError(ctx, "Sign OAuth state", err), nil
+	}
+	cookie := fmt.Sprintf("%s=%s; HttpOnly; Path=/; Max-Age=%d; SameSite=Lax; Secure", oauthStateCookie, nonce, int(oauthStateTTL.Seconds()))
 
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusFound,
 		Headers: map[string]string{
-			"Location": url,
+			"Location": h.authService.GenerateAuthURL(state),
+		},
+		MultiValueHeaders: map[string][]string{
+			"Set-Cookie": {cookie},
 		},
 	}, nil
 }
 
+// verifyState checks that the OAuth state was issued by Login to the
+// browser making the request.
+func (h *AuthHandler) verifyState(ctx context.Context, req events.APIGatewayProxyRequest) error {
+	if h.signer == nil {
+		return nil
+	}
+	nonce, err := h.signer.Verify(ctx, oauthStatePurpose, req.QueryStringParameters["state"])
+	if err != nil {
+		return err
+	}
+	if cookie := cookieValue(req, oauthStateCookie); cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(nonce)) != 1 {
+		return errors.New("OAuth state does not match this browser")
+	}
+	return nil
+}
+
 // Callback handles the OAuth2 callback from Google.
 func (h *AuthHandler) Callback(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if err := h.verifyState(ctx, req); err != nil {
+		slog.WarnContext(ctx, "Rejected OAuth callback", "error", err)
+		return Error(ctx, http.StatusBadRequest, "Sign-in expired or was started elsewhere; please sign in again"), nil
+	}
+
 	code := req.QueryStringParameters["code"]
 	if code == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing code"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing code"), nil
 	}
 
 	// Exchange code for token
 	token, err := h.authService.ExchangeCode(ctx, code)
 	if err != nil {
-		fmt.Printf("ExchangeCode error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to exchange code"}, nil
+		return respondError(ctx, "ExchangeCode", err), nil
 	}
 
 	// Get User Info from Google
 	oauth2Service, err := oauth2.NewService(ctx, option.WithTokenSource(h.authService.Config().TokenSource(ctx, token)))
 	if err != nil {
-		fmt.Printf("NewService error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to create oauth2 service"}, nil
+		return respondError(ctx, "NewService", err), nil
 	}
 
 	userinfo, err := oauth2Service.Userinfo.Get().Do()
 	if err != nil {
-		fmt.Printf("Userinfo error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to get user info"}, nil
+		return respondError(ctx, "Userinfo", err), nil
 	}
 
 	// Save Token (Refresh Token) to DynamoDB
@@ -77,7 +133,7 @@ func (h *AuthHandler) Callback(ctx context.Context, req events.APIGatewayProxyRe
 
 	err = h.authService.SaveToken(ctx, userID, token)
 	if err != nil {
-		fmt.Printf("SaveToken error: %v\n", err)
+		slog.ErrorContext(ctx, "SaveToken failed", "error", err)
 		// Proceed even if saving refresh token failed (e.g. no refresh token returned on subsequent login)
 		// Ideally we should warn or handle this better.
 	}
@@ -93,7 +149,7 @@ func (h *AuthHandler) Callback(ctx context.Context, req events.APIGatewayProxyRe
 
 	signedToken, err := jwtToken.SignedString([]byte(h.jwtSecret))
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to sign token"}, nil
+		return Error(ctx, http.StatusInternalServerError, "Failed to sign token"), nil
 	}
 
 	// Redirect to Frontend with success
@@ -112,6 +168,7 @@ func (h *AuthHandler) Callback(ctx context.Context, req events.APIGatewayProxyRe
 
 	// Set secure httpOnly cookie
 	cookie := fmt.Sprintf("session_token=%s; HttpOnly; Path=/; Max-Age=86400; SameSite=%s; Secure", signedToken, sameSite)
+	clearState := fmt.Sprintf("%s=; HttpOnly; Path=/; Max-Age=0; SameSite=Lax; Secure", oauthStateCookie)
 
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusFound,
@@ -119,7 +176,7 @@ func (h *AuthHandler) Callback(ctx context.Context, req events.APIGatewayProxyRe
 			"Location": fmt.Sprintf("%s/?success=true", frontendURL),
 		},
 		MultiValueHeaders: map[string][]string{
-			"Set-Cookie": {cookie},
+			"Set-Cookie": {cookie, clearState},
 		},
 	}, nil
 }
@@ -127,23 +184,21 @@ func (h *AuthHandler) Callback(ctx context.Context, req events.APIGatewayProxyRe
 // ListDriveFolders lists the root folders in Google Drive (or Memory).
 func (h *AuthHandler) ListDriveFolders(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// 1. Validate Session
-	userID, err := GetUserID(req, h.jwtSecret)
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: "Unauthorized"}, nil
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
 	}
 
 	// 2. Get Adapter
 	adapter, err := h.storageProvider.GetAdapter(ctx, userID)
 	if err != nil {
-		fmt.Printf("GetAdapter error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to get storage adapter"}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	// 3. List Root Folders
 	folders, err := adapter.ListRootFolders(ctx)
 	if err != nil {
-		fmt.Printf("ListRootFolders error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to list folders"}, nil
+		return respondError(ctx, "ListRootFolders", err), nil
 	}
 
 	body, _ := json.Marshal(folders)
@@ -159,15 +214,15 @@ func (h *AuthHandler) ListDriveFolders(ctx context.Context, req events.APIGatewa
 // GetUser returns the current user's profile.
 func (h *AuthHandler) GetUser(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// 1. Validate Session
-	userID, err := GetUserID(req, h.jwtSecret)
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: "Unauthorized"}, nil
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
 	}
 
 	// 2. Get User Token (Profile)
 	token, err := h.authService.GetUserToken(ctx, userID)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to get user profile"}, nil
+		return Error(ctx, http.StatusInternalServerError, "Failed to get user profile"), nil
 	}
 
 	// 3. Return Profile
@@ -189,9 +244,9 @@ func (h *AuthHandler) GetUser(ctx context.Context, req events.APIGatewayProxyReq
 // UpdateUser updates user settings.
 func (h *AuthHandler) UpdateUser(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// 1. Validate Session
-	userID, err := GetUserID(req, h.jwtSecret)
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: "Unauthorized"}, nil
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
 	}
 
 	// 2. Parse Body
@@ -199,14 +254,13 @@ func (h *AuthHandler) UpdateUser(ctx context.Context, req events.APIGatewayProxy
 		BaseFolderID string `json:"base_folder_id"`
 	}
 	if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
 	}
 
 	// 3. Update BaseFolderID
 	if body.BaseFolderID != "" {
 		if err := h.authService.UpdateBaseFolderID(ctx, userID, body.BaseFolderID); err != nil {
-			fmt.Printf("UpdateBaseFolderID error: %v\n", err)
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to update user settings"}, nil
+			return respondError(ctx, "UpdateBaseFolderID", err), nil
 		}
 	}
 
@@ -228,15 +282,15 @@ func (h *AuthHandler) DemoLogin(ctx context.Context, req events.APIGatewayProxyR
 	// Get Storage Adapter for this user to create root folder
 	storage, err := h.storageProvider.GetAdapter(ctx, userID)
 	if err != nil {
-		fmt.Printf("DemoLogin GetAdapter error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to get storage adapter"}, nil
+		slog.ErrorContext(ctx, "DemoLogin GetAdapter failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to get storage adapter"), nil
 	}
 
 	// Create Root Folder
 	rootFolderID, err := storage.EnsureRootFolder(ctx, "Demo Notes")
 	if err != nil {
-		fmt.Printf("DemoLogin EnsureRootFolder error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to create root folder"}, nil
+		slog.ErrorContext(ctx, "DemoLogin EnsureRootFolder failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to create root folder"), nil
 	}
 
 	// Save dummy user token to DynamoDB so that GetUser works (and BaseFolderID is set)
@@ -251,13 +305,13 @@ func (h *AuthHandler) DemoLogin(ctx context.Context, req events.APIGatewayProxyR
 	// SaveToken saves the token. UpdateBaseFolderID updates the setting.
 	// Or we can modify authService to allow saving with BaseFolderID, or just call UpdateBaseFolderID after.
 	if err := h.authService.SaveToken(ctx, userID, dummyToken); err != nil {
-		fmt.Printf("DemoLogin SaveToken error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to save demo user token"}, nil
+		slog.ErrorContext(ctx, "DemoLogin SaveToken failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to save demo user token"), nil
 	}
 
 	if err := h.authService.UpdateBaseFolderID(ctx, userID, rootFolderID); err != nil {
-		fmt.Printf("DemoLogin UpdateBaseFolderID error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to set base folder ID"}, nil
+		slog.ErrorContext(ctx, "DemoLogin UpdateBaseFolderID failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to set base folder ID"), nil
 	}
 
 	// Create Welcome Notes
@@ -380,7 +434,7 @@ func main() {
 
 	for _, note := range welcomeNotes {
 		if _, err := storage.CreateFile(ctx, note.Name, []byte(note.Content), rootFolderID); err != nil {
-			fmt.Printf("DemoLogin CreateFile (%s) error: %v\n", note.Name, err)
+			slog.ErrorContext(ctx, "DemoLogin CreateFile failed", "name", note.Name, "error", err)
 			// Continue even if file creation fails for one
 		}
 	}
@@ -395,7 +449,7 @@ func main() {
 
 	signedToken, err := jwtToken.SignedString([]byte(h.jwtSecret))
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to sign token"}, nil
+		return Error(ctx, http.StatusInternalServerError, "Failed to sign token"), nil
 	}
 
 	frontendURL := os.Getenv("FRONTEND_URL")
@@ -3,12 +3,16 @@ package handler
 import (
 	"context"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/jun/gophdrive/backend/internal/adapter/memory"
 	"github.com/jun/gophdrive/backend/internal/auth"
 	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/secret"
+	"golang.org/x/oauth2"
 )
 
 func TestDemoLogin_CreatesWelcomeNotes(t *testing.T) {
@@ -78,3 +82,46 @@ func TestDemoLogin_CreatesWelcomeNotes(t *testing.T) {
 		t.Errorf("English welcome note not found")
 	}
 }
+
+func TestAuthHandler_LoginState(t *testing.T) {
+	authService := auth.NewAuthService(&oauth2.Config{ClientID: "client-id"}, nil, "", crypto.NewMockEncryptor())
+	h := NewAuthHandler(authService, memory.NewProvider(nil, authService), "test-secret")
+	h.SetSigner(crypto.NewSigner(secret.Static("signing-key")))
+	ctx := context.Background()
+
+	resp, _ := h.Login(ctx, events.APIGatewayProxyRequest{})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected 302, got %d", resp.StatusCode)
+	}
+	location, _ := url.Parse(resp.Headers["Location"])
+	state := location.Query().Get("state")
+	cookies := resp.MultiValueHeaders["Set-Cookie"]
+	if state == "" || len(cookies) != 1 || !strings.HasPrefix(cookies[0], "oauth_state=") {
+		t.Fatalf("Expected signed state and cookie, got state %q, cookies %v", state, cookies)
+	}
+	nonceCookie, _, _ := strings.Cut(cookies[0], ";")
+
+	callback := func(state, cookie string) events.APIGatewayProxyResponse {
+		req := events.APIGatewayProxyRequest{
+			QueryStringParameters: map[string]string{"state": state},
+			Headers:               map[string]string{"Cookie": cookie},
+		}
+		resp, _ := h.Callback(ctx, req)
+		return resp
+	}
+	for name, resp := range map[string]events.APIGatewayProxyResponse{
+		"forged state":   callback("random-state", nonceCookie),
+		"missing cookie": callback(state, ""),
+		"other browser":  callback(state, "oauth_state=someone-else"),
+	} {
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(resp.Body, "sign in again") {
+			t.Errorf("%s: expected 400 asking to sign in again, got %d: %s", name, resp.StatusCode, resp.Body)
+		}
+	}
+
+	// A matching state gets past the check to the missing code.
+	resp = callback(state, "theme=dark; "+nonceCookie)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(resp.Body, "Missing code") {
+		t.Errorf("Expected the state to be accepted, got %d: %s", resp.StatusCode, resp.Body)
+	}
+}
@@ -0,0 +1,373 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/board"
+)
+
+// Board is a kanban view of a folder or a note. On a folder board each
+// card is a note, placed by its frontmatter status; on a note board each
+// card is a list item under a "## " heading.
+type Board struct {
+	ID string `json:"id"`
+	// ETag is set on note boards. Send it as If-Match when moving a card.
+	ETag    string        `json:"etag,omitempty"`
+	Columns []BoardColumn `json:"columns"`
+}
+
+// BoardColumn is a column of a board. On a folder board the column named
+// "" holds the notes without a status.
+type BoardColumn struct {
+	Name  string      `json:"name"`
+	Cards []BoardCard `json:"cards"`
+}
+
+// BoardCard is a card on a board. Its ID is the note ID on a folder board
+// and the item's index on a note board.
+type BoardCard struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Done  bool   `json:"done,omitempty"`
+}
+
+// moveCardInput is the body of the card move endpoints. Position counts
+// the cards in the target column without the moved one; if it is omitted
+// the card goes to the end.
+type moveCardInput struct {
+	Card     int    `json:"card"` // note boards only
+	Column   string `json:"column"`
+	Position *int   `json:"position"`
+}
+
+// parseMoveCard decodes and checks the body of a card move.
+func parseMoveCard(ctx context.Context, body string) (moveCardInput, *events.APIGatewayProxyResponse) {
+	var input moveCardInput
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		resp := Error(ctx, http.StatusBadRequest, "Invalid request body")
+		return input, &resp
+	}
+	if input.Position != nil && *input.Position < 0 {
+		resp := Error(ctx, http.StatusBadRequest, "position must not be negative")
+		return input, &resp
+	}
+	return input, nil
+}
+
+// folderCard is a note on a folder board.
+type folderCard struct {
+	note    adapter.FileMetadata
+	content []byte
+	status  string
+	order   float64
+	ordered bool
+}
+
+func newFolderCard(note adapter.FileMetadata, content []byte) folderCard {
+	c := folderCard{note: note, content: content, status: board.Status(content)}
+	c.order, c.ordered = board.Order(content)
+	return c
+}
+
+// compareCards orders a column's cards: by order, then those without one
+// by name.
+func compareCards(a, b folderCard) int {
+	if a.ordered != b.ordered {
+		if a.ordered {
+			return -1
+		}
+		return 1
+	}
+	return cmp.Or(
+		cmp.Compare(a.order, b.order),
+		cmp.Compare(strings.ToLower(a.note.Name), strings.ToLower(b.note.Name)),
+		strings.Compare(a.note.ID, b.note.ID),
+	)
+}
+
+// loadFolderBoard returns the cards of the notes directly in folderID.
+// Archived and encrypted notes are left out.
+func loadFolderBoard(ctx context.Context, storage adapter.StorageAdapter, folderID string) ([]folderCard, error) {
+	files, err := storage.ListFiles(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	notes := slices.DeleteFunc(withoutArchived(files), func(f adapter.FileMetadata) bool {
+		return f.MIMEType == folderMIMEType || f.Encrypted
+	})
+
+	var (
+		mu    sync.Mutex
+		cards []folderCard
+	)
+	err = fetchNotes(ctx, storage, notes, func(n adapter.FileMetadata, file *adapter.File) {
+		if file.Encrypted {
+			return
+		}
+		card := newFolderCard(file.FileMetadata, file.Content)
+		mu.Lock()
+		defer mu.Unlock()
+		cards = append(cards, card)
+	})
+	return cards, err
+}
+
+// folderBoard groups cards into columns: first the named ones, which are
+// listed even when empty, then the other statuses alphabetically, then the
+// notes without a status.
+func folderBoard(folderID string, cards []folderCard, named []string) Board {
+	byStatus := make(map[string][]folderCard)
+	for _, c := range cards {
+		byStatus[c.status] = append(byStatus[c.status], c)
+	}
+	var others []string
+	for status := range byStatus {
+		if status != "" && !slices.Contains(named, status) {
+			others = append(others, status)
+		}
+	}
+	slices.SortFunc(others, func(a, b string) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a), strings.ToLower(b)), strings.Compare(a, b))
+	})
+	names := append(slices.Clone(named), others...)
+	if len(byStatus[""]) > 0 {
+		names = append(names, "")
+	}
+
+	b := Board{ID: folderID, Columns: []BoardColumn{}}
+	for _, name := range names {
+		column := byStatus[name]
+		slices.SortFunc(column, compareCards)
+		col := BoardColumn{Name: name, Cards: []BoardCard{}}
+		for _, c := range column {
+			col.Cards = append(col.Cards, BoardCard{ID: c.note.ID, Title: c.note.Name})
+		}
+		b.Columns = append(b.Columns, col)
+	}
+	return b
+}
+
+// columnOrder parses ?columns=Todo,Doing,Done.
+func columnOrder(req events.APIGatewayProxyRequest) []string {
+	var names []string
+	for name := range strings.SplitSeq(req.QueryStringParameters["columns"], ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// placeOrder returns the order that puts a card at position in column,
+// the sorted cards of its target column without it. Only the moved note is
+// written, so a card placed after one without an order joins the unordered
+// cards, which sort by name.
+func placeOrder(column []folderCard, position int) *float64 {
+	position = min(position, len(column))
+	var order float64
+	switch {
+	case position > 0 && !column[position-1].ordered:
+		return nil
+	case position > 0 && position < len(column) && column[position].ordered:
+		order = (column[position-1].order + column[position].order) / 2
+	case position > 0:
+		order = column[position-1].order + 1
+	case len(column) == 0:
+		return nil
+	case column[0].ordered:
+		order = column[0].order - 1
+	}
+	return &order
+}
+
+// respondBoard returns b as a 200 response.
+func respondBoard(b Board) events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(b)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
+// GetBoard handles GET /boards/{folderId}, the kanban view of the notes in
+// a folder. Each note is a card in the column named by the status field of
+// its frontmatter, ordered by its order field. ?columns=Todo,Doing,Done
+// sets the order of those columns and lists them even when empty.
+func (h *NoteHandler) GetBoard(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+	folderID := req.PathParameters["folderId"]
+	if folderID == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing folder ID"), nil
+	}
+
+	cards, err := loadFolderBoard(ctx, storage, folderID)
+	if err != nil {
+		return respondError(ctx, "GetBoard", err), nil
+	}
+	return respondBoard(folderBoard(folderID, cards, columnOrder(req))), nil
+}
+
+// MoveBoardCard handles PATCH /boards/{folderId}/cards/{cardId}, moving a
+// note to another column or position by rewriting the status and order
+// fields of its frontmatter. The body is {"column": "Done", "position": 0};
+// column "" clears the status. It returns the updated board, taking the
+// same ?columns= as GetBoard.
+func (h *NoteHandler) MoveBoardCard(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+	folderID, cardID := req.PathParameters["folderId"], req.PathParameters["cardId"]
+	if folderID == "" || cardID == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing folder or card ID"), nil
+	}
+	input, errResp := parseMoveCard(ctx, req.Body)
+	if errResp != nil {
+		return *errResp, nil
+	}
+	if errResp := h.checkEditLock(ctx, req, cardID); errResp != nil {
+		return *errResp, nil
+	}
+
+	cards, err := loadFolderBoard(ctx, storage, folderID)
+	if err != nil {
+		return respondError(ctx, "MoveBoardCard", err), nil
+	}
+	i := slices.IndexFunc(cards, func(c folderCard) bool { return c.note.ID == cardID })
+	if i < 0 {
+		return respondError(ctx, "MoveBoardCard", adapter.ErrNotFound), nil
+	}
+	moved := cards[i]
+
+	var column []folderCard
+	for _, c := range cards {
+		if c.status == input.Column && c.note.ID != cardID {
+			column = append(column, c)
+		}
+	}
+	slices.SortFunc(column, compareCards)
+	position := len(column)
+	if input.Position != nil {
+		position = *input.Position
+	}
+
+	content, err := board.Place(moved.content, input.Column, placeOrder(column, position))
+	if err != nil {
+		return respondError(ctx, "Place", err), nil
+	}
+	saved, err := storage.SaveFile(ctx, cardID, content, moved.note.ETag)
+	if err != nil {
+		return respondError(ctx, "SaveFile", err), nil
+	}
+	h.indexReminders(ctx, req, saved, string(content))
+
+	note := *saved
+	note.Name = moved.note.Name // as listed, like the other cards
+	cards[i] = newFolderCard(note, content)
+	return respondBoard(folderBoard(folderID, cards, columnOrder(req))), nil
+}
+
+// noteBoard returns the board of a note.
+func noteBoard(note *adapter.File) Board {
+	b := Board{ID: note.ID, ETag: note.ETag, Columns: []BoardColumn{}}
+	for _, column := range board.Parse(note.Content) {
+		col := BoardColumn{Name: column.Name, Cards: []BoardCard{}}
+		for _, card := range column.Cards {
+			col.Cards = append(col.Cards, BoardCard{ID: strconv.Itoa(card.Index), Title: card.Text, Done: card.Done})
+		}
+		b.Columns = append(b.Columns, col)
+	}
+	return b
+}
+
+// GetNoteBoard handles GET /notes/{id}/board, the kanban view of a single
+// note: its "## " headings are the columns and the list items under them
+// the cards.
+func (h *NoteHandler) GetNoteBoard(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+	id := req.PathParameters["id"]
+	if id == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
+	}
+
+	note, err := storage.GetFile(ctx, id)
+	if err == nil && note.Encrypted {
+		err = adapter.ErrEncrypted
+	}
+	if err != nil {
+		return respondError(ctx, "GetNoteBoard", err), nil
+	}
+	return respondBoard(noteBoard(note)), nil
+}
+
+// MoveNoteBoardCard handles PATCH /notes/{id}/board, moving a list item to
+// another column or position. The body is {"card": 2, "column": "Done",
+// "position": 0}. Card indexes refer to the version in If-Match, so the
+// move fails with 412 if the note has changed since.
+func (h *NoteHandler) MoveNoteBoardCard(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+	id := req.PathParameters["id"]
+	if id == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
+	}
+	input, errResp := parseMoveCard(ctx, req.Body)
+	if errResp != nil {
+		return *errResp, nil
+	}
+	if errResp := h.checkEditLock(ctx, req, id); errResp != nil {
+		return *errResp, nil
+	}
+
+	note, err := storage.GetFile(ctx, id)
+	if err == nil && note.Encrypted {
+		err = adapter.ErrEncrypted
+	}
+	if err != nil {
+		return respondError(ctx, "MoveNoteBoardCard", err), nil
+	}
+	etag := requestHeader(req, "If-Match")
+	if etag == "" {
+		etag = note.ETag
+	}
+
+	position := -1
+	if input.Position != nil {
+		position = *input.Position
+	}
+	content, err := board.MoveCard(note.Content, input.Card, input.Column, position)
+	switch {
+	case errors.Is(err, board.ErrCardNotFound):
+		return Error(ctx, http.StatusNotFound, "Card not found"), nil
+	case errors.Is(err, board.ErrColumnNotFound):
+		return Error(ctx, http.StatusBadRequest, "Column not found"), nil
+	}
+
+	saved, err := storage.SaveFile(ctx, id, content, etag)
+	if err != nil {
+		return respondError(ctx, "SaveFile", err), nil
+	}
+	h.indexReminders(ctx, req, saved, string(content))
+	return respondBoard(noteBoard(&adapter.File{FileMetadata: *saved, Content: content})), nil
+}
@@ -0,0 +1,127 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func decodeBoard(t *testing.T, resp events.APIGatewayProxyResponse) handler.Board {
+	t.Helper()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var b handler.Board
+	json.Unmarshal([]byte(resp.Body), &b)
+	return b
+}
+
+// columnTitles returns each column's name and card titles as
+// "name: a, b" for easy comparison.
+func columnTitles(b handler.Board) []string {
+	var out []string
+	for _, col := range b.Columns {
+		var titles []string
+		for _, c := range col.Cards {
+			titles = append(titles, c.Title)
+		}
+		out = append(out, col.Name+": "+strings.Join(titles, ", "))
+	}
+	return out
+}
+
+func TestNoteHandler_FolderBoard(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	folder, _ := storage.CreateFolder(ctx, "Sprint", nil)
+	storage.CreateFile(ctx, "login.md", []byte("---\nstatus: Doing\n---\n# Login\n"), folder.ID)
+	storage.CreateFile(ctx, "docs.md", []byte("---\nstatus: Todo\norder: 2\n---\n"), folder.ID)
+	api, _ := storage.CreateFile(ctx, "api.md", []byte("---\nstatus: Todo\norder: 1\n---\n"), folder.ID)
+	storage.CreateFile(ctx, "ideas.md", []byte("No status yet.\n"), folder.ID)
+	storage.CreateFile(ctx, "elsewhere.md", []byte("---\nstatus: Todo\n---\n"), "")
+
+	get := makeRequest("GET", "/boards/"+folder.ID, "")
+	get.PathParameters["folderId"] = folder.ID
+	get.QueryStringParameters = map[string]string{"columns": "Todo,Doing,Done"}
+	resp, _ := h.GetBoard(ctx, get)
+	got := columnTitles(decodeBoard(t, resp))
+	want := []string{"Todo: api, docs", "Doing: login", "Done: ", ": ideas"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("Expected columns %q, got %q", want, got)
+	}
+
+	move := makeRequest("PATCH", "/boards/"+folder.ID+"/cards/"+api.ID, `{"column":"Done"}`)
+	move.PathParameters["folderId"] = folder.ID
+	move.PathParameters["cardId"] = api.ID
+	move.QueryStringParameters = get.QueryStringParameters
+	resp, _ = h.MoveBoardCard(ctx, move)
+	got = columnTitles(decodeBoard(t, resp))
+	want = []string{"Todo: docs", "Doing: login", "Done: api", ": ideas"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected columns %q after the move, got %q", want, got)
+	}
+	file, _ := storage.GetFile(ctx, api.ID)
+	if string(file.Content) != "---\nstatus: Done\n---\n" {
+		t.Errorf("Expected the note's status to be rewritten, got %q", file.Content)
+	}
+
+	// Placing a card first in a column orders it before the others.
+	move.PathParameters["cardId"] = api.ID
+	move.Body = `{"column":"Todo","position":0}`
+	resp, _ = h.MoveBoardCard(ctx, move)
+	if got := columnTitles(decodeBoard(t, resp)); got[0] != "Todo: api, docs" {
+		t.Errorf("Expected api first in Todo, got %q", got)
+	}
+
+	move.PathParameters["cardId"] = "missing"
+	if resp, _ := h.MoveBoardCard(ctx, move); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a note outside the folder, got %d", resp.StatusCode)
+	}
+}
+
+func TestNoteHandler_NoteBoard(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "sprint.md", []byte("# Sprint\n\n## Todo\n- write tests\n- plan release\n\n## Done\n"), "")
+
+	get := makeRequest("GET", "/notes/"+note.ID+"/board", "")
+	get.PathParameters["id"] = note.ID
+	resp, _ := h.GetNoteBoard(ctx, get)
+	b := decodeBoard(t, resp)
+	if got := columnTitles(b); strings.Join(got, "|") != "Todo: write tests, plan release|Done: " {
+		t.Fatalf("Unexpected board %q", got)
+	}
+
+	move := makeRequest("PATCH", "/notes/"+note.ID+"/board", `{"card":0,"column":"Done"}`)
+	move.PathParameters["id"] = note.ID
+	move.Headers["If-Match"] = b.ETag
+	resp, _ = h.MoveNoteBoardCard(ctx, move)
+	moved := decodeBoard(t, resp)
+	if got := columnTitles(moved); strings.Join(got, "|") != "Todo: plan release|Done: write tests" {
+		t.Errorf("Unexpected board after the move %q", got)
+	}
+
+	// The second move was made against the old version.
+	resp, _ = h.MoveNoteBoardCard(ctx, move)
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale If-Match, got %d", resp.StatusCode)
+	}
+
+	move.Headers["If-Match"] = moved.ETag
+	move.Body = `{"card":0,"column":"Later"}`
+	if resp, _ := h.MoveNoteBoardCard(ctx, move); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown column, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/collab"
+)
+
+// CollabHandler relays CRDT operations for real-time co-editing.
+type CollabHandler struct {
+	storageProvider adapter.StorageProvider
+	relay           collab.Relay
+	jwtSecret       string
+}
+
+// NewCollabHandler creates a new CollabHandler.
+func NewCollabHandler(provider adapter.StorageProvider, relay collab.Relay, jwtSecret string) *CollabHandler {
+	return &CollabHandler{storageProvider: provider, relay: relay, jwtSecret: jwtSecret}
+}
+
+// CollabOps is the body of both relay endpoints.
+type CollabOps struct {
+	Ops []json.RawMessage `json:"ops"`
+	Seq int               `json:"seq"`
+}
+
+// authorize checks the caller can read the note before touching its op log.
+func (h *CollabHandler) authorize(ctx context.Context, req events.APIGatewayProxyRequest) (string, *events.APIGatewayProxyResponse) {
+	fail := func(resp events.APIGatewayProxyResponse) (string, *events.APIGatewayProxyResponse) {
+		return "", &resp
+	}
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return fail(Error(ctx, http.StatusUnauthorized, "Unauthorized"))
+	}
+
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		return fail(Error(ctx, http.StatusBadRequest, "Missing note ID"))
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		return fail(respondError(ctx, "GetAdapter", fmt.Errorf("%w: %v", ErrUnauthorized, err)))
+	}
+	file, err := storage.GetFile(ctx, noteID)
+	if err != nil {
+		return fail(respondError(ctx, "Collab GetFile", err))
+	}
+	// Ops carry plaintext edits, which would leak an encrypted note.
+	if file.Encrypted {
+		return fail(respondError(ctx, "Collab", adapter.ErrEncrypted))
+	}
+	return noteID, nil
+}
+
+// PushOps handles POST /collab/{id}/ops
+func (h *CollabHandler) PushOps(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	noteID, errResp := h.authorize(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	var input CollabOps
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil || len(input.Ops) == 0 {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+
+	seq, err := h.relay.Append(ctx, noteID, input.Ops)
+	if err != nil {
+		slog.ErrorContext(ctx, "Collab Append failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to relay ops"), nil
+	}
+
+	body, _ := json.Marshal(CollabOps{Ops: []json.RawMessage{}, Seq: seq})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// PullOps handles GET /collab/{id}/ops?since=<seq>. A since the relay no
+// longer holds gets a 410 with the latest seq in details.
+func (h *CollabHandler) PullOps(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	noteID, errResp := h.authorize(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	since := 0
+	if raw := req.QueryStringParameters["since"]; raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return Error(ctx, http.StatusBadRequest, "Invalid since parameter"), nil
+		}
+		since = n
+	}
+
+	ops, seq, err := h.relay.Since(ctx, noteID, since)
+	if errors.Is(err, collab.ErrSeqExpired) {
+		return ErrorResponse{
+			Code:    CodeCursorExpired,
+			Message: "These ops are no longer kept; reload the note and pull from the latest seq",
+			Details: map[string]int{"seq": seq},
+		}.Response(ctx, http.StatusGone), nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Collab Since failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to read ops"), nil
+	}
+
+	body, _ := json.Marshal(CollabOps{Ops: ops, Seq: seq})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/collab"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func TestCollabHandler_PushAndPull(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewCollabHandler(provider, collab.NewMemoryRelay(), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "shared.md", []byte(""), "")
+
+	push := makeRequest("POST", "/collab/"+note.ID+"/ops", `{"ops":[{"t":"ins"},{"t":"del"}]}`)
+	push.PathParameters["id"] = note.ID
+	resp, _ := h.PushOps(ctx, push)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	pull := makeRequest("GET", "/collab/"+note.ID+"/ops", "")
+	pull.PathParameters["id"] = note.ID
+	pull.QueryStringParameters = map[string]string{"since": "1"}
+	resp, _ = h.PullOps(ctx, pull)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var result handler.CollabOps
+	json.Unmarshal([]byte(resp.Body), &result)
+	if result.Seq != 2 {
+		t.Errorf("Expected seq 2, got %d", result.Seq)
+	}
+	if len(result.Ops) != 1 || string(result.Ops[0]) != `{"t":"del"}` {
+		t.Errorf("Expected only the op after seq 1, got %s", result.Ops)
+	}
+}
+
+func TestCollabHandler_ExpiredSeq(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewCollabHandler(provider, collab.NewMemoryRelay(), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "shared.md", []byte(""), "")
+
+	pull := makeRequest("GET", "/collab/"+note.ID+"/ops", "")
+	pull.PathParameters["id"] = note.ID
+	pull.QueryStringParameters = map[string]string{"since": "5"}
+	resp, _ := h.PullOps(ctx, pull)
+	var body handler.ErrorResponse
+	json.Unmarshal([]byte(resp.Body), &body)
+	if resp.StatusCode != http.StatusGone || body.Code != handler.CodeCursorExpired {
+		t.Errorf("Expected 410 %s for a seq past the log, got %d: %s", handler.CodeCursorExpired, resp.StatusCode, resp.Body)
+	}
+}
+
+func TestCollabHandler_UnknownNote(t *testing.T) {
+	h := handler.NewCollabHandler(memory.NewProvider(nil, nil), collab.NewMemoryRelay(), "test-secret")
+
+	req := makeRequest("GET", "/collab/missing/ops", "")
+	req.PathParameters["id"] = "missing"
+	resp, _ := h.PullOps(context.Background(), req)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/comment"
+)
+
+// maxCommentLength is the longest comment body accepted, in characters.
+const maxCommentLength = 4000
+
+// CommentHandler handles review comments on notes.
+type CommentHandler struct {
+	storageProvider adapter.StorageProvider
+	store           *comment.Store
+	jwtSecret       string
+}
+
+// NewCommentHandler creates a new CommentHandler.
+func NewCommentHandler(provider adapter.StorageProvider, store *comment.Store, jwtSecret string) *CommentHandler {
+	return &CommentHandler{storageProvider: provider, store: store, jwtSecret: jwtSecret}
+}
+
+// authorize checks the caller can read the note before touching its
+// comments, and returns the caller's claims and the note ID.
+func (h *CommentHandler) authorize(ctx context.Context, req events.APIGatewayProxyRequest) (*UserClaims, string, *events.APIGatewayProxyResponse) {
+	fail := func(resp events.APIGatewayProxyResponse) (*UserClaims, string, *events.APIGatewayProxyResponse) {
+		return nil, "", &resp
+	}
+	claims, err := requestUserClaims(ctx, req, h.jwtSecret)
+	if err != nil {
+		return fail(Error(ctx, http.StatusUnauthorized, "Unauthorized"))
+	}
+
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		return fail(Error(ctx, http.StatusBadRequest, "Missing note ID"))
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, claims.UserID)
+	if err != nil {
+		return fail(respondError(ctx, "GetAdapter", fmt.Errorf("%w: %v", ErrUnauthorized, err)))
+	}
+	if _, err := storage.GetFile(ctx, noteID); err != nil {
+		return fail(respondError(ctx, "Comments GetFile", err))
+	}
+	return claims, noteID, nil
+}
+
+// ListComments handles GET /notes/{id}/comments. Comments are returned
+// oldest first; replies carry the ID of their thread in parentId.
+func (h *CommentHandler) ListComments(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, noteID, errResp := h.authorize(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	comments, err := h.store.List(ctx, noteID)
+	if err != nil {
+		return respondError(ctx, "ListComments", err), nil
+	}
+
+	body, _ := json.Marshal(comments)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// AddComment handles POST /notes/{id}/comments. A parentId replies to
+// that comment's thread.
+func (h *CommentHandler) AddComment(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, noteID, errResp := h.authorize(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	var input struct {
+		Body     string `json:"body"`
+		ParentID string `json:"parentId"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if strings.TrimSpace(input.Body) == "" {
+		return Error(ctx, http.StatusBadRequest, "Comment body is required"), nil
+	}
+	if utf8.RuneCountInString(input.Body) > maxCommentLength {
+		return Error(ctx, http.StatusBadRequest, fmt.Sprintf("Comments are limited to %d characters", maxCommentLength)), nil
+	}
+
+	c, err := h.store.Add(ctx, comment.Comment{
+		NoteID:     noteID,
+		ParentID:   input.ParentID,
+		AuthorID:   claims.UserID,
+		AuthorName: cmp.Or(claims.Name, claims.Email),
+		Body:       input.Body,
+	})
+	if err != nil {
+		return respondError(ctx, "AddComment", err), nil
+	}
+
+	body, _ := json.Marshal(c)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusCreated,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// DeleteComment handles DELETE /notes/{id}/comments/{commentId}. Only the
+// author may delete a comment.
+func (h *CommentHandler) DeleteComment(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, noteID, errResp := h.authorize(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	commentID := req.PathParameters["commentId"]
+	if commentID == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing comment ID"), nil
+	}
+
+	if err := h.store.Delete(ctx, noteID, commentID, claims.UserID); err != nil {
+		return respondError(ctx, "DeleteComment", err), nil
+	}
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, nil
+}
@@ -0,0 +1,103 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/comment"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func TestCommentHandler_AddListDelete(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	store := comment.NewStore(nil, "")
+	h := handler.NewCommentHandler(provider, store, "test-secret")
+	notes := handler.NewNoteHandler(provider, "test-secret")
+	notes.EnableComments(store)
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "review.md", []byte("# Draft"), "")
+
+	add := makeRequest("POST", "/notes/"+note.ID+"/comments", `{"body":"Needs a summary"}`)
+	add.PathParameters["id"] = note.ID
+	resp, _ := h.AddComment(ctx, add)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var added comment.Comment
+	json.Unmarshal([]byte(resp.Body), &added)
+	if added.ID == "" || added.AuthorID != testUserID || added.Body != "Needs a summary" {
+		t.Errorf("Unexpected comment %+v", added)
+	}
+
+	reply := makeRequest("POST", "/notes/"+note.ID+"/comments", `{"body":"Added","parentId":"`+added.ID+`"}`)
+	reply.PathParameters["id"] = note.ID
+	if resp, _ := h.AddComment(ctx, reply); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201 for reply, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	list := makeRequest("GET", "/notes/"+note.ID+"/comments", "")
+	list.PathParameters["id"] = note.ID
+	resp, _ = h.ListComments(ctx, list)
+	var comments []comment.Comment
+	json.Unmarshal([]byte(resp.Body), &comments)
+	if len(comments) != 2 || comments[1].ParentID != added.ID {
+		t.Errorf("Expected the comment and its reply, got %s", resp.Body)
+	}
+
+	listNotes := makeRequest("GET", "/notes", "")
+	resp, _ = notes.ListNotes(ctx, listNotes)
+	var files []adapter.FileMetadata
+	json.Unmarshal([]byte(resp.Body), &files)
+	i := slices.IndexFunc(files, func(f adapter.FileMetadata) bool { return f.ID == note.ID })
+	if i < 0 || files[i].CommentCount != 2 {
+		t.Errorf("Expected the note with commentCount 2 in the listing, got %s", resp.Body)
+	}
+
+	del := makeRequest("DELETE", "/notes/"+note.ID+"/comments/"+added.ID, "")
+	del.PathParameters["id"] = note.ID
+	del.PathParameters["commentId"] = added.ID
+	if resp, _ := h.DeleteComment(ctx, del); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if resp, _ := h.DeleteComment(ctx, del); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 deleting twice, got %d", resp.StatusCode)
+	}
+}
+
+func TestCommentHandler_Validation(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewCommentHandler(provider, comment.NewStore(nil, ""), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "review.md", []byte("# Draft"), "")
+
+	tests := []struct {
+		name   string
+		noteID string
+		body   string
+		want   int
+	}{
+		{"empty body", note.ID, `{"body":"  "}`, http.StatusBadRequest},
+		{"too long", note.ID, `{"body":"` + strings.Repeat("x", 4001) + `"}`, http.StatusBadRequest},
+		{"unknown thread", note.ID, `{"body":"Hi","parentId":"missing"}`, http.StatusNotFound},
+		{"unknown note", "missing", `{"body":"Hi"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := makeRequest("POST", "/notes/"+tt.noteID+"/comments", tt.body)
+			req.PathParameters["id"] = tt.noteID
+			if resp, _ := h.AddComment(ctx, req); resp.StatusCode != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, resp.StatusCode, resp.Body)
+			}
+		})
+	}
+}
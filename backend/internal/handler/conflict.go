@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// conflictCopyPattern matches "Name (conflicted copy)" and "Name (conflicted copy 2)".
+var conflictCopyPattern = regexp.MustCompile(`^(.*) \(conflicted copy(?: \d+)?\)$`)
+
+// conflictOriginalName returns the original note name for a conflicted copy.
+func conflictOriginalName(name string) (string, bool) {
+	m := conflictCopyPattern.FindStringSubmatch(strings.TrimSuffix(name, ".md"))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// noteKey identifies a note by folder and name (without the .md extension).
+func noteKey(meta adapter.FileMetadata, name string) string {
+	parent := ""
+	if len(meta.Parents) > 0 {
+		parent = meta.Parents[0]
+	}
+	return parent + "/" + strings.TrimSuffix(name, ".md")
+}
+
+// ConflictPair is a conflicted copy and the note it was copied from.
+// Original is nil when the original note no longer exists.
+type ConflictPair struct {
+	Copy     adapter.FileMetadata  `json:"copy"`
+	Original *adapter.FileMetadata `json:"original"`
+}
+
+// ListConflicts handles GET /conflicts
+func (h *SyncHandler) ListConflicts(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	notes, err := listAllNotes(ctx, storage)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListConflicts list failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to list notes"), nil
+	}
+
+	byKey := make(map[string]adapter.FileMetadata, len(notes))
+	for _, n := range notes {
+		byKey[noteKey(n, n.Name)] = n
+	}
+
+	pairs := []ConflictPair{}
+	for _, n := range notes {
+		originalName, ok := conflictOriginalName(n.Name)
+		if !ok {
+			continue
+		}
+		pair := ConflictPair{Copy: n}
+		if original, ok := byKey[noteKey(n, originalName)]; ok {
+			pair.Original = &original
+		}
+		pairs = append(pairs, pair)
+	}
+
+	body, _ := json.Marshal(pairs)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// Conflict resolution strategies.
+const (
+	ResolveKeepMine   = "keep-mine"
+	ResolveKeepTheirs = "keep-theirs"
+	ResolveMerge      = "merge"
+)
+
+// ResolveConflictRequest is the body of POST /conflicts/{id}/resolve.
+// "mine" is the conflicted copy, "theirs" is the original note.
+// Content is required for the merge strategy.
+type ResolveConflictRequest struct {
+	Strategy string `json:"strategy"`
+	Content  string `json:"content"`
+}
+
+// ResolveConflict handles POST /conflicts/{id}/resolve where id is the conflicted copy.
+// The chosen content is written to the original note and the copy is deleted.
+func (h *SyncHandler) ResolveConflict(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
+	}
+
+	var input ResolveConflictRequest
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	switch input.Strategy {
+	case ResolveKeepMine, ResolveKeepTheirs:
+	case ResolveMerge:
+		if input.Content == "" {
+			return Error(ctx, http.StatusBadRequest, "Content is required for merge"), nil
+		}
+	default:
+		return Error(ctx, http.StatusBadRequest, "Unknown strategy"), nil
+	}
+
+	copyFile, err := storage.GetFile(ctx, id)
+	if err != nil {
+		return respondError(ctx, "GetFile", err), nil
+	}
+	originalName, ok := conflictOriginalName(copyFile.Name)
+	if !ok {
+		return Error(ctx, http.StatusBadRequest, "Note is not a conflicted copy"), nil
+	}
+
+	original, err := findSibling(ctx, storage, copyFile.FileMetadata, originalName)
+	if err != nil {
+		slog.ErrorContext(ctx, "ResolveConflict list failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to find original note"), nil
+	}
+	if original == nil {
+		return Error(ctx, http.StatusNotFound, "Original note not found"), nil
+	}
+
+	result := original
+	if input.Strategy != ResolveKeepTheirs {
+		content := copyFile.Content
+		if input.Strategy == ResolveMerge {
+			content = []byte(input.Content)
+		}
+		result, err = storage.SaveFile(ctx, original.ID, content, "")
+		if err != nil {
+			return respondError(ctx, "SaveFile", err), nil
+		}
+	}
+
+	if err := storage.DeleteFile(ctx, copyFile.ID); err != nil {
+		return respondError(ctx, "DeleteFile", err), nil
+	}
+
+	body, _ := json.Marshal(result)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// findSibling returns the note named name in the same folder as meta, or nil.
+func findSibling(ctx context.Context, storage adapter.StorageAdapter, meta adapter.FileMetadata, name string) (*adapter.FileMetadata, error) {
+	folderID := ""
+	if len(meta.Parents) > 0 {
+		folderID = meta.Parents[0]
+	}
+	files, err := storage.ListFiles(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	want := noteKey(meta, name)
+	for _, f := range files {
+		if f.MIMEType != folderMIMEType && f.ID != meta.ID && noteKey(f, f.Name) == want {
+			return &f, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,100 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/journal"
+)
+
+func TestListConflicts(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewSyncHandler(provider, journal.NewStore(nil, ""), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	original, _ := storage.CreateFile(ctx, "plan.md", []byte("theirs"), "")
+	copy1, _ := storage.CreateFile(ctx, "plan (conflicted copy).md", []byte("mine"), "")
+	storage.CreateFile(ctx, "orphan (conflicted copy 2).md", []byte("x"), "")
+	storage.CreateFile(ctx, "other.md", []byte("y"), "")
+
+	resp, _ := h.ListConflicts(ctx, makeRequest("GET", "/conflicts", ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var pairs []handler.ConflictPair
+	json.Unmarshal([]byte(resp.Body), &pairs)
+	if len(pairs) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %d: %s", len(pairs), resp.Body)
+	}
+	for _, p := range pairs {
+		if p.Copy.ID == copy1.ID {
+			if p.Original == nil || p.Original.ID != original.ID {
+				t.Errorf("Expected copy to pair with %s, got %+v", original.ID, p.Original)
+			}
+		} else if p.Original != nil {
+			t.Errorf("Expected orphan copy to have no original, got %+v", p.Original)
+		}
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	tests := []struct {
+		strategy string
+		content  string
+		want     string
+	}{
+		{handler.ResolveKeepMine, "", "mine"},
+		{handler.ResolveKeepTheirs, "", "theirs"},
+		{handler.ResolveMerge, "merged", "merged"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			provider := memory.NewProvider(nil, nil)
+			h := handler.NewSyncHandler(provider, journal.NewStore(nil, ""), "test-secret")
+			ctx := context.Background()
+
+			storage, _ := provider.GetAdapter(ctx, testUserID)
+			original, _ := storage.CreateFile(ctx, "plan.md", []byte("theirs"), "")
+			conflicted, _ := storage.CreateFile(ctx, "plan (conflicted copy).md", []byte("mine"), "")
+
+			body, _ := json.Marshal(handler.ResolveConflictRequest{Strategy: tt.strategy, Content: tt.content})
+			req := makeRequest("POST", "/conflicts/"+conflicted.ID+"/resolve", string(body))
+			req.PathParameters["id"] = conflicted.ID
+			resp, _ := h.ResolveConflict(ctx, req)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+			}
+
+			file, _ := storage.GetFile(ctx, original.ID)
+			if string(file.Content) != tt.want {
+				t.Errorf("Expected original content %q, got %q", tt.want, file.Content)
+			}
+			if _, err := storage.GetFile(ctx, conflicted.ID); err == nil {
+				t.Error("Expected conflicted copy to be deleted")
+			}
+		})
+	}
+}
+
+func TestResolveConflict_NotAConflict(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewSyncHandler(provider, journal.NewStore(nil, ""), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "plan.md", []byte("x"), "")
+
+	req := makeRequest("POST", "/conflicts/"+note.ID+"/resolve", `{"strategy":"keep-mine"}`)
+	req.PathParameters["id"] = note.ID
+	resp, _ := h.ResolveConflict(ctx, req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", resp.StatusCode)
+	}
+}
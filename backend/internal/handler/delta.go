@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/share"
+)
+
+// DeltaEdit replaces Delete characters at Offset with Insert.
+// Offsets and lengths count Unicode code points in the current content.
+type DeltaEdit struct {
+	Offset int    `json:"offset"`
+	Delete int    `json:"delete"`
+	Insert string `json:"insert"`
+}
+
+// errInvalidDelta is returned when edits fall outside the content or overlap.
+var errInvalidDelta = errors.New("invalid delta")
+
+// applyDelta applies edits whose offsets all refer to the original content.
+func applyDelta(content string, edits []DeltaEdit) (string, error) {
+	runes := []rune(content)
+
+	sorted := make([]DeltaEdit, len(edits))
+	copy(sorted, edits)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Offset < sorted[j].Offset })
+
+	end := 0
+	for _, e := range sorted {
+		if e.Offset < end || e.Delete < 0 || e.Offset+e.Delete > len(runes) {
+			return "", errInvalidDelta
+		}
+		end = e.Offset + e.Delete
+	}
+
+	// Apply back to front so earlier offsets stay valid.
+	for i := len(sorted) - 1; i >= 0; i-- {
+		e := sorted[i]
+		tail := append([]rune(e.Insert), runes[e.Offset+e.Delete:]...)
+		runes = append(runes[:e.Offset], tail...)
+	}
+	return string(runes), nil
+}
+
+// PatchNoteDelta handles PATCH /notes/{id}/delta.
+// The client sends a list of edits against the version named by If-Match, so
+// large notes can be saved without uploading the whole content.
+func (h *NoteHandler) PatchNoteDelta(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
+	}
+
+	etag := req.Headers["If-Match"]
+	if etag == "" {
+		return Error(ctx, http.StatusPreconditionRequired, "If-Match header is required for delta updates"), nil
+	}
+
+	var input struct {
+		Edits []DeltaEdit `json:"edits"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+
+	if errResp := h.checkEditLock(ctx, req, id); errResp != nil {
+		return *errResp, nil
+	}
+
+	current, err := storage.GetFile(ctx, id)
+	var sh *share.Share
+	if errors.Is(err, adapter.ErrNotFound) {
+		if storage, sh, err = h.sharedStorage(ctx, req, id, share.PermissionWrite); err == nil {
+			current, err = storage.GetFile(ctx, id)
+		}
+	}
+	if err != nil {
+		return respondError(ctx, "GetFile", err), nil
+	}
+	if current.ETag != etag {
+		return respondError(ctx, "PatchNoteDelta", adapter.ErrPreconditionFailed), nil
+	}
+	// Offsets into ciphertext mean nothing; encrypted notes are saved whole.
+	if current.Encrypted {
+		return respondError(ctx, "PatchNoteDelta", adapter.ErrEncrypted), nil
+	}
+
+	content, err := applyDelta(string(current.Content), input.Edits)
+	if err != nil {
+		return Error(ctx, http.StatusUnprocessableEntity, "Edits are out of range or overlap"), nil
+	}
+
+	file, err := storage.SaveFile(ctx, id, []byte(content), etag)
+	if err != nil {
+		return respondError(ctx, "SaveFile", err), nil
+	}
+	if sh != nil {
+		h.indexRemindersFor(ctx, sh.OwnerID, sh.OwnerEmail, file, content)
+	} else {
+		h.indexReminders(ctx, req, file, content)
+	}
+
+	body, _ := json.Marshal(file)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,102 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func TestNoteHandler_PatchNoteDelta(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "delta.md", []byte("héllo world"), "")
+	originalETag := note.ETag
+
+	req := makeRequest("PATCH", "/notes/"+note.ID+"/delta", `{"edits":[{"offset":6,"delete":5,"insert":"there"},{"offset":0,"delete":1,"insert":"H"}]}`)
+	req.PathParameters["id"] = note.ID
+	req.Headers["If-Match"] = originalETag
+	resp, _ := h.PatchNoteDelta(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	file, _ := storage.GetFile(ctx, note.ID)
+	if string(file.Content) != "Héllo there" {
+		t.Errorf("Expected 'Héllo there', got %q", file.Content)
+	}
+
+	var meta struct {
+		ETag string `json:"etag"`
+	}
+	json.Unmarshal([]byte(resp.Body), &meta)
+	if meta.ETag == originalETag {
+		t.Error("Expected ETag to change after delta update")
+	}
+
+	// Stale ETag is rejected
+	resp, _ = h.PatchNoteDelta(ctx, req)
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for stale ETag, got %d", resp.StatusCode)
+	}
+}
+
+func TestNoteHandler_PatchNoteDelta_Invalid(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "delta.md", []byte("abc"), "")
+
+	// Missing If-Match
+	req := makeRequest("PATCH", "/notes/"+note.ID+"/delta", `{"edits":[]}`)
+	req.PathParameters["id"] = note.ID
+	resp, _ := h.PatchNoteDelta(ctx, req)
+	if resp.StatusCode != http.StatusPreconditionRequired {
+		t.Errorf("Expected 428 without If-Match, got %d", resp.StatusCode)
+	}
+
+	// Out of range and overlapping edits
+	for _, body := range []string{
+		`{"edits":[{"offset":2,"delete":5}]}`,
+		`{"edits":[{"offset":0,"delete":2},{"offset":1,"delete":1}]}`,
+	} {
+		req = makeRequest("PATCH", "/notes/"+note.ID+"/delta", body)
+		req.PathParameters["id"] = note.ID
+		req.Headers["If-Match"] = note.ETag
+		resp, _ = h.PatchNoteDelta(ctx, req)
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected 422, got %d", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestNoteHandler_PatchNoteDelta_Encrypted(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "sealed.md", []byte(adapter.EncryptedPrefix+"v1:c2FsdA==:abc"), "")
+
+	req := makeRequest("PATCH", "/notes/"+note.ID+"/delta", `{"edits":[{"offset":0,"delete":1}]}`)
+	req.PathParameters["id"] = note.ID
+	req.Headers["If-Match"] = note.ETag
+	resp, _ := h.PatchNoteDelta(ctx, req)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var body handler.ErrorResponse
+	json.Unmarshal([]byte(resp.Body), &body)
+	if body.Code != handler.CodeNoteEncrypted {
+		t.Errorf("Expected code %q, got %q", handler.CodeNoteEncrypted, body.Code)
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/draft"
+)
+
+// DraftHandler handles server-side autosave drafts of notes.
+type DraftHandler struct {
+	storageProvider adapter.StorageProvider
+	store           *draft.Store
+	jwtSecret       string
+}
+
+// NewDraftHandler creates a new DraftHandler.
+func NewDraftHandler(provider adapter.StorageProvider, store *draft.Store, jwtSecret string) *DraftHandler {
+	return &DraftHandler{storageProvider: provider, store: store, jwtSecret: jwtSecret}
+}
+
+// DraftResponse is a draft as returned by GetDraft. Stale is set when the
+// note has been saved since the draft was started, so committing the draft
+// would overwrite those changes.
+type DraftResponse struct {
+	draft.Draft
+	Stale bool `json:"stale"`
+}
+
+// GetDraft handles GET /notes/{id}/draft, returning the caller's draft of
+// the note or 404 if there is none.
+func (h *DraftHandler) GetDraft(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
+	}
+
+	d, err := h.store.Get(ctx, userID, noteID)
+	if err != nil {
+		return respondError(ctx, "GetDraft", err), nil
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", fmt.Errorf("%w: %v", ErrUnauthorized, err)), nil
+	}
+	note, err := storage.GetFile(ctx, noteID)
+	if err != nil {
+		return respondError(ctx, "Draft GetFile", err), nil
+	}
+
+	body, _ := json.Marshal(DraftResponse{
+		Draft: *d,
+		Stale: d.BaseETag != "" && d.BaseETag != note.ETag,
+	})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// SaveDraft handles PUT /notes/{id}/draft, replacing the caller's draft of
+// the note. It does not read the note, so frequent autosaves stay cheap.
+func (h *DraftHandler) SaveDraft(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
+	}
+
+	var input struct {
+		Content   string `json:"content"`
+		BaseETag  string `json:"baseEtag"`
+		Encrypted bool   `json:"encrypted"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if input.Encrypted && !adapter.IsEncrypted([]byte(input.Content)) {
+		return Error(ctx, http.StatusBadRequest, "Drafts of encrypted notes must be sealed on the client before upload"), nil
+	}
+
+	d, err := h.store.Put(ctx, draft.Draft{
+		UserID:   userID,
+		NoteID:   noteID,
+		Content:  input.Content,
+		BaseETag: input.BaseETag,
+	})
+	if err != nil {
+		return respondError(ctx, "SaveDraft", err), nil
+	}
+
+	body, _ := json.Marshal(d)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// DeleteDraft handles DELETE /notes/{id}/draft, discarding the caller's
+// draft of the note.
+func (h *DraftHandler) DeleteDraft(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
+	}
+
+	if err := h.store.Delete(ctx, userID, noteID); err != nil {
+		return respondError(ctx, "DeleteDraft", err), nil
+	}
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, nil
+}
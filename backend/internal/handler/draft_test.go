@@ -0,0 +1,80 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/draft"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func TestDraftHandler_SaveGetDelete(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	store := draft.NewStore(nil, "")
+	h := handler.NewDraftHandler(provider, store, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "wip", []byte("# Start"), "")
+
+	call := func(fn func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error), method, body string) handler.DraftResponse {
+		t.Helper()
+		r := makeRequest(method, "/notes/"+note.ID+"/draft", body)
+		r.PathParameters["id"] = note.ID
+		resp, _ := fn(ctx, r)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s draft: expected 200, got %d: %s", method, resp.StatusCode, resp.Body)
+		}
+		var d handler.DraftResponse
+		json.Unmarshal([]byte(resp.Body), &d)
+		return d
+	}
+
+	call(h.SaveDraft, "PUT", `{"content":"# Start\n\nUnsaved","baseEtag":"`+note.ETag+`"}`)
+	got := call(h.GetDraft, "GET", "")
+	if got.Content != "# Start\n\nUnsaved" || got.Stale {
+		t.Errorf("Unexpected draft %+v", got)
+	}
+
+	// The note is saved elsewhere, so committing the draft would overwrite it.
+	storage.SaveFile(ctx, note.ID, []byte("# Changed"), "")
+	if got := call(h.GetDraft, "GET", ""); !got.Stale {
+		t.Errorf("Expected a stale draft after the note changed, got %+v", got)
+	}
+
+	del := makeRequest("DELETE", "/notes/"+note.ID+"/draft", "")
+	del.PathParameters["id"] = note.ID
+	if resp, _ := h.DeleteDraft(ctx, del); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", resp.StatusCode)
+	}
+	get := makeRequest("GET", "/notes/"+note.ID+"/draft", "")
+	get.PathParameters["id"] = note.ID
+	if resp, _ := h.GetDraft(ctx, get); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 after discarding, got %d", resp.StatusCode)
+	}
+}
+
+func TestNoteHandler_UpdateNoteDiscardsDraft(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	store := draft.NewStore(nil, "")
+	notes := handler.NewNoteHandler(provider, "test-secret")
+	notes.EnableDrafts(store)
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "wip", []byte("# Start"), "")
+	store.Put(ctx, draft.Draft{UserID: testUserID, NoteID: note.ID, Content: "# Committed"})
+
+	update := makeRequest("PUT", "/notes/"+note.ID, `{"content":"# Committed"}`)
+	update.PathParameters["id"] = note.ID
+	if resp, _ := notes.UpdateNote(ctx, update); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if _, err := store.Get(ctx, testUserID, note.ID); err != draft.ErrNotFound {
+		t.Errorf("Expected the draft discarded after saving, got %v", err)
+	}
+}
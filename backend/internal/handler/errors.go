@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/jun/gophdrive/backend/internal/accesstoken"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/comment"
+	"github.com/jun/gophdrive/backend/internal/draft"
+	"github.com/jun/gophdrive/backend/internal/gitexport"
+	"github.com/jun/gophdrive/backend/internal/publish"
+	"github.com/jun/gophdrive/backend/internal/session"
+	"github.com/jun/gophdrive/backend/internal/share"
+	"github.com/jun/gophdrive/backend/internal/summary"
+	"github.com/jun/gophdrive/backend/internal/translate"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the request's ID, which error
+// responses echo so users can quote it in bug reports.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the ID stored by WithRequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ErrUnauthorized is returned when the request carries no valid session or
+// the user's storage cannot be opened with it.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrorResponse is the JSON body of every error response. Code is a stable
+// machine-readable identifier; Message is meant for people and may change.
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// Error codes beyond the per-status defaults.
+const (
+	CodeETagMismatch  = "etag_mismatch"
+	CodeLimitExceeded = "limit_exceeded"
+	CodeLockHeld      = "lock_held"
+	CodeNotLockOwner  = "not_lock_owner"
+	CodeLockNotFound  = "lock_not_found"
+	CodeLockExpired   = "lock_expired"
+	CodeNoteEncrypted = "note_encrypted"
+	CodeCursorExpired = "cursor_expired"
+)
+
+// statusCodes are the default codes for each status.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusGone:                  "gone",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnprocessableEntity:   "unprocessable_entity",
+	http.StatusLocked:                "locked",
+	http.StatusPreconditionRequired:  "precondition_required",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusGatewayTimeout:        "timeout",
+}
+
+// Response returns e as a JSON response with status, filling in the code
+// for status and the request ID when they are not set.
+func (e ErrorResponse) Response(ctx context.Context, status int) events.APIGatewayProxyResponse {
+	if e.Code == "" {
+		e.Code = statusCodes[status]
+	}
+	if e.RequestID == "" {
+		e.RequestID = RequestIDFromContext(ctx)
+	}
+	body, _ := json.Marshal(e)
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(body),
+	}
+}
+
+// Error returns an error response with status, its default code and
+// message.
+func Error(ctx context.Context, status int, message string) events.APIGatewayProxyResponse {
+	return ErrorResponse{Message: message}.Response(ctx, status)
+}
+
+// Timeout returns a 504 response for a request that ran out of time.
+func Timeout(ctx context.Context) events.APIGatewayProxyResponse {
+	return Error(ctx, http.StatusGatewayTimeout, "The request timed out; please try again")
+}
+
+// InternalError returns a 500 response that reveals nothing about the
+// failure beyond the request ID.
+func InternalError(ctx context.Context) events.APIGatewayProxyResponse {
+	return Error(ctx, http.StatusInternalServerError, "Internal Server Error")
+}
+
+// errorMappings translates sentinel errors from the storage adapters, the
+// lock manager, the comment, draft, publish, access token and share stores,
+// the summarizer, the translator, the Git export and the handlers into
+// responses. The first match wins.
+var errorMappings = []struct {
+	err     error
+	status  int
+	code    string
+	message string // empty means the error's own text, which must be safe to show
+}{
+	{ErrUnauthorized, http.StatusUnauthorized, "", "Unauthorized"},
+	{adapter.ErrNotFound, http.StatusNotFound, "", "Note not found"},
+	{adapter.ErrPreconditionFailed, http.StatusPreconditionFailed, CodeETagMismatch, "The note was changed since it was loaded (ETag mismatch)"},
+	{adapter.ErrLimitExceeded, http.StatusUnprocessableEntity, CodeLimitExceeded, ""},
+	{adapter.ErrEncrypted, http.StatusUnprocessableEntity, CodeNoteEncrypted, "This note is encrypted end to end; the server cannot read or edit its content"},
+	{adapter.ErrNotFolder, http.StatusBadRequest, "", "Only folders have a color and icon"},
+	{session.ErrLocked, http.StatusConflict, CodeLockHeld, "File is locked by another user"},
+	{session.ErrNotOwner, http.StatusForbidden, CodeNotLockOwner, "Lock is held by another user"},
+	{session.ErrLockNotFound, http.StatusNotFound, CodeLockNotFound, "Lock not found or expired"},
+	{session.ErrMaxDurationExceeded, http.StatusConflict, CodeLockExpired, "Lock has reached its maximum duration; re-acquire to continue editing"},
+	{comment.ErrNotFound, http.StatusNotFound, "", "Comment not found"},
+	{comment.ErrNotAuthor, http.StatusForbidden, "", "Only the author can delete a comment"},
+	{draft.ErrNotFound, http.StatusNotFound, "", "Draft not found"},
+	{draft.ErrTooLarge, http.StatusRequestEntityTooLarge, "", "Drafts are limited to 350 KB; save the note instead"},
+	{publish.ErrNotFound, http.StatusNotFound, "", "This note is not published"},
+	{publish.ErrTooLarge, http.StatusRequestEntityTooLarge, "", "Notes over 350 KB cannot be published"},
+	{summary.ErrEmpty, http.StatusUnprocessableEntity, "", "The note is empty; there is nothing to summarize"},
+	{translate.ErrTooLarge, http.StatusRequestEntityTooLarge, "", "Notes over 100 KB cannot be translated"},
+	{accesstoken.ErrNotFound, http.StatusNotFound, "", "Access token not found"},
+	{ErrAmbiguousPath, http.StatusConflict, "", "More than one note or folder has this path; rename one of them"},
+	{accesstoken.ErrTooMany, http.StatusUnprocessableEntity, CodeLimitExceeded, "You can have at most 20 access tokens; delete one first"},
+	{gitexport.ErrNotConfigured, http.StatusNotFound, "", "No Git remote is configured; set one up first"},
+	{gitexport.ErrRemote, http.StatusBadGateway, "", ""},
+	{share.ErrNotFound, http.StatusNotFound, "", "This note is not shared with that user"},
+	{share.ErrReadOnly, http.StatusForbidden, "", "This note is shared with you read-only"},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, "", "The request timed out; please try again"},
+}
+
+// respondError maps err to an error response. Known errors get their own
+// status and code; anything else is logged as "<op> failed" and reported as
+// a 500 without its details, which may include internal identifiers.
+func respondError(ctx context.Context, op string, err error) events.APIGatewayProxyResponse {
+	if e, status, ok := mapError(err); ok {
+		return e.Response(ctx, status)
+	}
+	slog.ErrorContext(ctx, op+" failed", "error", err)
+	return InternalError(ctx)
+}
+
+// mapError returns the response body and status for err if it is in
+// errorMappings.
+func mapError(err error) (ErrorResponse, int, bool) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			message := m.message
+			if message == "" {
+				message = err.Error()
+			}
+			return ErrorResponse{Code: m.code, Message: message}, m.status, true
+		}
+	}
+	return ErrorResponse{}, 0, false
+}
@@ -0,0 +1,101 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/session"
+)
+
+func decodeError(t *testing.T, body string) handler.ErrorResponse {
+	t.Helper()
+	var e handler.ErrorResponse
+	if err := json.Unmarshal([]byte(body), &e); err != nil {
+		t.Fatalf("error body %q is not JSON: %v", body, err)
+	}
+	return e
+}
+
+func TestError_DefaultCodeAndRequestID(t *testing.T) {
+	ctx := handler.WithRequestID(context.Background(), "req-1")
+	resp := handler.Error(ctx, http.StatusBadRequest, "Missing note ID")
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", resp.StatusCode)
+	}
+	if ct := resp.Headers["Content-Type"]; ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	e := decodeError(t, resp.Body)
+	if e.Code != "bad_request" || e.Message != "Missing note ID" || e.RequestID != "req-1" {
+		t.Errorf("body = %+v", e)
+	}
+}
+
+func TestErrorResponses_MapDomainErrors(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "errors.md", []byte("hello"), "")
+
+	t.Run("etag mismatch", func(t *testing.T) {
+		req := makeRequest("PUT", "/notes/"+note.ID, `{"content":"changed"}`)
+		req.PathParameters["id"] = note.ID
+		req.Headers["If-Match"] = "stale"
+		resp, _ := h.UpdateNote(ctx, req)
+		if resp.StatusCode != http.StatusPreconditionFailed {
+			t.Fatalf("Expected 412, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		if e := decodeError(t, resp.Body); e.Code != handler.CodeETagMismatch {
+			t.Errorf("code = %q, want %q", e.Code, handler.CodeETagMismatch)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		req := makeRequest("GET", "/notes/missing", "")
+		req.PathParameters["id"] = "missing"
+		resp, _ := h.GetNote(ctx, req)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected 404, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		if e := decodeError(t, resp.Body); e.Code != "not_found" {
+			t.Errorf("code = %q, want not_found", e.Code)
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req := makeRequest("GET", "/notes/"+note.ID, "")
+		req.PathParameters["id"] = note.ID
+		delete(req.Headers, "Authorization")
+		resp, _ := h.GetNote(ctx, req)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("Expected 401, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		if e := decodeError(t, resp.Body); e.Code != "unauthorized" || e.Message != "Unauthorized" {
+			t.Errorf("body = %+v, want code unauthorized without details", e)
+		}
+	})
+}
+
+func TestErrorResponses_LockHeld(t *testing.T) {
+	locker := session.NewMockLocker()
+	h := handler.NewSessionHandler(locker, "test-secret")
+	ctx := context.Background()
+	locker.AcquireLock(ctx, "file1", "other-user", session.HolderInfo{})
+
+	req := makeRequest("POST", "/sessions/file1/lock", "")
+	req.PathParameters = map[string]string{"fileId": "file1"}
+	resp, _ := h.AcquireLock(ctx, req)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if e := decodeError(t, resp.Body); e.Code != handler.CodeLockHeld {
+		t.Errorf("code = %q, want %q", e.Code, handler.CodeLockHeld)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/core/markdown"
+)
+
+// PatchFrontmatter handles PATCH /notes/{id}/frontmatter, editing fields of
+// the note's YAML frontmatter, such as its tags, without sending the whole
+// note. The body is {"set": {"tags": ["go", "wasm"]}, "remove": ["draft"]};
+// values may be strings, numbers, booleans, null or lists of strings. Other
+// fields, comments and the body are left as written. An If-Match header is
+// checked against the note's ETag.
+func (h *NoteHandler) PatchFrontmatter(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
+	}
+
+	var input struct {
+		Set    map[string]any `json:"set"`
+		Remove []string       `json:"remove"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if len(input.Set) == 0 && len(input.Remove) == 0 {
+		return Error(ctx, http.StatusBadRequest, "No fields to update"), nil
+	}
+
+	if errResp := h.checkEditLock(ctx, req, id); errResp != nil {
+		return *errResp, nil
+	}
+
+	note, err := storage.GetFile(ctx, id)
+	if err == nil && note.Encrypted {
+		err = adapter.ErrEncrypted
+	}
+	if err != nil {
+		return respondError(ctx, "GetFile", err), nil
+	}
+
+	content := note.Content
+	// Sorted so the fields a request adds are appended in a stable order.
+	for _, key := range slices.Sorted(maps.Keys(input.Set)) {
+		if err == nil {
+			content, err = markdown.SetFrontmatterField(content, key, input.Set[key])
+		}
+	}
+	for _, key := range input.Remove {
+		if err == nil {
+			content, err = markdown.RemoveFrontmatterField(content, key)
+		}
+	}
+	if errors.Is(err, markdown.ErrInvalidFrontmatterKey) || errors.Is(err, markdown.ErrUnsupportedFrontmatterValue) {
+		return Error(ctx, http.StatusBadRequest, err.Error()), nil
+	}
+	if err != nil {
+		return respondError(ctx, "PatchFrontmatter", err), nil
+	}
+
+	// Without If-Match, the note read above is the version edited.
+	saved, err := storage.SaveFile(ctx, id, content, cmp.Or(req.Headers["If-Match"], note.ETag))
+	if err != nil {
+		return respondError(ctx, "SaveFile", err), nil
+	}
+	h.indexReminders(ctx, req, saved, string(content))
+
+	body, _ := json.Marshal(saved)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
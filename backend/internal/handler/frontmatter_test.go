@@ -0,0 +1,47 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func TestNoteHandler_PatchFrontmatter(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "post.md", []byte("---\ntitle: Post # working title\ndraft: true\n---\n# Post\n"), "")
+	staleETag := note.ETag
+
+	patch := func(body string, headers map[string]string) (int, string) {
+		req := makeRequest("PATCH", "/notes/"+note.ID+"/frontmatter", body)
+		req.PathParameters["id"] = note.ID
+		for k, v := range headers {
+			req.Headers[k] = v
+		}
+		resp, _ := h.PatchFrontmatter(ctx, req)
+		return resp.StatusCode, resp.Body
+	}
+
+	if status, body := patch(`{"set":{"tags":["go","wasm notes"],"order":2},"remove":["draft"]}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", status, body)
+	}
+	want := "---\ntitle: Post # working title\norder: 2\ntags: [go, wasm notes]\n---\n# Post\n"
+	if f, _ := storage.GetFile(ctx, note.ID); string(f.Content) != want {
+		t.Errorf("Expected %q, got %q", want, f.Content)
+	}
+
+	for _, body := range []string{`{}`, `{"set":{"bad key:":1}}`, `{"set":{"meta":{"a":1}}}`, `{"remove":["#x"]}`} {
+		if status, _ := patch(body, nil); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, status)
+		}
+	}
+	if status, _ := patch(`{"set":{"title":"New"}}`, map[string]string{"If-Match": staleETag}); status != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale If-Match, got %d", status)
+	}
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/gitexport"
+)
+
+// maxGitCommits bounds the commits one export makes, so it finishes well
+// within the API Gateway timeout. Later exports continue where it stopped.
+const maxGitCommits = 20
+
+// GitExportHandler exports the user's notes to a Git repository on GitHub
+// or GitLab, by vault path, one commit per changed note.
+type GitExportHandler struct {
+	storageProvider adapter.StorageProvider
+	remotes         *gitexport.Store
+	client          *gitexport.Client
+	jwtSecret       string
+}
+
+// NewGitExportHandler creates a new GitExportHandler.
+func NewGitExportHandler(provider adapter.StorageProvider, remotes *gitexport.Store, client *gitexport.Client, jwtSecret string) *GitExportHandler {
+	return &GitExportHandler{storageProvider: provider, remotes: remotes, client: client, jwtSecret: jwtSecret}
+}
+
+// GetGitRemote handles GET /export/git/remote, returning the caller's remote
+// without its token.
+func (h *GitExportHandler) GetGitRemote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	r, err := h.remotes.Get(ctx, userID)
+	if err != nil {
+		return respondError(ctx, "GetGitRemote", err), nil
+	}
+	body, _ := json.Marshal(r)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// PutGitRemote handles PUT /export/git/remote with {provider, repository,
+// branch, token}, replacing the caller's remote. The token may be left out
+// to keep the current one. The repository is read with the token first, so
+// a wrong token or repository is reported now rather than on export.
+func (h *GitExportHandler) PutGitRemote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	var input struct {
+		Provider   string `json:"provider"`
+		Repository string `json:"repository"`
+		Branch     string `json:"branch"`
+		Token      string `json:"token"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	r := gitexport.Remote{
+		UserID:     userID,
+		Provider:   input.Provider,
+		Repository: input.Repository,
+		Branch:     input.Branch,
+		Token:      input.Token,
+		UpdatedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	if err := r.Validate(); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid remote: "+err.Error()), nil
+	}
+	if r.Token == "" {
+		existing, err := h.remotes.Get(ctx, userID)
+		if errors.Is(err, gitexport.ErrNotConfigured) {
+			return Error(ctx, http.StatusBadRequest, "A token is required"), nil
+		}
+		if err != nil {
+			return respondError(ctx, "GetGitRemote", err), nil
+		}
+		if err := h.remotes.DecryptToken(ctx, existing); err != nil {
+			return respondError(ctx, "DecryptToken", err), nil
+		}
+		r.Token = existing.Token
+	}
+
+	repo, err := h.client.Open(r)
+	if err != nil {
+		return respondError(ctx, "OpenRepository", err), nil
+	}
+	if _, err := repo.Files(ctx); err != nil {
+		return respondError(ctx, "ListRepository", err), nil
+	}
+	if err := h.remotes.Put(ctx, r); err != nil {
+		return respondError(ctx, "PutGitRemote", err), nil
+	}
+
+	body, _ := json.Marshal(r)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// DeleteGitRemote handles DELETE /export/git/remote, forgetting the remote
+// and its token. The repository is left as it is.
+func (h *GitExportHandler) DeleteGitRemote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+	if err := h.remotes.Delete(ctx, userID); err != nil {
+		return respondError(ctx, "DeleteGitRemote", err), nil
+	}
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, nil
+}
+
+// GitExport is the response of ExportGit. Skipped lists the vault paths
+// held by more than one note or folder, whose files were left as they are.
+type GitExport struct {
+	gitexport.Result
+	Skipped []string `json:"skipped"`
+}
+
+// ExportGit handles POST /export/git, committing the notes that changed
+// since the last export to the caller's remote, each as a commit of its own
+// such as "Update Work/Plan.md". Notes are stored at their vault paths;
+// Markdown files without a note are deleted, and other files are left
+// alone. End-to-end encrypted notes are exported encrypted. At most
+// maxGitCommits commits are made; "remaining" counts the rest, which the
+// next export makes.
+func (h *GitExportHandler) ExportGit(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	r, err := h.remotes.Get(ctx, userID)
+	if err != nil {
+		return respondError(ctx, "GetGitRemote", err), nil
+	}
+	if err := h.remotes.DecryptToken(ctx, r); err != nil {
+		return respondError(ctx, "DecryptToken", err), nil
+	}
+	repo, err := h.client.Open(*r)
+	if err != nil {
+		return respondError(ctx, "OpenRepository", err), nil
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+	v, err := loadVault(ctx, storage)
+	if err != nil {
+		return respondError(ctx, "LoadVault", err), nil
+	}
+
+	var (
+		mu    sync.Mutex
+		notes = make(map[string][]byte)
+		paths = make(map[string]string) // by note ID
+		list  []adapter.FileMetadata
+	)
+	for p, item := range v.items {
+		if item.MIMEType != folderMIMEType {
+			paths[item.ID] = p
+			list = append(list, item)
+		}
+	}
+	err = fetchNotes(ctx, storage, list, func(n adapter.FileMetadata, f *adapter.File) {
+		mu.Lock()
+		notes[paths[n.ID]] = f.Content
+		mu.Unlock()
+	})
+	if err != nil {
+		return respondError(ctx, "FetchNotes", err), nil
+	}
+	out := GitExport{Skipped: []string{}}
+	for p := range v.ambiguous {
+		notes[p] = nil
+		out.Skipped = append(out.Skipped, p)
+	}
+	slices.Sort(out.Skipped)
+
+	res, err := gitexport.Export(ctx, repo, notes, maxGitCommits)
+	if res != nil && len(res.Commits) > 0 {
+		last := res.Commits[len(res.Commits)-1]
+		if rerr := h.remotes.RecordExport(ctx, userID, last.SHA, time.Now().UTC()); rerr != nil {
+			slog.WarnContext(ctx, "Recording git export failed", "error", rerr)
+		}
+	}
+	if err != nil {
+		return respondError(ctx, "ExportGit", err), nil
+	}
+
+	out.Result = *res
+	body, _ := json.Marshal(out)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
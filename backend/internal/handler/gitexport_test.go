@@ -0,0 +1,123 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/gitexport"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+// fakeGitHub is a GitHub repository "me/notes" that accepts the token
+// "ghp_secret".
+type fakeGitHub struct {
+	mu       sync.Mutex
+	files    map[string]string
+	messages []string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer ghp_secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"Bad credentials"}`))
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, "/repos/me/notes/")
+	if strings.HasPrefix(p, "git/trees/") {
+		tree := []map[string]string{}
+		for path, content := range f.files {
+			tree = append(tree, map[string]string{"path": path, "type": "blob", "sha": gitexport.BlobSHA([]byte(content))})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"tree": tree})
+		return
+	}
+	var input struct{ Message, Content string }
+	json.NewDecoder(r.Body).Decode(&input)
+	p = strings.TrimPrefix(p, "contents/")
+	if r.Method == http.MethodDelete {
+		delete(f.files, p)
+	} else {
+		content, _ := base64.StdEncoding.DecodeString(input.Content)
+		f.files[p] = string(content)
+	}
+	f.messages = append(f.messages, input.Message)
+	w.Write([]byte(`{"commit":{"sha":"abc123"}}`))
+}
+
+func TestGitExport(t *testing.T) {
+	ctx := context.Background()
+	github := &fakeGitHub{files: map[string]string{"Stale.md": "gone", "LICENSE": "MIT"}}
+	srv := httptest.NewServer(github)
+	defer srv.Close()
+	client := gitexport.NewClient(srv.Client())
+	client.GitHubURL = srv.URL
+
+	provider := memory.NewProvider(nil, nil)
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	folder, _ := storage.CreateFolder(ctx, "Work", nil)
+	storage.CreateFile(ctx, "Plan.md", []byte("# Plan\n"), folder.ID)
+	storage.CreateFile(ctx, "Twin.md", []byte("one"), "")
+	storage.CreateFile(ctx, "Twin.md", []byte("two"), "")
+	h := handler.NewGitExportHandler(provider, gitexport.NewStore(nil, "", crypto.NewMockEncryptor()), client, "test-secret")
+
+	if resp, _ := h.ExportGit(ctx, makeRequest("POST", "/export/git", "")); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("export without a remote = %d", resp.StatusCode)
+	}
+
+	// The token is checked against the repository before it is saved.
+	resp, _ := h.PutGitRemote(ctx, makeRequest("PUT", "/export/git/remote", `{"provider":"github","repository":"me/notes","token":"wrong"}`))
+	if resp.StatusCode != http.StatusBadGateway || !strings.Contains(resp.Body, "Bad credentials") {
+		t.Errorf("PUT with a bad token = %d %s", resp.StatusCode, resp.Body)
+	}
+	if resp, _ := h.PutGitRemote(ctx, makeRequest("PUT", "/export/git/remote", `{"provider":"github","repository":"me/notes"}`)); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT without a token = %d", resp.StatusCode)
+	}
+	resp, _ = h.PutGitRemote(ctx, makeRequest("PUT", "/export/git/remote", `{"provider":"github","repository":"me/notes","token":"ghp_secret"}`))
+	if resp.StatusCode != http.StatusOK || strings.Contains(resp.Body, "ghp_secret") || !strings.Contains(resp.Body, `"branch":"main"`) {
+		t.Fatalf("PUT = %d %s", resp.StatusCode, resp.Body)
+	}
+	// Leaving the token out keeps it.
+	if resp, _ := h.PutGitRemote(ctx, makeRequest("PUT", "/export/git/remote", `{"provider":"github","repository":"me/notes","branch":"backup"}`)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT keeping the token = %d %s", resp.StatusCode, resp.Body)
+	}
+
+	resp, _ = h.ExportGit(ctx, makeRequest("POST", "/export/git", ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("export = %d %s", resp.StatusCode, resp.Body)
+	}
+	var out handler.GitExport
+	json.Unmarshal([]byte(resp.Body), &out)
+	if len(out.Commits) != 2 || out.Commits[0].Path != "Stale.md" || out.Commits[1].Path != "Work/Plan.md" || len(out.Skipped) != 1 {
+		t.Errorf("export = %+v", out)
+	}
+	if github.files["Work/Plan.md"] != "# Plan\n" || github.files["LICENSE"] != "MIT" || len(github.files) != 2 {
+		t.Errorf("repository = %q", github.files)
+	}
+	if github.messages[1] != "Add Work/Plan.md\n\nExported from GophDrive." {
+		t.Errorf("messages = %q", github.messages)
+	}
+
+	resp, _ = h.GetGitRemote(ctx, makeRequest("GET", "/export/git/remote", ""))
+	var remote gitexport.Remote
+	json.Unmarshal([]byte(resp.Body), &remote)
+	if remote.Branch != "backup" || remote.LastCommit != "abc123" || remote.LastExportAt == nil {
+		t.Errorf("remote = %s", resp.Body)
+	}
+
+	if resp, _ := h.DeleteGitRemote(ctx, makeRequest("DELETE", "/export/git/remote", "")); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE = %d", resp.StatusCode)
+	}
+	if resp, _ := h.GetGitRemote(ctx, makeRequest("GET", "/export/git/remote", "")); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after DELETE = %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/core/markdown"
+)
+
+// noteRenderer renders notes on the server with the same core package the
+// editor runs as Wasm, so both produce the same HTML.
+var noteRenderer = markdown.NewRenderer()
+
+// MarkdownHandler serves views of a note computed from its Markdown source
+// by the core markdown package.
+type MarkdownHandler struct {
+	storageProvider adapter.StorageProvider
+	jwtSecret       string
+}
+
+// NewMarkdownHandler creates a new MarkdownHandler.
+func NewMarkdownHandler(provider adapter.StorageProvider, jwtSecret string) *MarkdownHandler {
+	return &MarkdownHandler{storageProvider: provider, jwtSecret: jwtSecret}
+}
+
+// note returns the note named in the path, after checking the caller can
+// read it and its content is not encrypted end to end.
+func (h *MarkdownHandler) note(ctx context.Context, req events.APIGatewayProxyRequest) (*adapter.File, *events.APIGatewayProxyResponse) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		resp := Error(ctx, http.StatusUnauthorized, "Unauthorized")
+		return nil, &resp
+	}
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		resp := Error(ctx, http.StatusBadRequest, "Missing note ID")
+		return nil, &resp
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		resp := respondError(ctx, "GetAdapter", fmt.Errorf("%w: %v", ErrUnauthorized, err))
+		return nil, &resp
+	}
+	note, err := storage.GetFile(ctx, noteID)
+	if err == nil && note.Encrypted {
+		err = adapter.ErrEncrypted
+	}
+	if err != nil {
+		resp := respondError(ctx, "Markdown GetFile", err)
+		return nil, &resp
+	}
+	return note, nil
+}
+
+// GetNoteHTML handles GET /notes/{id}/html, returning the note rendered as
+// an HTML fragment. Raw HTML in the note is left out and dangerous URLs are
+// dropped, since the result may be embedded outside the editor.
+func (h *MarkdownHandler) GetNoteHTML(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	note, errResp := h.note(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	html, err := noteRenderer.RenderSanitized(note.Content)
+	if err != nil {
+		return respondError(ctx, "RenderSanitized", err), nil
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(html),
+		Headers: map[string]string{
+			"Content-Type": "text/html; charset=utf-8",
+			"ETag":         note.ETag,
+		},
+	}, nil
+}
+
+// GetNoteStats handles GET /notes/{id}/stats, returning the note's word,
+// character, heading and task counts and reading time, as shown in the
+// editor status bar.
+func (h *MarkdownHandler) GetNoteStats(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	note, errResp := h.note(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	body, _ := json.Marshal(markdown.Stats(note.Content))
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+			"ETag":         note.ETag,
+		},
+	}, nil
+}
+
+// FormattedNote is the response of FormatNote: the note's content after
+// formatting, for the version of the note in ETag. Changed is false when the
+// note was already formatted.
+type FormattedNote struct {
+	Content string `json:"content"`
+	ETag    string `json:"etag"`
+	Changed bool   `json:"changed"`
+}
+
+// FormatNote handles POST /notes/{id}/format, returning the note normalized
+// by markdown.Format. The optional body selects the rules, as
+// markdown.FormatRules; omitted rules keep their format-on-save defaults.
+// Nothing is stored: the client saves the result with PUT /notes/{id},
+// passing ETag as If-Match.
+func (h *MarkdownHandler) FormatNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	note, errResp := h.note(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	rules := markdown.DefaultFormatRules()
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &rules); err != nil {
+			return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+		}
+	}
+	switch rules.ListMarker {
+	case "", "-", "*", "+":
+	default:
+		return Error(ctx, http.StatusBadRequest, "listMarker must be -, * or +"), nil
+	}
+
+	formatted := markdown.Format(note.Content, rules)
+	body, _ := json.Marshal(FormattedNote{
+		Content: string(formatted),
+		ETag:    note.ETag,
+		Changed: !bytes.Equal(formatted, note.Content),
+	})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// LintResult is the response of LintNote: the issues found in the version
+// of the note in ETag, in source order.
+type LintResult struct {
+	Issues []markdown.LintIssue `json:"issues"`
+	ETag   string               `json:"etag"`
+}
+
+// LintNote handles POST /notes/{id}/lint, checking the note with
+// markdown.Lint for broken link syntax, skipped heading levels, unclosed
+// code fences and long lines.
+func (h *MarkdownHandler) LintNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	note, errResp := h.note(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	issues := markdown.Lint(note.Content)
+	if issues == nil {
+		issues = []markdown.LintIssue{}
+	}
+	body, _ := json.Marshal(LintResult{Issues: issues, ETag: note.ETag})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
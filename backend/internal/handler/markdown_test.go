@@ -0,0 +1,129 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/core/markdown"
+)
+
+func TestMarkdownHandler_GetNoteHTML(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewMarkdownHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "page.md", []byte("# Hi\n\n<script>alert(1)</script>\n\n[x](javascript:alert(1))\n"), "")
+
+	req := makeRequest("GET", "/notes/"+note.ID+"/html", "")
+	req.PathParameters["id"] = note.ID
+	resp, _ := h.GetNoteHTML(ctx, req)
+	if resp.StatusCode != http.StatusOK || resp.Headers["Content-Type"] != "text/html; charset=utf-8" {
+		t.Fatalf("Expected 200 HTML, got %d %v: %s", resp.StatusCode, resp.Headers, resp.Body)
+	}
+	if !strings.Contains(resp.Body, `<h1 id="hi">Hi</h1>`) {
+		t.Errorf("Expected the rendered heading, got %s", resp.Body)
+	}
+	if strings.Contains(resp.Body, "<script>") || strings.Contains(resp.Body, "javascript:") {
+		t.Errorf("Expected raw HTML and javascript: links to be dropped, got %s", resp.Body)
+	}
+
+	req.PathParameters["id"] = "missing"
+	if resp, _ := h.GetNoteHTML(ctx, req); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing note, got %d", resp.StatusCode)
+	}
+}
+
+func TestMarkdownHandler_GetNoteStats(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewMarkdownHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	content := []byte("# Todo\n\n- [x] buy milk\n- [ ] pay rent\n")
+	note, _ := storage.CreateFile(ctx, "todo.md", content, "")
+
+	req := makeRequest("GET", "/notes/"+note.ID+"/stats", "")
+	req.PathParameters["id"] = note.ID
+	resp, _ := h.GetNoteStats(ctx, req)
+	var stats markdown.NoteStats
+	json.Unmarshal([]byte(resp.Body), &stats)
+	if resp.StatusCode != http.StatusOK || stats != markdown.Stats(content) {
+		t.Errorf("Expected the core stats, got %d %s", resp.StatusCode, resp.Body)
+	}
+	if stats.Headings != 1 || stats.Tasks != 2 || stats.TasksDone != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestMarkdownHandler_FormatNote(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewMarkdownHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "list.md", []byte("#  List\n* one   \n* two\n"), "")
+
+	format := func(body string) (int, handler.FormattedNote) {
+		req := makeRequest("POST", "/notes/"+note.ID+"/format", body)
+		req.PathParameters["id"] = note.ID
+		resp, _ := h.FormatNote(ctx, req)
+		var f handler.FormattedNote
+		json.Unmarshal([]byte(resp.Body), &f)
+		return resp.StatusCode, f
+	}
+
+	if status, f := format(""); status != http.StatusOK || f.Content != "# List\n\n- one\n- two\n" || !f.Changed || f.ETag != note.ETag {
+		t.Errorf("Expected the default rules, got %d %+v", status, f)
+	}
+	if _, f := format(`{"listMarker":""}`); f.Content != "# List\n\n* one\n* two\n" {
+		t.Errorf("Expected list markers kept, got %q", f.Content)
+	}
+	if status, _ := format(`{"listMarker":"1."}`); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad list marker, got %d", status)
+	}
+	// Nothing is saved.
+	if f, _ := storage.GetFile(ctx, note.ID); string(f.Content) != "#  List\n* one   \n* two\n" {
+		t.Errorf("Expected the note unchanged, got %q", f.Content)
+	}
+}
+
+func TestMarkdownHandler_LintNote(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewMarkdownHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "draft.md", []byte("# Title\n\n### Skipped\n\n```go\nfmt.Println()\n"), "")
+	clean, _ := storage.CreateFile(ctx, "clean.md", []byte("# Title\n\n## Section\n"), "")
+
+	lint := func(id string) handler.LintResult {
+		req := makeRequest("POST", "/notes/"+id+"/lint", "")
+		req.PathParameters["id"] = id
+		resp, _ := h.LintNote(ctx, req)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		var r handler.LintResult
+		json.Unmarshal([]byte(resp.Body), &r)
+		return r
+	}
+
+	r := lint(note.ID)
+	var rules []string
+	for _, issue := range r.Issues {
+		rules = append(rules, fmt.Sprintf("%s@%d", issue.Rule, issue.Line))
+	}
+	if got := strings.Join(rules, " "); got != "heading-increment@3 unclosed-fence@5" {
+		t.Errorf("Expected a heading and a fence issue, got %s", got)
+	}
+	if r := lint(clean.ID); r.Issues == nil || len(r.Issues) != 0 {
+		t.Errorf("Expected an empty issue list, got %+v", r.Issues)
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/mirror"
+)
+
+// MirrorHandler reports on the backup copies kept by mirror.Provider.
+type MirrorHandler struct {
+	entries   *mirror.Store // nil when mirroring is not configured
+	jwtSecret string
+}
+
+// NewMirrorHandler creates a new MirrorHandler. entries is nil if notes are
+// not mirrored.
+func NewMirrorHandler(entries *mirror.Store, jwtSecret string) *MirrorHandler {
+	return &MirrorHandler{entries: entries, jwtSecret: jwtSecret}
+}
+
+// GetMirrorStatus handles GET /mirror/status, counting the caller's notes
+// by mirror status and listing recent failures. Without mirroring it
+// reports {"enabled": false}.
+func (h *MirrorHandler) GetMirrorStatus(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	status := &mirror.Status{Failures: []mirror.Entry{}}
+	if h.entries != nil {
+		if status, err = h.entries.Status(ctx, userID); err != nil {
+			return respondError(ctx, "GetMirrorStatus", err), nil
+		}
+	}
+	body, _ := json.Marshal(status)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
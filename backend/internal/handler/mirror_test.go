@@ -0,0 +1,36 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/localfs"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/mirror"
+)
+
+func TestGetMirrorStatus(t *testing.T) {
+	ctx := context.Background()
+
+	resp, _ := handler.NewMirrorHandler(nil, "test-secret").GetMirrorStatus(ctx, makeRequest("GET", "/mirror/status", ""))
+	if resp.StatusCode != http.StatusOK || resp.Body != `{"enabled":false,"synced":0,"pending":0,"failed":0,"failures":[]}` {
+		t.Errorf("status without mirroring = %d %s", resp.StatusCode, resp.Body)
+	}
+
+	entries := mirror.NewStore(nil, "")
+	provider := mirror.NewProvider(memory.NewProvider(nil, nil), localfs.NewProvider(t.TempDir()), entries)
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	storage.CreateFile(ctx, "Plan", []byte("# Plan"), "")
+	storage.CreateFile(ctx, "Ideas", []byte("# Ideas"), "")
+	provider.Wait()
+
+	resp, _ = handler.NewMirrorHandler(entries, "test-secret").GetMirrorStatus(ctx, makeRequest("GET", "/mirror/status", ""))
+	var status mirror.Status
+	json.Unmarshal([]byte(resp.Body), &status)
+	if resp.StatusCode != http.StatusOK || !status.Enabled || status.Synced != 2 || status.LastMirroredAt == nil {
+		t.Errorf("status = %d %s", resp.StatusCode, resp.Body)
+	}
+}
@@ -1,21 +1,46 @@
 package handler
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/comment"
+	"github.com/jun/gophdrive/backend/internal/draft"
+	"github.com/jun/gophdrive/backend/internal/publish"
+	"github.com/jun/gophdrive/backend/internal/reminder"
+	"github.com/jun/gophdrive/backend/internal/session"
+	"github.com/jun/gophdrive/backend/internal/share"
 )
 
 // NoteHandler handles CRUD operations for notes.
 type NoteHandler struct {
 	storageProvider adapter.StorageProvider
 	jwtSecret       string
+
+	// lockManager is set only when lock enforcement is enabled.
+	lockManager session.Locker
+	// comments is set only when note comments are enabled.
+	comments *comment.Store
+	// drafts is set only when server-side drafts are enabled.
+	drafts *draft.Store
+	// publications is set only when publishing is enabled.
+	publications *publish.Store
+	// reminders is set only when task reminders are enabled.
+	reminders *reminder.Store
+	// shares is set only when users can share notes with each other.
+	shares *share.Store
 }
 
 // NewNoteHandler creates a new NoteHandler.
@@ -23,38 +48,231 @@ func NewNoteHandler(provider adapter.StorageProvider, jwtSecret string) *NoteHan
 	return &NoteHandler{storageProvider: provider, jwtSecret: jwtSecret}
 }
 
+// EnableLockEnforcement makes UpdateNote reject saves from users who do not
+// hold the active editing lock. Saves are still allowed when no lock exists.
+func (h *NoteHandler) EnableLockEnforcement(lockManager session.Locker) {
+	h.lockManager = lockManager
+}
+
+// EnableComments makes note listings report comment counts from comments,
+// and deleting a note delete its comments.
+func (h *NoteHandler) EnableComments(comments *comment.Store) {
+	h.comments = comments
+}
+
+// EnableDrafts makes saving or deleting a note discard the caller's
+// server-side draft of it.
+func (h *NoteHandler) EnableDrafts(drafts *draft.Store) {
+	h.drafts = drafts
+}
+
+// EnablePublishing makes deleting a note unpublish it.
+func (h *NoteHandler) EnablePublishing(publications *publish.Store) {
+	h.publications = publications
+}
+
+// EnableReminders makes saving a note index the due dates of its tasks,
+// and deleting a note drop them.
+func (h *NoteHandler) EnableReminders(reminders *reminder.Store) {
+	h.reminders = reminders
+}
+
+// EnableSharing lets users open, list and save the notes and folders
+// other users shared with them, and makes deleting a note delete its
+// shares.
+func (h *NoteHandler) EnableSharing(shares *share.Store) {
+	h.shares = shares
+}
+
+// indexReminders replaces the reminders of a saved note with those in
+// content, if reminders are enabled. Encrypted content has no readable
+// tasks, so it clears them. Failures are logged: the next save retries.
+func (h *NoteHandler) indexReminders(ctx context.Context, req events.APIGatewayProxyRequest, note *adapter.FileMetadata, content string) {
+	claims, err := requestUserClaims(ctx, req, h.jwtSecret)
+	if err != nil {
+		return
+	}
+	h.indexRemindersFor(ctx, claims.UserID, claims.Email, note, content)
+}
+
+// indexRemindersFor is indexReminders for a note owned by userID, such as
+// one shared with the caller.
+func (h *NoteHandler) indexRemindersFor(ctx context.Context, userID, email string, note *adapter.FileMetadata, content string) {
+	if h.reminders == nil {
+		return
+	}
+	var reminders []reminder.Reminder
+	if !adapter.IsEncrypted([]byte(content)) {
+		reminders = reminder.FromNote(userID, email, note.ID, note.Name, content)
+	}
+	if err := h.reminders.Replace(ctx, userID, note.ID, reminders); err != nil {
+		slog.WarnContext(ctx, "Indexing reminders failed", "note_id", note.ID, "error", err)
+	}
+}
+
+// discardDraft deletes the caller's draft of noteID, if drafts are enabled.
+// Failures are logged: the draft expires on its own.
+func (h *NoteHandler) discardDraft(ctx context.Context, req events.APIGatewayProxyRequest, noteID string) {
+	if h.drafts == nil {
+		return
+	}
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return
+	}
+	if err := h.drafts.Delete(ctx, userID, noteID); err != nil {
+		slog.WarnContext(ctx, "Discarding draft failed", "note_id", noteID, "error", err)
+	}
+}
+
+// addCommentCounts sets CommentCount on files. Counts are left out if they
+// cannot be read, rather than failing the listing.
+func (h *NoteHandler) addCommentCounts(ctx context.Context, files []adapter.FileMetadata) {
+	if h.comments == nil || len(files) == 0 {
+		return
+	}
+	ids := make([]string, len(files))
+	for i, f := range files {
+		ids[i] = f.ID
+	}
+	counts, err := h.comments.Counts(ctx, ids)
+	if err != nil {
+		slog.WarnContext(ctx, "Comment counts failed", "error", err)
+		return
+	}
+	for i := range files {
+		files[i].CommentCount = counts[files[i].ID]
+	}
+}
+
+// checkEditLock returns a 423 response when lock enforcement is enabled and
+// another user holds the editing lock on id.
+func (h *NoteHandler) checkEditLock(ctx context.Context, req events.APIGatewayProxyRequest, id string) *events.APIGatewayProxyResponse {
+	if h.lockManager == nil {
+		return nil
+	}
+	userID, _ := requestUserID(ctx, req, h.jwtSecret)
+	lock, err := h.lockManager.GetLockStatus(ctx, id)
+	if err != nil {
+		resp := respondError(ctx, "GetLockStatus", err)
+		return &resp
+	}
+	if lock != nil && lock.UserID != userID {
+		resp := ErrorResponse{Code: CodeLockHeld, Message: "Note is locked by another user"}.Response(ctx, http.StatusLocked)
+		return &resp
+	}
+	return nil
+}
+
 // getStorageAdapter creates a new storage adapter for the authenticated user.
 func (h *NoteHandler) getStorageAdapter(ctx context.Context, req events.APIGatewayProxyRequest) (adapter.StorageAdapter, error) {
-	userID, err := GetUserID(req, h.jwtSecret)
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
 	}
 
 	storage, err := h.storageProvider.GetAdapter(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get storage adapter: %w", err)
+		// Usually a missing or revoked Google token; a 401 makes the client
+		// sign in again.
+		return nil, fmt.Errorf("%w: get storage adapter: %v", ErrUnauthorized, err)
 	}
 
 	return storage, nil
 }
 
+// sharedStorage returns the storage of the user who shared note or folder
+// id with the caller, with at least permission, and their share. It is
+// used once id was not found in the caller's own storage, and to list
+// shared folders, so it returns adapter.ErrNotFound when the note is not
+// shared with the caller.
+func (h *NoteHandler) sharedStorage(ctx context.Context, req events.APIGatewayProxyRequest, id, permission string) (adapter.StorageAdapter, *share.Share, error) {
+	if h.shares == nil {
+		return nil, nil, adapter.ErrNotFound
+	}
+	claims, err := requestUserClaims(ctx, req, h.jwtSecret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+	email, err := share.NormalizeEmail(claims.Email)
+	if err != nil || isDemoUser(claims.UserID) {
+		return nil, nil, adapter.ErrNotFound
+	}
+	sh, err := h.shares.Find(ctx, email, id)
+	if errors.Is(err, share.ErrNotFound) || err == nil && sh.OwnerID == claims.UserID {
+		return nil, nil, adapter.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !sh.Allows(permission) {
+		return nil, nil, share.ErrReadOnly
+	}
+	storage, err := h.storageProvider.GetAdapter(ctx, sh.OwnerID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get storage of %s: %w", sh.OwnerID, err)
+	}
+	return storage, sh, nil
+}
+
 // ListNotes lists all notes in the specified folder (or root "GophDrive" folder if not specified).
+// Archived notes are left out unless ?includeArchived=true.
 func (h *NoteHandler) ListNotes(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	folderID := req.QueryStringParameters["folderId"]
 	if folderID == "" {
 
 	}
+	propKey, propValue, err := parsePropertyFilter(req.QueryStringParameters["property"])
+	if err != nil {
+		return Error(ctx, http.StatusBadRequest, err.Error()), nil
+	}
 
 	files, err := storage.ListFiles(ctx, folderID)
 	if err != nil {
-		fmt.Printf("ListFiles error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to list notes: %v", err)}, nil
+		return respondError(ctx, "ListFiles", err), nil
+	}
+	files = filterByProperty(files, propKey, propValue)
+	if req.QueryStringParameters["includeArchived"] != "true" {
+		files = withoutArchived(files)
 	}
+	h.addCommentCounts(ctx, files)
+
+	body, _ := json.Marshal(files)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// ListSharedFolder handles GET /shared-with-me/{id}/notes, listing a folder
+// another user shared with the caller, or a folder inside it, from the
+// owner's storage. Archived notes are left out unless ?includeArchived=true.
+func (h *NoteHandler) ListSharedFolder(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	folderID := req.PathParameters["id"]
+	if folderID == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing folder ID"), nil
+	}
+
+	storage, _, err := h.sharedStorage(ctx, req, folderID, share.PermissionRead)
+	if err != nil {
+		return respondError(ctx, "SharedStorage", err), nil
+	}
+	files, err := storage.ListFiles(ctx, folderID)
+	if err != nil {
+		return respondError(ctx, "ListFiles", err), nil
+	}
+	if req.QueryStringParameters["includeArchived"] != "true" {
+		files = withoutArchived(files)
+	}
+	h.addCommentCounts(ctx, files)
 
 	body, _ := json.Marshal(files)
 	return events.APIGatewayProxyResponse{
@@ -70,7 +288,7 @@ func (h *NoteHandler) ListNotes(ctx context.Context, req events.APIGatewayProxyR
 func (h *NoteHandler) CreateFolder(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	var payload struct {
@@ -78,11 +296,11 @@ func (h *NoteHandler) CreateFolder(ctx context.Context, req events.APIGatewayPro
 		ParentID string `json:"parentId"`
 	}
 	if err := json.Unmarshal([]byte(req.Body), &payload); err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
 	}
 
 	if payload.Name == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Folder name is required"}, nil
+		return Error(ctx, http.StatusBadRequest, "Folder name is required"), nil
 	}
 
 	parents := []string{}
@@ -93,8 +311,7 @@ func (h *NoteHandler) CreateFolder(ctx context.Context, req events.APIGatewayPro
 
 	folder, err := storage.CreateFolder(ctx, payload.Name, parents)
 	if err != nil {
-		fmt.Printf("CreateFolder error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to create folder: %v", err)}, nil
+		return respondError(ctx, "CreateFolder", err), nil
 	}
 
 	body, _ := json.Marshal(folder)
@@ -107,45 +324,58 @@ func (h *NoteHandler) CreateFolder(ctx context.Context, req events.APIGatewayPro
 	}, nil
 }
 
+// NoteResponse is a note with its content as returned by GetNote. Modified
+// is an RFC 3339 time.
+type NoteResponse struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Content      string   `json:"content"`
+	Modified     string   `json:"modified"`
+	ETag         string   `json:"etag"`
+	Parents      []string `json:"parents"`
+	Encrypted    bool     `json:"encrypted,omitempty"`
+	Protected    bool     `json:"protected,omitempty"`
+	CommentCount int      `json:"commentCount,omitempty"`
+}
+
 // GetNote retrieves a simplified note representation.
 func (h *NoteHandler) GetNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	id := req.PathParameters["id"]
 	if id == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing note ID"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
 	}
 
 	file, err := storage.GetFile(ctx, id)
-	if err != nil {
-		if errors.Is(err, adapter.ErrNotFound) {
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: "Note not found"}, nil
+	if errors.Is(err, adapter.ErrNotFound) {
+		if storage, _, err = h.sharedStorage(ctx, req, id, share.PermissionRead); err == nil {
+			file, err = storage.GetFile(ctx, id)
 		}
-		fmt.Printf("GetFile error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to get note: %v", err)}, nil
 	}
-
-	// For MVP, just return content as string in body, or JSON if model.Note
-	// Let's return JSON wrapping content.
-	type NoteResponse struct {
-		ID       string   `json:"id"`
-		Name     string   `json:"name"`
-		Content  string   `json:"content"`
-		Modified string   `json:"modified"`
-		ETag     string   `json:"etag"`
-		Parents  []string `json:"parents"`
+	if err != nil {
+		return respondError(ctx, "GetFile", err), nil
 	}
+	return h.noteResponse(ctx, file), nil
+}
 
+// noteResponse returns file as a NoteResponse, with its ETag header.
+func (h *NoteHandler) noteResponse(ctx context.Context, file *adapter.File) events.APIGatewayProxyResponse {
+	meta := []adapter.FileMetadata{file.FileMetadata}
+	h.addCommentCounts(ctx, meta)
 	resp := NoteResponse{
-		ID:       file.ID,
-		Name:     file.Name,
-		Content:  string(file.Content),
-		Modified: file.ModifiedTime.Format(time.RFC3339),
-		ETag:     file.ETag,
-		Parents:  file.Parents,
+		ID:           file.ID,
+		Name:         file.Name,
+		Content:      string(file.Content),
+		Modified:     file.ModifiedTime.Format(time.RFC3339),
+		ETag:         file.ETag,
+		Parents:      file.Parents,
+		Encrypted:    file.Encrypted,
+		Protected:    file.Protected,
+		CommentCount: meta[0].CommentCount,
 	}
 
 	body, _ := json.Marshal(resp)
@@ -156,23 +386,31 @@ func (h *NoteHandler) GetNote(ctx context.Context, req events.APIGatewayProxyReq
 			"Content-Type": "application/json",
 			"ETag":         file.ETag,
 		},
-	}, nil
+	}
 }
 
 // CreateNote creates a new note.
 func (h *NoteHandler) CreateNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	var input struct {
-		Name     string `json:"name"`
-		Content  string `json:"content"`
-		ParentID string `json:"parentId"`
+		Name      string `json:"name"`
+		Content   string `json:"content"`
+		ParentID  string `json:"parentId"`
+		Encrypted bool   `json:"encrypted"`
+		Protected bool   `json:"protected"`
 	}
 	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if input.Encrypted && !adapter.IsEncrypted([]byte(input.Content)) {
+		return Error(ctx, http.StatusBadRequest, "Encrypted notes must be sealed on the client before upload"), nil
+	}
+	if input.Protected && !adapter.IsProtected([]byte(input.Content)) {
+		return Error(ctx, http.StatusBadRequest, "Protected notes must be sealed with their passphrase before upload"), nil
 	}
 
 	folderID := input.ParentID
@@ -182,9 +420,9 @@ func (h *NoteHandler) CreateNote(ctx context.Context, req events.APIGatewayProxy
 
 	file, err := storage.CreateFile(ctx, input.Name, []byte(input.Content), folderID)
 	if err != nil {
-		fmt.Printf("CreateFile error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to create note: %v", err)}, nil
+		return respondError(ctx, "CreateFile", err), nil
 	}
+	h.indexReminders(ctx, req, file, input.Content)
 
 	body, _ := json.Marshal(file)
 	return events.APIGatewayProxyResponse{
@@ -200,19 +438,31 @@ func (h *NoteHandler) CreateNote(ctx context.Context, req events.APIGatewayProxy
 func (h *NoteHandler) UpdateNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	id := req.PathParameters["id"]
 	if id == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing note ID"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
 	}
 
 	var input struct {
-		Content string `json:"content"`
+		Content   string `json:"content"`
+		Encrypted bool   `json:"encrypted"`
+		Protected bool   `json:"protected"`
 	}
 	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if input.Encrypted && !adapter.IsEncrypted([]byte(input.Content)) {
+		return Error(ctx, http.StatusBadRequest, "Encrypted notes must be sealed on the client before upload"), nil
+	}
+	if input.Protected && !adapter.IsProtected([]byte(input.Content)) {
+		return Error(ctx, http.StatusBadRequest, "Protected notes must be sealed with their passphrase before upload"), nil
+	}
+
+	if errResp := h.checkEditLock(ctx, req, id); errResp != nil {
+		return *errResp, nil
 	}
 
 	// Verify ETag from header (If-Match)
@@ -221,15 +471,20 @@ func (h *NoteHandler) UpdateNote(ctx context.Context, req events.APIGatewayProxy
 	// For optimistic locking, client SHOULD send If-Match.
 
 	file, err := storage.SaveFile(ctx, id, []byte(input.Content), etag)
-	if err != nil {
-		if errors.Is(err, adapter.ErrPreconditionFailed) {
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusPreconditionFailed, Body: "ETag mismatch"}, nil
-		}
-		if errors.Is(err, adapter.ErrNotFound) {
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: "Note not found"}, nil
+	var sh *share.Share
+	if errors.Is(err, adapter.ErrNotFound) {
+		if storage, sh, err = h.sharedStorage(ctx, req, id, share.PermissionWrite); err == nil {
+			file, err = storage.SaveFile(ctx, id, []byte(input.Content), etag)
 		}
-		fmt.Printf("SaveFile error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to update note: %v", err)}, nil
+	}
+	if err != nil {
+		return respondError(ctx, "SaveFile", err), nil
+	}
+	h.discardDraft(ctx, req, id)
+	if sh != nil {
+		h.indexRemindersFor(ctx, sh.OwnerID, sh.OwnerEmail, file, input.Content)
+	} else {
+		h.indexReminders(ctx, req, file, input.Content)
 	}
 
 	body, _ := json.Marshal(file)
@@ -246,42 +501,67 @@ func (h *NoteHandler) UpdateNote(ctx context.Context, req events.APIGatewayProxy
 func (h *NoteHandler) DeleteNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	id := req.PathParameters["id"]
 	if id == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing note ID"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
 	}
 
 	err = storage.DeleteFile(ctx, id)
 	if err != nil {
-		fmt.Printf("DeleteFile error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to delete note: %v", err)}, nil
+		return respondError(ctx, "DeleteFile", err), nil
 	}
+	h.forgetNote(ctx, req, id)
 
 	return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, nil
 }
 
+// forgetNote removes what the other stores keep about deleted note id.
+func (h *NoteHandler) forgetNote(ctx context.Context, req events.APIGatewayProxyRequest, id string) {
+	if h.comments != nil {
+		// The note is gone either way; leftover comments are unreachable.
+		if err := h.comments.DeleteNote(ctx, id); err != nil {
+			slog.WarnContext(ctx, "Deleting comments failed", "note_id", id, "error", err)
+		}
+	}
+	h.discardDraft(ctx, req, id)
+	if h.reminders != nil {
+		if userID, err := requestUserID(ctx, req, h.jwtSecret); err == nil {
+			if err := h.reminders.DeleteNote(ctx, userID, id); err != nil {
+				slog.WarnContext(ctx, "Deleting reminders failed", "note_id", id, "error", err)
+			}
+		}
+	}
+	if h.shares != nil {
+		if err := h.shares.DeleteNote(ctx, id); err != nil {
+			slog.WarnContext(ctx, "Deleting shares failed", "note_id", id, "error", err)
+		}
+	}
+	if h.publications != nil {
+		// A deleted note must not stay readable through its share links.
+		if err := h.publications.Delete(ctx, id); err != nil {
+			slog.ErrorContext(ctx, "Unpublishing deleted note failed", "note_id", id, "error", err)
+		}
+	}
+}
+
 // DuplicateNote duplicates a note.
 func (h *NoteHandler) DuplicateNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	id := req.PathParameters["id"]
 	if id == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing note ID"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
 	}
 
 	newFile, err := storage.DuplicateFile(ctx, id)
 	if err != nil {
-		if errors.Is(err, adapter.ErrNotFound) {
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: "Note not found"}, nil
-		}
-		fmt.Printf("DuplicateFile error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to duplicate note: %v", err)}, nil
+		return respondError(ctx, "DuplicateFile", err), nil
 	}
 
 	body, _ := json.Marshal(newFile)
@@ -298,32 +578,28 @@ func (h *NoteHandler) DuplicateNote(ctx context.Context, req events.APIGatewayPr
 func (h *NoteHandler) RenameNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	id := req.PathParameters["id"]
 	if id == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing note ID"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
 	}
 
 	var input struct {
 		Name string `json:"name"`
 	}
 	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
 	}
 
 	if input.Name == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Name is required"}, nil
+		return Error(ctx, http.StatusBadRequest, "Name is required"), nil
 	}
 
 	updatedFile, err := storage.RenameFile(ctx, id, input.Name)
 	if err != nil {
-		if errors.Is(err, adapter.ErrNotFound) {
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: "Note not found"}, nil
-		}
-		fmt.Printf("RenameFile error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to rename note: %v", err)}, nil
+		return respondError(ctx, "RenameFile", err), nil
 	}
 
 	body, _ := json.Marshal(updatedFile)
@@ -336,24 +612,37 @@ func (h *NoteHandler) RenameNote(ctx context.Context, req events.APIGatewayProxy
 	}, nil
 }
 
-// PatchNote handles partial updates to a note (e.g. starring).
+// folderColorPattern matches the RGB hex colors accepted for folders.
+var folderColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// maxIconLength is the longest folder icon accepted, in characters.
+const maxIconLength = 32
+
+// PatchNote handles partial updates to a note (e.g. starring), including a
+// folder's color and icon.
 func (h *NoteHandler) PatchNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	id := req.PathParameters["id"]
 	if id == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing note ID"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
 	}
 
 	var input struct {
-		Name    *string `json:"name"`
-		Starred *bool   `json:"starred"`
+		Name     *string `json:"name"`
+		Starred  *bool   `json:"starred"`
+		Color    *string `json:"color"`
+		Icon     *string `json:"icon"`
+		Archived *bool   `json:"archived"`
+		// Properties are merged into the note's own; an empty value
+		// removes a key.
+		Properties map[string]string `json:"properties"`
 	}
 	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
 	}
 
 	var updatedFile *adapter.FileMetadata
@@ -361,16 +650,12 @@ func (h *NoteHandler) PatchNote(ctx context.Context, req events.APIGatewayProxyR
 	// Handle Rename
 	if input.Name != nil {
 		if *input.Name == "" {
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Name cannot be empty"}, nil
+			return Error(ctx, http.StatusBadRequest, "Name cannot be empty"), nil
 		}
 		var err error
 		updatedFile, err = storage.RenameFile(ctx, id, *input.Name)
 		if err != nil {
-			if errors.Is(err, adapter.ErrNotFound) {
-				return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: "Note not found"}, nil
-			}
-			fmt.Printf("RenameFile error: %v\n", err)
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to rename note: %v", err)}, nil
+			return respondError(ctx, "RenameFile", err), nil
 		}
 	}
 
@@ -386,11 +671,49 @@ func (h *NoteHandler) PatchNote(ctx context.Context, req events.APIGatewayProxyR
 		var err error
 		updatedFile, err = storage.SetStarred(ctx, id, *input.Starred)
 		if err != nil {
-			if errors.Is(err, adapter.ErrNotFound) {
-				return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: "Note not found"}, nil
+			return respondError(ctx, "SetStarred", err), nil
+		}
+		// A note starred again goes to the end of the list, not its old place.
+		if !*input.Starred && updatedFile.OrderIndex != 0 {
+			if updatedFile, err = storage.SetOrderIndex(ctx, id, 0); err != nil {
+				return respondError(ctx, "SetOrderIndex", err), nil
 			}
-			fmt.Printf("SetStarred error: %v\n", err)
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: fmt.Sprintf("Failed to update starred status: %v", err)}, nil
+		}
+	}
+
+	// Handle folder color and icon
+	if input.Color != nil || input.Icon != nil {
+		if input.Color != nil && *input.Color != "" && !folderColorPattern.MatchString(*input.Color) {
+			return Error(ctx, http.StatusBadRequest, "Color must be an RGB hex string such as #4986e7"), nil
+		}
+		if input.Icon != nil && utf8.RuneCountInString(*input.Icon) > maxIconLength {
+			return Error(ctx, http.StatusBadRequest, fmt.Sprintf("Icons are limited to %d characters", maxIconLength)), nil
+		}
+		var err error
+		updatedFile, err = storage.SetAppearance(ctx, id, input.Color, input.Icon)
+		if err != nil {
+			return respondError(ctx, "SetAppearance", err), nil
+		}
+	}
+
+	// Handle archiving
+	if input.Archived != nil {
+		var err error
+		updatedFile, err = storage.SetArchived(ctx, id, *input.Archived)
+		if err != nil {
+			return respondError(ctx, "SetArchived", err), nil
+		}
+	}
+
+	// Handle custom properties
+	if len(input.Properties) > 0 {
+		if err := validateProperties(input.Properties); err != nil {
+			return Error(ctx, http.StatusBadRequest, err.Error()), nil
+		}
+		var err error
+		updatedFile, err = storage.SetProperties(ctx, id, input.Properties)
+		if err != nil {
+			return respondError(ctx, "SetProperties", err), nil
 		}
 	}
 
@@ -399,7 +722,7 @@ func (h *NoteHandler) PatchNote(ctx context.Context, req events.APIGatewayProxyR
 	if updatedFile == nil {
 		// Just return the file as is? Or error?
 		// Let's assume we wanted to do something.
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "No valid fields to update"}, nil
+		return Error(ctx, http.StatusBadRequest, "No valid fields to update"), nil
 	}
 
 	body, _ := json.Marshal(updatedFile)
@@ -416,14 +739,179 @@ func (h *NoteHandler) PatchNote(ctx context.Context, req events.APIGatewayProxyR
 func (h *NoteHandler) ListStarredNotes(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	files, err := storage.ListStarred(ctx)
+	if err != nil {
+		return respondError(ctx, "ListStarred", err), nil
+	}
+	sortStarred(files)
+	h.addCommentCounts(ctx, files)
+
+	body, _ := json.Marshal(files)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// ListArchivedNotes handles GET /archive, listing archived notes/folders by
+// name.
+func (h *NoteHandler) ListArchivedNotes(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	files, err := storage.ListArchived(ctx)
+	if err != nil {
+		return respondError(ctx, "ListArchived", err), nil
+	}
+	if files == nil {
+		files = []adapter.FileMetadata{}
+	}
+	slices.SortFunc(files, func(a, b adapter.FileMetadata) int {
+		return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	h.addCommentCounts(ctx, files)
+
+	body, _ := json.Marshal(files)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// DuplicateGroup is a set of notes with the same content.
+type DuplicateGroup struct {
+	ContentHash string                 `json:"contentHash"`
+	Notes       []adapter.FileMetadata `json:"notes"`
+}
+
+// ListDuplicates handles GET /duplicates. It groups the notes under the
+// base folder whose content is identical, oldest note first in each group,
+// so users who imported notes twice can clean up. Empty notes are not
+// reported.
+func (h *NoteHandler) ListDuplicates(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	notes, err := listAllNotes(ctx, storage)
+	if err != nil {
+		return respondError(ctx, "ListDuplicates", err), nil
+	}
+
+	byHash := make(map[string][]adapter.FileMetadata)
+	for _, n := range notes {
+		if n.ContentHash == "" || n.Size == 0 {
+			continue
+		}
+		byHash[n.ContentHash] = append(byHash[n.ContentHash], n)
+	}
+
+	groups := []DuplicateGroup{}
+	for hash, group := range byHash {
+		if len(group) < 2 {
+			continue
+		}
+		slices.SortFunc(group, func(a, b adapter.FileMetadata) int {
+			return cmp.Or(a.ModifiedTime.Compare(b.ModifiedTime), cmp.Compare(a.ID, b.ID))
+		})
+		groups = append(groups, DuplicateGroup{ContentHash: hash, Notes: group})
+	}
+	slices.SortFunc(groups, func(a, b DuplicateGroup) int {
+		return cmp.Compare(strings.ToLower(a.Notes[0].Name), strings.ToLower(b.Notes[0].Name))
+	})
+
+	body, _ := json.Marshal(groups)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// withoutArchived drops archived files from a listing.
+func withoutArchived(files []adapter.FileMetadata) []adapter.FileMetadata {
+	return slices.DeleteFunc(files, func(f adapter.FileMetadata) bool { return f.Archived })
+}
+
+// maxStarredOrder bounds the IDs accepted by ReorderStarred.
+const maxStarredOrder = 500
+
+// sortStarred orders files as the user arranged them. Items never placed
+// follow, by name.
+func sortStarred(files []adapter.FileMetadata) {
+	slices.SortStableFunc(files, func(a, b adapter.FileMetadata) int {
+		if (a.OrderIndex == 0) != (b.OrderIndex == 0) {
+			return cmp.Compare(b.OrderIndex, a.OrderIndex) // 0 last
+		}
+		return cmp.Or(
+			cmp.Compare(a.OrderIndex, b.OrderIndex),
+			cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+		)
+	})
+}
+
+// ReorderStarred handles PATCH /starred/order. The body lists starred item
+// IDs in the order the user wants; starred items left out move to the end.
+// It returns the reordered starred list.
+func (h *NoteHandler) ReorderStarred(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	var input struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if len(input.IDs) > maxStarredOrder {
+		return Error(ctx, http.StatusBadRequest, fmt.Sprintf("At most %d items can be ordered", maxStarredOrder)), nil
 	}
 
 	files, err := storage.ListStarred(ctx)
 	if err != nil {
-		fmt.Printf("ListStarred error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to list starred notes"}, nil
+		return respondError(ctx, "ListStarred", err), nil
+	}
+	want := make(map[string]int, len(input.IDs))
+	for i, id := range input.IDs {
+		if _, dup := want[id]; dup {
+			return Error(ctx, http.StatusBadRequest, "Duplicate ID "+id), nil
+		}
+		want[id] = i + 1
+	}
+	for id := range want {
+		if !slices.ContainsFunc(files, func(f adapter.FileMetadata) bool { return f.ID == id }) {
+			return Error(ctx, http.StatusBadRequest, "Only starred items can be ordered; "+id+" is not starred"), nil
+		}
+	}
+
+	// Only items whose position changes are written.
+	for i, f := range files {
+		if f.OrderIndex == want[f.ID] {
+			continue
+		}
+		if _, err := storage.SetOrderIndex(ctx, f.ID, want[f.ID]); err != nil {
+			return respondError(ctx, "SetOrderIndex", err), nil
+		}
+		files[i].OrderIndex = want[f.ID]
 	}
+	sortStarred(files)
+	h.addCommentCounts(ctx, files)
 
 	body, _ := json.Marshal(files)
 	return events.APIGatewayProxyResponse{
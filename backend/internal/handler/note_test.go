@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/jun/gophdrive/backend/internal/adapter"
 	"github.com/jun/gophdrive/backend/internal/adapter/memory"
 	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/session"
 )
 
 const testUserID = "test-user-123"
@@ -89,6 +92,76 @@ func TestNoteHandler_CreateAndList(t *testing.T) {
 	}
 }
 
+func TestNoteHandler_CreateNote_Encrypted(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	// Claiming encryption without sealed content is rejected.
+	req := makeRequest("POST", "/notes", `{"name":"diary.md","content":"# Plain","encrypted":true}`)
+	resp, _ := h.CreateNote(ctx, req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for unsealed content, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	req = makeRequest("POST", "/notes", `{"name":"diary.md","content":"`+adapter.EncryptedPrefix+`v1:c2FsdA==:abc","encrypted":true}`)
+	resp, _ = h.CreateNote(ctx, req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201 Created, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var created adapter.FileMetadata
+	json.Unmarshal([]byte(resp.Body), &created)
+	if !created.Encrypted {
+		t.Error("Expected created note to be marked encrypted")
+	}
+
+	getReq := makeRequest("GET", "/notes/"+created.ID, "")
+	getReq.PathParameters["id"] = created.ID
+	getResp, _ := h.GetNote(ctx, getReq)
+	var note struct {
+		Encrypted bool `json:"encrypted"`
+	}
+	json.Unmarshal([]byte(getResp.Body), &note)
+	if !note.Encrypted {
+		t.Errorf("Expected GetNote to report encrypted, got %s", getResp.Body)
+	}
+}
+
+func TestNoteHandler_CreateNote_Protected(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	// A note encrypted end to end is not protected by its own passphrase.
+	req := makeRequest("POST", "/notes", `{"name":"diary.md","content":"`+adapter.EncryptedPrefix+`v2:abc","protected":true}`)
+	resp, _ := h.CreateNote(ctx, req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for an unprotected note, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	req = makeRequest("POST", "/notes", `{"name":"diary.md","content":"`+adapter.ProtectedPrefix+`v2:abc","protected":true}`)
+	resp, _ = h.CreateNote(ctx, req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201 Created, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var created adapter.FileMetadata
+	json.Unmarshal([]byte(resp.Body), &created)
+	if !created.Encrypted || !created.Protected {
+		t.Errorf("Expected created note to be encrypted and protected, got %s", resp.Body)
+	}
+
+	getReq := makeRequest("GET", "/notes/"+created.ID, "")
+	getReq.PathParameters["id"] = created.ID
+	getResp, _ := h.GetNote(ctx, getReq)
+	var note struct {
+		Protected bool `json:"protected"`
+	}
+	json.Unmarshal([]byte(getResp.Body), &note)
+	if !note.Protected {
+		t.Errorf("Expected GetNote to report protected, got %s", getResp.Body)
+	}
+}
+
 func TestNoteHandler_GetNote(t *testing.T) {
 	provider := memory.NewProvider(nil, nil)
 	h := handler.NewNoteHandler(provider, "test-secret")
@@ -421,6 +494,217 @@ func TestNoteHandler_ListStarredNotes(t *testing.T) {
 	}
 }
 
+func TestNoteHandler_ReorderStarred(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	var ids []string
+	for _, name := range []string{"a", "b", "c"} {
+		f, _ := storage.CreateFile(ctx, name, []byte(name), "")
+		storage.SetStarred(ctx, f.ID, true)
+		ids = append(ids, f.ID)
+	}
+	starredIDs := func(body string) []string {
+		var files []adapter.FileMetadata
+		json.Unmarshal([]byte(body), &files)
+		var got []string
+		for _, f := range files {
+			got = append(got, f.ID)
+		}
+		return got
+	}
+
+	// c first, then a; b was left out and follows.
+	req := makeRequest("PATCH", "/starred/order", `{"ids":["`+ids[2]+`","`+ids[0]+`"]}`)
+	resp, _ := h.ReorderStarred(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	want := []string{ids[2], ids[0], ids[1]}
+	if got := starredIDs(resp.Body); !slices.Equal(got, want) {
+		t.Errorf("Reorder response order = %v, want %v", got, want)
+	}
+	listResp, _ := h.ListStarredNotes(ctx, makeRequest("GET", "/starred", ""))
+	if got := starredIDs(listResp.Body); !slices.Equal(got, want) {
+		t.Errorf("ListStarredNotes order = %v, want %v", got, want)
+	}
+
+	// Unstarring forgets the position.
+	unstar := makeRequest("PATCH", "/notes/"+ids[2], `{"starred":false}`)
+	unstar.PathParameters["id"] = ids[2]
+	resp, _ = h.PatchNote(ctx, unstar)
+	var patched adapter.FileMetadata
+	json.Unmarshal([]byte(resp.Body), &patched)
+	if patched.OrderIndex != 0 {
+		t.Errorf("Expected orderIndex cleared on unstar, got %d", patched.OrderIndex)
+	}
+
+	for _, body := range []string{`{"ids":["` + ids[0] + `","` + ids[0] + `"]}`, `{"ids":["` + ids[2] + `"]}`, `not-json`} {
+		if resp, _ := h.ReorderStarred(ctx, makeRequest("PATCH", "/starred/order", body)); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("ReorderStarred(%s) = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestNoteHandler_PatchNote_FolderAppearance(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	folder, _ := storage.CreateFolder(ctx, "Projects", nil)
+	note, _ := storage.CreateFile(ctx, "note", []byte("content"), "")
+
+	req := makeRequest("PATCH", "/notes/"+folder.ID, `{"color":"#4986e7","icon":"rocket"}`)
+	req.PathParameters["id"] = folder.ID
+	resp, _ := h.PatchNote(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var updated adapter.FileMetadata
+	json.Unmarshal([]byte(resp.Body), &updated)
+	if updated.Color != "#4986e7" || updated.Icon != "rocket" {
+		t.Errorf("Unexpected folder %+v", updated)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		body string
+	}{
+		{"bad color", folder.ID, `{"color":"blue"}`},
+		{"long icon", folder.ID, `{"icon":"` + strings.Repeat("x", 33) + `"}`},
+		{"note", note.ID, `{"color":"#4986e7"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := makeRequest("PATCH", "/notes/"+tt.id, tt.body)
+			req.PathParameters["id"] = tt.id
+			if resp, _ := h.PatchNote(ctx, req); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d: %s", resp.StatusCode, resp.Body)
+			}
+		})
+	}
+}
+
+func TestNoteHandler_Properties(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	open, _ := storage.CreateFile(ctx, "open", []byte("a"), "")
+	done, _ := storage.CreateFile(ctx, "done", []byte("b"), "")
+	for id, status := range map[string]string{open.ID: "open", done.ID: "done"} {
+		req := makeRequest("PATCH", "/notes/"+id, `{"properties":{"status":"`+status+`"}}`)
+		req.PathParameters["id"] = id
+		resp, _ := h.PatchNote(ctx, req)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+	}
+
+	list := makeRequest("GET", "/notes", "")
+	list.QueryStringParameters = map[string]string{"property": "status:open"}
+	resp, _ := h.ListNotes(ctx, list)
+	var files []adapter.FileMetadata
+	json.Unmarshal([]byte(resp.Body), &files)
+	if len(files) != 1 || files[0].ID != open.ID || files[0].Properties["status"] != "open" {
+		t.Errorf("Expected only the open note, got %s", resp.Body)
+	}
+
+	list.QueryStringParameters = map[string]string{"property": "status"}
+	if resp, _ := h.ListNotes(ctx, list); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a filter without a value, got %d", resp.StatusCode)
+	}
+
+	bad := makeRequest("PATCH", "/notes/"+open.ID, `{"properties":{"has space":"x"}}`)
+	bad.PathParameters["id"] = open.ID
+	if resp, _ := h.PatchNote(ctx, bad); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid key, got %d", resp.StatusCode)
+	}
+}
+
+func TestNoteHandler_Archive(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	search := handler.NewSearchHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	old, _ := storage.CreateFile(ctx, "reference", []byte("old notes"), "")
+	storage.CreateFile(ctx, "current", []byte("new notes"), "")
+
+	req := makeRequest("PATCH", "/notes/"+old.ID, `{"archived":true}`)
+	req.PathParameters["id"] = old.ID
+	resp, _ := h.PatchNote(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	ids := func(resp events.APIGatewayProxyResponse) []string {
+		var files []adapter.FileMetadata
+		json.Unmarshal([]byte(resp.Body), &files)
+		var out []string
+		for _, f := range files {
+			out = append(out, f.ID)
+		}
+		return out
+	}
+
+	list := makeRequest("GET", "/notes", "")
+	resp, _ = h.ListNotes(ctx, list)
+	if slices.Contains(ids(resp), old.ID) {
+		t.Errorf("Expected the archived note left out of ListNotes, got %s", resp.Body)
+	}
+	list.QueryStringParameters = map[string]string{"includeArchived": "true"}
+	resp, _ = h.ListNotes(ctx, list)
+	if !slices.Contains(ids(resp), old.ID) {
+		t.Errorf("Expected the archived note with includeArchived, got %s", resp.Body)
+	}
+
+	searchReq := makeRequest("GET", "/search", "")
+	searchReq.QueryStringParameters = map[string]string{"q": "notes"}
+	resp, _ = search.Search(ctx, searchReq)
+	if got := ids(resp); len(got) != 1 || got[0] == old.ID {
+		t.Errorf("Expected only the active note in search, got %s", resp.Body)
+	}
+
+	resp, _ = h.ListArchivedNotes(ctx, makeRequest("GET", "/archive", ""))
+	if got := ids(resp); !slices.Equal(got, []string{old.ID}) {
+		t.Errorf("Expected only the archived note in /archive, got %s", resp.Body)
+	}
+}
+
+func TestNoteHandler_ListDuplicates(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	folder, _ := storage.CreateFolder(ctx, "imported", nil)
+	original, _ := storage.CreateFile(ctx, "meeting", []byte("# Minutes"), "")
+	copied, _ := storage.CreateFile(ctx, "meeting (1)", []byte("# Minutes"), folder.ID)
+	storage.CreateFile(ctx, "other", []byte("# Other"), "")
+	storage.CreateFile(ctx, "empty-1", nil, "")
+	storage.CreateFile(ctx, "empty-2", nil, "")
+
+	resp, _ := h.ListDuplicates(ctx, makeRequest("GET", "/duplicates", ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var groups []handler.DuplicateGroup
+	json.Unmarshal([]byte(resp.Body), &groups)
+	if len(groups) != 1 || len(groups[0].Notes) != 2 {
+		t.Fatalf("Expected one group of two notes, got %s", resp.Body)
+	}
+	if groups[0].Notes[0].ID != original.ID || groups[0].Notes[1].ID != copied.ID {
+		t.Errorf("Expected the original note first, got %s", resp.Body)
+	}
+}
+
 func TestNoteHandler_GetNote_NotFound(t *testing.T) {
 	provider := memory.NewProvider(nil, nil)
 	h := handler.NewNoteHandler(provider, "test-secret")
@@ -436,3 +720,44 @@ func TestNoteHandler_GetNote_NotFound(t *testing.T) {
 		t.Errorf("Expected 404, got %d: %s", resp.StatusCode, resp.Body)
 	}
 }
+
+func TestNoteHandler_UpdateNote_LockEnforcement(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	locker := session.NewMockLocker()
+	h := handler.NewNoteHandler(provider, "test-secret")
+	h.EnableLockEnforcement(locker)
+	ctx := context.Background()
+
+	createReq := makeRequest("POST", "/notes", `{"name":"locked.md","content":"v1"}`)
+	createResp, _ := h.CreateNote(ctx, createReq)
+	var created adapter.FileMetadata
+	json.Unmarshal([]byte(createResp.Body), &created)
+
+	update := func() events.APIGatewayProxyResponse {
+		req := makeRequest("PUT", "/notes/"+created.ID, `{"content":"v2"}`)
+		req.PathParameters["id"] = created.ID
+		resp, err := h.UpdateNote(ctx, req)
+		if err != nil {
+			t.Fatalf("UpdateNote returned error: %v", err)
+		}
+		return resp
+	}
+
+	// No lock: save allowed
+	if resp := update(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 without lock, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	// Lock held by caller: save allowed
+	locker.AcquireLock(ctx, created.ID, testUserID, session.HolderInfo{})
+	if resp := update(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 with own lock, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	locker.ReleaseLock(ctx, created.ID, testUserID)
+
+	// Lock held by someone else: rejected
+	locker.AcquireLock(ctx, created.ID, "other-user", session.HolderInfo{})
+	if resp := update(); resp.StatusCode != http.StatusLocked {
+		t.Fatalf("Expected 423 when locked by another user, got %d: %s", resp.StatusCode, resp.Body)
+	}
+}
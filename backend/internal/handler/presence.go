@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/session"
+)
+
+// maxPresenceFiles bounds the files one presence stream watches.
+const maxPresenceFiles = 50
+
+// PresenceStreamHandler streams lock presence events as Server-Sent Events.
+// API Gateway/Lambda cannot hold the connection open, and each Lambda
+// instance broadcasts only to its own subscribers, so this is mounted only by
+// the long-running net/http servers; clients of the Lambda API get no presence
+// events.
+type PresenceStreamHandler struct {
+	storageProvider adapter.StorageProvider
+	broadcaster     *session.Broadcaster
+	jwtSecret       string
+}
+
+func NewPresenceStreamHandler(provider adapter.StorageProvider, broadcaster *session.Broadcaster, jwtSecret string) *PresenceStreamHandler {
+	return &PresenceStreamHandler{
+		storageProvider: provider,
+		broadcaster:     broadcaster,
+		jwtSecret:       jwtSecret,
+	}
+}
+
+// ServeHTTP handles GET /sessions/events?file_ids=a,b. file_ids is
+// required, and each file must be one the caller can read, since events
+// name who is editing it.
+func (h *PresenceStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	headers := make(map[string]string)
+	multiHeaders := make(map[string][]string)
+	for k, v := range r.Header {
+		if len(v) > 0 {
+			headers[k] = v[len(v)-1]
+			multiHeaders[k] = v
+		}
+	}
+	req := events.APIGatewayProxyRequest{Headers: headers, MultiValueHeaders: multiHeaders}
+	userID, err := GetUserID(req, h.jwtSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	raw := r.URL.Query().Get("file_ids")
+	if raw == "" {
+		http.Error(w, "Missing file_ids", http.StatusBadRequest)
+		return
+	}
+	fileIDs := strings.Split(raw, ",")
+	if len(fileIDs) > maxPresenceFiles {
+		http.Error(w, fmt.Sprintf("At most %d file_ids", maxPresenceFiles), http.StatusBadRequest)
+		return
+	}
+	storage, err := h.storageProvider.GetAdapter(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	for _, id := range fileIDs {
+		_, err := storage.GetFile(r.Context(), id)
+		if errors.Is(err, adapter.ErrNotFound) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Presence GetFile failed", "error", err)
+			http.Error(w, "Failed to check file", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	ch, unsubscribe := h.broadcaster.Subscribe(fileIDs...)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-ch:
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Presence marshal failed", "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/session"
+)
+
+func TestPresenceStream_Unauthorized(t *testing.T) {
+	h := handler.NewPresenceStreamHandler(memory.NewProvider(nil, nil), session.NewBroadcaster(), testJWTSecret)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sessions/events", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", rec.Code)
+	}
+}
+
+func TestPresenceStream_RequiresReadableFiles(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewPresenceStreamHandler(provider, session.NewBroadcaster(), testJWTSecret)
+	storage, _ := provider.GetAdapter(context.Background(), "other-user")
+	foreign, _ := storage.CreateFile(context.Background(), "Theirs.md", nil, "")
+
+	for target, want := range map[string]int{
+		"/sessions/events":                        http.StatusBadRequest,
+		"/sessions/events?file_ids=missing":       http.StatusNotFound,
+		"/sessions/events?file_ids=" + foreign.ID: http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+makeToken(testUserID))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", target, want, rec.Code)
+		}
+	}
+}
+
+func TestPresenceStream_RepeatedCookieHeaders(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewPresenceStreamHandler(provider, session.NewBroadcaster(), testJWTSecret)
+	storage, _ := provider.GetAdapter(context.Background(), testUserID)
+	note, _ := storage.CreateFile(context.Background(), "Mine.md", nil, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/sessions/events?file_ids="+note.ID, nil).WithContext(ctx)
+	req.Header.Add("Cookie", "theme=dark")
+	req.Header.Add("Cookie", "session_token="+makeToken(testUserID))
+	req.Header.Add("Cookie", "lang=en")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rec.Code)
+	}
+}
+
+func TestPresenceStream_DeliversEvents(t *testing.T) {
+	b := session.NewBroadcaster()
+	locker := session.NewNotifyingLocker(session.NewMockLocker(), b)
+	provider := memory.NewProvider(nil, nil)
+	storage, _ := provider.GetAdapter(context.Background(), testUserID)
+	note, _ := storage.CreateFile(context.Background(), "Mine.md", nil, "")
+	server := httptest.NewServer(handler.NewPresenceStreamHandler(provider, b, testJWTSecret))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?file_ids="+note.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+makeToken(testUserID))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", ct)
+	}
+
+	locker.AcquireLock(context.Background(), note.ID, "other-user", session.HolderInfo{DisplayName: "Bob"})
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if strings.TrimSpace(line) != "event: acquired" {
+		t.Errorf("Expected acquired event, got %q", line)
+	}
+	line, _ = reader.ReadString('\n')
+	if !strings.Contains(line, `"display_name":"Bob"`) {
+		t.Errorf("Expected holder name in data, got %q", line)
+	}
+}
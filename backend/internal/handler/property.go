@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// propertyKeyPattern matches the custom property keys accepted on notes.
+var propertyKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// maxPropertyValue is the longest property value accepted, in bytes. Drive
+// limits each appProperty's key and value to 124 bytes together.
+const maxPropertyValue = 80
+
+// validateProperties checks the keys and values of a properties update.
+func validateProperties(props map[string]string) error {
+	if len(props) > adapter.MaxProperties {
+		return fmt.Errorf("a note can have at most %d properties", adapter.MaxProperties)
+	}
+	for k, v := range props {
+		if !propertyKeyPattern.MatchString(k) {
+			return fmt.Errorf("property key %q must be 1-32 letters, digits, '-' or '_'", k)
+		}
+		if len(v) > maxPropertyValue || !utf8.ValidString(v) {
+			return fmt.Errorf("property %q must be valid text of at most %d bytes", k, maxPropertyValue)
+		}
+	}
+	return nil
+}
+
+// parsePropertyFilter parses a ?property=key:value query parameter. An
+// empty raw value means no filter.
+func parsePropertyFilter(raw string) (key, value string, err error) {
+	if raw == "" {
+		return "", "", nil
+	}
+	key, value, ok := strings.Cut(raw, ":")
+	if !ok || !propertyKeyPattern.MatchString(key) {
+		return "", "", fmt.Errorf("property filter must be key:value")
+	}
+	return key, value, nil
+}
+
+// filterByProperty keeps the files whose property key equals value. An
+// empty key keeps every file.
+func filterByProperty(files []adapter.FileMetadata, key, value string) []adapter.FileMetadata {
+	if key == "" {
+		return files
+	}
+	return slices.DeleteFunc(files, func(f adapter.FileMetadata) bool {
+		v, ok := f.Properties[key]
+		return !ok || v != value
+	})
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/publish"
+)
+
+const (
+	// sharePurpose is the crypto.Signer purpose of share link tokens.
+	sharePurpose = "share"
+	// shareLinkTTL is how long a share link works. Owners get a fresh link
+	// each time they fetch the publication.
+	shareLinkTTL = 90 * 24 * time.Hour
+	// maxScheduleAhead is how far ahead a publication can be scheduled.
+	maxScheduleAhead = 365 * 24 * time.Hour
+)
+
+// shareLinkData returns the data a share link for snap signs: the note ID
+// and the publication's nonce, so links stop working once the note is
+// unpublished, even if it is published again.
+func shareLinkData(snap *publish.Snapshot) string {
+	if snap.Nonce == "" {
+		return snap.NoteID
+	}
+	return snap.NoteID + "#" + snap.Nonce
+}
+
+// PublishHandler handles publishing notes to public share pages. A
+// published note is a snapshot: later edits stay private until the owner
+// publishes again.
+type PublishHandler struct {
+	storageProvider adapter.StorageProvider
+	store           *publish.Store
+	signer          *crypto.Signer
+	jwtSecret       string
+}
+
+// NewPublishHandler creates a new PublishHandler.
+func NewPublishHandler(provider adapter.StorageProvider, store *publish.Store, signer *crypto.Signer, jwtSecret string) *PublishHandler {
+	return &PublishHandler{storageProvider: provider, store: store, signer: signer, jwtSecret: jwtSecret}
+}
+
+// PublicationResponse is a note's publication as its owner sees it.
+// ShareToken goes in the public page URL. Stale is set when the note has
+// been edited since it was published.
+type PublicationResponse struct {
+	publish.Snapshot
+	ShareToken string `json:"shareToken"`
+	Stale      bool   `json:"stale"`
+}
+
+// PublicNote is a published note as served on its public page. HTML is
+// Content rendered without raw HTML or dangerous URLs, safe to show to
+// anyone with the link.
+type PublicNote struct {
+	Name        string    `json:"name"`
+	Content     string    `json:"content"`
+	HTML        string    `json:"html"`
+	PublishedAt time.Time `json:"publishedAt"`
+}
+
+// note returns the caller's user ID and the note named in the path, after
+// checking the caller can read it.
+func (h *PublishHandler) note(ctx context.Context, req events.APIGatewayProxyRequest) (string, *adapter.File, *events.APIGatewayProxyResponse) {
+	fail := func(resp events.APIGatewayProxyResponse) (string, *adapter.File, *events.APIGatewayProxyResponse) {
+		return "", nil, &resp
+	}
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return fail(Error(ctx, http.StatusUnauthorized, "Unauthorized"))
+	}
+
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		return fail(Error(ctx, http.StatusBadRequest, "Missing note ID"))
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		return fail(respondError(ctx, "GetAdapter", fmt.Errorf("%w: %v", ErrUnauthorized, err)))
+	}
+	note, err := storage.GetFile(ctx, noteID)
+	if err != nil {
+		return fail(respondError(ctx, "Publish GetFile", err))
+	}
+	return userID, note, nil
+}
+
+// respond returns snap as its owner sees it, with a fresh share link.
+func (h *PublishHandler) respond(ctx context.Context, status int, snap *publish.Snapshot, note *adapter.File) events.APIGatewayProxyResponse {
+	token, err := h.signer.Sign(ctx, sharePurpose, shareLinkData(snap), shareLinkTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "Signing share link failed", "error", err)
+		return InternalError(ctx)
+	}
+
+	body, _ := json.Marshal(PublicationResponse{
+		Snapshot:   *snap,
+		ShareToken: token,
+		Stale:      snap.SourceETag != note.ETag,
+	})
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
+// GetPublication handles GET /notes/{id}/publish, returning the note's
+// publication or 404 if it is not published.
+func (h *PublishHandler) GetPublication(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, note, errResp := h.note(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	snap, err := h.store.Get(ctx, note.ID)
+	if err != nil {
+		return respondError(ctx, "GetPublication", err), nil
+	}
+	return h.respond(ctx, http.StatusOK, snap, note), nil
+}
+
+// Publish handles POST /notes/{id}/publish. It freezes the note's current
+// content for its public page, replacing any earlier snapshot. An optional
+// body {"publishAt": "<RFC 3339 time>"} schedules the snapshot instead; it
+// goes live when the scheduled release job next runs after that time.
+func (h *PublishHandler) Publish(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, note, errResp := h.note(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	var input struct {
+		PublishAt *time.Time `json:"publishAt"`
+	}
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+			return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+		}
+	}
+	if at := input.PublishAt; at != nil {
+		now := time.Now()
+		if !at.After(now) {
+			return Error(ctx, http.StatusBadRequest, "publishAt must be in the future"), nil
+		}
+		if at.After(now.Add(maxScheduleAhead)) {
+			return Error(ctx, http.StatusBadRequest, "publishAt must be within a year"), nil
+		}
+	}
+	if note.MIMEType == folderMIMEType {
+		return Error(ctx, http.StatusBadRequest, "Only notes can be published"), nil
+	}
+	if note.Encrypted {
+		return respondError(ctx, "Publish", adapter.ErrEncrypted), nil
+	}
+
+	snap, err := h.store.Put(ctx, publish.Snapshot{
+		NoteID:     note.ID,
+		OwnerID:    userID,
+		Name:       note.Name,
+		Content:    string(note.Content),
+		SourceETag: note.ETag,
+		PublishAt:  input.PublishAt,
+	})
+	if err != nil {
+		return respondError(ctx, "Publish", err), nil
+	}
+	return h.respond(ctx, http.StatusOK, snap, note), nil
+}
+
+// Unpublish handles DELETE /notes/{id}/publish. The note's share links
+// stop working, and stay revoked if it is published again.
+func (h *PublishHandler) Unpublish(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, note, errResp := h.note(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	if err := h.store.Delete(ctx, note.ID); err != nil {
+		return respondError(ctx, "Unpublish", err), nil
+	}
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, nil
+}
+
+// GetPublicNote handles GET /public/{token}, the unauthenticated read of a
+// published note through its share link.
+func (h *PublishHandler) GetPublicNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	data, err := h.signer.Verify(ctx, sharePurpose, req.PathParameters["token"])
+	if errors.Is(err, crypto.ErrInvalidToken) || errors.Is(err, crypto.ErrTokenExpired) {
+		slog.InfoContext(ctx, "Rejected share link", "error", err)
+		return Error(ctx, http.StatusNotFound, "This share link is invalid or has expired"), nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Verifying share link failed", "error", err)
+		return InternalError(ctx), nil
+	}
+
+	noteID := data
+	if i := strings.LastIndexByte(data, '#'); i >= 0 {
+		noteID = data[:i]
+	}
+	snap, err := h.store.Get(ctx, noteID)
+	if err == nil && !snap.Live() {
+		err = publish.ErrNotFound
+	}
+	if err != nil {
+		return respondError(ctx, "GetPublicNote", err), nil
+	}
+	if data != shareLinkData(snap) {
+		slog.InfoContext(ctx, "Rejected share link of an earlier publication")
+		return Error(ctx, http.StatusNotFound, "This share link is invalid or has expired"), nil
+	}
+
+	html, err := noteRenderer.RenderSanitized([]byte(snap.Content))
+	if err != nil {
+		return respondError(ctx, "RenderSanitized", err), nil
+	}
+
+	body, _ := json.Marshal(PublicNote{
+		Name:        snap.Name,
+		Content:     snap.Content,
+		HTML:        string(html),
+		PublishedAt: snap.PublishedAt,
+	})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,144 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/publish"
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+func TestPublishHandler_Workflow(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	store := publish.NewStore(nil, "")
+	h := handler.NewPublishHandler(provider, store, crypto.NewSigner(secret.Static("signing-key")), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "announcement", []byte("# v1"), "")
+
+	pub := makeRequest("POST", "/notes/"+note.ID+"/publish", "")
+	pub.PathParameters["id"] = note.ID
+	resp, _ := h.Publish(ctx, pub)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var publication handler.PublicationResponse
+	json.Unmarshal([]byte(resp.Body), &publication)
+	if publication.ShareToken == "" || publication.Stale {
+		t.Fatalf("Unexpected publication %+v", publication)
+	}
+
+	public := func() (int, handler.PublicNote) {
+		req := makeRequest("GET", "/public/"+publication.ShareToken, "")
+		req.Headers = map[string]string{}
+		req.PathParameters["token"] = publication.ShareToken
+		resp, _ := h.GetPublicNote(ctx, req)
+		var n handler.PublicNote
+		json.Unmarshal([]byte(resp.Body), &n)
+		return resp.StatusCode, n
+	}
+
+	// Edits after publishing stay private until the note is republished.
+	storage.SaveFile(ctx, note.ID, []byte("# v2"), "")
+	if status, n := public(); status != http.StatusOK || n.Content != "# v1" || n.Name != "announcement" {
+		t.Errorf("Expected the published snapshot, got %d %+v", status, n)
+	} else if n.HTML != "<h1 id=\"v1\">v1</h1>\n" {
+		t.Errorf("Expected the snapshot rendered, got %q", n.HTML)
+	}
+	get := makeRequest("GET", "/notes/"+note.ID+"/publish", "")
+	get.PathParameters["id"] = note.ID
+	resp, _ = h.GetPublication(ctx, get)
+	json.Unmarshal([]byte(resp.Body), &publication)
+	if !publication.Stale {
+		t.Errorf("Expected a stale publication after editing, got %s", resp.Body)
+	}
+
+	h.Publish(ctx, pub)
+	if _, n := public(); n.Content != "# v2" {
+		t.Errorf("Expected the republished content, got %+v", n)
+	}
+
+	unpub := makeRequest("DELETE", "/notes/"+note.ID+"/publish", "")
+	unpub.PathParameters["id"] = note.ID
+	if resp, _ := h.Unpublish(ctx, unpub); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", resp.StatusCode)
+	}
+	if status, _ := public(); status != http.StatusNotFound {
+		t.Errorf("Expected 404 after unpublishing, got %d", status)
+	}
+
+	// Publishing again does not bring back the revoked links.
+	revoked := publication.ShareToken
+	resp, _ = h.Publish(ctx, pub)
+	json.Unmarshal([]byte(resp.Body), &publication)
+	if status, _ := public(); status != http.StatusOK {
+		t.Errorf("Expected the new link to work, got %d", status)
+	}
+	publication.ShareToken = revoked
+	if status, _ := public(); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for a link revoked by unpublishing, got %d", status)
+	}
+}
+
+func TestPublishHandler_Schedule(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	store := publish.NewStore(nil, "")
+	h := handler.NewPublishHandler(provider, store, crypto.NewSigner(secret.Static("signing-key")), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "newsletter", []byte("# Issue 1"), "")
+
+	publishAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	pub := makeRequest("POST", "/notes/"+note.ID+"/publish", `{"publishAt":"`+publishAt.Format(time.RFC3339)+`"}`)
+	pub.PathParameters["id"] = note.ID
+	resp, _ := h.Publish(ctx, pub)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var publication handler.PublicationResponse
+	json.Unmarshal([]byte(resp.Body), &publication)
+	if publication.PublishAt == nil || !publication.PublishAt.Equal(publishAt) {
+		t.Fatalf("Expected a publication scheduled for %v, got %s", publishAt, resp.Body)
+	}
+
+	public := makeRequest("GET", "/public/"+publication.ShareToken, "")
+	public.PathParameters["token"] = publication.ShareToken
+	if resp, _ := h.GetPublicNote(ctx, public); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 before the scheduled time, got %d", resp.StatusCode)
+	}
+
+	store.Release(ctx, publishAt)
+	if resp, _ := h.GetPublicNote(ctx, public); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 once released, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	for _, body := range []string{
+		`{"publishAt":"` + time.Now().Add(-time.Minute).Format(time.RFC3339) + `"}`,
+		`{"publishAt":"` + time.Now().AddDate(2, 0, 0).Format(time.RFC3339) + `"}`,
+		`{"publishAt":"tomorrow"}`,
+	} {
+		req := makeRequest("POST", "/notes/"+note.ID+"/publish", body)
+		req.PathParameters["id"] = note.ID
+		if resp, _ := h.Publish(ctx, req); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestPublishHandler_RejectsBadTokens(t *testing.T) {
+	h := handler.NewPublishHandler(memory.NewProvider(nil, nil), publish.NewStore(nil, ""), crypto.NewSigner(secret.Static("signing-key")), "test-secret")
+
+	req := makeRequest("GET", "/public/forged", "")
+	req.PathParameters["token"] = "forged.token"
+	if resp, _ := h.GetPublicNote(context.Background(), req); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a forged token, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/reminder"
+)
+
+const (
+	// calendarPurpose is the crypto.Signer purpose of calendar feed tokens.
+	calendarPurpose = "calendar"
+	// calendarFeedTTL is how long a calendar feed URL works. Calendar apps
+	// keep polling the URL they were given, so it is long-lived; fetching
+	// the link again issues a fresh one.
+	calendarFeedTTL = 365 * 24 * time.Hour
+)
+
+// ReminderHandler serves the reminders collected from task due dates.
+type ReminderHandler struct {
+	store     *reminder.Store
+	signer    *crypto.Signer
+	jwtSecret string
+}
+
+// NewReminderHandler creates a new ReminderHandler.
+func NewReminderHandler(store *reminder.Store, signer *crypto.Signer, jwtSecret string) *ReminderHandler {
+	return &ReminderHandler{store: store, signer: signer, jwtSecret: jwtSecret}
+}
+
+// CalendarLink is the token for a user's calendar feed URL,
+// /tasks/calendar.ics?token=<Token>.
+type CalendarLink struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ListReminders handles GET /reminders, returning the caller's open tasks
+// with a due date across all notes, soonest first. ?dueBy=YYYY-MM-DD
+// limits them to tasks due on or before that date.
+func (h *ReminderHandler) ListReminders(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	dueBy := req.QueryStringParameters["dueBy"]
+	if dueBy != "" {
+		if _, err := time.Parse(time.DateOnly, dueBy); err != nil {
+			return Error(ctx, http.StatusBadRequest, "dueBy must be a date (YYYY-MM-DD)"), nil
+		}
+	}
+
+	reminders, err := h.store.List(ctx, userID, dueBy)
+	if err != nil {
+		return respondError(ctx, "ListReminders", err), nil
+	}
+
+	body, _ := json.Marshal(reminders)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// GetCalendarLink handles GET /tasks/calendar, issuing the token for the
+// caller's calendar feed.
+func (h *ReminderHandler) GetCalendarLink(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	token, err := h.signer.Sign(ctx, calendarPurpose, userID, calendarFeedTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "Signing calendar link failed", "error", err)
+		return InternalError(ctx), nil
+	}
+
+	body, _ := json.Marshal(CalendarLink{
+		Token:     token,
+		ExpiresAt: time.Now().Add(calendarFeedTTL).UTC().Truncate(time.Second),
+	})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// GetCalendarFeed handles GET /tasks/calendar.ics?token=..., the iCalendar
+// feed of the user's open tasks with due dates. Calendar apps cannot send
+// the session token, so the feed is authenticated by the token from
+// GetCalendarLink instead.
+func (h *ReminderHandler) GetCalendarFeed(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := h.signer.Verify(ctx, calendarPurpose, req.QueryStringParameters["token"])
+	if errors.Is(err, crypto.ErrInvalidToken) || errors.Is(err, crypto.ErrTokenExpired) {
+		slog.InfoContext(ctx, "Rejected calendar feed token", "error", err)
+		return Error(ctx, http.StatusUnauthorized, "This calendar link is invalid or has expired"), nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Verifying calendar feed token failed", "error", err)
+		return InternalError(ctx), nil
+	}
+
+	reminders, err := h.store.List(ctx, userID, "")
+	if err != nil {
+		return respondError(ctx, "GetCalendarFeed", err), nil
+	}
+
+	var feed bytes.Buffer
+	reminder.WriteCalendar(&feed, reminders, time.Now())
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       feed.String(),
+		Headers: map[string]string{
+			"Content-Type":  "text/calendar; charset=utf-8",
+			"Cache-Control": "private, max-age=300",
+		},
+	}, nil
+}
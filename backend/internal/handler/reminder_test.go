@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/reminder"
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+func TestReminderHandler_IndexesSavedNotes(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	store := reminder.NewStore(nil, "")
+	notes := handler.NewNoteHandler(provider, "test-secret")
+	notes.EnableReminders(store)
+	h := handler.NewReminderHandler(store, crypto.NewSigner(secret.Static("signing-key")), "test-secret")
+	ctx := context.Background()
+
+	list := func(query map[string]string) (int, []reminder.Reminder) {
+		req := makeRequest("GET", "/reminders", "")
+		req.QueryStringParameters = query
+		resp, _ := h.ListReminders(ctx, req)
+		var reminders []reminder.Reminder
+		json.Unmarshal([]byte(resp.Body), &reminders)
+		return resp.StatusCode, reminders
+	}
+
+	create := makeRequest("POST", "/notes", `{"name":"Bills.md","content":"- [ ] pay rent 📅 2024-06-01\n- [ ] insurance 📅 2024-06-10\n- [ ] someday\n"}`)
+	resp, _ := notes.CreateNote(ctx, create)
+	var note adapter.FileMetadata
+	json.Unmarshal([]byte(resp.Body), &note)
+
+	status, reminders := list(nil)
+	if status != http.StatusOK || len(reminders) != 2 || reminders[0].Text != "pay rent" || reminders[0].NoteName != "Bills.md" {
+		t.Fatalf("Expected both dated tasks, got %d %+v", status, reminders)
+	}
+	if _, reminders := list(map[string]string{"dueBy": "2024-06-05"}); len(reminders) != 1 {
+		t.Errorf("Expected one reminder due by 2024-06-05, got %+v", reminders)
+	}
+	if status, _ := list(map[string]string{"dueBy": "June"}); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad dueBy, got %d", status)
+	}
+
+	update := makeRequest("PUT", "/notes/"+note.ID, `{"content":"- [x] pay rent 📅 2024-06-01\n- [ ] insurance 📅 2024-06-10\n"}`)
+	update.PathParameters["id"] = note.ID
+	notes.UpdateNote(ctx, update)
+	if _, reminders := list(nil); len(reminders) != 1 || reminders[0].Text != "insurance" {
+		t.Errorf("Expected only the open task after saving, got %+v", reminders)
+	}
+
+	del := makeRequest("DELETE", "/notes/"+note.ID, "")
+	del.PathParameters["id"] = note.ID
+	notes.DeleteNote(ctx, del)
+	if _, reminders := list(nil); len(reminders) != 0 {
+		t.Errorf("Expected no reminders after deleting the note, got %+v", reminders)
+	}
+}
+
+func TestReminderHandler_CalendarFeed(t *testing.T) {
+	store := reminder.NewStore(nil, "")
+	store.Replace(context.Background(), testUserID, "a", reminder.FromNote(testUserID, "", "a", "Bills", "- [ ] pay rent 📅 2024-06-01\n- [ ] someday\n"))
+	h := handler.NewReminderHandler(store, crypto.NewSigner(secret.Static("signing-key")), "test-secret")
+	ctx := context.Background()
+
+	resp, _ := h.GetCalendarLink(ctx, makeRequest("GET", "/tasks/calendar", ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var link handler.CalendarLink
+	json.Unmarshal([]byte(resp.Body), &link)
+
+	// Calendar apps fetch the feed without a session.
+	feed := func(token string) events.APIGatewayProxyResponse {
+		req := events.APIGatewayProxyRequest{
+			HTTPMethod:            "GET",
+			Path:                  "/tasks/calendar.ics",
+			QueryStringParameters: map[string]string{"token": token},
+		}
+		resp, _ := h.GetCalendarFeed(ctx, req)
+		return resp
+	}
+
+	resp = feed(link.Token)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Headers["Content-Type"], "text/calendar") {
+		t.Fatalf("Expected a calendar, got %d %v: %s", resp.StatusCode, resp.Headers, resp.Body)
+	}
+	if !strings.Contains(resp.Body, "SUMMARY:pay rent\r\n") || strings.Contains(resp.Body, "someday") {
+		t.Errorf("Expected only the dated task in the feed, got:\n%s", resp.Body)
+	}
+
+	if resp := feed(link.Token + "x"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an altered token, got %d", resp.StatusCode)
+	}
+	if resp := feed(""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", resp.StatusCode)
+	}
+}
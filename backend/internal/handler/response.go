@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// minCompressSize is the smallest body Compress gzips; below it the gzip
+// header and base64 overhead outweigh the savings.
+const minCompressSize = 1024
+
+// Binary returns a response carrying data as is. API Gateway only passes
+// text through, so the body is base64-encoded with IsBase64Encoded set;
+// API Gateway (and WriteProxyResponse locally) decodes it. Behind the REST
+// API, contentType must be one of its binary media types and the client
+// must accept it, e.g. with Accept: application/zip.
+func Binary(status int, contentType string, data []byte) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		StatusCode:      status,
+		Headers:         map[string]string{"Content-Type": contentType},
+		Body:            base64.StdEncoding.EncodeToString(data),
+		IsBase64Encoded: true,
+	}
+}
+
+// Attachment returns data as a file download named filename, e.g. a zip
+// export or a PDF.
+func Attachment(contentType, filename string, data []byte) events.APIGatewayProxyResponse {
+	resp := Binary(http.StatusOK, contentType, data)
+	resp.Headers["Content-Disposition"] = mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	return resp
+}
+
+// Compress gzips resp's body when the request accepts gzip, the body is at
+// least minCompressSize bytes and resp is not already encoded. It sets
+// Content-Encoding and Vary; otherwise resp is returned unchanged.
+func Compress(req events.APIGatewayProxyRequest, resp events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+	if len(resp.Body) < minCompressSize || resp.Headers["Content-Encoding"] != "" || !acceptsGzip(requestHeader(req, "Accept-Encoding")) {
+		return resp
+	}
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			return resp
+		}
+		body = decoded
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return resp
+	}
+	if err := zw.Close(); err != nil {
+		return resp
+	}
+
+	headers := make(map[string]string, len(resp.Headers)+2)
+	for k, v := range resp.Headers {
+		headers[k] = v
+	}
+	headers["Content-Encoding"] = "gzip"
+	if vary := headers["Vary"]; vary == "" {
+		headers["Vary"] = "Accept-Encoding"
+	} else if !strings.Contains(strings.ToLower(vary), "accept-encoding") {
+		headers["Vary"] = vary + ",Accept-Encoding"
+	}
+	resp.Headers = headers
+	resp.Body = base64.StdEncoding.EncodeToString(buf.Bytes())
+	resp.IsBase64Encoded = true
+	return resp
+}
+
+// acceptsGzip reports whether an Accept-Encoding value allows gzip: it
+// lists gzip or * without q=0.
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// requestHeader returns the value of the named request header, matching
+// the name case-insensitively.
+func requestHeader(req events.APIGatewayProxyRequest, name string) string {
+	if v, ok := req.Headers[name]; ok {
+		return v
+	}
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
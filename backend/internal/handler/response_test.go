@@ -0,0 +1,59 @@
+package handler_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func TestAttachment(t *testing.T) {
+	resp := handler.Attachment("application/pdf", "Résumé.pdf", []byte{0x25, 0x50, 0xff})
+	if resp.StatusCode != http.StatusOK || !resp.IsBase64Encoded || resp.Headers["Content-Type"] != "application/pdf" {
+		t.Fatalf("Unexpected response: %+v", resp)
+	}
+	if got, _ := base64.StdEncoding.DecodeString(resp.Body); string(got) != "\x25\x50\xff" {
+		t.Errorf("body decodes to %q", got)
+	}
+	if cd := resp.Headers["Content-Disposition"]; cd != "attachment; filename*=utf-8''R%C3%A9sum%C3%A9.pdf" {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "application/json", "Vary": "Origin"},
+		Body:       strings.Repeat("a", 2048),
+	}
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		resp           events.APIGatewayProxyResponse
+		wantGzip       bool
+	}{
+		{"gzip accepted", "gzip", large, true},
+		{"wildcard", "br;q=1, *;q=0.5", large, true},
+		{"gzip refused", "gzip;q=0, deflate", large, false},
+		{"no header", "", large, false},
+		{"small body", "gzip", events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "{}"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{Headers: map[string]string{"accept-encoding": tt.acceptEncoding}}
+			resp := handler.Compress(req, tt.resp)
+			if got := resp.Headers["Content-Encoding"] == "gzip"; got != tt.wantGzip {
+				t.Fatalf("gzipped = %v, want %v", got, tt.wantGzip)
+			}
+			if tt.wantGzip && (!resp.IsBase64Encoded || resp.Headers["Vary"] != "Origin,Accept-Encoding") {
+				t.Errorf("base64=%v Vary=%q", resp.IsBase64Encoded, resp.Headers["Vary"])
+			}
+		})
+	}
+	if large.Headers["Content-Encoding"] != "" {
+		t.Error("Compress modified the caller's headers")
+	}
+}
@@ -28,35 +28,45 @@ func NewSearchHandler(storageProvider adapter.StorageProvider, jwtSecret string)
 // (Duplicated helper or could be shared if extracted)
 func (h *SearchHandler) getStorageAdapter(ctx context.Context, req events.APIGatewayProxyRequest) (adapter.StorageAdapter, error) {
 	// Reusing GetUserID from auth.go (assuming it's in this package)
-	userID, err := GetUserID(req, h.jwtSecret)
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
 	if err != nil {
-		return nil, fmt.Errorf("unauthorized: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
 	}
 
 	storage, err := h.storageProvider.GetAdapter(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get storage adapter: %w", err)
+		// Usually a missing or revoked Google token; a 401 makes the client
+		// sign in again.
+		return nil, fmt.Errorf("%w: get storage adapter: %v", ErrUnauthorized, err)
 	}
 
 	return storage, nil
 }
 
-// Search handles GET /search
+// Search handles GET /search. Archived notes are left out unless
+// ?includeArchived=true.
 func (h *SearchHandler) Search(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	storage, err := h.getStorageAdapter(ctx, req)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: err.Error()}, nil
+		return respondError(ctx, "GetAdapter", err), nil
 	}
 
 	query := req.QueryStringParameters["q"]
 	if query == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Query parameter 'q' is required"}, nil
+		return Error(ctx, http.StatusBadRequest, "Query parameter 'q' is required"), nil
+	}
+	propKey, propValue, err := parsePropertyFilter(req.QueryStringParameters["property"])
+	if err != nil {
+		return Error(ctx, http.StatusBadRequest, err.Error()), nil
 	}
 
 	files, err := storage.SearchFiles(ctx, query)
 	if err != nil {
-		fmt.Printf("SearchFiles error: %v\n", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to search files"}, nil
+		return respondError(ctx, "SearchFiles", err), nil
+	}
+	files = filterByProperty(files, propKey, propValue)
+	if req.QueryStringParameters["includeArchived"] != "true" {
+		files = withoutArchived(files)
 	}
 
 	if files == nil {
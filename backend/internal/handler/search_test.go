@@ -86,3 +86,26 @@ func TestSearch_Unauthorized(t *testing.T) {
 		t.Errorf("Expected 401, got %d", resp.StatusCode)
 	}
 }
+
+func TestSearch_PropertyFilter(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	searchH := handler.NewSearchHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	urgent, _ := storage.CreateFile(ctx, "plan-a", []byte("a"), "")
+	storage.CreateFile(ctx, "plan-b", []byte("b"), "")
+	storage.SetProperties(ctx, urgent.ID, map[string]string{"priority": "high"})
+
+	searchReq := makeRequest("GET", "/search", "")
+	searchReq.QueryStringParameters = map[string]string{"q": "plan", "property": "priority:high"}
+	resp, _ := searchH.Search(ctx, searchReq)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var results []adapter.FileMetadata
+	json.Unmarshal([]byte(resp.Body), &results)
+	if len(results) != 1 || results[0].ID != urgent.ID {
+		t.Errorf("Expected only the high-priority note, got %s", resp.Body)
+	}
+}
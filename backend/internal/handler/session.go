@@ -3,9 +3,13 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/metrics"
+	"github.com/jun/gophdrive/backend/internal/model"
 	"github.com/jun/gophdrive/backend/internal/session"
 )
 
@@ -13,6 +17,7 @@ import (
 type SessionHandler struct {
 	lockManager session.Locker
 	jwtSecret   string
+	advisory    bool
 }
 
 // NewSessionHandler creates a new SessionHandler.
@@ -20,67 +25,177 @@ func NewSessionHandler(lockManager session.Locker, jwtSecret string) *SessionHan
 	return &SessionHandler{lockManager: lockManager, jwtSecret: jwtSecret}
 }
 
+// EnableAdvisoryLocks makes AcquireLock report existing holders instead of
+// failing with 409. Concurrent edits are then resolved by ETag conflicts on save.
+func (h *SessionHandler) EnableAdvisoryLocks() {
+	h.advisory = true
+}
+
+// AdvisoryLockResponse is returned in advisory mode when another user already
+// holds the lock. The caller may proceed to edit; Holder tells the UI who else is editing.
+type AdvisoryLockResponse struct {
+	FileID   string                `json:"file_id"`
+	Advisory bool                  `json:"advisory"`
+	Acquired bool                  `json:"acquired"`
+	Holder   *model.EditingSession `json:"holder,omitempty"`
+}
+
+// LockResponse is returned by lock endpoints. It embeds the session and adds
+// hints the client uses to schedule heartbeats.
+type LockResponse struct {
+	model.EditingSession
+	TTLSeconds               int64 `json:"ttl_seconds"`
+	HeartbeatIntervalSeconds int64 `json:"heartbeat_interval_seconds"`
+	MaxExpiresAt             int64 `json:"max_expires_at,omitempty"`
+}
+
+// lockResponse builds the JSON response for an acquired or refreshed lock.
+func (h *SessionHandler) lockResponse(s *model.EditingSession) events.APIGatewayProxyResponse {
+	policy := h.lockManager.Policy()
+	resp := LockResponse{
+		EditingSession:           *s,
+		TTLSeconds:               int64(policy.TTL.Seconds()),
+		HeartbeatIntervalSeconds: int64(policy.HeartbeatInterval().Seconds()),
+		MaxExpiresAt:             policy.MaxExpiresAt(s.AcquiredAt),
+	}
+
+	body, _ := json.Marshal(resp)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
 // AcquireLock
+// The holder's display name is taken from the JWT claims; an optional
+// {"client_id": "..."} body identifies the tab or device holding the lock.
 func (h *SessionHandler) AcquireLock(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	userID, err := GetUserID(req, h.jwtSecret)
+	claims, err := requestUserClaims(ctx, req, h.jwtSecret)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: "Unauthorized"}, nil
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
 	}
 
 	fileID := req.PathParameters["fileId"]
 	if fileID == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing file ID"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing file ID"), nil
+	}
+
+	var input struct {
+		ClientID string `json:"client_id"`
+	}
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+			return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+		}
+	}
+
+	displayName := claims.Name
+	if displayName == "" {
+		displayName = claims.Email
 	}
 
-	session, err := h.lockManager.AcquireLock(ctx, fileID, userID)
+	holder := session.HolderInfo{
+		DisplayName: displayName,
+		ClientID:    input.ClientID,
+	}
+
+	lock, err := h.lockManager.AcquireLock(ctx, fileID, claims.UserID, holder)
 	if err != nil {
-		if err.Error() == "file is locked by another user" {
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusConflict, Body: "File is locked by another user"}, nil
+		if errors.Is(err, session.ErrLocked) {
+			metrics.Add(ctx, metrics.LockContention, 1)
+			if h.advisory {
+				return h.advisoryResponse(ctx, fileID), nil
+			}
 		}
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to acquire lock"}, nil
+		return respondError(ctx, "AcquireLock", err), nil
+	}
+
+	return h.lockResponse(lock), nil
+}
+
+// advisoryResponse reports the current holder of fileID without failing the request.
+func (h *SessionHandler) advisoryResponse(ctx context.Context, fileID string) events.APIGatewayProxyResponse {
+	holder, err := h.lockManager.GetLockStatus(ctx, fileID)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetLockStatus failed", "error", err)
 	}
 
-	body, _ := json.Marshal(session)
-	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: string(body)}, nil
+	body, _ := json.Marshal(AdvisoryLockResponse{
+		FileID:   fileID,
+		Advisory: true,
+		Acquired: false,
+		Holder:   holder,
+	})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
 }
 
 // Heartbeat
 func (h *SessionHandler) Heartbeat(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	userID, err := GetUserID(req, h.jwtSecret)
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: "Unauthorized"}, nil
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
 	}
 
 	fileID := req.PathParameters["fileId"]
 	if fileID == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing file ID"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing file ID"), nil
 	}
 
-	session, err := h.lockManager.Heartbeat(ctx, fileID, userID)
+	lock, err := h.lockManager.Heartbeat(ctx, fileID, userID)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: "Lock not found or expired"}, nil
+		return respondError(ctx, "Heartbeat", err), nil
 	}
 
-	body, _ := json.Marshal(session)
-	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: string(body)}, nil
+	return h.lockResponse(lock), nil
 }
 
 // ReleaseLock
 func (h *SessionHandler) ReleaseLock(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	userID, err := GetUserID(req, h.jwtSecret)
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: "Unauthorized"}, nil
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
 	}
 
 	fileID := req.PathParameters["fileId"]
 	if fileID == "" {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Missing file ID"}, nil
+		return Error(ctx, http.StatusBadRequest, "Missing file ID"), nil
 	}
 
 	err = h.lockManager.ReleaseLock(ctx, fileID, userID)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Failed to release lock"}, nil
+		return respondError(ctx, "ReleaseLock", err), nil
 	}
 
 	return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, nil
 }
+
+// ListMyLocks returns all active locks held by the authenticated user.
+func (h *SessionHandler) ListMyLocks(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	sessions, err := h.lockManager.ListLocks(ctx, userID)
+	if err != nil {
+		return respondError(ctx, "ListLocks", err), nil
+	}
+
+	body, _ := json.Marshal(sessions)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/jun/gophdrive/backend/internal/handler"
 	"github.com/jun/gophdrive/backend/internal/model"
 	"github.com/jun/gophdrive/backend/internal/session"
@@ -34,6 +36,103 @@ func TestSessionHandler_AcquireLock_Success(t *testing.T) {
 	}
 }
 
+func TestSessionHandler_AcquireLock_HolderMetadata(t *testing.T) {
+	locker := session.NewMockLocker()
+	h := handler.NewSessionHandler(locker, "test-secret")
+	ctx := context.Background()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   testUserID,
+		"name":  "Alice",
+		"email": "alice@example.com",
+		"exp":   time.Now().Add(1 * time.Hour).Unix(),
+	})
+	signed, _ := token.SignedString([]byte("test-secret"))
+
+	req := makeRequest("POST", "/sessions/file1/lock", `{"client_id":"tab-1"}`)
+	req.Headers["Authorization"] = "Bearer " + signed
+	req.PathParameters = map[string]string{"fileId": "file1"}
+	resp, _ := h.AcquireLock(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var s model.EditingSession
+	json.Unmarshal([]byte(resp.Body), &s)
+	if s.DisplayName != "Alice" {
+		t.Errorf("Expected display name 'Alice', got '%s'", s.DisplayName)
+	}
+	if s.ClientID != "tab-1" {
+		t.Errorf("Expected client ID 'tab-1', got '%s'", s.ClientID)
+	}
+	if s.AcquiredAt == 0 {
+		t.Error("Expected non-zero AcquiredAt")
+	}
+}
+
+func TestSessionHandler_AcquireLock_ExpiryHints(t *testing.T) {
+	locker := session.NewMockLockerWithPolicy(session.Policy{TTL: 2 * time.Minute, MaxDuration: time.Hour})
+	h := handler.NewSessionHandler(locker, "test-secret")
+	ctx := context.Background()
+
+	req := makeRequest("POST", "/sessions/file1/lock", "")
+	req.PathParameters = map[string]string{"fileId": "file1"}
+	resp, _ := h.AcquireLock(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var lock handler.LockResponse
+	json.Unmarshal([]byte(resp.Body), &lock)
+	if lock.TTLSeconds != 120 {
+		t.Errorf("Expected ttl_seconds 120, got %d", lock.TTLSeconds)
+	}
+	if lock.HeartbeatIntervalSeconds != 60 {
+		t.Errorf("Expected heartbeat_interval_seconds 60, got %d", lock.HeartbeatIntervalSeconds)
+	}
+	if lock.MaxExpiresAt != lock.AcquiredAt+3600 {
+		t.Errorf("Expected max_expires_at %d, got %d", lock.AcquiredAt+3600, lock.MaxExpiresAt)
+	}
+}
+
+func TestSessionHandler_AcquireLock_Advisory(t *testing.T) {
+	locker := session.NewMockLocker()
+	ctx := context.Background()
+	locker.AcquireLock(ctx, "file1", "other-user", session.HolderInfo{DisplayName: "Bob"})
+
+	req := makeRequest("POST", "/sessions/file1/lock", "")
+	req.PathParameters = map[string]string{"fileId": "file1"}
+
+	// Hard locks reject the second editor
+	h := handler.NewSessionHandler(locker, "test-secret")
+	resp, _ := h.AcquireLock(ctx, req)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	// Advisory locks report the holder instead
+	h.EnableAdvisoryLocks()
+	resp, _ = h.AcquireLock(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var result handler.AdvisoryLockResponse
+	json.Unmarshal([]byte(resp.Body), &result)
+	if !result.Advisory || result.Acquired {
+		t.Errorf("Expected advisory=true acquired=false, got %+v", result)
+	}
+	if result.Holder == nil || result.Holder.UserID != "other-user" || result.Holder.DisplayName != "Bob" {
+		t.Errorf("Expected holder other-user/Bob, got %+v", result.Holder)
+	}
+
+	// The existing lock is left untouched
+	lock, _ := locker.GetLockStatus(ctx, "file1")
+	if lock == nil || lock.UserID != "other-user" {
+		t.Errorf("Expected lock to remain with other-user, got %+v", lock)
+	}
+}
+
 func TestSessionHandler_AcquireLock_Unauthorized(t *testing.T) {
 	locker := session.NewMockLocker()
 	h := handler.NewSessionHandler(locker, "test-secret")
@@ -97,6 +196,32 @@ func TestSessionHandler_Heartbeat_NotFound(t *testing.T) {
 	}
 }
 
+func TestSessionHandler_NotOwner(t *testing.T) {
+	locker := session.NewMockLocker()
+	h := handler.NewSessionHandler(locker, "test-secret")
+	ctx := context.Background()
+	locker.AcquireLock(ctx, "file1", "other-user", session.HolderInfo{})
+
+	hbReq := makeRequest("POST", "/sessions/file1/heartbeat", "")
+	hbReq.PathParameters = map[string]string{"fileId": "file1"}
+	resp, _ := h.Heartbeat(ctx, hbReq)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for heartbeat, got %d", resp.StatusCode)
+	}
+
+	relReq := makeRequest("DELETE", "/sessions/file1/lock", "")
+	relReq.PathParameters = map[string]string{"fileId": "file1"}
+	resp, _ = h.ReleaseLock(ctx, relReq)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for release, got %d", resp.StatusCode)
+	}
+
+	lock, _ := locker.GetLockStatus(ctx, "file1")
+	if lock == nil {
+		t.Error("Expected lock to remain after rejected release")
+	}
+}
+
 func TestSessionHandler_ReleaseLock_Success(t *testing.T) {
 	locker := session.NewMockLocker()
 	h := handler.NewSessionHandler(locker, "test-secret")
@@ -118,3 +243,35 @@ func TestSessionHandler_ReleaseLock_Success(t *testing.T) {
 		t.Errorf("Expected 204, got %d", resp.StatusCode)
 	}
 }
+
+func TestSessionHandler_ListMyLocks(t *testing.T) {
+	locker := session.NewMockLocker()
+	h := handler.NewSessionHandler(locker, "test-secret")
+	ctx := context.Background()
+
+	for _, id := range []string{"file1", "file2"} {
+		req := makeRequest("POST", "/sessions/"+id+"/lock", "")
+		req.PathParameters = map[string]string{"fileId": id}
+		h.AcquireLock(ctx, req)
+	}
+	locker.AcquireLock(ctx, "file3", "other-user", session.HolderInfo{})
+
+	resp, err := h.ListMyLocks(ctx, makeRequest("GET", "/sessions/mine", ""))
+	if err != nil {
+		t.Fatalf("ListMyLocks returned error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var sessions []model.EditingSession
+	json.Unmarshal([]byte(resp.Body), &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 locks, got %d", len(sessions))
+	}
+	for _, s := range sessions {
+		if s.UserID != testUserID {
+			t.Errorf("Expected only locks for '%s', got '%s'", testUserID, s.UserID)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/share"
+)
+
+// maxSharesPerNote bounds the users a note or folder is shared with.
+const maxSharesPerNote = 50
+
+// isDemoUser reports whether userID is a demo account from DemoLogin.
+// Demo accounts share one email address, so they cannot share notes.
+func isDemoUser(userID string) bool {
+	return strings.HasPrefix(userID, "demo-user-")
+}
+
+// ShareHandler shares notes and folders with other users by email address.
+// NoteHandler serves the shared notes themselves (see EnableSharing).
+type ShareHandler struct {
+	storageProvider adapter.StorageProvider
+	shares          *share.Store
+	jwtSecret       string
+}
+
+// NewShareHandler creates a new ShareHandler.
+func NewShareHandler(provider adapter.StorageProvider, shares *share.Store, jwtSecret string) *ShareHandler {
+	return &ShareHandler{storageProvider: provider, shares: shares, jwtSecret: jwtSecret}
+}
+
+// authorize checks the caller owns the note or folder before touching its
+// shares, and returns the caller's claims, their storage and the note.
+func (h *ShareHandler) authorize(ctx context.Context, req events.APIGatewayProxyRequest) (*UserClaims, adapter.StorageAdapter, *adapter.File, *events.APIGatewayProxyResponse) {
+	fail := func(resp events.APIGatewayProxyResponse) (*UserClaims, adapter.StorageAdapter, *adapter.File, *events.APIGatewayProxyResponse) {
+		return nil, nil, nil, &resp
+	}
+	claims, err := requestUserClaims(ctx, req, h.jwtSecret)
+	if err != nil {
+		return fail(Error(ctx, http.StatusUnauthorized, "Unauthorized"))
+	}
+
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		return fail(Error(ctx, http.StatusBadRequest, "Missing note ID"))
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, claims.UserID)
+	if err != nil {
+		return fail(respondError(ctx, "GetAdapter", fmt.Errorf("%w: %v", ErrUnauthorized, err)))
+	}
+	note, err := storage.GetFile(ctx, noteID)
+	if err != nil {
+		return fail(respondError(ctx, "Shares GetFile", err))
+	}
+	return claims, storage, note, nil
+}
+
+// inBaseFolder reports whether the note or folder f is inside the base
+// folder of storage, the only part of the owner's storage GophDrive serves.
+// A Google Drive file ID can name any file the app can reach, so without
+// this a share could hand out files the owner never put in GophDrive.
+func inBaseFolder(ctx context.Context, storage adapter.StorageAdapter, f *adapter.File) (bool, error) {
+	top, err := storage.ListFiles(ctx, "")
+	if err != nil {
+		return false, err
+	}
+	inBase := make(map[string]bool, len(top))
+	for _, t := range top {
+		inBase[t.ID] = true
+	}
+	for range share.MaxDepth {
+		if inBase[f.ID] {
+			return true, nil
+		}
+		if len(f.Parents) == 0 || f.Parents[0] == "" {
+			return false, nil
+		}
+		f, err = storage.GetFile(ctx, f.Parents[0])
+		if errors.Is(err, adapter.ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// ListShares handles GET /notes/{id}/shares, listing who the caller shared
+// the note or folder with.
+func (h *ShareHandler) ListShares(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, _, note, errResp := h.authorize(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	shares, err := h.shares.ListForNote(ctx, note.ID)
+	if err != nil {
+		return respondError(ctx, "ListShares", err), nil
+	}
+	owned := []share.Share{}
+	for _, sh := range shares {
+		if sh.OwnerID == claims.UserID {
+			owned = append(owned, sh)
+		}
+	}
+
+	body, _ := json.Marshal(owned)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// PutShare handles PUT /notes/{id}/shares with {email, permission},
+// sharing the note or folder with the user who signs in with email, or
+// changing their permission. Permission is "read" or "write"; write lets
+// them save the note, or the notes in the folder, but not rename, move or
+// delete them. Only notes and folders inside the owner's base folder can be
+// shared. Sharing a folder again indexes the notes added to it outside
+// GophDrive.
+func (h *ShareHandler) PutShare(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, storage, note, errResp := h.authorize(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+	if isDemoUser(claims.UserID) {
+		return Error(ctx, http.StatusForbidden, "Demo accounts cannot share notes"), nil
+	}
+
+	var input struct {
+		Email      string `json:"email"`
+		Permission string `json:"permission"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	email, err := share.NormalizeEmail(input.Email)
+	if err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid email: "+err.Error()), nil
+	}
+	if strings.EqualFold(email, claims.Email) {
+		return Error(ctx, http.StatusBadRequest, "You cannot share a note with yourself"), nil
+	}
+	if input.Permission != share.PermissionRead && input.Permission != share.PermissionWrite {
+		return Error(ctx, http.StatusBadRequest, `Permission must be "read" or "write"`), nil
+	}
+
+	existing, err := h.shares.ListForNote(ctx, note.ID)
+	if err != nil {
+		return respondError(ctx, "ListShares", err), nil
+	}
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	replaced := false
+	for _, sh := range existing {
+		if sh.Email == email {
+			createdAt = sh.CreatedAt
+			replaced = true
+		}
+	}
+	if !replaced && len(existing) >= maxSharesPerNote {
+		return ErrorResponse{Code: CodeLimitExceeded, Message: fmt.Sprintf("A note can be shared with at most %d users", maxSharesPerNote)}.Response(ctx, http.StatusUnprocessableEntity), nil
+	}
+	inBase, err := inBaseFolder(ctx, storage, note)
+	if err == nil && !inBase {
+		err = adapter.ErrNotFound
+	}
+	if err != nil {
+		return respondError(ctx, "Shares GetFile", err), nil
+	}
+
+	sh := share.Share{
+		NoteID:     note.ID,
+		Email:      email,
+		OwnerID:    claims.UserID,
+		OwnerName:  claims.Name,
+		OwnerEmail: claims.Email,
+		Name:       note.Name,
+		Folder:     note.MIMEType == folderMIMEType,
+		Permission: input.Permission,
+		CreatedAt:  createdAt,
+	}
+	// The folder's items are indexed first, so a failure leaves no share
+	// that covers only part of them.
+	if sh.Folder {
+		if err := h.shares.IndexFolder(ctx, storage, sh); err != nil {
+			return respondError(ctx, "IndexFolder", err), nil
+		}
+	}
+	if err := h.shares.Put(ctx, sh); err != nil {
+		return respondError(ctx, "PutShare", err), nil
+	}
+
+	body, _ := json.Marshal(sh)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// DeleteShare handles DELETE /notes/{id}/shares/{email}. The owner can
+// stop sharing with anyone, and the user it is shared with can remove
+// themselves.
+func (h *ShareHandler) DeleteShare(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, err := requestUserClaims(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+	noteID := req.PathParameters["id"]
+	raw, err := url.PathUnescape(req.PathParameters["email"])
+	if noteID == "" || err != nil {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID or email"), nil
+	}
+	email, err := share.NormalizeEmail(raw)
+	if err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid email: "+err.Error()), nil
+	}
+
+	sh, err := h.shares.Get(ctx, noteID, email)
+	if err != nil {
+		return respondError(ctx, "GetShare", err), nil
+	}
+	self := !isDemoUser(claims.UserID) && strings.EqualFold(email, claims.Email)
+	if sh.OwnerID != claims.UserID && !self {
+		// Do not reveal who else a note is shared with.
+		return respondError(ctx, "DeleteShare", share.ErrNotFound), nil
+	}
+	if err := h.shares.Delete(ctx, noteID, email); err != nil {
+		return respondError(ctx, "DeleteShare", err), nil
+	}
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, nil
+}
+
+// ListSharedWithMe handles GET /shared-with-me, listing the notes and
+// folders other users shared with the caller, newest first. Open a note
+// with GET /notes/{id} and a folder with GET /shared-with-me/{id}/notes.
+func (h *ShareHandler) ListSharedWithMe(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, err := requestUserClaims(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	shares := []share.Share{}
+	if email, err := share.NormalizeEmail(claims.Email); err == nil && !isDemoUser(claims.UserID) {
+		all, err := h.shares.ListForEmail(ctx, email)
+		if err != nil {
+			return respondError(ctx, "ListSharedWithMe", err), nil
+		}
+		for _, sh := range all {
+			if sh.OwnerID != claims.UserID {
+				shares = append(shares, sh)
+			}
+		}
+	}
+
+	body, _ := json.Marshal(shares)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,142 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/reminder"
+	"github.com/jun/gophdrive/backend/internal/share"
+)
+
+func TestSharing(t *testing.T) {
+	shares := share.NewStore(nil, "")
+	provider := share.NewProvider(memory.NewProvider(nil, nil), shares)
+	reminders := reminder.NewStore(nil, "")
+	notes := handler.NewNoteHandler(provider, "test-secret")
+	notes.EnableSharing(shares)
+	notes.EnableReminders(reminders)
+	h := handler.NewShareHandler(provider, shares, "test-secret")
+
+	alice := handler.WithUserClaims(context.Background(), &handler.UserClaims{UserID: testUserID, Email: "alice@example.com", Name: "Alice"})
+	bob := handler.WithUserClaims(context.Background(), &handler.UserClaims{UserID: "bob-456", Email: "Bob@Example.com"})
+
+	storage, _ := provider.GetAdapter(alice, testUserID)
+	folder, _ := storage.CreateFolder(alice, "Team", nil)
+	note, _ := storage.CreateFile(alice, "Plan.md", []byte("# Plan"), folder.ID)
+	private, _ := storage.CreateFile(alice, "Diary.md", []byte("private"), "")
+	stray, _ := storage.CreateFile(alice, "Stray.md", []byte("outside"), "not-in-gophdrive")
+
+	get := func(ctx context.Context, id string) int {
+		req := makeRequest("GET", "/notes/"+id, "")
+		req.PathParameters["id"] = id
+		resp, _ := notes.GetNote(ctx, req)
+		return resp.StatusCode
+	}
+	save := func(ctx context.Context, id, content string) int {
+		req := makeRequest("PUT", "/notes/"+id, `{"content":"`+content+`"}`)
+		req.PathParameters["id"] = id
+		resp, _ := notes.UpdateNote(ctx, req)
+		return resp.StatusCode
+	}
+	put := func(ctx context.Context, id, body string) (int, string) {
+		req := makeRequest("PUT", "/notes/"+id+"/shares", body)
+		req.PathParameters["id"] = id
+		resp, _ := h.PutShare(ctx, req)
+		return resp.StatusCode, resp.Body
+	}
+
+	if status := get(bob, note.ID); status != http.StatusNotFound {
+		t.Fatalf("GET before sharing = %d", status)
+	}
+	// Only the owner can share.
+	if status, _ := put(bob, note.ID, `{"email":"bob@example.com","permission":"read"}`); status != http.StatusNotFound {
+		t.Errorf("PUT by another user = %d", status)
+	}
+	if status, _ := put(alice, folder.ID, `{"email":"bob@example.com","permission":"admin"}`); status != http.StatusBadRequest {
+		t.Errorf("PUT with a bad permission = %d", status)
+	}
+	if status, _ := put(alice, folder.ID, `{"email":"alice@example.com","permission":"read"}`); status != http.StatusBadRequest {
+		t.Errorf("PUT sharing with oneself = %d", status)
+	}
+	// Only what is inside the owner's base folder can be shared.
+	if status, _ := put(alice, stray.ID, `{"email":"bob@example.com","permission":"read"}`); status != http.StatusNotFound {
+		t.Errorf("PUT outside the base folder = %d", status)
+	}
+	if status, body := put(alice, folder.ID, `{"email":"BOB@example.com","permission":"read"}`); status != http.StatusOK {
+		t.Fatalf("PUT = %d %s", status, body)
+	}
+
+	// A folder share covers the notes in it, read-only.
+	if status := get(bob, note.ID); status != http.StatusOK {
+		t.Errorf("GET of a note in a shared folder = %d", status)
+	}
+	if status := get(bob, private.ID); status != http.StatusNotFound {
+		t.Errorf("GET of an unshared note = %d", status)
+	}
+	if status := save(bob, note.ID, "hacked"); status != http.StatusForbidden {
+		t.Errorf("PUT with read permission = %d", status)
+	}
+	// Notes created in the folder after sharing it are shared too.
+	added, _ := storage.CreateFile(alice, "Later.md", []byte("# Later"), folder.ID)
+	if status := get(bob, added.ID); status != http.StatusOK {
+		t.Errorf("GET of a note added to a shared folder = %d", status)
+	}
+
+	// A shared folder is listed apart from Bob's own folders.
+	req := makeRequest("GET", "/notes", "")
+	req.QueryStringParameters = map[string]string{"folderId": folder.ID}
+	resp, _ := notes.ListNotes(bob, req)
+	var listed []map[string]any
+	json.Unmarshal([]byte(resp.Body), &listed)
+	if resp.StatusCode != http.StatusOK || len(listed) != 0 {
+		t.Errorf("listing a shared folder as one's own = %d %s", resp.StatusCode, resp.Body)
+	}
+	req = makeRequest("GET", "/shared-with-me/"+folder.ID+"/notes", "")
+	req.PathParameters["id"] = folder.ID
+	resp, _ = notes.ListSharedFolder(bob, req)
+	json.Unmarshal([]byte(resp.Body), &listed)
+	if resp.StatusCode != http.StatusOK || len(listed) != 2 {
+		t.Errorf("listing a shared folder = %d %s", resp.StatusCode, resp.Body)
+	}
+	req.PathParameters["id"] = "root"
+	if resp, _ := notes.ListSharedFolder(bob, req); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("listing an unshared folder = %d", resp.StatusCode)
+	}
+
+	// Write permission lets Bob save, and Alice's reminders follow.
+	put(alice, note.ID, `{"email":"bob@example.com","permission":"write"}`)
+	if status := save(bob, note.ID, "- [ ] ship 📅 2030-01-01"); status != http.StatusOK {
+		t.Errorf("PUT with write permission = %d", status)
+	}
+	if f, _ := storage.GetFile(alice, note.ID); string(f.Content) != "- [ ] ship 📅 2030-01-01" {
+		t.Errorf("content = %q", f.Content)
+	}
+	if rs, _ := reminders.List(alice, testUserID, ""); len(rs) != 1 {
+		t.Errorf("owner's reminders = %+v", rs)
+	}
+
+	resp, _ = h.ListSharedWithMe(bob, makeRequest("GET", "/shared-with-me", ""))
+	var shared []share.Share
+	json.Unmarshal([]byte(resp.Body), &shared)
+	if len(shared) != 2 || shared[0].OwnerName != "Alice" || shared[0].Email != "bob@example.com" {
+		t.Errorf("shared with Bob = %s", resp.Body)
+	}
+
+	// Bob can leave a share; then the note is out of reach again.
+	req = makeRequest("DELETE", "/notes/"+note.ID+"/shares/bob@example.com", "")
+	req.PathParameters = map[string]string{"id": note.ID, "email": "bob%40example.com"}
+	if resp, _ := h.DeleteShare(bob, req); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE by the grantee = %d %s", resp.StatusCode, resp.Body)
+	}
+	req.PathParameters = map[string]string{"id": folder.ID, "email": "bob@example.com"}
+	if resp, _ := h.DeleteShare(alice, req); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE by the owner = %d", resp.StatusCode)
+	}
+	if status := get(bob, note.ID); status != http.StatusNotFound {
+		t.Errorf("GET after unsharing = %d", status)
+	}
+}
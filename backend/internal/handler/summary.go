@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/summary"
+)
+
+// SummaryHandler summarizes notes and suggests their titles and tags with a
+// language model. It is only routed when a model is configured.
+type SummaryHandler struct {
+	storageProvider adapter.StorageProvider
+	assistant       *summary.Assistant
+	jwtSecret       string
+}
+
+// NewSummaryHandler creates a new SummaryHandler.
+func NewSummaryHandler(provider adapter.StorageProvider, assistant *summary.Assistant, jwtSecret string) *SummaryHandler {
+	return &SummaryHandler{storageProvider: provider, assistant: assistant, jwtSecret: jwtSecret}
+}
+
+// NoteSummary is the response of SummarizeNote. ETag is the version of the
+// note that was summarized; Truncated is set when only the start of a long
+// note was read.
+type NoteSummary struct {
+	Summary   string `json:"summary"`
+	ETag      string `json:"etag"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// NoteSuggestion is the response of SuggestNote: a proposed title and tags,
+// for the version of the note in ETag.
+type NoteSuggestion struct {
+	summary.Suggestion
+	ETag string `json:"etag"`
+}
+
+// note returns the note named in the path, after checking the caller can
+// read it and its content is not encrypted end to end.
+func (h *SummaryHandler) note(ctx context.Context, req events.APIGatewayProxyRequest) (*adapter.File, *events.APIGatewayProxyResponse) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		resp := Error(ctx, http.StatusUnauthorized, "Unauthorized")
+		return nil, &resp
+	}
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		resp := Error(ctx, http.StatusBadRequest, "Missing note ID")
+		return nil, &resp
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		resp := respondError(ctx, "GetAdapter", fmt.Errorf("%w: %v", ErrUnauthorized, err))
+		return nil, &resp
+	}
+	note, err := storage.GetFile(ctx, noteID)
+	if err == nil && note.Encrypted {
+		err = adapter.ErrEncrypted
+	}
+	if err != nil {
+		resp := respondError(ctx, "Summary GetFile", err)
+		return nil, &resp
+	}
+	return note, nil
+}
+
+// SummarizeNote handles POST /notes/{id}/summarize, returning a short
+// summary of the note. Nothing is stored; the client decides what to do with
+// the summary. End-to-end encrypted notes cannot be summarized.
+func (h *SummaryHandler) SummarizeNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	note, errResp := h.note(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	content := string(note.Content)
+	_, truncated := summary.Truncate(content, summary.MaxInputBytes)
+	text, err := h.assistant.Summarize(ctx, strings.TrimSuffix(note.Name, ".md"), content)
+	if err != nil {
+		return respondError(ctx, "Summarize", err), nil
+	}
+
+	body, _ := json.Marshal(NoteSummary{Summary: text, ETag: note.ETag, Truncated: truncated})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// SuggestNote handles POST /notes/{id}/suggest, returning a proposed title
+// and tags for the note. Like a summary it is only a proposal: the client
+// applies what the user accepts by renaming the note with PATCH
+// /notes/{id} and setting its tags with PATCH /notes/{id}/frontmatter.
+func (h *SummaryHandler) SuggestNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	note, errResp := h.note(ctx, req)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	suggestion, err := h.assistant.Suggest(ctx, strings.TrimSuffix(note.Name, ".md"), string(note.Content))
+	if err != nil {
+		return respondError(ctx, "Suggest", err), nil
+	}
+
+	body, _ := json.Marshal(NoteSuggestion{Suggestion: *suggestion, ETag: note.ETag})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,97 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/summary"
+)
+
+// fakeProvider replies with reply and records the last prompt.
+type fakeProvider struct {
+	prompt, reply string
+}
+
+func (f *fakeProvider) Complete(ctx context.Context, system, prompt string, maxTokens int32) (string, error) {
+	f.prompt = prompt
+	return f.reply, nil
+}
+
+func TestSummaryHandler_SummarizeNote(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	model := &fakeProvider{reply: "A short summary."}
+	h := handler.NewSummaryHandler(provider, summary.NewAssistant(model), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "standup.md", []byte("# Standup\n\n- ship Friday\n"), "")
+
+	req := makeRequest("POST", "/notes/"+note.ID+"/summarize", "")
+	req.PathParameters["id"] = note.ID
+	resp, _ := h.SummarizeNote(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var got handler.NoteSummary
+	json.Unmarshal([]byte(resp.Body), &got)
+	if got.Summary != "A short summary." || got.ETag != note.ETag || got.Truncated {
+		t.Errorf("Unexpected summary %+v", got)
+	}
+	if !strings.Contains(model.prompt, `"standup"`) || !strings.HasSuffix(model.prompt, "- ship Friday\n") {
+		t.Errorf("Unexpected prompt %q", model.prompt)
+	}
+
+	empty, _ := storage.CreateFile(ctx, "empty.md", nil, "")
+	req.PathParameters["id"] = empty.ID
+	if resp, _ := h.SummarizeNote(ctx, req); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for an empty note, got %d", resp.StatusCode)
+	}
+
+	sealed, _ := storage.CreateFile(ctx, "sealed.md", []byte(adapter.EncryptedPrefix+"v1:c2FsdA==:abc"), "")
+	req.PathParameters["id"] = sealed.ID
+	resp, _ = h.SummarizeNote(ctx, req)
+	var body handler.ErrorResponse
+	json.Unmarshal([]byte(resp.Body), &body)
+	if resp.StatusCode != http.StatusUnprocessableEntity || body.Code != handler.CodeNoteEncrypted {
+		t.Errorf("Expected 422 %s for an encrypted note, got %d %s", handler.CodeNoteEncrypted, resp.StatusCode, body.Code)
+	}
+
+	req.PathParameters["id"] = "missing"
+	if resp, _ := h.SummarizeNote(ctx, req); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing note, got %d", resp.StatusCode)
+	}
+}
+
+func TestSummaryHandler_SuggestNote(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	model := &fakeProvider{reply: `{"title": "Release plan", "tags": ["Releases", "planning"]}`}
+	h := handler.NewSummaryHandler(provider, summary.NewAssistant(model), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "untitled.md", []byte("We ship 2.0 on Friday.\n"), "")
+
+	req := makeRequest("POST", "/notes/"+note.ID+"/suggest", "")
+	req.PathParameters["id"] = note.ID
+	resp, _ := h.SuggestNote(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var got handler.NoteSuggestion
+	json.Unmarshal([]byte(resp.Body), &got)
+	if got.Title != "Release plan" || !slices.Equal(got.Tags, []string{"releases", "planning"}) || got.ETag != note.ETag {
+		t.Errorf("Unexpected suggestion %+v", got)
+	}
+
+	model.reply = "Sorry, no."
+	if resp, _ := h.SuggestNote(ctx, req); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected 500 for an unusable reply, got %d", resp.StatusCode)
+	}
+}
@@ -3,69 +3,163 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/journal"
 )
 
+const folderMIMEType = "application/vnd.google-apps.folder"
+
 // SyncHandler handles synchronization and conflict detection.
 type SyncHandler struct {
-	jwtSecret string
+	storageProvider adapter.StorageProvider
+	journal         *journal.Store
+	jwtSecret       string
 }
 
 // NewSyncHandler creates a new SyncHandler.
-func NewSyncHandler(jwtSecret string) *SyncHandler {
-	return &SyncHandler{jwtSecret: jwtSecret}
+func NewSyncHandler(provider adapter.StorageProvider, journal *journal.Store, jwtSecret string) *SyncHandler {
+	return &SyncHandler{storageProvider: provider, journal: journal, jwtSecret: jwtSecret}
+}
+
+// getStorageAdapter creates a new storage adapter for the authenticated user.
+func (h *SyncHandler) getStorageAdapter(ctx context.Context, req events.APIGatewayProxyRequest) (adapter.StorageAdapter, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		// Usually a missing or revoked Google token; a 401 makes the client
+		// sign in again.
+		return nil, fmt.Errorf("%w: get storage adapter: %v", ErrUnauthorized, err)
+	}
+
+	return storage, nil
 }
 
 // CheckConflictRequest represents the request body for conflict checking.
+// Clients either send NoteID and ETag to check against live storage, or the
+// legacy LocalETag/RemoteETag pair for a stateless comparison.
 type CheckConflictRequest struct {
+	NoteID     string `json:"note_id,omitempty"`
+	ETag       string `json:"etag,omitempty"`
 	LocalETag  string `json:"local_etag"`
 	RemoteETag string `json:"remote_etag"`
 }
 
 // CheckConflictResponse represents the response body.
+// RemoteETag and ModifiedTime are only set for storage-backed checks.
 type CheckConflictResponse struct {
-	HasConflict bool `json:"has_conflict"`
+	HasConflict  bool       `json:"has_conflict"`
+	RemoteETag   string     `json:"remote_etag,omitempty"`
+	ModifiedTime *time.Time `json:"modified_time,omitempty"`
 }
 
 // CheckConflict determines if there is a conflict between local and remote versions.
 func (h *SyncHandler) CheckConflict(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	_, err := GetUserID(req, h.jwtSecret)
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusUnauthorized, Body: "Unauthorized"}, nil
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
 	}
 
 	var input CheckConflictRequest
 	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+
+	var resp CheckConflictResponse
+	if input.NoteID != "" {
+		storage, err := h.storageProvider.GetAdapter(ctx, userID)
+		if err != nil {
+			return respondError(ctx, "GetAdapter", fmt.Errorf("%w: %v", ErrUnauthorized, err)), nil
+		}
+		file, err := storage.GetFile(ctx, input.NoteID)
+		if err != nil {
+			return respondError(ctx, "CheckConflict GetFile", err), nil
+		}
+		modified := file.ModifiedTime
+		resp = CheckConflictResponse{
+			HasConflict:  input.ETag != file.ETag,
+			RemoteETag:   file.ETag,
+			ModifiedTime: &modified,
+		}
+	} else {
+		// Stateless comparison of two client-provided ETags.
+		resp.HasConflict = input.LocalETag != input.RemoteETag
 	}
 
-	// Simple conflict detection logic:
-	// If localETag != remoteETag, it's a conflict?
-	// Usually:
-	// If base (last known) != remote, then remote has changed.
-	// If local has changes (dirty), and remote has changed, then conflict.
-	// Here the client sends what it has.
-	// Actually, client should check if its 'base' etag matches current remote etag.
-	// This endpoint might be redundant if we use conditional updates (If-Match) in UpdateNote.
-	// But the plan included it.
+	body, _ := json.Marshal(resp)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// ReconcileRequest is the client's snapshot of note IDs to ETags.
+type ReconcileRequest struct {
+	Notes map[string]string `json:"notes"`
+}
 
-	// Implementation:
-	// Conflict if LocalETag != RemoteETag
-	// (Assuming LocalETag is the version the client has, and RemoteETag is what server has?
-	// No, client passes both? That seems like client-side logic.
-	// Maybe endpoint accepts specific note ID and client's ETag, and server checks against DB.)
+// ReconcileResponse lists what differs between the client snapshot and storage.
+type ReconcileResponse struct {
+	Changed []adapter.FileMetadata `json:"changed"`
+	Deleted []string               `json:"deleted"`
+	New     []adapter.FileMetadata `json:"new"`
+}
 
-	// Re-reading plan: `POST /sync/check` -> `syncHandler` -> `CheckConflict(localEtag, remoteEtag) bool`
-	// It seems to be a stateless check logic exposed as API?
-	// Or maybe checking against server state?
-	// If input is CheckConflictRequest with 2 tags, it's stateless.
+// Reconcile handles POST /sync/reconcile.
+// It compares the client's {noteID: etag} snapshot with every note under the
+// base folder so a client resuming from offline use needs only one request.
+func (h *SyncHandler) Reconcile(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
 
-	hasConflict := input.LocalETag != input.RemoteETag
+	var input ReconcileRequest
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
 
-	resp := CheckConflictResponse{
-		HasConflict: hasConflict,
+	notes, err := listAllNotes(ctx, storage)
+	if err != nil {
+		slog.ErrorContext(ctx, "Reconcile list failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to list notes"), nil
+	}
+
+	resp := ReconcileResponse{
+		Changed: []adapter.FileMetadata{},
+		Deleted: []string{},
+		New:     []adapter.FileMetadata{},
+	}
+	seen := make(map[string]bool, len(notes))
+	for _, note := range notes {
+		seen[note.ID] = true
+		etag, known := input.Notes[note.ID]
+		switch {
+		case !known:
+			resp.New = append(resp.New, note)
+		case etag != note.ETag:
+			resp.Changed = append(resp.Changed, note)
+		}
+	}
+	for id := range input.Notes {
+		if !seen[id] {
+			resp.Deleted = append(resp.Deleted, id)
+		}
 	}
 
 	body, _ := json.Marshal(resp)
@@ -77,3 +171,178 @@ func (h *SyncHandler) CheckConflict(ctx context.Context, req events.APIGatewayPr
 		},
 	}, nil
 }
+
+// ManifestEntry is a compact description of one note.
+type ManifestEntry struct {
+	ID       string `json:"id"`
+	ETag     string `json:"etag"`
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified"` // Unix seconds
+}
+
+// Manifest handles GET /sync/manifest.
+// It lists every note under the base folder so offline-first clients can plan
+// downloads and detect deletions. The response is gzip-compressed when the
+// client sends Accept-Encoding: gzip.
+func (h *SyncHandler) Manifest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	notes, err := listAllNotes(ctx, storage)
+	if err != nil {
+		slog.ErrorContext(ctx, "Manifest list failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to list notes"), nil
+	}
+
+	entries := make([]ManifestEntry, 0, len(notes))
+	for _, n := range notes {
+		entries = append(entries, ManifestEntry{
+			ID:       n.ID,
+			ETag:     n.ETag,
+			Size:     n.Size,
+			Modified: n.ModifiedTime.Unix(),
+		})
+	}
+
+	body, _ := json.Marshal(entries)
+	return Compress(req, events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}), nil
+}
+
+// listAllNotes walks the base folder tree and returns every note (not folders).
+func listAllNotes(ctx context.Context, storage adapter.StorageAdapter) ([]adapter.FileMetadata, error) {
+	var notes []adapter.FileMetadata
+	visited := make(map[string]bool)
+	queue := []string{""} // "" is the user's base folder
+
+	for len(queue) > 0 {
+		folderID := queue[0]
+		queue = queue[1:]
+		if visited[folderID] {
+			continue
+		}
+		visited[folderID] = true
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		files, err := storage.ListFiles(ctx, folderID)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.MIMEType == folderMIMEType {
+				queue = append(queue, f.ID)
+				continue
+			}
+			notes = append(notes, f)
+		}
+	}
+	return notes, nil
+}
+
+// maxNoteFetches bounds the notes fetchNotes downloads at once.
+const maxNoteFetches = 8
+
+// fetchNotes downloads notes, at most maxNoteFetches at a time, and calls
+// visit with each one; visit must be safe for concurrent use. Notes deleted
+// since they were listed are skipped.
+func fetchNotes(ctx context.Context, storage adapter.StorageAdapter, notes []adapter.FileMetadata, visit func(adapter.FileMetadata, *adapter.File)) error {
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, maxNoteFetches)
+	)
+	for _, n := range notes {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			file, err := storage.GetFile(ctx, n.ID)
+			if err == nil {
+				// Stop downloading once the request's deadline has passed.
+				err = ctx.Err()
+			}
+			switch {
+			case errors.Is(err, adapter.ErrNotFound):
+			case err != nil:
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			default:
+				visit(n, file)
+			}
+		})
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ChangesResponse lists changes since the requested cursor.
+// Cursor is the value to pass as ?since= on the next poll. It trails the
+// current time by journal.CursorLag, so a change can be listed again on the
+// next poll.
+type ChangesResponse struct {
+	Changes []journal.Change `json:"changes"`
+	Cursor  int64            `json:"cursor"`
+}
+
+// Changes handles GET /sync/changes?since=<cursor|RFC3339 timestamp>. A
+// since older than journal.Retention gets a 410, since the changes after it
+// may have expired; the client lists its notes again and polls from the
+// returned cursor.
+func (h *SyncHandler) Changes(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+
+	var since int64
+	if raw := req.QueryStringParameters["since"]; raw != "" {
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			since = n
+		} else if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			since = t.UnixNano()
+		} else {
+			return Error(ctx, http.StatusBadRequest, "Invalid since parameter"), nil
+		}
+	}
+
+	now := time.Now()
+	cursor := max(since, now.Add(-journal.CursorLag).UnixNano())
+	if since != 0 && since < now.Add(-journal.Retention).UnixNano() {
+		return ErrorResponse{
+			Code:    CodeCursorExpired,
+			Message: "Changes this old are no longer kept; list the notes again and poll from the returned cursor",
+			Details: map[string]int64{"cursor": cursor},
+		}.Response(ctx, http.StatusGone), nil
+	}
+
+	changes, err := h.journal.Since(ctx, userID, since)
+	if err != nil {
+		slog.ErrorContext(ctx, "Journal Since failed", "error", err)
+		return Error(ctx, http.StatusInternalServerError, "Failed to list changes"), nil
+	}
+
+	resp := ChangesResponse{Changes: changes, Cursor: cursor}
+
+	body, _ := json.Marshal(resp)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
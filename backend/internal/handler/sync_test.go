@@ -1,17 +1,24 @@
 package handler_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
 	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/journal"
 )
 
 func TestCheckConflict_Match(t *testing.T) {
-	h := handler.NewSyncHandler("test-secret")
+	h := handler.NewSyncHandler(memory.NewProvider(nil, nil), journal.NewStore(nil, ""), "test-secret")
 	ctx := context.Background()
 
 	req := makeRequest("POST", "/sync/check", `{"local_etag":"abc","remote_etag":"abc"}`)
@@ -33,7 +40,7 @@ func TestCheckConflict_Match(t *testing.T) {
 }
 
 func TestCheckConflict_Mismatch(t *testing.T) {
-	h := handler.NewSyncHandler("test-secret")
+	h := handler.NewSyncHandler(memory.NewProvider(nil, nil), journal.NewStore(nil, ""), "test-secret")
 	ctx := context.Background()
 
 	req := makeRequest("POST", "/sync/check", `{"local_etag":"abc","remote_etag":"xyz"}`)
@@ -49,7 +56,7 @@ func TestCheckConflict_Mismatch(t *testing.T) {
 }
 
 func TestCheckConflict_Unauthorized(t *testing.T) {
-	h := handler.NewSyncHandler("test-secret")
+	h := handler.NewSyncHandler(memory.NewProvider(nil, nil), journal.NewStore(nil, ""), "test-secret")
 	ctx := context.Background()
 
 	req := events.APIGatewayProxyRequest{
@@ -63,7 +70,7 @@ func TestCheckConflict_Unauthorized(t *testing.T) {
 }
 
 func TestCheckConflict_InvalidBody(t *testing.T) {
-	h := handler.NewSyncHandler("test-secret")
+	h := handler.NewSyncHandler(memory.NewProvider(nil, nil), journal.NewStore(nil, ""), "test-secret")
 	ctx := context.Background()
 
 	req := makeRequest("POST", "/sync/check", "not-json")
@@ -72,3 +79,220 @@ func TestCheckConflict_InvalidBody(t *testing.T) {
 		t.Errorf("Expected 400 for invalid body, got %d", resp.StatusCode)
 	}
 }
+
+func TestReconcile(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewSyncHandler(provider, journal.NewStore(nil, ""), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	unchanged, _ := storage.CreateFile(ctx, "unchanged.md", []byte("a"), "")
+	edited, _ := storage.CreateFile(ctx, "edited.md", []byte("b"), "")
+	folder, _ := storage.CreateFolder(ctx, "sub", nil)
+	added, _ := storage.CreateFile(ctx, "added.md", []byte("c"), folder.ID)
+	staleETag := edited.ETag
+	storage.SaveFile(ctx, edited.ID, []byte("b2"), "")
+
+	snapshot, _ := json.Marshal(map[string]any{
+		"notes": map[string]string{
+			unchanged.ID: unchanged.ETag,
+			edited.ID:    staleETag,
+			"gone":       "etag",
+		},
+	})
+	resp, _ := h.Reconcile(ctx, makeRequest("POST", "/sync/reconcile", string(snapshot)))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var result handler.ReconcileResponse
+	json.Unmarshal([]byte(resp.Body), &result)
+
+	if len(result.Changed) != 1 || result.Changed[0].ID != edited.ID {
+		t.Errorf("Expected changed=[%s], got %+v", edited.ID, result.Changed)
+	}
+	if len(result.New) != 1 || result.New[0].ID != added.ID {
+		t.Errorf("Expected new=[%s] (from subfolder), got %+v", added.ID, result.New)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0] != "gone" {
+		t.Errorf("Expected deleted=[gone], got %v", result.Deleted)
+	}
+}
+
+func TestReconcile_InvalidBody(t *testing.T) {
+	h := handler.NewSyncHandler(memory.NewProvider(nil, nil), journal.NewStore(nil, ""), "test-secret")
+
+	resp, _ := h.Reconcile(context.Background(), makeRequest("POST", "/sync/reconcile", "not-json"))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid body, got %d", resp.StatusCode)
+	}
+}
+
+func TestReconcile_StopsAtDeadline(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewSyncHandler(provider, journal.NewStore(nil, ""), "test-secret")
+	storage, _ := provider.GetAdapter(context.Background(), testUserID)
+	storage.CreateFile(context.Background(), "a.md", []byte("a"), "")
+
+	// The memory adapter ignores cancellation, so the walk itself must stop.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp, _ := h.Reconcile(ctx, makeRequest("POST", "/sync/reconcile", `{"notes":{}}`))
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected 500 once the deadline has passed, got %d: %s", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	store := journal.NewStore(nil, "")
+	provider := journal.NewProvider(memory.NewProvider(nil, nil), store)
+	h := handler.NewSyncHandler(provider, store, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "a.md", []byte("a"), "")
+
+	resp, _ := h.Changes(ctx, makeRequest("GET", "/sync/changes", ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var first handler.ChangesResponse
+	json.Unmarshal([]byte(resp.Body), &first)
+	if len(first.Changes) != 1 || first.Changes[0].NoteID != note.ID || first.Changes[0].Type != journal.ChangeCreated {
+		t.Fatalf("Expected one created change, got %+v", first.Changes)
+	}
+
+	storage.DeleteFile(ctx, note.ID)
+
+	req := makeRequest("GET", "/sync/changes", "")
+	req.QueryStringParameters = map[string]string{"since": strconv.FormatInt(first.Cursor, 10)}
+	resp, _ = h.Changes(ctx, req)
+	var second handler.ChangesResponse
+	json.Unmarshal([]byte(resp.Body), &second)
+	if len(second.Changes) != 1 || second.Changes[0].Type != journal.ChangeDeleted {
+		t.Errorf("Expected one deleted change, got %+v", second.Changes)
+	}
+	if second.Cursor <= first.Cursor {
+		t.Errorf("Expected cursor to advance, got %d after %d", second.Cursor, first.Cursor)
+	}
+	if lag := time.Since(time.Unix(0, second.Cursor)); lag < journal.CursorLag {
+		t.Errorf("Expected the cursor to trail the clock by %v, got %v", journal.CursorLag, lag)
+	}
+}
+
+func TestChanges_ExpiredSince(t *testing.T) {
+	h := handler.NewSyncHandler(memory.NewProvider(nil, nil), journal.NewStore(nil, ""), "test-secret")
+
+	req := makeRequest("GET", "/sync/changes", "")
+	req.QueryStringParameters = map[string]string{"since": time.Now().Add(-journal.Retention - time.Hour).Format(time.RFC3339)}
+	resp, _ := h.Changes(context.Background(), req)
+	var body handler.ErrorResponse
+	json.Unmarshal([]byte(resp.Body), &body)
+	if resp.StatusCode != http.StatusGone || body.Code != handler.CodeCursorExpired {
+		t.Errorf("Expected 410 %s, got %d: %s", handler.CodeCursorExpired, resp.StatusCode, resp.Body)
+	}
+}
+
+func TestChanges_InvalidSince(t *testing.T) {
+	h := handler.NewSyncHandler(memory.NewProvider(nil, nil), journal.NewStore(nil, ""), "test-secret")
+
+	req := makeRequest("GET", "/sync/changes", "")
+	req.QueryStringParameters = map[string]string{"since": "yesterday"}
+	resp, _ := h.Changes(context.Background(), req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestCheckConflict_AgainstStorage(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewSyncHandler(provider, journal.NewStore(nil, ""), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	note, _ := storage.CreateFile(ctx, "a.md", []byte("a"), "")
+	baseETag := note.ETag
+
+	check := func() handler.CheckConflictResponse {
+		body, _ := json.Marshal(map[string]string{"note_id": note.ID, "etag": baseETag})
+		resp, _ := h.CheckConflict(ctx, makeRequest("POST", "/sync/check", string(body)))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		var result handler.CheckConflictResponse
+		json.Unmarshal([]byte(resp.Body), &result)
+		return result
+	}
+
+	if result := check(); result.HasConflict || result.RemoteETag != baseETag || result.ModifiedTime == nil {
+		t.Errorf("Expected no conflict with current etag and metadata, got %+v", result)
+	}
+
+	saved, _ := storage.SaveFile(ctx, note.ID, []byte("b"), "")
+	if result := check(); !result.HasConflict || result.RemoteETag != saved.ETag {
+		t.Errorf("Expected conflict reporting remote etag %s, got %+v", saved.ETag, result)
+	}
+}
+
+func TestCheckConflict_NoteNotFound(t *testing.T) {
+	h := handler.NewSyncHandler(memory.NewProvider(nil, nil), journal.NewStore(nil, ""), "test-secret")
+
+	resp, _ := h.CheckConflict(context.Background(), makeRequest("POST", "/sync/check", `{"note_id":"missing","etag":"x"}`))
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewSyncHandler(provider, journal.NewStore(nil, ""), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	folder, _ := storage.CreateFolder(ctx, "sub", nil)
+	top, _ := storage.CreateFile(ctx, "top.md", []byte("abc"), "")
+	storage.CreateFile(ctx, "nested.md", []byte("de"), folder.ID)
+
+	resp, _ := h.Manifest(ctx, makeRequest("GET", "/sync/manifest", ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	var entries []handler.ManifestEntry
+	json.Unmarshal([]byte(resp.Body), &entries)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 notes (folders excluded), got %d", len(entries))
+	}
+	for _, e := range entries {
+		if e.ID == top.ID && (e.Size != 3 || e.ETag != top.ETag || e.Modified == 0) {
+			t.Errorf("Unexpected entry for top.md: %+v", e)
+		}
+	}
+}
+
+func TestManifest_GzipsLargeResponses(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewSyncHandler(provider, journal.NewStore(nil, ""), "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	for i := range 20 {
+		storage.CreateFile(ctx, "note-"+strconv.Itoa(i)+".md", []byte("x"), "")
+	}
+
+	req := makeRequest("GET", "/sync/manifest", "")
+	req.Headers["Accept-Encoding"] = "gzip, deflate, br"
+	resp, _ := h.Manifest(ctx, req)
+	if resp.StatusCode != http.StatusOK || !resp.IsBase64Encoded || resp.Headers["Content-Encoding"] != "gzip" {
+		t.Fatalf("Expected a gzipped 200, got %d base64=%v headers=%v", resp.StatusCode, resp.IsBase64Encoded, resp.Headers)
+	}
+	compressed, _ := base64.StdEncoding.DecodeString(resp.Body)
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	var entries []handler.ManifestEntry
+	if err := json.NewDecoder(zr).Decode(&entries); err != nil || len(entries) != 20 {
+		t.Errorf("Expected 20 entries, got %d (%v)", len(entries), err)
+	}
+}
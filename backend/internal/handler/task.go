@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/core/markdown"
+)
+
+// OpenTask is an unchecked task item and the note it is in.
+type OpenTask struct {
+	NoteID   string `json:"noteId"`
+	NoteName string `json:"noteName"`
+	markdown.Task
+}
+
+// ListOpenTasks handles GET /tasks, returning the unchecked tasks in every
+// note under the base folder: dated tasks soonest first, then the rest by
+// note. ?q= limits it to notes matching a full-text search, and archived
+// notes are left out unless ?includeArchived=true. Encrypted notes are
+// skipped; their tasks can only be read on the client.
+func (h *NoteHandler) ListOpenTasks(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	var notes []adapter.FileMetadata
+	if q := req.QueryStringParameters["q"]; q != "" {
+		notes, err = storage.SearchFiles(ctx, q)
+	} else {
+		notes, err = listAllNotes(ctx, storage)
+	}
+	if err != nil {
+		return respondError(ctx, "ListOpenTasks", err), nil
+	}
+	if req.QueryStringParameters["includeArchived"] != "true" {
+		notes = withoutArchived(notes)
+	}
+	notes = slices.DeleteFunc(notes, func(n adapter.FileMetadata) bool {
+		return n.Encrypted || n.Size == 0
+	})
+
+	tasks, err := openTasks(ctx, storage, notes)
+	if err != nil {
+		return respondError(ctx, "ListOpenTasks", err), nil
+	}
+	slices.SortFunc(tasks, func(a, b OpenTask) int {
+		// Undated tasks ("") sort after dated ones.
+		if (a.Due == "") != (b.Due == "") {
+			if a.Due == "" {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Or(
+			strings.Compare(a.Due, b.Due),
+			cmp.Compare(strings.ToLower(a.NoteName), strings.ToLower(b.NoteName)),
+			strings.Compare(a.NoteID, b.NoteID),
+			cmp.Compare(a.Index, b.Index),
+		)
+	})
+
+	body, _ := json.Marshal(tasks)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// openTasks returns the unchecked tasks in notes.
+func openTasks(ctx context.Context, storage adapter.StorageAdapter, notes []adapter.FileMetadata) ([]OpenTask, error) {
+	var (
+		mu    sync.Mutex
+		tasks = []OpenTask{}
+	)
+	err := fetchNotes(ctx, storage, notes, func(n adapter.FileMetadata, file *adapter.File) {
+		if file.Encrypted {
+			return
+		}
+		var found []OpenTask
+		for _, t := range markdown.ExtractTasks(file.Content) {
+			if !t.Done {
+				found = append(found, OpenTask{NoteID: n.ID, NoteName: n.Name, Task: t})
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		tasks = append(tasks, found...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
@@ -0,0 +1,56 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func TestNoteHandler_ListOpenTasks(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	folder, _ := storage.CreateFolder(ctx, "Home", nil)
+	storage.CreateFile(ctx, "work.md", []byte("# Work\n\n- [ ] write report\n- [x] send invoice 📅 2024-05-01\n- [ ] renew domain 📅 2024-06-20\n"), "")
+	storage.CreateFile(ctx, "home.md", []byte("- [ ] pay rent 📅 2024-06-01\n"), folder.ID)
+	archived, _ := storage.CreateFile(ctx, "old.md", []byte("- [ ] forgotten\n"), "")
+	storage.SetArchived(ctx, archived.ID, true)
+
+	list := func(query map[string]string) []handler.OpenTask {
+		req := makeRequest("GET", "/tasks", "")
+		req.QueryStringParameters = query
+		resp, _ := h.ListOpenTasks(ctx, req)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		var tasks []handler.OpenTask
+		json.Unmarshal([]byte(resp.Body), &tasks)
+		return tasks
+	}
+
+	tasks := list(nil)
+	var texts []string
+	for _, task := range tasks {
+		texts = append(texts, task.Text)
+	}
+	want := []string{"pay rent", "renew domain", "write report"}
+	if len(texts) != len(want) || texts[0] != want[0] || texts[1] != want[1] || texts[2] != want[2] {
+		t.Fatalf("Expected %v, got %v", want, texts)
+	}
+	if tasks[2].NoteName != "work" || tasks[2].Line != 3 || tasks[2].Index != 0 {
+		t.Errorf("Unexpected task %+v", tasks[2])
+	}
+
+	if tasks := list(map[string]string{"includeArchived": "true"}); len(tasks) != 4 {
+		t.Errorf("Expected 4 tasks including archived notes, got %+v", tasks)
+	}
+	if tasks := list(map[string]string{"q": "rent"}); len(tasks) != 1 || tasks[0].Text != "pay rent" {
+		t.Errorf("Expected only the matching note's task, got %+v", tasks)
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/core/template"
+)
+
+// TemplateNote is the response of CreateFromTemplate: the new note and the
+// byte offset in its content of the template's {{cursor}}, or -1.
+type TemplateNote struct {
+	adapter.FileMetadata
+	Cursor int `json:"cursor"`
+}
+
+// CreateFromTemplate handles POST /templates/{id}/notes, creating a note
+// from the note {id} with its variables expanded by core/template, as the
+// editor does when a note is created from a template. The body is
+// {"name": "Standup.md", "parentId": "...", "variables": {"project": "X"},
+// "now": "2024-06-01T09:00:00+09:00"}. {{title}} is the name without its
+// extension, and {{date}} and {{time}} use now, which the client sends so
+// they are in the user's time zone; it defaults to the server's clock.
+func (h *NoteHandler) CreateFromTemplate(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	id := req.PathParameters["id"]
+	if id == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing template ID"), nil
+	}
+
+	var input struct {
+		Name      string            `json:"name"`
+		ParentID  string            `json:"parentId"`
+		Variables map[string]string `json:"variables"`
+		Now       time.Time         `json:"now"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if input.Name == "" {
+		return Error(ctx, http.StatusBadRequest, "Name cannot be empty"), nil
+	}
+
+	tmpl, err := storage.GetFile(ctx, id)
+	if err == nil && tmpl.Encrypted {
+		err = adapter.ErrEncrypted
+	}
+	if err != nil {
+		return respondError(ctx, "GetFile", err), nil
+	}
+	if tmpl.MIMEType == folderMIMEType {
+		return Error(ctx, http.StatusBadRequest, "A folder cannot be used as a template"), nil
+	}
+
+	expansion := template.ExpandVariables(string(tmpl.Content), template.Context{
+		Title:     strings.TrimSuffix(input.Name, ".md"),
+		Now:       input.Now,
+		Variables: input.Variables,
+	})
+	file, err := storage.CreateFile(ctx, input.Name, []byte(expansion.Text), input.ParentID)
+	if err != nil {
+		return respondError(ctx, "CreateFile", err), nil
+	}
+	h.indexReminders(ctx, req, file, expansion.Text)
+
+	body, _ := json.Marshal(TemplateNote{FileMetadata: *file, Cursor: expansion.Cursor})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusCreated,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
@@ -0,0 +1,51 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func TestNoteHandler_CreateFromTemplate(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewNoteHandler(provider, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	tmpl, _ := storage.CreateFile(ctx, "Meeting.md", []byte("# {{title}}\n\n{{date}} {{time}} with {{who}}\n\n- {{cursor}}\n"), "")
+	folder, _ := storage.CreateFolder(ctx, "Meetings", nil)
+
+	create := func(body string) (int, handler.TemplateNote) {
+		req := makeRequest("POST", "/templates/"+tmpl.ID+"/notes", body)
+		req.PathParameters["id"] = tmpl.ID
+		resp, _ := h.CreateFromTemplate(ctx, req)
+		var n handler.TemplateNote
+		json.Unmarshal([]byte(resp.Body), &n)
+		return resp.StatusCode, n
+	}
+
+	status, n := create(`{"name":"Standup.md","parentId":"` + folder.ID + `","variables":{"who":"Ana"},"now":"2024-06-01T09:30:00+09:00"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", status)
+	}
+	want := "# Standup\n\n2024-06-01 09:30 with Ana\n\n- \n"
+	if f, _ := storage.GetFile(ctx, n.ID); string(f.Content) != want || n.Cursor != len(want)-1 {
+		t.Errorf("Expected %q with the cursor at %d, got %q at %d", want, len(want)-1, f.Content, n.Cursor)
+	}
+	if len(n.Parents) != 1 || n.Parents[0] != folder.ID {
+		t.Errorf("Expected the note in the folder, got parents %v", n.Parents)
+	}
+
+	if status, _ := create(`{"variables":{}}`); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a name, got %d", status)
+	}
+	req := makeRequest("POST", "/templates/"+folder.ID+"/notes", `{"name":"x.md"}`)
+	req.PathParameters["id"] = folder.ID
+	if resp, _ := h.CreateFromTemplate(ctx, req); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a folder template, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/translate"
+)
+
+// TranslateHandler translates notes. It is only routed when a translator is
+// configured.
+type TranslateHandler struct {
+	storageProvider adapter.StorageProvider
+	translator      translate.Translator
+	jwtSecret       string
+}
+
+// NewTranslateHandler creates a new TranslateHandler.
+func NewTranslateHandler(provider adapter.StorageProvider, translator translate.Translator, jwtSecret string) *TranslateHandler {
+	return &TranslateHandler{storageProvider: provider, translator: translator, jwtSecret: jwtSecret}
+}
+
+// NoteTranslation is the response of TranslateNote. ETag is the version of
+// the note that was translated. Note is the new sibling note when the
+// translation was saved.
+type NoteTranslation struct {
+	Lang    string                `json:"lang"`
+	Content string                `json:"content"`
+	ETag    string                `json:"etag"`
+	Note    *adapter.FileMetadata `json:"note,omitempty"`
+}
+
+// TranslateNote handles POST /notes/{id}/translate?lang=ja, translating the
+// text of the note into the language tagged lang. Markdown structure,
+// code and frontmatter are kept as they are. With ?save=true the
+// translation is also created as a new note next to the original, named
+// "<name> (<lang>).md", and the response is 201. End-to-end encrypted notes
+// cannot be translated.
+func (h *TranslateHandler) TranslateNote(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := requestUserID(ctx, req, h.jwtSecret)
+	if err != nil {
+		return Error(ctx, http.StatusUnauthorized, "Unauthorized"), nil
+	}
+	noteID := req.PathParameters["id"]
+	if noteID == "" {
+		return Error(ctx, http.StatusBadRequest, "Missing note ID"), nil
+	}
+	lang := req.QueryStringParameters["lang"]
+	if !translate.ValidLanguage(lang) {
+		return Error(ctx, http.StatusBadRequest, "Query parameter 'lang' must be a language tag such as ja or pt-BR"), nil
+	}
+
+	storage, err := h.storageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", fmt.Errorf("%w: %v", ErrUnauthorized, err)), nil
+	}
+	note, err := storage.GetFile(ctx, noteID)
+	if err == nil && note.Encrypted {
+		err = adapter.ErrEncrypted
+	}
+	if err != nil {
+		return respondError(ctx, "Translate GetFile", err), nil
+	}
+
+	content, err := translate.Note(ctx, h.translator, note.Content, lang)
+	if err != nil {
+		return respondError(ctx, "Translate", err), nil
+	}
+	resp := NoteTranslation{Lang: lang, Content: string(content), ETag: note.ETag}
+	status := http.StatusOK
+
+	if req.QueryStringParameters["save"] == "true" {
+		var parent string
+		if len(note.Parents) > 0 {
+			parent = note.Parents[0]
+		}
+		name := strings.TrimSuffix(note.Name, ".md") + " (" + lang + ").md"
+		resp.Note, err = storage.CreateFile(ctx, name, content, parent)
+		if err != nil {
+			return respondError(ctx, "Translate CreateFile", err), nil
+		}
+		status = http.StatusCreated
+	}
+
+	body, _ := json.Marshal(resp)
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
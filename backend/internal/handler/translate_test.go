@@ -0,0 +1,65 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+// shout translates by upper-casing.
+type shout struct{}
+
+func (shout) Translate(ctx context.Context, texts []string, lang string) ([]string, error) {
+	out := make([]string, len(texts))
+	for i, t := range texts {
+		out[i] = strings.ToUpper(t)
+	}
+	return out, nil
+}
+
+func TestTranslateHandler_TranslateNote(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	h := handler.NewTranslateHandler(provider, shout{}, "test-secret")
+	ctx := context.Background()
+
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	folder, _ := storage.CreateFolder(ctx, "Work", nil)
+	note, _ := storage.CreateFile(ctx, "plan.md", []byte("# Plan\n\nShip `v2` on *Friday*.\n"), folder.ID)
+
+	req := makeRequest("POST", "/notes/"+note.ID+"/translate", "")
+	req.PathParameters["id"] = note.ID
+	req.QueryStringParameters = map[string]string{"lang": "ja"}
+	resp, _ := h.TranslateNote(ctx, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var got handler.NoteTranslation
+	json.Unmarshal([]byte(resp.Body), &got)
+	if got.Content != "# PLAN\n\nSHIP `v2` ON *FRIDAY*.\n" || got.Lang != "ja" || got.ETag != note.ETag || got.Note != nil {
+		t.Errorf("Unexpected translation %+v", got)
+	}
+
+	req.QueryStringParameters["save"] = "true"
+	resp, _ = h.TranslateNote(ctx, req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201 when saving, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	json.Unmarshal([]byte(resp.Body), &got)
+	if got.Note == nil {
+		t.Fatal("Expected the saved note in the response")
+	}
+	saved, _ := storage.GetFile(ctx, got.Note.ID)
+	if string(saved.Content) != got.Content || !strings.HasPrefix(saved.Name, "plan (ja)") || len(saved.Parents) != 1 || saved.Parents[0] != folder.ID {
+		t.Errorf("Unexpected saved note %q in %v: %q", saved.Name, saved.Parents, saved.Content)
+	}
+
+	req.QueryStringParameters = map[string]string{"lang": "not a language"}
+	if resp, _ := h.TranslateNote(ctx, req); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad language, got %d", resp.StatusCode)
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -8,8 +9,60 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// UserClaims holds the identity fields carried in the session JWT.
+// AccessTokenID is set instead when the request authenticated with a
+// personal access token.
+type UserClaims struct {
+	UserID        string
+	Email         string
+	Name          string
+	AccessTokenID string
+}
+
+type userClaimsKey struct{}
+
+// WithUserClaims returns a context carrying claims already verified by the
+// app's authentication middleware.
+func WithUserClaims(ctx context.Context, claims *UserClaims) context.Context {
+	return context.WithValue(ctx, userClaimsKey{}, claims)
+}
+
+// UserClaimsFromContext returns the claims stored by WithUserClaims.
+func UserClaimsFromContext(ctx context.Context) (*UserClaims, bool) {
+	claims, ok := ctx.Value(userClaimsKey{}).(*UserClaims)
+	return claims, ok && claims != nil
+}
+
+// requestUserClaims returns the caller's claims from ctx, verifying the
+// request's token only when no middleware has done so (e.g. direct calls in
+// tests).
+func requestUserClaims(ctx context.Context, req events.APIGatewayProxyRequest, jwtSecret string) (*UserClaims, error) {
+	if claims, ok := UserClaimsFromContext(ctx); ok {
+		return claims, nil
+	}
+	return GetUserClaims(req, jwtSecret)
+}
+
+// requestUserID is requestUserClaims for callers that only need the user ID.
+func requestUserID(ctx context.Context, req events.APIGatewayProxyRequest, jwtSecret string) (string, error) {
+	claims, err := requestUserClaims(ctx, req, jwtSecret)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
 // GetUserID extracts the user ID from the Authorization header or session cookie.
 func GetUserID(req events.APIGatewayProxyRequest, jwtSecret string) (string, error) {
+	claims, err := GetUserClaims(req, jwtSecret)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
+// GetUserClaims extracts and verifies the session JWT, returning its identity claims.
+func GetUserClaims(req events.APIGatewayProxyRequest, jwtSecret string) (*UserClaims, error) {
 	// Helper for case-insensitive header lookup
 	getHeader := func(name string) string {
 		for k, v := range req.Headers {
@@ -29,22 +82,11 @@ func GetUserID(req events.APIGatewayProxyRequest, jwtSecret string) (string, err
 
 	// 2. Check Cookie
 	if tokenString == "" {
-		// Cookie format: session_token=xxx; ...
-		cookies := getHeader("Cookie")
-		if cookies != "" {
-			parts := strings.Split(cookies, ";")
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if strings.HasPrefix(part, "session_token=") {
-					tokenString = strings.TrimPrefix(part, "session_token=")
-					break
-				}
-			}
-		}
+		tokenString = cookieValue(req, "session_token")
 	}
 
 	if tokenString == "" {
-		return "", fmt.Errorf("no authorization token found")
+		return nil, fmt.Errorf("no authorization token found")
 	}
 
 	// Verify JWT
@@ -56,14 +98,43 @@ func GetUserID(req events.APIGatewayProxyRequest, jwtSecret string) (string, err
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("invalid token: %v", err)
+		return nil, fmt.Errorf("invalid token: %v", err)
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		if sub, ok := claims["sub"].(string); ok {
-			return sub, nil
+			email, _ := claims["email"].(string)
+			name, _ := claims["name"].(string)
+			return &UserClaims{UserID: sub, Email: email, Name: name}, nil
 		}
 	}
 
-	return "", fmt.Errorf("invalid token claims")
+	return nil, fmt.Errorf("invalid token claims")
+}
+
+// cookieValue returns the value of the named cookie sent with req, or "".
+// Every Cookie header is searched, including repeated ones.
+func cookieValue(req events.APIGatewayProxyRequest, name string) string {
+	var lines []string
+	for k, v := range req.MultiValueHeaders {
+		if strings.EqualFold(k, "Cookie") {
+			lines = append(lines, v...)
+		}
+	}
+	if lines == nil {
+		for k, v := range req.Headers {
+			if strings.EqualFold(k, "Cookie") {
+				lines = append(lines, v)
+			}
+		}
+	}
+	for _, line := range lines {
+		// Cookie format: session_token=xxx; ...
+		for _, part := range strings.Split(line, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(part), name+"="); ok {
+				return value
+			}
+		}
+	}
+	return ""
 }
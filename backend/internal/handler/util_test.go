@@ -1,6 +1,7 @@
 package handler_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -105,3 +106,16 @@ func TestGetUserID_CaseInsensitiveHeaders(t *testing.T) {
 		t.Errorf("Expected userID '%s', got '%s'", testUserID, userID)
 	}
 }
+
+func TestUserClaimsFromContext(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := handler.UserClaimsFromContext(ctx); ok {
+		t.Fatal("Expected no claims in an empty context")
+	}
+
+	ctx = handler.WithUserClaims(ctx, &handler.UserClaims{UserID: testUserID})
+	claims, ok := handler.UserClaimsFromContext(ctx)
+	if !ok || claims.UserID != testUserID {
+		t.Errorf("Expected claims for %s, got %+v", testUserID, claims)
+	}
+}
@@ -0,0 +1,502 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// A vault is the user's notes seen as a tree of Markdown files, as in an
+// Obsidian vault: the note "Plan" in the folder "Work" is "Work/Plan.md",
+// relative to the base folder. Only notes and folders are stored, so a
+// vault's attachments and other files are not synced.
+
+// ErrAmbiguousPath is returned when more than one note or folder has a
+// vault path, which Drive allows but a file system does not.
+var ErrAmbiguousPath = errors.New("more than one note or folder has this path")
+
+const (
+	// maxVaultPath bounds the length of a vault path.
+	maxVaultPath = 1024
+	// maxVaultChanges bounds the changes in one push.
+	maxVaultChanges = 200
+	// vaultExt ends the vault path of every note.
+	vaultExt = ".md"
+)
+
+// parseVaultPath splits a note's vault path into its folder's path and the
+// note's name.
+func parseVaultPath(p string) (dir, name string, err error) {
+	if p == "" || len(p) > maxVaultPath {
+		return "", "", errors.New("path must be 1 to 1024 characters")
+	}
+	if path.Clean(p) != p || strings.HasPrefix(p, "/") || strings.HasPrefix(p, "../") || p == ".." {
+		return "", "", fmt.Errorf("%q is not a clean relative path", p)
+	}
+	dir, file := path.Split(p)
+	name, ok := strings.CutSuffix(file, vaultExt)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("%q is not a Markdown file", p)
+	}
+	return strings.TrimSuffix(dir, "/"), name, nil
+}
+
+// vault indexes the items under the base folder by vault path.
+type vault struct {
+	items map[string]adapter.FileMetadata
+	// ambiguous holds the paths of more than one item, which are left out
+	// of items.
+	ambiguous map[string]bool
+}
+
+// loadVault walks the base folder tree. Items whose names contain a slash
+// have no vault path and are skipped.
+func loadVault(ctx context.Context, storage adapter.StorageAdapter) (*vault, error) {
+	v := &vault{items: map[string]adapter.FileMetadata{}, ambiguous: map[string]bool{}}
+	type folder struct{ id, path string }
+	queue := []folder{{}} // "" is the user's base folder
+	visited := make(map[string]bool)
+
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if visited[f.id] {
+			continue
+		}
+		visited[f.id] = true
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		files, err := storage.ListFiles(ctx, f.id)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range files {
+			if strings.Contains(item.Name, "/") {
+				continue
+			}
+			p := item.Name
+			if item.MIMEType != folderMIMEType {
+				p += vaultExt
+			}
+			p = path.Join(f.path, p)
+			v.add(p, item)
+			if item.MIMEType == folderMIMEType {
+				queue = append(queue, folder{item.ID, p})
+			}
+		}
+	}
+	return v, nil
+}
+
+// add records item at p, marking p ambiguous if it is taken.
+func (v *vault) add(p string, item adapter.FileMetadata) {
+	if _, taken := v.items[p]; taken || v.ambiguous[p] {
+		delete(v.items, p)
+		v.ambiguous[p] = true
+		return
+	}
+	v.items[p] = item
+}
+
+// note returns the note at p; ok is false if there is none.
+func (v *vault) note(p string) (note adapter.FileMetadata, ok bool, err error) {
+	if v.ambiguous[p] {
+		return adapter.FileMetadata{}, false, ErrAmbiguousPath
+	}
+	note, ok = v.items[p]
+	if ok && note.MIMEType == folderMIMEType {
+		// A folder named like a note.
+		return adapter.FileMetadata{}, false, ErrAmbiguousPath
+	}
+	return note, ok, nil
+}
+
+// ensureFolder returns the ID of the folder at dir, creating it and its
+// parents if needed. The base folder is "".
+func (v *vault) ensureFolder(ctx context.Context, storage adapter.StorageAdapter, dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if v.ambiguous[dir] {
+		return "", ErrAmbiguousPath
+	}
+	if f, ok := v.items[dir]; ok {
+		if f.MIMEType != folderMIMEType {
+			return "", ErrAmbiguousPath
+		}
+		return f.ID, nil
+	}
+	parent, name := path.Split(dir)
+	parentID, err := v.ensureFolder(ctx, storage, strings.TrimSuffix(parent, "/"))
+	if err != nil {
+		return "", err
+	}
+	var parents []string
+	if parentID != "" {
+		parents = []string{parentID}
+	}
+	f, err := storage.CreateFolder(ctx, name, parents)
+	if err != nil {
+		return "", err
+	}
+	v.items[dir] = *f
+	return f.ID, nil
+}
+
+// VaultNote is a note in the vault manifest. Modified is in Unix seconds.
+type VaultNote struct {
+	Path      string `json:"path"`
+	ID        string `json:"id"`
+	ETag      string `json:"etag"`
+	Size      int64  `json:"size"`
+	Modified  int64  `json:"modified"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
+
+// VaultManifest lists the vault. Ambiguous paths are held by more than one
+// note or folder; clients should leave them alone until they are renamed.
+type VaultManifest struct {
+	Folders   []string    `json:"folders"`
+	Notes     []VaultNote `json:"notes"`
+	Ambiguous []string    `json:"ambiguous"`
+}
+
+// GetVault handles GET /sync/vault, listing every folder and note by vault
+// path so a client can mirror them into a local directory. Like
+// /sync/manifest, the response is gzip-compressed for clients that accept
+// it.
+func (h *NoteHandler) GetVault(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	v, err := loadVault(ctx, storage)
+	if err != nil {
+		return respondError(ctx, "LoadVault", err), nil
+	}
+
+	m := VaultManifest{Folders: []string{}, Notes: []VaultNote{}, Ambiguous: []string{}}
+	for p, item := range v.items {
+		if item.MIMEType == folderMIMEType {
+			m.Folders = append(m.Folders, p)
+			continue
+		}
+		m.Notes = append(m.Notes, VaultNote{
+			Path:      p,
+			ID:        item.ID,
+			ETag:      item.ETag,
+			Size:      item.Size,
+			Modified:  item.ModifiedTime.Unix(),
+			Encrypted: item.Encrypted,
+		})
+	}
+	for p := range v.ambiguous {
+		m.Ambiguous = append(m.Ambiguous, p)
+	}
+	slices.Sort(m.Folders)
+	slices.SortFunc(m.Notes, func(a, b VaultNote) int { return cmp.Compare(a.Path, b.Path) })
+	slices.Sort(m.Ambiguous)
+
+	body, _ := json.Marshal(m)
+	return Compress(req, events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}), nil
+}
+
+// GetNoteByPath handles GET /notes/by-path?path=Folder/Note.md, returning
+// the note like GetNote.
+func (h *NoteHandler) GetNoteByPath(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	p := req.QueryStringParameters["path"]
+	if _, _, err := parseVaultPath(p); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid path: "+err.Error()), nil
+	}
+
+	v, err := loadVault(ctx, storage)
+	if err != nil {
+		return respondError(ctx, "LoadVault", err), nil
+	}
+	note, ok, err := v.note(p)
+	if err == nil && !ok {
+		err = adapter.ErrNotFound
+	}
+	if err != nil {
+		return respondError(ctx, "GetNoteByPath", err), nil
+	}
+
+	file, err := storage.GetFile(ctx, note.ID)
+	if err != nil {
+		return respondError(ctx, "GetFile", err), nil
+	}
+	return h.noteResponse(ctx, file), nil
+}
+
+// VaultChange is one change pushed from a vault.
+//
+// Content is written to the note at Path. ETag is the version of the note
+// the client last synced, which must still be current; it is empty for
+// notes the client created. With From, the note at From is moved to Path,
+// keeping its content if Content is null, and ETag is that of the note at
+// From. With Deleted, the note at Path is deleted; without an ETag, even if
+// it changed since the client synced it.
+type VaultChange struct {
+	Path    string  `json:"path"`
+	Content *string `json:"content"`
+	ETag    string  `json:"etag,omitempty"`
+	From    string  `json:"from,omitempty"`
+	Deleted bool    `json:"deleted,omitempty"`
+}
+
+// Vault change statuses.
+const (
+	VaultApplied  = "applied"
+	VaultConflict = "conflict"
+	VaultFailed   = "failed"
+)
+
+// VaultResult is the outcome of one change. ID and ETag describe the note
+// at Path afterwards, or the server's version on a conflict.
+type VaultResult struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	ETag   string `json:"etag,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PushVault handles POST /sync/vault with {"changes": [VaultChange...]}.
+// Changes are applied in order, creating folders as needed, and each gets
+// a VaultResult. A change that conflicts with the server is not applied;
+// the client should pull the note and push again.
+func (h *NoteHandler) PushVault(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	storage, err := h.getStorageAdapter(ctx, req)
+	if err != nil {
+		return respondError(ctx, "GetAdapter", err), nil
+	}
+
+	var input struct {
+		Changes []VaultChange `json:"changes"`
+	}
+	if err := json.Unmarshal([]byte(req.Body), &input); err != nil {
+		return Error(ctx, http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if len(input.Changes) == 0 || len(input.Changes) > maxVaultChanges {
+		return Error(ctx, http.StatusBadRequest, fmt.Sprintf("Push 1 to %d changes at a time", maxVaultChanges)), nil
+	}
+
+	v, err := loadVault(ctx, storage)
+	if err != nil {
+		return respondError(ctx, "LoadVault", err), nil
+	}
+
+	results := make([]VaultResult, 0, len(input.Changes))
+	for _, c := range input.Changes {
+		r := h.applyVaultChange(ctx, req, storage, v, c)
+		r.Path = c.Path
+		results = append(results, r)
+	}
+
+	body, _ := json.Marshal(map[string]any{"results": results})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+func vaultConflict(message string, current adapter.FileMetadata) VaultResult {
+	return VaultResult{Status: VaultConflict, ID: current.ID, ETag: current.ETag, Error: message}
+}
+
+// vaultFailure reports err like respondError: unknown errors are logged
+// and their details left out.
+func vaultFailure(ctx context.Context, err error) VaultResult {
+	e, status, ok := mapError(err)
+	if !ok {
+		slog.ErrorContext(ctx, "PushVault failed", "error", err)
+		return VaultResult{Status: VaultFailed, Error: "Internal Server Error"}
+	}
+	if status == http.StatusConflict || status == http.StatusPreconditionFailed {
+		return VaultResult{Status: VaultConflict, Error: e.Message}
+	}
+	return VaultResult{Status: VaultFailed, Error: e.Message}
+}
+
+func (h *NoteHandler) applyVaultChange(ctx context.Context, req events.APIGatewayProxyRequest, storage adapter.StorageAdapter, v *vault, c VaultChange) VaultResult {
+	dir, name, err := parseVaultPath(c.Path)
+	if err == nil && c.From != "" {
+		_, _, err = parseVaultPath(c.From)
+	}
+	if err == nil && c.Deleted && (c.From != "" || c.Content != nil) {
+		err = errors.New("a deletion has no content or from")
+	}
+	if err == nil && !c.Deleted && c.From == "" && c.Content == nil {
+		err = errors.New("content is required")
+	}
+	if err != nil {
+		return VaultResult{Status: VaultFailed, Error: err.Error()}
+	}
+
+	current, exists, err := v.note(c.Path)
+	if err != nil {
+		return vaultFailure(ctx, err)
+	}
+
+	switch {
+	case c.Deleted:
+		if !exists {
+			return VaultResult{Status: VaultApplied} // already gone
+		}
+		if c.ETag != "" && c.ETag != current.ETag {
+			return vaultConflict("The note was changed since it was synced", current)
+		}
+		if resp := h.checkEditLock(ctx, req, current.ID); resp != nil {
+			return vaultConflict("The note is being edited by another user", current)
+		}
+		if err := storage.DeleteFile(ctx, current.ID); err != nil {
+			return vaultFailure(ctx, err)
+		}
+		h.forgetNote(ctx, req, current.ID)
+		delete(v.items, c.Path)
+		return VaultResult{Status: VaultApplied}
+
+	case c.From != "":
+		if exists {
+			return vaultConflict("A note already exists at the new path", current)
+		}
+		source, ok, err := v.note(c.From)
+		if err != nil {
+			return vaultFailure(ctx, err)
+		}
+		if !ok {
+			return VaultResult{Status: VaultConflict, Error: "The note to move no longer exists"}
+		}
+		if c.ETag != "" && c.ETag != source.ETag {
+			return vaultConflict("The note was changed since it was synced", source)
+		}
+		if resp := h.checkEditLock(ctx, req, source.ID); resp != nil {
+			return vaultConflict("The note is being edited by another user", source)
+		}
+		return h.moveVaultNote(ctx, req, storage, v, c, source, dir, name)
+
+	case exists:
+		content := []byte(*c.Content)
+		if c.ETag == "" {
+			// A retried create, or the same note added on both sides.
+			if current.ContentHash == adapter.ContentHash(content) {
+				return VaultResult{Status: VaultApplied, ID: current.ID, ETag: current.ETag}
+			}
+			return vaultConflict("A note already exists at this path", current)
+		}
+		if resp := h.checkEditLock(ctx, req, current.ID); resp != nil {
+			return vaultConflict("The note is being edited by another user", current)
+		}
+		file, err := storage.SaveFile(ctx, current.ID, content, c.ETag)
+		if errors.Is(err, adapter.ErrPreconditionFailed) {
+			return vaultConflict("The note was changed since it was synced", current)
+		}
+		if err != nil {
+			return vaultFailure(ctx, err)
+		}
+		h.discardDraft(ctx, req, file.ID)
+		h.indexReminders(ctx, req, file, *c.Content)
+		v.items[c.Path] = *file
+		return VaultResult{Status: VaultApplied, ID: file.ID, ETag: file.ETag}
+
+	default:
+		if c.ETag != "" {
+			return VaultResult{Status: VaultConflict, Error: "The note was deleted since it was synced"}
+		}
+		folderID, err := v.ensureFolder(ctx, storage, dir)
+		if err != nil {
+			return vaultFailure(ctx, err)
+		}
+		file, err := storage.CreateFile(ctx, name, []byte(*c.Content), folderID)
+		if err != nil {
+			return vaultFailure(ctx, err)
+		}
+		h.indexReminders(ctx, req, file, *c.Content)
+		v.items[c.Path] = *file
+		return VaultResult{Status: VaultApplied, ID: file.ID, ETag: file.ETag}
+	}
+}
+
+// moveVaultNote moves source to c.Path. A rename within a folder keeps the
+// note's ID, and with it its comments, reminders and share links. The
+// storage adapters cannot move notes between folders, so such a move
+// creates a new note and deletes the old one.
+func (h *NoteHandler) moveVaultNote(ctx context.Context, req events.APIGatewayProxyRequest, storage adapter.StorageAdapter, v *vault, c VaultChange, source adapter.FileMetadata, dir, name string) VaultResult {
+	fromDir, _, _ := parseVaultPath(c.From)
+	if fromDir == dir {
+		file, err := storage.RenameFile(ctx, source.ID, name)
+		if err != nil {
+			return vaultFailure(ctx, err)
+		}
+		delete(v.items, c.From)
+		if c.Content != nil {
+			file, err = storage.SaveFile(ctx, file.ID, []byte(*c.Content), file.ETag)
+			if err != nil {
+				return vaultFailure(ctx, err)
+			}
+			h.discardDraft(ctx, req, file.ID)
+			h.indexReminders(ctx, req, file, *c.Content)
+		}
+		v.items[c.Path] = *file
+		return VaultResult{Status: VaultApplied, ID: file.ID, ETag: file.ETag}
+	}
+
+	var content []byte
+	if c.Content != nil {
+		content = []byte(*c.Content)
+	} else {
+		f, err := storage.GetFile(ctx, source.ID)
+		if err != nil {
+			return vaultFailure(ctx, err)
+		}
+		content = f.Content
+	}
+	folderID, err := v.ensureFolder(ctx, storage, dir)
+	if err != nil {
+		return vaultFailure(ctx, err)
+	}
+	file, err := storage.CreateFile(ctx, name, content, folderID)
+	if err != nil {
+		return vaultFailure(ctx, err)
+	}
+	v.items[c.Path] = *file
+	if !adapter.IsEncrypted(content) {
+		h.indexReminders(ctx, req, file, string(content))
+	}
+	if err := storage.DeleteFile(ctx, source.ID); err != nil {
+		// The note now exists twice; report it so the client pulls both.
+		slog.ErrorContext(ctx, "Deleting moved note failed", "note_id", source.ID, "error", err)
+		return VaultResult{Status: VaultFailed, ID: file.ID, ETag: file.ETag, Error: "The note was copied but the original could not be deleted"}
+	}
+	h.forgetNote(ctx, req, source.ID)
+	delete(v.items, c.From)
+	return VaultResult{Status: VaultApplied, ID: file.ID, ETag: file.ETag}
+}
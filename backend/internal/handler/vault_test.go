@@ -0,0 +1,163 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/handler"
+)
+
+func pushVault(t *testing.T, h *handler.NoteHandler, changes ...handler.VaultChange) []handler.VaultResult {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"changes": changes})
+	resp, err := h.PushVault(context.Background(), makeRequest("POST", "/sync/vault", string(body)))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("PushVault = %d %s, %v", resp.StatusCode, resp.Body, err)
+	}
+	var out struct {
+		Results []handler.VaultResult `json:"results"`
+	}
+	json.Unmarshal([]byte(resp.Body), &out)
+	return out.Results
+}
+
+func getVault(t *testing.T, h *handler.NoteHandler) handler.VaultManifest {
+	t.Helper()
+	resp, err := h.GetVault(context.Background(), makeRequest("GET", "/sync/vault", ""))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GetVault = %d %s, %v", resp.StatusCode, resp.Body, err)
+	}
+	var m handler.VaultManifest
+	json.Unmarshal([]byte(resp.Body), &m)
+	return m
+}
+
+func getByPath(h *handler.NoteHandler, path string) (int, handler.NoteResponse) {
+	req := makeRequest("GET", "/notes/by-path", "")
+	req.QueryStringParameters = map[string]string{"path": path}
+	resp, _ := h.GetNoteByPath(context.Background(), req)
+	var note handler.NoteResponse
+	json.Unmarshal([]byte(resp.Body), &note)
+	return resp.StatusCode, note
+}
+
+func text(s string) *string { return &s }
+
+func TestVault_PushAndPull(t *testing.T) {
+	h := handler.NewNoteHandler(memory.NewProvider(nil, nil), "test-secret")
+
+	res := pushVault(t, h,
+		handler.VaultChange{Path: "Work/Projects/Plan.md", Content: text("# Plan\n")},
+		handler.VaultChange{Path: "Inbox.md", Content: text("todo\n")},
+		handler.VaultChange{Path: "../escape.md", Content: text("x")},
+		handler.VaultChange{Path: "image.png", Content: text("x")},
+	)
+	if res[0].Status != handler.VaultApplied || res[1].Status != handler.VaultApplied {
+		t.Fatalf("creates = %+v", res)
+	}
+	if res[2].Status != handler.VaultFailed || res[3].Status != handler.VaultFailed {
+		t.Errorf("invalid paths = %+v", res[2:])
+	}
+
+	m := getVault(t, h)
+	if !slices.Equal(m.Folders, []string{"Work", "Work/Projects"}) {
+		t.Errorf("folders = %q", m.Folders)
+	}
+	if len(m.Notes) != 2 || m.Notes[0].Path != "Inbox.md" || m.Notes[1].Path != "Work/Projects/Plan.md" || m.Notes[1].ID != res[0].ID {
+		t.Errorf("notes = %+v", m.Notes)
+	}
+
+	status, note := getByPath(h, "Work/Projects/Plan.md")
+	if status != http.StatusOK || note.Content != "# Plan\n" || note.ETag != res[0].ETag {
+		t.Errorf("by-path = %d %+v", status, note)
+	}
+	if status, _ := getByPath(h, "Work/Missing.md"); status != http.StatusNotFound {
+		t.Errorf("by-path of a missing note = %d", status)
+	}
+	if status, _ := getByPath(h, "/Inbox.md"); status != http.StatusBadRequest {
+		t.Errorf("by-path of an absolute path = %d", status)
+	}
+
+	// A retried create is harmless; a different note at the path conflicts.
+	res = pushVault(t, h,
+		handler.VaultChange{Path: "Inbox.md", Content: text("todo\n")},
+		handler.VaultChange{Path: "Inbox.md", Content: text("other\n")},
+	)
+	if res[0].Status != handler.VaultApplied || res[1].Status != handler.VaultConflict {
+		t.Errorf("repeated creates = %+v", res)
+	}
+}
+
+func TestVault_EditMoveDelete(t *testing.T) {
+	h := handler.NewNoteHandler(memory.NewProvider(nil, nil), "test-secret")
+	res := pushVault(t, h,
+		handler.VaultChange{Path: "A/Note.md", Content: text("v1")},
+		handler.VaultChange{Path: "A/Other.md", Content: text("o1")},
+	)
+	note, other := res[0], res[1]
+
+	res = pushVault(t, h,
+		handler.VaultChange{Path: "A/Note.md", Content: text("v2"), ETag: note.ETag},
+		handler.VaultChange{Path: "A/Note.md", Content: text("v3"), ETag: note.ETag},
+	)
+	if res[0].Status != handler.VaultApplied || res[1].Status != handler.VaultConflict || res[1].ETag != res[0].ETag {
+		t.Fatalf("edits = %+v", res)
+	}
+	note = res[0]
+
+	// A rename keeps the note; a move to another folder recreates it.
+	res = pushVault(t, h,
+		handler.VaultChange{Path: "A/Renamed.md", From: "A/Note.md", ETag: note.ETag},
+		handler.VaultChange{Path: "B/Moved.md", From: "A/Other.md", ETag: other.ETag},
+	)
+	if res[0].Status != handler.VaultApplied || res[0].ID != note.ID {
+		t.Errorf("rename = %+v, want ID %s", res[0], note.ID)
+	}
+	if res[1].Status != handler.VaultApplied || res[1].ID == other.ID {
+		t.Errorf("move = %+v", res[1])
+	}
+	if _, n := getByPath(h, "A/Renamed.md"); n.Content != "v2" {
+		t.Errorf("renamed note = %+v", n)
+	}
+	if _, n := getByPath(h, "B/Moved.md"); n.Content != "o1" {
+		t.Errorf("moved note = %+v", n)
+	}
+	if status, _ := getByPath(h, "A/Other.md"); status != http.StatusNotFound {
+		t.Errorf("old path after move = %d", status)
+	}
+
+	res = pushVault(t, h,
+		handler.VaultChange{Path: "A/Renamed.md", Deleted: true, ETag: "stale"},
+		handler.VaultChange{Path: "B/Moved.md", Deleted: true},
+		handler.VaultChange{Path: "B/Moved.md", Deleted: true},
+		handler.VaultChange{Path: "B/Moved.md", Content: text("back"), ETag: res[1].ETag},
+	)
+	want := []string{handler.VaultConflict, handler.VaultApplied, handler.VaultApplied, handler.VaultConflict}
+	for i, r := range res {
+		if r.Status != want[i] {
+			t.Errorf("change %d = %+v, want %s", i, r, want[i])
+		}
+	}
+}
+
+func TestVault_AmbiguousPath(t *testing.T) {
+	provider := memory.NewProvider(nil, nil)
+	storage, _ := provider.GetAdapter(context.Background(), testUserID)
+	storage.CreateFile(context.Background(), "Twin.md", []byte("one"), "")
+	storage.CreateFile(context.Background(), "Twin.md", []byte("two"), "")
+	h := handler.NewNoteHandler(provider, "test-secret")
+
+	if m := getVault(t, h); !slices.Equal(m.Ambiguous, []string{"Twin.md"}) || len(m.Notes) != 0 {
+		t.Errorf("manifest = %+v", m)
+	}
+	if status, _ := getByPath(h, "Twin.md"); status != http.StatusConflict {
+		t.Errorf("by-path of an ambiguous path = %d", status)
+	}
+	if res := pushVault(t, h, handler.VaultChange{Path: "Twin.md", Content: text("three")}); res[0].Status != handler.VaultConflict {
+		t.Errorf("push to an ambiguous path = %+v", res)
+	}
+}
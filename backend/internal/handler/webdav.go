@@ -0,0 +1,530 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"golang.org/x/net/webdav"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// WebDAVHandler serves the user's notes over WebDAV, so editors and file
+// managers can mount them as a folder of Markdown files laid out as in a
+// vault (see vault.go). It authenticates with personal access tokens, sent
+// as the password of HTTP Basic authentication or as a bearer token.
+//
+// It needs a long-lived server for WebDAV's methods and locks, so it is
+// only served when the app runs over net/http (see app.Handler).
+type WebDAVHandler struct {
+	notes    *NoteHandler
+	tokens   *AccessTokenHandler
+	prefix   string
+	maxBytes int64
+
+	mu    sync.Mutex
+	locks map[string]webdav.LockSystem // by user ID
+}
+
+// NewWebDAVHandler creates a WebDAVHandler serving the notes of notes under
+// prefix, such as "/dav". Uploads are limited to maxBytes.
+func NewWebDAVHandler(notes *NoteHandler, tokens *AccessTokenHandler, prefix string, maxBytes int64) *WebDAVHandler {
+	return &WebDAVHandler{notes: notes, tokens: tokens, prefix: prefix, maxBytes: maxBytes, locks: make(map[string]webdav.LockSystem)}
+}
+
+// lockSystem returns the WebDAV locks of userID. Each user has their own,
+// since lock roots are paths and every user's tree starts at "/".
+func (h *WebDAVHandler) lockSystem(userID string) webdav.LockSystem {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ls, ok := h.locks[userID]
+	if !ok {
+		ls = webdav.NewMemLS()
+		h.locks[userID] = ls
+	}
+	return ls
+}
+
+func (h *WebDAVHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	}
+	claims, err := h.tokens.Authenticate(r.Context(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Authorization": "Bearer " + token},
+	})
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="GophDrive", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx := WithUserClaims(r.Context(), claims)
+	storage, err := h.notes.getStorageAdapter(ctx, events.APIGatewayProxyRequest{})
+	if err != nil {
+		slog.WarnContext(ctx, "WebDAV GetAdapter failed", "error", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+	dav := &webdav.Handler{
+		Prefix:     h.prefix,
+		FileSystem: &davFS{notes: h.notes, storage: storage, lists: make(map[string][]adapter.FileMetadata)},
+		LockSystem: h.lockSystem(claims.UserID),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				slog.InfoContext(r.Context(), "WebDAV request failed", "method", r.Method, "error", err)
+			}
+		},
+	}
+	dav.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// errAmbiguous is returned for paths of more than one note or folder.
+var errAmbiguous = fmt.Errorf("%w: %w", fs.ErrPermission, ErrAmbiguousPath)
+
+// davFS is a webdav.FileSystem over a user's storage for one request.
+// Folder listings are cached for the request, since WebDAV clients stat
+// each entry of a listing.
+type davFS struct {
+	notes   *NoteHandler
+	storage adapter.StorageAdapter
+	lists   map[string][]adapter.FileMetadata // by folder ID
+}
+
+// davEntry is a resolved path. The base folder is the root, with an empty
+// item.
+type davEntry struct {
+	item     adapter.FileMetadata
+	parentID string
+	root     bool
+}
+
+func (e davEntry) isDir() bool { return e.root || e.item.MIMEType == folderMIMEType }
+
+// davName returns the file name of item: a folder's name, or a note's
+// with .md.
+func davName(item adapter.FileMetadata) string {
+	if item.MIMEType == folderMIMEType {
+		return item.Name
+	}
+	return item.Name + vaultExt
+}
+
+func (f *davFS) list(ctx context.Context, folderID string) ([]adapter.FileMetadata, error) {
+	if files, ok := f.lists[folderID]; ok {
+		return files, nil
+	}
+	files, err := f.storage.ListFiles(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	f.lists[folderID] = files
+	return files, nil
+}
+
+// changed forgets the cached listings after a write.
+func (f *davFS) changed() { clear(f.lists) }
+
+func (f *davFS) resolve(ctx context.Context, name string) (davEntry, error) {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return davEntry{root: true}, nil
+	}
+	var e davEntry
+	parentID := ""
+	segments := strings.Split(name[1:], "/")
+	for i, seg := range segments {
+		files, err := f.list(ctx, parentID)
+		if err != nil {
+			return davEntry{}, err
+		}
+		var matches []adapter.FileMetadata
+		for _, item := range files {
+			if davName(item) == seg {
+				matches = append(matches, item)
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			return davEntry{}, fs.ErrNotExist
+		case len(matches) > 1:
+			return davEntry{}, errAmbiguous
+		}
+		e = davEntry{item: matches[0], parentID: parentID}
+		if i < len(segments)-1 && !e.isDir() {
+			return davEntry{}, fs.ErrNotExist
+		}
+		parentID = e.item.ID
+	}
+	return e, nil
+}
+
+// parent returns the ID of the folder that name would be created in and
+// the new item's file name, which must not be hidden and, for notes, must
+// end in .md. Other files, such as those file managers leave behind, are
+// refused, since only notes can be stored.
+func (f *davFS) parent(ctx context.Context, name string, dir bool) (string, string, error) {
+	name = path.Clean("/" + name)
+	base := path.Base(name)
+	if name == "/" || strings.HasPrefix(base, ".") || (!dir && (!strings.HasSuffix(base, vaultExt) || base == vaultExt)) {
+		return "", "", fs.ErrPermission
+	}
+	p, err := f.resolve(ctx, path.Dir(name))
+	if err != nil {
+		return "", "", err
+	}
+	if !p.isDir() {
+		return "", "", fs.ErrNotExist
+	}
+	return p.item.ID, base, nil
+}
+
+// checkLock refuses writes to notes another user is editing.
+func (f *davFS) checkLock(ctx context.Context, id string) error {
+	if resp := f.notes.checkEditLock(ctx, events.APIGatewayProxyRequest{}, id); resp != nil {
+		return fmt.Errorf("%w: note is locked", fs.ErrPermission)
+	}
+	return nil
+}
+
+func (f *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	e, err := f.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return newDavInfo(e, path.Base(path.Clean("/"+name))), nil
+}
+
+func (f *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	e, err := f.resolve(ctx, name)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		info := newDavInfo(e, path.Base(path.Clean("/"+name)))
+		if e.isDir() {
+			return &davDir{fs: f, ctx: ctx, id: e.item.ID, info: info}, nil
+		}
+		file, err := f.storage.GetFile(ctx, e.item.ID)
+		if err != nil {
+			return nil, davError(err)
+		}
+		return &davNote{Reader: bytes.NewReader(file.Content), info: info}, nil
+	}
+
+	switch {
+	case err == nil && e.isDir():
+		return nil, fs.ErrPermission
+	case err == nil:
+		if flag&os.O_EXCL != 0 {
+			return nil, fs.ErrExist
+		}
+		if err := f.checkLock(ctx, e.item.ID); err != nil {
+			return nil, err
+		}
+		return &davWriter{fs: f, ctx: ctx, note: &e.item, info: newDavInfo(e, davName(e.item))}, nil
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+		parentID, base, err := f.parent(ctx, name, false)
+		if err != nil {
+			return nil, err
+		}
+		return &davWriter{fs: f, ctx: ctx, parentID: parentID, info: &davInfo{name: base, modTime: time.Now()}}, nil
+	default:
+		return nil, err
+	}
+}
+
+func (f *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if _, err := f.resolve(ctx, name); err == nil {
+		return fs.ErrExist
+	}
+	parentID, base, err := f.parent(ctx, name, true)
+	if err != nil {
+		return err
+	}
+	var parents []string
+	if parentID != "" {
+		parents = []string{parentID}
+	}
+	if _, err := f.storage.CreateFolder(ctx, base, parents); err != nil {
+		return davError(err)
+	}
+	f.changed()
+	return nil
+}
+
+func (f *davFS) RemoveAll(ctx context.Context, name string) error {
+	e, err := f.resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	if e.root {
+		return fs.ErrPermission
+	}
+	// A folder is deleted only if none of the notes it takes with it is
+	// locked by someone else; they are cleaned up after.
+	var gone []string
+	if e.isDir() {
+		queue := []string{e.item.ID}
+		for len(queue) > 0 {
+			files, err := f.storage.ListFiles(ctx, queue[0])
+			if err != nil {
+				return davError(err)
+			}
+			queue = queue[1:]
+			for _, item := range files {
+				if item.MIMEType == folderMIMEType {
+					queue = append(queue, item.ID)
+				} else {
+					gone = append(gone, item.ID)
+				}
+			}
+		}
+	} else {
+		gone = []string{e.item.ID}
+	}
+	for _, id := range gone {
+		if err := f.checkLock(ctx, id); err != nil {
+			return err
+		}
+	}
+	if err := f.storage.DeleteFile(ctx, e.item.ID); err != nil {
+		return davError(err)
+	}
+	for _, id := range gone {
+		f.notes.forgetNote(ctx, events.APIGatewayProxyRequest{}, id)
+	}
+	f.changed()
+	return nil
+}
+
+// Rename renames or moves a note, or renames a folder. A rename keeps the
+// note's ID; the storage adapters cannot move items between folders, so a
+// moved note is recreated, as in PushVault, and folders cannot be moved.
+func (f *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	src, err := f.resolve(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	if src.root {
+		return fs.ErrPermission
+	}
+	if _, err := f.resolve(ctx, newName); err == nil {
+		return fs.ErrExist
+	}
+	parentID, base, err := f.parent(ctx, newName, src.isDir())
+	if err != nil {
+		return err
+	}
+	if !src.isDir() {
+		if err := f.checkLock(ctx, src.item.ID); err != nil {
+			return err
+		}
+	}
+	defer f.changed()
+
+	name := base
+	if !src.isDir() {
+		name = strings.TrimSuffix(base, vaultExt)
+	}
+	if parentID == src.parentID {
+		_, err := f.storage.RenameFile(ctx, src.item.ID, name)
+		return davError(err)
+	}
+	if src.isDir() {
+		return fs.ErrPermission
+	}
+
+	file, err := f.storage.GetFile(ctx, src.item.ID)
+	if err != nil {
+		return davError(err)
+	}
+	created, err := f.storage.CreateFile(ctx, name, file.Content, parentID)
+	if err != nil {
+		return davError(err)
+	}
+	f.notes.indexReminders(ctx, events.APIGatewayProxyRequest{}, created, string(file.Content))
+	if err := f.storage.DeleteFile(ctx, src.item.ID); err != nil {
+		return davError(err)
+	}
+	f.notes.forgetNote(ctx, events.APIGatewayProxyRequest{}, src.item.ID)
+	return nil
+}
+
+// davError maps storage errors to the fs errors the WebDAV handler turns
+// into statuses.
+func davError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, adapter.ErrNotFound):
+		return fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+	case errors.Is(err, adapter.ErrLimitExceeded), errors.Is(err, adapter.ErrPreconditionFailed):
+		return fmt.Errorf("%w: %w", fs.ErrPermission, err)
+	}
+	return err
+}
+
+// davInfo describes a note or folder. It reports the storage ETag and the
+// content type of notes, sparing the WebDAV handler from reading them.
+type davInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+	etag    string
+}
+
+func newDavInfo(e davEntry, name string) *davInfo {
+	if e.root {
+		return &davInfo{name: "/", dir: true}
+	}
+	return &davInfo{name: name, size: e.item.Size, modTime: e.item.ModifiedTime, dir: e.isDir(), etag: e.item.ETag}
+}
+
+func (i *davInfo) Name() string       { return i.name }
+func (i *davInfo) Size() int64        { return i.size }
+func (i *davInfo) ModTime() time.Time { return i.modTime }
+func (i *davInfo) IsDir() bool        { return i.dir }
+func (i *davInfo) Sys() any           { return nil }
+
+func (i *davInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func (i *davInfo) ETag(ctx context.Context) (string, error) {
+	if i.etag == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + i.etag + `"`, nil
+}
+
+func (i *davInfo) ContentType(ctx context.Context) (string, error) {
+	if i.dir {
+		return "", webdav.ErrNotImplemented
+	}
+	return "text/markdown; charset=utf-8", nil
+}
+
+// davNote is a note opened for reading.
+type davNote struct {
+	*bytes.Reader
+	info *davInfo
+}
+
+func (n *davNote) Close() error               { return nil }
+func (n *davNote) Stat() (fs.FileInfo, error) { return n.info, nil }
+func (n *davNote) Write([]byte) (int, error)  { return 0, fs.ErrPermission }
+func (n *davNote) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, errors.New("not a directory")
+}
+
+// davDir is a folder opened for listing. Paths held by more than one item
+// are left out.
+type davDir struct {
+	fs      *davFS
+	ctx     context.Context
+	id      string
+	info    *davInfo
+	entries []fs.FileInfo
+	loaded  bool
+}
+
+func (d *davDir) Close() error                                 { return nil }
+func (d *davDir) Stat() (fs.FileInfo, error)                   { return d.info, nil }
+func (d *davDir) Read([]byte) (int, error)                     { return 0, errors.New("is a directory") }
+func (d *davDir) Write([]byte) (int, error)                    { return 0, fs.ErrPermission }
+func (d *davDir) Seek(offset int64, whence int) (int64, error) { return 0, nil }
+
+func (d *davDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.loaded {
+		d.loaded = true
+		files, err := d.fs.list(d.ctx, d.id)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]int)
+		for _, item := range files {
+			seen[davName(item)]++
+		}
+		for _, item := range files {
+			if name := davName(item); seen[name] == 1 && !strings.Contains(item.Name, "/") {
+				d.entries = append(d.entries, newDavInfo(davEntry{item: item}, name))
+			}
+		}
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// davWriter buffers an upload and saves it as a note on Close.
+type davWriter struct {
+	fs       *davFS
+	ctx      context.Context
+	note     *adapter.FileMetadata // nil for a new note
+	parentID string
+	info     *davInfo
+	buf      bytes.Buffer
+}
+
+func (w *davWriter) Write(p []byte) (int, error) {
+	n, err := w.buf.Write(p)
+	w.info.size = int64(w.buf.Len())
+	return n, err
+}
+
+func (w *davWriter) Stat() (fs.FileInfo, error)                   { return w.info, nil }
+func (w *davWriter) Read([]byte) (int, error)                     { return 0, fs.ErrPermission }
+func (w *davWriter) Seek(offset int64, whence int) (int64, error) { return 0, fs.ErrPermission }
+func (w *davWriter) Readdir(int) ([]fs.FileInfo, error)           { return nil, errors.New("not a directory") }
+
+// Close saves the note and updates the info returned by Stat, from which
+// the WebDAV handler reads the new ETag.
+func (w *davWriter) Close() error {
+	ctx, content := w.ctx, w.buf.Bytes()
+	var (
+		file *adapter.FileMetadata
+		err  error
+	)
+	if w.note != nil {
+		file, err = w.fs.storage.SaveFile(ctx, w.note.ID, content, "")
+		if err == nil {
+			w.fs.notes.discardDraft(ctx, events.APIGatewayProxyRequest{}, file.ID)
+		}
+	} else {
+		file, err = w.fs.storage.CreateFile(ctx, strings.TrimSuffix(w.info.name, vaultExt), content, w.parentID)
+	}
+	if err != nil {
+		return davError(err)
+	}
+	w.fs.notes.indexReminders(ctx, events.APIGatewayProxyRequest{}, file, string(content))
+	w.fs.changed()
+	w.info.etag, w.info.modTime = file.ETag, file.ModifiedTime
+	return nil
+}
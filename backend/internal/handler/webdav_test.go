@@ -0,0 +1,168 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jun/gophdrive/backend/internal/accesstoken"
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+	"github.com/jun/gophdrive/backend/internal/crypto"
+	"github.com/jun/gophdrive/backend/internal/handler"
+	"github.com/jun/gophdrive/backend/internal/secret"
+	"github.com/jun/gophdrive/backend/internal/session"
+)
+
+// newWebDAVServer serves the test user's notes over WebDAV, enforcing the
+// editing locks of the returned locker, and returns a function making
+// authenticated requests to it.
+func newWebDAVServer(t *testing.T) (adapter.StorageAdapter, *session.MockLocker, func(method, path, body string, headers ...string) *http.Response) {
+	t.Helper()
+	ctx := context.Background()
+	provider := memory.NewProvider(nil, nil)
+	storage, _ := provider.GetAdapter(ctx, testUserID)
+	notes := handler.NewNoteHandler(provider, "test-secret")
+	locker := session.NewMockLocker()
+	notes.EnableLockEnforcement(locker)
+	tokens := handler.NewAccessTokenHandler(accesstoken.NewStore(nil, ""), crypto.NewSigner(secret.Static("signing-key")), "test-secret")
+	resp, _ := tokens.CreateAccessToken(ctx, makeRequest("POST", "/auth/tokens", `{"name":"webdav"}`))
+	var created handler.CreatedAccessToken
+	json.Unmarshal([]byte(resp.Body), &created)
+
+	srv := httptest.NewServer(handler.NewWebDAVHandler(notes, tokens, "/dav", 1<<20))
+	t.Cleanup(srv.Close)
+	do := func(method, path, body string, headers ...string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.SetBasicAuth("me", created.Secret)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	return storage, locker, do
+}
+
+func readBody(resp *http.Response) string {
+	b, _ := io.ReadAll(resp.Body)
+	return string(b)
+}
+
+func TestWebDAV_Authentication(t *testing.T) {
+	_, _, do := newWebDAVServer(t)
+	if resp := do("PROPFIND", "/dav/", "", "Depth", "0"); resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND with a token = %d", resp.StatusCode)
+	}
+	resp := do("PROPFIND", "/dav/", "", "Authorization", "Bearer gdp_not-a-token", "Depth", "0")
+	if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Basic") {
+		t.Errorf("PROPFIND with a bad token = %d %q", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func TestWebDAV_Files(t *testing.T) {
+	storage, _, do := newWebDAVServer(t)
+	ctx := context.Background()
+
+	if resp := do("MKCOL", "/dav/Work", ""); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("MKCOL = %d", resp.StatusCode)
+	}
+	resp := do("PUT", "/dav/Work/Plan.md", "# Plan\n")
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("ETag") == "" {
+		t.Fatalf("PUT = %d, ETag %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	if resp := do("GET", "/dav/Work/Plan.md", ""); readBody(resp) != "# Plan\n" {
+		t.Errorf("GET = %d", resp.StatusCode)
+	}
+
+	resp = do("PROPFIND", "/dav/Work/", "", "Depth", "1")
+	body := readBody(resp)
+	if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "/dav/Work/Plan.md") || !strings.Contains(body, "text/markdown") {
+		t.Errorf("PROPFIND = %d %s", resp.StatusCode, body)
+	}
+
+	// Uploads replace the note's content in place.
+	files, _ := storage.ListFiles(ctx, "")
+	folderID := files[0].ID
+	files, _ = storage.ListFiles(ctx, folderID)
+	if len(files) != 1 || files[0].Name != "Plan" {
+		t.Fatalf("files in Work = %+v", files)
+	}
+	id := files[0].ID
+	if resp := do("PUT", "/dav/Work/Plan.md", "# Plan v2\n"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("second PUT = %d", resp.StatusCode)
+	}
+	if f, _ := storage.GetFile(ctx, id); string(f.Content) != "# Plan v2\n" {
+		t.Errorf("content after PUT = %q", f.Content)
+	}
+
+	// Only notes can be stored, in folders that exist.
+	if resp := do("PUT", "/dav/photo.png", "png"); resp.StatusCode < 400 {
+		t.Errorf("PUT of a PNG = %d", resp.StatusCode)
+	}
+	if resp := do("PUT", "/dav/._Plan.md", "resource fork"); resp.StatusCode < 400 {
+		t.Errorf("PUT of a hidden file = %d", resp.StatusCode)
+	}
+	if resp := do("PUT", "/dav/Missing/Note.md", "x"); resp.StatusCode != http.StatusConflict {
+		t.Errorf("PUT into a missing folder = %d", resp.StatusCode)
+	}
+}
+
+func TestWebDAV_MoveAndDelete(t *testing.T) {
+	storage, _, do := newWebDAVServer(t)
+	ctx := context.Background()
+	note, _ := storage.CreateFile(ctx, "Draft.md", []byte("text"), "")
+	id := note.ID
+	do("MKCOL", "/dav/Archive", "")
+
+	// A rename keeps the note.
+	resp := do("MOVE", "/dav/Draft.md", "", "Destination", "/dav/Final.md")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("MOVE = %d", resp.StatusCode)
+	}
+	if f, err := storage.GetFile(ctx, id); err != nil || f.Name != "Final" {
+		t.Errorf("renamed note = %+v, %v", f, err)
+	}
+
+	// A move to another folder recreates it there.
+	if resp := do("MOVE", "/dav/Final.md", "", "Destination", "/dav/Archive/Final.md"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("MOVE to a folder = %d", resp.StatusCode)
+	}
+	if resp := do("GET", "/dav/Archive/Final.md", ""); readBody(resp) != "text" {
+		t.Errorf("GET of the moved note = %d", resp.StatusCode)
+	}
+	if _, err := storage.GetFile(ctx, id); err == nil {
+		t.Error("the original of the moved note still exists")
+	}
+
+	if resp := do("DELETE", "/dav/Archive/Final.md", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE = %d", resp.StatusCode)
+	}
+	if resp := do("GET", "/dav/Archive/Final.md", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after DELETE = %d", resp.StatusCode)
+	}
+}
+
+func TestWebDAV_DeleteFolderWithLockedNote(t *testing.T) {
+	storage, locker, do := newWebDAVServer(t)
+	ctx := context.Background()
+	folder, _ := storage.CreateFolder(ctx, "Shared", nil)
+	note, _ := storage.CreateFile(ctx, "Plan.md", []byte("text"), folder.ID)
+	locker.AcquireLock(ctx, note.ID, "other-user", session.HolderInfo{})
+
+	if resp := do("DELETE", "/dav/Shared", ""); resp.StatusCode < 400 {
+		t.Errorf("DELETE of a folder with a locked note = %d, want an error", resp.StatusCode)
+	}
+	if _, err := storage.GetFile(ctx, note.ID); err != nil {
+		t.Errorf("locked note was deleted: %v", err)
+	}
+}
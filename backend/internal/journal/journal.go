@@ -0,0 +1,166 @@
+// Package journal records per-user note modifications so clients can poll for
+// changes instead of re-listing folders.
+package journal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Retention is how long journal entries are kept before DynamoDB TTL removes them.
+// Clients whose cursor is older than this should fall back to a full listing.
+const Retention = 30 * 24 * time.Hour
+
+// CursorLag is how far behind the current time the cursor handed back to
+// clients is. Cursors are the clocks of whichever instances recorded the
+// changes, so a change can be stored after, yet with a lower cursor than,
+// one a client has already seen; re-reading the last CursorLag on each
+// poll catches it, at the cost of some changes being reported twice.
+const CursorLag = time.Minute
+
+// ChangeType describes what happened to a note.
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+	ChangeRenamed ChangeType = "renamed"
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// Change is a single journal entry. Cursor increases monotonically per user.
+type Change struct {
+	UserID    string     `json:"-" dynamodbav:"user_id"`
+	Cursor    int64      `json:"cursor" dynamodbav:"seq"`
+	NoteID    string     `json:"note_id" dynamodbav:"note_id"`
+	Type      ChangeType `json:"type" dynamodbav:"change_type"`
+	ExpiresAt int64      `json:"-" dynamodbav:"expires_at"`
+}
+
+// Store persists journal entries.
+// If client is nil, it uses an in-memory map (for tests).
+type Store struct {
+	client    *dynamodb.Client
+	tableName string
+
+	// Fallback for tests
+	entries map[string][]Change
+	last    int64
+	mu      sync.Mutex
+}
+
+// NewStore creates a new journal Store.
+func NewStore(client *dynamodb.Client, tableName string) *Store {
+	return &Store{
+		client:    client,
+		tableName: tableName,
+		entries:   make(map[string][]Change),
+	}
+}
+
+// nextCursor returns a nanosecond timestamp that is strictly increasing within this process.
+func (s *Store) nextCursor() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UnixNano()
+	if now <= s.last {
+		now = s.last + 1
+	}
+	s.last = now
+	return now
+}
+
+// Record appends a change for the user.
+func (s *Store) Record(ctx context.Context, userID, noteID string, changeType ChangeType) error {
+	change := Change{
+		UserID:    userID,
+		Cursor:    s.nextCursor(),
+		NoteID:    noteID,
+		Type:      changeType,
+		ExpiresAt: time.Now().Add(Retention).Unix(),
+	}
+
+	if s.client == nil {
+		s.mu.Lock()
+		s.entries[userID] = append(s.entries[userID], change)
+		s.mu.Unlock()
+		return nil
+	}
+
+	item, err := attributevalue.MarshalMap(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal change: %w", err)
+	}
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record change: %w", err)
+	}
+	return nil
+}
+
+// Since returns the latest change per note recorded after cursor, oldest first.
+func (s *Store) Since(ctx context.Context, userID string, cursor int64) ([]Change, error) {
+	var all []Change
+	if s.client == nil {
+		s.mu.Lock()
+		for _, c := range s.entries[userID] {
+			if c.Cursor > cursor {
+				all = append(all, c)
+			}
+		}
+		s.mu.Unlock()
+	} else {
+		paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+			TableName:              aws.String(s.tableName),
+			KeyConditionExpression: aws.String("user_id = :user_id AND seq > :seq"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":user_id": &types.AttributeValueMemberS{Value: userID},
+				":seq":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", cursor)},
+			},
+		})
+		for paginator.HasMorePages() {
+			out, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query journal: %w", err)
+			}
+			var page []Change
+			if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal changes: %w", err)
+			}
+			all = append(all, page...)
+		}
+	}
+
+	return coalesce(all), nil
+}
+
+// coalesce keeps only the most recent change per note.
+// A note created and later updated in the window is still reported as created.
+func coalesce(changes []Change) []Change {
+	latest := make(map[string]Change)
+	for _, c := range changes {
+		prev, ok := latest[c.NoteID]
+		if ok && prev.Type == ChangeCreated && c.Type != ChangeDeleted {
+			c.Type = ChangeCreated
+		}
+		latest[c.NoteID] = c
+	}
+
+	result := make([]Change, 0, len(latest))
+	for _, c := range latest {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Cursor < result[j].Cursor })
+	return result
+}
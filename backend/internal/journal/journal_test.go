@@ -0,0 +1,37 @@
+package journal
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStore_SinceCoalesces(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+
+	s.Record(ctx, "user1", "a", ChangeCreated)
+	s.Record(ctx, "user1", "a", ChangeUpdated)
+	s.Record(ctx, "user1", "b", ChangeUpdated)
+	s.Record(ctx, "user2", "c", ChangeUpdated)
+	s.Record(ctx, "user1", "b", ChangeDeleted)
+
+	changes, err := s.Since(ctx, "user1", 0)
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 coalesced changes, got %d: %+v", len(changes), changes)
+	}
+	if changes[0].NoteID != "a" || changes[0].Type != ChangeCreated {
+		t.Errorf("Expected a/created first, got %+v", changes[0])
+	}
+	if changes[1].NoteID != "b" || changes[1].Type != ChangeDeleted {
+		t.Errorf("Expected b/deleted second, got %+v", changes[1])
+	}
+
+	// Nothing new after the latest cursor
+	changes, _ = s.Since(ctx, "user1", changes[1].Cursor)
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes after latest cursor, got %+v", changes)
+	}
+}
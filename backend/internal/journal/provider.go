@@ -0,0 +1,82 @@
+package journal
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// Provider wraps a StorageProvider so that every note mutation is journaled.
+type Provider struct {
+	adapter.StorageProvider
+	store *Store
+}
+
+// NewProvider creates a journaling StorageProvider.
+func NewProvider(provider adapter.StorageProvider, store *Store) *Provider {
+	return &Provider{StorageProvider: provider, store: store}
+}
+
+// GetAdapter returns the wrapped adapter for userID with journaling applied.
+func (p *Provider) GetAdapter(ctx context.Context, userID string) (adapter.StorageAdapter, error) {
+	storage, err := p.StorageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &journalingAdapter{StorageAdapter: storage, store: p.store, userID: userID}, nil
+}
+
+// journalingAdapter records successful mutations. Journal failures are logged
+// rather than failing the write, since the storage change already happened.
+type journalingAdapter struct {
+	adapter.StorageAdapter
+	store  *Store
+	userID string
+}
+
+func (a *journalingAdapter) record(ctx context.Context, noteID string, changeType ChangeType) {
+	if err := a.store.Record(ctx, a.userID, noteID, changeType); err != nil {
+		slog.ErrorContext(ctx, "Journal failed", "error", err)
+	}
+}
+
+func (a *journalingAdapter) SaveFile(ctx context.Context, fileID string, content []byte, etag string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.SaveFile(ctx, fileID, content, etag)
+	if err == nil {
+		a.record(ctx, fileID, ChangeUpdated)
+	}
+	return meta, err
+}
+
+func (a *journalingAdapter) CreateFile(ctx context.Context, name string, content []byte, folderID string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.CreateFile(ctx, name, content, folderID)
+	if err == nil {
+		a.record(ctx, meta.ID, ChangeCreated)
+	}
+	return meta, err
+}
+
+func (a *journalingAdapter) DeleteFile(ctx context.Context, fileID string) error {
+	err := a.StorageAdapter.DeleteFile(ctx, fileID)
+	if err == nil {
+		a.record(ctx, fileID, ChangeDeleted)
+	}
+	return err
+}
+
+func (a *journalingAdapter) DuplicateFile(ctx context.Context, fileID string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.DuplicateFile(ctx, fileID)
+	if err == nil {
+		a.record(ctx, meta.ID, ChangeCreated)
+	}
+	return meta, err
+}
+
+func (a *journalingAdapter) RenameFile(ctx context.Context, fileID string, newName string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.RenameFile(ctx, fileID, newName)
+	if err == nil {
+		a.record(ctx, fileID, ChangeRenamed)
+	}
+	return meta, err
+}
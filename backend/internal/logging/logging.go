@@ -0,0 +1,96 @@
+// Package logging configures the backend's structured logger.
+//
+// Logs go through log/slog: JSON in Lambda, where CloudWatch can query the
+// fields, and text in DEV_MODE. Request-scoped fields (request ID, route,
+// hashed user ID) are attached to the context by the app middleware and
+// added to every record logged with that context, so handlers only need
+// slog.ErrorContext(ctx, ...).
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Setup installs the default logger: text when devMode is set, JSON
+// otherwise. Output goes to stdout, which Lambda forwards to CloudWatch.
+func Setup(devMode bool) {
+	slog.SetDefault(New(os.Stdout, devMode))
+}
+
+// New returns a logger writing to w that includes request fields from the
+// context.
+func New(w io.Writer, devMode bool) *slog.Logger {
+	var h slog.Handler
+	if devMode {
+		h = slog.NewTextHandler(w, nil)
+	} else {
+		h = slog.NewJSONHandler(w, nil)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// HashUserID returns a short, stable pseudonym for a user ID so log lines
+// can be correlated per user without recording the ID itself.
+func HashUserID(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:6])
+}
+
+// fields holds the request-scoped attributes. It is shared by pointer so
+// middleware further in (routing, authentication) can add attributes that
+// the outer request log line also reports.
+type fields struct {
+	attrs []slog.Attr
+}
+
+type fieldsKey struct{}
+
+// WithRequest returns a context that carries request-scoped log fields,
+// starting with attrs.
+func WithRequest(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, &fields{attrs: attrs})
+}
+
+// AddAttrs adds attributes to the request fields in ctx, replacing any with
+// the same key. It does nothing if ctx has no request fields.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return
+	}
+outer:
+	for _, a := range attrs {
+		for i := range f.attrs {
+			if f.attrs[i].Key == a.Key {
+				f.attrs[i] = a
+				continue outer
+			}
+		}
+		f.attrs = append(f.attrs, a)
+	}
+}
+
+// contextHandler adds the request fields from the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if f, ok := ctx.Value(fieldsKey{}).(*fields); ok {
+		r.AddAttrs(f.attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
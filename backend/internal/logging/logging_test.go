@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_JSONIncludesRequestFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, false)
+
+	ctx := WithRequest(context.Background(), slog.String("request_id", "r1"))
+	AddAttrs(ctx, slog.String("route", "GET /notes/{id}"))
+	AddAttrs(ctx, slog.String("route", "GET /notes"))
+	logger.ErrorContext(ctx, "list files failed", "error", "boom")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("output is not JSON: %q", buf.String())
+	}
+	want := map[string]any{"msg": "list files failed", "level": "ERROR", "error": "boom", "request_id": "r1", "route": "GET /notes"}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+}
+
+func TestNew_TextInDevMode(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, true).Info("started", "port", 8080)
+	if !strings.Contains(buf.String(), "msg=started port=8080") {
+		t.Errorf("output = %q, want text format", buf.String())
+	}
+}
+
+func TestAddAttrs_WithoutRequestFields(t *testing.T) {
+	// Must not panic outside a request.
+	AddAttrs(context.Background(), slog.String("k", "v"))
+}
+
+func TestHashUserID(t *testing.T) {
+	a, b := HashUserID("user-1"), HashUserID("user-2")
+	if a == b || len(a) != 12 || a != HashUserID("user-1") {
+		t.Errorf("HashUserID = %q, %q", a, b)
+	}
+	if strings.Contains(a, "user") {
+		t.Error("hash must not contain the user ID")
+	}
+}
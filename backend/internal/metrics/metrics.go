@@ -0,0 +1,208 @@
+// Package metrics emits CloudWatch metrics in the Embedded Metric Format
+// (EMF): JSON lines on stdout that CloudWatch Logs turns into metrics, so
+// no API calls or agents are needed on Lambda.
+//
+// Metrics are collected per request. Middleware starts a set with
+// Recorder.WithRequest, code further in adds to it with Add, and the set is
+// written as one EMF record when the request ends. Outside a request set,
+// Add does nothing.
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Namespace is the default CloudWatch namespace.
+const Namespace = "GophDrive"
+
+// Metric names.
+const (
+	// Requests, Latency, Errors (5xx) and ClientErrors (4xx) are recorded
+	// for every request. Errors and ClientErrors are 0 or 1, so their
+	// average is the error rate.
+	Requests     = "Requests"
+	Latency      = "Latency"
+	Errors       = "Errors"
+	ClientErrors = "ClientErrors"
+
+	// DriveCalls counts Google Drive API requests.
+	DriveCalls = "DriveCalls"
+	// LockContention counts lock requests refused because another user
+	// holds the lock.
+	LockContention = "LockContention"
+	// DemoLimitRejections counts demo writes refused by the demo limits.
+	DemoLimitRejections = "DemoLimitRejections"
+)
+
+// Unit is a CloudWatch metric unit.
+type Unit string
+
+const (
+	Count        Unit = "Count"
+	Milliseconds Unit = "Milliseconds"
+)
+
+// Recorder writes EMF records. A nil *Recorder records nothing.
+type Recorder struct {
+	mu        sync.Mutex
+	w         io.Writer
+	namespace string
+	now       func() time.Time
+}
+
+// New returns a Recorder writing records for namespace to w.
+func New(w io.Writer, namespace string) *Recorder {
+	return &Recorder{w: w, namespace: namespace, now: time.Now}
+}
+
+// set collects one request's metrics. Dimensions are also written as
+// properties, as EMF requires.
+type set struct {
+	mu         sync.Mutex
+	recorder   *Recorder
+	dimensions map[string]string
+	properties map[string]any
+	values     map[string]float64
+	units      map[string]Unit
+}
+
+type setKey struct{}
+
+// WithRequest returns a context that collects metrics for one request,
+// starting with the given dimension. It returns ctx unchanged if r is nil.
+func (r *Recorder) WithRequest(ctx context.Context, dimension, value string) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, setKey{}, &set{
+		recorder:   r,
+		dimensions: map[string]string{dimension: value},
+		properties: map[string]any{},
+		values:     map[string]float64{},
+		units:      map[string]Unit{},
+	})
+}
+
+func fromContext(ctx context.Context) *set {
+	s, _ := ctx.Value(setKey{}).(*set)
+	return s
+}
+
+// SetDimension replaces the value of a dimension started by WithRequest,
+// e.g. once the route is known.
+func SetDimension(ctx context.Context, dimension, value string) {
+	if s := fromContext(ctx); s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.dimensions[dimension]; ok {
+			s.dimensions[dimension] = value
+		}
+	}
+}
+
+// SetProperty adds a field that is logged with the record but is not a
+// metric or dimension, such as the request ID.
+func SetProperty(ctx context.Context, name string, value any) {
+	if s := fromContext(ctx); s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.properties[name] = value
+	}
+}
+
+// Add adds n to the count metric name.
+func Add(ctx context.Context, name string, n float64) {
+	if s := fromContext(ctx); s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.values[name] += n
+		s.units[name] = Count
+	}
+}
+
+// Put sets metric name to value.
+func Put(ctx context.Context, name string, value float64, unit Unit) {
+	if s := fromContext(ctx); s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.values[name] = value
+		s.units[name] = unit
+	}
+}
+
+// Flush writes the request's metrics as one EMF record. Metrics are
+// reported per dimension value and in aggregate.
+func Flush(ctx context.Context) error {
+	s := fromContext(ctx)
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.recorder
+
+	type metric struct {
+		Name string `json:"Name"`
+		Unit Unit   `json:"Unit"`
+	}
+	names := slices.Sorted(maps.Keys(s.values))
+	defs := make([]metric, 0, len(names))
+	for _, name := range names {
+		defs = append(defs, metric{Name: name, Unit: s.units[name]})
+	}
+	dims := slices.Sorted(maps.Keys(s.dimensions))
+
+	record := make(map[string]any, len(s.properties)+len(s.dimensions)+len(s.values)+1)
+	for k, v := range s.properties {
+		record[k] = v
+	}
+	for k, v := range s.dimensions {
+		record[k] = v
+	}
+	for k, v := range s.values {
+		record[k] = v
+	}
+	record["_aws"] = map[string]any{
+		"Timestamp": r.now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]any{{
+			"Namespace":  r.namespace,
+			"Dimensions": [][]string{dims, {}},
+			"Metrics":    defs,
+		}},
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(append(line, '\n'))
+	return err
+}
+
+// Transport wraps base so that each request made with it adds 1 to the
+// count metric name in the request's context.
+func Transport(base http.RoundTripper, name string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper{base: base, name: name}
+}
+
+type roundTripper struct {
+	base http.RoundTripper
+	name string
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	Add(req.Context(), t.name, 1)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlush_WritesEMFRecord(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(&buf, "Test")
+	r.now = func() time.Time { return time.UnixMilli(1700000000000) }
+
+	ctx := r.WithRequest(context.Background(), "Route", "unmatched")
+	SetDimension(ctx, "Route", "GET /notes")
+	SetDimension(ctx, "Other", "ignored")
+	SetProperty(ctx, "RequestId", "req-1")
+	Add(ctx, Requests, 1)
+	Add(ctx, DriveCalls, 2)
+	Add(ctx, DriveCalls, 3)
+	Put(ctx, Latency, 12.5, Milliseconds)
+	if err := Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("output is not one JSON record: %q", buf.String())
+	}
+	want := map[string]any{
+		"Route":     "GET /notes",
+		"RequestId": "req-1",
+		Requests:    float64(1),
+		DriveCalls:  float64(5),
+		Latency:     12.5,
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+	if _, ok := rec["Other"]; ok {
+		t.Error("SetDimension added a dimension WithRequest did not start")
+	}
+
+	aws := rec["_aws"].(map[string]any)
+	if aws["Timestamp"] != float64(1700000000000) {
+		t.Errorf("Timestamp = %v", aws["Timestamp"])
+	}
+	directive := aws["CloudWatchMetrics"].([]any)[0].(map[string]any)
+	if directive["Namespace"] != "Test" {
+		t.Errorf("Namespace = %v", directive["Namespace"])
+	}
+	if got, want := directive["Dimensions"], []any{[]any{"Route"}, []any{}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dimensions = %v, want %v", got, want)
+	}
+	wantMetrics := []any{
+		map[string]any{"Name": DriveCalls, "Unit": "Count"},
+		map[string]any{"Name": Latency, "Unit": "Milliseconds"},
+		map[string]any{"Name": Requests, "Unit": "Count"},
+	}
+	if got := directive["Metrics"]; !reflect.DeepEqual(got, wantMetrics) {
+		t.Errorf("Metrics = %v, want %v", got, wantMetrics)
+	}
+}
+
+func TestNilRecorderAndNoRequest(t *testing.T) {
+	var r *Recorder
+	ctx := r.WithRequest(context.Background(), "Route", "x")
+	Add(ctx, Requests, 1)
+	if err := Flush(ctx); err != nil {
+		t.Errorf("Flush without a request set = %v, want nil", err)
+	}
+}
+
+func TestTransport_CountsRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	ctx := New(&buf, "Test").WithRequest(context.Background(), "Route", "r")
+	client := &http.Client{Transport: Transport(nil, DriveCalls)}
+	for range 3 {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if err := Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec[DriveCalls] != float64(3) {
+		t.Errorf("DriveCalls = %v, want 3", rec[DriveCalls])
+	}
+}
@@ -0,0 +1,306 @@
+// Package mirror keeps a backup copy of every note in a second storage
+// provider, so notes survive an outage of the primary one or the loss of
+// the account.
+//
+// Each note written through a Provider gets an Entry, recorded as pending
+// before the write returns; the copy is then made in the background. Copies
+// that fail, or that never ran because the process stopped, are retried by
+// Reconcile. Notes are copied by name into one backup folder: the mirror is
+// a flat backup, not a replica of the folder tree, and deleting a note
+// keeps its copy.
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// Folder is the folder that holds the copies in each user's mirror storage.
+const Folder = "GophDrive Backup"
+
+const (
+	// copyTimeout bounds one background copy.
+	copyTimeout = time.Minute
+	// reconcileDelay is how long an entry stays pending before Reconcile
+	// retries it, so it does not race with the background copy.
+	reconcileDelay = time.Minute
+	// reconcileBatch bounds the copies one Reconcile makes.
+	reconcileBatch = 100
+)
+
+// Provider wraps a StorageProvider so that every note written is copied to
+// the target provider.
+type Provider struct {
+	adapter.StorageProvider
+	target adapter.StorageProvider
+	store  *Store
+
+	// locks serialize the copies of a note, so an older content cannot
+	// overwrite a newer one. A note's lock is chosen by hashing its key.
+	locks [64]sync.Mutex
+	wg    sync.WaitGroup
+}
+
+// NewProvider creates a mirroring StorageProvider.
+func NewProvider(provider, target adapter.StorageProvider, store *Store) *Provider {
+	return &Provider{StorageProvider: provider, target: target, store: store}
+}
+
+// GetAdapter returns the wrapped adapter for userID with mirroring applied.
+func (p *Provider) GetAdapter(ctx context.Context, userID string) (adapter.StorageAdapter, error) {
+	storage, err := p.StorageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &mirroringAdapter{StorageAdapter: storage, p: p, userID: userID}, nil
+}
+
+// Wait waits for the background copies started so far.
+func (p *Provider) Wait() {
+	p.wg.Wait()
+}
+
+func (p *Provider) lock(userID, noteID string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(userID + "/" + noteID))
+	mu := &p.locks[h.Sum32()%uint32(len(p.locks))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// enqueue marks a note pending and copies it in the background. content is
+// the note's new content, or nil if it has to be read back.
+func (p *Provider) enqueue(ctx context.Context, userID string, meta *adapter.FileMetadata, content []byte) {
+	if err := p.markPending(ctx, userID, meta, content); err != nil {
+		slog.ErrorContext(ctx, "Mirror failed", "error", err)
+		return
+	}
+	p.wg.Go(func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), copyTimeout)
+		defer cancel()
+		if _, err := p.copy(ctx, userID, meta.ID, content); err != nil {
+			slog.WarnContext(ctx, "Mirror copy failed; it will be retried", "note_id", meta.ID, "error", err)
+		}
+	})
+}
+
+func (p *Provider) markPending(ctx context.Context, userID string, meta *adapter.FileMetadata, content []byte) error {
+	defer p.lock(userID, meta.ID)()
+	e, err := p.store.Get(ctx, userID, meta.ID)
+	if errors.Is(err, ErrNotFound) {
+		e = &Entry{UserID: userID, NoteID: meta.ID}
+	} else if err != nil {
+		return err
+	}
+	if meta.Name != "" {
+		e.Name = meta.Name
+	}
+	if content != nil {
+		e.Hash = adapter.ContentHash(content)
+	} else {
+		e.Hash = meta.ContentHash
+	}
+	e.Status = StatusPending
+	e.Error = ""
+	e.UpdatedAt = time.Now().UTC()
+	return p.store.Put(ctx, *e)
+}
+
+// copy writes a note to the target if its entry is not synced, reporting
+// whether it did. content is used if it is the entry's latest content;
+// otherwise the note is read from the primary storage.
+func (p *Provider) copy(ctx context.Context, userID, noteID string, content []byte) (bool, error) {
+	defer p.lock(userID, noteID)()
+	e, err := p.store.Get(ctx, userID, noteID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil // deleted meanwhile
+	}
+	if err != nil {
+		return false, err
+	}
+	if e.Status == StatusSynced {
+		return false, nil
+	}
+
+	if content == nil || e.Hash == "" || adapter.ContentHash(content) != e.Hash {
+		storage, err := p.StorageProvider.GetAdapter(ctx, userID)
+		if err != nil {
+			return false, err
+		}
+		f, err := storage.GetFile(ctx, noteID)
+		if errors.Is(err, adapter.ErrNotFound) {
+			return false, p.store.Delete(ctx, userID, noteID)
+		}
+		if err != nil {
+			return false, p.fail(ctx, e, err)
+		}
+		content = f.Content
+		e.Name = f.Name
+		e.Hash = adapter.ContentHash(content)
+	}
+
+	if err := p.write(ctx, e, content); err != nil {
+		return false, p.fail(ctx, e, err)
+	}
+	now := time.Now().UTC()
+	e.Status = StatusSynced
+	e.Error = ""
+	e.Attempts = 0
+	e.UpdatedAt = now
+	e.MirroredAt = &now
+	return true, p.store.Put(ctx, *e)
+}
+
+// write brings the note's copy in the target up to date with e and
+// content, renaming it or creating it as needed, and records the copy in e.
+func (p *Provider) write(ctx context.Context, e *Entry, content []byte) error {
+	target, err := p.target.GetAdapter(ctx, e.UserID)
+	if err != nil {
+		return err
+	}
+	if e.MirrorID != "" && e.MirroredName != e.Name {
+		meta, err := target.RenameFile(ctx, e.MirrorID, e.Name)
+		switch {
+		case errors.Is(err, adapter.ErrNotFound):
+			e.MirrorID = ""
+		case err != nil:
+			return fmt.Errorf("rename copy: %w", err)
+		default:
+			e.MirrorID = meta.ID
+			e.MirroredName = e.Name
+		}
+	}
+	if e.MirrorID != "" {
+		if e.MirroredHash == e.Hash {
+			return nil
+		}
+		_, err := target.SaveFile(ctx, e.MirrorID, content, "")
+		if err == nil {
+			e.MirroredHash = e.Hash
+			return nil
+		}
+		if !errors.Is(err, adapter.ErrNotFound) {
+			return fmt.Errorf("save copy: %w", err)
+		}
+		// The copy was removed from the target; make a new one.
+	}
+
+	folderID, err := target.EnsureRootFolder(ctx, Folder)
+	if err != nil {
+		return fmt.Errorf("create backup folder: %w", err)
+	}
+	meta, err := target.CreateFile(ctx, e.Name, content, folderID)
+	if err != nil {
+		return fmt.Errorf("create copy: %w", err)
+	}
+	e.MirrorID = meta.ID
+	e.MirroredName = e.Name
+	e.MirroredHash = e.Hash
+	return nil
+}
+
+// fail records a failed copy in e and returns err.
+func (p *Provider) fail(ctx context.Context, e *Entry, err error) error {
+	e.Status = StatusFailed
+	e.Error = err.Error()
+	e.Attempts++
+	e.UpdatedAt = time.Now().UTC()
+	if perr := p.store.Put(ctx, *e); perr != nil {
+		return errors.Join(err, perr)
+	}
+	return err
+}
+
+// Reconcile retries the copies that are still pending or failed a minute
+// after they were last attempted, at most reconcileBatch of them. It
+// returns how many notes it copied. Notes that no longer exist are
+// forgotten.
+func (p *Provider) Reconcile(ctx context.Context, now time.Time) (int, error) {
+	entries, err := p.store.Unsynced(ctx, now.Add(-reconcileDelay))
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) > reconcileBatch {
+		entries = entries[:reconcileBatch]
+	}
+	var (
+		copied int
+		errs   []error
+	)
+	for _, e := range entries {
+		ok, err := p.copy(ctx, e.UserID, e.NoteID, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("note %s of %s: %w", e.NoteID, e.UserID, err))
+		}
+		if ok {
+			copied++
+		}
+	}
+	return copied, errors.Join(errs...)
+}
+
+// mirroringAdapter marks written notes pending and copies them in the
+// background. Mirror failures are logged rather than failing the write,
+// since the storage change already happened.
+type mirroringAdapter struct {
+	adapter.StorageAdapter
+	p      *Provider
+	userID string
+}
+
+func (a *mirroringAdapter) SaveFile(ctx context.Context, fileID string, content []byte, etag string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.SaveFile(ctx, fileID, content, etag)
+	if err == nil {
+		a.p.enqueue(ctx, a.userID, meta, content)
+	}
+	return meta, err
+}
+
+func (a *mirroringAdapter) CreateFile(ctx context.Context, name string, content []byte, folderID string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.CreateFile(ctx, name, content, folderID)
+	if err == nil {
+		a.p.enqueue(ctx, a.userID, meta, content)
+	}
+	return meta, err
+}
+
+func (a *mirroringAdapter) DuplicateFile(ctx context.Context, fileID string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.DuplicateFile(ctx, fileID)
+	if err == nil {
+		a.p.enqueue(ctx, a.userID, meta, nil)
+	}
+	return meta, err
+}
+
+// RenameFile renames the note's copy too, if the note has one. Folders
+// have no entry and are left alone.
+func (a *mirroringAdapter) RenameFile(ctx context.Context, fileID string, newName string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.RenameFile(ctx, fileID, newName)
+	if err != nil {
+		return meta, err
+	}
+	if _, gerr := a.p.store.Get(ctx, a.userID, fileID); gerr == nil {
+		a.p.enqueue(ctx, a.userID, meta, nil)
+	} else if !errors.Is(gerr, ErrNotFound) {
+		slog.ErrorContext(ctx, "Mirror failed", "error", gerr)
+	}
+	return meta, err
+}
+
+// DeleteFile forgets the note's entry but keeps its copy.
+func (a *mirroringAdapter) DeleteFile(ctx context.Context, fileID string) error {
+	err := a.StorageAdapter.DeleteFile(ctx, fileID)
+	if err == nil {
+		if derr := a.p.store.Delete(ctx, a.userID, fileID); derr != nil {
+			slog.ErrorContext(ctx, "Mirror failed", "error", derr)
+		}
+	}
+	return err
+}
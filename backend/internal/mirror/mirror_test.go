@@ -0,0 +1,160 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+	"github.com/jun/gophdrive/backend/internal/adapter/localfs"
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+)
+
+// flakyProvider fails while down is set.
+type flakyProvider struct {
+	adapter.StorageProvider
+	down atomic.Bool
+}
+
+func (f *flakyProvider) GetAdapter(ctx context.Context, userID string) (adapter.StorageAdapter, error) {
+	if f.down.Load() {
+		return nil, errors.New("provider unavailable")
+	}
+	return f.StorageProvider.GetAdapter(ctx, userID)
+}
+
+func setup(t *testing.T) (*Provider, *flakyProvider, *Store) {
+	t.Helper()
+	target := &flakyProvider{StorageProvider: localfs.NewProvider(t.TempDir())}
+	store := NewStore(nil, "")
+	return NewProvider(memory.NewProvider(nil, nil), target, store), target, store
+}
+
+// backup returns the user's copies by name.
+func backup(t *testing.T, target adapter.StorageProvider, userID string) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	storage, err := target.GetAdapter(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folderID, _ := storage.EnsureRootFolder(ctx, Folder)
+	files, err := storage.ListFiles(ctx, folderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copies := make(map[string]string)
+	for _, f := range files {
+		file, _ := storage.GetFile(ctx, f.ID)
+		copies[f.Name] = string(file.Content)
+	}
+	return copies
+}
+
+func TestProvider_CopiesWrites(t *testing.T) {
+	ctx := context.Background()
+	p, target, store := setup(t)
+	storage, _ := p.GetAdapter(ctx, "u1")
+
+	note, err := storage.CreateFile(ctx, "Plan", []byte("v1"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Wait()
+	if _, err := storage.SaveFile(ctx, note.ID, []byte("v2"), ""); err != nil {
+		t.Fatal(err)
+	}
+	p.Wait()
+	if got := backup(t, target, "u1"); len(got) != 1 || got["Plan"] != "v2" {
+		t.Fatalf("backup after save = %q", got)
+	}
+
+	if _, err := storage.RenameFile(ctx, note.ID, "Roadmap"); err != nil {
+		t.Fatal(err)
+	}
+	p.Wait()
+	if got := backup(t, target, "u1"); len(got) != 1 || got["Roadmap"] != "v2" {
+		t.Errorf("backup after rename = %q", got)
+	}
+
+	// Deleting a note keeps its copy.
+	if err := storage.DeleteFile(ctx, note.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := backup(t, target, "u1"); got["Roadmap"] != "v2" {
+		t.Errorf("backup after delete = %q", got)
+	}
+	if _, err := store.Get(ctx, "u1", note.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("entry after delete error = %v", err)
+	}
+}
+
+func TestReconcile_RetriesFailedCopies(t *testing.T) {
+	ctx := context.Background()
+	p, target, store := setup(t)
+	storage, _ := p.GetAdapter(ctx, "u1")
+
+	target.down.Store(true)
+	note, _ := storage.CreateFile(ctx, "Plan", []byte("v1"), "")
+	gone, _ := storage.CreateFile(ctx, "Gone", []byte("x"), "")
+	p.Wait()
+	st, err := store.Status(ctx, "u1")
+	if err != nil || st.Failed != 2 || len(st.Failures) != 2 || st.Failures[0].Error != "provider unavailable" {
+		t.Fatalf("Status while down = %+v, %v", st, err)
+	}
+
+	// Entries are retried a minute after they were last attempted.
+	target.down.Store(false)
+	if n, err := p.Reconcile(ctx, time.Now()); n != 0 || err != nil {
+		t.Errorf("early Reconcile = %d, %v", n, err)
+	}
+	// A note removed without the mirror knowing is forgotten.
+	inner, _ := p.StorageProvider.GetAdapter(ctx, "u1")
+	inner.SaveFile(ctx, note.ID, []byte("v2"), "")
+	inner.DeleteFile(ctx, gone.ID)
+	if n, err := p.Reconcile(ctx, time.Now().Add(2*time.Minute)); n != 1 || err != nil {
+		t.Errorf("Reconcile = %d, %v", n, err)
+	}
+	if got := backup(t, target, "u1"); len(got) != 1 || got["Plan"] != "v2" {
+		t.Errorf("backup = %q", got)
+	}
+	st, _ = store.Status(ctx, "u1")
+	if st.Synced != 1 || st.Failed != 0 || st.Pending != 0 || st.LastMirroredAt == nil {
+		t.Errorf("Status = %+v", st)
+	}
+}
+
+func TestProvider_RecreatesRemovedCopy(t *testing.T) {
+	ctx := context.Background()
+	p, target, _ := setup(t)
+	storage, _ := p.GetAdapter(ctx, "u1")
+	note, _ := storage.CreateFile(ctx, "Plan", []byte("v1"), "")
+	p.Wait()
+
+	mirrored, _ := target.GetAdapter(ctx, "u1")
+	folderID, _ := mirrored.EnsureRootFolder(ctx, Folder)
+	files, _ := mirrored.ListFiles(ctx, folderID)
+	mirrored.DeleteFile(ctx, files[0].ID)
+
+	storage.SaveFile(ctx, note.ID, []byte("v2"), "")
+	p.Wait()
+	if got := backup(t, target, "u1"); got["Plan"] != "v2" {
+		t.Errorf("backup = %q", got)
+	}
+}
+
+func TestStore_PutMarksUnsynced(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil, "")
+	s.Put(ctx, Entry{UserID: "u1", NoteID: "n1", Status: StatusFailed})
+	if e, _ := s.Get(ctx, "u1", "n1"); e.Unsynced != unsyncedValue {
+		t.Errorf("failed entry Unsynced = %q", e.Unsynced)
+	}
+	// A synced entry leaves the sparse index.
+	s.Put(ctx, Entry{UserID: "u1", NoteID: "n1", Status: StatusSynced, Unsynced: unsyncedValue})
+	if e, _ := s.Get(ctx, "u1", "n1"); e.Unsynced != "" {
+		t.Errorf("synced entry Unsynced = %q", e.Unsynced)
+	}
+}
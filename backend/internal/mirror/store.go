@@ -0,0 +1,277 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Entry statuses.
+const (
+	StatusPending = "pending"
+	StatusSynced  = "synced"
+	StatusFailed  = "failed"
+)
+
+// unsyncedIndexName is the sparse GSI on Mirrors keyed by unsynced and
+// updated_at, which only entries that are not synced have.
+const unsyncedIndexName = "unsynced-index"
+
+// unsyncedValue is the unsynced attribute of the entries that are not
+// synced.
+const unsyncedValue = "1"
+
+// ErrNotFound is returned for a note that has never been mirrored.
+var ErrNotFound = errors.New("mirror entry not found")
+
+// Entry tracks the mirrored copy of one note. Hash is the ContentHash of
+// the note's latest content, or empty if it is not known and must be read
+// from the primary storage; MirroredHash and MirroredName describe the copy.
+type Entry struct {
+	UserID       string     `json:"-" dynamodbav:"user_id"`
+	NoteID       string     `json:"noteId" dynamodbav:"note_id"`
+	Name         string     `json:"name" dynamodbav:"name"`
+	Hash         string     `json:"-" dynamodbav:"hash,omitempty"`
+	MirrorID     string     `json:"-" dynamodbav:"mirror_id,omitempty"`
+	MirroredName string     `json:"-" dynamodbav:"mirrored_name,omitempty"`
+	MirroredHash string     `json:"-" dynamodbav:"mirrored_hash,omitempty"`
+	Status       string     `json:"status" dynamodbav:"status"`
+	Error        string     `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	Attempts     int        `json:"attempts,omitempty" dynamodbav:"attempts,omitempty"`
+	UpdatedAt    time.Time  `json:"updatedAt" dynamodbav:"updated_at"`
+	MirroredAt   *time.Time `json:"mirroredAt,omitempty" dynamodbav:"mirrored_at,omitempty"`
+	// Unsynced is set by Put for entries that are not synced, putting them
+	// in the unsynced GSI.
+	Unsynced string `json:"-" dynamodbav:"unsynced,omitempty"`
+}
+
+// Store persists entries in a DynamoDB table keyed by user_id and note_id,
+// with a sparse GSI on the entries that are not synced. If client is nil, it uses an in-memory map (for tests).
+type Store struct {
+	client    *dynamodb.Client
+	tableName string
+
+	// Fallback for tests
+	entries map[string]map[string]Entry // by user ID, then note ID
+	mu      sync.Mutex
+}
+
+// NewStore creates a new mirror Store.
+func NewStore(client *dynamodb.Client, tableName string) *Store {
+	return &Store{
+		client:    client,
+		tableName: tableName,
+		entries:   make(map[string]map[string]Entry),
+	}
+}
+
+func key(userID, noteID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"user_id": &types.AttributeValueMemberS{Value: userID},
+		"note_id": &types.AttributeValueMemberS{Value: noteID},
+	}
+}
+
+// Get returns the entry of a note, or ErrNotFound.
+func (s *Store) Get(ctx context.Context, userID, noteID string) (*Entry, error) {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		e, ok := s.entries[userID][noteID]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return &e, nil
+	}
+
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            key(userID, noteID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mirror entry: %w", err)
+	}
+	if out.Item == nil {
+		return nil, ErrNotFound
+	}
+	var e Entry
+	if err := attributevalue.UnmarshalMap(out.Item, &e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mirror entry: %w", err)
+	}
+	return &e, nil
+}
+
+// Put stores e, replacing the note's entry.
+func (s *Store) Put(ctx context.Context, e Entry) error {
+	e.Unsynced = ""
+	if e.Status != StatusSynced {
+		e.Unsynced = unsyncedValue
+	}
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.entries[e.UserID] == nil {
+			s.entries[e.UserID] = make(map[string]Entry)
+		}
+		s.entries[e.UserID][e.NoteID] = e
+		return nil
+	}
+
+	item, err := attributevalue.MarshalMap(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mirror entry: %w", err)
+	}
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save mirror entry: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the entry of a note.
+func (s *Store) Delete(ctx context.Context, userID, noteID string) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.entries[userID], noteID)
+		return nil
+	}
+
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       key(userID, noteID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete mirror entry: %w", err)
+	}
+	return nil
+}
+
+// List returns the user's entries.
+func (s *Store) List(ctx context.Context, userID string) ([]Entry, error) {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return slices.Collect(maps.Values(s.entries[userID])), nil
+	}
+
+	var entries []Entry
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user_id": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query mirror entries: %w", err)
+		}
+		var page []Entry
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mirror entries: %w", err)
+		}
+		entries = append(entries, page...)
+	}
+	return entries, nil
+}
+
+// Unsynced returns the entries of every user that are not synced and were
+// last updated before cutoff.
+func (s *Store) Unsynced(ctx context.Context, cutoff time.Time) ([]Entry, error) {
+	var entries []Entry
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, byNote := range s.entries {
+			for _, e := range byNote {
+				if e.Status != StatusSynced && e.UpdatedAt.Before(cutoff) {
+					entries = append(entries, e)
+				}
+			}
+		}
+		return entries, nil
+	}
+
+	// The GSI holds only the entries that are not synced, so this reads
+	// those rather than every user's entries.
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		IndexName:              aws.String(unsyncedIndexName),
+		KeyConditionExpression: aws.String("unsynced = :unsynced AND updated_at < :cutoff"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":unsynced": &types.AttributeValueMemberS{Value: unsyncedValue},
+			":cutoff":   &types.AttributeValueMemberS{Value: cutoff.UTC().Format(time.RFC3339Nano)},
+		},
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query mirror entries: %w", err)
+		}
+		var page []Entry
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mirror entries: %w", err)
+		}
+		entries = append(entries, page...)
+	}
+	return entries, nil
+}
+
+// maxFailures bounds the failed entries listed in a Status.
+const maxFailures = 20
+
+// Status summarizes a user's mirror.
+type Status struct {
+	Enabled        bool       `json:"enabled"`
+	Synced         int        `json:"synced"`
+	Pending        int        `json:"pending"`
+	Failed         int        `json:"failed"`
+	LastMirroredAt *time.Time `json:"lastMirroredAt,omitempty"`
+	// Failures lists the most recently failed entries, newest first.
+	Failures []Entry `json:"failures"`
+}
+
+// Status counts the user's entries by status.
+func (s *Store) Status(ctx context.Context, userID string) (*Status, error) {
+	entries, err := s.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{Enabled: true, Failures: []Entry{}}
+	for _, e := range entries {
+		switch e.Status {
+		case StatusSynced:
+			st.Synced++
+		case StatusFailed:
+			st.Failed++
+			st.Failures = append(st.Failures, e)
+		default:
+			st.Pending++
+		}
+		if e.MirroredAt != nil && (st.LastMirroredAt == nil || e.MirroredAt.After(*st.LastMirroredAt)) {
+			st.LastMirroredAt = e.MirroredAt
+		}
+	}
+	slices.SortFunc(st.Failures, func(a, b Entry) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	if len(st.Failures) > maxFailures {
+		st.Failures = st.Failures[:maxFailures]
+	}
+	return st, nil
+}
@@ -12,9 +12,12 @@ type UserToken struct {
 
 // EditingSession represents an active editing session (lock) on a file.
 type EditingSession struct {
-	FileID    string `json:"file_id" dynamodbav:"file_id"`
-	UserID    string `json:"user_id" dynamodbav:"user_id"`
-	ExpiresAt int64  `json:"expires_at" dynamodbav:"expires_at"` // TTL (Unix timestamp)
+	FileID      string `json:"file_id" dynamodbav:"file_id"`
+	UserID      string `json:"user_id" dynamodbav:"user_id"`
+	DisplayName string `json:"display_name,omitempty" dynamodbav:"display_name,omitempty"` // Holder name shown to other users
+	ClientID    string `json:"client_id,omitempty" dynamodbav:"client_id,omitempty"`       // Browser tab / device that holds the lock
+	AcquiredAt  int64  `json:"acquired_at" dynamodbav:"acquired_at"`                       // Unix timestamp of first acquisition
+	ExpiresAt   int64  `json:"expires_at" dynamodbav:"expires_at"`                         // TTL (Unix timestamp)
 }
 
 // Note represents the note structure used in API.
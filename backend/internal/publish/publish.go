@@ -0,0 +1,247 @@
+// Package publish stores the published snapshots of notes: frozen copies
+// served on public share pages while the owner keeps editing the note.
+package publish
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MaxContentBytes is the largest note that can be published. DynamoDB
+// items are limited to 400 KB.
+const MaxContentBytes = 350 << 10
+
+var (
+	// ErrNotFound is returned for a note that is not published.
+	ErrNotFound = errors.New("note is not published")
+	// ErrTooLarge is returned when a note is too large to publish.
+	ErrTooLarge = errors.New("note too large to publish")
+)
+
+// Snapshot is the published copy of a note. SourceETag is the note's ETag
+// when it was published, so the owner can tell whether the working copy
+// has changed since.
+//
+// Nonce identifies the publication: it is kept when the note is
+// republished and replaced when it is published again after being
+// unpublished, so share links signed over it stop working on unpublish.
+// Snapshots published before nonces were added have none.
+//
+// A scheduled snapshot has PublishAt set and is not served until Release
+// makes it live, which clears PublishAt and sets PublishedAt.
+type Snapshot struct {
+	NoteID      string     `json:"noteId" dynamodbav:"note_id"`
+	OwnerID     string     `json:"-" dynamodbav:"owner_id"`
+	Name        string     `json:"name" dynamodbav:"name"`
+	Content     string     `json:"content" dynamodbav:"content"`
+	SourceETag  string     `json:"sourceEtag" dynamodbav:"source_etag"`
+	PublishAt   *time.Time `json:"publishAt,omitempty" dynamodbav:"publish_at,unixtime,omitempty"`
+	PublishedAt time.Time  `json:"publishedAt,omitzero" dynamodbav:"published_at"`
+	Nonce       string     `json:"-" dynamodbav:"nonce,omitempty"`
+}
+
+// Live reports whether the snapshot is served on its public page.
+func (s *Snapshot) Live() bool {
+	return s.PublishAt == nil
+}
+
+// Store persists snapshots in a DynamoDB table keyed by note_id.
+// If client is nil, it uses an in-memory map (for tests).
+type Store struct {
+	client    *dynamodb.Client
+	tableName string
+
+	// Fallback for tests
+	snapshots map[string]Snapshot // by note ID
+	mu        sync.Mutex
+}
+
+// NewStore creates a new publish Store.
+func NewStore(client *dynamodb.Client, tableName string) *Store {
+	return &Store{
+		client:    client,
+		tableName: tableName,
+		snapshots: make(map[string]Snapshot),
+	}
+}
+
+func key(noteID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"note_id": &types.AttributeValueMemberS{Value: noteID},
+	}
+}
+
+// Put publishes snap, replacing the note's earlier snapshot, and returns it
+// with its publication time and nonce set. If snap.PublishAt is in the
+// future the snapshot is scheduled instead, and the earlier one stops being
+// served.
+func (s *Store) Put(ctx context.Context, snap Snapshot) (*Snapshot, error) {
+	if len(snap.Content) > MaxContentBytes {
+		return nil, ErrTooLarge
+	}
+	prev, err := s.Get(ctx, snap.NoteID)
+	switch {
+	case err == nil:
+		snap.Nonce = prev.Nonce
+	case errors.Is(err, ErrNotFound):
+		snap.Nonce = rand.Text()
+	default:
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if snap.PublishAt != nil && snap.PublishAt.After(now) {
+		at := snap.PublishAt.UTC().Truncate(time.Second)
+		snap.PublishAt = &at
+		snap.PublishedAt = time.Time{}
+	} else {
+		snap.PublishAt = nil
+		snap.PublishedAt = now
+	}
+
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.snapshots[snap.NoteID] = snap
+		return &snap, nil
+	}
+
+	item, err := attributevalue.MarshalMap(snap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish note: %w", err)
+	}
+	return &snap, nil
+}
+
+// Get returns the published snapshot of noteID, or ErrNotFound.
+func (s *Store) Get(ctx context.Context, noteID string) (*Snapshot, error) {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		snap, ok := s.snapshots[noteID]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return &snap, nil
+	}
+
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       key(noteID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	if out.Item == nil {
+		return nil, ErrNotFound
+	}
+	var snap Snapshot
+	if err := attributevalue.UnmarshalMap(out.Item, &snap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	return &snap, nil
+}
+
+// Release makes live every scheduled snapshot whose PublishAt is not after
+// now and returns how many it released. It scans the table, which holds one
+// item per published note.
+func (s *Store) Release(ctx context.Context, now time.Time) (int, error) {
+	now = now.UTC()
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		released := 0
+		for id, snap := range s.snapshots {
+			if snap.PublishAt != nil && !snap.PublishAt.After(now) {
+				snap.PublishAt = nil
+				snap.PublishedAt = now
+				s.snapshots[id] = snap
+				released++
+			}
+		}
+		return released, nil
+	}
+
+	publishedAt, err := attributevalue.Marshal(now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal publication time: %w", err)
+	}
+	released := 0
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:            aws.String(s.tableName),
+		FilterExpression:     aws.String("publish_at <= :now"),
+		ProjectionExpression: aws.String("note_id, publish_at"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return released, fmt.Errorf("failed to scan scheduled snapshots: %w", err)
+		}
+		for _, item := range page.Items {
+			noteID, ok := item["note_id"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			// The condition skips a snapshot that was republished or
+			// unpublished since the scan.
+			_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:           aws.String(s.tableName),
+				Key:                 key(noteID.Value),
+				UpdateExpression:    aws.String("SET published_at = :published_at REMOVE publish_at"),
+				ConditionExpression: aws.String("publish_at = :publish_at"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":published_at": publishedAt,
+					":publish_at":   item["publish_at"],
+				},
+			})
+			var conditionFailed *types.ConditionalCheckFailedException
+			if errors.As(err, &conditionFailed) {
+				continue
+			}
+			if err != nil {
+				return released, fmt.Errorf("failed to release snapshot: %w", err)
+			}
+			released++
+		}
+	}
+	return released, nil
+}
+
+// Delete unpublishes noteID. Unpublishing a note that is not published is
+// not an error.
+func (s *Store) Delete(ctx context.Context, noteID string) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.snapshots, noteID)
+		return nil
+	}
+
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       key(noteID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unpublish note: %w", err)
+	}
+	return nil
+}
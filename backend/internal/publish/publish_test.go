@@ -0,0 +1,73 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_PutGetDelete(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Put error = %v, want ErrNotFound", err)
+	}
+	first, _ := s.Put(ctx, Snapshot{NoteID: "a", OwnerID: "user1", Content: "v1"})
+	snap, err := s.Put(ctx, Snapshot{NoteID: "a", OwnerID: "user1", Content: "v2"})
+	if err != nil || snap.PublishedAt.IsZero() {
+		t.Fatalf("Put = %+v, %v", snap, err)
+	}
+	if snap.Nonce == "" || snap.Nonce != first.Nonce {
+		t.Errorf("Republished nonce = %q, want %q kept", snap.Nonce, first.Nonce)
+	}
+	if got, _ := s.Get(ctx, "a"); got == nil || got.Content != "v2" {
+		t.Errorf("Get = %+v, want the republished snapshot", got)
+	}
+
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if again, _ := s.Put(ctx, Snapshot{NoteID: "a", OwnerID: "user1", Content: "v3"}); again.Nonce == first.Nonce {
+		t.Errorf("Nonce kept after unpublishing, want a new one")
+	}
+
+	big := strings.Repeat("x", MaxContentBytes+1)
+	if _, err := s.Put(ctx, Snapshot{NoteID: "b", Content: big}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Put of a large note error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestStore_Schedule(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+
+	s.Put(ctx, Snapshot{NoteID: "a", Content: "live"})
+	at := time.Now().Add(time.Hour)
+	snap, err := s.Put(ctx, Snapshot{NoteID: "a", Content: "newsletter", PublishAt: &at})
+	if err != nil || snap.Live() || !snap.PublishedAt.IsZero() {
+		t.Fatalf("Put = %+v, %v; want a scheduled snapshot", snap, err)
+	}
+	// A publishAt in the past publishes at once.
+	past := time.Now().Add(-time.Hour)
+	if snap, _ := s.Put(ctx, Snapshot{NoteID: "b", Content: "now", PublishAt: &past}); !snap.Live() {
+		t.Errorf("Put with a past publishAt = %+v, want live", snap)
+	}
+
+	if n, _ := s.Release(ctx, time.Now()); n != 0 {
+		t.Errorf("Release before publishAt released %d, want 0", n)
+	}
+	n, err := s.Release(ctx, at)
+	if err != nil || n != 1 {
+		t.Fatalf("Release = %d, %v; want 1", n, err)
+	}
+	got, _ := s.Get(ctx, "a")
+	if !got.Live() || got.Content != "newsletter" || got.PublishedAt.IsZero() {
+		t.Errorf("Get after Release = %+v, want the scheduled snapshot live", got)
+	}
+}
@@ -0,0 +1,80 @@
+package reminder
+
+import (
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icalDateTime is the UTC DATE-TIME format of RFC 5545.
+const icalDateTime = "20060102T150405Z"
+
+// WriteCalendar writes reminders as an iCalendar (RFC 5545) feed of
+// all-day events on their due dates, for calendar apps to subscribe to.
+// stamp is the feed's generation time.
+func WriteCalendar(w io.Writer, reminders []Reminder, stamp time.Time) error {
+	cw := &calendarWriter{w: w}
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:-//GophDrive//Tasks//EN")
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:GophDrive tasks")
+	for _, r := range reminders {
+		due, err := time.Parse(time.DateOnly, r.Due)
+		if err != nil {
+			continue
+		}
+		cw.line("BEGIN:VEVENT")
+		// The ID stays the same across saves of the note, so calendar apps
+		// update the event rather than adding a new one.
+		cw.line("UID:" + escapeText(r.ID) + "@gophdrive")
+		cw.line("DTSTAMP:" + stamp.UTC().Format(icalDateTime))
+		cw.line("DTSTART;VALUE=DATE:" + due.Format("20060102"))
+		cw.line("DTEND;VALUE=DATE:" + due.AddDate(0, 0, 1).Format("20060102"))
+		cw.line("SUMMARY:" + escapeText(r.Text))
+		cw.line("DESCRIPTION:" + escapeText("From "+r.NoteName))
+		cw.line("TRANSP:TRANSPARENT")
+		cw.line("END:VEVENT")
+	}
+	cw.line("END:VCALENDAR")
+	return cw.err
+}
+
+// calendarWriter writes content lines, folding them at 75 octets, and
+// keeps the first write error.
+type calendarWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *calendarWriter) line(s string) {
+	if cw.err != nil {
+		return
+	}
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		// Fold on a rune boundary; continuation lines start with a space.
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+	_, cw.err = io.WriteString(cw.w, b.String())
+}
+
+// textEscaper escapes the characters RFC 5545 reserves in TEXT values.
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeText escapes an iCalendar TEXT value.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
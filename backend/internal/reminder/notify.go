@@ -0,0 +1,152 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/secret"
+)
+
+// Notifier tells a user that a reminder is due.
+type Notifier interface {
+	Notify(ctx context.Context, r Reminder) error
+}
+
+// Notifiers sends each reminder through every notifier in the list.
+type Notifiers []Notifier
+
+// Notify implements Notifier. It tries every notifier and joins their
+// errors.
+func (ns Notifiers) Notify(ctx context.Context, r Reminder) error {
+	var errs []error
+	for _, n := range ns {
+		if err := n.Notify(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WebhookPayload is the JSON body WebhookNotifier posts.
+type WebhookPayload struct {
+	UserID   string `json:"userId"`
+	Email    string `json:"email,omitempty"`
+	NoteID   string `json:"noteId"`
+	NoteName string `json:"noteName"`
+	Text     string `json:"text"`
+	Due      string `json:"due"`
+}
+
+// WebhookNotifier posts each due reminder to one URL for the whole
+// deployment, e.g. a chat integration on a self-hosted instance.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client // nil means a client with a 10s timeout
+}
+
+// Notify implements Notifier.
+func (w *WebhookNotifier) Notify(ctx context.Context, r Reminder) error {
+	body, _ := json.Marshal(WebhookPayload{
+		UserID:   r.UserID,
+		Email:    r.Email,
+		NoteID:   r.NoteID,
+		NoteName: r.NoteName,
+		Text:     r.Text,
+		Due:      r.Due,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("reminder webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("reminder webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("reminder webhook: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailNotifier emails each due reminder to its owner through an SMTP
+// server. Reminders without an address are skipped.
+type EmailNotifier struct {
+	Addr string // host:port
+	From string
+	// Username and Password authenticate to the server. Leave Username
+	// empty for servers that accept unauthenticated mail, such as a local
+	// relay.
+	Username string
+	Password *secret.Value
+}
+
+// Notify implements Notifier.
+func (e *EmailNotifier) Notify(ctx context.Context, r Reminder) error {
+	if r.Email == "" {
+		return nil
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", r.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Due "+r.Due+": "+oneLine(r.Text)))
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\nDue %s in %q.\r\n", r.Text, r.Due, r.NoteName)
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		password, err := e.Password.Get(ctx)
+		if err != nil {
+			return fmt.Errorf("reminder email: %w", err)
+		}
+		host, _, _ := net.SplitHostPort(e.Addr)
+		auth = smtp.PlainAuth("", e.Username, password, host)
+	}
+	if err := smtp.SendMail(e.Addr, auth, e.From, []string{r.Email}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("reminder email: %w", err)
+	}
+	return nil
+}
+
+// oneLine keeps a header value on one line.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Send notifies every reminder due by now (in UTC) that has not been
+// notified and returns how many it sent. A reminder that fails is logged
+// and retried on the next run.
+func Send(ctx context.Context, store *Store, notifier Notifier, now time.Time) (int, error) {
+	pending, err := store.Pending(ctx, now.UTC().Format(time.DateOnly))
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, r := range pending {
+		if err := notifier.Notify(ctx, r); err != nil {
+			slog.WarnContext(ctx, "Sending reminder failed", "note_id", r.NoteID, "error", err)
+			continue
+		}
+		if err := store.MarkNotified(ctx, r, now); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
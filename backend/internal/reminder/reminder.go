@@ -0,0 +1,320 @@
+// Package reminder collects the due dates of open tasks across a user's
+// notes and notifies the user when they fall due.
+//
+// Reminders are indexed from a note's content whenever it is saved through
+// the API, so edits made directly in Google Drive are picked up on the
+// next save.
+package reminder
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jun/gophdrive/core/markdown"
+)
+
+// Reminder is an open task with a due date. Email is the owner's address
+// when the note was last saved, used for email notifications.
+type Reminder struct {
+	UserID     string     `json:"-" dynamodbav:"user_id"`
+	ID         string     `json:"-" dynamodbav:"reminder_id"`
+	NoteID     string     `json:"noteId" dynamodbav:"note_id"`
+	NoteName   string     `json:"noteName" dynamodbav:"note_name"`
+	TaskIndex  int        `json:"taskIndex" dynamodbav:"task_index"`
+	Text       string     `json:"text" dynamodbav:"text"`
+	Due        string     `json:"due" dynamodbav:"due"`
+	Email      string     `json:"-" dynamodbav:"email,omitempty"`
+	NotifiedAt *time.Time `json:"notifiedAt,omitempty" dynamodbav:"notified_at,omitempty"`
+}
+
+// FromNote returns the reminders for the open, dated tasks in a note, found
+// by markdown.ExtractTasks so the indexes match what the editor toggles.
+func FromNote(userID, email, noteID, noteName, content string) []Reminder {
+	var reminders []Reminder
+	for _, t := range markdown.ExtractTasks([]byte(content)) {
+		if t.Done || t.Due == "" {
+			continue
+		}
+		reminders = append(reminders, Reminder{
+			UserID:    userID,
+			ID:        reminderID(noteID, t.Index),
+			NoteID:    noteID,
+			NoteName:  noteName,
+			TaskIndex: t.Index,
+			Text:      t.Text,
+			Due:       t.Due,
+			Email:     email,
+		})
+	}
+	return reminders
+}
+
+func reminderID(noteID string, index int) string {
+	return noteID + "#" + strconv.Itoa(index)
+}
+
+// Store persists reminders in a DynamoDB table keyed by user_id and
+// reminder_id ("<note ID>#<task index>").
+// If client is nil, it uses an in-memory map (for tests).
+type Store struct {
+	client    *dynamodb.Client
+	tableName string
+
+	// Fallback for tests
+	reminders map[string]map[string]Reminder // by user ID, then reminder ID
+	mu        sync.Mutex
+}
+
+// NewStore creates a new reminder Store.
+func NewStore(client *dynamodb.Client, tableName string) *Store {
+	return &Store{
+		client:    client,
+		tableName: tableName,
+		reminders: make(map[string]map[string]Reminder),
+	}
+}
+
+// sameTask reports whether r and old describe the same task, so a
+// notification already sent for old is not sent again.
+func sameTask(r, old Reminder) bool {
+	return r.Text == old.Text && r.Due == old.Due
+}
+
+// Replace sets the reminders of noteID to reminders, dropping those of
+// tasks that were completed or removed. Reminders for unchanged tasks keep
+// their notification time.
+func (s *Store) Replace(ctx context.Context, userID, noteID string, reminders []Reminder) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		byID := s.reminders[userID]
+		if byID == nil {
+			byID = make(map[string]Reminder)
+			s.reminders[userID] = byID
+		}
+		old := make(map[string]Reminder)
+		for id, r := range byID {
+			if r.NoteID == noteID {
+				old[id] = r
+				delete(byID, id)
+			}
+		}
+		for _, r := range reminders {
+			if prev, ok := old[r.ID]; ok && sameTask(r, prev) {
+				r.NotifiedAt = prev.NotifiedAt
+			}
+			byID[r.ID] = r
+		}
+		return nil
+	}
+
+	existing, err := s.query(ctx, userID, noteID)
+	if err != nil {
+		return err
+	}
+	old := make(map[string]Reminder, len(existing))
+	for _, r := range existing {
+		old[r.ID] = r
+	}
+
+	var writes []types.WriteRequest
+	for _, r := range reminders {
+		prev, ok := old[r.ID]
+		if ok && sameTask(r, prev) {
+			r.NotifiedAt = prev.NotifiedAt
+		}
+		delete(old, r.ID)
+		item, err := attributevalue.MarshalMap(r)
+		if err != nil {
+			return fmt.Errorf("failed to marshal reminder: %w", err)
+		}
+		writes = append(writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+	for id := range old {
+		writes = append(writes, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key(userID, id)}})
+	}
+	return s.batchWrite(ctx, writes)
+}
+
+// DeleteNote removes the reminders of noteID, for when the note is deleted.
+func (s *Store) DeleteNote(ctx context.Context, userID, noteID string) error {
+	return s.Replace(ctx, userID, noteID, nil)
+}
+
+// List returns the user's reminders due on or before dueBy (YYYY-MM-DD),
+// or all of them if dueBy is "", soonest first.
+func (s *Store) List(ctx context.Context, userID, dueBy string) ([]Reminder, error) {
+	var all []Reminder
+	if s.client == nil {
+		s.mu.Lock()
+		for _, r := range s.reminders[userID] {
+			all = append(all, r)
+		}
+		s.mu.Unlock()
+	} else {
+		var err error
+		if all, err = s.query(ctx, userID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	reminders := []Reminder{}
+	for _, r := range all {
+		if dueBy == "" || r.Due <= dueBy {
+			reminders = append(reminders, r)
+		}
+	}
+	sortReminders(reminders)
+	return reminders, nil
+}
+
+// Pending returns every user's reminders due on or before today
+// (YYYY-MM-DD) that have not been notified. It scans the table, which
+// holds one item per open dated task.
+func (s *Store) Pending(ctx context.Context, today string) ([]Reminder, error) {
+	reminders := []Reminder{}
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, byID := range s.reminders {
+			for _, r := range byID {
+				if r.NotifiedAt == nil && r.Due <= today {
+					reminders = append(reminders, r)
+				}
+			}
+		}
+		sortReminders(reminders)
+		return reminders, nil
+	}
+
+	paginator := dynamodb.NewScanPaginator(s.client, &dynamodb.ScanInput{
+		TableName:        aws.String(s.tableName),
+		FilterExpression: aws.String("due <= :today AND attribute_not_exists(notified_at)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":today": &types.AttributeValueMemberS{Value: today},
+		},
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reminders: %w", err)
+		}
+		var page []Reminder
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal reminders: %w", err)
+		}
+		reminders = append(reminders, page...)
+	}
+	sortReminders(reminders)
+	return reminders, nil
+}
+
+// MarkNotified records that r was notified at, so it is not sent again.
+// A reminder removed in the meantime stays removed.
+func (s *Store) MarkNotified(ctx context.Context, r Reminder, at time.Time) error {
+	at = at.UTC()
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if cur, ok := s.reminders[r.UserID][r.ID]; ok {
+			cur.NotifiedAt = &at
+			s.reminders[r.UserID][r.ID] = cur
+		}
+		return nil
+	}
+
+	notifiedAt, err := attributevalue.Marshal(at)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification time: %w", err)
+	}
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.tableName),
+		Key:                 key(r.UserID, r.ID),
+		UpdateExpression:    aws.String("SET notified_at = :notified_at"),
+		ConditionExpression: aws.String("attribute_exists(reminder_id)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":notified_at": notifiedAt,
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionFailed) {
+		return fmt.Errorf("failed to mark reminder notified: %w", err)
+	}
+	return nil
+}
+
+// query returns the user's reminders, only those of noteID if it is set.
+func (s *Store) query(ctx context.Context, userID, noteID string) ([]Reminder, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user_id": &types.AttributeValueMemberS{Value: userID},
+		},
+	}
+	if noteID != "" {
+		input.KeyConditionExpression = aws.String("user_id = :user_id AND begins_with(reminder_id, :prefix)")
+		input.ExpressionAttributeValues[":prefix"] = &types.AttributeValueMemberS{Value: noteID + "#"}
+	}
+
+	var reminders []Reminder
+	paginator := dynamodb.NewQueryPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query reminders: %w", err)
+		}
+		var page []Reminder
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal reminders: %w", err)
+		}
+		reminders = append(reminders, page...)
+	}
+	return reminders, nil
+}
+
+func (s *Store) batchWrite(ctx context.Context, writes []types.WriteRequest) error {
+	// BatchWriteItem writes at most 25 items per call.
+	for chunk := range slices.Chunk(writes, 25) {
+		request := map[string][]types.WriteRequest{s.tableName: chunk}
+		for len(request) > 0 {
+			out, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: request})
+			if err != nil {
+				return fmt.Errorf("failed to write reminders: %w", err)
+			}
+			request = out.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+func key(userID, reminderID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"user_id":     &types.AttributeValueMemberS{Value: userID},
+		"reminder_id": &types.AttributeValueMemberS{Value: reminderID},
+	}
+}
+
+// sortReminders orders reminders by due date, then note and task.
+func sortReminders(reminders []Reminder) {
+	slices.SortFunc(reminders, func(a, b Reminder) int {
+		return cmp.Or(
+			strings.Compare(a.Due, b.Due),
+			strings.Compare(a.NoteName, b.NoteName),
+			strings.Compare(a.NoteID, b.NoteID),
+			cmp.Compare(a.TaskIndex, b.TaskIndex),
+		)
+	})
+}
@@ -0,0 +1,138 @@
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_Replace(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+
+	s.Replace(ctx, "user1", "a", FromNote("user1", "", "a", "Bills", "- [ ] pay rent 📅 2024-06-01\n- [ ] insurance 📅 2024-06-10\n"))
+	s.Replace(ctx, "user1", "b", FromNote("user1", "", "b", "Trip", "- [ ] book hotel 📅 2024-05-30\n"))
+	s.Replace(ctx, "user2", "c", FromNote("user2", "", "c", "Other", "- [ ] not mine 📅 2024-05-01\n"))
+
+	list, _ := s.List(ctx, "user1", "")
+	if len(list) != 3 || list[0].Text != "book hotel" || list[2].Text != "insurance" {
+		t.Fatalf("List = %+v, want user1's three reminders soonest first", list)
+	}
+	if list, _ := s.List(ctx, "user1", "2024-06-01"); len(list) != 2 {
+		t.Errorf("List due by 2024-06-01 = %+v, want 2", list)
+	}
+
+	// Completing a task drops its reminder.
+	s.Replace(ctx, "user1", "a", FromNote("user1", "", "a", "Bills", "- [x] pay rent 📅 2024-06-01\n- [ ] insurance 📅 2024-06-10\n"))
+	if list, _ := s.List(ctx, "user1", ""); len(list) != 2 {
+		t.Errorf("List after completing a task = %+v, want 2", list)
+	}
+
+	if err := s.DeleteNote(ctx, "user1", "b"); err != nil {
+		t.Fatalf("DeleteNote: %v", err)
+	}
+	if list, _ := s.List(ctx, "user1", ""); len(list) != 1 || list[0].NoteID != "a" {
+		t.Errorf("List after DeleteNote = %+v", list)
+	}
+}
+
+type recordingNotifier struct {
+	sent []Reminder
+	err  error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, r Reminder) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, r)
+	return nil
+}
+
+func TestSend(t *testing.T) {
+	s := NewStore(nil, "")
+	ctx := context.Background()
+	note := "- [ ] pay rent 📅 2024-06-01\n- [ ] insurance 📅 2024-06-10\n"
+	s.Replace(ctx, "user1", "a", FromNote("user1", "me@example.com", "a", "Bills", note))
+
+	now := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	failing := &recordingNotifier{err: errors.New("down")}
+	if n, _ := Send(ctx, s, failing, now); n != 0 {
+		t.Errorf("Send with a failing notifier sent %d, want 0", n)
+	}
+
+	notifier := &recordingNotifier{}
+	n, err := Send(ctx, s, notifier, now)
+	if err != nil || n != 1 || notifier.sent[0].Text != "pay rent" || notifier.sent[0].Email != "me@example.com" {
+		t.Fatalf("Send = %d, %v, sent %+v; want the rent reminder", n, err, notifier.sent)
+	}
+	if n, _ := Send(ctx, s, notifier, now); n != 0 {
+		t.Errorf("second Send sent %d, want 0", n)
+	}
+
+	// Re-saving the note does not send the reminder again, but changing
+	// the task's due date does.
+	s.Replace(ctx, "user1", "a", FromNote("user1", "me@example.com", "a", "Bills", note+"\nMore notes.\n"))
+	if n, _ := Send(ctx, s, notifier, now); n != 0 {
+		t.Errorf("Send after an unrelated edit sent %d, want 0", n)
+	}
+	s.Replace(ctx, "user1", "a", FromNote("user1", "me@example.com", "a", "Bills", "- [ ] pay rent 📅 2024-05-31\n"))
+	if n, _ := Send(ctx, s, notifier, now); n != 1 {
+		t.Errorf("Send after changing the due date sent %d, want 1", n)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got WebhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	w := &WebhookNotifier{URL: srv.URL}
+	err := w.Notify(context.Background(), Reminder{UserID: "user1", NoteID: "a", NoteName: "Bills", Text: "pay rent", Due: "2024-06-01"})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got.NoteName != "Bills" || got.Text != "pay rent" || got.Due != "2024-06-01" {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestWriteCalendar(t *testing.T) {
+	long := strings.Repeat("renew the domain, certificates; ", 4)
+	reminders := []Reminder{
+		{ID: "a#0", NoteName: "Bills", Text: "pay rent", Due: "2024-06-01"},
+		{ID: "a#1", NoteName: "Bills", Text: long, Due: "2024-12-31"},
+	}
+	var b strings.Builder
+	if err := WriteCalendar(&b, reminders, time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("WriteCalendar: %v", err)
+	}
+	feed := b.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:a#0@gophdrive\r\nDTSTAMP:20240501T093000Z\r\nDTSTART;VALUE=DATE:20240601\r\nDTEND;VALUE=DATE:20240602\r\nSUMMARY:pay rent\r\n",
+		"DTEND;VALUE=DATE:20250101\r\n",
+		`SUMMARY:renew the domain\, certificates\; `,
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(feed, want) {
+			t.Errorf("feed is missing %q:\n%s", want, feed)
+		}
+	}
+	for line := range strings.SplitSeq(strings.TrimSuffix(feed, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+	if unfolded := strings.ReplaceAll(feed, "\r\n ", ""); !strings.Contains(unfolded, "SUMMARY:"+escapeText(long)+"\r\n") {
+		t.Errorf("folded summary does not unfold to the task text:\n%s", feed)
+	}
+}
@@ -0,0 +1,159 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is how long CachingResolver serves a secret before
+	// fetching it again.
+	DefaultCacheTTL = 5 * time.Minute
+
+	// minRefreshInterval limits how often a secret is fetched outside its
+	// TTL: forced refreshes are triggered by requests that fail to
+	// authenticate, which anyone can send, and a failing backend should not
+	// be retried on every request.
+	minRefreshInterval = 10 * time.Second
+
+	// refreshTimeout bounds a background refresh, which outlives the
+	// request that started it.
+	refreshTimeout = 5 * time.Second
+)
+
+// Refresher is implemented by resolvers that can be told to re-fetch a
+// secret, e.g. because a value that no longer authenticates may have been
+// rotated.
+type Refresher interface {
+	Refresh(ctx context.Context, name string) (string, error)
+}
+
+// CachingResolver caches another Resolver's secrets for a TTL. Once the TTL
+// has passed the cached value is still returned while it is re-fetched in
+// the background (stale-while-revalidate), so rotated secrets are picked up
+// without any request waiting on the backend. Only the first lookup of a
+// name blocks. Failed lookups are not cached, but Refresh retries one only
+// after minRefreshInterval.
+//
+// On Lambda a background refresh may only finish when the next invocation
+// thaws the execution environment; the stale value is served until then.
+type CachingResolver struct {
+	next Resolver
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*cacheEntry
+	refreshes sync.WaitGroup
+}
+
+type cacheEntry struct {
+	load sync.Mutex // serializes fetches of the entry's secret
+
+	// Guarded by CachingResolver.mu.
+	value       string
+	err         error     // of the last fetch, if it failed
+	fetchedAt   time.Time // of value
+	attemptedAt time.Time // of the last fetch, successful or not
+	refreshing  bool
+}
+
+// NewCachingResolver returns a Resolver that caches next's secrets for ttl,
+// or DefaultCacheTTL if ttl is not positive.
+func NewCachingResolver(next Resolver, ttl time.Duration) *CachingResolver {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &CachingResolver{next: next, ttl: ttl, now: time.Now, entries: make(map[string]*cacheEntry)}
+}
+
+// GetSecret returns the cached secret, fetching it if it has never been
+// fetched successfully. An expired secret is returned as is and refreshed in
+// the background.
+func (c *CachingResolver) GetSecret(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	e := c.entries[name]
+	if e == nil {
+		e = &cacheEntry{}
+		c.entries[name] = e
+	}
+	if e.value == "" {
+		c.mu.Unlock()
+		return c.load(ctx, name, e, time.Time{})
+	}
+	value := e.value
+	now := c.now()
+	if now.Sub(e.fetchedAt) >= c.ttl && now.Sub(e.attemptedAt) >= minRefreshInterval && !e.refreshing {
+		e.refreshing = true
+		c.refreshes.Add(1)
+		go c.refreshInBackground(context.WithoutCancel(ctx), name, e)
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+// Refresh fetches name again, ignoring the TTL, and returns the new value.
+// If the secret was fetched less than minRefreshInterval ago the cached
+// value, or the error of that fetch, is returned instead.
+func (c *CachingResolver) Refresh(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	e := c.entries[name]
+	if e == nil {
+		e = &cacheEntry{}
+		c.entries[name] = e
+	}
+	c.mu.Unlock()
+	return c.load(ctx, name, e, c.now().Add(-minRefreshInterval))
+}
+
+// load fetches the secret unless a fetch was attempted after since, as
+// happens when concurrent callers wait for the same one; then it returns
+// the cached value or, if there is none, that fetch's error. GetSecret
+// passes the zero since: it takes any cached value but retries failures.
+func (c *CachingResolver) load(ctx context.Context, name string, e *cacheEntry, since time.Time) (string, error) {
+	e.load.Lock()
+	defer e.load.Unlock()
+
+	c.mu.Lock()
+	if e.attemptedAt.After(since) && (e.value != "" || e.err != nil && !since.IsZero()) {
+		value, err := e.value, e.err
+		c.mu.Unlock()
+		if value != "" {
+			return value, nil
+		}
+		return "", err
+	}
+	started := c.now()
+	e.attemptedAt = started
+	c.mu.Unlock()
+
+	value, err := c.next.GetSecret(ctx, name)
+	if err == nil && value == "" {
+		err = fmt.Errorf("secret %q is empty", name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.err = err
+	if err != nil {
+		return "", err
+	}
+	e.value, e.fetchedAt = value, started
+	return value, nil
+}
+
+func (c *CachingResolver) refreshInBackground(ctx context.Context, name string, e *cacheEntry) {
+	defer c.refreshes.Done()
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+	if _, err := c.load(ctx, name, e, c.now()); err != nil {
+		// Keep serving the cached value; the next lookup after
+		// minRefreshInterval tries again.
+		slog.WarnContext(ctx, "Failed to refresh secret", "name", name, "error", err)
+	}
+	c.mu.Lock()
+	e.refreshing = false
+	c.mu.Unlock()
+}
@@ -0,0 +1,191 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingResolver returns the current value of each secret and counts
+// lookups.
+type countingResolver struct {
+	mu     sync.Mutex
+	values map[string]string
+	err    error
+	calls  int
+}
+
+func (r *countingResolver) GetSecret(_ context.Context, name string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.err != nil {
+		return "", r.err
+	}
+	return r.values[name], nil
+}
+
+func (r *countingResolver) set(value string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values["/s"], r.err = value, err
+}
+
+func (r *countingResolver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+// newTestCache returns a cache over next whose clock is advanced by the
+// returned function.
+func newTestCache(next Resolver, ttl time.Duration) (*CachingResolver, func(time.Duration)) {
+	c := NewCachingResolver(next, ttl)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	c.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	return c, func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+}
+
+func get(t *testing.T, r Resolver) string {
+	t.Helper()
+	v, err := r.GetSecret(context.Background(), "/s")
+	if err != nil {
+		t.Fatalf("GetSecret: %v", err)
+	}
+	return v
+}
+
+func TestCachingResolver_CachesWithinTTL(t *testing.T) {
+	next := &countingResolver{values: map[string]string{"/s": "v1"}}
+	c, advance := newTestCache(next, time.Minute)
+
+	get(t, c)
+	advance(59 * time.Second)
+	if v := get(t, c); v != "v1" || next.count() != 1 {
+		t.Errorf("got %q after %d lookups, want v1 from one lookup", v, next.count())
+	}
+}
+
+func TestCachingResolver_ServesStaleWhileRefreshing(t *testing.T) {
+	next := &countingResolver{values: map[string]string{"/s": "v1"}}
+	c, advance := newTestCache(next, time.Minute)
+	get(t, c)
+
+	next.set("v2", nil)
+	advance(time.Minute)
+	if v := get(t, c); v != "v1" {
+		t.Errorf("expired lookup = %q, want the stale v1", v)
+	}
+	c.refreshes.Wait()
+	if v := get(t, c); v != "v2" {
+		t.Errorf("after refresh = %q, want v2", v)
+	}
+}
+
+func TestCachingResolver_KeepsValueWhenRefreshFails(t *testing.T) {
+	next := &countingResolver{values: map[string]string{"/s": "v1"}}
+	c, advance := newTestCache(next, time.Minute)
+	get(t, c)
+
+	next.set("", errors.New("throttled"))
+	advance(time.Minute)
+	get(t, c)
+	c.refreshes.Wait()
+	if v := get(t, c); v != "v1" {
+		t.Errorf("got %q, want v1 kept after a failed refresh", v)
+	}
+	c.refreshes.Wait()
+	if n := next.count(); n != 2 {
+		t.Errorf("%d lookups, want failed refreshes retried after minRefreshInterval only", n)
+	}
+
+	advance(minRefreshInterval)
+	get(t, c)
+	c.refreshes.Wait()
+	if n := next.count(); n != 3 {
+		t.Errorf("%d lookups, want a retry after minRefreshInterval", n)
+	}
+}
+
+func TestCachingResolver_DoesNotCacheFailures(t *testing.T) {
+	next := &countingResolver{values: map[string]string{}, err: errors.New("unavailable")}
+	c, _ := newTestCache(next, time.Minute)
+
+	if _, err := c.GetSecret(context.Background(), "/s"); err == nil {
+		t.Fatal("expected an error")
+	}
+	next.set("v1", nil)
+	if v := get(t, c); v != "v1" {
+		t.Errorf("got %q, want v1 once the backend recovers", v)
+	}
+}
+
+func TestCachingResolver_RefreshAfterFailure(t *testing.T) {
+	next := &countingResolver{values: map[string]string{}, err: errors.New("unavailable")}
+	c, advance := newTestCache(next, time.Minute)
+	c.GetSecret(context.Background(), "/s")
+
+	// Refreshes right after a failed fetch return its error.
+	next.set("v1", nil)
+	for range 3 {
+		if _, err := c.Refresh(context.Background(), "/s"); err == nil {
+			t.Fatal("expected the error of the last fetch")
+		}
+	}
+	if n := next.count(); n != 1 {
+		t.Errorf("%d lookups, want failures refreshed after minRefreshInterval only", n)
+	}
+
+	advance(minRefreshInterval)
+	if v, err := c.Refresh(context.Background(), "/s"); v != "v1" || err != nil {
+		t.Errorf("Refresh = %q, %v, want v1", v, err)
+	}
+}
+
+func TestCachingResolver_Refresh(t *testing.T) {
+	next := &countingResolver{values: map[string]string{"/s": "v1"}}
+	c, advance := newTestCache(next, time.Hour)
+	get(t, c)
+	next.set("v2", nil)
+
+	// Refreshes right after a fetch are served from the cache.
+	if v, _ := c.Refresh(context.Background(), "/s"); v != "v1" {
+		t.Errorf("throttled Refresh = %q, want v1", v)
+	}
+	advance(minRefreshInterval)
+	if v, err := c.Refresh(context.Background(), "/s"); v != "v2" || err != nil {
+		t.Errorf("Refresh = %q, %v, want v2", v, err)
+	}
+	if v := get(t, c); v != "v2" {
+		t.Errorf("GetSecret after Refresh = %q, want v2", v)
+	}
+}
+
+func TestValue_Refresh(t *testing.T) {
+	next := &countingResolver{values: map[string]string{"/s": "v1"}}
+	c, advance := newTestCache(next, time.Hour)
+	v := NewValue(c, "/s")
+	if got, _ := v.Get(context.Background()); got != "v1" {
+		t.Fatalf("Get = %q, want v1", got)
+	}
+
+	next.set("v2", nil)
+	advance(minRefreshInterval)
+	if got, _ := v.Refresh(context.Background()); got != "v2" {
+		t.Errorf("Refresh = %q, want v2", got)
+	}
+	if got, _ := Static("fixed").Refresh(context.Background()); got != "fixed" {
+		t.Errorf("Static Refresh = %q, want fixed", got)
+	}
+}
@@ -0,0 +1,50 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+)
+
+// Value is a named secret looked up when it is needed rather than once at
+// startup. It keeps no copy of its own: backed by a CachingResolver, lookups
+// are cheap and a rotated secret is picked up when the cache refreshes.
+type Value struct {
+	resolver Resolver
+	name     string
+	value    string // set by Static
+}
+
+// NewValue returns a Value that resolves name with resolver.
+func NewValue(resolver Resolver, name string) *Value {
+	return &Value{resolver: resolver, name: name}
+}
+
+// Static returns a Value that always has value, e.g. for tests.
+func Static(value string) *Value {
+	return &Value{value: value}
+}
+
+// Get returns the secret.
+func (v *Value) Get(ctx context.Context) (string, error) {
+	if v.resolver == nil {
+		return v.value, nil
+	}
+	value, err := v.resolver.GetSecret(ctx, v.name)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", fmt.Errorf("secret %q is empty", v.name)
+	}
+	return value, nil
+}
+
+// Refresh returns the secret, re-fetching it if the resolver is a
+// Refresher. Callers use it when the current value failed to authenticate
+// and may have been rotated.
+func (v *Value) Refresh(ctx context.Context) (string, error) {
+	if r, ok := v.resolver.(Refresher); ok {
+		return r.Refresh(ctx, v.name)
+	}
+	return v.Get(ctx)
+}
@@ -2,7 +2,10 @@ package session
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -14,70 +17,164 @@ import (
 
 const DefaultTTL = 5 * time.Minute
 
+// userIndexName is the GSI on EditingSessions keyed by user_id.
+const userIndexName = "user_id-index"
+
 // LockManager handles session locking for files using DynamoDB TTL.
 type LockManager struct {
 	client      *dynamodb.Client
 	tableName   string
 	ttlDuration time.Duration
+	maxDuration time.Duration
 }
 
-// NewLockManager creates a new LockManager.
-func NewLockManager(client *dynamodb.Client, tableName string) *LockManager {
+// NewLockManager creates a new LockManager using the given lock policy.
+func NewLockManager(client *dynamodb.Client, tableName string, policy Policy) *LockManager {
 	return &LockManager{
 		client:      client,
 		tableName:   tableName,
-		ttlDuration: DefaultTTL,
+		ttlDuration: policy.TTL,
+		maxDuration: policy.MaxDuration,
 	}
 }
 
+// Policy returns the lock policy in effect.
+func (m *LockManager) Policy() Policy {
+	return Policy{TTL: m.ttlDuration, MaxDuration: m.maxDuration}
+}
+
 // AcquireLock attempts to acquire a lock on a file for the given user.
 // It succeeds if:
 // 1. No lock exists for the file.
 // 2. The existing lock has expired (TTL < now).
 // 3. The existing lock belongs to the same user (refresh).
-func (m *LockManager) AcquireLock(ctx context.Context, fileID string, userID string) (*model.EditingSession, error) {
+// A refresh by the same user keeps the original AcquiredAt time unless the
+// lock has already reached its maximum duration, in which case it starts over.
+//
+// Each step is a single conditional write, so a concurrent acquire by
+// another user cannot slip in between reading the lock and replacing it.
+func (m *LockManager) AcquireLock(ctx context.Context, fileID string, userID string, holder HolderInfo) (*model.EditingSession, error) {
+	policy := m.Policy()
 	now := time.Now().Unix()
-	expiresAt := now + int64(m.ttlDuration.Seconds())
+	maxSeconds := int64(policy.MaxDuration.Seconds())
 
-	session := model.EditingSession{
-		FileID:    fileID,
-		UserID:    userID,
-		ExpiresAt: expiresAt,
+	values := map[string]types.AttributeValue{
+		":now":        &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now)},
+		":user_id":    &types.AttributeValueMemberS{Value: userID},
+		":expires_at": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", policy.expiresAt(now, now))},
+	}
+	set := []string{"user_id = :user_id"}
+	var remove []string
+	for _, attr := range [][2]string{{"display_name", holder.DisplayName}, {"client_id", holder.ClientID}} {
+		name, value := attr[0], attr[1]
+		if value == "" {
+			remove = append(remove, name)
+			continue
+		}
+		set = append(set, name+" = :"+name)
+		values[":"+name] = &types.AttributeValueMemberS{Value: value}
+	}
+	update := func(assignments ...string) string {
+		expr := "SET " + strings.Join(append(slices.Clone(set), assignments...), ", ")
+		if len(remove) > 0 {
+			expr += " REMOVE " + strings.Join(remove, ", ")
+		}
+		return expr
 	}
 
-	item, err := attributevalue.MarshalMap(session)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal session: %w", err)
+	// 1. A new lock, or a refresh of the user's own lock that stays within
+	// the maximum duration: acquired_at is only written if it is missing.
+	refresh := "attribute_not_exists(file_id) OR (user_id = :user_id AND expires_at >= :now)"
+	if maxSeconds > 0 {
+		refresh = "attribute_not_exists(file_id) OR (user_id = :user_id AND expires_at >= :now AND acquired_at >= :min_acquired_at)"
+		values[":min_acquired_at"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now+int64(policy.TTL.Seconds())-maxSeconds)}
+	}
+	session, err := m.updateLock(ctx, fileID, update("acquired_at = if_not_exists(acquired_at, :now)", "expires_at = :expires_at"), refresh, values)
+	if !errors.Is(err, ErrLocked) {
+		return session, err
+	}
+
+	// 2. An expired lock, or the user's own lock past its maximum duration:
+	// start over.
+	takeover := "expires_at < :now"
+	if maxSeconds > 0 {
+		takeover = "expires_at < :now OR (user_id = :user_id AND acquired_at <= :exceeded_at)"
+		values[":exceeded_at"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now-maxSeconds)}
+	}
+	session, err = m.updateLock(ctx, fileID, update("acquired_at = :now", "expires_at = :expires_at"), takeover, values)
+	if !errors.Is(err, ErrLocked) || maxSeconds == 0 {
+		return session, err
 	}
 
-	// Condition: (attribute_not_exists(file_id)) OR (expires_at < :now) OR (user_id = :user_id)
-	_, err = m.client.PutItem(ctx, &dynamodb.PutItemInput{
+	// 3. The user's own lock close to its maximum duration: extend it up
+	// to that limit.
+	values[":max_duration"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", maxSeconds)}
+	return m.updateLock(ctx, fileID, update("expires_at = acquired_at + :max_duration"), "user_id = :user_id AND expires_at >= :now", values)
+}
+
+// updateLock applies a conditional update to the lock item for fileID and
+// returns the updated lock. It returns ErrLocked if the condition fails.
+func (m *LockManager) updateLock(ctx context.Context, fileID, update, condition string, values map[string]types.AttributeValue) (*model.EditingSession, error) {
+	// The expression only references some of the values.
+	used := make(map[string]types.AttributeValue)
+	for name, value := range values {
+		if strings.Contains(update, name) || strings.Contains(condition, name) {
+			used[name] = value
+		}
+	}
+	out, err := m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(m.tableName),
-		Item:      item,
-		ConditionExpression: aws.String(
-			"attribute_not_exists(file_id) OR expires_at < :now OR user_id = :user_id",
-		),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":now":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now)},
-			":user_id": &types.AttributeValueMemberS{Value: userID},
+		Key: map[string]types.AttributeValue{
+			"file_id": &types.AttributeValueMemberS{Value: fileID},
 		},
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: used,
+		ReturnValues:              types.ReturnValueAllNew,
 	})
-
 	if err != nil {
-		if string(err.Error()) == "ConditionalCheckFailedException" {
-			// In AWS SDK v2, use errors.As(err, &condCheckFailed)
-			return nil, fmt.Errorf("file is locked by another user")
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			return nil, ErrLocked
 		}
 		return nil, fmt.Errorf("failed to acquire lock: %w", err)
 	}
 
+	var session model.EditingSession
+	if err := attributevalue.UnmarshalMap(out.Attributes, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
 	return &session, nil
 }
 
 // Heartbeat extends the lock TTL if the user owns the lock.
+// It refuses to extend a lock past the policy's maximum duration.
 func (m *LockManager) Heartbeat(ctx context.Context, fileID string, userID string) (*model.EditingSession, error) {
+	policy := m.Policy()
 	now := time.Now().Unix()
-	expiresAt := now + int64(m.ttlDuration.Seconds())
+
+	// Read the raw item: a lock past its maximum duration has also expired,
+	// and the caller should learn why rather than see "not found".
+	existing, err := m.getLock(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrLockNotFound
+	}
+	if existing.UserID != userID {
+		if existing.ExpiresAt < now {
+			return nil, ErrLockNotFound
+		}
+		return nil, ErrNotOwner
+	}
+	if policy.exceeded(now, existing.AcquiredAt) {
+		return nil, ErrMaxDurationExceeded
+	}
+	if existing.ExpiresAt < now {
+		return nil, ErrLockNotFound
+	}
+	expiresAt := policy.expiresAt(now, existing.AcquiredAt)
 
 	// We only update if user_id matches and lock is not expired (safety check, though if it's expired we could re-acquire).
 	// But strictly heartbeat implies active session.
@@ -99,6 +196,11 @@ func (m *LockManager) Heartbeat(ctx context.Context, fileID string, userID strin
 
 	out, err := m.client.UpdateItem(ctx, input)
 	if err != nil {
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			// Lost the lock between the status read and the update
+			return nil, ErrNotOwner
+		}
 		return nil, fmt.Errorf("failed to send heartbeat: %w", err)
 	}
 
@@ -124,6 +226,14 @@ func (m *LockManager) ReleaseLock(ctx context.Context, fileID string, userID str
 		},
 	})
 	if err != nil {
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			// The condition fails both for a missing item and another user's lock
+			if existing, statusErr := m.GetLockStatus(ctx, fileID); statusErr == nil && existing == nil {
+				return ErrLockNotFound
+			}
+			return ErrNotOwner
+		}
 		return fmt.Errorf("failed to release lock: %w", err)
 	}
 	return nil
@@ -131,6 +241,23 @@ func (m *LockManager) ReleaseLock(ctx context.Context, fileID string, userID str
 
 // GetLockStatus retrieves the current lock status.
 func (m *LockManager) GetLockStatus(ctx context.Context, fileID string) (*model.EditingSession, error) {
+	session, err := m.getLock(ctx, fileID)
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	// Check expiry
+	now := time.Now().Unix()
+	if session.ExpiresAt < now {
+		return nil, nil // Expired
+	}
+
+	return session, nil
+}
+
+// getLock reads the lock item for fileID, including expired items that
+// DynamoDB TTL has not yet removed.
+func (m *LockManager) getLock(ctx context.Context, fileID string) (*model.EditingSession, error) {
 	out, err := m.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(m.tableName),
 		Key: map[string]types.AttributeValue{
@@ -150,11 +277,32 @@ func (m *LockManager) GetLockStatus(ctx context.Context, fileID string) (*model.
 		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
 	}
 
-	// Check expiry
+	return &session, nil
+}
+
+// ListLocks returns all unexpired locks held by the given user.
+// It queries the user_id GSI so clients can release orphaned locks after a crash.
+func (m *LockManager) ListLocks(ctx context.Context, userID string) ([]model.EditingSession, error) {
 	now := time.Now().Unix()
-	if session.ExpiresAt < now {
-		return nil, nil // Expired
+
+	out, err := m.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(m.tableName),
+		IndexName:              aws.String(userIndexName),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		FilterExpression:       aws.String("expires_at >= :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user_id": &types.AttributeValueMemberS{Value: userID},
+			":now":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locks: %w", err)
 	}
 
-	return &session, nil
+	sessions := []model.EditingSession{}
+	if err := attributevalue.UnmarshalListOfMaps(out.Items, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sessions: %w", err)
+	}
+
+	return sessions, nil
 }
@@ -2,6 +2,7 @@ package session
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -10,7 +11,7 @@ func TestMockLocker_AcquireAndRelease(t *testing.T) {
 	m := NewMockLocker()
 	ctx := context.Background()
 
-	s, err := m.AcquireLock(ctx, "file1", "user1")
+	s, err := m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
 	if err != nil {
 		t.Fatalf("AcquireLock failed: %v", err)
 	}
@@ -33,29 +34,53 @@ func TestMockLocker_DoubleAcquire_SameUser(t *testing.T) {
 	m := NewMockLocker()
 	ctx := context.Background()
 
-	_, err := m.AcquireLock(ctx, "file1", "user1")
+	_, err := m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
 	if err != nil {
 		t.Fatalf("First acquire failed: %v", err)
 	}
 
-	_, err = m.AcquireLock(ctx, "file1", "user1")
+	_, err = m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
 	if err != nil {
 		t.Errorf("Same user should be able to re-acquire: %v", err)
 	}
 }
 
+func TestMockLocker_Reacquire_KeepsAcquiredAt(t *testing.T) {
+	m := NewMockLocker()
+	ctx := context.Background()
+
+	first, err := m.AcquireLock(ctx, "file1", "user1", HolderInfo{DisplayName: "Alice"})
+	if err != nil {
+		t.Fatalf("First acquire failed: %v", err)
+	}
+	acquiredAt := first.AcquiredAt
+
+	time.Sleep(1100 * time.Millisecond)
+
+	second, err := m.AcquireLock(ctx, "file1", "user1", HolderInfo{DisplayName: "Alice"})
+	if err != nil {
+		t.Fatalf("Re-acquire failed: %v", err)
+	}
+	if second.AcquiredAt != acquiredAt {
+		t.Errorf("Expected AcquiredAt to be preserved: original=%d, got=%d", acquiredAt, second.AcquiredAt)
+	}
+	if second.DisplayName != "Alice" {
+		t.Errorf("Expected display name 'Alice', got '%s'", second.DisplayName)
+	}
+}
+
 func TestMockLocker_DoubleAcquire_DifferentUser(t *testing.T) {
 	m := NewMockLocker()
 	ctx := context.Background()
 
-	_, err := m.AcquireLock(ctx, "file1", "user1")
+	_, err := m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
 	if err != nil {
 		t.Fatalf("First acquire failed: %v", err)
 	}
 
-	_, err = m.AcquireLock(ctx, "file1", "user2")
-	if err == nil {
-		t.Error("Expected error when different user tries to acquire existing lock")
+	_, err = m.AcquireLock(ctx, "file1", "user2", HolderInfo{})
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked when different user tries to acquire existing lock, got %v", err)
 	}
 }
 
@@ -63,7 +88,7 @@ func TestMockLocker_Heartbeat(t *testing.T) {
 	m := NewMockLocker()
 	ctx := context.Background()
 
-	s, _ := m.AcquireLock(ctx, "file1", "user1")
+	s, _ := m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
 	originalExpiry := s.ExpiresAt
 
 	// Wait a bit so time.Now() gives a different second
@@ -78,17 +103,56 @@ func TestMockLocker_Heartbeat(t *testing.T) {
 	}
 }
 
+func TestMockLocker_Heartbeat_MaxDuration(t *testing.T) {
+	m := NewMockLockerWithPolicy(Policy{TTL: 5 * time.Minute, MaxDuration: 1 * time.Second})
+	ctx := context.Background()
+
+	s, err := m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	if s.ExpiresAt != s.AcquiredAt+1 {
+		t.Errorf("Expected expiry clamped to max duration: acquired=%d, expires=%d", s.AcquiredAt, s.ExpiresAt)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	_, err = m.Heartbeat(ctx, "file1", "user1")
+	if !errors.Is(err, ErrMaxDurationExceeded) {
+		t.Errorf("Expected ErrMaxDurationExceeded, got %v", err)
+	}
+}
+
+func TestPolicy_MaxExpiresAt(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     Policy
+		acquiredAt int64
+		want       int64
+	}{
+		{"no cap", Policy{TTL: time.Minute}, 1000, 0},
+		{"with cap", Policy{TTL: time.Minute, MaxDuration: time.Hour}, 1000, 4600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.MaxExpiresAt(tt.acquiredAt); got != tt.want {
+				t.Errorf("MaxExpiresAt(%d) = %d, want %d", tt.acquiredAt, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestMockLocker_ExpiredLock(t *testing.T) {
 	m := NewMockLocker()
 	m.ttlDuration = -1 * time.Second // already expired
 	ctx := context.Background()
 
-	_, err := m.AcquireLock(ctx, "file1", "user1")
+	_, err := m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
 	if err != nil {
 		t.Fatalf("First acquire failed: %v", err)
 	}
 
-	_, err = m.AcquireLock(ctx, "file1", "user2")
+	_, err = m.AcquireLock(ctx, "file1", "user2", HolderInfo{})
 	if err != nil {
 		t.Errorf("Should acquire expired lock: %v", err)
 	}
@@ -98,7 +162,7 @@ func TestMockLocker_GetLockStatus_Active(t *testing.T) {
 	m := NewMockLocker()
 	ctx := context.Background()
 
-	m.AcquireLock(ctx, "file1", "user1")
+	m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
 
 	status, err := m.GetLockStatus(ctx, "file1")
 	if err != nil {
@@ -129,10 +193,24 @@ func TestMockLocker_ReleaseLock_WrongUser(t *testing.T) {
 	m := NewMockLocker()
 	ctx := context.Background()
 
-	m.AcquireLock(ctx, "file1", "user1")
+	m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
 
 	err := m.ReleaseLock(ctx, "file1", "user2")
-	if err == nil {
-		t.Error("Expected error when releasing lock owned by another user")
+	if !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner when releasing lock owned by another user, got %v", err)
+	}
+}
+
+func TestMockLocker_Heartbeat_Errors(t *testing.T) {
+	m := NewMockLocker()
+	ctx := context.Background()
+
+	if _, err := m.Heartbeat(ctx, "file1", "user1"); !errors.Is(err, ErrLockNotFound) {
+		t.Errorf("Expected ErrLockNotFound for missing lock, got %v", err)
+	}
+
+	m.AcquireLock(ctx, "file1", "user1", HolderInfo{})
+	if _, err := m.Heartbeat(ctx, "file1", "user2"); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner for another user's lock, got %v", err)
 	}
 }
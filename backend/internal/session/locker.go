@@ -2,15 +2,32 @@ package session
 
 import (
 	"context"
+	"errors"
 
 	"github.com/jun/gophdrive/backend/internal/model"
 )
 
+var (
+	// ErrLocked is returned by AcquireLock when another user holds an unexpired lock.
+	ErrLocked = errors.New("file is locked by another user")
+	// ErrNotOwner is returned when the lock exists but belongs to another user.
+	ErrNotOwner = errors.New("lock is not owned by user")
+	// ErrLockNotFound is returned when there is no unexpired lock on the file.
+	ErrLockNotFound = errors.New("lock not found")
+)
+
+// HolderInfo carries descriptive details about the user acquiring a lock.
+// It is stored alongside the lock so other collaborators can see who is editing.
+type HolderInfo struct {
+	DisplayName string
+	ClientID    string
+}
+
 // Locker defines the interface for file lock management.
 // Implementations manage session-based locking to prevent concurrent edit conflicts.
 type Locker interface {
 	// AcquireLock attempts to acquire a lock on a file for the given user.
-	AcquireLock(ctx context.Context, fileID, userID string) (*model.EditingSession, error)
+	AcquireLock(ctx context.Context, fileID, userID string, holder HolderInfo) (*model.EditingSession, error)
 
 	// Heartbeat extends the lock TTL if the user owns the lock.
 	Heartbeat(ctx context.Context, fileID, userID string) (*model.EditingSession, error)
@@ -20,4 +37,10 @@ type Locker interface {
 
 	// GetLockStatus retrieves the current lock status.
 	GetLockStatus(ctx context.Context, fileID string) (*model.EditingSession, error)
+
+	// ListLocks returns all unexpired locks held by the given user.
+	ListLocks(ctx context.Context, userID string) ([]model.EditingSession, error)
+
+	// Policy returns the TTL and maximum duration applied to locks.
+	Policy() Policy
 }
@@ -2,7 +2,6 @@ package session
 
 import (
 	"context"
-	"fmt"
 	"sync"
 	"time"
 
@@ -14,34 +13,53 @@ type MockLocker struct {
 	locks       map[string]*model.EditingSession
 	mu          sync.Mutex
 	ttlDuration time.Duration
+	maxDuration time.Duration
 }
 
-// NewMockLocker creates a new MockLocker with the default TTL.
+// NewMockLocker creates a new MockLocker with the default policy.
 func NewMockLocker() *MockLocker {
+	return NewMockLockerWithPolicy(DefaultPolicy())
+}
+
+// NewMockLockerWithPolicy creates a new MockLocker with the given policy.
+func NewMockLockerWithPolicy(policy Policy) *MockLocker {
 	return &MockLocker{
 		locks:       make(map[string]*model.EditingSession),
-		ttlDuration: DefaultTTL,
+		ttlDuration: policy.TTL,
+		maxDuration: policy.MaxDuration,
 	}
 }
 
-func (m *MockLocker) AcquireLock(ctx context.Context, fileID, userID string) (*model.EditingSession, error) {
+func (m *MockLocker) Policy() Policy {
+	return Policy{TTL: m.ttlDuration, MaxDuration: m.maxDuration}
+}
+
+func (m *MockLocker) AcquireLock(ctx context.Context, fileID, userID string, holder HolderInfo) (*model.EditingSession, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	policy := m.Policy()
 	now := time.Now().Unix()
-	expiresAt := now + int64(m.ttlDuration.Seconds())
+	acquiredAt := now
 
 	if existing, ok := m.locks[fileID]; ok {
 		// Allow if expired or same user
 		if existing.ExpiresAt > now && existing.UserID != userID {
-			return nil, fmt.Errorf("file is locked by another user")
+			return nil, ErrLocked
+		}
+		if existing.ExpiresAt > now && existing.UserID == userID && !policy.exceeded(now, existing.AcquiredAt) {
+			acquiredAt = existing.AcquiredAt
 		}
 	}
+	expiresAt := policy.expiresAt(now, acquiredAt)
 
 	session := &model.EditingSession{
-		FileID:    fileID,
-		UserID:    userID,
-		ExpiresAt: expiresAt,
+		FileID:      fileID,
+		UserID:      userID,
+		DisplayName: holder.DisplayName,
+		ClientID:    holder.ClientID,
+		AcquiredAt:  acquiredAt,
+		ExpiresAt:   expiresAt,
 	}
 	m.locks[fileID] = session
 	return session, nil
@@ -51,13 +69,26 @@ func (m *MockLocker) Heartbeat(ctx context.Context, fileID, userID string) (*mod
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	policy := m.Policy()
+	now := time.Now().Unix()
+
 	existing, ok := m.locks[fileID]
-	if !ok || existing.UserID != userID {
-		return nil, fmt.Errorf("lock not found or not owned by user")
+	if !ok {
+		return nil, ErrLockNotFound
 	}
-
-	expiresAt := time.Now().Unix() + int64(m.ttlDuration.Seconds())
-	existing.ExpiresAt = expiresAt
+	if existing.UserID != userID {
+		if existing.ExpiresAt < now {
+			return nil, ErrLockNotFound
+		}
+		return nil, ErrNotOwner
+	}
+	if policy.exceeded(now, existing.AcquiredAt) {
+		return nil, ErrMaxDurationExceeded
+	}
+	if existing.ExpiresAt < now {
+		return nil, ErrLockNotFound
+	}
+	existing.ExpiresAt = policy.expiresAt(now, existing.AcquiredAt)
 	return existing, nil
 }
 
@@ -66,8 +97,11 @@ func (m *MockLocker) ReleaseLock(ctx context.Context, fileID, userID string) err
 	defer m.mu.Unlock()
 
 	existing, ok := m.locks[fileID]
-	if !ok || existing.UserID != userID {
-		return fmt.Errorf("lock not found or not owned by user")
+	if !ok {
+		return ErrLockNotFound
+	}
+	if existing.UserID != userID {
+		return ErrNotOwner
 	}
 
 	delete(m.locks, fileID)
@@ -90,3 +124,17 @@ func (m *MockLocker) GetLockStatus(ctx context.Context, fileID string) (*model.E
 
 	return existing, nil
 }
+
+func (m *MockLocker) ListLocks(ctx context.Context, userID string) ([]model.EditingSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().Unix()
+	sessions := []model.EditingSession{}
+	for _, s := range m.locks {
+		if s.UserID == userID && s.ExpiresAt >= now {
+			sessions = append(sessions, *s)
+		}
+	}
+	return sessions, nil
+}
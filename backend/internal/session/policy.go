@@ -0,0 +1,54 @@
+package session
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultMaxDuration bounds how long a single editing session may be kept alive by heartbeats.
+const DefaultMaxDuration = 8 * time.Hour
+
+// ErrMaxDurationExceeded is returned by Heartbeat once a lock has been held for longer than the policy allows.
+var ErrMaxDurationExceeded = errors.New("lock has reached its maximum duration")
+
+// Policy controls lock lifetimes.
+type Policy struct {
+	// TTL is the lifetime granted by each acquire or heartbeat.
+	TTL time.Duration
+	// MaxDuration caps the total age of a lock. Zero disables the cap.
+	MaxDuration time.Duration
+}
+
+// DefaultPolicy returns the policy used when nothing is configured.
+func DefaultPolicy() Policy {
+	return Policy{TTL: DefaultTTL, MaxDuration: DefaultMaxDuration}
+}
+
+// HeartbeatInterval is the suggested client heartbeat period (half the TTL).
+func (p Policy) HeartbeatInterval() time.Duration {
+	return p.TTL / 2
+}
+
+// MaxExpiresAt returns the latest expiry allowed for a lock acquired at acquiredAt,
+// or 0 if there is no cap.
+func (p Policy) MaxExpiresAt(acquiredAt int64) int64 {
+	if p.MaxDuration <= 0 {
+		return 0
+	}
+	return acquiredAt + int64(p.MaxDuration.Seconds())
+}
+
+// expiresAt computes the expiry for a lock refreshed at now, clamped to the max duration.
+func (p Policy) expiresAt(now, acquiredAt int64) int64 {
+	expiresAt := now + int64(p.TTL.Seconds())
+	if limit := p.MaxExpiresAt(acquiredAt); limit != 0 && expiresAt > limit {
+		return limit
+	}
+	return expiresAt
+}
+
+// exceeded reports whether a lock acquired at acquiredAt may no longer be extended.
+func (p Policy) exceeded(now, acquiredAt int64) bool {
+	limit := p.MaxExpiresAt(acquiredAt)
+	return limit != 0 && now >= limit
+}
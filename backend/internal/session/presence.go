@@ -0,0 +1,135 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/model"
+)
+
+// PresenceEventType identifies what happened to a lock.
+type PresenceEventType string
+
+const (
+	PresenceAcquired  PresenceEventType = "acquired"
+	PresenceHeartbeat PresenceEventType = "heartbeat"
+	PresenceReleased  PresenceEventType = "released"
+)
+
+// PresenceEvent describes a change in who is editing a file.
+type PresenceEvent struct {
+	Type        PresenceEventType `json:"type"`
+	FileID      string            `json:"file_id"`
+	UserID      string            `json:"user_id"`
+	DisplayName string            `json:"display_name,omitempty"`
+	ExpiresAt   int64             `json:"expires_at,omitempty"`
+	Timestamp   int64             `json:"timestamp"`
+}
+
+// Publisher receives presence events.
+type Publisher interface {
+	Publish(ctx context.Context, event PresenceEvent)
+}
+
+// Broadcaster fans presence events out to in-process subscribers.
+// It only reaches clients connected to the same process, so it is useful for
+// long-lived servers (cmd/server) rather than individual Lambda invocations.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan PresenceEvent]map[string]bool
+}
+
+// NewBroadcaster creates an empty Broadcaster.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[chan PresenceEvent]map[string]bool)}
+}
+
+// Subscribe registers a listener for events on the given files; with none,
+// it receives nothing. Events name the user editing a file, so callers
+// check the subscriber may read each file first.
+// The returned function unsubscribes and closes the channel.
+func (b *Broadcaster) Subscribe(fileIDs ...string) (<-chan PresenceEvent, func()) {
+	filter := make(map[string]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		filter[id] = true
+	}
+
+	ch := make(chan PresenceEvent, 16)
+	b.mu.Lock()
+	b.subscribers[ch] = filter
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers the event to matching subscribers. Slow subscribers drop events
+// rather than blocking lock operations.
+func (b *Broadcaster) Publish(ctx context.Context, event PresenceEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch, filter := range b.subscribers {
+		if !filter[event.FileID] {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// NotifyingLocker wraps a Locker and publishes presence events for successful
+// acquire, heartbeat and release operations.
+type NotifyingLocker struct {
+	Locker
+	publisher Publisher
+}
+
+// NewNotifyingLocker creates a Locker that reports lock changes to publisher.
+func NewNotifyingLocker(locker Locker, publisher Publisher) *NotifyingLocker {
+	return &NotifyingLocker{Locker: locker, publisher: publisher}
+}
+
+func (n *NotifyingLocker) AcquireLock(ctx context.Context, fileID, userID string, holder HolderInfo) (*model.EditingSession, error) {
+	s, err := n.Locker.AcquireLock(ctx, fileID, userID, holder)
+	if err == nil {
+		n.publish(ctx, PresenceAcquired, s)
+	}
+	return s, err
+}
+
+func (n *NotifyingLocker) Heartbeat(ctx context.Context, fileID, userID string) (*model.EditingSession, error) {
+	s, err := n.Locker.Heartbeat(ctx, fileID, userID)
+	if err == nil {
+		n.publish(ctx, PresenceHeartbeat, s)
+	}
+	return s, err
+}
+
+func (n *NotifyingLocker) ReleaseLock(ctx context.Context, fileID, userID string) error {
+	err := n.Locker.ReleaseLock(ctx, fileID, userID)
+	if err == nil {
+		n.publish(ctx, PresenceReleased, &model.EditingSession{FileID: fileID, UserID: userID})
+	}
+	return err
+}
+
+func (n *NotifyingLocker) publish(ctx context.Context, eventType PresenceEventType, s *model.EditingSession) {
+	n.publisher.Publish(ctx, PresenceEvent{
+		Type:        eventType,
+		FileID:      s.FileID,
+		UserID:      s.UserID,
+		DisplayName: s.DisplayName,
+		ExpiresAt:   s.ExpiresAt,
+		Timestamp:   time.Now().Unix(),
+	})
+}
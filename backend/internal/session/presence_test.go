@@ -0,0 +1,63 @@
+package session
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNotifyingLocker_PublishesEvents(t *testing.T) {
+	b := NewBroadcaster()
+	locker := NewNotifyingLocker(NewMockLocker(), b)
+	ctx := context.Background()
+
+	events, unsubscribe := b.Subscribe("file1")
+	defer unsubscribe()
+
+	locker.AcquireLock(ctx, "file1", "user1", HolderInfo{DisplayName: "Alice"})
+	locker.AcquireLock(ctx, "file2", "user1", HolderInfo{}) // filtered out
+	locker.Heartbeat(ctx, "file1", "user1")
+	locker.ReleaseLock(ctx, "file1", "user1")
+
+	want := []PresenceEventType{PresenceAcquired, PresenceHeartbeat, PresenceReleased}
+	for _, w := range want {
+		e := <-events
+		if e.Type != w {
+			t.Errorf("Expected event %q, got %q", w, e.Type)
+		}
+		if e.FileID != "file1" {
+			t.Errorf("Expected file 'file1', got '%s'", e.FileID)
+		}
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no further events, got %d", len(events))
+	}
+}
+
+func TestNotifyingLocker_NoEventOnFailure(t *testing.T) {
+	b := NewBroadcaster()
+	locker := NewNotifyingLocker(NewMockLocker(), b)
+	ctx := context.Background()
+
+	locker.AcquireLock(ctx, "file1", "user1", HolderInfo{})
+
+	events, unsubscribe := b.Subscribe("file1")
+	defer unsubscribe()
+
+	if _, err := locker.AcquireLock(ctx, "file1", "user2", HolderInfo{}); err == nil {
+		t.Fatal("Expected acquire by another user to fail")
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events for failed acquire, got %d", len(events))
+	}
+}
+
+func TestBroadcaster_SubscribeWithoutFiles(t *testing.T) {
+	b := NewBroadcaster()
+	events, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	b.Publish(context.Background(), PresenceEvent{Type: PresenceAcquired, FileID: "file1"})
+	if len(events) != 0 {
+		t.Errorf("Expected no events without files, got %d", len(events))
+	}
+}
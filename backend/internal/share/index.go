@@ -0,0 +1,235 @@
+package share
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// folderMIMEType is the MIME type of folders in every adapter.
+const folderMIMEType = "application/vnd.google-apps.folder"
+
+// inheritedPrefix starts the note_id of the index entries the Shares table
+// holds beside the shares, so they never show up as shares of a note.
+const inheritedPrefix = "inherited#"
+
+// BatchWriteItem returns the items it could not write when the table is
+// throttled. batchWrite resends them up to batchRetries times, waiting
+// batchBackoff and then twice as long each time.
+const (
+	batchRetries = 8
+	batchBackoff = 50 * time.Millisecond
+)
+
+// entry records that the note or folder ItemID of OwnerID is Depth folders
+// below Via, a folder shared with Email. A folder share has an entry for
+// each item inside it, so Find looks up one item instead of walking up its
+// owner's folders. The email key is Email and Via, so the entries of one
+// share are listed on the email GSI without those of the user's shares.
+type entry struct {
+	NoteID  string `dynamodbav:"note_id"`
+	Key     string `dynamodbav:"email"`
+	ItemID  string `dynamodbav:"item_id"`
+	Email   string `dynamodbav:"shared_with"`
+	Via     string `dynamodbav:"via"`
+	OwnerID string `dynamodbav:"owner_id"`
+	Depth   int    `dynamodbav:"depth"`
+}
+
+func newEntry(itemID, email, via, ownerID string, depth int) entry {
+	return entry{
+		NoteID:  inheritedPrefix + itemID,
+		Key:     entryKey(email, via),
+		ItemID:  itemID,
+		Email:   email,
+		Via:     via,
+		OwnerID: ownerID,
+		Depth:   depth,
+	}
+}
+
+func entryKey(email, via string) string {
+	return email + "#" + via
+}
+
+// IndexFolder indexes the notes and folders inside the shared folder of sh,
+// walking it once in its owner's storage, and drops the entries of items
+// no longer there. Items GophDrive creates in the folder later are indexed
+// as they are created (see Provider); ones added to it outside GophDrive
+// are covered once the folder is shared again.
+func (s *Store) IndexFolder(ctx context.Context, storage adapter.StorageAdapter, sh Share) error {
+	var entries []entry
+	keep := make(map[string]bool)
+	level := []string{sh.NoteID}
+	for depth := 1; depth <= MaxDepth && len(level) > 0; depth++ {
+		var next []string
+		for _, folderID := range level {
+			files, err := storage.ListFiles(ctx, folderID)
+			if err != nil {
+				return fmt.Errorf("failed to list shared folder: %w", err)
+			}
+			for _, f := range files {
+				entries = append(entries, newEntry(f.ID, sh.Email, sh.NoteID, sh.OwnerID, depth))
+				keep[inheritedPrefix+f.ID] = true
+				if f.MIMEType == folderMIMEType {
+					next = append(next, f.ID)
+				}
+			}
+		}
+		level = next
+	}
+	if err := s.putEntries(ctx, entries); err != nil {
+		return err
+	}
+	return s.unindex(ctx, sh.NoteID, sh.Email, keep)
+}
+
+// Inherit indexes the note or folder itemID, just created by ownerID in
+// parentID, under the shares of the folders parentID is in.
+func (s *Store) Inherit(ctx context.Context, ownerID, parentID, itemID string) error {
+	shares, err := s.ListForNote(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	var entries []entry
+	for _, sh := range shares {
+		if sh.Folder && sh.OwnerID == ownerID {
+			entries = append(entries, newEntry(itemID, sh.Email, parentID, ownerID, 1))
+		}
+	}
+	above, err := s.entries(ctx, "", "note_id", inheritedPrefix+parentID)
+	if err != nil {
+		return err
+	}
+	for _, e := range above {
+		if e.OwnerID == ownerID && e.Depth < MaxDepth {
+			entries = append(entries, newEntry(itemID, e.Email, e.Via, ownerID, e.Depth+1))
+		}
+	}
+	return s.putEntries(ctx, entries)
+}
+
+// unindex removes the entries of the folder share of folderID with email,
+// except those of the items in keep.
+func (s *Store) unindex(ctx context.Context, folderID, email string, keep map[string]bool) error {
+	entries, err := s.entries(ctx, emailIndexName, "email", entryKey(email, folderID))
+	if err != nil {
+		return err
+	}
+	entries = slices.DeleteFunc(entries, func(e entry) bool { return keep[e.NoteID] })
+	return s.deleteEntries(ctx, entries)
+}
+
+func (s *Store) entries(ctx context.Context, index, attr, value string) ([]entry, error) {
+	var entries []entry
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for noteID, byKey := range s.indexed {
+			for key, e := range byKey {
+				if attr == "note_id" && noteID == value || attr == "email" && key == value {
+					entries = append(entries, e)
+				}
+			}
+		}
+		return entries, nil
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String(attr + " = :v"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v": &types.AttributeValueMemberS{Value: value},
+		},
+	}
+	if index != "" {
+		input.IndexName = aws.String(index)
+	}
+	paginator := dynamodb.NewQueryPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query shared folder index: %w", err)
+		}
+		var page []entry
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal shared folder index: %w", err)
+		}
+		entries = append(entries, page...)
+	}
+	return entries, nil
+}
+
+func (s *Store) putEntries(ctx context.Context, entries []entry) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, e := range entries {
+			if s.indexed[e.NoteID] == nil {
+				s.indexed[e.NoteID] = make(map[string]entry)
+			}
+			s.indexed[e.NoteID][e.Key] = e
+		}
+		return nil
+	}
+
+	writes := make([]types.WriteRequest, 0, len(entries))
+	for _, e := range entries {
+		item, err := attributevalue.MarshalMap(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal shared folder index: %w", err)
+		}
+		writes = append(writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+	return s.batchWrite(ctx, writes)
+}
+
+func (s *Store) deleteEntries(ctx context.Context, entries []entry) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, e := range entries {
+			delete(s.indexed[e.NoteID], e.Key)
+		}
+		return nil
+	}
+
+	writes := make([]types.WriteRequest, 0, len(entries))
+	for _, e := range entries {
+		writes = append(writes, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key(e.NoteID, e.Key)}})
+	}
+	return s.batchWrite(ctx, writes)
+}
+
+func (s *Store) batchWrite(ctx context.Context, writes []types.WriteRequest) error {
+	// BatchWriteItem writes at most 25 items per call.
+	for chunk := range slices.Chunk(writes, 25) {
+		request := map[string][]types.WriteRequest{s.tableName: chunk}
+		for retry := 0; len(request) > 0; retry++ {
+			if retry > 0 {
+				if retry > batchRetries {
+					return fmt.Errorf("failed to write shared folder index: %d items unprocessed after %d retries", len(request[s.tableName]), batchRetries)
+				}
+				select {
+				case <-time.After(batchBackoff << (retry - 1)):
+				case <-ctx.Done():
+					return fmt.Errorf("failed to write shared folder index: %w", ctx.Err())
+				}
+			}
+			out, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: request})
+			if err != nil {
+				return fmt.Errorf("failed to write shared folder index: %w", err)
+			}
+			request = out.UnprocessedItems
+		}
+	}
+	return nil
+}
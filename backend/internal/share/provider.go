@@ -0,0 +1,72 @@
+package share
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// Provider wraps a StorageProvider so that notes and folders created in a
+// shared folder are shared with it, by indexing them as they are created.
+type Provider struct {
+	adapter.StorageProvider
+	store *Store
+}
+
+// NewProvider creates a StorageProvider that keeps the index of shared
+// folders in store up to date.
+func NewProvider(provider adapter.StorageProvider, store *Store) *Provider {
+	return &Provider{StorageProvider: provider, store: store}
+}
+
+// GetAdapter returns the wrapped adapter for userID with indexing applied.
+func (p *Provider) GetAdapter(ctx context.Context, userID string) (adapter.StorageAdapter, error) {
+	storage, err := p.StorageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &indexingAdapter{StorageAdapter: storage, store: p.store, userID: userID}, nil
+}
+
+// indexingAdapter indexes the items it creates. Index failures are logged
+// rather than failing the write, since the item was already created; the
+// owner shares the folder again to index it.
+type indexingAdapter struct {
+	adapter.StorageAdapter
+	store  *Store
+	userID string
+}
+
+func (a *indexingAdapter) inherit(ctx context.Context, parents []string, itemID string) {
+	if len(parents) == 0 || parents[0] == "" {
+		return
+	}
+	if err := a.store.Inherit(ctx, a.userID, parents[0], itemID); err != nil {
+		slog.ErrorContext(ctx, "Indexing shared folder failed", "error", err)
+	}
+}
+
+func (a *indexingAdapter) CreateFile(ctx context.Context, name string, content []byte, folderID string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.CreateFile(ctx, name, content, folderID)
+	if err == nil {
+		a.inherit(ctx, []string{folderID}, meta.ID)
+	}
+	return meta, err
+}
+
+func (a *indexingAdapter) CreateFolder(ctx context.Context, name string, parents []string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.CreateFolder(ctx, name, parents)
+	if err == nil {
+		a.inherit(ctx, parents, meta.ID)
+	}
+	return meta, err
+}
+
+func (a *indexingAdapter) DuplicateFile(ctx context.Context, fileID string) (*adapter.FileMetadata, error) {
+	meta, err := a.StorageAdapter.DuplicateFile(ctx, fileID)
+	if err == nil {
+		a.inherit(ctx, meta.Parents, meta.ID)
+	}
+	return meta, err
+}
@@ -0,0 +1,300 @@
+// Package share stores notes and folders that users share with each other.
+//
+// A share names the other user by email address, so a note can be shared
+// before that user has signed in; it applies to whoever signs in with the
+// address. Shared notes stay in their owner's storage and are read and
+// written there on the other user's behalf.
+package share
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Permissions a share can give.
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+)
+
+// emailIndexName is the GSI on Shares keyed by email.
+const emailIndexName = "email-index"
+
+// MaxDepth bounds the folders walked below a shared folder to index its
+// items, and above a note to check it is in its owner's base folder.
+const MaxDepth = 32
+
+var (
+	// ErrNotFound is returned for a note that is not shared with the user.
+	ErrNotFound = errors.New("share not found")
+	// ErrReadOnly is returned when a user with read permission changes a
+	// shared note.
+	ErrReadOnly = errors.New("this note is shared with you read-only")
+)
+
+// Share gives the user with Email access to a note or folder of OwnerID.
+// A folder share covers the notes in the folder and its subfolders. Name
+// is the note's or folder's name when it was shared.
+type Share struct {
+	NoteID     string    `json:"noteId" dynamodbav:"note_id"`
+	Email      string    `json:"email" dynamodbav:"email"`
+	OwnerID    string    `json:"-" dynamodbav:"owner_id"`
+	OwnerName  string    `json:"ownerName,omitempty" dynamodbav:"owner_name,omitempty"`
+	OwnerEmail string    `json:"ownerEmail,omitempty" dynamodbav:"owner_email,omitempty"`
+	Name       string    `json:"name" dynamodbav:"name"`
+	Folder     bool      `json:"folder,omitempty" dynamodbav:"folder,omitempty"`
+	Permission string    `json:"permission" dynamodbav:"permission"`
+	CreatedAt  time.Time `json:"createdAt" dynamodbav:"created_at"`
+}
+
+// Allows reports whether the share gives permission, where write includes
+// read.
+func (s *Share) Allows(permission string) bool {
+	return s.Permission == PermissionWrite || permission == PermissionRead
+}
+
+// NormalizeEmail returns the address in s in lower case, or an error if s
+// is not a plain email address.
+func NormalizeEmail(s string) (string, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != strings.TrimSpace(s) {
+		return "", fmt.Errorf("%q is not an email address", s)
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// Store persists shares in a DynamoDB table keyed by note_id and email,
+// with a GSI on email. If client is nil, it uses an in-memory map (for
+// tests).
+type Store struct {
+	client    *dynamodb.Client
+	tableName string
+
+	// Fallback for tests
+	shares  map[string]map[string]Share // by note ID, then email
+	indexed map[string]map[string]entry // by note_id, then email key
+	mu      sync.Mutex
+}
+
+// NewStore creates a new share Store.
+func NewStore(client *dynamodb.Client, tableName string) *Store {
+	return &Store{
+		client:    client,
+		tableName: tableName,
+		shares:    make(map[string]map[string]Share),
+		indexed:   make(map[string]map[string]entry),
+	}
+}
+
+func key(noteID, email string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"note_id": &types.AttributeValueMemberS{Value: noteID},
+		"email":   &types.AttributeValueMemberS{Value: email},
+	}
+}
+
+// Put stores sh, replacing an existing share of the note with the same user.
+func (s *Store) Put(ctx context.Context, sh Share) error {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.shares[sh.NoteID] == nil {
+			s.shares[sh.NoteID] = make(map[string]Share)
+		}
+		s.shares[sh.NoteID][sh.Email] = sh
+		return nil
+	}
+
+	item, err := attributevalue.MarshalMap(sh)
+	if err != nil {
+		return fmt.Errorf("failed to marshal share: %w", err)
+	}
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save share: %w", err)
+	}
+	return nil
+}
+
+// Get returns the share of a note with email, or ErrNotFound.
+func (s *Store) Get(ctx context.Context, noteID, email string) (*Share, error) {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		sh, ok := s.shares[noteID][email]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return &sh, nil
+	}
+
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       key(noteID, email),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share: %w", err)
+	}
+	if out.Item == nil {
+		return nil, ErrNotFound
+	}
+	var sh Share
+	if err := attributevalue.UnmarshalMap(out.Item, &sh); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal share: %w", err)
+	}
+	return &sh, nil
+}
+
+// ListForNote returns the shares of a note, by email.
+func (s *Store) ListForNote(ctx context.Context, noteID string) ([]Share, error) {
+	return s.query(ctx, "", "note_id", noteID)
+}
+
+// ListForEmail returns the shares with email, newest first.
+func (s *Store) ListForEmail(ctx context.Context, email string) ([]Share, error) {
+	shares, err := s.query(ctx, emailIndexName, "email", email)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(shares, func(a, b Share) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return shares, nil
+}
+
+func (s *Store) query(ctx context.Context, index, attr, value string) ([]Share, error) {
+	shares := []Share{}
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for noteID, byEmail := range s.shares {
+			for email, sh := range byEmail {
+				if attr == "note_id" && noteID == value || attr == "email" && email == value {
+					shares = append(shares, sh)
+				}
+			}
+		}
+		slices.SortFunc(shares, func(a, b Share) int {
+			return strings.Compare(a.Email, b.Email)
+		})
+		return shares, nil
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String(attr + " = :v"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v": &types.AttributeValueMemberS{Value: value},
+		},
+	}
+	if index != "" {
+		input.IndexName = aws.String(index)
+	}
+	paginator := dynamodb.NewQueryPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query shares: %w", err)
+		}
+		var page []Share
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal shares: %w", err)
+		}
+		shares = append(shares, page...)
+	}
+	return shares, nil
+}
+
+// Delete removes the share of a note with email, and for a folder the
+// index of its items. It returns ErrNotFound if there is none.
+func (s *Store) Delete(ctx context.Context, noteID, email string) error {
+	if s.client == nil {
+		s.mu.Lock()
+		_, ok := s.shares[noteID][email]
+		delete(s.shares[noteID], email)
+		s.mu.Unlock()
+		if !ok {
+			return ErrNotFound
+		}
+		return s.unindex(ctx, noteID, email, nil)
+	}
+
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(s.tableName),
+		Key:                 key(noteID, email),
+		ConditionExpression: aws.String("attribute_exists(note_id)"),
+	})
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete share: %w", err)
+	}
+	return s.unindex(ctx, noteID, email, nil)
+}
+
+// DeleteNote removes every share of a deleted note, and its entries in the
+// index of shared folders.
+func (s *Store) DeleteNote(ctx context.Context, noteID string) error {
+	shares, err := s.ListForNote(ctx, noteID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, sh := range shares {
+		if err := s.Delete(ctx, noteID, sh.Email); err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	entries, err := s.entries(ctx, "", "note_id", inheritedPrefix+noteID)
+	if err == nil {
+		err = s.deleteEntries(ctx, entries)
+	}
+	return errors.Join(append(errs, err)...)
+}
+
+// Find returns the share that gives email access to a note: a share of the
+// note itself, or else of the nearest shared folder above it, found through
+// the index of the folder's items. It returns ErrNotFound if there is none.
+func (s *Store) Find(ctx context.Context, email, noteID string) (*Share, error) {
+	if sh, err := s.Get(ctx, noteID, email); !errors.Is(err, ErrNotFound) {
+		return sh, err
+	}
+
+	entries, err := s.entries(ctx, "", "note_id", inheritedPrefix+noteID)
+	if err != nil {
+		return nil, err
+	}
+	entries = slices.DeleteFunc(entries, func(e entry) bool { return e.Email != email })
+	slices.SortFunc(entries, func(a, b entry) int { return cmp.Compare(a.Depth, b.Depth) })
+	for _, e := range entries {
+		// The folder's share, not the entry, decides the permission.
+		sh, err := s.Get(ctx, e.Via, email)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sh.OwnerID == e.OwnerID {
+			return sh, nil
+		}
+	}
+	return nil, ErrNotFound
+}
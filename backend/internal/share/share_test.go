@@ -0,0 +1,114 @@
+package share
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jun/gophdrive/backend/internal/adapter/memory"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	if got, err := NormalizeEmail(" Bob@Example.com "); err != nil || got != "bob@example.com" {
+		t.Errorf("NormalizeEmail = %q, %v", got, err)
+	}
+	for _, s := range []string{"", "bob", "Bob <bob@example.com>", "a@b.com, c@d.com"} {
+		if _, err := NormalizeEmail(s); err == nil {
+			t.Errorf("NormalizeEmail(%q) succeeded", s)
+		}
+	}
+}
+
+func TestShare_Allows(t *testing.T) {
+	read, write := Share{Permission: PermissionRead}, Share{Permission: PermissionWrite}
+	if !read.Allows(PermissionRead) || read.Allows(PermissionWrite) || !write.Allows(PermissionWrite) || !write.Allows(PermissionRead) {
+		t.Error("write must include read, and read only read")
+	}
+}
+
+func TestStore_Find(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil, "")
+	provider := NewProvider(memory.NewProvider(nil, nil), s)
+	storage, _ := provider.GetAdapter(ctx, "alice")
+	work, _ := storage.CreateFolder(ctx, "Work", nil)
+	plans, _ := storage.CreateFolder(ctx, "Plans", []string{work.ID})
+	note, _ := storage.CreateFile(ctx, "Q3.md", []byte("# Q3"), plans.ID)
+	other, _ := storage.CreateFile(ctx, "Diary.md", []byte("private"), "")
+
+	if _, err := s.Find(ctx, "bob@example.com", note.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Find before sharing error = %v", err)
+	}
+
+	folderShare := Share{NoteID: work.ID, Email: "bob@example.com", OwnerID: "alice", Folder: true, Permission: PermissionRead, CreatedAt: time.Now()}
+	if err := s.IndexFolder(ctx, storage, folderShare); err != nil {
+		t.Fatal(err)
+	}
+	// The index alone gives no access.
+	if _, err := s.Find(ctx, "bob@example.com", note.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Find before the folder share is stored error = %v", err)
+	}
+	s.Put(ctx, folderShare)
+	sh, err := s.Find(ctx, "bob@example.com", note.ID)
+	if err != nil || sh.NoteID != work.ID {
+		t.Fatalf("Find in a shared folder = %+v, %v", sh, err)
+	}
+	if _, err := s.Find(ctx, "bob@example.com", other.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find outside the folder error = %v", err)
+	}
+	if _, err := s.Find(ctx, "eve@example.com", note.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find for another user error = %v", err)
+	}
+	if shares, _ := s.ListForEmail(ctx, "bob@example.com"); len(shares) != 1 {
+		t.Errorf("ListForEmail includes the index: %+v", shares)
+	}
+
+	// Items created in the folder later are indexed by the Provider.
+	later, _ := storage.CreateFolder(ctx, "Later", []string{plans.ID})
+	draft, _ := storage.CreateFile(ctx, "Draft.md", nil, later.ID)
+	if sh, err := s.Find(ctx, "bob@example.com", draft.ID); err != nil || sh.NoteID != work.ID {
+		t.Errorf("Find of a note created in the folder = %+v, %v", sh, err)
+	}
+
+	// The nearest shared folder wins, and unsharing it falls back to the
+	// one above.
+	plansShare := Share{NoteID: plans.ID, Email: "bob@example.com", OwnerID: "alice", Folder: true, Permission: PermissionWrite, CreatedAt: time.Now()}
+	s.IndexFolder(ctx, storage, plansShare)
+	s.Put(ctx, plansShare)
+	if sh, _ := s.Find(ctx, "bob@example.com", draft.ID); sh.NoteID != plans.ID {
+		t.Errorf("Find under two shared folders = %+v", sh)
+	}
+	if err := s.Delete(ctx, plans.ID, "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if sh, _ := s.Find(ctx, "bob@example.com", draft.ID); sh.NoteID != work.ID {
+		t.Errorf("Find after unsharing the inner folder = %+v", sh)
+	}
+
+	// A share of the note itself comes first.
+	s.Put(ctx, Share{NoteID: note.ID, Email: "bob@example.com", OwnerID: "alice", Permission: PermissionWrite, CreatedAt: time.Now()})
+	if sh, _ := s.Find(ctx, "bob@example.com", note.ID); sh.Permission != PermissionWrite {
+		t.Errorf("Find = %+v", sh)
+	}
+
+	if err := s.DeleteNote(ctx, note.ID); err != nil {
+		t.Fatal(err)
+	}
+	if shares, _ := s.ListForEmail(ctx, "bob@example.com"); len(shares) != 1 || shares[0].NoteID != work.ID {
+		t.Errorf("shares after DeleteNote = %+v", shares)
+	}
+	if err := s.Delete(ctx, note.ID, "bob@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of a missing share error = %v", err)
+	}
+
+	if err := s.Delete(ctx, work.ID, "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Find(ctx, "bob@example.com", draft.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find after unsharing error = %v", err)
+	}
+	if len(s.indexed[inheritedPrefix+draft.ID]) != 0 {
+		t.Errorf("index after unsharing = %+v", s.indexed[inheritedPrefix+draft.ID])
+	}
+}
@@ -0,0 +1,67 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// ConverseAPI is the part of the Bedrock runtime client BedrockProvider
+// uses, so tests can stand in for it.
+type ConverseAPI interface {
+	Converse(ctx context.Context, params *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error)
+}
+
+// BedrockProvider completes prompts with a model on Amazon Bedrock through
+// the Converse API, so any text model that supports it can be configured.
+type BedrockProvider struct {
+	client  ConverseAPI
+	modelID string
+}
+
+// NewBedrockProvider creates a provider that calls modelID, a model or
+// inference profile ID, through client.
+func NewBedrockProvider(client ConverseAPI, modelID string) *BedrockProvider {
+	return &BedrockProvider{client: client, modelID: modelID}
+}
+
+// Complete implements Provider. The temperature is kept low: summaries and
+// suggestions should follow the note, not vary from call to call.
+func (p *BedrockProvider) Complete(ctx context.Context, system, prompt string, maxTokens int32) (string, error) {
+	out, err := p.client.Converse(ctx, &bedrockruntime.ConverseInput{
+		ModelId: aws.String(p.modelID),
+		System:  []types.SystemContentBlock{&types.SystemContentBlockMemberText{Value: system}},
+		Messages: []types.Message{{
+			Role:    types.ConversationRoleUser,
+			Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: prompt}},
+		}},
+		InferenceConfig: &types.InferenceConfiguration{
+			MaxTokens:   aws.Int32(maxTokens),
+			Temperature: aws.Float32(0.2),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to invoke model %s: %w", p.modelID, err)
+	}
+
+	msg, ok := out.Output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return "", errors.New("model returned no message")
+	}
+	var b strings.Builder
+	for _, block := range msg.Value.Content {
+		if text, ok := block.(*types.ContentBlockMemberText); ok {
+			b.WriteString(text.Value)
+		}
+	}
+	reply := strings.TrimSpace(b.String())
+	if reply == "" {
+		return "", errors.New("model returned an empty reply")
+	}
+	return reply, nil
+}
@@ -0,0 +1,101 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxSuggestedTags is how many tags Suggest returns at most.
+	MaxSuggestedTags = 5
+	// maxTagLength and maxTitleLength bound suggestions, in characters.
+	maxTagLength   = 32
+	maxTitleLength = 80
+	// suggestMaxTokens leaves room for the JSON around a title and tags.
+	suggestMaxTokens = 200
+)
+
+// Suggestion is a proposed title and tags for a note. Tags are lower-case,
+// without a leading '#', and use '-' between words.
+type Suggestion struct {
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+}
+
+// Suggest proposes a title and tags for the content of a note called name.
+func (a *Assistant) Suggest(ctx context.Context, name, content string) (*Suggestion, error) {
+	prompt, err := notePrompt("Suggest a title and tags for", name, content)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := a.provider.Complete(ctx, suggestPrompt, prompt, suggestMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	return parseSuggestion(reply)
+}
+
+// parseSuggestion reads the model's JSON reply, ignoring any text around
+// the object, and cleans up the title and tags.
+func parseSuggestion(reply string) (*Suggestion, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("model reply is not a JSON object")
+	}
+	var raw Suggestion
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &raw); err != nil {
+		return nil, errors.New("model reply is not a valid suggestion")
+	}
+
+	title := strings.TrimSpace(strings.Trim(strings.TrimSpace(raw.Title), `"#`))
+	s := &Suggestion{Title: clip(title, maxTitleLength), Tags: []string{}}
+	seen := make(map[string]bool)
+	for _, t := range raw.Tags {
+		tag := normalizeTag(t)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		s.Tags = append(s.Tags, tag)
+		if len(s.Tags) == MaxSuggestedTags {
+			break
+		}
+	}
+	return s, nil
+}
+
+// normalizeTag lower-cases tag, drops a leading '#' and joins its words
+// with '-'. Characters other than letters, digits, '-', '_' and '/' are
+// dropped.
+func normalizeTag(tag string) string {
+	tag = strings.TrimLeft(strings.TrimSpace(tag), "#")
+	var b strings.Builder
+	for _, word := range strings.Fields(strings.ToLower(tag)) {
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '/' {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return strings.Trim(clip(b.String(), maxTagLength), "-")
+}
+
+// clip cuts s to at most n characters.
+func clip(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// suggestPrompt asks for a JSON object so the reply can be parsed.
+const suggestPrompt = `You suggest a title and tags for notes written in Markdown, in the language of the note.
+Reply with a JSON object only, like {"title": "Q3 planning meeting", "tags": ["planning", "meetings"]}.
+The title is short and specific, without Markdown. Give up to 5 tags: single lower-case words or short phrases joined with "-", describing the topics of the note.`
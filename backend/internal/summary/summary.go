@@ -0,0 +1,77 @@
+// Package summary uses a language model to summarize notes and to suggest
+// their title and tags. The model sits behind the Provider interface;
+// BedrockProvider is the implementation used in production.
+package summary
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxInputBytes is how much of a note is sent to the model. Longer notes
+// are cut at this length, which keeps the request within the model's
+// context and its cost bounded.
+const MaxInputBytes = 100 << 10
+
+// summaryMaxTokens bounds the length of a summary.
+const summaryMaxTokens = 600
+
+// ErrEmpty is returned for a note with nothing to summarize.
+var ErrEmpty = errors.New("the note is empty")
+
+// Provider completes a prompt with a language model, following the system
+// instructions, in at most maxTokens tokens of output.
+type Provider interface {
+	Complete(ctx context.Context, system, prompt string, maxTokens int32) (string, error)
+}
+
+// Assistant writes summaries and suggestions for notes with a Provider.
+type Assistant struct {
+	provider Provider
+}
+
+// NewAssistant creates an Assistant that uses provider.
+func NewAssistant(provider Provider) *Assistant {
+	return &Assistant{provider: provider}
+}
+
+// Summarize returns a short Markdown summary of the content of a note called
+// name.
+func (a *Assistant) Summarize(ctx context.Context, name, content string) (string, error) {
+	prompt, err := notePrompt("Summarize", name, content)
+	if err != nil {
+		return "", err
+	}
+	return a.provider.Complete(ctx, summaryPrompt, prompt, summaryMaxTokens)
+}
+
+// notePrompt asks the model to act on a note, cutting long content at
+// MaxInputBytes.
+func notePrompt(verb, name, content string) (string, error) {
+	if strings.TrimSpace(content) == "" {
+		return "", ErrEmpty
+	}
+	content, _ = Truncate(content, MaxInputBytes)
+	return verb + " the note \"" + name + "\":\n\n" + content, nil
+}
+
+// Truncate cuts content to at most max bytes without splitting a UTF-8
+// character. It reports whether anything was cut.
+func Truncate(content string, max int) (string, bool) {
+	if len(content) <= max {
+		return content, false
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut], true
+}
+
+// summaryPrompt instructs the model. It asks for Markdown so the summary can
+// be shown, or pasted into the note, like the rest of its content.
+const summaryPrompt = `You summarize notes written in Markdown. Reply with the summary only, in the language of the note.
+Start with one or two sentences on what the note is about. If the note records decisions, action items or open questions, such as the notes of a meeting, list them after that as short bullet points.
+Keep the summary under 200 words. Do not invent anything the note does not say.`
@@ -0,0 +1,91 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+type fakeConverse struct {
+	input *bedrockruntime.ConverseInput
+	reply string
+}
+
+func (f *fakeConverse) Converse(ctx context.Context, in *bedrockruntime.ConverseInput, _ ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	f.input = in
+	return &bedrockruntime.ConverseOutput{
+		Output: &types.ConverseOutputMemberMessage{Value: types.Message{
+			Role:    types.ConversationRoleAssistant,
+			Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: f.reply}},
+		}},
+	}, nil
+}
+
+func TestBedrockProvider(t *testing.T) {
+	client := &fakeConverse{reply: "  Weekly sync.\n\n- Ship on Friday\n"}
+	a := NewAssistant(NewBedrockProvider(client, "anthropic.claude-3-haiku-20240307-v1:0"))
+
+	got, err := a.Summarize(context.Background(), "Standup", "# Standup\nWe agreed to ship on Friday.")
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if got != "Weekly sync.\n\n- Ship on Friday" {
+		t.Errorf("Summarize = %q", got)
+	}
+	if *client.input.ModelId != "anthropic.claude-3-haiku-20240307-v1:0" || *client.input.InferenceConfig.MaxTokens != summaryMaxTokens {
+		t.Errorf("Unexpected request %+v", client.input)
+	}
+	prompt := client.input.Messages[0].Content[0].(*types.ContentBlockMemberText).Value
+	if !strings.Contains(prompt, `"Standup"`) || !strings.HasSuffix(prompt, "ship on Friday.") {
+		t.Errorf("Prompt = %q", prompt)
+	}
+
+	if _, err := a.Summarize(context.Background(), "Blank", " \n"); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Summarize of a blank note error = %v, want ErrEmpty", err)
+	}
+	client.reply = ""
+	if _, err := a.Summarize(context.Background(), "Standup", "text"); err == nil {
+		t.Error("Summarize accepted an empty reply")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	client := &fakeConverse{reply: "Here you go:\n```json\n" +
+		`{"title": "\"Q3 Planning\"", "tags": ["#Planning", "road map", "planning", "", "q3!", "budget", "hiring", "extra"]}` +
+		"\n```"}
+	a := NewAssistant(NewBedrockProvider(client, "model"))
+
+	got, err := a.Suggest(context.Background(), "Untitled", "We planned Q3.")
+	if err != nil {
+		t.Fatalf("Suggest: %v", err)
+	}
+	if got.Title != "Q3 Planning" {
+		t.Errorf("Title = %q", got.Title)
+	}
+	if want := []string{"planning", "road-map", "q3", "budget", "hiring"}; !slices.Equal(got.Tags, want) {
+		t.Errorf("Tags = %q, want %q", got.Tags, want)
+	}
+
+	client.reply = "I cannot help with that."
+	if _, err := a.Suggest(context.Background(), "Untitled", "text"); err == nil {
+		t.Error("Suggest accepted a reply without JSON")
+	}
+	if _, err := a.Suggest(context.Background(), "Untitled", ""); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Suggest for an empty note error = %v, want ErrEmpty", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got, cut := Truncate("héllo", 10); got != "héllo" || cut {
+		t.Errorf("Truncate short = %q, %v", got, cut)
+	}
+	// "é" is two bytes; cutting inside it drops the whole character.
+	if got, cut := Truncate("héllo", 2); got != "h" || !cut {
+		t.Errorf("Truncate inside a character = %q, %v", got, cut)
+	}
+}
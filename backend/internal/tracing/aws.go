@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentAWS makes every client built from cfg record a client span per
+// operation, named like "DynamoDB.Query". Retries happen inside the span.
+func InstrumentAWS(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		// After, so that the service and operation names are registered.
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("TracingSpan", awsSpan), middleware.After)
+	})
+}
+
+func awsSpan(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (out middleware.InitializeOutput, md middleware.Metadata, err error) {
+	service := awsmiddleware.GetServiceID(ctx)
+	operation := awsmiddleware.GetOperationName(ctx)
+	ctx, span := Tracer().Start(ctx, service+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.RPCSystemKey.String("aws-api"),
+			semconv.RPCService(service),
+			semconv.RPCMethod(operation),
+			semconv.CloudRegion(awsmiddleware.GetRegion(ctx)),
+		),
+	)
+	defer func() { End(span, err) }()
+
+	out, md, err = next.HandleInitialize(ctx, in)
+	if id, ok := awsmiddleware.GetRequestIDMetadata(md); ok {
+		span.SetAttributes(attribute.String("aws.request_id", id))
+	}
+	return out, md, err
+}
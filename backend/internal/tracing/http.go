@@ -0,0 +1,20 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Transport wraps base so that each outgoing request records a client span
+// named "<service> <METHOD>". Trace headers are not sent to the remote
+// service.
+func Transport(base http.RoundTripper, service string) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return service + " " + r.Method
+		}),
+	)
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// StorageProvider wraps a StorageProvider so that every adapter call
+// records a span named "storage.<Method>".
+type StorageProvider struct {
+	adapter.StorageProvider
+}
+
+// NewStorageProvider creates a tracing StorageProvider.
+func NewStorageProvider(provider adapter.StorageProvider) *StorageProvider {
+	return &StorageProvider{StorageProvider: provider}
+}
+
+// GetAdapter returns the wrapped adapter for userID with tracing applied.
+func (p *StorageProvider) GetAdapter(ctx context.Context, userID string) (adapter.StorageAdapter, error) {
+	storage, err := p.StorageProvider.GetAdapter(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &tracingAdapter{next: storage, kind: fmt.Sprintf("%T", storage)}, nil
+}
+
+// tracingAdapter records a span around each call. The span carries the
+// concrete adapter type, so Drive and demo storage can be told apart.
+type tracingAdapter struct {
+	next adapter.StorageAdapter
+	kind string
+}
+
+func (a *tracingAdapter) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Start(ctx, "storage."+method, append(attrs, attribute.String("storage.adapter", a.kind))...)
+}
+
+func fileID(id string) attribute.KeyValue {
+	return attribute.String("storage.file_id", id)
+}
+
+func resultCount(span trace.Span, n int) {
+	span.SetAttributes(attribute.Int("storage.result_count", n))
+}
+
+func (a *tracingAdapter) ListFiles(ctx context.Context, folderID string) (files []adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "ListFiles", attribute.String("storage.folder_id", folderID))
+	defer func() { resultCount(span, len(files)); End(span, err) }()
+	return a.next.ListFiles(ctx, folderID)
+}
+
+func (a *tracingAdapter) GetFile(ctx context.Context, id string) (file *adapter.File, err error) {
+	ctx, span := a.start(ctx, "GetFile", fileID(id))
+	defer func() { End(span, err) }()
+	return a.next.GetFile(ctx, id)
+}
+
+func (a *tracingAdapter) SaveFile(ctx context.Context, id string, content []byte, etag string) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "SaveFile", fileID(id), attribute.Int("storage.content_bytes", len(content)))
+	defer func() { End(span, err) }()
+	return a.next.SaveFile(ctx, id, content, etag)
+}
+
+func (a *tracingAdapter) CreateFile(ctx context.Context, name string, content []byte, folderID string) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "CreateFile", attribute.String("storage.folder_id", folderID), attribute.Int("storage.content_bytes", len(content)))
+	defer func() { End(span, err) }()
+	return a.next.CreateFile(ctx, name, content, folderID)
+}
+
+func (a *tracingAdapter) CreateFolder(ctx context.Context, name string, parents []string) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "CreateFolder")
+	defer func() { End(span, err) }()
+	return a.next.CreateFolder(ctx, name, parents)
+}
+
+func (a *tracingAdapter) ListRootFolders(ctx context.Context) (folders []adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "ListRootFolders")
+	defer func() { resultCount(span, len(folders)); End(span, err) }()
+	return a.next.ListRootFolders(ctx)
+}
+
+func (a *tracingAdapter) EnsureRootFolder(ctx context.Context, name string) (id string, err error) {
+	ctx, span := a.start(ctx, "EnsureRootFolder")
+	defer func() { End(span, err) }()
+	return a.next.EnsureRootFolder(ctx, name)
+}
+
+func (a *tracingAdapter) DeleteFile(ctx context.Context, id string) (err error) {
+	ctx, span := a.start(ctx, "DeleteFile", fileID(id))
+	defer func() { End(span, err) }()
+	return a.next.DeleteFile(ctx, id)
+}
+
+func (a *tracingAdapter) DuplicateFile(ctx context.Context, id string) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "DuplicateFile", fileID(id))
+	defer func() { End(span, err) }()
+	return a.next.DuplicateFile(ctx, id)
+}
+
+func (a *tracingAdapter) RenameFile(ctx context.Context, id string, newName string) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "RenameFile", fileID(id))
+	defer func() { End(span, err) }()
+	return a.next.RenameFile(ctx, id, newName)
+}
+
+func (a *tracingAdapter) SetStarred(ctx context.Context, id string, starred bool) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "SetStarred", fileID(id))
+	defer func() { End(span, err) }()
+	return a.next.SetStarred(ctx, id, starred)
+}
+
+func (a *tracingAdapter) SetOrderIndex(ctx context.Context, id string, index int) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "SetOrderIndex", fileID(id))
+	defer func() { End(span, err) }()
+	return a.next.SetOrderIndex(ctx, id, index)
+}
+
+func (a *tracingAdapter) SetAppearance(ctx context.Context, id string, color, icon *string) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "SetAppearance", fileID(id))
+	defer func() { End(span, err) }()
+	return a.next.SetAppearance(ctx, id, color, icon)
+}
+
+func (a *tracingAdapter) SetArchived(ctx context.Context, id string, archived bool) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "SetArchived", fileID(id))
+	defer func() { End(span, err) }()
+	return a.next.SetArchived(ctx, id, archived)
+}
+
+func (a *tracingAdapter) ListArchived(ctx context.Context) (files []adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "ListArchived")
+	defer func() { resultCount(span, len(files)); End(span, err) }()
+	return a.next.ListArchived(ctx)
+}
+
+func (a *tracingAdapter) SetProperties(ctx context.Context, id string, props map[string]string) (meta *adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "SetProperties", fileID(id))
+	defer func() { End(span, err) }()
+	return a.next.SetProperties(ctx, id, props)
+}
+
+func (a *tracingAdapter) ListStarred(ctx context.Context) (files []adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "ListStarred")
+	defer func() { resultCount(span, len(files)); End(span, err) }()
+	return a.next.ListStarred(ctx)
+}
+
+func (a *tracingAdapter) SearchFiles(ctx context.Context, query string) (files []adapter.FileMetadata, err error) {
+	ctx, span := a.start(ctx, "SearchFiles")
+	defer func() { resultCount(span, len(files)); End(span, err) }()
+	return a.next.SearchFiles(ctx, query)
+}
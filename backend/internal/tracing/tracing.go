@@ -0,0 +1,123 @@
+// Package tracing sets up OpenTelemetry tracing and provides the helpers
+// that instrument requests, storage adapters and AWS and Google Drive calls.
+//
+// Spans are exported over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT (or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) is set. On Lambda that is the ADOT
+// collector layer listening on localhost:4318, which forwards to X-Ray.
+// Without an endpoint every span is a no-op. The standard OTEL_* variables
+// (OTEL_SERVICE_NAME, OTEL_TRACES_SAMPLER, ...) are honoured.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/jun/gophdrive/backend"
+	defaultServiceName  = "gophdrive-backend"
+)
+
+// provider is the SDK provider installed by Setup, or nil when tracing is
+// disabled.
+var provider *sdktrace.TracerProvider
+
+// propagator reads the caller's trace context. A W3C traceparent header
+// takes precedence over an X-Ray trace header.
+var propagator = propagation.NewCompositeTextMapPropagator(xrayPropagator{}, propagation.TraceContext{})
+
+// Setup installs the global tracer provider when an OTLP endpoint is
+// configured and does nothing otherwise.
+func Setup(ctx context.Context) error {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		slog.Info("Tracing disabled (OTEL_EXPORTER_OTLP_ENDPOINT not set)")
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return fmt.Errorf("create OTLP exporter: %w", err)
+	}
+	// Later options win, so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+	// override the default service name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(defaultServiceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil && !errors.Is(err, resource.ErrPartialResource) {
+		return fmt.Errorf("build trace resource: %w", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	slog.Info("Tracing enabled", "exporter", "otlphttp")
+	return nil
+}
+
+// Flush exports buffered spans. Lambda freezes the process between
+// invocations, so call it before returning each response.
+func Flush(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.ForceFlush(ctx)
+}
+
+// Shutdown flushes buffered spans and stops the exporter.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
+// Tracer returns the backend's tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts an internal span named name.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract returns ctx carrying the remote parent described by the request
+// headers. On Lambda the invocation's X-Ray trace header replaces the one
+// API Gateway forwarded, so spans nest under the function's segment.
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	carrier := propagation.HeaderCarrier(make(http.Header, len(headers)+1))
+	for k, v := range headers {
+		carrier.Set(k, v)
+	}
+	if id, ok := ctx.Value(lambdaTraceIDKey).(string); ok && id != "" {
+		carrier.Set(xrayHeader, id)
+	}
+	return propagator.Extract(ctx, carrier)
+}
@@ -0,0 +1,162 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jun/gophdrive/backend/internal/adapter"
+)
+
+// recordSpans installs an in-memory tracer provider for the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+func TestParseXRayHeader(t *testing.T) {
+	sc, ok := parseXRayHeader("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	if !ok {
+		t.Fatal("valid header rejected")
+	}
+	if got := sc.TraceID().String(); got != "5759e988bd862e3fe1be46a994272793" {
+		t.Errorf("trace ID = %s", got)
+	}
+	if got := sc.SpanID().String(); got != "53995c3f42cd8ad8" {
+		t.Errorf("span ID = %s", got)
+	}
+	if !sc.IsSampled() || !sc.IsRemote() {
+		t.Errorf("sampled = %v, remote = %v, want both", sc.IsSampled(), sc.IsRemote())
+	}
+
+	for _, bad := range []string{"", "Root=2-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8", "Root=1-5759e988-bd862e3fe1be46a994272793"} {
+		if _, ok := parseXRayHeader(bad); ok {
+			t.Errorf("parseXRayHeader(%q) accepted", bad)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	const xray = "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+	const traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	parent := func(ctx context.Context) string {
+		return trace.SpanContextFromContext(ctx).TraceID().String()
+	}
+	if got := parent(Extract(context.Background(), map[string]string{"X-Amzn-Trace-Id": xray})); got != "5759e988bd862e3fe1be46a994272793" {
+		t.Errorf("X-Ray parent = %s", got)
+	}
+	headers := map[string]string{"traceparent": traceparent, "X-Amzn-Trace-Id": xray}
+	if got := parent(Extract(context.Background(), headers)); got != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("traceparent should win, got %s", got)
+	}
+	// aws-lambda-go stores the invocation trace header under a plain string key.
+	lambdaCtx := context.WithValue(context.Background(), lambdaTraceIDKey, xray)
+	if got := parent(Extract(lambdaCtx, nil)); got != "5759e988bd862e3fe1be46a994272793" {
+		t.Errorf("Lambda parent = %s", got)
+	}
+}
+
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestInstrumentAWS(t *testing.T) {
+	rec := recordSpans(t)
+
+	cfg := aws.Config{
+		Region:      "ap-northeast-1",
+		Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		HTTPClient: doerFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"X-Amzn-Requestid": {"rid-1"}},
+				Body:       io.NopCloser(strings.NewReader(`{"Table":{"TableStatus":"ACTIVE"}}`)),
+				Request:    r,
+			}, nil
+		}),
+	}
+	InstrumentAWS(&cfg)
+	client := dynamodb.NewFromConfig(cfg)
+	if _, err := client.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{TableName: aws.String("Notes")}); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if got := spans[0].Name(); got != "DynamoDB.DescribeTable" {
+		t.Errorf("span name = %q", got)
+	}
+	if spans[0].SpanKind() != trace.SpanKindClient {
+		t.Errorf("span kind = %v, want client", spans[0].SpanKind())
+	}
+	attrs := map[string]string{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["aws.request_id"] != "rid-1" || attrs["cloud.region"] != "ap-northeast-1" {
+		t.Errorf("attributes = %v", attrs)
+	}
+}
+
+type stubProvider struct{ storage adapter.StorageAdapter }
+
+func (p stubProvider) GetAdapter(context.Context, string) (adapter.StorageAdapter, error) {
+	return p.storage, nil
+}
+
+type stubAdapter struct {
+	adapter.StorageAdapter
+}
+
+func (stubAdapter) ListStarred(context.Context) ([]adapter.FileMetadata, error) {
+	return []adapter.FileMetadata{{ID: "a"}, {ID: "b"}}, nil
+}
+
+func (stubAdapter) GetFile(context.Context, string) (*adapter.File, error) {
+	return nil, errors.New("not found")
+}
+
+func TestStorageProvider(t *testing.T) {
+	rec := recordSpans(t)
+
+	storage, err := NewStorageProvider(stubProvider{stubAdapter{}}).GetAdapter(context.Background(), "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := storage.ListStarred(context.Background()); len(files) != 2 {
+		t.Errorf("ListStarred returned %d files, want 2", len(files))
+	}
+	if _, err := storage.GetFile(context.Background(), "f1"); err == nil {
+		t.Error("GetFile error was swallowed")
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[0].Name() != "storage.ListStarred" || spans[0].Status().Code == codes.Error {
+		t.Errorf("span 0 = %q %v", spans[0].Name(), spans[0].Status())
+	}
+	if spans[1].Name() != "storage.GetFile" || spans[1].Status().Code != codes.Error {
+		t.Errorf("span 1 = %q %v, want error status", spans[1].Name(), spans[1].Status())
+	}
+}
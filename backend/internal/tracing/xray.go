@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const xrayHeader = "X-Amzn-Trace-Id"
+
+// lambdaTraceIDKey is the context key under which aws-lambda-go stores the
+// invocation's X-Ray trace header.
+const lambdaTraceIDKey = "x-amzn-trace-id"
+
+// xrayPropagator reads X-Ray trace headers such as
+//
+//	Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1
+//
+// X-Ray accepts W3C trace IDs, so the root maps directly onto one. Context
+// is only extracted; outgoing calls carry no trace header.
+type xrayPropagator struct{}
+
+var _ propagation.TextMapPropagator = xrayPropagator{}
+
+func (xrayPropagator) Inject(context.Context, propagation.TextMapCarrier) {}
+
+func (xrayPropagator) Fields() []string {
+	return []string{xrayHeader}
+}
+
+func (xrayPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	sc, ok := parseXRayHeader(carrier.Get(xrayHeader))
+	if !ok {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+func parseXRayHeader(header string) (trace.SpanContext, bool) {
+	var cfg trace.SpanContextConfig
+	for _, part := range strings.Split(header, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "Root":
+			// 1-<8 hex digit epoch>-<24 hex digits>
+			fields := strings.Split(value, "-")
+			if len(fields) != 3 || fields[0] != "1" {
+				return trace.SpanContext{}, false
+			}
+			id, err := trace.TraceIDFromHex(fields[1] + fields[2])
+			if err != nil {
+				return trace.SpanContext{}, false
+			}
+			cfg.TraceID = id
+		case "Parent":
+			id, err := trace.SpanIDFromHex(value)
+			if err != nil {
+				return trace.SpanContext{}, false
+			}
+			cfg.SpanID = id
+		case "Sampled":
+			if value == "1" {
+				cfg.TraceFlags = trace.FlagsSampled
+			}
+		}
+	}
+	cfg.Remote = true
+	sc := trace.NewSpanContext(cfg)
+	return sc, sc.IsValid()
+}
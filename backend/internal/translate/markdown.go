@@ -0,0 +1,122 @@
+package translate
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// parser reads notes the way core's renderer does: CommonMark with GitHub
+// Flavored Markdown and footnotes. The backend does not import core, so the
+// extensions are repeated here; wikilinks and math, which core parses with
+// its own extensions, are kept out of segments by protected instead.
+var parser = goldmark.New(goldmark.WithExtensions(extension.GFM, extension.Footnote)).Parser()
+
+// protected matches inline syntax inside text that must not be translated:
+// wikilinks, inline and display math, and task due dates.
+var protected = regexp.MustCompile(`\[\[[^\]\n]*\]\]|\$\$[^$]*\$\$|\$[^$\s][^$\n]*\$|📅\s*\d{4}-\d{2}-\d{2}`)
+
+// segment is a run of translatable text, source[start:stop].
+type segment struct {
+	start, stop int
+}
+
+// segments returns the translatable text of a Markdown note in document
+// order. Only text nodes are included: code, HTML, link destinations and
+// the frontmatter block keep their source. Adjacent text nodes, such as the
+// lines of a paragraph, form one segment so sentences are translated whole;
+// emphasis and links split them.
+func segments(source []byte) []segment {
+	first := frontmatterEnd(source)
+	doc := parser.Parse(text.NewReader(source[first:]))
+	body := source[first:]
+
+	var runs []segment
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.CodeSpan, *ast.RawHTML, *ast.AutoLink:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			seg := segment{n.Segment.Start, n.Segment.Stop}
+			if last := len(runs) - 1; last >= 0 && joins(body[runs[last].stop:seg.start]) {
+				runs[last].stop = seg.stop
+			} else {
+				runs = append(runs, seg)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var out []segment
+	for _, run := range runs {
+		at := run.start
+		for _, m := range protected.FindAllIndex(body[run.start:run.stop], -1) {
+			out = appendTrimmed(out, body, at, run.start+m[0])
+			at = run.start + m[1]
+		}
+		out = appendTrimmed(out, body, at, run.stop)
+	}
+	for i := range out {
+		out[i].start += first
+		out[i].stop += first
+	}
+	return out
+}
+
+// joins reports whether two text nodes separated by gap belong to one
+// segment: nothing, or a bare line break, lies between them.
+func joins(gap []byte) bool {
+	return len(gap) == 0 || string(gap) == "\n" || string(gap) == "\r\n"
+}
+
+// appendTrimmed appends source[start:stop] without its surrounding
+// whitespace, so spacing around markup is kept, if it has any letters.
+func appendTrimmed(out []segment, source []byte, start, stop int) []segment {
+	s := source[start:stop]
+	start += len(s) - len(bytes.TrimLeftFunc(s, unicode.IsSpace))
+	stop -= len(s) - len(bytes.TrimRightFunc(s, unicode.IsSpace))
+	if start >= stop || !bytes.ContainsFunc(source[start:stop], unicode.IsLetter) {
+		return out
+	}
+	return append(out, segment{start, stop})
+}
+
+// splice replaces each segment of source with the text at the same index.
+func splice(source []byte, segs []segment, texts []string) []byte {
+	var b strings.Builder
+	at := 0
+	for i, seg := range segs {
+		b.Write(source[at:seg.start])
+		b.WriteString(texts[i])
+		at = seg.stop
+	}
+	b.Write(source[at:])
+	return []byte(b.String())
+}
+
+// frontmatterEnd returns the offset just after a YAML frontmatter block
+// that opens source, or 0 if there is none.
+func frontmatterEnd(source []byte) int {
+	lines := bytes.SplitAfter(source, []byte("\n"))
+	if len(lines) == 0 || string(bytes.TrimRight(lines[0], " \t\r\n")) != "---" {
+		return 0
+	}
+	offset := len(lines[0])
+	for _, line := range lines[1:] {
+		offset += len(line)
+		switch string(bytes.TrimRight(line, " \t\r\n")) {
+		case "---", "...":
+			return offset
+		}
+	}
+	return 0
+}
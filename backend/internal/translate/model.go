@@ -0,0 +1,79 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jun/gophdrive/backend/internal/summary"
+)
+
+const (
+	// batchBytes bounds the text sent to the model in one request, so each
+	// reply fits in batchMaxTokens.
+	batchBytes     = 6 << 10
+	batchMaxTokens = 8192
+)
+
+// ModelTranslator translates with a language model, such as the summary
+// model on Amazon Bedrock. Texts are sent in batches as a JSON array and
+// the model replies with an array of the same length.
+type ModelTranslator struct {
+	provider summary.Provider
+}
+
+// NewModelTranslator creates a translator that uses provider.
+func NewModelTranslator(provider summary.Provider) *ModelTranslator {
+	return &ModelTranslator{provider: provider}
+}
+
+// Translate implements Translator.
+func (t *ModelTranslator) Translate(ctx context.Context, texts []string, lang string) ([]string, error) {
+	out := make([]string, 0, len(texts))
+	for start := 0; start < len(texts); {
+		end, size := start, 0
+		for end < len(texts) && (end == start || size+len(texts[end]) <= batchBytes) {
+			size += len(texts[end])
+			end++
+		}
+		batch, err := t.translateBatch(ctx, texts[start:end], lang)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, batch...)
+		start = end
+	}
+	return out, nil
+}
+
+func (t *ModelTranslator) translateBatch(ctx context.Context, texts []string, lang string) ([]string, error) {
+	input, err := json.Marshal(texts)
+	if err != nil {
+		return nil, err
+	}
+	system := fmt.Sprintf(translatePrompt, lang)
+	reply, err := t.provider.Complete(ctx, system, string(input), batchMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("model reply is not a JSON array")
+	}
+	var translated []string
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &translated); err != nil {
+		return nil, errors.New("model reply is not a JSON array of strings")
+	}
+	if len(translated) != len(texts) {
+		return nil, fmt.Errorf("model returned %d translations for %d texts", len(translated), len(texts))
+	}
+	return translated, nil
+}
+
+// translatePrompt is formatted with the target language tag.
+const translatePrompt = `You translate the text of Markdown notes into the language with BCP 47 tag %q.
+The input is a JSON array of text fragments from one note, in order. Reply with a JSON array only, with exactly one translated string for each fragment, in the same order.
+Translate each fragment on its own; do not merge, split or drop fragments. Keep line breaks, numbers, URLs and any Markdown punctuation inside a fragment as they are. A fragment already in the target language is returned unchanged.`
@@ -0,0 +1,65 @@
+// Package translate translates Markdown notes while keeping their
+// structure: only the text of the note is sent to a Translator, and the
+// translations are put back in place of the original text, so headings,
+// lists, links, code and frontmatter come through unchanged.
+package translate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// MaxNoteBytes is the largest note Note translates. A translation must
+// cover the whole note, so longer notes are refused rather than cut.
+const MaxNoteBytes = 100 << 10
+
+var (
+	// ErrTooLarge is returned for a note over MaxNoteBytes.
+	ErrTooLarge = errors.New("the note is too long to translate")
+	// ErrInvalidLanguage is returned for a target language that is not a
+	// language tag.
+	ErrInvalidLanguage = errors.New("invalid language")
+)
+
+// languagePattern matches BCP 47 language tags such as "ja", "pt-BR" or
+// "zh-Hant".
+var languagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// ValidLanguage reports whether lang looks like a BCP 47 language tag.
+func ValidLanguage(lang string) bool {
+	return len(lang) <= 35 && languagePattern.MatchString(lang)
+}
+
+// Translator translates texts into the language tagged lang. It returns one
+// translation per text, in order.
+type Translator interface {
+	Translate(ctx context.Context, texts []string, lang string) ([]string, error)
+}
+
+// Note translates the text of a Markdown note into lang with t.
+func Note(ctx context.Context, t Translator, content []byte, lang string) ([]byte, error) {
+	if !ValidLanguage(lang) {
+		return nil, ErrInvalidLanguage
+	}
+	if len(content) > MaxNoteBytes {
+		return nil, ErrTooLarge
+	}
+	segs := segments(content)
+	if len(segs) == 0 {
+		return content, nil
+	}
+	texts := make([]string, len(segs))
+	for i, seg := range segs {
+		texts[i] = string(content[seg.start:seg.stop])
+	}
+	translated, err := t.Translate(ctx, texts, lang)
+	if err != nil {
+		return nil, err
+	}
+	if len(translated) != len(texts) {
+		return nil, fmt.Errorf("translator returned %d texts for %d", len(translated), len(texts))
+	}
+	return splice(content, segs, translated), nil
+}
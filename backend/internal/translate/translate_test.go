@@ -0,0 +1,124 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const note = `---
+title: Weekly sync
+tags: [meetings]
+---
+# Weekly sync
+
+We agreed to **ship on Friday** and to
+update the [release notes](https://example.com/notes).
+
+- [ ] email the team 📅 2024-06-07
+- see [[Release plan]] and $x^2$
+
+> Quoted text
+
+` + "```go\n// keep this comment\n```" + `
+
+| Owner | Task |
+|-------|------|
+| Ana   | Docs |
+`
+
+// upper translates by upper-casing, which makes what was sent easy to see.
+type upper struct{ texts []string }
+
+func (u *upper) Translate(ctx context.Context, texts []string, lang string) ([]string, error) {
+	u.texts = texts
+	out := make([]string, len(texts))
+	for i, t := range texts {
+		out[i] = strings.ToUpper(t)
+	}
+	return out, nil
+}
+
+func TestNote(t *testing.T) {
+	u := &upper{}
+	got, err := Note(context.Background(), u, []byte(note), "ja")
+	if err != nil {
+		t.Fatalf("Note: %v", err)
+	}
+	want := `---
+title: Weekly sync
+tags: [meetings]
+---
+# WEEKLY SYNC
+
+WE AGREED TO **SHIP ON FRIDAY** AND TO
+UPDATE THE [RELEASE NOTES](https://example.com/notes).
+
+- [ ] EMAIL THE TEAM 📅 2024-06-07
+- SEE [[Release plan]] AND $x^2$
+
+> QUOTED TEXT
+
+` + "```go\n// keep this comment\n```" + `
+
+| OWNER | TASK |
+|-------|------|
+| ANA   | DOCS |
+`
+	if string(got) != want {
+		t.Errorf("Note =\n%s\nwant\n%s", got, want)
+	}
+	// The lines of a paragraph are sent together.
+	if u.texts[3] != "and to\nupdate the" {
+		t.Errorf("Segments = %q", u.texts)
+	}
+
+	if _, err := Note(context.Background(), u, []byte(note), "japanese!"); !errors.Is(err, ErrInvalidLanguage) {
+		t.Errorf("Note with a bad language error = %v", err)
+	}
+	if _, err := Note(context.Background(), u, make([]byte, MaxNoteBytes+1), "ja"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Note of a long note error = %v", err)
+	}
+}
+
+type fakeProvider struct {
+	calls int
+	reply func(texts []string) string
+}
+
+func (f *fakeProvider) Complete(ctx context.Context, system, prompt string, maxTokens int32) (string, error) {
+	f.calls++
+	var texts []string
+	json.Unmarshal([]byte(prompt), &texts)
+	return f.reply(texts), nil
+}
+
+func TestModelTranslator(t *testing.T) {
+	provider := &fakeProvider{reply: func(texts []string) string {
+		for i := range texts {
+			texts[i] = "«" + texts[i] + "»"
+		}
+		out, _ := json.Marshal(texts)
+		return "```json\n" + string(out) + "\n```"
+	}}
+	tr := NewModelTranslator(provider)
+
+	long := strings.Repeat("x", batchBytes-1)
+	got, err := tr.Translate(context.Background(), []string{"a", long, "b"}, "fr")
+	if err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	if len(got) != 3 || got[0] != "«a»" || got[2] != "«b»" {
+		t.Errorf("Translate = %q", got)
+	}
+	if provider.calls != 2 {
+		t.Errorf("Translate made %d model calls, want 2 batches", provider.calls)
+	}
+
+	provider.reply = func([]string) string { return `["only one"]` }
+	if _, err := tr.Translate(context.Background(), []string{"a", "b"}, "fr"); err == nil {
+		t.Error("Translate accepted a reply with too few translations")
+	}
+}
@@ -3,48 +3,598 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
 	"syscall/js"
+	"time"
+	"unicode/utf16"
 
+	"github.com/jun/gophdrive/core/crdt"
+	"github.com/jun/gophdrive/core/crypto"
 	"github.com/jun/gophdrive/core/markdown"
+	"github.com/jun/gophdrive/core/search"
 	"github.com/jun/gophdrive/core/sync"
+	"github.com/jun/gophdrive/core/template"
 )
 
 func main() {
 	renderer := markdown.NewRenderer()
+	// Preview re-renders on every keystroke; only changed blocks are converted.
+	incremental := markdown.NewIncrementalRenderer(renderer)
 
-	// format: renderMarkdown(sourceString) -> htmlString
-	renderFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	// The renderer for the options last passed to renderMarkdown. A view
+	// renders with the same options on every keystroke, so only the last
+	// one is kept rather than one per options object ever seen.
+	var (
+		viewOptions  renderOptions
+		viewRenderer *markdown.IncrementalRenderer
+	)
+
+	// format: renderMarkdown(sourceString, sanitize? bool | options? {hardWraps, unsafeHTML, headingIDs, baseURL, theme}) -> {html, error}
+	renderFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 && len(args) != 2 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		render := incremental.Render
+		if hasArg(args, 1) {
+			switch args[1].Type() {
+			case js.TypeBoolean:
+				if args[1].Bool() {
+					render = renderer.RenderSanitized
+				}
+			case js.TypeObject:
+				var opts renderOptions
+				raw := js.Global().Get("JSON").Call("stringify", args[1]).String()
+				if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+					return nil, err
+				}
+				// Compared decoded, so key order and unknown fields do not
+				// force a new renderer.
+				if viewRenderer == nil || !reflect.DeepEqual(opts, viewOptions) {
+					viewOptions = opts
+					viewRenderer = markdown.NewIncrementalRenderer(markdown.NewRenderer(opts.rendererOptions()...))
+				}
+				render = viewRenderer.Render
+			default:
+				return nil, argError(1, "a boolean or an options object")
+			}
+		}
+		htmlBytes, err := render([]byte(source))
+		if err != nil {
+			return nil, err
+		}
+
+		return string(htmlBytes), nil
+	})
+
+	// format: renderDiff(oldSource, newSource string) -> {html, error}
+	renderDiffFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		oldSource, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		newSource, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		htmlBytes, err := renderer.RenderDiff([]byte(oldSource), []byte(newSource))
+		if err != nil {
+			return nil, err
+		}
+		return string(htmlBytes), nil
+	})
+
+	// format: renderText(sourceString) -> {result: plainTextString, error}
+	renderTextFunc := handler(func(args []js.Value) (any, error) {
 		if len(args) != 1 {
-			return "Error: Invalid number of arguments"
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
 		}
-		source := args[0].String()
+		return string(renderer.RenderText([]byte(source))), nil
+	})
+
+	// format: setRenderOptions(optionsJSON {hardWraps, unsafeHTML, headingIDs, baseURL, theme, darkTheme}) -> {result: stylesheet CSS, error}
+	setRenderOptionsFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		optionsJSON, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		var opts renderOptions
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return nil, err
+		}
+		if opts.Theme == "" {
+			opts.Theme = markdown.DefaultTheme
+		}
+
+		css, err := markdown.Stylesheet(opts.Theme, opts.DarkTheme)
+		if err != nil {
+			return nil, err
+		}
+		renderer = markdown.NewRenderer(opts.rendererOptions()...)
+		incremental = markdown.NewIncrementalRenderer(renderer)
+		return css, nil
+	})
 
-		htmlBytes, err := renderer.Render([]byte(source))
+	// format: getNoteStats(sourceString) -> {result: JSON {words, characters, headings, tasks, tasksDone, readingMinutes}, error}
+	getNoteStatsFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
 		if err != nil {
-			return "Error: " + err.Error()
+			return nil, err
 		}
+		return marshal(markdown.Stats([]byte(source)))
+	})
 
-		return string(htmlBytes)
+	// format: extractWikiLinks(sourceString) -> {result: JSON [{target, alias}], error}
+	extractWikiLinksFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(renderer.ExtractWikiLinks([]byte(source)))
 	})
 
-	// format: checkConflict(localEtag, remoteEtag string) -> bool
-	checkConflictFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	// format: formatMarkdown(sourceString, rulesJSON? string) -> {result: sourceString, error}
+	formatMarkdownFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 && len(args) != 2 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		rules := markdown.DefaultFormatRules()
+		if hasArg(args, 1) {
+			rulesJSON, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(rulesJSON), &rules); err != nil {
+				return nil, err
+			}
+		}
+		return string(markdown.Format([]byte(source), rules)), nil
+	})
+
+	// format: toggleTask(sourceString, index int) -> {result: sourceString, error}
+	toggleTaskFunc := handler(func(args []js.Value) (any, error) {
 		if len(args) != 2 {
-			return false
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		index, err := intArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := markdown.ToggleTask([]byte(source), index)
+		if err != nil {
+			return nil, err
+		}
+		return string(updated), nil
+	})
+
+	// format: extractTasks(sourceString) -> {result: JSON [{index, text, done, due, line}], error}
+	extractTasksFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
 		}
-		localEtag := args[0].String()
-		remoteEtag := args[1].String()
-		return sync.CheckConflict(localEtag, remoteEtag)
+		return marshal(markdown.ExtractTasks([]byte(source)))
 	})
 
-	// format: createOfflineChange(noteID, content string) -> object
-	createOfflineChangeFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	// format: extractLinks(sourceString) -> {result: JSON [{kind, target, text, offset, line}], error}
+	extractLinksFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(renderer.ExtractLinks([]byte(source)))
+	})
+
+	// format: getSection(sourceString, headingSlug string) -> {result: sectionMarkdown, error}
+	getSectionFunc := handler(func(args []js.Value) (any, error) {
 		if len(args) != 2 {
-			return nil
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		slug, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		section, err := renderer.GetSection([]byte(source), slug)
+		if err != nil {
+			return nil, err
+		}
+		return string(section), nil
+	})
+
+	// format: extractTOC(sourceString) -> {result: JSON [{level, text, id}], error}
+	extractTOCFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(renderer.ExtractTOC([]byte(source)))
+	})
+
+	// format: lintMarkdown(sourceString) -> {result: JSON [{rule, message, offset, line, column}], error}
+	lintMarkdownFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(markdown.Lint([]byte(source)))
+	})
+
+	// format: validateMermaid(sourceString) -> {result: JSON [{rule, message, offset, line, column}], error}
+	validateMermaidFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(markdown.ValidateMermaid([]byte(source)))
+	})
+
+	// format: setFrontmatterField(sourceString, key string, valueJSON string) -> {result: sourceString, error}
+	setFrontmatterFieldFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 3 {
+			return nil, errArgCount
+		}
+		fields, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if err := json.Unmarshal([]byte(fields[2]), &value); err != nil {
+			return nil, err
+		}
+		updated, err := markdown.SetFrontmatterField([]byte(fields[0]), fields[1], value)
+		if err != nil {
+			return nil, err
+		}
+		return string(updated), nil
+	})
+
+	// format: removeFrontmatterField(sourceString, key string) -> {result: sourceString, error}
+	removeFrontmatterFieldFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		fields, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := markdown.RemoveFrontmatterField([]byte(fields[0]), fields[1])
+		if err != nil {
+			return nil, err
+		}
+		return string(updated), nil
+	})
+
+	// format: expandTemplate(sourceString, contextJSON? {title, variables, now (epoch ms)}) -> {result: {text, cursor}, error}
+	// cursor is a UTF-16 index for the editor, or -1 without {{cursor}}.
+	expandTemplateFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 && len(args) != 2 {
+			return nil, errArgCount
+		}
+		source, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		var ctx struct {
+			Title     string            `json:"title"`
+			Variables map[string]string `json:"variables"`
+			Now       int64             `json:"now"`
+		}
+		if hasArg(args, 1) {
+			contextJSON, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(contextJSON), &ctx); err != nil {
+				return nil, err
+			}
+		}
+		tctx := template.Context{Title: ctx.Title, Variables: ctx.Variables}
+		if ctx.Now != 0 {
+			tctx.Now = time.UnixMilli(ctx.Now)
+		}
+
+		expanded := template.ExpandVariables(source, tctx)
+		cursor := expanded.Cursor
+		if cursor >= 0 {
+			cursor = len(utf16.Encode([]rune(expanded.Text[:cursor])))
+		}
+		return js.ValueOf(map[string]any{"text": expanded.Text, "cursor": cursor}), nil
+	})
+
+	// The offline search index lives in Wasm memory between calls.
+	index := search.NewIndex(nil)
+
+	// format: buildIndex(docsJSON [{id, title, content}]) -> {result: int (documents indexed), error}
+	buildIndexFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		docsJSON, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		var docs []search.Document
+		if err := json.Unmarshal([]byte(docsJSON), &docs); err != nil {
+			return nil, err
+		}
+		index = search.NewIndex(docs)
+		return index.Len(), nil
+	})
+
+	// format: search(query string, limit? int) -> {result: JSON [{id, title, score, snippet}], error}
+	searchFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 && len(args) != 2 {
+			return nil, errArgCount
+		}
+		query, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		limit := 0
+		if hasArg(args, 1) {
+			if limit, err = intArg(args, 1); err != nil {
+				return nil, err
+			}
+		}
+		return marshal(index.Search(query, limit))
+	})
+
+	// format: deriveKey(passphrase, saltBase64? string) -> {result: JSON {key, salt} (base64), error}
+	deriveKeyFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 && len(args) != 2 {
+			return nil, errArgCount
+		}
+		passphrase, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		var salt []byte
+		if hasArg(args, 1) {
+			salt, err = base64Arg(args, 1)
+		} else {
+			salt, err = crypto.NewSalt()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := crypto.DeriveKey(passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(map[string]string{
+			"key":  base64.StdEncoding.EncodeToString(key),
+			"salt": base64.StdEncoding.EncodeToString(salt),
+		})
+	})
+
+	// format: encryptData(keyBase64, plaintext string) -> {result: ciphertextBase64, error}
+	encryptDataFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		key, err := base64Arg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Encrypt(key, plaintext)
+	})
+
+	// format: decryptData(keyBase64, ciphertextBase64 string) -> {result: plaintext, error}
+	decryptDataFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		key, err := base64Arg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Decrypt(key, ciphertext)
+	})
+
+	// format: sealNote(passphrase, plaintext string) -> {result: sealed content, error}
+	sealNoteFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		strs, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.SealNote(strs[0], strs[1])
+	})
+
+	// format: openNote(passphrase, sealed string) -> {result: plaintext, error}
+	openNoteFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		strs, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.OpenNote(strs[0], strs[1])
+	})
+
+	// Keys of the protected notes the user has unlocked, by note ID. They
+	// stay in Wasm memory and are gone when the page unloads.
+	var keyring crypto.Keyring
+
+	// format: protectNote(noteID, passphrase, plaintext string) -> {result: sealed content, error}
+	// The note stays unlocked, so later edits are sealed with sealUnlockedNote.
+	protectNoteFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 3 {
+			return nil, errArgCount
+		}
+		strs, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return keyring.Protect(strs[0], strs[1], strs[2])
+	})
+
+	// format: unlockNote(noteID, passphrase, sealed string) -> {result: plaintext, error}
+	unlockNoteFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 3 {
+			return nil, errArgCount
+		}
+		strs, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return keyring.Unlock(strs[0], strs[1], strs[2])
+	})
+
+	// format: sealUnlockedNote(noteID, plaintext string) -> {result: sealed content, error}
+	sealUnlockedNoteFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		strs, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return keyring.Seal(strs[0], strs[1])
+	})
+
+	// format: isNoteUnlocked(noteID string) -> {result: bool, error}
+	isNoteUnlockedFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		noteID, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return keyring.Unlocked(noteID), nil
+	})
+
+	// format: lockNote(noteID string) -> {result: null, error}
+	// An empty noteID locks every note.
+	lockNoteFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		noteID, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		keyring.Lock(noteID)
+		return nil, nil
+	})
+
+	// format: isSealedNote(content string) -> {result: bool, error}
+	isSealedNoteFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		content, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.IsSealedNote(content), nil
+	})
+
+	// format: isProtectedNote(content string) -> {result: bool, error}
+	isProtectedNoteFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		content, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.IsProtectedNote(content), nil
+	})
+
+	// format: checkConflict(localEtag, remoteEtag string) -> {result: bool, error}
+	checkConflictFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		localEtag, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		remoteEtag, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return sync.CheckConflict(localEtag, remoteEtag), nil
+	})
+
+	// format: createOfflineChange(noteID, content string) -> {result: {noteId, content, timestamp}, error}
+	createOfflineChangeFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		noteID, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		content, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
 		}
-		noteID := args[0].String()
-		content := args[1].String()
 
 		change := sync.NewOfflineChange(noteID, content)
 
@@ -53,15 +603,462 @@ func main() {
 		obj.Set("content", change.Content)
 		obj.Set("timestamp", change.Timestamp)
 
-		return obj
+		return obj, nil
+	})
+
+	// format: mergeNotes(base, local, remote string) -> {result: JSON {content, conflicts}, error}
+	mergeNotesFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 3 {
+			return nil, errArgCount
+		}
+		versions, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(sync.Merge(versions[0], versions[1], versions[2]))
+	})
+
+	// format: diffNotes(a, b string) -> {result: JSON [{type, lines}], error}
+	diffNotesFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		texts, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return marshal(sync.Diff(texts[0], texts[1]))
+	})
+
+	// format: queueAdd(queueJSON, noteID, content, baseEtag string) -> {result: queueJSON, error}
+	queueAddFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 4 {
+			return nil, errArgCount
+		}
+		fields, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		queue, err := sync.LoadOfflineQueue(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		change := sync.NewOfflineChange(fields[1], fields[2])
+		change.BaseETag = fields[3]
+		queue.Add(change)
+		return marshal(queue)
+	})
+
+	// format: queueRemove(queueJSON, noteID string) -> {result: queueJSON, error}
+	queueRemoveFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		fields, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		queue, err := sync.LoadOfflineQueue(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		queue.Remove(fields[1])
+		return marshal(queue)
 	})
 
-	js.Global().Set("renderMarkdown", renderFunc)
-	js.Global().Set("checkConflict", checkConflictFunc)
-	js.Global().Set("createOfflineChange", createOfflineChangeFunc)
+	// format: queueLength(queueJSON string) -> {result: int, error}
+	queueLengthFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		queueJSON, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		queue, err := sync.LoadOfflineQueue(queueJSON)
+		if err != nil {
+			return nil, err
+		}
+		return queue.Len(), nil
+	})
+
+	// format: crdtInsert(state, replica string, pos int, text string) -> {result: {state, ops}, error}
+	crdtInsertFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 4 {
+			return nil, errArgCount
+		}
+		doc, err := loadCRDTArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		pos, err := intArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		text, err := stringArg(args, 3)
+		if err != nil {
+			return nil, err
+		}
+		ops, err := doc.Insert(pos, text)
+		if err != nil {
+			return nil, err
+		}
+		return crdtResult(doc, ops)
+	})
+
+	// format: crdtDelete(state, replica string, pos, count int) -> {result: {state, ops}, error}
+	crdtDeleteFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 4 {
+			return nil, errArgCount
+		}
+		doc, err := loadCRDTArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		pos, err := intArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		count, err := intArg(args, 3)
+		if err != nil {
+			return nil, err
+		}
+		ops, err := doc.Delete(pos, count)
+		if err != nil {
+			return nil, err
+		}
+		return crdtResult(doc, ops)
+	})
+
+	// format: crdtApply(state, opsJSON string) -> {result: state, error}
+	crdtApplyFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		fields, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := loadCRDT(fields[0], "")
+		if err != nil {
+			return nil, err
+		}
+		var ops []crdt.Op
+		if err := json.Unmarshal([]byte(fields[1]), &ops); err != nil {
+			return nil, err
+		}
+		doc.Apply(ops)
+		data, err := doc.Encode()
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	})
+
+	// format: crdtMerge(stateA, stateB string) -> {result: state, error}
+	crdtMergeFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 2 {
+			return nil, errArgCount
+		}
+		states, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		a, err := loadCRDT(states[0], "")
+		if err != nil {
+			return nil, err
+		}
+		b, err := loadCRDT(states[1], "")
+		if err != nil {
+			return nil, err
+		}
+		a.Merge(b)
+		data, err := a.Encode()
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	})
+
+	// format: crdtText(state string) -> {result: string, error}
+	crdtTextFunc := handler(func(args []js.Value) (any, error) {
+		if len(args) != 1 {
+			return nil, errArgCount
+		}
+		state, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := loadCRDT(state, "")
+		if err != nil {
+			return nil, err
+		}
+		return doc.Text(), nil
+	})
+
+	// Each function is exposed twice: name(...) returns {<key>, error} and
+	// nameAsync(...) returns a Promise settled from a goroutine. Rendering
+	// functions use the "html" key; everything else uses "result".
+	handlers := []struct {
+		name string
+		key  string
+		h    handler
+	}{
+		{"renderMarkdown", "html", renderFunc},
+		{"renderDiff", "html", renderDiffFunc},
+		{"renderText", "result", renderTextFunc},
+		{"setRenderOptions", "result", setRenderOptionsFunc},
+		{"getNoteStats", "result", getNoteStatsFunc},
+		{"extractWikiLinks", "result", extractWikiLinksFunc},
+		{"formatMarkdown", "result", formatMarkdownFunc},
+		{"toggleTask", "result", toggleTaskFunc},
+		{"extractTasks", "result", extractTasksFunc},
+		{"extractLinks", "result", extractLinksFunc},
+		{"getSection", "result", getSectionFunc},
+		{"extractTOC", "result", extractTOCFunc},
+		{"lintMarkdown", "result", lintMarkdownFunc},
+		{"validateMermaid", "result", validateMermaidFunc},
+		{"setFrontmatterField", "result", setFrontmatterFieldFunc},
+		{"removeFrontmatterField", "result", removeFrontmatterFieldFunc},
+		{"expandTemplate", "result", expandTemplateFunc},
+		{"buildIndex", "result", buildIndexFunc},
+		{"search", "result", searchFunc},
+		{"deriveKey", "result", deriveKeyFunc},
+		{"encryptData", "result", encryptDataFunc},
+		{"decryptData", "result", decryptDataFunc},
+		{"sealNote", "result", sealNoteFunc},
+		{"openNote", "result", openNoteFunc},
+		{"isSealedNote", "result", isSealedNoteFunc},
+		{"isProtectedNote", "result", isProtectedNoteFunc},
+		{"protectNote", "result", protectNoteFunc},
+		{"unlockNote", "result", unlockNoteFunc},
+		{"sealUnlockedNote", "result", sealUnlockedNoteFunc},
+		{"isNoteUnlocked", "result", isNoteUnlockedFunc},
+		{"lockNote", "result", lockNoteFunc},
+		{"checkConflict", "result", checkConflictFunc},
+		{"createOfflineChange", "result", createOfflineChangeFunc},
+		{"mergeNotes", "result", mergeNotesFunc},
+		{"diffNotes", "result", diffNotesFunc},
+		{"queueAdd", "result", queueAddFunc},
+		{"queueRemove", "result", queueRemoveFunc},
+		{"queueLength", "result", queueLengthFunc},
+		{"crdtInsert", "result", crdtInsertFunc},
+		{"crdtDelete", "result", crdtDeleteFunc},
+		{"crdtApply", "result", crdtApplyFunc},
+		{"crdtMerge", "result", crdtMergeFunc},
+		{"crdtText", "result", crdtTextFunc},
+	}
+	for _, fn := range handlers {
+		js.Global().Set(fn.name, syncFunc(fn.key, fn.h))
+		js.Global().Set(fn.name+"Async", asyncFunc(fn.h))
+	}
 
 	fmt.Println("GophDrive Core Wasm Initialized")
 
 	// Prevent the function from returning, which would exit the Wasm module
 	select {}
 }
+
+// handler is the body of a bridge function. syncFunc and asyncFunc decide how
+// its result and error reach JavaScript.
+type handler func(args []js.Value) (any, error)
+
+var errArgCount = errors.New("Invalid number of arguments")
+
+// call runs h, turning a panic from a bad js.Value conversion into an error
+// so a malformed call cannot take down the Wasm module.
+func call(h handler, args []js.Value) (v any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	return h(args)
+}
+
+// syncFunc exposes h as a blocking call returning {<key>: value, error: null}
+// on success and {<key>: null, error: message} on failure.
+func syncFunc(key string, h handler) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		v, err := call(h, args)
+		if err != nil {
+			return js.ValueOf(map[string]any{key: nil, "error": err.Error()})
+		}
+		return js.ValueOf(map[string]any{key: v, "error": nil})
+	})
+}
+
+// asyncFunc exposes h as a function returning a Promise. h runs on its own
+// goroutine so the caller's event loop turn returns before the work starts;
+// errors reject the Promise with a JS Error.
+//
+// Wasm goroutines share the single JS thread and are not preempted, so
+// handlers never interleave mid-call and the shared renderer state needs no
+// locking.
+func asyncFunc(h handler) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		args = append([]js.Value(nil), args...)
+		executor := js.FuncOf(func(this js.Value, settle []js.Value) interface{} {
+			resolve, reject := settle[0], settle[1]
+			go func() {
+				v, err := call(h, args)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New(err.Error()))
+					return
+				}
+				resolve.Invoke(v)
+			}()
+			return nil
+		})
+		defer executor.Release()
+		return js.Global().Get("Promise").New(executor)
+	})
+}
+
+// hasArg reports whether argument i was passed as something other than
+// undefined or null.
+func hasArg(args []js.Value, i int) bool {
+	return i < len(args) && !args[i].IsUndefined() && !args[i].IsNull()
+}
+
+// argError reports a missing or mistyped argument; i is zero-based.
+func argError(i int, want string) error {
+	return fmt.Errorf("argument %d must be %s", i+1, want)
+}
+
+// stringArg returns argument i, which must be a string.
+func stringArg(args []js.Value, i int) (string, error) {
+	if i >= len(args) || args[i].Type() != js.TypeString {
+		return "", argError(i, "a string")
+	}
+	return args[i].String(), nil
+}
+
+// stringArgs returns every argument, each of which must be a string.
+func stringArgs(args []js.Value) ([]string, error) {
+	out := make([]string, len(args))
+	for i := range args {
+		s, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// intArg returns argument i, which must be a number.
+func intArg(args []js.Value, i int) (int, error) {
+	if i >= len(args) || args[i].Type() != js.TypeNumber {
+		return 0, argError(i, "a number")
+	}
+	return args[i].Int(), nil
+}
+
+// base64Arg decodes argument i, which must be a base64 string.
+func base64Arg(args []js.Value, i int) ([]byte, error) {
+	s, err := stringArg(args, i)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// marshal encodes v as a JSON string result.
+func marshal(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// loadCRDT decodes an encoded CRDT state; an empty state starts a new document.
+func loadCRDT(state, replica string) (*crdt.Document, error) {
+	if state == "" {
+		return crdt.NewDocument(replica), nil
+	}
+	return crdt.Decode([]byte(state), replica)
+}
+
+// loadCRDTArgs loads the document from the leading (state, replica) arguments.
+func loadCRDTArgs(args []js.Value) (*crdt.Document, error) {
+	state, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	replica, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return loadCRDT(state, replica)
+}
+
+// crdtResult returns the new state and the ops to relay as a JS object.
+func crdtResult(doc *crdt.Document, ops []crdt.Op) (any, error) {
+	data, err := doc.Encode()
+	if err != nil {
+		return nil, err
+	}
+	opsJSON, _ := json.Marshal(ops)
+
+	obj := js.Global().Get("Object").New()
+	obj.Set("state", string(data))
+	obj.Set("ops", string(opsJSON))
+	return obj, nil
+}
+
+// renderOptions is the JSON form of markdown.Option accepted by the bridge.
+// Unset fields keep the renderer's defaults.
+type renderOptions struct {
+	HardWraps  *bool `json:"hardWraps"`
+	UnsafeHTML *bool `json:"unsafeHTML"`
+	HeadingIDs *bool `json:"headingIDs"`
+	// Typographer turns on smart quotes, dashes and ellipses.
+	Typographer *bool `json:"typographer"`
+	// UnicodeSlugs keeps non-ASCII letters in heading IDs.
+	UnicodeSlugs *bool  `json:"unicodeSlugs"`
+	BaseURL      string `json:"baseURL"`
+	// AttachmentURL is a template such as "/api/attachments/{ref}" for
+	// relative images and attachment: references.
+	AttachmentURL string `json:"attachmentURL"`
+	Theme         string `json:"theme"`
+	DarkTheme     string `json:"darkTheme"`
+	// InlineStyles writes highlighting colours as style attributes.
+	InlineStyles *bool `json:"inlineStyles"`
+}
+
+func (o renderOptions) rendererOptions() []markdown.Option {
+	var opts []markdown.Option
+	if o.HardWraps != nil {
+		opts = append(opts, markdown.WithHardWraps(*o.HardWraps))
+	}
+	if o.UnsafeHTML != nil {
+		opts = append(opts, markdown.WithUnsafeHTML(*o.UnsafeHTML))
+	}
+	if o.HeadingIDs != nil {
+		opts = append(opts, markdown.WithHeadingIDs(*o.HeadingIDs))
+	}
+	if o.Typographer != nil {
+		opts = append(opts, markdown.WithTypographer(*o.Typographer))
+	}
+	if o.UnicodeSlugs != nil {
+		opts = append(opts, markdown.WithUnicodeSlugs(*o.UnicodeSlugs))
+	}
+	if o.AttachmentURL != "" {
+		opts = append(opts, markdown.WithImageResolver(markdown.AttachmentURL(o.AttachmentURL)))
+	}
+	if o.BaseURL != "" {
+		opts = append(opts, markdown.WithBaseURL(o.BaseURL))
+	}
+	if o.Theme != "" {
+		opts = append(opts, markdown.WithTheme(o.Theme))
+	}
+	if o.DarkTheme != "" {
+		opts = append(opts, markdown.WithDarkTheme(o.DarkTheme))
+	}
+	if o.InlineStyles != nil {
+		opts = append(opts, markdown.WithInlineStyles(*o.InlineStyles))
+	}
+	return opts
+}
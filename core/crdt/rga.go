@@ -0,0 +1,275 @@
+// Package crdt implements a Replicated Growable Array (RGA) for collaborative
+// plain-text editing. Replicas exchange operations; applying the same set of
+// operations in any order yields the same text.
+package crdt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrOutOfRange is returned when an edit position is outside the document.
+var ErrOutOfRange = errors.New("position out of range")
+
+// ID uniquely identifies a character. Counters are Lamport timestamps; the
+// replica name breaks ties.
+type ID struct {
+	Counter uint64 `json:"c"`
+	Replica string `json:"r"`
+}
+
+// less orders IDs; a greater ID wins the position nearest its origin.
+func (id ID) less(other ID) bool {
+	if id.Counter != other.Counter {
+		return id.Counter < other.Counter
+	}
+	return id.Replica < other.Replica
+}
+
+// OpType is the kind of operation.
+type OpType string
+
+const (
+	OpInsert OpType = "ins"
+	OpDelete OpType = "del"
+)
+
+// Op is a single insert or delete. Inserts carry the ID of the character they
+// follow (zero ID for the start of the document); deletes carry the target ID.
+type Op struct {
+	Type   OpType `json:"t"`
+	ID     ID     `json:"id"`
+	Origin ID     `json:"o,omitempty"`
+	Value  string `json:"v,omitempty"`
+}
+
+type element struct {
+	id      ID
+	origin  ID
+	value   rune
+	deleted bool
+}
+
+// Document is one replica's copy of the text.
+type Document struct {
+	replica  string
+	clock    uint64
+	elements []element
+	index    map[ID]int
+	// pending holds ops whose dependencies have not arrived yet.
+	pending []Op
+}
+
+// NewDocument creates an empty document for the given replica.
+func NewDocument(replica string) *Document {
+	return &Document{replica: replica, index: make(map[ID]int)}
+}
+
+// Text returns the visible text.
+func (d *Document) Text() string {
+	runes := make([]rune, 0, len(d.elements))
+	for _, e := range d.elements {
+		if !e.deleted {
+			runes = append(runes, e.value)
+		}
+	}
+	return string(runes)
+}
+
+// Insert inserts text at the visible rune position and returns the operations
+// to broadcast to other replicas.
+func (d *Document) Insert(pos int, text string) ([]Op, error) {
+	origin, err := d.originAt(pos)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []Op
+	for _, r := range text {
+		d.clock++
+		op := Op{Type: OpInsert, ID: ID{Counter: d.clock, Replica: d.replica}, Origin: origin, Value: string(r)}
+		d.integrate(op)
+		ops = append(ops, op)
+		origin = op.ID
+	}
+	return ops, nil
+}
+
+// Delete removes count runes starting at the visible position and returns the
+// operations to broadcast.
+func (d *Document) Delete(pos, count int) ([]Op, error) {
+	if pos < 0 || count < 0 {
+		return nil, ErrOutOfRange
+	}
+
+	var targets []ID
+	visible := 0
+	for _, e := range d.elements {
+		if e.deleted {
+			continue
+		}
+		if visible >= pos && visible < pos+count {
+			targets = append(targets, e.id)
+		}
+		visible++
+	}
+	if pos+count > visible {
+		return nil, ErrOutOfRange
+	}
+
+	ops := make([]Op, 0, len(targets))
+	for _, id := range targets {
+		op := Op{Type: OpDelete, ID: id}
+		d.integrate(op)
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// Apply integrates remote operations. Duplicates are ignored and operations
+// whose dependencies are missing are held until those arrive.
+func (d *Document) Apply(ops []Op) {
+	d.pending = append(d.pending, ops...)
+	for progress := true; progress; {
+		progress = false
+		remaining := d.pending[:0]
+		for _, op := range d.pending {
+			if d.ready(op) {
+				d.integrate(op)
+				progress = true
+			} else {
+				remaining = append(remaining, op)
+			}
+		}
+		d.pending = remaining
+	}
+}
+
+// Merge applies every operation known to other.
+func (d *Document) Merge(other *Document) {
+	d.Apply(other.Ops())
+}
+
+// Ops returns operations that reproduce this document's state, in causal order.
+func (d *Document) Ops() []Op {
+	ops := make([]Op, 0, len(d.elements))
+	var deletes []Op
+	for _, e := range d.elements {
+		ops = append(ops, Op{Type: OpInsert, ID: e.id, Origin: e.origin, Value: string(e.value)})
+		if e.deleted {
+			deletes = append(deletes, Op{Type: OpDelete, ID: e.id})
+		}
+	}
+	ops = append(ops, deletes...)
+	return append(ops, d.pending...)
+}
+
+// state is the serialized form of a Document.
+type state struct {
+	Replica string `json:"replica"`
+	Clock   uint64 `json:"clock"`
+	Ops     []Op   `json:"ops"`
+}
+
+// Encode serializes the document, including pending operations.
+func (d *Document) Encode() ([]byte, error) {
+	return json.Marshal(state{Replica: d.replica, Clock: d.clock, Ops: d.Ops()})
+}
+
+// Decode restores a document produced by Encode. If replica is non-empty it
+// replaces the encoded replica name, letting each client load a shared state.
+func Decode(data []byte, replica string) (*Document, error) {
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("crdt: invalid state: %w", err)
+	}
+	if replica == "" {
+		replica = s.Replica
+	}
+	d := NewDocument(replica)
+	d.Apply(s.Ops)
+	if s.Clock > d.clock {
+		d.clock = s.Clock
+	}
+	return d, nil
+}
+
+// ready reports whether op can be integrated now.
+func (d *Document) ready(op Op) bool {
+	switch op.Type {
+	case OpInsert:
+		return op.Origin == (ID{}) || d.has(op.Origin)
+	case OpDelete:
+		return d.has(op.ID)
+	}
+	return false
+}
+
+func (d *Document) has(id ID) bool {
+	_, ok := d.index[id]
+	return ok
+}
+
+// integrate applies an op whose dependencies are present.
+func (d *Document) integrate(op Op) {
+	if op.ID.Counter > d.clock {
+		d.clock = op.ID.Counter
+	}
+
+	switch op.Type {
+	case OpDelete:
+		if i, ok := d.index[op.ID]; ok {
+			d.elements[i].deleted = true
+		}
+	case OpInsert:
+		if d.has(op.ID) {
+			return
+		}
+		i := 0
+		if op.Origin != (ID{}) {
+			i = d.index[op.Origin] + 1
+		}
+		// Skip over concurrent inserts at the same origin that win the position.
+		for i < len(d.elements) && op.ID.less(d.elements[i].id) {
+			i++
+		}
+		r := []rune(op.Value)
+		var value rune
+		if len(r) > 0 {
+			value = r[0]
+		}
+		d.elements = append(d.elements, element{})
+		copy(d.elements[i+1:], d.elements[i:])
+		d.elements[i] = element{id: op.ID, origin: op.Origin, value: value}
+		d.reindex(i)
+	}
+}
+
+// reindex refreshes positions from i onward.
+func (d *Document) reindex(from int) {
+	for j := from; j < len(d.elements); j++ {
+		d.index[d.elements[j].id] = j
+	}
+}
+
+// originAt returns the ID of the visible rune before pos.
+func (d *Document) originAt(pos int) (ID, error) {
+	if pos < 0 {
+		return ID{}, ErrOutOfRange
+	}
+	if pos == 0 {
+		return ID{}, nil
+	}
+	visible := 0
+	for _, e := range d.elements {
+		if e.deleted {
+			continue
+		}
+		visible++
+		if visible == pos {
+			return e.id, nil
+		}
+	}
+	return ID{}, ErrOutOfRange
+}
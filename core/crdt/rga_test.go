@@ -0,0 +1,113 @@
+package crdt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDocument_InsertDelete(t *testing.T) {
+	d := NewDocument("a")
+	if _, err := d.Insert(0, "hello"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if _, err := d.Insert(5, " world"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if _, err := d.Delete(0, 1); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := d.Text(); got != "ello world" {
+		t.Errorf("Text() = %q, want %q", got, "ello world")
+	}
+}
+
+func TestDocument_OutOfRange(t *testing.T) {
+	d := NewDocument("a")
+	d.Insert(0, "abc")
+
+	if _, err := d.Insert(4, "x"); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Insert past end: got %v, want ErrOutOfRange", err)
+	}
+	if _, err := d.Delete(2, 2); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Delete past end: got %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestDocument_ConcurrentInsertsConverge(t *testing.T) {
+	base := NewDocument("base")
+	baseOps, _ := base.Insert(0, "ac")
+
+	a := NewDocument("a")
+	b := NewDocument("b")
+	a.Apply(baseOps)
+	b.Apply(baseOps)
+
+	opsA, _ := a.Insert(1, "X")
+	opsB, _ := b.Insert(1, "Y")
+	delB, _ := b.Delete(2, 1) // delete "c"
+
+	// Deliver in different orders
+	a.Apply(append(opsB, delB...))
+	b.Apply(opsA)
+
+	if a.Text() != b.Text() {
+		t.Fatalf("replicas diverged: a=%q b=%q", a.Text(), b.Text())
+	}
+	if len(a.Text()) != 3 {
+		t.Errorf("Text() = %q, want 3 runes", a.Text())
+	}
+}
+
+func TestDocument_OutOfOrderDelivery(t *testing.T) {
+	src := NewDocument("a")
+	ops, _ := src.Insert(0, "abc")
+	del, _ := src.Delete(1, 1)
+
+	dst := NewDocument("b")
+	// Deletes and later inserts arrive before their dependencies
+	dst.Apply(del)
+	dst.Apply([]Op{ops[2], ops[1]})
+	dst.Apply([]Op{ops[0]})
+
+	if dst.Text() != "ac" {
+		t.Errorf("Text() = %q, want %q", dst.Text(), "ac")
+	}
+
+	// Re-delivery is idempotent
+	dst.Apply(ops)
+	if dst.Text() != "ac" {
+		t.Errorf("after duplicate apply Text() = %q, want %q", dst.Text(), "ac")
+	}
+}
+
+func TestEncodeDecode_Merge(t *testing.T) {
+	a := NewDocument("a")
+	a.Insert(0, "shared")
+	data, err := a.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	b, err := Decode(data, "b")
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if b.Text() != "shared" {
+		t.Fatalf("decoded Text() = %q, want %q", b.Text(), "shared")
+	}
+
+	a.Insert(6, "!")
+	b.Insert(0, ">")
+
+	a.Merge(b)
+	b.Merge(a)
+	if a.Text() != ">shared!" || b.Text() != ">shared!" {
+		t.Errorf("after merge a=%q b=%q, want %q", a.Text(), b.Text(), ">shared!")
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	if _, err := Decode([]byte("not json"), "a"); err == nil {
+		t.Error("expected error for invalid state")
+	}
+}
@@ -0,0 +1,98 @@
+// Package crypto encrypts data with a key derived from a passphrase. The
+// frontend uses it through the Wasm bridge to encrypt its offline note cache.
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+const (
+	// KeySize is the AES-256 key length in bytes.
+	KeySize = 32
+	// SaltSize is the PBKDF2 salt length in bytes.
+	SaltSize = 16
+	// Iterations is the PBKDF2-HMAC-SHA256 work factor, per OWASP guidance.
+	Iterations = 600_000
+
+	// version prefixes each ciphertext so the format can change later.
+	version byte = 1
+)
+
+var (
+	// ErrInvalidKey is returned for a key that is not KeySize bytes.
+	ErrInvalidKey = errors.New("key must be 32 bytes")
+	// ErrDecrypt is returned when ciphertext is malformed, was tampered with,
+	// or was encrypted with a different key.
+	ErrDecrypt = errors.New("failed to decrypt: invalid ciphertext or wrong key")
+)
+
+// NewSalt returns a random salt for DeriveKey.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// DeriveKey derives an AES-256 key from passphrase and salt. The same inputs
+// always give the same key, so the salt must be stored alongside the data.
+func DeriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, Iterations, KeySize)
+}
+
+// Encrypt seals plaintext with AES-GCM under key and returns base64 of
+// version | nonce | ciphertext.
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := append([]byte{version}, nonce...)
+	out = gcm.Seal(out, nonce, []byte(plaintext), []byte{version})
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Decrypt opens a value produced by Encrypt.
+func Decrypt(key []byte, ciphertext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(data) < 1+gcm.NonceSize() || data[0] != version {
+		return "", ErrDecrypt
+	}
+	nonce, sealed := data[1:1+gcm.NonceSize()], data[1+gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, sealed, []byte{version})
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestDeriveKey(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+
+	a, err := DeriveKey("correct horse", salt)
+	if err != nil {
+		t.Fatalf("DeriveKey() error = %v", err)
+	}
+	b, _ := DeriveKey("correct horse", salt)
+	c, _ := DeriveKey("correct horse", []byte("fedcba9876543210"))
+
+	if len(a) != KeySize {
+		t.Errorf("len(key) = %d, want %d", len(a), KeySize)
+	}
+	if !bytes.Equal(a, b) {
+		t.Error("DeriveKey() is not deterministic")
+	}
+	if bytes.Equal(a, c) {
+		t.Error("DeriveKey() ignores the salt")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KeySize)
+
+	ciphertext, err := Encrypt(key, "# Secret note\n\n日本語も")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	again, _ := Encrypt(key, "# Secret note\n\n日本語も")
+	if ciphertext == again {
+		t.Error("Encrypt() reused a nonce")
+	}
+
+	plaintext, err := Decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if plaintext != "# Secret note\n\n日本語も" {
+		t.Errorf("Decrypt() = %q", plaintext)
+	}
+}
+
+func TestDecrypt_Errors(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KeySize)
+	other := bytes.Repeat([]byte{8}, KeySize)
+	ciphertext, _ := Encrypt(key, "data")
+
+	raw, _ := base64.StdEncoding.DecodeString(ciphertext)
+	raw[len(raw)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(raw)
+
+	for name, tc := range map[string]struct {
+		key        []byte
+		ciphertext string
+	}{
+		"wrong key":   {other, ciphertext},
+		"tampered":    {key, tampered},
+		"not base64":  {key, "!!!"},
+		"too short":   {key, base64.StdEncoding.EncodeToString([]byte{version})},
+		"bad version": {key, base64.StdEncoding.EncodeToString(append([]byte{9}, raw[1:]...))},
+	} {
+		if _, err := Decrypt(tc.key, tc.ciphertext); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: Decrypt() error = %v, want ErrDecrypt", name, err)
+		}
+	}
+
+	if _, err := Encrypt([]byte("short"), "data"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Encrypt() with short key error = %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,280 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// NotePrefix starts notes sealed by the first version of SealNote,
+	// whose key was derived with PBKDF2 (see DeriveKey). OpenNote still
+	// reads them. The backend recognises encrypted notes by the shared
+	// "gophdrive-e2e:" prefix and never sees their plaintext.
+	NotePrefix = "gophdrive-e2e:v1:"
+	// NotePrefixV2 starts notes sealed by SealNote, whose key is derived
+	// with Argon2id.
+	NotePrefixV2 = "gophdrive-e2e:v2:"
+	// ProtectedNotePrefix starts notes locked with a passphrase of their
+	// own by Keyring.Protect. The rest is as in NotePrefixV2; the backend
+	// reports these notes as protected as well as encrypted, so clients
+	// know to offer the unlock flow.
+	ProtectedNotePrefix = "gophdrive-e2e:protected:v2:"
+
+	// sealedPrefix is the part of the prefix every version shares.
+	sealedPrefix = "gophdrive-e2e:"
+)
+
+// ErrLocked is returned by Keyring.Seal for a note that is not unlocked.
+var ErrLocked = errors.New("note is locked")
+
+// argon2Params are the Argon2id costs a note's key was derived with. They
+// are stored in the sealed note so they can be raised for new notes without
+// breaking old ones.
+type argon2Params struct {
+	memory  uint32 // KiB
+	time    uint32
+	threads uint8
+}
+
+// noteParams are the costs for newly sealed notes: the second recommended
+// option of RFC 9106, 64 MiB and three passes.
+var noteParams = argon2Params{memory: 64 * 1024, time: 3, threads: 4}
+
+// maxParams bounds the costs OpenNote accepts, so a crafted note cannot make
+// the client allocate without limit.
+var maxParams = argon2Params{memory: 1024 * 1024, time: 16, threads: 16}
+
+func (p argon2Params) String() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", p.memory, p.time, p.threads)
+}
+
+func parseArgon2Params(s string) (argon2Params, error) {
+	var p argon2Params
+	if _, err := fmt.Sscanf(s, "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil || p.String() != s {
+		return p, ErrDecrypt
+	}
+	if p.memory < 8*uint32(p.threads) || p.time == 0 || p.threads == 0 ||
+		p.memory > maxParams.memory || p.time > maxParams.time || p.threads > maxParams.threads {
+		return p, ErrDecrypt
+	}
+	return p, nil
+}
+
+// NoteKey is the key of one sealed note. It is derived once, when the note
+// is protected or unlocked, so saving edits does not repeat the slow
+// derivation or keep the passphrase around.
+type NoteKey struct {
+	params argon2Params
+	salt   []byte
+	key    []byte
+	// protected is set for the keys of protected notes, which Seal marks
+	// with ProtectedNotePrefix.
+	protected bool
+}
+
+// newNoteKey derives a key for passphrase with a fresh salt.
+func newNoteKey(passphrase string) (*NoteKey, error) {
+	salt, err := NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	return deriveNoteKey(passphrase, salt, noteParams), nil
+}
+
+func deriveNoteKey(passphrase string, salt []byte, p argon2Params) *NoteKey {
+	return &NoteKey{
+		params: p,
+		salt:   salt,
+		key:    argon2.IDKey([]byte(passphrase), salt, p.time, p.memory, p.threads, KeySize),
+	}
+}
+
+// Seal encrypts plaintext as a note under k. Each call uses a fresh nonce:
+//
+//	"gophdrive-e2e:v2:" + "m=65536,t=3,p=4" + ":" + base64(salt) + ":" + Encrypt(key, plaintext)
+//
+// with ProtectedNotePrefix in place of NotePrefixV2 for protected notes.
+func (k *NoteKey) Seal(plaintext string) (string, error) {
+	ciphertext, err := Encrypt(k.key, plaintext)
+	if err != nil {
+		return "", err
+	}
+	prefix := NotePrefixV2
+	if k.protected {
+		prefix = ProtectedNotePrefix
+	}
+	return prefix + k.params.String() + ":" + base64.StdEncoding.EncodeToString(k.salt) + ":" + ciphertext, nil
+}
+
+// SealNote encrypts a note's content end to end with a key derived from
+// passphrase and a fresh salt with Argon2id. The result is plain text that
+// can be stored as the note's content.
+func SealNote(passphrase, plaintext string) (string, error) {
+	key, err := newNoteKey(passphrase)
+	if err != nil {
+		return "", err
+	}
+	return key.Seal(plaintext)
+}
+
+// OpenNote decrypts content produced by SealNote. It returns ErrDecrypt for
+// content that is not a sealed note or was sealed with another passphrase.
+func OpenNote(passphrase, sealed string) (string, error) {
+	_, plaintext, err := UnlockNote(passphrase, sealed)
+	return plaintext, err
+}
+
+// UnlockNote decrypts content produced by SealNote or Keyring.Protect and
+// also returns the note's key, for sealing later edits with NoteKey.Seal,
+// which keeps a protected note protected. A note sealed in the first,
+// PBKDF2 format gets a new Argon2id key, so its next save upgrades it.
+func UnlockNote(passphrase, sealed string) (*NoteKey, string, error) {
+	if rest, ok := strings.CutPrefix(sealed, NotePrefix); ok {
+		plaintext, err := openV1(passphrase, rest)
+		if err != nil {
+			return nil, "", err
+		}
+		key, err := newNoteKey(passphrase)
+		if err != nil {
+			return nil, "", err
+		}
+		return key, plaintext, nil
+	}
+
+	rest, protected := strings.CutPrefix(sealed, ProtectedNotePrefix)
+	if !protected {
+		var ok bool
+		if rest, ok = strings.CutPrefix(sealed, NotePrefixV2); !ok {
+			return nil, "", ErrDecrypt
+		}
+	}
+	fields := strings.SplitN(rest, ":", 3)
+	if len(fields) != 3 {
+		return nil, "", ErrDecrypt
+	}
+	params, err := parseArgon2Params(fields[0])
+	if err != nil {
+		return nil, "", err
+	}
+	salt, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil || len(salt) != SaltSize {
+		return nil, "", ErrDecrypt
+	}
+	key := deriveNoteKey(passphrase, salt, params)
+	key.protected = protected
+	plaintext, err := Decrypt(key.key, fields[2])
+	if err != nil {
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
+
+// openV1 decrypts the part of a first-format note after NotePrefix:
+// base64(salt) + ":" + Encrypt(PBKDF2 key, plaintext).
+func openV1(passphrase, rest string) (string, error) {
+	encodedSalt, ciphertext, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", ErrDecrypt
+	}
+	salt, err := base64.StdEncoding.DecodeString(encodedSalt)
+	if err != nil || len(salt) != SaltSize {
+		return "", ErrDecrypt
+	}
+	key, err := DeriveKey(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	return Decrypt(key, ciphertext)
+}
+
+// IsSealedNote reports whether content was produced by SealNote, in any
+// version, or Keyring.Protect.
+func IsSealedNote(content string) bool {
+	return strings.HasPrefix(content, sealedPrefix)
+}
+
+// IsProtectedNote reports whether content was produced by Keyring.Protect,
+// so opening it needs the note's own passphrase.
+func IsProtectedNote(content string) bool {
+	return strings.HasPrefix(content, ProtectedNotePrefix)
+}
+
+// Keyring holds the keys of the notes a client has unlocked, by note ID.
+// The Wasm bridge keeps one so keys never leave WebAssembly memory. The
+// zero value is an empty keyring, safe for concurrent use.
+type Keyring struct {
+	mu   sync.Mutex
+	keys map[string]*NoteKey
+}
+
+func (r *Keyring) put(noteID string, key *NoteKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keys == nil {
+		r.keys = make(map[string]*NoteKey)
+	}
+	r.keys[noteID] = key
+}
+
+// Protect seals plaintext as a protected note with a new key derived from
+// passphrase and keeps the note unlocked.
+func (r *Keyring) Protect(noteID, passphrase, plaintext string) (string, error) {
+	key, err := newNoteKey(passphrase)
+	if err != nil {
+		return "", err
+	}
+	key.protected = true
+	sealed, err := key.Seal(plaintext)
+	if err != nil {
+		return "", err
+	}
+	r.put(noteID, key)
+	return sealed, nil
+}
+
+// Unlock opens a sealed note and keeps its key until Lock.
+func (r *Keyring) Unlock(noteID, passphrase, sealed string) (string, error) {
+	key, plaintext, err := UnlockNote(passphrase, sealed)
+	if err != nil {
+		return "", err
+	}
+	r.put(noteID, key)
+	return plaintext, nil
+}
+
+// Seal encrypts an edit of an unlocked note. It returns ErrLocked if the
+// note has not been protected or unlocked, or was locked again.
+func (r *Keyring) Seal(noteID, plaintext string) (string, error) {
+	r.mu.Lock()
+	key, ok := r.keys[noteID]
+	r.mu.Unlock()
+	if !ok {
+		return "", ErrLocked
+	}
+	return key.Seal(plaintext)
+}
+
+// Unlocked reports whether noteID's key is held.
+func (r *Keyring) Unlocked(noteID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.keys[noteID]
+	return ok
+}
+
+// Lock forgets noteID's key. An empty noteID forgets every key, e.g. on
+// sign-out.
+func (r *Keyring) Lock(noteID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if noteID == "" {
+		clear(r.keys)
+		return
+	}
+	delete(r.keys, noteID)
+}
@@ -0,0 +1,169 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealNote_RoundTrip(t *testing.T) {
+	plaintext := "# Diary\n\nNothing to see here."
+
+	sealed, err := SealNote("correct horse", plaintext)
+	if err != nil {
+		t.Fatalf("SealNote() error = %v", err)
+	}
+	if !strings.HasPrefix(sealed, NotePrefixV2+"m=65536,t=3,p=4:") {
+		t.Errorf("SealNote() = %q, want the Argon2id format", sealed)
+	}
+	if !IsSealedNote(sealed) {
+		t.Errorf("IsSealedNote(%q) = false, want true", sealed)
+	}
+	if strings.Contains(sealed, "Diary") {
+		t.Errorf("sealed note contains plaintext: %q", sealed)
+	}
+
+	got, err := OpenNote("correct horse", sealed)
+	if err != nil {
+		t.Fatalf("OpenNote() error = %v", err)
+	}
+	if got != plaintext {
+		t.Errorf("OpenNote() = %q, want %q", got, plaintext)
+	}
+}
+
+func TestSealNote_FreshSalt(t *testing.T) {
+	a, _ := SealNote("pw", "same")
+	b, _ := SealNote("pw", "same")
+	if a == b {
+		t.Error("sealing the same note twice gave identical output")
+	}
+}
+
+func TestOpenNote_Errors(t *testing.T) {
+	sealed, err := SealNote("correct horse", "secret")
+	if err != nil {
+		t.Fatalf("SealNote() error = %v", err)
+	}
+	fields := strings.SplitN(strings.TrimPrefix(sealed, NotePrefixV2), ":", 3)
+
+	tests := []struct {
+		name       string
+		passphrase string
+		content    string
+	}{
+		{"wrong passphrase", "battery staple", sealed},
+		{"not sealed", "correct horse", "# Plain note"},
+		{"missing ciphertext", "correct horse", NotePrefixV2 + "m=65536,t=3,p=4:c2FsdA=="},
+		{"bad salt", "correct horse", NotePrefixV2 + "m=65536,t=3,p=4:!!!:" + fields[2]},
+		{"bad params", "correct horse", NotePrefixV2 + "m=64,t=3:" + fields[1] + ":" + fields[2]},
+		{"excessive memory", "correct horse", NotePrefixV2 + "m=4294967295,t=3,p=4:" + fields[1] + ":" + fields[2]},
+		{"v1 missing ciphertext", "correct horse", NotePrefix + "c2FsdA=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := OpenNote(tt.passphrase, tt.content); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("OpenNote() error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+// sealV1 seals plaintext in the first, PBKDF2 format.
+func sealV1(t *testing.T, passphrase, plaintext string) string {
+	t.Helper()
+	salt, _ := NewSalt()
+	key, err := DeriveKey(passphrase, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(key, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NotePrefix + base64.StdEncoding.EncodeToString(salt) + ":" + ciphertext
+}
+
+func TestOpenNote_V1(t *testing.T) {
+	sealed := sealV1(t, "correct horse", "old secret")
+	if !IsSealedNote(sealed) {
+		t.Errorf("IsSealedNote(%q) = false, want true", sealed)
+	}
+	got, err := OpenNote("correct horse", sealed)
+	if err != nil || got != "old secret" {
+		t.Fatalf("OpenNote() = %q, %v; want the plaintext", got, err)
+	}
+
+	// Resealing after unlocking upgrades the note to Argon2id.
+	key, _, err := UnlockNote("correct horse", sealed)
+	if err != nil {
+		t.Fatalf("UnlockNote() error = %v", err)
+	}
+	resealed, _ := key.Seal("edited")
+	if !strings.HasPrefix(resealed, NotePrefixV2) {
+		t.Errorf("resealed v1 note = %q, want the v2 format", resealed)
+	}
+	if got, err := OpenNote("correct horse", resealed); err != nil || got != "edited" {
+		t.Errorf("OpenNote(resealed) = %q, %v", got, err)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	var r Keyring
+
+	if _, err := r.Seal("a", "text"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Seal() before unlocking error = %v, want ErrLocked", err)
+	}
+
+	sealed, err := r.Protect("a", "correct horse", "first")
+	if err != nil {
+		t.Fatalf("Protect() error = %v", err)
+	}
+	if !r.Unlocked("a") {
+		t.Error("note is not unlocked after Protect")
+	}
+	edited, err := r.Seal("a", "second")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if edited == sealed {
+		t.Error("Seal() reused the nonce")
+	}
+	if !IsProtectedNote(sealed) || !IsProtectedNote(edited) || !IsSealedNote(edited) {
+		t.Errorf("protected note = %q, want the protected format", edited)
+	}
+	if got, _ := OpenNote("correct horse", edited); got != "second" {
+		t.Errorf("OpenNote(edited) = %q, want %q", got, "second")
+	}
+
+	r.Lock("a")
+	if _, err := r.Seal("a", "third"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Seal() after Lock error = %v, want ErrLocked", err)
+	}
+	if _, err := r.Unlock("a", "wrong", edited); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Unlock() with the wrong passphrase error = %v, want ErrDecrypt", err)
+	}
+	if got, err := r.Unlock("a", "correct horse", edited); err != nil || got != "second" {
+		t.Errorf("Unlock() = %q, %v", got, err)
+	}
+	if resealed, _ := r.Seal("a", "third"); !IsProtectedNote(resealed) {
+		t.Errorf("Seal() after Unlock = %q, want the note to stay protected", resealed)
+	}
+
+	// A note encrypted end to end is not protected, and stays so when
+	// unlocked and edited.
+	e2e, _ := SealNote("correct horse", "shared")
+	if IsProtectedNote(e2e) {
+		t.Errorf("SealNote() = %q, want it unprotected", e2e)
+	}
+	r.Unlock("b", "correct horse", e2e)
+	if resealed, _ := r.Seal("b", "edited"); !strings.HasPrefix(resealed, NotePrefixV2) {
+		t.Errorf("Seal() of an unlocked e2e note = %q, want the v2 format", resealed)
+	}
+
+	r.Lock("")
+	if r.Unlocked("a") {
+		t.Error("Lock(\"\") kept a key")
+	}
+}
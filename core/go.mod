@@ -3,11 +3,14 @@ module github.com/jun/gophdrive/core
 go 1.26.0
 
 require (
+	github.com/alecthomas/chroma/v2 v2.23.1
 	github.com/yuin/goldmark v1.7.16
+	github.com/yuin/goldmark-emoji v1.0.6
 	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
+	golang.org/x/crypto v0.47.0
 )
 
 require (
-	github.com/alecthomas/chroma/v2 v2.23.1 // indirect
 	github.com/dlclark/regexp2 v1.11.5 // indirect
+	golang.org/x/sys v0.40.0 // indirect
 )
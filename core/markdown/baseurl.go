@@ -0,0 +1,57 @@
+package markdown
+
+import (
+	"net/url"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// baseURLTransformer resolves relative link and image destinations against
+// a base URL. Absolute URLs and in-page #fragments are left alone.
+type baseURLTransformer struct {
+	base *url.URL
+}
+
+func (t *baseURLTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Link:
+			n.Destination = t.resolve(n.Destination)
+		case *ast.Image:
+			n.Destination = t.resolve(n.Destination)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func (t *baseURLTransformer) resolve(dest []byte) []byte {
+	if len(dest) == 0 || dest[0] == '#' {
+		return dest
+	}
+	ref, err := url.Parse(string(dest))
+	if err != nil || ref.IsAbs() {
+		return dest
+	}
+	return []byte(t.base.ResolveReference(ref).String())
+}
+
+type baseURLExtension struct {
+	base string
+}
+
+func (e *baseURLExtension) Extend(m goldmark.Markdown) {
+	base, err := url.Parse(e.base)
+	if err != nil {
+		return
+	}
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(util.Prioritized(&baseURLTransformer{base: base}, 100)),
+	)
+}
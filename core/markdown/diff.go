@@ -0,0 +1,212 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxDiffCells caps the LCS table size. Larger differences are shown as
+// whole removed and added runs instead.
+const maxDiffCells = 1 << 20
+
+// RenderDiff renders newSource with the changes from oldSource marked up.
+// Unchanged top-level blocks render normally, removed and added blocks are
+// wrapped in <del class="diff"> and <ins class="diff">, and a block replaced
+// by another is shown as a word-level diff of its text inside
+// <div class="diff-changed">.
+func (r *Renderer) RenderDiff(oldSource, newSource []byte) ([]byte, error) {
+	oldBlocks := splitBlocks(oldSource)
+	newBlocks := splitBlocks(newSource)
+
+	var out bytes.Buffer
+	for _, run := range diffRuns(blockStrings(oldBlocks), blockStrings(newBlocks)) {
+		if run.equal {
+			for _, block := range run.inserted {
+				if err := r.writeBlock(&out, block); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		paired := min(len(run.deleted), len(run.inserted))
+		for i := 0; i < paired; i++ {
+			out.WriteString(`<div class="diff-changed"><p>`)
+			oldText := strings.TrimSpace(string(r.RenderText([]byte(run.deleted[i]))))
+			newText := strings.TrimSpace(string(r.RenderText([]byte(run.inserted[i]))))
+			writeWordDiff(&out, oldText, newText)
+			out.WriteString("</p></div>\n")
+		}
+		for _, block := range run.deleted[paired:] {
+			out.WriteString(`<del class="diff">`)
+			if err := r.writeBlock(&out, block); err != nil {
+				return nil, err
+			}
+			out.WriteString("</del>\n")
+		}
+		for _, block := range run.inserted[paired:] {
+			out.WriteString(`<ins class="diff">`)
+			if err := r.writeBlock(&out, block); err != nil {
+				return nil, err
+			}
+			out.WriteString("</ins>\n")
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func (r *Renderer) writeBlock(out *bytes.Buffer, block string) error {
+	html, err := r.Render([]byte(block))
+	if err != nil {
+		return err
+	}
+	out.Write(html)
+	return nil
+}
+
+func blockStrings(blocks [][]byte) []string {
+	out := make([]string, len(blocks))
+	for i, b := range blocks {
+		// Trailing blank lines separate blocks and are not content.
+		out[i] = strings.TrimRight(string(b), "\r\n") + "\n"
+	}
+	return out
+}
+
+// diffRun is a stretch of a diff: either items common to both sides
+// (equal, held in inserted) or items deleted and inserted between them.
+type diffRun struct {
+	equal    bool
+	deleted  []string
+	inserted []string
+}
+
+// diffRuns compares a and b with a longest-common-subsequence diff after
+// trimming the common prefix and suffix.
+func diffRuns(a, b []string) []diffRun {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var runs []diffRun
+	run := func(equal bool) *diffRun {
+		if len(runs) == 0 || runs[len(runs)-1].equal != equal {
+			runs = append(runs, diffRun{equal: equal})
+		}
+		return &runs[len(runs)-1]
+	}
+	keep := func(s string) { r := run(true); r.inserted = append(r.inserted, s) }
+	del := func(s string) { r := run(false); r.deleted = append(r.deleted, s) }
+	ins := func(s string) { r := run(false); r.inserted = append(r.inserted, s) }
+
+	for _, s := range b[:prefix] {
+		keep(s)
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, s := range midA {
+			del(s)
+		}
+		for _, s := range midB {
+			ins(s)
+		}
+	} else {
+		// lcs[i][j] is the LCS length of midA[i:] and midB[j:].
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(midA) || j < len(midB) {
+			switch {
+			case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+				keep(midB[j])
+				i++
+				j++
+			case j < len(midB) && (i == len(midA) || lcs[i][j+1] > lcs[i+1][j]):
+				ins(midB[j])
+				j++
+			default:
+				del(midA[i])
+				i++
+			}
+		}
+	}
+
+	for _, s := range b[len(b)-suffix:] {
+		keep(s)
+	}
+	return runs
+}
+
+// writeWordDiff writes newText as escaped HTML with words removed from
+// oldText in <del class="diff"> and added words in <ins class="diff">.
+func writeWordDiff(out *bytes.Buffer, oldText, newText string) {
+	for _, run := range diffRuns(diffTokens(oldText), diffTokens(newText)) {
+		if run.equal {
+			writeDiffText(out, strings.Join(run.inserted, ""))
+			continue
+		}
+		if deleted := strings.Join(run.deleted, ""); deleted != "" {
+			out.WriteString(`<del class="diff">`)
+			writeDiffText(out, deleted)
+			out.WriteString("</del>")
+		}
+		if inserted := strings.Join(run.inserted, ""); inserted != "" {
+			out.WriteString(`<ins class="diff">`)
+			writeDiffText(out, inserted)
+			out.WriteString("</ins>")
+		}
+	}
+}
+
+func writeDiffText(out *bytes.Buffer, s string) {
+	out.WriteString(strings.ReplaceAll(html.EscapeString(s), "\n", "<br />\n"))
+}
+
+// diffTokens splits s into words and the whitespace between them.
+// Punctuation and CJK characters are tokens on their own, since those
+// scripts do not separate words with spaces.
+func diffTokens(s string) []string {
+	var tokens []string
+	start := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if unicode.IsPunct(r) || unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			if start < i {
+				tokens = append(tokens, s[start:i])
+			}
+			tokens = append(tokens, s[i:i+size])
+			start = i + size
+		} else if i > start {
+			prev, _ := utf8.DecodeLastRuneInString(s[:i])
+			if unicode.IsSpace(prev) != unicode.IsSpace(r) {
+				tokens = append(tokens, s[start:i])
+				start = i
+			}
+		}
+		i += size
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
@@ -0,0 +1,55 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderer_RenderDiff(t *testing.T) {
+	oldSource := "# Title\n\nThe quick brown fox.\n\nRemoved paragraph.\n\n- item\n"
+	newSource := "# Title\n\nThe quick red fox jumps.\n\n- item\n\nAdded **paragraph**.\n"
+
+	output, err := NewRenderer().RenderDiff([]byte(oldSource), []byte(newSource))
+	if err != nil {
+		t.Fatalf("RenderDiff() error = %v", err)
+	}
+	got := string(output)
+
+	for _, want := range []string{
+		`<h1 id="title">Title</h1>`,
+		`<div class="diff-changed"><p>The quick <del class="diff">brown</del><ins class="diff">red</ins> fox<ins class="diff"> jumps</ins>.</p></div>`,
+		"<del class=\"diff\"><p>Removed paragraph.</p>\n</del>",
+		"<ul>\n<li>item</li>\n</ul>",
+		"<ins class=\"diff\"><p>Added <strong>paragraph</strong>.</p>\n</ins>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderDiff() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderer_RenderDiff_Unchanged(t *testing.T) {
+	source := []byte("# Same\n\ntext <b>&</b>\n")
+	renderer := NewRenderer()
+	diff, _ := renderer.RenderDiff(source, source)
+	html, _ := renderer.Render(source)
+	if string(diff) != string(html) {
+		t.Errorf("RenderDiff() of identical sources = %q, want %q", diff, html)
+	}
+}
+
+func TestRenderer_RenderDiff_EscapesChangedText(t *testing.T) {
+	output, _ := NewRenderer().RenderDiff([]byte("a <b>\n"), []byte("a <i>\n"))
+	if strings.Contains(string(output), "<i>") {
+		t.Errorf("RenderDiff() = %q, want changed text escaped", output)
+	}
+}
+
+func TestDiffTokens(t *testing.T) {
+	got := diffTokens("hello,  world 日本語 ok")
+	want := []string{"hello", ",", "  ", "world", " ", "日", "本", "語", " ", "ok"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTokens() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,11 @@
+// Package markdown renders GophDrive notes to HTML and analyses their
+// source: table of contents, links, statistics, formatting, linting and
+// frontmatter edits.
+//
+// The package has no Wasm dependencies. The browser uses it through
+// core/bridge, and Go programs such as a CLI or a server-side exporter can
+// import it directly to get byte-for-byte the same output:
+//
+//	r := markdown.NewRenderer(markdown.WithBaseURL("https://example.com/"))
+//	html, err := r.RenderSanitized(source)
+package markdown
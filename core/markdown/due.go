@@ -0,0 +1,66 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// dueDatePattern matches a task's due date, written as in the Obsidian
+// Tasks plugin: "- [ ] pay rent 📅 2024-06-01".
+var dueDatePattern = regexp.MustCompile(`📅\s*(\d{4}-\d{2}-\d{2})`)
+
+// Task is a task list item found in a note.
+type Task struct {
+	// Index is the task's position as passed to ToggleTask.
+	Index int `json:"index"`
+	// Text is the task's first line without the checkbox and due date.
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+	// Due is the task's due date as YYYY-MM-DD, or "" if it has none.
+	Due string `json:"due,omitempty"`
+	// Line is the 1-based line number of the task.
+	Line int `json:"line"`
+}
+
+// ExtractTasks returns every task in source in document order, with due
+// dates parsed. A due date that is not a real date is left in Text.
+func ExtractTasks(source []byte) []Task {
+	doc := statsParser.Parse(text.NewReader(source))
+
+	tasks := []Task{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		box, ok := n.(*extast.TaskCheckBox)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		seg := n.Parent().Lines().At(0)
+		// The first line starts with the checkbox: "[ ] ".
+		line := string(source[seg.Start:seg.Stop])
+		if len(line) >= 3 {
+			line = line[3:]
+		}
+
+		task := Task{
+			Index: len(tasks),
+			Done:  box.IsChecked,
+			Line:  bytes.Count(source[:seg.Start], []byte("\n")) + 1,
+		}
+		if m := dueDatePattern.FindStringSubmatchIndex(line); m != nil {
+			due := line[m[2]:m[3]]
+			if _, err := time.Parse(time.DateOnly, due); err == nil {
+				task.Due = due
+				line = line[:m[0]] + line[m[1]:]
+			}
+		}
+		task.Text = strings.Join(strings.Fields(line), " ")
+		tasks = append(tasks, task)
+		return ast.WalkContinue, nil
+	})
+	return tasks
+}
@@ -0,0 +1,26 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractTasks(t *testing.T) {
+	source := "# Bills\n\n- [ ] pay rent 📅 2024-06-01\n- [x] renew passport 📅2024-05-20 urgent\n  - [ ] no date\n\n```\n- [ ] not a task 📅 2024-01-01\n```\n\n1. [ ] bad date 📅 2024-13-01\n"
+
+	want := []Task{
+		{Index: 0, Text: "pay rent", Due: "2024-06-01", Line: 3},
+		{Index: 1, Text: "renew passport urgent", Done: true, Due: "2024-05-20", Line: 4},
+		{Index: 2, Text: "no date", Line: 5},
+		{Index: 3, Text: "bad date 📅 2024-13-01", Line: 11},
+	}
+	if got := ExtractTasks([]byte(source)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractTasks =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestExtractTasks_None(t *testing.T) {
+	if got := ExtractTasks([]byte("Just prose.\n")); got == nil || len(got) != 0 {
+		t.Errorf("ExtractTasks = %#v, want an empty slice", got)
+	}
+}
@@ -0,0 +1,69 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var ticketPattern = regexp.MustCompile(`^#\d+`)
+
+// ticketParser links ticket references such as #42, as a deployment might.
+type ticketParser struct{}
+
+func (p *ticketParser) Trigger() []byte {
+	return []byte{'#'}
+}
+
+func (p *ticketParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	id := ticketPattern.Find(line)
+	if id == nil {
+		return nil
+	}
+	block.Advance(len(id))
+	link := ast.NewLink()
+	link.Destination = []byte("https://tickets.example.com/" + string(id[1:]))
+	link.AppendChild(link, ast.NewTextSegment(segment.WithStop(segment.Start+len(id))))
+	return link
+}
+
+type ticketExtension struct{}
+
+func (e *ticketExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&ticketParser{}, 500)))
+}
+
+// externalLinks marks absolute links to open in a new tab.
+type externalLinks struct{}
+
+func (t *externalLinks) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*ast.Link); ok && entering && strings.HasPrefix(string(link.Destination), "https://") {
+			link.SetAttributeString("target", []byte("_blank"))
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func TestNewRendererWithExtensions(t *testing.T) {
+	renderer := NewRendererWithExtensions(
+		[]goldmark.Extender{&ticketExtension{}},
+		WithASTTransformers(util.Prioritized(&externalLinks{}, 500)),
+	)
+
+	output, err := renderer.Render([]byte("Fixed in #42, see [docs](/docs)."))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := `<p>Fixed in <a href="https://tickets.example.com/42" target="_blank">#42</a>, see <a href="/docs">docs</a>.</p>`
+	if !strings.Contains(string(output), want) {
+		t.Errorf("Render() = %q, want %q", output, want)
+	}
+}
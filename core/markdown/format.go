@@ -0,0 +1,280 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FormatRules selects which normalisations Format applies.
+type FormatRules struct {
+	// ListMarker replaces bullet list markers with "-", "*" or "+". Empty
+	// leaves markers alone.
+	ListMarker string `json:"listMarker"`
+	// AlignTables pads table cells so the columns line up.
+	AlignTables bool `json:"alignTables"`
+	// HeadingSpacing normalises "#  Title ##" to "# Title" and surrounds
+	// headings with blank lines.
+	HeadingSpacing bool `json:"headingSpacing"`
+	// TrimTrailingWhitespace strips trailing spaces and tabs. The renderer
+	// uses hard wraps, so trailing-space line breaks are not needed.
+	TrimTrailingWhitespace bool `json:"trimTrailingWhitespace"`
+}
+
+// DefaultFormatRules returns the rules used for format-on-save.
+func DefaultFormatRules() FormatRules {
+	return FormatRules{
+		ListMarker:             "-",
+		AlignTables:            true,
+		HeadingSpacing:         true,
+		TrimTrailingWhitespace: true,
+	}
+}
+
+var (
+	fencePattern         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	atxHeadingPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	bulletPattern        = regexp.MustCompile(`^([ \t]*)([-*+])([ \t]+)`)
+	orderedPattern       = regexp.MustCompile(`^[ \t]*\d{1,9}[.)][ \t]+`)
+	thematicBreakPattern = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	tableDelimPattern    = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+)
+
+// Format normalises Markdown source according to rules. Fenced code blocks
+// are left untouched, and the result always ends with a single newline.
+func Format(source []byte, rules FormatRules) []byte {
+	lines := strings.Split(strings.ReplaceAll(string(source), "\r\n", "\n"), "\n")
+
+	var out []string
+	var fence string
+	prevList := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if fence != "" {
+			out = append(out, line)
+			if m := fencePattern.FindStringSubmatch(line); m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) &&
+				strings.TrimSpace(line[len(m[0]):]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			if rules.TrimTrailingWhitespace {
+				line = strings.TrimRight(line, " \t")
+			}
+			out = append(out, line)
+			prevList = false
+			continue
+		}
+
+		if rules.TrimTrailingWhitespace {
+			line = strings.TrimRight(line, " \t")
+		}
+
+		if rules.AlignTables && strings.Contains(line, "|") && i+1 < len(lines) && tableDelimPattern.MatchString(lines[i+1]) {
+			end := i + 2
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" && strings.Contains(lines[end], "|") {
+				end++
+			}
+			if table := formatTable(lines[i:end]); table != nil {
+				out = append(out, table...)
+				i = end - 1
+				prevList = false
+				continue
+			}
+		}
+
+		if rules.HeadingSpacing {
+			if m := atxHeadingPattern.FindStringSubmatch(line); m != nil {
+				line = m[1]
+				if m[2] != "" {
+					line += " " + m[2]
+				}
+				if len(out) > 0 && out[len(out)-1] != "" {
+					out = append(out, "")
+				}
+				out = append(out, line)
+				if i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					out = append(out, "")
+				}
+				prevList = false
+				continue
+			}
+		}
+
+		isList := !thematicBreakPattern.MatchString(line) && (bulletPattern.MatchString(line) || orderedPattern.MatchString(line))
+		if rules.ListMarker != "" && isList {
+			if m := bulletPattern.FindStringSubmatch(line); m != nil && (indentWidth(m[1]) < 4 || prevList) {
+				line = m[1] + rules.ListMarker + line[len(m[1])+1:]
+			}
+		}
+		if strings.TrimSpace(line) != "" {
+			// Indented lines continue the list they follow.
+			prevList = isList || (prevList && indentWidth(line) > 0)
+		}
+		out = append(out, line)
+	}
+
+	result := strings.TrimRight(strings.Join(out, "\n"), "\n")
+	if result == "" {
+		return []byte{}
+	}
+	return []byte(result + "\n")
+}
+
+func indentWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+type cellAlign int
+
+const (
+	alignNone cellAlign = iota
+	alignLeft
+	alignCenter
+	alignRight
+)
+
+// formatTable pads a GFM table's cells. lines holds the header, delimiter
+// and body rows. It returns nil if the header and delimiter disagree.
+func formatTable(lines []string) []string {
+	header := splitTableRow(lines[0])
+	delims := splitTableRow(lines[1])
+	if len(header) != len(delims) {
+		return nil
+	}
+
+	aligns := make([]cellAlign, len(delims))
+	for i, d := range delims {
+		left, right := strings.HasPrefix(d, ":"), strings.HasSuffix(d, ":")
+		switch {
+		case left && right:
+			aligns[i] = alignCenter
+		case left:
+			aligns[i] = alignLeft
+		case right:
+			aligns[i] = alignRight
+		}
+	}
+
+	rows := [][]string{header}
+	for _, line := range lines[2:] {
+		cells := splitTableRow(line)
+		// GFM pads short rows and drops extra cells.
+		for len(cells) < len(header) {
+			cells = append(cells, "")
+		}
+		rows = append(rows, cells[:len(header)])
+	}
+
+	widths := make([]int, len(header))
+	for i := range widths {
+		widths[i] = 3
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+
+	out := make([]string, 0, len(lines))
+	for r, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = padCell(cell, widths[i], aligns[i])
+		}
+		out = append(out, "| "+strings.Join(cells, " | ")+" |")
+
+		if r == 0 {
+			for i, align := range aligns {
+				dashes := widths[i]
+				prefix, suffix := "", ""
+				if align == alignLeft || align == alignCenter {
+					prefix = ":"
+					dashes--
+				}
+				if align == alignRight || align == alignCenter {
+					suffix = ":"
+					dashes--
+				}
+				cells[i] = prefix + strings.Repeat("-", dashes) + suffix
+			}
+			out = append(out, "| "+strings.Join(cells, " | ")+" |")
+		}
+	}
+	return out
+}
+
+// splitTableRow splits a table row on unescaped pipes outside code spans.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	inCode := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			cell.WriteByte(c)
+			cell.WriteByte(line[i+1])
+			i++
+			continue
+		case c == '`':
+			inCode = !inCode
+		case c == '|' && !inCode:
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(c)
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func padCell(cell string, width int, align cellAlign) string {
+	pad := width - displayWidth(cell)
+	switch align {
+	case alignRight:
+		return strings.Repeat(" ", pad) + cell
+	case alignCenter:
+		return strings.Repeat(" ", pad/2) + cell + strings.Repeat(" ", pad-pad/2)
+	default:
+		return cell + strings.Repeat(" ", pad)
+	}
+}
+
+// displayWidth approximates the monospace width of s, counting CJK and
+// fullwidth characters as two columns.
+func displayWidth(s string) int {
+	width := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+			(r >= 0xFF01 && r <= 0xFF60) || (r >= 0x3000 && r <= 0x303F) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
@@ -0,0 +1,72 @@
+package markdown
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "List markers",
+			input: "* one\n+ two\n    * nested\n\n* * *\n",
+			want:  "- one\n- two\n    - nested\n\n* * *\n",
+		},
+		{
+			name:  "Indented code is not a list",
+			input: "text\n\n    * code\n",
+			want:  "text\n\n    * code\n",
+		},
+		{
+			name:  "Heading spacing",
+			input: "intro\n#   Title  ##\nbody\n##Not a heading\n",
+			want:  "intro\n\n# Title\n\nbody\n##Not a heading\n",
+		},
+		{
+			name:  "Trailing whitespace and final newline",
+			input: "line   \nnext\t\n\n\n",
+			want:  "line\nnext\n",
+		},
+		{
+			name:  "Table alignment",
+			input: "|a|long header|c|\n|:-|:-:|-:|\n|1|2|333|\n|x|`a|b`|\n",
+			want: "| a   | long header |   c |\n" +
+				"| :-- | :---------: | --: |\n" +
+				"| 1   |      2      | 333 |\n" +
+				"| x   |    `a|b`    |     |\n",
+		},
+		{
+			name:  "Wide characters",
+			input: "| 名前 | n |\n|---|---|\n| 山田太郎 | 1 |\n",
+			want:  "| 名前     | n   |\n| -------- | --- |\n| 山田太郎 | 1   |\n",
+		},
+		{
+			name:  "Code blocks untouched",
+			input: "```\n* keep   \n#  keep\n```\n* fix\n",
+			want:  "```\n* keep   \n#  keep\n```\n- fix\n",
+		},
+		{
+			name:  "Empty",
+			input: "\n\n",
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Format([]byte(tt.input), DefaultFormatRules()))
+			if got != tt.want {
+				t.Errorf("Format() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat_RulesDisabled(t *testing.T) {
+	input := "* one\n#  Title\n|a|b|\n|-|-|\n"
+	got := string(Format([]byte(input), FormatRules{}))
+	if got != input {
+		t.Errorf("Format() with no rules = %q, want %q", got, input)
+	}
+}
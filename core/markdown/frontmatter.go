@@ -0,0 +1,270 @@
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidFrontmatterKey is returned for keys that cannot be written as
+	// a plain top-level YAML key.
+	ErrInvalidFrontmatterKey = errors.New("invalid frontmatter key")
+	// ErrUnsupportedFrontmatterValue is returned for values other than
+	// strings, numbers, booleans, nil and lists of strings.
+	ErrUnsupportedFrontmatterValue = errors.New("unsupported frontmatter value")
+)
+
+// plainScalar matches strings that YAML reads back unchanged without quotes.
+// Leading digits are excluded so numbers, dates and hex stay strings.
+var plainScalar = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./ ()+-]*$`)
+
+// yamlKeywords are plain scalars YAML would read as something other than a
+// string.
+var yamlKeywords = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"null": true, "~": true,
+}
+
+// frontmatter locates the YAML block that opens source. lines holds the
+// source split after each newline; the block's fields are lines[1:end] and
+// the closing delimiter is lines[end]. ok is false when source has no
+// terminated frontmatter block.
+func frontmatter(source []byte) (lines [][]byte, end int, ok bool) {
+	lines = bytes.SplitAfter(source, []byte("\n"))
+	if len(lines) == 0 || string(bytes.TrimRight(lines[0], " \t\r\n")) != "---" {
+		return lines, 0, false
+	}
+	for i := 1; i < len(lines); i++ {
+		switch string(bytes.TrimRight(lines[i], " \t\r\n")) {
+		case "---", "...":
+			return lines, i, true
+		}
+	}
+	return lines, 0, false
+}
+
+// fieldRange returns the lines [start, stop) holding top-level key within
+// the frontmatter fields lines[1:end], including an indented or list-item
+// continuation such as a block sequence. start is -1 when key is absent.
+func fieldRange(lines [][]byte, end int, key string) (start, stop int) {
+	prefix := key + ":"
+	for i := 1; i < end; i++ {
+		line := lines[i]
+		if !bytes.HasPrefix(line, []byte(prefix)) {
+			continue
+		}
+		if rest := line[len(prefix):]; len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\r' && rest[0] != '\n' {
+			continue
+		}
+		stop = i + 1
+		for stop < end {
+			next := lines[stop]
+			if len(bytes.TrimSpace(next)) == 0 || next[0] == ' ' || next[0] == '\t' || bytes.HasPrefix(next, []byte("- ")) {
+				stop++
+				continue
+			}
+			break
+		}
+		// Blank lines after the value separate it from the next field.
+		for stop > i+1 && len(bytes.TrimSpace(lines[stop-1])) == 0 {
+			stop--
+		}
+		return i, stop
+	}
+	return -1, -1
+}
+
+// FrontmatterLines returns the number of lines the YAML frontmatter that
+// opens source takes up, delimiters included, or 0 when source has none.
+func FrontmatterLines(source []byte) int {
+	_, end, ok := frontmatter(source)
+	if !ok {
+		return 0
+	}
+	return end + 1
+}
+
+// FrontmatterField returns the value of the top-level key in the YAML
+// frontmatter of source. Quoted scalars are unquoted and a trailing comment
+// is dropped; other values, such as flow sequences, are returned as written
+// on the key's line. ok is false when the key is absent or null.
+func FrontmatterField(source []byte, key string) (value string, ok bool) {
+	lines, end, found := frontmatter(source)
+	if !found {
+		return "", false
+	}
+	start, _ := fieldRange(lines, end, key)
+	if start < 0 {
+		return "", false
+	}
+	value = strings.TrimSpace(string(lines[start][len(key)+1:]))
+	switch {
+	case strings.HasPrefix(value, `"`):
+		s, err := strconv.QuotedPrefix(value)
+		if err != nil {
+			return "", false
+		}
+		value, err = strconv.Unquote(s)
+		return value, err == nil
+	case strings.HasPrefix(value, "'"):
+		i := strings.LastIndexByte(value, '\'')
+		if i == 0 {
+			return "", false
+		}
+		return strings.ReplaceAll(value[1:i], "''", "'"), true
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	switch value {
+	case "", "~", "null":
+		return "", false
+	}
+	return value, true
+}
+
+// SetFrontmatterField sets the top-level key in the YAML frontmatter of
+// source to value and returns the updated source. An existing field is
+// replaced in place; a new one is appended to the block, and a block is
+// created when source has none. Other fields, comments and the body are left
+// byte-for-byte unchanged.
+//
+// value may be a string, bool, integer, float, nil, or a []string or []any of
+// strings, which is written as a flow sequence such as [a, b].
+func SetFrontmatterField(source []byte, key string, value any) ([]byte, error) {
+	if !validFrontmatterKey(key) {
+		return nil, ErrInvalidFrontmatterKey
+	}
+	scalar, err := yamlValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, end, ok := frontmatter(source)
+	newline := "\n"
+	if len(lines) > 0 && bytes.HasSuffix(lines[0], []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	field := []byte(key + ": " + scalar + newline)
+
+	if !ok {
+		var out bytes.Buffer
+		out.WriteString("---" + newline)
+		out.Write(field)
+		out.WriteString("---" + newline)
+		out.Write(source)
+		return out.Bytes(), nil
+	}
+
+	start, stop := fieldRange(lines, end, key)
+	if start < 0 {
+		start, stop = end, end
+	}
+	var out bytes.Buffer
+	for _, line := range lines[:start] {
+		out.Write(line)
+	}
+	out.Write(field)
+	for _, line := range lines[stop:] {
+		out.Write(line)
+	}
+	return out.Bytes(), nil
+}
+
+// RemoveFrontmatterField deletes the top-level key from the YAML frontmatter
+// of source. The block is dropped entirely once it has no fields left.
+// Source without the key is returned unchanged.
+func RemoveFrontmatterField(source []byte, key string) ([]byte, error) {
+	if !validFrontmatterKey(key) {
+		return nil, ErrInvalidFrontmatterKey
+	}
+	lines, end, ok := frontmatter(source)
+	if !ok {
+		return source, nil
+	}
+	start, stop := fieldRange(lines, end, key)
+	if start < 0 {
+		return source, nil
+	}
+
+	remaining := append(append([][]byte{}, lines[1:start]...), lines[stop:end]...)
+	empty := true
+	for _, line := range remaining {
+		if len(bytes.TrimSpace(line)) > 0 {
+			empty = false
+			break
+		}
+	}
+
+	var out bytes.Buffer
+	if !empty {
+		out.Write(lines[0])
+		for _, line := range remaining {
+			out.Write(line)
+		}
+		out.Write(lines[end])
+	}
+	for _, line := range lines[end+1:] {
+		out.Write(line)
+	}
+	return out.Bytes(), nil
+}
+
+func validFrontmatterKey(key string) bool {
+	if key == "" || strings.ContainsAny(key, ":\r\n\t#") {
+		return false
+	}
+	switch key[0] {
+	case ' ', '-', '?', '[', '{', '&', '*', '!', '|', '>', '\'', '"', '%', '@', '`':
+		return false
+	}
+	return key[len(key)-1] != ' '
+}
+
+// yamlValue formats value as a single-line YAML value.
+func yamlValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return yamlString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case []string:
+		items := make([]string, len(v))
+		for i, s := range v {
+			items[i] = yamlString(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("%w: list item %T", ErrUnsupportedFrontmatterValue, item)
+			}
+			items[i] = yamlString(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnsupportedFrontmatterValue, value)
+}
+
+// yamlString writes s plain when YAML would read it back as the same string,
+// and double-quoted otherwise.
+func yamlString(s string) string {
+	if plainScalar.MatchString(s) && !yamlKeywords[strings.ToLower(s)] && strings.TrimSpace(s) == s {
+		return s
+	}
+	return strconv.Quote(s)
+}
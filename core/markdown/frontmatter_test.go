@@ -0,0 +1,170 @@
+package markdown
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetFrontmatterField(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		key    string
+		value  any
+		want   string
+	}{
+		{
+			"creates block",
+			"# Note\n",
+			"title", "Note",
+			"---\ntitle: Note\n---\n# Note\n",
+		},
+		{
+			"replaces in place",
+			"---\ntitle: Old  # keep?\nauthor: me\n---\nbody\n",
+			"title", "New",
+			"---\ntitle: New\nauthor: me\n---\nbody\n",
+		},
+		{
+			"appends",
+			"---\n# comment\ntitle: T\n\n---\nbody",
+			"draft", true,
+			"---\n# comment\ntitle: T\n\ndraft: true\n---\nbody",
+		},
+		{
+			"replaces block sequence",
+			"---\ntags:\n  - a\n- b\n\nauthor: me\n---\n",
+			"tags", []string{"go", "wasm notes"},
+			"---\ntags: [go, wasm notes]\n\nauthor: me\n---\n",
+		},
+		{
+			"matches whole key",
+			"---\ntitles: x\n---\n",
+			"title", "y",
+			"---\ntitles: x\ntitle: y\n---\n",
+		},
+		{
+			"quotes ambiguous strings",
+			"---\n---\n",
+			"v", []any{"yes", "1.0", "a: b", ""},
+			"---\nv: [\"yes\", \"1.0\", \"a: b\", \"\"]\n---\n",
+		},
+		{
+			"keeps CRLF",
+			"---\r\na: 1\r\n---\r\nbody\r\n",
+			"b", 2,
+			"---\r\na: 1\r\nb: 2\r\n---\r\nbody\r\n",
+		},
+		{
+			"unterminated block is body",
+			"---\ntext\n",
+			"a", nil,
+			"---\na: null\n---\n---\ntext\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SetFrontmatterField([]byte(tt.source), tt.key, tt.value)
+			if err != nil {
+				t.Fatalf("SetFrontmatterField error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("SetFrontmatterField =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetFrontmatterField_Errors(t *testing.T) {
+	if _, err := SetFrontmatterField(nil, "a: b", "x"); !errors.Is(err, ErrInvalidFrontmatterKey) {
+		t.Errorf("invalid key error = %v, want ErrInvalidFrontmatterKey", err)
+	}
+	if _, err := SetFrontmatterField(nil, "a", map[string]string{}); !errors.Is(err, ErrUnsupportedFrontmatterValue) {
+		t.Errorf("map value error = %v, want ErrUnsupportedFrontmatterValue", err)
+	}
+	if _, err := SetFrontmatterField(nil, "a", []any{1}); !errors.Is(err, ErrUnsupportedFrontmatterValue) {
+		t.Errorf("non-string list error = %v, want ErrUnsupportedFrontmatterValue", err)
+	}
+}
+
+func TestRemoveFrontmatterField(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		key    string
+		want   string
+	}{
+		{
+			"removes field",
+			"---\ntitle: T\ntags:\n  - a\nauthor: me\n---\nbody\n",
+			"tags",
+			"---\ntitle: T\nauthor: me\n---\nbody\n",
+		},
+		{
+			"drops empty block",
+			"---\ntitle: T\n---\n# Body\n",
+			"title",
+			"# Body\n",
+		},
+		{
+			"missing key",
+			"---\ntitle: T\n---\n",
+			"tags",
+			"---\ntitle: T\n---\n",
+		},
+		{
+			"no frontmatter",
+			"title: T\n",
+			"title",
+			"title: T\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemoveFrontmatterField([]byte(tt.source), tt.key)
+			if err != nil {
+				t.Fatalf("RemoveFrontmatterField error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("RemoveFrontmatterField =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrontmatterField(t *testing.T) {
+	source := []byte("---\nstatus: \"In progress\" # moved Monday\nowner: 'Jun''s'\norder: 2.5\ntags: [a, b]\ndue: ~\n---\nstatus: body\n")
+	tests := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{"status", "In progress", true},
+		{"owner", "Jun's", true},
+		{"order", "2.5", true},
+		{"tags", "[a, b]", true},
+		{"due", "", false},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := FrontmatterField(source, tt.key); got != tt.want || ok != tt.wantOK {
+			t.Errorf("FrontmatterField(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+		}
+	}
+	if _, ok := FrontmatterField([]byte("status: Todo\n"), "status"); ok {
+		t.Error("FrontmatterField read a field outside frontmatter")
+	}
+}
+
+func TestFrontmatterLines(t *testing.T) {
+	for source, want := range map[string]int{
+		"---\ntitle: x\n---\nbody\n":     3,
+		"---\r\na: 1\r\nb: 2\r\n...\r\n": 4,
+		"# No frontmatter\n":             0,
+		"---\nunterminated\n":            0,
+	} {
+		if got := FrontmatterLines([]byte(source)); got != want {
+			t.Errorf("FrontmatterLines(%q) = %d, want %d", source, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package markdown
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// AttachmentScheme marks an image reference as an attachment ID, as in
+// ![diagram](attachment:1a2b3c).
+const AttachmentScheme = "attachment:"
+
+// ImageResolver maps an image reference stored alongside a note to the URL
+// the browser should load, such as a backend attachment URL or a signed
+// Drive link. ref has any "./" or "attachment:" prefix removed. Returning ""
+// leaves the image unchanged.
+type ImageResolver func(ref string) string
+
+// WithImageResolver rewrites relative image references and attachment IDs
+// with resolve. Absolute and root-relative URLs are left alone.
+func WithImageResolver(resolve ImageResolver) Option {
+	return func(o *options) {
+		o.imageResolver = resolve
+	}
+}
+
+// AttachmentURL returns an ImageResolver that substitutes the escaped
+// reference for {ref} in template, e.g. "/api/attachments/{ref}".
+func AttachmentURL(template string) ImageResolver {
+	return func(ref string) string {
+		return strings.ReplaceAll(template, "{ref}", url.PathEscape(ref))
+	}
+}
+
+// imageRef returns the attachment reference in dest, or false if dest is an
+// absolute, root-relative or fragment URL.
+func imageRef(dest string) (string, bool) {
+	if ref, ok := strings.CutPrefix(dest, AttachmentScheme); ok {
+		return ref, ref != ""
+	}
+	if dest == "" || dest[0] == '/' || dest[0] == '#' {
+		return "", false
+	}
+	if u, err := url.Parse(dest); err != nil || u.IsAbs() {
+		return "", false
+	}
+	ref := strings.TrimPrefix(dest, "./")
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	return ref, true
+}
+
+type imageTransformer struct {
+	resolve ImageResolver
+}
+
+func (t *imageTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if img, ok := n.(*ast.Image); ok && entering {
+			if ref, ok := imageRef(string(img.Destination)); ok {
+				if resolved := t.resolve(ref); resolved != "" {
+					img.Destination = []byte(resolved)
+				}
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+type imageExtension struct {
+	resolve ImageResolver
+}
+
+func (e *imageExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		// Ahead of the base URL transformer (100), which then leaves the
+		// resolved absolute URLs alone.
+		parser.WithASTTransformers(util.Prioritized(&imageTransformer{resolve: e.resolve}, 99)),
+	)
+}
@@ -0,0 +1,55 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWithImageResolver(t *testing.T) {
+	renderer := NewRenderer(WithImageResolver(AttachmentURL("/api/attachments/{ref}")))
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Relative file", "![a](./img.png)", `src="/api/attachments/img.png"`},
+		{"Bare file with space", "![a](<my pic.png>)", `src="/api/attachments/my%20pic.png"`},
+		{"Attachment ID", "![a](attachment:1a2b3c)", `src="/api/attachments/1a2b3c"`},
+		{"Absolute URL untouched", "![a](https://example.com/x.png)", `src="https://example.com/x.png"`},
+		{"Root-relative untouched", "![a](/static/x.png)", `src="/static/x.png"`},
+		{"Links untouched", "[a](./doc.md)", `href="./doc.md"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := renderer.Render([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if !strings.Contains(string(output), tt.expected) {
+				t.Errorf("Render() = %q, want substring %q", output, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithImageResolver_SignedLinksAndBaseURL(t *testing.T) {
+	signed := map[string]string{"chart.png": "https://drive.example.com/file?id=9&sig=abc"}
+	renderer := NewRenderer(
+		WithImageResolver(func(ref string) string { return signed[ref] }),
+		WithBaseURL("https://app.example.com/notes/"),
+	)
+
+	output, err := renderer.Render([]byte("![c](chart.png) ![d](missing.png)"))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	got := string(output)
+	if !strings.Contains(got, `src="https://drive.example.com/file?id=9&amp;sig=abc"`) {
+		t.Errorf("Render() = %q, want signed link", got)
+	}
+	// Unresolved references still get the base URL.
+	if !strings.Contains(got, `src="https://app.example.com/notes/missing.png"`) {
+		t.Errorf("Render() = %q, want unresolved image resolved against base URL", got)
+	}
+}
@@ -0,0 +1,150 @@
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+)
+
+// IncrementalRenderer renders a document block by block and caches the HTML
+// of each top-level block, so re-rendering a large note after a small edit
+// only converts the blocks that changed. It is not safe for concurrent use.
+type IncrementalRenderer struct {
+	r     *Renderer
+	cache map[[sha256.Size]byte]renderedBlock
+}
+
+type renderedBlock struct {
+	html []byte
+	// headingIDs are the IDs the block generated and priorIDs the IDs that
+	// existed before it. A block with headings is only reused when priorIDs
+	// still match, since duplicates are numbered across the whole document.
+	headingIDs []string
+	priorIDs   string
+}
+
+// NewIncrementalRenderer wraps r with a block cache.
+func NewIncrementalRenderer(r *Renderer) *IncrementalRenderer {
+	return &IncrementalRenderer{r: r, cache: map[[sha256.Size]byte]renderedBlock{}}
+}
+
+// wholeDocumentPattern matches constructs that link blocks together:
+// reference definitions, footnotes and HTML comments that may span blank
+// lines. Documents using them are rendered in one pass.
+var wholeDocumentPattern = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:|<!--`)
+
+// Render converts Markdown to HTML, producing the same output as
+// Renderer.Render.
+func (ir *IncrementalRenderer) Render(source []byte) ([]byte, error) {
+	if wholeDocumentPattern.Match(source) {
+		return ir.r.Render(source)
+	}
+
+	ids := &recordingIDs{IDs: NewSlugger(ir.r.unicodeSlugs)}
+	var seen []string
+	cache := make(map[[sha256.Size]byte]renderedBlock, len(ir.cache))
+
+	var out bytes.Buffer
+	for _, block := range splitBlocks(source) {
+		key := sha256.Sum256(block)
+		prior := strings.Join(seen, "\x00")
+
+		cached, ok := ir.cache[key]
+		if !ok || (len(cached.headingIDs) > 0 && cached.priorIDs != prior) {
+			ids.generated = nil
+			var buf bytes.Buffer
+			pc := parser.NewContext(parser.WithIDs(ids))
+			if err := ir.r.md.Convert(block, &buf, parser.WithContext(pc)); err != nil {
+				return nil, err
+			}
+			cached = renderedBlock{html: buf.Bytes(), headingIDs: ids.generated, priorIDs: prior}
+		} else {
+			for _, id := range cached.headingIDs {
+				ids.Put([]byte(id))
+			}
+		}
+
+		cache[key] = cached
+		seen = append(seen, cached.headingIDs...)
+		out.Write(cached.html)
+	}
+
+	ir.cache = cache
+	return out.Bytes(), nil
+}
+
+// recordingIDs remembers the IDs generated since generated was last reset.
+type recordingIDs struct {
+	parser.IDs
+	generated []string
+}
+
+func (r *recordingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	id := r.IDs.Generate(value, kind)
+	r.generated = append(r.generated, string(id))
+	return id
+}
+
+var (
+	blockFencePattern = regexp.MustCompile("^ {0,3}(`{3,}|~{3,}|\\$\\$)")
+	listItemPattern   = regexp.MustCompile(`^([-*+]|\d{1,9}[.)])([ \t]|$)`)
+)
+
+// splitBlocks splits source at blank lines that separate top-level blocks.
+// It never splits inside fenced code or math, before indented continuation
+// lines, or between the items of a loose list.
+func splitBlocks(source []byte) [][]byte {
+	var blocks [][]byte
+	start := 0
+	fence := ""
+	blank := false
+	lastWasList := false
+
+	for offset := 0; offset < len(source); {
+		end := bytes.IndexByte(source[offset:], '\n')
+		if end < 0 {
+			end = len(source)
+		} else {
+			end += offset + 1
+		}
+		line := source[offset:end]
+		trimmed := bytes.TrimRight(line, "\r\n")
+
+		switch {
+		case fence != "":
+			if m := blockFencePattern.FindSubmatch(trimmed); m != nil && strings.HasPrefix(string(m[1]), fence) {
+				fence = ""
+			}
+		case len(bytes.TrimSpace(trimmed)) == 0:
+			blank = true
+		default:
+			topLevel := trimmed[0] != ' ' && trimmed[0] != '\t'
+			isList := listItemPattern.Match(trimmed)
+			if blank && topLevel && offset > start && !(lastWasList && isList) {
+				blocks = append(blocks, source[start:offset])
+				start = offset
+			}
+			if topLevel {
+				lastWasList = isList
+			}
+			if m := blockFencePattern.FindSubmatch(trimmed); m != nil {
+				fence = string(m[1])
+				// $$ x $$ on one line opens and closes the block.
+				rest := bytes.TrimSpace(trimmed[len(m[0]):])
+				if fence == "$$" && len(rest) >= 2 && bytes.HasSuffix(rest, []byte("$$")) {
+					fence = ""
+				}
+			}
+			blank = false
+		}
+		offset = end
+	}
+	if start < len(source) {
+		blocks = append(blocks, source[start:])
+	}
+	return blocks
+}
@@ -0,0 +1,91 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+const incrementalDoc = "# Intro\n\nFirst paragraph with **bold**.\n\n" +
+	"- loose item\n\n- second item\n\n  continued\n\n" +
+	"```go\nfunc main() {\n\n}\n```\n\n" +
+	"$$\nx^2\n\n$$\n\n$$ y $$\n\n" +
+	"## Intro\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n" +
+	"> quote\n\n    indented code\n\n# Intro\n"
+
+func TestIncrementalRenderer_MatchesRender(t *testing.T) {
+	renderer := NewRenderer()
+	incremental := NewIncrementalRenderer(renderer)
+
+	docs := []string{
+		incrementalDoc,
+		strings.Replace(incrementalDoc, "First paragraph", "Edited paragraph", 1),
+		strings.Replace(incrementalDoc, "## Intro", "## Renamed", 1),
+		incrementalDoc + "\nSee [ref].\n\n[ref]: https://example.com\n",
+		"",
+	}
+	for i, doc := range docs {
+		want, err := renderer.Render([]byte(doc))
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		got, err := incremental.Render([]byte(doc))
+		if err != nil {
+			t.Fatalf("IncrementalRenderer.Render() error = %v", err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("doc %d: IncrementalRenderer.Render() =\n%s\nwant\n%s", i, got, want)
+		}
+	}
+}
+
+func TestIncrementalRenderer_ReusesUnchangedBlocks(t *testing.T) {
+	incremental := NewIncrementalRenderer(NewRenderer())
+	if _, err := incremental.Render([]byte(incrementalDoc)); err != nil {
+		t.Fatal(err)
+	}
+	before := map[string]*byte{}
+	for _, block := range incremental.cache {
+		if len(block.html) > 0 {
+			before[string(block.html)] = &block.html[0]
+		}
+	}
+
+	edited := strings.Replace(incrementalDoc, "> quote", "> changed quote", 1)
+	if _, err := incremental.Render([]byte(edited)); err != nil {
+		t.Fatal(err)
+	}
+
+	reused := 0
+	for _, block := range incremental.cache {
+		if ptr, ok := before[string(block.html)]; ok && len(block.html) > 0 && ptr == &block.html[0] {
+			reused++
+		}
+	}
+	if want := len(splitBlocks([]byte(edited))) - 1; reused != want {
+		t.Errorf("reused %d blocks, want %d", reused, want)
+	}
+}
+
+func TestSplitBlocks(t *testing.T) {
+	blocks := splitBlocks([]byte(incrementalDoc))
+	want := []string{
+		"# Intro\n\n",
+		"First paragraph with **bold**.\n\n",
+		"- loose item\n\n- second item\n\n  continued\n\n",
+		"```go\nfunc main() {\n\n}\n```\n\n",
+		"$$\nx^2\n\n$$\n\n",
+		"$$ y $$\n\n",
+		"## Intro\n\n",
+		"| a | b |\n|---|---|\n| 1 | 2 |\n\n",
+		"> quote\n\n    indented code\n\n",
+		"# Intro\n",
+	}
+	if len(blocks) != len(want) {
+		t.Fatalf("splitBlocks() returned %d blocks, want %d: %q", len(blocks), len(want), blocks)
+	}
+	for i := range want {
+		if string(blocks[i]) != want[i] {
+			t.Errorf("block %d = %q, want %q", i, blocks[i], want[i])
+		}
+	}
+}
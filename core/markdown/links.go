@@ -0,0 +1,99 @@
+package markdown
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// LinkKind says how an outbound link was written.
+type LinkKind string
+
+const (
+	LinkWiki     LinkKind = "wiki"     // [[Target]] or [[Target|alias]]
+	LinkMarkdown LinkKind = "markdown" // [text](url)
+	LinkURL      LinkKind = "url"      // bare or <angle-bracketed> URLs
+)
+
+// Link is an outbound link found in a note.
+type Link struct {
+	Kind   LinkKind `json:"kind"`
+	Target string   `json:"target"`
+	Text   string   `json:"text"`
+	// Offset is the byte offset of the link in the source and Line its
+	// 1-based line number.
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+}
+
+// ExtractLinks returns every outbound link in source, in document order.
+// Images are not links and are skipped.
+func (r *Renderer) ExtractLinks(source []byte) []Link {
+	doc := r.parse(source)
+
+	links := []Link{}
+	// cursor trails the walk through source so links without a text child
+	// can be located by searching forward from it.
+	cursor := 0
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+			cursor = n.Lines().At(0).Start
+		}
+
+		switch n := n.(type) {
+		case *ast.Text:
+			cursor = n.Segment.Stop
+		case *ast.Image:
+			return ast.WalkSkipChildren, nil
+		case *WikiLink:
+			links = append(links, Link{Kind: LinkWiki, Target: n.Target, Text: n.Label(), Offset: n.offset})
+		case *ast.Link:
+			offset := indexFrom(source, cursor, []byte("["))
+			if first, ok := n.FirstChild().(*ast.Text); ok {
+				offset = first.Segment.Start - 1
+			}
+			links = append(links, Link{
+				Kind:   LinkMarkdown,
+				Target: string(n.Destination),
+				Text:   inlineText(n, source),
+				Offset: offset,
+			})
+		case *ast.AutoLink:
+			label := n.Label(source)
+			target := string(n.URL(source))
+			if n.AutoLinkType == ast.AutoLinkEmail && !bytes.HasPrefix(n.URL(source), []byte("mailto:")) {
+				target = "mailto:" + target
+			}
+			offset := indexFrom(source, cursor, label)
+			if offset >= 0 {
+				cursor = offset + len(label)
+			}
+			links = append(links, Link{Kind: LinkURL, Target: target, Text: string(label), Offset: offset})
+		}
+		return ast.WalkContinue, nil
+	})
+
+	lineStarts := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	for i := range links {
+		links[i].Line = sort.Search(len(lineStarts), func(j int) bool { return lineStarts[j] > links[i].Offset })
+	}
+	return links
+}
+
+// indexFrom returns the offset of the first sep at or after from, or from
+// itself if sep is not found.
+func indexFrom(source []byte, from int, sep []byte) int {
+	if i := bytes.Index(source[from:], sep); i >= 0 {
+		return from + i
+	}
+	return from
+}
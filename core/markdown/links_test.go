@@ -0,0 +1,39 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	source := "# Links\n\nSee [[Plan|the plan]] and [docs](https://example.com/docs).\nVisit https://go.dev or <https://pkg.go.dev>.\n\n![img](pic.png) [**bold** link](/x)\n"
+
+	got := NewRenderer().ExtractLinks([]byte(source))
+	want := []Link{
+		{Kind: LinkWiki, Target: "Plan", Text: "the plan", Offset: 13, Line: 3},
+		{Kind: LinkMarkdown, Target: "https://example.com/docs", Text: "docs", Offset: 35, Line: 3},
+		{Kind: LinkURL, Target: "https://go.dev", Text: "https://go.dev", Offset: 75, Line: 4},
+		{Kind: LinkURL, Target: "https://pkg.go.dev", Text: "https://pkg.go.dev", Offset: 94, Line: 4},
+		{Kind: LinkMarkdown, Target: "/x", Text: "bold link", Offset: 132, Line: 6},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExtractLinks() =\n%+v\nwant\n%+v", got, want)
+	}
+	for _, link := range got {
+		prefix := "["
+		if link.Kind == LinkURL {
+			prefix = link.Text
+		}
+		if !strings.HasPrefix(source[link.Offset:], prefix) {
+			t.Errorf("Offset %d for %q does not point at %q", link.Offset, link.Target, prefix)
+		}
+	}
+}
+
+func TestExtractLinks_Email(t *testing.T) {
+	got := NewRenderer().ExtractLinks([]byte("Mail <me@example.com>"))
+	if len(got) != 1 || got[0].Target != "mailto:me@example.com" || got[0].Kind != LinkURL {
+		t.Errorf("ExtractLinks() = %+v, want one mailto link", got)
+	}
+}
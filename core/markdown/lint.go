@@ -0,0 +1,150 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// LintRule identifies the check that reported a LintIssue.
+type LintRule string
+
+const (
+	LintBrokenLink       LintRule = "broken-link"       // [text]( without a valid destination
+	LintHeadingIncrement LintRule = "heading-increment" // heading more than one level below the previous
+	LintUnclosedFence    LintRule = "unclosed-fence"    // code fence running to the end of the note
+	LintLineLength       LintRule = "line-length"       // line wider than MaxLineLength
+	LintMermaid          LintRule = "mermaid"           // mermaid diagram that will not render; see ValidateMermaid
+)
+
+// MaxLineLength is the widest line Lint accepts, in monospace columns.
+const MaxLineLength = 120
+
+// LintIssue is a problem found in a note.
+type LintIssue struct {
+	Rule    LintRule `json:"rule"`
+	Message string   `json:"message"`
+	// Offset is the byte offset of the issue in the source. Line and Column
+	// are 1-based; Column counts characters.
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Lint checks source for common Markdown mistakes and returns the issues in
+// source order.
+func Lint(source []byte) []LintIssue {
+	issues := lintLines(source)
+	issues = append(issues, lintTree(source)...)
+
+	locateIssues(source, issues)
+	return issues
+}
+
+// locateIssues sorts issues by offset and fills in their line and column.
+func locateIssues(source []byte, issues []LintIssue) {
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Offset < issues[j].Offset })
+
+	lineStarts := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	for i := range issues {
+		line := sort.Search(len(lineStarts), func(j int) bool { return lineStarts[j] > issues[i].Offset })
+		issues[i].Line = line
+		issues[i].Column = utf8.RuneCount(source[lineStarts[line-1]:issues[i].Offset]) + 1
+	}
+}
+
+// lintLines runs the checks that work line by line: unclosed fences and
+// long lines.
+func lintLines(source []byte) []LintIssue {
+	issues := []LintIssue{}
+
+	var fence string
+	fenceOffset := 0
+	offset := 0
+	for _, raw := range bytes.SplitAfter(source, []byte("\n")) {
+		line := strings.TrimRight(string(raw), "\r\n")
+		start := offset
+		offset += len(raw)
+
+		if fence != "" {
+			if m := fencePattern.FindStringSubmatch(line); m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) &&
+				strings.TrimSpace(line[len(m[0]):]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			fence, fenceOffset = m[1], start
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		// Table rows and unbreakable tokens such as URLs cannot be wrapped.
+		if strings.HasPrefix(trimmed, "|") || !strings.ContainsAny(trimmed, " \t") {
+			continue
+		}
+		if width := displayWidth(line); width > MaxLineLength {
+			issues = append(issues, LintIssue{
+				Rule:    LintLineLength,
+				Message: fmt.Sprintf("Line is %d columns long (max %d)", width, MaxLineLength),
+				Offset:  start,
+			})
+		}
+	}
+	if fence != "" {
+		issues = append(issues, LintIssue{
+			Rule:    LintUnclosedFence,
+			Message: fmt.Sprintf("Code fence %s is never closed", fence),
+			Offset:  fenceOffset,
+		})
+	}
+	return issues
+}
+
+// lintTree runs the checks that need the parsed document: heading levels and
+// link syntax that did not parse as a link.
+func lintTree(source []byte) []LintIssue {
+	doc := statsParser.Parse(text.NewReader(source))
+
+	issues := []LintIssue{}
+	prevLevel := 0
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.CodeSpan, *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Heading:
+			if prevLevel > 0 && n.Level > prevLevel+1 && n.Lines().Len() > 0 {
+				issues = append(issues, LintIssue{
+					Rule:    LintHeadingIncrement,
+					Message: fmt.Sprintf("Heading level %d follows level %d", n.Level, prevLevel),
+					Offset:  bytes.LastIndexByte(source[:n.Lines().At(0).Start], '\n') + 1,
+				})
+			}
+			prevLevel = n.Level
+		case *ast.Text:
+			// A parsed link never leaves "](" behind in its text.
+			if i := bytes.Index(n.Segment.Value(source), []byte("](")); i >= 0 {
+				issues = append(issues, LintIssue{
+					Rule:    LintBrokenLink,
+					Message: "Link syntax is not closed or has an invalid destination",
+					Offset:  n.Segment.Start + i,
+				})
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return issues
+}
@@ -0,0 +1,46 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	long := strings.Repeat("word ", 30)
+	source := "# Title\n\n### Skipped\n\nSee [docs](https://example.com and `[a](b` here.\n\n" +
+		long + "\n" + "| " + long + " |\n\n## Ok\n\n```go\n" + long + "\n"
+
+	got := Lint([]byte(source))
+	want := []struct {
+		rule   LintRule
+		line   int
+		column int
+	}{
+		{LintHeadingIncrement, 3, 1},
+		{LintBrokenLink, 5, 10},
+		{LintLineLength, 7, 1},
+		{LintUnclosedFence, 12, 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Lint returned %d issues, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Rule != w.rule || got[i].Line != w.line || got[i].Column != w.column {
+			t.Errorf("issue %d = %s at %d:%d, want %s at %d:%d",
+				i, got[i].Rule, got[i].Line, got[i].Column, w.rule, w.line, w.column)
+		}
+	}
+}
+
+func TestLint_NoFalsePositives(t *testing.T) {
+	source := "# Title\n\n## Section\n\nA [link](https://example.com) and [[Wiki]].\n\n~~~\n## not a heading\n~~~\n\n#### Deep\n"
+	// "#### Deep" follows "## Section" and is reported; everything else is clean.
+	got := Lint([]byte(source))
+	if len(got) != 1 || got[0].Rule != LintHeadingIncrement || got[0].Line != 11 {
+		t.Errorf("Lint = %+v, want one heading-increment issue on line 11", got)
+	}
+
+	if got := Lint([]byte("日本語の文章 " + strings.Repeat("あ", 50))); len(got) != 0 {
+		t.Errorf("Lint(short CJK line) = %+v, want none", got)
+	}
+}
@@ -0,0 +1,168 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Math nodes are rendered with \( \) and \[ \] delimiters inside elements
+// classed "math", which both KaTeX auto-render and MathJax pick up.
+
+// KindMathInline is the node kind for inline $...$ math.
+var KindMathInline = ast.NewNodeKind("MathInline")
+
+// KindMathBlock is the node kind for $$...$$ display math.
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// MathInline is an inline formula.
+type MathInline struct {
+	ast.BaseInline
+	Formula []byte
+}
+
+func (n *MathInline) Kind() ast.NodeKind { return KindMathInline }
+
+func (n *MathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Formula": string(n.Formula)}, nil)
+}
+
+// MathBlock is a display formula. Its lines hold the formula source.
+type MathBlock struct {
+	ast.BaseBlock
+
+	// singleLine is set for the $$ x $$ form, which closes on its own line.
+	singleLine bool
+}
+
+func (n *MathBlock) Kind() ast.NodeKind { return KindMathBlock }
+
+func (n *MathBlock) IsRaw() bool { return true }
+
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte { return []byte{'$'} }
+
+// Parse follows Pandoc's rule: the opening $ must not be followed by a space,
+// and the closing $ must not be preceded by a space or followed by a digit,
+// so prices like "$5 and $10" stay literal.
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if len(line) < 3 || line[1] == '$' || util.IsSpace(line[1]) {
+		return nil
+	}
+	for i := 2; i < len(line); i++ {
+		if line[i] != '$' || line[i-1] == '\\' || util.IsSpace(line[i-1]) {
+			continue
+		}
+		if i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9' {
+			continue
+		}
+		node := &MathInline{Formula: append([]byte(nil), line[1:i]...)}
+		block.Advance(i + 1)
+		return node
+	}
+	return nil
+}
+
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte { return []byte{'$'} }
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+
+	node := &MathBlock{}
+	rest := util.TrimRightSpace(line[pos+2:])
+	// Single-line form: $$ x^2 $$
+	if len(rest) >= 2 && bytes.HasSuffix(rest, []byte("$$")) {
+		start := segment.Start + pos + 2
+		node.Lines().Append(text.NewSegment(start, start+len(rest)-2))
+		node.singleLine = true
+		reader.AdvanceToEOL()
+		return node, parser.NoChildren
+	}
+	if len(util.TrimLeftSpace(rest)) > 0 {
+		start := segment.Start + pos + 2
+		node.Lines().Append(text.NewSegment(start, start+len(rest)))
+	}
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if line == nil || node.(*MathBlock).singleLine {
+		return parser.Close
+	}
+	trimmed := util.TrimLeftSpace(line)
+	if bytes.HasPrefix(trimmed, []byte("$$")) {
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool { return true }
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool { return false }
+
+type mathHTMLRenderer struct{}
+
+func (r *mathHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMathInline, r.renderInline)
+	reg.Register(KindMathBlock, r.renderBlock)
+}
+
+func (r *mathHTMLRenderer) renderInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(`<span class="math inline">\(`)
+		_, _ = w.Write(util.EscapeHTML(node.(*MathInline).Formula))
+		_, _ = w.WriteString(`\)</span>`)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathHTMLRenderer) renderBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString(`<div class="math display">\[`)
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		_, _ = w.Write(util.EscapeHTML(seg.Value(source)))
+	}
+	_, _ = w.WriteString("\\]</div>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+// mathExtension adds $...$ and $$...$$ math.
+type mathExtension struct{}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 501)),
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 701)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&mathHTMLRenderer{}, 500)),
+	)
+}
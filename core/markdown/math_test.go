@@ -0,0 +1,74 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Inline math",
+			input:    "Euler: $e^{i\\pi} + 1 = 0$.",
+			expected: `<p>Euler: <span class="math inline">\(e^{i\pi} + 1 = 0\)</span>.</p>`,
+		},
+		{
+			name:     "Inline math escapes HTML",
+			input:    "$a<b$",
+			expected: `<span class="math inline">\(a&lt;b\)</span>`,
+		},
+		{
+			name:     "Prices are not math",
+			input:    "It costs $5 and $10.",
+			expected: "<p>It costs $5 and $10.</p>",
+		},
+		{
+			name:     "Display math block",
+			input:    "$$\n\\int_0^1 x\\,dx\n$$",
+			expected: "<div class=\"math display\">\\[\\int_0^1 x\\,dx\n\\]</div>",
+		},
+		{
+			name:     "Single-line display math",
+			input:    "$$ x^2 $$",
+			expected: "<div class=\"math display\">\\[ x^2 \\]</div>",
+		},
+		{
+			name:     "Single-line display math closes",
+			input:    "$$ x^2 $$\n\n# After",
+			expected: "\\]</div>\n<h1 id=\"after\">After</h1>",
+		},
+		{
+			name:     "Underscores inside math are not emphasis",
+			input:    "$a_1 + b_1$",
+			expected: `\(a_1 + b_1\)`,
+		},
+	}
+
+	renderer := NewRenderer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := renderer.Render([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if !strings.Contains(string(output), tt.expected) {
+				t.Errorf("Render() = %q, want substring %q", output, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMath_Disabled(t *testing.T) {
+	renderer := NewRenderer(WithMath(false))
+	output, err := renderer.Render([]byte("$x$"))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if strings.Contains(string(output), "math") {
+		t.Errorf("Render() = %q, want no math markup", output)
+	}
+}
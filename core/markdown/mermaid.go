@@ -0,0 +1,172 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// mermaidDiagrams are the diagram declarations mermaid accepts.
+var mermaidDiagrams = map[string]bool{
+	"graph": true, "flowchart": true, "sequenceDiagram": true, "classDiagram": true,
+	"stateDiagram": true, "stateDiagram-v2": true, "erDiagram": true, "journey": true,
+	"gantt": true, "pie": true, "quadrantChart": true, "requirementDiagram": true,
+	"gitGraph": true, "mindmap": true, "timeline": true, "zenuml": true,
+	"C4Context": true, "C4Container": true, "C4Component": true, "C4Dynamic": true, "C4Deployment": true,
+	"sankey-beta": true, "xychart-beta": true, "block-beta": true, "packet-beta": true,
+	"architecture-beta": true, "kanban": true,
+}
+
+// flowchartDirections are the orientations accepted after graph/flowchart.
+var flowchartDirections = map[string]bool{"TB": true, "TD": true, "BT": true, "RL": true, "LR": true}
+
+// mermaidBrackets maps each closing bracket to its opener. Diagrams not listed
+// in bracketDiagrams use brackets in arrows and cardinalities ("-)",
+// "}o--||"), so only these are checked for balance.
+var (
+	mermaidBrackets = map[byte]byte{')': '(', ']': '[', '}': '{'}
+	bracketDiagrams = map[string]bool{
+		"graph": true, "flowchart": true, "classDiagram": true,
+		"stateDiagram": true, "stateDiagram-v2": true,
+	}
+)
+
+// mermaidBlocks maps a diagram type to the keywords that open a block closed
+// by "end".
+var mermaidBlocks = map[string]map[string]bool{
+	"graph":     {"subgraph": true},
+	"flowchart": {"subgraph": true},
+	"sequenceDiagram": {
+		"loop": true, "alt": true, "opt": true, "par": true, "critical": true,
+		"break": true, "rect": true, "box": true,
+	},
+}
+
+// ValidateMermaid checks every ```mermaid fence in source for mistakes that
+// make the mermaid renderer throw: an unknown diagram type, unbalanced
+// brackets, and blocks missing their "end". It is a quick structural check,
+// not a full mermaid parser.
+func ValidateMermaid(source []byte) []LintIssue {
+	doc := statsParser.Parse(text.NewReader(source))
+
+	issues := []LintIssue{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		block, ok := n.(*ast.FencedCodeBlock)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if string(block.Language(source)) == "mermaid" {
+			issues = append(issues, validateMermaidBlock(source, block)...)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	locateIssues(source, issues)
+	return issues
+}
+
+func validateMermaidBlock(source []byte, block *ast.FencedCodeBlock) []LintIssue {
+	issue := func(offset int, format string, args ...any) LintIssue {
+		return LintIssue{Rule: LintMermaid, Message: fmt.Sprintf(format, args...), Offset: offset}
+	}
+
+	var issues []LintIssue
+	kind := ""
+	inConfig := false
+	inString := false
+	type open struct {
+		char   byte
+		offset int
+	}
+	var brackets, blocks []open
+	for i := 0; i < block.Lines().Len(); i++ {
+		seg := block.Lines().At(i)
+		line := seg.Value(source)
+		trimmed := strings.TrimSpace(string(line))
+
+		if kind == "" {
+			switch {
+			case trimmed == "---":
+				// A YAML config block may precede the declaration.
+				inConfig = !inConfig
+				continue
+			case inConfig, trimmed == "", strings.HasPrefix(trimmed, "%%"):
+				continue
+			}
+			fields := strings.Fields(trimmed)
+			kind = fields[0]
+			if !mermaidDiagrams[kind] {
+				return []LintIssue{issue(seg.Start, "Unknown mermaid diagram type %q", kind)}
+			}
+			if (kind == "graph" || kind == "flowchart") && len(fields) > 1 && !flowchartDirections[fields[1]] {
+				issues = append(issues, issue(seg.Start, "Unknown flowchart direction %q", fields[1]))
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "%%") {
+			continue
+		}
+
+		if openers := mermaidBlocks[kind]; openers != nil && !inString {
+			word, _, _ := strings.Cut(trimmed, " ")
+			switch {
+			case openers[word]:
+				blocks = append(blocks, open{offset: seg.Start})
+			case word == "end":
+				if len(blocks) == 0 {
+					issues = append(issues, issue(seg.Start, `"end" without an open block`))
+				} else {
+					blocks = blocks[:len(blocks)-1]
+				}
+			}
+		}
+
+		if !bracketDiagrams[kind] {
+			continue
+		}
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			if c == '"' {
+				inString = !inString
+			}
+			if inString {
+				continue
+			}
+			switch c {
+			case '(', '[', '{':
+				brackets = append(brackets, open{char: c, offset: seg.Start + j})
+			case '>':
+				// "id>label]" is flowchart's asymmetric node shape; every other
+				// ">" ends an arrow such as "-->" or "==>".
+				if (kind == "graph" || kind == "flowchart") && j > 0 && isWordByte(line[j-1]) {
+					brackets = append(brackets, open{char: '>', offset: seg.Start + j})
+				}
+			case ')', ']', '}':
+				top := len(brackets) - 1
+				if top >= 0 && (brackets[top].char == mermaidBrackets[c] || c == ']' && brackets[top].char == '>') {
+					brackets = brackets[:top]
+				} else {
+					issues = append(issues, issue(seg.Start+j, "Unexpected %q", c))
+				}
+			}
+		}
+	}
+
+	if kind == "" {
+		offset := bytes.LastIndexByte(source[:block.Info.Segment.Start], '\n') + 1
+		return []LintIssue{issue(offset, "Mermaid diagram is empty")}
+	}
+	for _, b := range brackets {
+		issues = append(issues, issue(b.offset, "%q is never closed", b.char))
+	}
+	for _, b := range blocks {
+		issues = append(issues, issue(b.offset, `Block is missing its "end"`))
+	}
+	return issues
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
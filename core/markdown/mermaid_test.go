@@ -0,0 +1,83 @@
+package markdown
+
+import "testing"
+
+func TestValidateMermaid(t *testing.T) {
+	tests := []struct {
+		name    string
+		diagram string
+		// want holds the message and 1-based note line of each issue.
+		want []LintIssue
+	}{
+		{
+			"valid flowchart",
+			"flowchart LR\n  A[Start] --> B{Ok?}\n  B -->|yes| C>Flag]\n  subgraph S\n    D((\"x ) y\"))\n  end\n",
+			nil,
+		},
+		{
+			"config and comments before the declaration",
+			"---\ntitle: T\n---\n%% note\nsequenceDiagram\n  loop Every minute\n    A-)B: ping\n  end\n",
+			nil,
+		},
+		{
+			"er cardinalities are not brackets",
+			"erDiagram\n  CUSTOMER ||--o{ ORDER : places\n",
+			nil,
+		},
+		{
+			"unknown type",
+			"flowchat TD\n  A --> B\n",
+			[]LintIssue{{Message: `Unknown mermaid diagram type "flowchat"`, Line: 2}},
+		},
+		{
+			"bad direction",
+			"graph XY\n",
+			[]LintIssue{{Message: `Unknown flowchart direction "XY"`, Line: 2}},
+		},
+		{
+			"unbalanced brackets",
+			"graph TD\n  A[Start --> B)\n",
+			[]LintIssue{
+				{Message: `'[' is never closed`, Line: 3},
+				{Message: `Unexpected ')'`, Line: 3},
+			},
+		},
+		{
+			"missing end",
+			"sequenceDiagram\n  alt ok\n    A->>B: hi\n  else\n    B->>A: no\n",
+			[]LintIssue{{Message: `Block is missing its "end"`, Line: 3}},
+		},
+		{
+			"stray end",
+			"flowchart TD\n  A --> B\n  end\n",
+			[]LintIssue{{Message: `"end" without an open block`, Line: 4}},
+		},
+		{
+			"empty",
+			"\n%% only a comment\n",
+			[]LintIssue{{Message: "Mermaid diagram is empty", Line: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := "```mermaid\n" + tt.diagram + "```\n"
+			got := ValidateMermaid([]byte(source))
+			if len(got) != len(tt.want) {
+				t.Fatalf("ValidateMermaid = %+v, want %d issues", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if got[i].Rule != LintMermaid || got[i].Message != w.Message || got[i].Line != w.Line {
+					t.Errorf("issue %d = %q on line %d, want %q on line %d",
+						i, got[i].Message, got[i].Line, w.Message, w.Line)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMermaid_IgnoresOtherFences(t *testing.T) {
+	source := "# Note\n\n```go\nfunc f() {\n```\n\n```mermaid\npie\n  \"a\" : 1\n```\n"
+	if got := ValidateMermaid([]byte(source)); len(got) != 0 {
+		t.Errorf("ValidateMermaid = %+v, want none", got)
+	}
+}
@@ -0,0 +1,77 @@
+package markdown
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// RenderText converts Markdown to plain text without markup, for email
+// export, search snippets and accessibility previews. Blocks are separated by
+// blank lines, list items keep a "- " or "1. " prefix, and table cells are
+// separated by tabs. Raw HTML is dropped.
+func (r *Renderer) RenderText(source []byte) []byte {
+	doc := r.parse(source)
+	out := strings.TrimSpace(blockText(doc, source))
+	if out == "" {
+		return []byte{}
+	}
+	return []byte(out + "\n")
+}
+
+// blockText returns the plain text of a block node.
+func blockText(n ast.Node, source []byte) string {
+	switch n := n.(type) {
+	case *ast.Paragraph, *ast.TextBlock, *ast.Heading:
+		var buf bytes.Buffer
+		writeInlineText(&buf, n, source, '\n')
+		return strings.TrimSpace(buf.String())
+	case *ast.FencedCodeBlock, *ast.CodeBlock, *MathBlock:
+		return strings.TrimRight(string(n.Lines().Value(source)), "\n")
+	case *ast.HTMLBlock, *ast.ThematicBreak:
+		return ""
+	case *ast.List:
+		var items []string
+		number := n.Start
+		for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := "- "
+			if n.IsOrdered() {
+				marker = strconv.Itoa(number) + ". "
+				number++
+			}
+			text := blockText(item, source)
+			items = append(items, marker+strings.ReplaceAll(text, "\n", "\n"+strings.Repeat(" ", len(marker))))
+		}
+		return strings.Join(items, "\n")
+	case *ast.ListItem:
+		return childBlocksText(n, source, "\n")
+	case *extast.Table:
+		var rows []string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, inlineText(cell, source))
+			}
+			rows = append(rows, strings.Join(cells, "\t"))
+		}
+		return strings.Join(rows, "\n")
+	case *extast.Footnote:
+		return "[" + strconv.Itoa(n.Index) + "] " + childBlocksText(n, source, "\n")
+	case *extast.FootnoteList:
+		return childBlocksText(n, source, "\n")
+	}
+	return childBlocksText(n, source, "\n\n")
+}
+
+func childBlocksText(n ast.Node, source []byte, sep string) string {
+	var parts []string
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if text := blockText(c, source); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, sep)
+}
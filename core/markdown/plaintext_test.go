@@ -0,0 +1,51 @@
+package markdown
+
+import "testing"
+
+func TestRenderer_RenderText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "Inline markup is stripped",
+			input: "# Title\n\nSome **bold**, `code` and [a link](https://example.com) :tada:\nnext line",
+			want:  "Title\n\nSome bold, code and a link 🎉\nnext line\n",
+		},
+		{
+			name:  "Lists",
+			input: "- one\n- two\n  - nested\n\n3. three\n4. [x] four",
+			want:  "- one\n- two\n  - nested\n\n3. three\n4. [x] four\n",
+		},
+		{
+			name:  "Code and HTML",
+			input: "```go\nfunc main() {}\n```\n\n<div>raw</div>\n\n---\n\n> quoted",
+			want:  "func main() {}\n\nquoted\n",
+		},
+		{
+			name:  "Table",
+			input: "| a | b |\n|---|---|\n| 1 | 2 |",
+			want:  "a\tb\n1\t2\n",
+		},
+		{
+			name:  "Footnotes",
+			input: "Claim[^1].\n\n[^1]: Source.",
+			want:  "Claim[1].\n\n[1] Source.\n",
+		},
+		{
+			name:  "Empty",
+			input: "",
+			want:  "",
+		},
+	}
+
+	renderer := NewRenderer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(renderer.RenderText([]byte(tt.input))); got != tt.want {
+				t.Errorf("RenderText() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,49 +5,244 @@ import (
 
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
 // Renderer handles Markdown rendering.
 type Renderer struct {
 	md goldmark.Markdown
+	// sanitized shares md's extensions but omits raw HTML and drops
+	// dangerous link destinations such as javascript: URLs.
+	sanitized goldmark.Markdown
+	theme     string
+	darkTheme string
+	// unicodeSlugs is passed to the Slugger each parse gets.
+	unicodeSlugs bool
+}
+
+// Option configures a Renderer.
+type Option func(*options)
+
+type options struct {
+	math          bool
+	emoji         bool
+	typographer   bool
+	hardWraps     bool
+	unsafeHTML    bool
+	headingIDs    bool
+	unicodeSlugs  bool
+	baseURL       string
+	imageResolver ImageResolver
+	extensions    []goldmark.Extender
+	transformers  []util.PrioritizedValue
+	wikiLinkURL   string
+	theme         string
+	darkTheme     string
+	inlineStyles  bool
+}
+
+// WithHardWraps renders every newline inside a paragraph as <br />. It is
+// enabled by default.
+func WithHardWraps(enabled bool) Option {
+	return func(o *options) {
+		o.hardWraps = enabled
+	}
+}
+
+// WithUnsafeHTML passes raw HTML through Render. It is enabled by default;
+// when disabled, Render behaves like RenderSanitized.
+func WithUnsafeHTML(enabled bool) Option {
+	return func(o *options) {
+		o.unsafeHTML = enabled
+	}
+}
+
+// WithHeadingIDs adds id attributes to headings. It is enabled by default.
+func WithHeadingIDs(enabled bool) Option {
+	return func(o *options) {
+		o.headingIDs = enabled
+	}
+}
+
+// WithBaseURL resolves relative link and image destinations against base,
+// for views served from a different origin than the note's own pages.
+func WithBaseURL(base string) Option {
+	return func(o *options) {
+		o.baseURL = base
+	}
+}
+
+// WithMath enables or disables $...$ and $$...$$ math. It is enabled by default.
+func WithMath(enabled bool) Option {
+	return func(o *options) {
+		o.math = enabled
+	}
+}
+
+// WithEmoji enables or disables :shortcode: emoji such as :tada:. It is
+// enabled by default.
+func WithEmoji(enabled bool) Option {
+	return func(o *options) {
+		o.emoji = enabled
+	}
+}
+
+// WithTypographer converts straight quotes to curly quotes, -- and --- to
+// en and em dashes, and ... to an ellipsis. It is disabled by default.
+func WithTypographer(enabled bool) Option {
+	return func(o *options) {
+		o.typographer = enabled
+	}
+}
+
+// WithWikiLinkURL sets the URL template for [[...]] links; {target} is
+// replaced with the escaped target. Defaults to DefaultWikiLinkURL.
+func WithWikiLinkURL(template string) Option {
+	return func(o *options) {
+		o.wikiLinkURL = template
+	}
+}
+
+// WithExtensions adds goldmark extensions after the built-in ones, so
+// deployments can add their own syntax without changing this package.
+func WithExtensions(extensions ...goldmark.Extender) Option {
+	return func(o *options) {
+		o.extensions = append(o.extensions, extensions...)
+	}
+}
+
+// WithASTTransformers adds parser AST transformers, e.g.
+// util.Prioritized(t, 500). Lower priorities run first.
+func WithASTTransformers(transformers ...util.PrioritizedValue) Option {
+	return func(o *options) {
+		o.transformers = append(o.transformers, transformers...)
+	}
+}
+
+// NewRendererWithExtensions creates a renderer with extra goldmark
+// extensions. It is shorthand for NewRenderer(WithExtensions(...), opts...).
+func NewRendererWithExtensions(extensions []goldmark.Extender, opts ...Option) *Renderer {
+	return NewRenderer(append([]Option{WithExtensions(extensions...)}, opts...)...)
 }
 
 // NewRenderer creates a new Markdown renderer with extensions.
-func NewRenderer() *Renderer {
-	md := goldmark.New(
-		goldmark.WithExtensions(
-			extension.GFM, // GitHub Flavored Markdown (Table, Strikethrough, TaskList, Autolink)
-			highlighting.NewHighlighting(
-				highlighting.WithStyle("github"),
-				highlighting.WithFormatOptions(
-					chromahtml.WithClasses(true),
-				),
-			),
-		),
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
+func NewRenderer(opts ...Option) *Renderer {
+	o := options{
+		math:         true,
+		emoji:        true,
+		hardWraps:    true,
+		unsafeHTML:   true,
+		headingIDs:   true,
+		unicodeSlugs: true,
+		wikiLinkURL:  DefaultWikiLinkURL,
+		theme:        DefaultTheme,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	extensions := []goldmark.Extender{
+		extension.GFM, // GitHub Flavored Markdown (Table, Strikethrough, TaskList, Autolink)
+		extension.NewFootnote(
+			extension.WithFootnoteBacklinkHTML("&#x21a9;&#xfe0e;"), // ↩ back-reference link
 		),
-		goldmark.WithRendererOptions(
-			html.WithHardWraps(),
-			html.WithXHTML(),
-			html.WithUnsafe(), // Allow raw HTML (needed for some Mermaid scenarios or user embedded HTML)
+		highlighting.NewHighlighting(
+			highlighting.WithStyle(o.theme),
+			highlighting.WithFormatOptions(
+				chromahtml.WithClasses(!o.inlineStyles),
+			),
 		),
+		&wikiLinkExtension{urlTemplate: o.wikiLinkURL},
+	}
+	if o.math {
+		extensions = append(extensions, &mathExtension{})
+	}
+	if o.emoji {
+		extensions = append(extensions, emoji.Emoji)
+	}
+	if o.typographer {
+		extensions = append(extensions, extension.Typographer)
+	}
+
+	if o.imageResolver != nil {
+		extensions = append(extensions, &imageExtension{resolve: o.imageResolver})
+	}
+	if o.baseURL != "" {
+		extensions = append(extensions, &baseURLExtension{base: o.baseURL})
+	}
+
+	extensions = append(extensions, o.extensions...)
+
+	var parserOptions []parser.Option
+	if o.headingIDs {
+		parserOptions = append(parserOptions, parser.WithAutoHeadingID())
+	}
+	if len(o.transformers) > 0 {
+		parserOptions = append(parserOptions, parser.WithASTTransformers(o.transformers...))
+	}
+	rendererOptions := []renderer.Option{html.WithXHTML()}
+	if o.hardWraps {
+		rendererOptions = append(rendererOptions, html.WithHardWraps())
+	}
+
+	sanitized := goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(parserOptions...),
+		goldmark.WithRendererOptions(rendererOptions...),
 	)
+	md := sanitized
+	if o.unsafeHTML {
+		md = goldmark.New(
+			goldmark.WithExtensions(extensions...),
+			goldmark.WithParserOptions(parserOptions...),
+			goldmark.WithRendererOptions(append(rendererOptions,
+				html.WithUnsafe(), // Allow raw HTML (needed for some Mermaid scenarios or user embedded HTML)
+			)...),
+		)
+	}
 
 	return &Renderer{
-		md: md,
+		md:           md,
+		sanitized:    sanitized,
+		theme:        o.theme,
+		darkTheme:    o.darkTheme,
+		unicodeSlugs: o.unicodeSlugs,
 	}
 }
 
-// Render converts Markdown to HTML.
+// Render converts Markdown to HTML. Raw HTML in the source is passed through,
+// so the output is only safe to show to the note's own author.
 func (r *Renderer) Render(source []byte) ([]byte, error) {
+	return r.convert(r.md, source)
+}
+
+// RenderSanitized converts Markdown to HTML for untrusted viewers, such as
+// shared or public pages. Raw HTML is omitted and dangerous URLs are dropped.
+func (r *Renderer) RenderSanitized(source []byte) ([]byte, error) {
+	return r.convert(r.sanitized, source)
+}
+
+func (r *Renderer) convert(md goldmark.Markdown, source []byte) ([]byte, error) {
 	var buf bytes.Buffer
-	if err := r.md.Convert(source, &buf); err != nil {
+	if err := md.Convert(source, &buf, parser.WithContext(r.newContext())); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
+
+// parse returns the AST for source with the same heading IDs Render uses.
+func (r *Renderer) parse(source []byte) ast.Node {
+	return r.md.Parser().Parse(text.NewReader(source), parser.WithContext(r.newContext()))
+}
+
+func (r *Renderer) newContext() parser.Context {
+	return parser.NewContext(parser.WithIDs(NewSlugger(r.unicodeSlugs)))
+}
@@ -51,6 +51,16 @@ func TestRenderer_Render(t *testing.T) {
 			input:    "## My Section",
 			expected: "id=\"my-section\"",
 		},
+		{
+			name:     "Footnote reference",
+			input:    "Claim[^1].\n\n[^1]: Source.",
+			expected: "<sup id=\"fnref:1\"><a href=\"#fn:1\" class=\"footnote-ref\" role=\"doc-noteref\">1</a></sup>",
+		},
+		{
+			name:     "Footnote back-reference",
+			input:    "Claim[^1].\n\n[^1]: Source.",
+			expected: "<a href=\"#fnref:1\" class=\"footnote-backref\" role=\"doc-backlink\">",
+		},
 		{
 			name:     "Raw HTML passthrough",
 			input:    "<div class=\"custom\">raw html</div>",
@@ -73,3 +83,145 @@ func TestRenderer_Render(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderer_RenderSanitized(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		contains  string
+		forbidden string
+	}{
+		{
+			name:      "Raw HTML block is omitted",
+			input:     "<script>alert(1)</script>",
+			forbidden: "<script>",
+		},
+		{
+			name:      "Inline HTML is omitted",
+			input:     "hi <img src=x onerror=alert(1)>",
+			forbidden: "onerror",
+		},
+		{
+			name:      "javascript URL is dropped",
+			input:     "[click](javascript:alert(1))",
+			forbidden: "javascript:",
+		},
+		{
+			name:     "Markdown still renders",
+			input:    "# Title\n\n**bold** [[Other]]",
+			contains: `<strong>bold</strong> <a href="/note?id=Other" class="wiki-link">Other</a>`,
+		},
+	}
+
+	renderer := NewRenderer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := renderer.RenderSanitized([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("RenderSanitized() error = %v", err)
+			}
+			if tt.forbidden != "" && strings.Contains(string(output), tt.forbidden) {
+				t.Errorf("RenderSanitized() = %q, must not contain %q", output, tt.forbidden)
+			}
+			if tt.contains != "" && !strings.Contains(string(output), tt.contains) {
+				t.Errorf("RenderSanitized() = %q, want substring %q", output, tt.contains)
+			}
+		})
+	}
+
+	// The default mode keeps passing raw HTML through.
+	output, _ := renderer.Render([]byte("<div>raw</div>"))
+	if !strings.Contains(string(output), "<div>raw</div>") {
+		t.Errorf("Render() = %q, want raw HTML preserved", output)
+	}
+}
+
+func TestRenderer_Emoji(t *testing.T) {
+	output, err := NewRenderer().Render([]byte("Shipped :tada: but not `:tada:` or :not_an_emoji:"))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	got := string(output)
+	if !strings.Contains(got, "Shipped &#x1f389;") {
+		t.Errorf("Render() = %q, want :tada: converted", got)
+	}
+	if !strings.Contains(got, "<code>:tada:</code>") || !strings.Contains(got, ":not_an_emoji:") {
+		t.Errorf("Render() = %q, want code spans and unknown shortcodes left alone", got)
+	}
+
+	output, _ = NewRenderer(WithEmoji(false)).Render([]byte("Shipped :tada:"))
+	if !strings.Contains(string(output), "Shipped :tada:") {
+		t.Errorf("Render() with WithEmoji(false) = %q, want shortcode kept", output)
+	}
+}
+
+func TestRenderer_Options(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		input    string
+		expected string
+	}{
+		{
+			name:     "Hard wraps by default",
+			input:    "a\nb",
+			expected: "<p>a<br />\nb</p>",
+		},
+		{
+			name:     "Hard wraps disabled",
+			opts:     []Option{WithHardWraps(false)},
+			input:    "a\nb",
+			expected: "<p>a\nb</p>",
+		},
+		{
+			name:     "Unsafe HTML disabled",
+			opts:     []Option{WithUnsafeHTML(false)},
+			input:    "<b>raw</b>",
+			expected: "<!-- raw HTML omitted -->raw<!-- raw HTML omitted -->",
+		},
+		{
+			name:     "Heading IDs disabled",
+			opts:     []Option{WithHeadingIDs(false)},
+			input:    "# Title",
+			expected: "<h1>Title</h1>",
+		},
+		{
+			name:     "Base URL resolves relative links and images",
+			opts:     []Option{WithBaseURL("https://drive.example.com/notes/")},
+			input:    "[a](other.md) ![i](img/pic.png) [b](/root) [c](https://x.org/y) [d](#top)",
+			expected: `<a href="https://drive.example.com/notes/other.md">a</a> <img src="https://drive.example.com/notes/img/pic.png" alt="i" /> <a href="https://drive.example.com/root">b</a> <a href="https://x.org/y">c</a> <a href="#top">d</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := NewRenderer(tt.opts...).Render([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if !strings.Contains(string(output), tt.expected) {
+				t.Errorf("Render() = %q, want substring %q", output, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderer_Typographer(t *testing.T) {
+	input := `"Quoted" -- it's done... --- really`
+
+	output, _ := NewRenderer().Render([]byte(input))
+	if !strings.Contains(string(output), "&quot;Quoted&quot; -- it's done... --- really") {
+		t.Errorf("Render() = %q, want punctuation unchanged by default", output)
+	}
+
+	output, _ = NewRenderer(WithTypographer(true)).Render([]byte(input))
+	want := "&ldquo;Quoted&rdquo; &ndash; it&rsquo;s done&hellip; &mdash; really"
+	if !strings.Contains(string(output), want) {
+		t.Errorf("Render() = %q, want %q", output, want)
+	}
+
+	output, _ = NewRenderer(WithTypographer(true)).Render([]byte("`\"code\" -- ...`"))
+	if !strings.Contains(string(output), "<code>&quot;code&quot; -- ...</code>") {
+		t.Errorf("Render() = %q, want code spans left alone", output)
+	}
+}
@@ -0,0 +1,47 @@
+package markdown
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// ErrSectionNotFound is returned when no top-level heading has the slug.
+var ErrSectionNotFound = errors.New("section not found")
+
+// GetSection returns the Markdown of the section headed by the top-level
+// heading whose ID is slug: the heading line through to the next heading of
+// the same or a higher level. IDs are the ones Render and ExtractTOC produce.
+func (r *Renderer) GetSection(source []byte, slug string) ([]byte, error) {
+	doc := r.parse(source)
+
+	start, level := -1, 0
+	end := len(source)
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		heading, ok := n.(*ast.Heading)
+		if !ok || heading.Lines().Len() == 0 {
+			continue
+		}
+		lineStart := bytes.LastIndexByte(source[:heading.Lines().At(0).Start], '\n') + 1
+
+		if start >= 0 {
+			if heading.Level <= level {
+				end = lineStart
+				break
+			}
+			continue
+		}
+		if id, ok := heading.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok && string(b) == slug {
+				start, level = lineStart, heading.Level
+			}
+		}
+	}
+	if start < 0 {
+		return nil, ErrSectionNotFound
+	}
+
+	section := bytes.TrimRight(source[start:end], "\r\n")
+	return append(section[:len(section):len(section)], '\n'), nil
+}
@@ -0,0 +1,41 @@
+package markdown
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRenderer_GetSection(t *testing.T) {
+	source := "# Notes\n\nIntro.\n\n## Setup\n\nInstall it.\n\n```\n# not a heading\n```\n\n### Details\n\nMore.\n\n## Usage\n\nRun it.\n\n> ## Quoted\n\nSetext\n------\n\nBody.\n"
+
+	tests := []struct {
+		slug string
+		want string
+	}{
+		{"setup", "## Setup\n\nInstall it.\n\n```\n# not a heading\n```\n\n### Details\n\nMore.\n"},
+		{"details", "### Details\n\nMore.\n"},
+		{"usage", "## Usage\n\nRun it.\n\n> ## Quoted\n"},
+		{"setext", "Setext\n------\n\nBody.\n"},
+		{"notes", source},
+	}
+
+	renderer := NewRenderer()
+	for _, tt := range tests {
+		got, err := renderer.GetSection([]byte(source), tt.slug)
+		if err != nil {
+			t.Fatalf("GetSection(%q) error = %v", tt.slug, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("GetSection(%q) =\n%q\nwant\n%q", tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestRenderer_GetSection_NotFound(t *testing.T) {
+	renderer := NewRenderer()
+	for _, slug := range []string{"missing", "quoted"} {
+		if _, err := renderer.GetSection([]byte("# A\n\n> ## Quoted\n"), slug); !errors.Is(err, ErrSectionNotFound) {
+			t.Errorf("GetSection(%q) error = %v, want ErrSectionNotFound", slug, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Slugger generates heading IDs the way GitHub does: lower-cased, spaces
+// turned into hyphens, punctuation dropped, and repeats numbered -1, -2, ...
+// It implements goldmark's parser.IDs, so the same slugger instance numbers
+// duplicates across a whole document.
+type Slugger struct {
+	unicode bool
+	seen    map[string]bool
+}
+
+// NewSlugger returns a Slugger. With keepUnicode, non-ASCII letters and
+// digits are kept ("日本語" stays "日本語"); otherwise they are dropped.
+func NewSlugger(keepUnicode bool) *Slugger {
+	return &Slugger{unicode: keepUnicode, seen: map[string]bool{}}
+}
+
+// WithUnicodeSlugs keeps non-ASCII letters and digits in heading IDs. It is
+// enabled by default; disabling it gives ASCII-only IDs.
+func WithUnicodeSlugs(enabled bool) Option {
+	return func(o *options) {
+		o.unicodeSlugs = enabled
+	}
+}
+
+// Slug returns a unique slug for text.
+func (s *Slugger) Slug(text string) string {
+	base := s.slugify(text)
+	if base == "" {
+		base = "heading"
+	}
+	slug := base
+	for i := 1; s.seen[slug]; i++ {
+		slug = base + "-" + strconv.Itoa(i)
+	}
+	s.seen[slug] = true
+	return slug
+}
+
+func (s *Slugger) slugify(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case r == ' ' || r == '-':
+			b.WriteByte('-')
+		case r == '_' || ('a' <= r && r <= 'z') || ('0' <= r && r <= '9'):
+			b.WriteRune(r)
+		case r > unicode.MaxASCII && s.unicode && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Generate implements parser.IDs.
+func (s *Slugger) Generate(value []byte, kind ast.NodeKind) []byte {
+	return []byte(s.Slug(string(value)))
+}
+
+// Put implements parser.IDs, reserving an ID set explicitly in the source.
+func (s *Slugger) Put(value []byte) {
+	s.seen[string(value)] = true
+}
@@ -0,0 +1,66 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugger_Slug(t *testing.T) {
+	s := NewSlugger(true)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hello World", "hello-world"},
+		{"Hello World", "hello-world-1"},
+		{"Hello World", "hello-world-2"},
+		{"What's new? (v2.0)", "whats-new-v20"},
+		{"snake_case & more", "snake_case--more"},
+		{"日本語の見出し", "日本語の見出し"},
+		{"Café Menu", "café-menu"},
+		{"!!!", "heading"},
+	}
+	for _, tt := range tests {
+		if got := s.Slug(tt.text); got != tt.want {
+			t.Errorf("Slug(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSlugger_ASCII(t *testing.T) {
+	s := NewSlugger(false)
+	if got := s.Slug("Café 日本"); got != "caf-" {
+		t.Errorf("Slug() = %q, want %q", got, "caf-")
+	}
+	if got := s.Slug("日本"); got != "heading" {
+		t.Errorf("Slug() = %q, want %q", got, "heading")
+	}
+}
+
+func TestSlugger_SharedByTOCAndRender(t *testing.T) {
+	source := "# 概要\n\n## Setup\n\n## Setup\n\n# 概要\n"
+
+	for _, unicodeSlugs := range []bool{true, false} {
+		renderer := NewRenderer(WithUnicodeSlugs(unicodeSlugs))
+		html, err := renderer.Render([]byte(source))
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		toc := renderer.ExtractTOC([]byte(source))
+		seen := map[string]bool{}
+		for _, entry := range toc {
+			if seen[entry.ID] {
+				t.Errorf("duplicate ID %q", entry.ID)
+			}
+			seen[entry.ID] = true
+			if !strings.Contains(string(html), `id="`+entry.ID+`"`) {
+				t.Errorf("unicode=%v: Render() missing anchor %q from ExtractTOC", unicodeSlugs, entry.ID)
+			}
+		}
+	}
+
+	toc := NewRenderer().ExtractTOC([]byte(source))
+	if toc[0].ID != "概要" || toc[3].ID != "概要-1" || toc[2].ID != "setup-1" {
+		t.Errorf("ExtractTOC() IDs = %+v", toc)
+	}
+}
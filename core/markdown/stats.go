@@ -0,0 +1,73 @@
+package markdown
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// WordsPerMinute is the reading speed used for NoteStats.ReadingMinutes.
+const WordsPerMinute = 200
+
+// NoteStats summarises a note for the editor status bar.
+type NoteStats struct {
+	Words          int `json:"words"`
+	Characters     int `json:"characters"`
+	Headings       int `json:"headings"`
+	Tasks          int `json:"tasks"`
+	TasksDone      int `json:"tasksDone"`
+	ReadingMinutes int `json:"readingMinutes"`
+}
+
+// statsParser only needs block structure and task lists, so it skips the
+// renderer's extensions.
+var statsParser = goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser()
+
+// Stats counts the words, characters, headings and tasks in source. Code
+// blocks and raw HTML are not counted as words.
+func Stats(source []byte) NoteStats {
+	doc := statsParser.Parse(text.NewReader(source))
+
+	var stats NoteStats
+	var prose strings.Builder
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock {
+				prose.WriteByte('\n')
+			}
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			stats.Headings++
+		case *extast.TaskCheckBox:
+			stats.Tasks++
+			if n.IsChecked {
+				stats.TasksDone++
+			}
+		case *ast.Text:
+			prose.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				prose.WriteByte(' ')
+			}
+		case *ast.String:
+			prose.Write(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	words := strings.Fields(prose.String())
+	stats.Words = len(words)
+	for _, line := range strings.Split(strings.TrimSpace(prose.String()), "\n") {
+		stats.Characters += utf8.RuneCountInString(strings.TrimSpace(line))
+	}
+	if stats.Words > 0 {
+		stats.ReadingMinutes = (stats.Words + WordsPerMinute - 1) / WordsPerMinute
+	}
+	return stats
+}
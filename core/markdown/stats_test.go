@@ -0,0 +1,36 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	source := "# Plan\n\nShip the **new** release.\n\n## Tasks\n\n- [x] Write code\n- [ ] Write docs\n\n```go\nfunc main() {}\n```\n"
+
+	got := Stats([]byte(source))
+	want := NoteStats{
+		Words:          10,
+		Characters:     50,
+		Headings:       2,
+		Tasks:          2,
+		TasksDone:      1,
+		ReadingMinutes: 1,
+	}
+	if got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestStats_Empty(t *testing.T) {
+	if got := Stats(nil); got != (NoteStats{}) {
+		t.Errorf("Stats(nil) = %+v, want zero", got)
+	}
+}
+
+func TestStats_ReadingMinutes(t *testing.T) {
+	source := strings.Repeat("word ", WordsPerMinute+1)
+	if got := Stats([]byte(source)).ReadingMinutes; got != 2 {
+		t.Errorf("ReadingMinutes = %d, want 2", got)
+	}
+}
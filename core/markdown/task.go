@@ -0,0 +1,46 @@
+package markdown
+
+import (
+	"errors"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// ErrTaskNotFound is returned when a task index is out of range.
+var ErrTaskNotFound = errors.New("task not found")
+
+// ToggleTask flips the index-th (0-based) task checkbox in source and returns
+// the updated source. Tasks are counted in document order, matching the order
+// of checkboxes in the rendered HTML.
+func ToggleTask(source []byte, index int) ([]byte, error) {
+	doc := statsParser.Parse(text.NewReader(source))
+
+	pos := -1
+	count := 0
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if _, ok := n.(*extast.TaskCheckBox); !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		if count == index {
+			// The checkbox opens the first line of its paragraph: "[ ]".
+			pos = n.Parent().Lines().At(0).Start + 1
+			return ast.WalkStop, nil
+		}
+		count++
+		return ast.WalkContinue, nil
+	})
+	if pos < 0 {
+		return nil, ErrTaskNotFound
+	}
+
+	out := make([]byte, len(source))
+	copy(out, source)
+	if out[pos] == ' ' {
+		out[pos] = 'x'
+	} else {
+		out[pos] = ' '
+	}
+	return out, nil
+}
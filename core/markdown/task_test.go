@@ -0,0 +1,37 @@
+package markdown
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToggleTask(t *testing.T) {
+	source := "# Todo\n\n- [ ] one\n- [X] two\n  - [ ] nested\n\n```\n- [ ] not a task\n```\n\n1. [x] numbered\n"
+
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "# Todo\n\n- [x] one\n- [X] two\n  - [ ] nested\n\n```\n- [ ] not a task\n```\n\n1. [x] numbered\n"},
+		{1, "# Todo\n\n- [ ] one\n- [ ] two\n  - [ ] nested\n\n```\n- [ ] not a task\n```\n\n1. [x] numbered\n"},
+		{2, "# Todo\n\n- [ ] one\n- [X] two\n  - [x] nested\n\n```\n- [ ] not a task\n```\n\n1. [x] numbered\n"},
+		{3, "# Todo\n\n- [ ] one\n- [X] two\n  - [ ] nested\n\n```\n- [ ] not a task\n```\n\n1. [ ] numbered\n"},
+	}
+	for _, tt := range tests {
+		got, err := ToggleTask([]byte(source), tt.index)
+		if err != nil {
+			t.Fatalf("ToggleTask(%d) error = %v", tt.index, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("ToggleTask(%d) =\n%q\nwant\n%q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestToggleTask_OutOfRange(t *testing.T) {
+	for _, index := range []int{-1, 1} {
+		if _, err := ToggleTask([]byte("- [ ] only"), index); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("ToggleTask(%d) error = %v, want ErrTaskNotFound", index, err)
+		}
+	}
+}
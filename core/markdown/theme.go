@@ -0,0 +1,95 @@
+package markdown
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// DefaultTheme is the chroma style used for code blocks.
+const DefaultTheme = "github"
+
+// DarkThemeSelector scopes dark theme rules, matching the frontend's
+// [data-theme="dark"] toggle.
+const DarkThemeSelector = `[data-theme="dark"]`
+
+// ErrUnknownTheme is returned for a chroma style name that does not exist.
+var ErrUnknownTheme = errors.New("unknown syntax highlighting theme")
+
+// Themes returns the names of all available syntax highlighting themes.
+func Themes() []string {
+	return styles.Names()
+}
+
+// WithTheme sets the syntax highlighting theme. Defaults to DefaultTheme.
+func WithTheme(name string) Option {
+	return func(o *options) {
+		o.theme = name
+	}
+}
+
+// WithDarkTheme sets the theme used under DarkThemeSelector. Empty (the
+// default) means dark mode reuses the light theme.
+func WithDarkTheme(name string) Option {
+	return func(o *options) {
+		o.darkTheme = name
+	}
+}
+
+// WithInlineStyles writes highlighting colours as style attributes instead of
+// class names, for HTML shown without the stylesheet such as shared pages and
+// email exports. Dark themes need class names and are ignored. It is disabled
+// by default.
+func WithInlineStyles(enabled bool) Option {
+	return func(o *options) {
+		o.inlineStyles = enabled
+	}
+}
+
+// Stylesheet returns the CSS for the renderer's highlighting themes. A
+// renderer using WithInlineStyles does not need it.
+func (r *Renderer) Stylesheet() (string, error) {
+	return Stylesheet(r.theme, r.darkTheme)
+}
+
+// Stylesheet returns CSS for code blocks rendered with class names. Rules for
+// dark are scoped under DarkThemeSelector; pass "" to omit them.
+func Stylesheet(light, dark string) (string, error) {
+	var buf bytes.Buffer
+	if err := writeThemeCSS(&buf, light, ""); err != nil {
+		return "", err
+	}
+	if dark != "" {
+		if err := writeThemeCSS(&buf, dark, DarkThemeSelector+" "); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+func writeThemeCSS(buf *bytes.Buffer, name, scope string) error {
+	style, ok := styles.Registry[name]
+	if !ok {
+		return ErrUnknownTheme
+	}
+
+	var css bytes.Buffer
+	if err := chromahtml.New(chromahtml.WithClasses(true), chromahtml.WithCSSComments(false)).WriteCSS(&css, style); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(&css)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		buf.WriteString(scope)
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,59 @@
+package markdown
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStylesheet(t *testing.T) {
+	css, err := Stylesheet("github", "monokai")
+	if err != nil {
+		t.Fatalf("Stylesheet() error = %v", err)
+	}
+	if !strings.Contains(css, ".chroma .k {") {
+		t.Errorf("Stylesheet() missing light keyword rule:\n%s", css)
+	}
+	if !strings.Contains(css, `[data-theme="dark"] .chroma .k {`) {
+		t.Errorf("Stylesheet() missing dark keyword rule:\n%s", css)
+	}
+}
+
+func TestStylesheet_LightOnly(t *testing.T) {
+	css, err := NewRenderer(WithTheme("dracula")).Stylesheet()
+	if err != nil {
+		t.Fatalf("Stylesheet() error = %v", err)
+	}
+	if strings.Contains(css, DarkThemeSelector) {
+		t.Errorf("Stylesheet() contains dark rules without a dark theme")
+	}
+}
+
+func TestStylesheet_UnknownTheme(t *testing.T) {
+	if _, err := Stylesheet("no-such-theme", ""); !errors.Is(err, ErrUnknownTheme) {
+		t.Errorf("Stylesheet() error = %v, want ErrUnknownTheme", err)
+	}
+	if _, err := Stylesheet("github", "no-such-theme"); !errors.Is(err, ErrUnknownTheme) {
+		t.Errorf("Stylesheet() dark error = %v, want ErrUnknownTheme", err)
+	}
+}
+
+func TestWithInlineStyles(t *testing.T) {
+	source := []byte("```go\nfunc main() {}\n```\n")
+
+	classed, err := NewRenderer().Render(source)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if !strings.Contains(string(classed), `class="kd"`) || strings.Contains(string(classed), "style=") {
+		t.Errorf("default Render() should use class names:\n%s", classed)
+	}
+
+	inline, err := NewRenderer(WithInlineStyles(true)).RenderSanitized(source)
+	if err != nil {
+		t.Fatalf("RenderSanitized() error = %v", err)
+	}
+	if strings.Contains(string(inline), `class="kd"`) || !strings.Contains(string(inline), `style="color:`) {
+		t.Errorf("WithInlineStyles RenderSanitized() should use style attributes:\n%s", inline)
+	}
+}
@@ -0,0 +1,81 @@
+package markdown
+
+import (
+	"bytes"
+	"strconv"
+
+	emojiast "github.com/yuin/goldmark-emoji/ast"
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// TOCEntry is a single heading in a document outline.
+type TOCEntry struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// ExtractTOC returns the document's headings in order. IDs match the anchors
+// produced by Render.
+func (r *Renderer) ExtractTOC(source []byte) []TOCEntry {
+	doc := r.parse(source)
+
+	entries := []TOCEntry{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		entry := TOCEntry{Level: heading.Level, Text: inlineText(heading, source)}
+		if id, ok := heading.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok {
+				entry.ID = string(b)
+			}
+		}
+		entries = append(entries, entry)
+		return ast.WalkSkipChildren, nil
+	})
+	return entries
+}
+
+// inlineText returns the plain text of n's inline children on one line.
+func inlineText(n ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	writeInlineText(&buf, n, source, ' ')
+	return buf.String()
+}
+
+// writeInlineText writes the plain text of n's inline children, writing
+// lineBreak for soft and hard line breaks.
+func writeInlineText(buf *bytes.Buffer, n ast.Node, source []byte, lineBreak byte) {
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := c.(type) {
+		case *ast.Text:
+			buf.Write(c.Segment.Value(source))
+			if c.SoftLineBreak() || c.HardLineBreak() {
+				buf.WriteByte(lineBreak)
+			}
+		case *ast.String:
+			buf.Write(c.Value)
+		case *WikiLink:
+			buf.WriteString(c.Label())
+		case *MathInline:
+			buf.Write(c.Formula)
+		case *emojiast.Emoji:
+			buf.WriteString(string(c.Value.Unicode))
+		case *extast.FootnoteLink:
+			buf.WriteString("[" + strconv.Itoa(c.Index) + "]")
+		case *extast.TaskCheckBox:
+			if c.IsChecked {
+				buf.WriteString("[x] ")
+			} else {
+				buf.WriteString("[ ] ")
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
@@ -0,0 +1,38 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractTOC(t *testing.T) {
+	source := "# Intro\n\ntext\n\n## Getting `started` *now*\n\n### Third level\n\n```\n# not a heading\n```\n\n## Intro\n"
+
+	renderer := NewRenderer()
+	got := renderer.ExtractTOC([]byte(source))
+	want := []TOCEntry{
+		{Level: 1, Text: "Intro", ID: "intro"},
+		{Level: 2, Text: "Getting started now", ID: "getting-started-now"},
+		{Level: 3, Text: "Third level", ID: "third-level"},
+		{Level: 2, Text: "Intro", ID: "intro-1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExtractTOC() = %+v, want %+v", got, want)
+	}
+
+	// IDs must match the anchors in the rendered HTML.
+	html, _ := renderer.Render([]byte(source))
+	for _, entry := range want {
+		if !strings.Contains(string(html), `id="`+entry.ID+`"`) {
+			t.Errorf("Render() missing anchor %q", entry.ID)
+		}
+	}
+}
+
+func TestExtractTOC_NoHeadings(t *testing.T) {
+	got := NewRenderer().ExtractTOC([]byte("just text"))
+	if len(got) != 0 {
+		t.Errorf("ExtractTOC() = %+v, want empty", got)
+	}
+}
@@ -0,0 +1,126 @@
+package markdown
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// DefaultWikiLinkURL is the URL template used for [[...]] links. {target} is
+// replaced with the escaped link target.
+const DefaultWikiLinkURL = "/note?id={target}"
+
+// KindWikiLink is the node kind for [[target]] and [[target|alias]] links.
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// WikiLink is an internal link to another note by title or ID.
+type WikiLink struct {
+	ast.BaseInline
+	Target string `json:"target"`
+	Alias  string `json:"alias,omitempty"`
+
+	// offset is the byte offset of the opening "[[" in the source.
+	offset int
+}
+
+func (n *WikiLink) Kind() ast.NodeKind { return KindWikiLink }
+
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Target, "Alias": n.Alias}, nil)
+}
+
+// Label returns the text shown for the link.
+func (n *WikiLink) Label() string {
+	if n.Alias != "" {
+		return n.Alias
+	}
+	return n.Target
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte { return []byte{'['} }
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line[2:], []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	inner := line[2 : 2+end]
+	if bytes.ContainsAny(inner, "[]\n") {
+		return nil
+	}
+
+	target, alias, _ := strings.Cut(string(inner), "|")
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil
+	}
+	block.Advance(2 + end + 2)
+	return &WikiLink{Target: target, Alias: strings.TrimSpace(alias), offset: segment.Start}
+}
+
+type wikiLinkHTMLRenderer struct {
+	urlTemplate string
+}
+
+func (r *wikiLinkHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.render)
+}
+
+func (r *wikiLinkHTMLRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*WikiLink)
+	// QueryEscape with %20 for spaces is valid in both path and query positions.
+	escaped := strings.ReplaceAll(url.QueryEscape(n.Target), "+", "%20")
+	href := strings.ReplaceAll(r.urlTemplate, "{target}", escaped)
+
+	_, _ = w.WriteString(`<a href="`)
+	_, _ = w.Write(util.EscapeHTML([]byte(href)))
+	_, _ = w.WriteString(`" class="wiki-link">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.Label())))
+	_, _ = w.WriteString(`</a>`)
+	return ast.WalkSkipChildren, nil
+}
+
+// wikiLinkExtension adds [[...]] internal links.
+type wikiLinkExtension struct {
+	urlTemplate string
+}
+
+func (e *wikiLinkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		// Ahead of the standard link parser (200) so "[[" is not read as a link.
+		parser.WithInlineParsers(util.Prioritized(&wikiLinkParser{}, 199)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&wikiLinkHTMLRenderer{urlTemplate: e.urlTemplate}, 500)),
+	)
+}
+
+// ExtractWikiLinks returns every [[...]] link in source, in document order.
+func (r *Renderer) ExtractWikiLinks(source []byte) []WikiLink {
+	doc := r.parse(source)
+
+	links := []WikiLink{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*WikiLink); ok && entering {
+			links = append(links, WikiLink{Target: link.Target, Alias: link.Alias})
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
@@ -0,0 +1,73 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWikiLink_Render(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Title link",
+			input:    "See [[Project Plan]].",
+			expected: `<a href="/note?id=Project%20Plan" class="wiki-link">Project Plan</a>`,
+		},
+		{
+			name:     "Aliased link",
+			input:    "[[abc123|the plan]]",
+			expected: `<a href="/note?id=abc123" class="wiki-link">the plan</a>`,
+		},
+		{
+			name:     "Target is escaped",
+			input:    "[[a&b <c>]]",
+			expected: `href="/note?id=a%26b%20%3Cc%3E" class="wiki-link">a&amp;b &lt;c&gt;</a>`,
+		},
+		{
+			name:     "Regular links still work",
+			input:    "[text](https://example.com)",
+			expected: `<a href="https://example.com">text</a>`,
+		},
+		{
+			name:     "Empty target is literal",
+			input:    "[[ ]]",
+			expected: "<p>[[ ]]</p>",
+		},
+	}
+
+	renderer := NewRenderer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := renderer.Render([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if !strings.Contains(string(output), tt.expected) {
+				t.Errorf("Render() = %q, want substring %q", output, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWikiLink_URLTemplate(t *testing.T) {
+	renderer := NewRenderer(WithWikiLinkURL("/wiki/{target}"))
+	output, _ := renderer.Render([]byte("[[Home]]"))
+	if !strings.Contains(string(output), `href="/wiki/Home"`) {
+		t.Errorf("Render() = %q, want custom template", output)
+	}
+}
+
+func TestExtractWikiLinks(t *testing.T) {
+	renderer := NewRenderer()
+	links := renderer.ExtractWikiLinks([]byte("# T\n\n[[One]] and [[two|Two]]\n\n`[[not a link]]`"))
+
+	if len(links) != 2 {
+		t.Fatalf("ExtractWikiLinks() returned %d links, want 2: %+v", len(links), links)
+	}
+	if links[0].Target != "One" || links[1].Target != "two" || links[1].Alias != "Two" {
+		t.Errorf("ExtractWikiLinks() = %+v", links)
+	}
+}
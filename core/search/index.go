@@ -0,0 +1,207 @@
+// Package search provides a trigram index over note contents for offline
+// full-text search in the browser.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// snippetRunes is how much context a result snippet shows around the match.
+const snippetRunes = 40
+
+// Document is a note to index.
+type Document struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// Result is a search hit.
+type Result struct {
+	ID      string  `json:"id"`
+	Title   string  `json:"title"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+type entry struct {
+	doc   Document
+	title string // lower-cased title
+	text  string // lower-cased content
+}
+
+// Index maps every three-rune sequence to the documents containing it, so
+// a query only checks documents that contain all of its trigrams. Substring
+// matching works for any script, including ones without word spaces.
+type Index struct {
+	entries  []*entry // nil where a document was removed
+	byID     map[string]int
+	postings map[string][]int
+}
+
+// NewIndex builds an index over docs.
+func NewIndex(docs []Document) *Index {
+	ix := &Index{byID: map[string]int{}, postings: map[string][]int{}}
+	for _, doc := range docs {
+		ix.Add(doc)
+	}
+	return ix
+}
+
+// Len returns the number of indexed documents.
+func (ix *Index) Len() int {
+	return len(ix.byID)
+}
+
+// Add indexes doc, replacing any document with the same ID.
+func (ix *Index) Add(doc Document) {
+	ix.Remove(doc.ID)
+
+	e := &entry{doc: doc, title: normalize(doc.Title), text: normalize(doc.Content)}
+	n := len(ix.entries)
+	ix.entries = append(ix.entries, e)
+	ix.byID[doc.ID] = n
+	for tri := range trigrams(e.title + "\n" + e.text) {
+		ix.postings[tri] = append(ix.postings[tri], n)
+	}
+}
+
+// Remove drops the document with id from the index. Its postings are
+// skipped at query time.
+func (ix *Index) Remove(id string) {
+	if n, ok := ix.byID[id]; ok {
+		ix.entries[n] = nil
+		delete(ix.byID, id)
+	}
+}
+
+// Search returns documents containing every whitespace-separated term of
+// query, best matches first. A limit of 0 or less returns all matches.
+func (ix *Index) Search(query string, limit int) []Result {
+	terms := strings.Fields(normalize(query))
+	if len(terms) == 0 {
+		return []Result{}
+	}
+
+	results := []Result{}
+	for _, n := range ix.candidates(terms) {
+		e := ix.entries[n]
+		score := 0.0
+		for _, term := range terms {
+			inTitle := strings.Count(e.title, term)
+			inText := strings.Count(e.text, term)
+			if inTitle+inText == 0 {
+				score = 0
+				break
+			}
+			score += 3*float64(inTitle) + math.Log1p(float64(inText))
+		}
+		if score == 0 {
+			continue
+		}
+		results = append(results, Result{
+			ID:      e.doc.ID,
+			Title:   e.doc.Title,
+			Score:   score,
+			Snippet: snippet(e, terms[0]),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Title < results[j].Title
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// candidates returns the documents holding every trigram of the terms.
+// Terms shorter than three runes cannot narrow the set.
+func (ix *Index) candidates(terms []string) []int {
+	var set []int
+	narrowed := false
+	for _, term := range terms {
+		for tri := range trigrams(term) {
+			if narrowed {
+				set = intersect(set, ix.postings[tri])
+			} else {
+				set = append([]int(nil), ix.postings[tri]...)
+				narrowed = true
+			}
+		}
+	}
+	if !narrowed {
+		for n := range ix.entries {
+			set = append(set, n)
+		}
+	}
+
+	live := set[:0]
+	for _, n := range set {
+		if ix.entries[n] != nil {
+			live = append(live, n)
+		}
+	}
+	return live
+}
+
+// intersect returns the values present in both sorted slices.
+func intersect(a, b []int) []int {
+	out := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, a[i])
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return out
+}
+
+// normalize lower-cases s rune by rune, so rune offsets in the result match
+// those in s.
+func normalize(s string) string {
+	return strings.Map(unicode.ToLower, s)
+}
+
+func trigrams(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	runes := []rune(s)
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = struct{}{}
+	}
+	return set
+}
+
+// snippet returns the content around the first match of term, or the start
+// of the content if term only matched the title.
+func snippet(e *entry, term string) string {
+	content := []rune(e.doc.Content)
+	start := 0
+	if i := strings.Index(e.text, term); i >= 0 {
+		start = max(0, utf8.RuneCountInString(e.text[:i])-snippetRunes)
+	}
+	end := min(len(content), start+2*snippetRunes+utf8.RuneCountInString(term))
+
+	s := strings.Join(strings.Fields(string(content[start:end])), " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(content) {
+		s += "…"
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package search
+
+import "testing"
+
+var testDocs = []Document{
+	{ID: "1", Title: "Go notes", Content: "Goroutines and channels make concurrency simple."},
+	{ID: "2", Title: "Groceries", Content: "Milk, eggs, and bread. Maybe some Go-kart tickets."},
+	{ID: "3", Title: "旅行の計画", Content: "京都で紅葉を見る。温泉にも行きたい。"},
+	{ID: "4", Title: "Channels", Content: "Buffered channels, unbuffered channels, and select."},
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := NewIndex(testDocs)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"Single term ranks title matches first", "channels", []string{"4", "1"}},
+		{"All terms must match", "channels select", []string{"4"}},
+		{"Case-insensitive", "GOROUTINES", []string{"1"}},
+		{"Short terms scan everything", "go", []string{"1", "2"}},
+		{"Japanese substring", "紅葉", []string{"3"}},
+		{"No match", "python", []string{}},
+		{"Empty query", "   ", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := ix.Search(tt.query, 0)
+			got := make([]string, len(results))
+			for i, r := range results {
+				got[i] = r.ID
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIndex_SearchLimitAndSnippet(t *testing.T) {
+	ix := NewIndex(testDocs)
+
+	results := ix.Search("channels", 1)
+	if len(results) != 1 || results[0].ID != "4" {
+		t.Fatalf("Search() with limit = %+v", results)
+	}
+
+	results = ix.Search("温泉", 0)
+	if len(results) != 1 || results[0].Snippet != "京都で紅葉を見る。温泉にも行きたい。" {
+		t.Errorf("Search() snippet = %+v", results)
+	}
+}
+
+func TestIndex_AddAndRemove(t *testing.T) {
+	ix := NewIndex(testDocs)
+
+	ix.Add(Document{ID: "2", Title: "Groceries", Content: "Apples only."})
+	if got := ix.Search("bread", 0); len(got) != 0 {
+		t.Errorf("Search() after replace = %+v, want no results", got)
+	}
+	if got := ix.Search("apples", 0); len(got) != 1 {
+		t.Errorf("Search() after replace = %+v, want one result", got)
+	}
+
+	ix.Remove("4")
+	if got := ix.Search("select", 0); len(got) != 0 {
+		t.Errorf("Search() after remove = %+v, want no results", got)
+	}
+	if ix.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", ix.Len())
+	}
+}
+
+func TestSnippet_Truncates(t *testing.T) {
+	long := ""
+	for i := 0; i < 20; i++ {
+		long += "filler words here "
+	}
+	ix := NewIndex([]Document{{ID: "1", Title: "Long", Content: long + "needle " + long}})
+
+	results := ix.Search("needle", 0)
+	if len(results) != 1 {
+		t.Fatalf("Search() = %+v", results)
+	}
+	snippet := []rune(results[0].Snippet)
+	if snippet[0] != '…' || snippet[len(snippet)-1] != '…' {
+		t.Errorf("Snippet = %q, want ellipses on both ends", results[0].Snippet)
+	}
+}
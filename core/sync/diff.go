@@ -0,0 +1,117 @@
+package sync
+
+import "strings"
+
+// maxDiffCells caps the LCS table used by Diff. Larger edits are reported
+// as one deletion and one insertion.
+const maxDiffCells = 4 << 20
+
+// DiffOpType says whether a run of lines is common, removed or added.
+type DiffOpType string
+
+const (
+	DiffEqual  DiffOpType = "equal"
+	DiffDelete DiffOpType = "delete"
+	DiffInsert DiffOpType = "insert"
+)
+
+// DiffOp is a run of lines in a line diff.
+type DiffOp struct {
+	Type  DiffOpType `json:"type"`
+	Lines []string   `json:"lines"`
+}
+
+// Diff returns the line-level differences that turn a into b.
+func Diff(a, b string) []DiffOp {
+	linesA, linesB := splitLines(a), splitLines(b)
+	matches := matchLines(linesA, linesB)
+
+	var ops []DiffOp
+	add := func(typ DiffOpType, line string) {
+		if len(ops) == 0 || ops[len(ops)-1].Type != typ {
+			ops = append(ops, DiffOp{Type: typ})
+		}
+		ops[len(ops)-1].Lines = append(ops[len(ops)-1].Lines, line)
+	}
+
+	i, j := 0, 0
+	for _, m := range append(matches, [2]int{len(linesA), len(linesB)}) {
+		for ; i < m[0]; i++ {
+			add(DiffDelete, linesA[i])
+		}
+		for ; j < m[1]; j++ {
+			add(DiffInsert, linesB[j])
+		}
+		if i < len(linesA) && j < len(linesB) {
+			add(DiffEqual, linesA[i])
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+// splitLines splits s into lines, keeping each line's trailing newline so
+// joining the lines gives back s exactly.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines returns the index pairs of a longest common subsequence of a
+// and b, in increasing order. The common prefix and suffix are matched
+// directly; if the rest is too large to compare, it is left unmatched.
+func matchLines(a, b []string) [][2]int {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var matches [][2]int
+	for i := 0; i < prefix; i++ {
+		matches = append(matches, [2]int{i, i})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+	if n > 0 && m > 0 && (n+1)*(m+1) <= maxDiffCells {
+		// lcs[i*(m+1)+j] is the LCS length of midA[i:] and midB[j:].
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+				} else {
+					lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+				}
+			}
+		}
+		for i, j := 0, 0; i < n && j < m; {
+			switch {
+			case midA[i] == midB[j]:
+				matches = append(matches, [2]int{prefix + i, prefix + j})
+				i++
+				j++
+			case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+				i++
+			default:
+				j++
+			}
+		}
+	}
+
+	for k := suffix; k > 0; k-- {
+		matches = append(matches, [2]int{len(a) - k, len(b) - k})
+	}
+	return matches
+}
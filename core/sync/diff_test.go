@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\n"
+	b := "one\n2\nthree\nfour\nfive\n"
+
+	got := Diff(a, b)
+	want := []DiffOp{
+		{Type: DiffEqual, Lines: []string{"one\n"}},
+		{Type: DiffDelete, Lines: []string{"two\n"}},
+		{Type: DiffInsert, Lines: []string{"2\n"}},
+		{Type: DiffEqual, Lines: []string{"three\n", "four\n"}},
+		{Type: DiffInsert, Lines: []string{"five\n"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDiff_RebuildsBothSides(t *testing.T) {
+	cases := [][2]string{
+		{"", "new\n"},
+		{"old\n", ""},
+		{"a\nb\nc", "a\nc\nb\nd"},
+		{"x\ny\nx\ny\n", "y\nx\ny\nx\n"},
+	}
+	for _, c := range cases {
+		var a, b strings.Builder
+		for _, op := range Diff(c[0], c[1]) {
+			for _, line := range op.Lines {
+				if op.Type != DiffInsert {
+					a.WriteString(line)
+				}
+				if op.Type != DiffDelete {
+					b.WriteString(line)
+				}
+			}
+		}
+		if a.String() != c[0] || b.String() != c[1] {
+			t.Errorf("Diff(%q, %q) rebuilds %q, %q", c[0], c[1], a.String(), b.String())
+		}
+	}
+}
@@ -0,0 +1,10 @@
+// Package sync holds the offline-editing logic shared by the browser and Go
+// tools: ETag conflict checks, the offline change queue, line diffs and
+// three-way merges of note content.
+//
+// It does not depend on the Wasm bridge and can be imported by any Go
+// program. The name shadows the standard library's sync, so callers that
+// need both usually import it under an alias:
+//
+//	import notesync "github.com/jun/gophdrive/core/sync"
+package sync
@@ -0,0 +1,98 @@
+package sync
+
+import "strings"
+
+// Conflict markers written around lines both sides changed differently.
+const (
+	ConflictMarkerLocal  = "<<<<<<< local\n"
+	ConflictMarkerSep    = "=======\n"
+	ConflictMarkerRemote = ">>>>>>> remote\n"
+)
+
+// MergeResult is the outcome of a three-way merge.
+type MergeResult struct {
+	Content   string `json:"content"`
+	Conflicts int    `json:"conflicts"`
+}
+
+// Clean reports whether the merge finished without conflicts.
+func (r MergeResult) Clean() bool {
+	return r.Conflicts == 0
+}
+
+// Merge combines local and remote edits of base line by line. Changes made
+// on only one side are applied; overlapping changes that differ are kept
+// between conflict markers with the local lines first.
+func Merge(base, local, remote string) MergeResult {
+	baseLines := splitLines(base)
+	localLines := splitLines(local)
+	remoteLines := splitLines(remote)
+
+	// toLocal[i] and toRemote[i] are the lines matching base line i, or -1.
+	toLocal := matchIndex(len(baseLines), matchLines(baseLines, localLines))
+	toRemote := matchIndex(len(baseLines), matchLines(baseLines, remoteLines))
+
+	var out strings.Builder
+	var result MergeResult
+	i, l, r := 0, 0, 0
+	for {
+		// Copy lines unchanged on both sides.
+		for i < len(baseLines) && toLocal[i] == l && toRemote[i] == r {
+			out.WriteString(baseLines[i])
+			i, l, r = i+1, l+1, r+1
+		}
+
+		// The next base line kept by both sides ends this chunk.
+		next, nextL, nextR := len(baseLines), len(localLines), len(remoteLines)
+		for k := i; k < len(baseLines); k++ {
+			if toLocal[k] >= 0 && toRemote[k] >= 0 {
+				next, nextL, nextR = k, toLocal[k], toRemote[k]
+				break
+			}
+		}
+		if next == i && nextL == l && nextR == r {
+			break
+		}
+
+		baseChunk := strings.Join(baseLines[i:next], "")
+		localChunk := strings.Join(localLines[l:nextL], "")
+		remoteChunk := strings.Join(remoteLines[r:nextR], "")
+		switch {
+		case localChunk == baseChunk || localChunk == remoteChunk:
+			out.WriteString(remoteChunk)
+		case remoteChunk == baseChunk:
+			out.WriteString(localChunk)
+		default:
+			result.Conflicts++
+			out.WriteString(ConflictMarkerLocal)
+			writeChunk(&out, localChunk)
+			out.WriteString(ConflictMarkerSep)
+			writeChunk(&out, remoteChunk)
+			out.WriteString(ConflictMarkerRemote)
+		}
+		i, l, r = next, nextL, nextR
+	}
+
+	result.Content = out.String()
+	return result
+}
+
+func matchIndex(n int, matches [][2]int) []int {
+	index := make([]int, n)
+	for i := range index {
+		index[i] = -1
+	}
+	for _, m := range matches {
+		index[m[0]] = m[1]
+	}
+	return index
+}
+
+// writeChunk writes a conflicting chunk, ending it with a newline so the
+// following marker starts on its own line.
+func writeChunk(out *strings.Builder, chunk string) {
+	out.WriteString(chunk)
+	if chunk != "" && !strings.HasSuffix(chunk, "\n") {
+		out.WriteByte('\n')
+	}
+}
@@ -0,0 +1,65 @@
+package sync
+
+import "testing"
+
+func TestMerge(t *testing.T) {
+	base := "# Title\n\nintro\n\nbody\n\nend\n"
+
+	tests := []struct {
+		name          string
+		local, remote string
+		want          string
+		conflicts     int
+	}{
+		{
+			name:   "Non-overlapping edits",
+			local:  "# Title\n\nnew intro\n\nbody\n\nend\n",
+			remote: "# Title\n\nintro\n\nbody\n\nend\nappendix\n",
+			want:   "# Title\n\nnew intro\n\nbody\n\nend\nappendix\n",
+		},
+		{
+			name:   "Same edit on both sides",
+			local:  "# Title\n\nintro\n\nBODY\n\nend\n",
+			remote: "# Title\n\nintro\n\nBODY\n\nend\n",
+			want:   "# Title\n\nintro\n\nBODY\n\nend\n",
+		},
+		{
+			name:   "Deletion and edit elsewhere",
+			local:  "# Title\n\nbody\n\nend\n",
+			remote: "# New Title\n\nintro\n\nbody\n\nend\n",
+			want:   "# New Title\n\nbody\n\nend\n",
+		},
+		{
+			name:      "Conflicting edits",
+			local:     "# Title\n\nintro\n\nmine\n\nend\n",
+			remote:    "# Title\n\nintro\n\ntheirs\n\nend\n",
+			want:      "# Title\n\nintro\n\n<<<<<<< local\nmine\n=======\ntheirs\n>>>>>>> remote\n\nend\n",
+			conflicts: 1,
+		},
+		{
+			name:      "Conflicting appends without trailing newline",
+			local:     base + "a",
+			remote:    base + "b",
+			want:      base + "<<<<<<< local\na\n=======\nb\n>>>>>>> remote\n",
+			conflicts: 1,
+		},
+		{
+			name:   "No changes",
+			local:  base,
+			remote: base,
+			want:   base,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Merge(base, tt.local, tt.remote)
+			if got.Content != tt.want {
+				t.Errorf("Merge().Content =\n%q\nwant\n%q", got.Content, tt.want)
+			}
+			if got.Conflicts != tt.conflicts || got.Clean() != (tt.conflicts == 0) {
+				t.Errorf("Merge().Conflicts = %d, want %d", got.Conflicts, tt.conflicts)
+			}
+		})
+	}
+}
@@ -3,10 +3,12 @@ package sync
 import "time"
 
 // OfflineChange represents a change made while offline.
+// BaseETag is the note version the change was made against, if known.
 type OfflineChange struct {
 	NoteID    string `json:"noteId"`
 	Content   string `json:"content"`
 	Timestamp int64  `json:"timestamp"`
+	BaseETag  string `json:"baseEtag,omitempty"`
 }
 
 // NewOfflineChange creates a new offline change.
@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// OfflineQueue holds pending offline changes, at most one per note.
+// It serializes to a JSON array so clients can persist it in IndexedDB.
+type OfflineQueue struct {
+	changes []OfflineChange
+}
+
+// NewOfflineQueue creates an empty queue.
+func NewOfflineQueue() *OfflineQueue {
+	return &OfflineQueue{}
+}
+
+// Add enqueues a change. An existing change for the same note is replaced by
+// the newer content and moved to the back; its BaseETag is kept, since that is
+// the version the offline edits started from.
+func (q *OfflineQueue) Add(change OfflineChange) {
+	for i, c := range q.changes {
+		if c.NoteID != change.NoteID {
+			continue
+		}
+		if c.BaseETag != "" {
+			change.BaseETag = c.BaseETag
+		}
+		q.changes = append(q.changes[:i], q.changes[i+1:]...)
+		break
+	}
+	q.changes = append(q.changes, change)
+}
+
+// Remove drops the change for noteID, typically after it has been synced.
+// It reports whether a change was removed.
+func (q *OfflineQueue) Remove(noteID string) bool {
+	for i, c := range q.changes {
+		if c.NoteID == noteID {
+			q.changes = append(q.changes[:i], q.changes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the pending change for noteID.
+func (q *OfflineQueue) Get(noteID string) (OfflineChange, bool) {
+	for _, c := range q.changes {
+		if c.NoteID == noteID {
+			return c, true
+		}
+	}
+	return OfflineChange{}, false
+}
+
+// Changes returns pending changes in replay order.
+func (q *OfflineQueue) Changes() []OfflineChange {
+	out := make([]OfflineChange, len(q.changes))
+	copy(out, q.changes)
+	return out
+}
+
+// Len returns the number of pending changes.
+func (q *OfflineQueue) Len() int {
+	return len(q.changes)
+}
+
+// MarshalJSON encodes the queue as a JSON array of changes.
+func (q *OfflineQueue) MarshalJSON() ([]byte, error) {
+	if q.changes == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(q.changes)
+}
+
+// UnmarshalJSON decodes a JSON array of changes, coalescing duplicates.
+func (q *OfflineQueue) UnmarshalJSON(data []byte) error {
+	var changes []OfflineChange
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return fmt.Errorf("invalid offline queue: %w", err)
+	}
+	q.changes = nil
+	for _, c := range changes {
+		q.Add(c)
+	}
+	return nil
+}
+
+// LoadOfflineQueue decodes a queue produced by MarshalJSON.
+// Empty input yields an empty queue.
+func LoadOfflineQueue(data string) (*OfflineQueue, error) {
+	q := NewOfflineQueue()
+	if data == "" {
+		return q, nil
+	}
+	if err := json.Unmarshal([]byte(data), q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
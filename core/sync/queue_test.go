@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOfflineQueue_AddCoalesces(t *testing.T) {
+	q := NewOfflineQueue()
+	q.Add(OfflineChange{NoteID: "a", Content: "a1", Timestamp: 1, BaseETag: "etag-a"})
+	q.Add(OfflineChange{NoteID: "b", Content: "b1", Timestamp: 2})
+	q.Add(OfflineChange{NoteID: "a", Content: "a2", Timestamp: 3})
+
+	if q.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", q.Len())
+	}
+	changes := q.Changes()
+	if changes[0].NoteID != "b" || changes[1].NoteID != "a" {
+		t.Errorf("order = [%s %s], want [b a]", changes[0].NoteID, changes[1].NoteID)
+	}
+	if changes[1].Content != "a2" {
+		t.Errorf("Content = %q, want latest %q", changes[1].Content, "a2")
+	}
+	if changes[1].BaseETag != "etag-a" {
+		t.Errorf("BaseETag = %q, want original %q", changes[1].BaseETag, "etag-a")
+	}
+}
+
+func TestOfflineQueue_Remove(t *testing.T) {
+	q := NewOfflineQueue()
+	q.Add(NewOfflineChange("a", "x"))
+
+	if !q.Remove("a") {
+		t.Error("Remove(a) = false, want true")
+	}
+	if q.Remove("a") {
+		t.Error("second Remove(a) = true, want false")
+	}
+	if _, ok := q.Get("a"); ok {
+		t.Error("Get(a) found a removed change")
+	}
+}
+
+func TestOfflineQueue_JSONRoundTrip(t *testing.T) {
+	q := NewOfflineQueue()
+	q.Add(OfflineChange{NoteID: "a", Content: "x", Timestamp: 1, BaseETag: "e1"})
+	q.Add(OfflineChange{NoteID: "b", Content: "y", Timestamp: 2})
+
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	loaded, err := LoadOfflineQueue(string(data))
+	if err != nil {
+		t.Fatalf("LoadOfflineQueue: %v", err)
+	}
+	if loaded.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", loaded.Len())
+	}
+	if c, _ := loaded.Get("a"); c.BaseETag != "e1" || c.Content != "x" {
+		t.Errorf("Get(a) = %+v", c)
+	}
+}
+
+func TestLoadOfflineQueue_EmptyAndInvalid(t *testing.T) {
+	q, err := LoadOfflineQueue("")
+	if err != nil || q.Len() != 0 {
+		t.Errorf("LoadOfflineQueue(\"\") = %v, %v; want empty queue", q, err)
+	}
+	data, _ := json.Marshal(q)
+	if string(data) != "[]" {
+		t.Errorf("empty queue marshals to %s, want []", data)
+	}
+	if _, err := LoadOfflineQueue("{"); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
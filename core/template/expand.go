@@ -0,0 +1,106 @@
+// Package template expands {{variables}} in note templates. The frontend
+// uses it through the Wasm bridge when a note is created from a template,
+// and the backend when one is created with POST /templates/{id}/notes.
+package template
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Built-in variable names.
+const (
+	VarDate   = "date"   // current date, DefaultDateFormat unless a layout is given
+	VarTime   = "time"   // current time, DefaultTimeFormat unless a layout is given
+	VarTitle  = "title"  // Context.Title
+	VarCursor = "cursor" // removed; marks where the editor places the caret
+)
+
+// Default layouts for {{date}} and {{time}}.
+const (
+	DefaultDateFormat = "2006-01-02"
+	DefaultTimeFormat = "15:04"
+)
+
+// variablePattern matches {{name}} and {{name:layout}}, allowing spaces
+// inside the braces.
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*(?::([^}]*))?\}\}`)
+
+// Context supplies the values for a template expansion.
+type Context struct {
+	Title string
+	// Now is the moment {{date}} and {{time}} describe, in its own location.
+	// The zero value means time.Now().
+	Now time.Time
+	// Variables holds custom values. They take precedence over the built-in
+	// date, time and title; {{cursor}} cannot be overridden.
+	Variables map[string]string
+}
+
+// Expansion is the result of ExpandVariables.
+type Expansion struct {
+	Text string `json:"text"`
+	// Cursor is the byte offset in Text of the first {{cursor}}, or -1 when
+	// the template has none.
+	Cursor int `json:"cursor"`
+}
+
+// ExpandVariables replaces the variables in source using ctx. {{date}} and
+// {{time}} accept a Go time layout after a colon, as in
+// {{date:Jan 2, 2006}}. Unknown variables are left as written so a typo stays
+// visible in the new note.
+func ExpandVariables(source string, ctx Context) Expansion {
+	now := ctx.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var out strings.Builder
+	cursor := -1
+	last := 0
+	for _, m := range variablePattern.FindAllStringSubmatchIndex(source, -1) {
+		name := source[m[2]:m[3]]
+		layout := ""
+		if m[4] >= 0 {
+			layout = strings.TrimSpace(source[m[4]:m[5]])
+		}
+
+		var value string
+		var ok bool
+		if name != VarCursor {
+			value, ok = ctx.Variables[name]
+		}
+		if !ok {
+			switch name {
+			case VarDate:
+				value, ok = now.Format(orDefault(layout, DefaultDateFormat)), true
+			case VarTime:
+				value, ok = now.Format(orDefault(layout, DefaultTimeFormat)), true
+			case VarTitle:
+				value, ok = ctx.Title, true
+			case VarCursor:
+				value, ok = "", true
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		out.WriteString(source[last:m[0]])
+		if name == VarCursor && cursor < 0 {
+			cursor = out.Len()
+		}
+		out.WriteString(value)
+		last = m[1]
+	}
+	out.WriteString(source[last:])
+	return Expansion{Text: out.String(), Cursor: cursor}
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
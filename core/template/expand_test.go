@@ -0,0 +1,42 @@
+package template
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpandVariables(t *testing.T) {
+	ctx := Context{
+		Title:     "Weekly sync",
+		Now:       time.Date(2026, 3, 9, 14, 5, 0, 0, time.UTC),
+		Variables: map[string]string{"project": "GophDrive", "title": "Override", "cursor": "x"},
+	}
+	tests := []struct {
+		name       string
+		source     string
+		want       string
+		wantCursor int
+	}{
+		{"built-ins", "# {{date}} {{ time }}\n", "# 2026-03-09 14:05\n", -1},
+		{"layout", "{{date:Jan 2, 2006}} {{time:3PM}}", "Mar 9, 2026 2PM", -1},
+		{"custom overrides title", "{{title}} for {{project}}", "Override for GophDrive", -1},
+		{"cursor", "## Notes\n\n{{cursor}}\n{{cursor}}", "## Notes\n\n\n", 10},
+		{"unknown left as is", "{{unknown}} and {{ bad name }}", "{{unknown}} and {{ bad name }}", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExpandVariables(tt.source, ctx)
+			if got.Text != tt.want || got.Cursor != tt.wantCursor {
+				t.Errorf("ExpandVariables(%q) = %q, cursor %d; want %q, cursor %d",
+					tt.source, got.Text, got.Cursor, tt.want, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestExpandVariables_Title(t *testing.T) {
+	got := ExpandVariables("# {{title}}", Context{Title: "Plan"})
+	if got.Text != "# Plan" {
+		t.Errorf("ExpandVariables = %q, want %q", got.Text, "# Plan")
+	}
+}
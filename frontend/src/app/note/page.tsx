@@ -21,6 +21,7 @@ import {
   renameNote,
   getBreadcrumbs,
   BreadcrumbItem,
+  errorMessage,
 } from "@/lib/api";
 import { Editor } from "@/components/Editor";
 import { Preview } from "@/components/Preview";
@@ -312,8 +313,7 @@ function NoteContent() {
         setConflictRemote(null);
         setConflictLocal(null);
       } else {
-        const text = await res.text();
-        alert(`Failed to overwrite: ${text}`);
+        alert(`Failed to overwrite: ${await errorMessage(res)}`);
       }
     });
   };
@@ -14,15 +14,23 @@ export function Preview({ markdown, className }: PreviewProps) {
   const [html, setHtml] = useState<string>("");
 
   useEffect(() => {
-    if (isReady && window.renderMarkdown) {
-      try {
-        const result = window.renderMarkdown(markdown);
-        // eslint-disable-next-line react-hooks/set-state-in-effect
-        setHtml(result);
-      } catch (e) {
-        console.error("Render error", e);
-        setHtml('<p style="color:red">Error rendering markdown</p>');
-      }
+    if (isReady && window.renderMarkdownAsync) {
+      // Stale renders are dropped when the note changes before they settle.
+      let cancelled = false;
+      window
+        .renderMarkdownAsync(markdown)
+        .then((result) => {
+          if (!cancelled) setHtml(result);
+        })
+        .catch((e) => {
+          console.error("Render error", e);
+          if (!cancelled) {
+            setHtml('<p style="color:red">Error rendering markdown</p>');
+          }
+        });
+      return () => {
+        cancelled = true;
+      };
     }
   }, [markdown, isReady]);
 
@@ -183,6 +183,20 @@ describe("API functions", () => {
     );
   });
 
+  it("reports the message of a JSON error response", async () => {
+    setFetchFn(
+      fakeFetch(404, {
+        code: "not_found",
+        message: "Note not found",
+        requestId: "req-1",
+      }),
+    );
+
+    await expect(listFiles()).rejects.toThrow(
+      "Failed to list files: Note not found (request req-1)",
+    );
+  });
+
   it("deleteFile succeeds on 2xx", async () => {
     setFetchFn(fakeFetch(200));
 
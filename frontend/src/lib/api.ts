@@ -73,11 +73,52 @@ export interface FileItem {
   size: number;
   parents?: string[];
   starred?: boolean;
+  encrypted?: boolean;
+  // protected notes are encrypted with a passphrase of their own.
+  protected?: boolean;
+  commentCount?: number;
+  orderIndex?: number;
+  color?: string;
+  icon?: string;
+  properties?: Record<string, string>;
+  archived?: boolean;
+  contentHash?: string;
 }
 
-async function handleError(res: Response, defaultMsg: string): Promise<never> {
+export interface DuplicateGroup {
+  contentHash: string;
+  notes: FileItem[];
+}
+
+// ApiError is the JSON body of every error response from the backend.
+export interface ApiError {
+  code: string;
+  message: string;
+  requestId?: string;
+  details?: unknown;
+}
+
+// errorMessage returns the message of an error response, falling back to
+// the raw body for responses that did not come from the backend (e.g. the
+// API Gateway or CloudFront error pages).
+export async function errorMessage(res: Response): Promise<string> {
   const text = await res.text();
-  throw new Error(`${defaultMsg}${text ? `: ${text}` : ""}`);
+  try {
+    const body = JSON.parse(text) as Partial<ApiError>;
+    if (typeof body.message === "string") {
+      return body.requestId
+        ? `${body.message} (request ${body.requestId})`
+        : body.message;
+    }
+  } catch {
+    // Not JSON.
+  }
+  return text;
+}
+
+async function handleError(res: Response, defaultMsg: string): Promise<never> {
+  const message = await errorMessage(res);
+  throw new Error(`${defaultMsg}${message ? `: ${message}` : ""}`);
 }
 
 export async function getHealth(): Promise<{ status: string }> {
@@ -86,8 +127,15 @@ export async function getHealth(): Promise<{ status: string }> {
   return res.json();
 }
 
-export async function listFiles(folderId?: string): Promise<FileItem[]> {
-  const query = folderId ? `?folderId=${encodeURIComponent(folderId)}` : "";
+// property filters by a custom property, written as "key:value".
+export async function listFiles(
+  folderId?: string,
+  property?: string,
+): Promise<FileItem[]> {
+  const params = new URLSearchParams();
+  if (folderId) params.set("folderId", folderId);
+  if (property) params.set("property", property);
+  const query = params.toString() ? `?${params}` : "";
   const res = await apiFetch(`/notes${query}`);
   if (!res.ok) return handleError(res, "Failed to list files");
   return res.json();
@@ -99,6 +147,245 @@ export async function listStarred(): Promise<FileItem[]> {
   return res.json();
 }
 
+export async function listArchived(): Promise<FileItem[]> {
+  const res = await apiFetch("/archive");
+  if (!res.ok) return handleError(res, "Failed to list archived files");
+  return res.json();
+}
+
+export async function listDuplicates(): Promise<DuplicateGroup[]> {
+  const res = await apiFetch("/duplicates");
+  if (!res.ok) return handleError(res, "Failed to find duplicate notes");
+  return res.json();
+}
+
+// Reminder is an open task with a due date ("- [ ] pay rent 📅 2024-06-01"),
+// collected from the user's notes as they are saved.
+export interface Reminder {
+  noteId: string;
+  noteName: string;
+  // taskIndex is the task's index for toggleTask.
+  taskIndex: number;
+  text: string;
+  due: string;
+  notifiedAt?: string;
+}
+
+// listReminders returns open tasks with due dates, soonest first, limited
+// to those due on or before dueBy (YYYY-MM-DD) if given.
+export async function listReminders(dueBy?: string): Promise<Reminder[]> {
+  const query = dueBy ? `?${new URLSearchParams({ dueBy })}` : "";
+  const res = await apiFetch(`/reminders${query}`);
+  if (!res.ok) return handleError(res, "Failed to list reminders");
+  return res.json();
+}
+
+// OpenTask is an unchecked task in one of the user's notes.
+export interface OpenTask {
+  noteId: string;
+  noteName: string;
+  // index is the task's index for toggleTask.
+  index: number;
+  text: string;
+  done: boolean;
+  due?: string;
+  line: number;
+}
+
+// listOpenTasks returns the open tasks across the user's notes, dated
+// tasks first by due date. q narrows the scan to notes matching a
+// full-text search; archived notes are left out unless includeArchived.
+export async function listOpenTasks(
+  q?: string,
+  includeArchived?: boolean,
+): Promise<OpenTask[]> {
+  const params = new URLSearchParams();
+  if (q) params.set("q", q);
+  if (includeArchived) params.set("includeArchived", "true");
+  const query = params.toString() ? `?${params}` : "";
+  const res = await apiFetch(`/tasks${query}`);
+  if (!res.ok) return handleError(res, "Failed to list tasks");
+  return res.json();
+}
+
+// getCalendarFeedURL returns the URL of the user's iCalendar feed of dated
+// tasks, for subscribing from Google or Apple Calendar. The URL carries its
+// own token and works for a year.
+export async function getCalendarFeedURL(): Promise<string> {
+  const res = await apiFetch("/tasks/calendar");
+  if (!res.ok) return handleError(res, "Failed to get calendar link");
+  const { token } = (await res.json()) as { token: string; expiresAt: string };
+  const query = new URLSearchParams({ token });
+  return new URL(
+    `${API_BASE}/tasks/calendar.ics?${query}`,
+    window.location.origin,
+  ).toString();
+}
+
+// Board is a kanban view of a folder or a note. On a folder board each card
+// is a note, placed by the status field of its frontmatter; on a note board
+// each card is a list item under a "## " heading.
+export interface Board {
+  id: string;
+  // etag is set on note boards; pass it to moveNoteBoardCard.
+  etag?: string;
+  columns: BoardColumn[];
+}
+
+// BoardColumn is a column of a board. On a folder board the column named ""
+// holds the notes without a status.
+export interface BoardColumn {
+  name: string;
+  cards: BoardCard[];
+}
+
+// BoardCard is a note on a folder board, or a list item on a note board
+// where id is the item's index.
+export interface BoardCard {
+  id: string;
+  title: string;
+  done?: boolean;
+}
+
+function boardQuery(columns?: string[]): string {
+  return columns?.length
+    ? `?${new URLSearchParams({ columns: columns.join(",") })}`
+    : "";
+}
+
+// getBoard returns the board of a folder. columns fixes the order of those
+// columns and lists them even when empty.
+export async function getBoard(
+  folderId: string,
+  columns?: string[],
+): Promise<Board> {
+  const res = await apiFetch(`/boards/${folderId}${boardQuery(columns)}`);
+  if (!res.ok) return handleError(res, "Failed to load board");
+  return res.json();
+}
+
+// moveBoardCard moves a note to column (clearing its status for "") at
+// position, or to the end, and returns the updated board.
+export async function moveBoardCard(
+  folderId: string,
+  noteId: string,
+  column: string,
+  position?: number,
+  columns?: string[],
+): Promise<Board> {
+  const res = await apiFetch(
+    `/boards/${folderId}/cards/${noteId}${boardQuery(columns)}`,
+    {
+      method: "PATCH",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ column, position }),
+    },
+  );
+  if (!res.ok) return handleError(res, "Failed to move card");
+  return res.json();
+}
+
+// getNoteBoard returns the board of a note.
+export async function getNoteBoard(noteId: string): Promise<Board> {
+  const res = await apiFetch(`/notes/${noteId}/board`);
+  if (!res.ok) return handleError(res, "Failed to load board");
+  return res.json();
+}
+
+// moveNoteBoardCard moves a list item on a note board. etag is the board's
+// etag; the move fails with "Conflict" if the note has changed since.
+export async function moveNoteBoardCard(
+  noteId: string,
+  etag: string,
+  card: number,
+  column: string,
+  position?: number,
+): Promise<Board> {
+  const res = await apiFetch(`/notes/${noteId}/board`, {
+    method: "PATCH",
+    headers: { "Content-Type": "application/json", "If-Match": etag },
+    body: JSON.stringify({ card, column, position }),
+  });
+  if (res.status === 412) {
+    throw new Error("Conflict");
+  }
+  if (!res.ok) return handleError(res, "Failed to move card");
+  return res.json();
+}
+
+// NoteSummary is a model-written summary of a note. etag is the version
+// that was summarized; truncated is set when only the start of a long note
+// was read.
+export interface NoteSummary {
+  summary: string;
+  etag: string;
+  truncated?: boolean;
+}
+
+// summarizeNote asks the server for a summary of a note. The endpoint only
+// exists when the deployment has a summary model configured, so a 404 means
+// summaries are off (or the note is gone).
+export async function summarizeNote(noteId: string): Promise<NoteSummary> {
+  const res = await apiFetch(`/notes/${noteId}/summarize`, { method: "POST" });
+  if (!res.ok) return handleError(res, "Failed to summarize note");
+  return res.json();
+}
+
+// NoteSuggestion is a model-written title and tags for a note, for the
+// version in etag. Tags are lower-case words joined with "-".
+export interface NoteSuggestion {
+  title: string;
+  tags: string[];
+  etag: string;
+}
+
+// suggestNote asks the server for a title and tags for a note. Apply the
+// ones the user accepts with renameNote and the note's "tags" frontmatter
+// field (setFrontmatterField in the Wasm module). Like summarizeNote, it
+// is only available when a summary model is configured.
+export async function suggestNote(noteId: string): Promise<NoteSuggestion> {
+  const res = await apiFetch(`/notes/${noteId}/suggest`, { method: "POST" });
+  if (!res.ok) return handleError(res, "Failed to suggest title and tags");
+  return res.json();
+}
+
+// NoteTranslation is a note translated into lang, for the version in etag.
+// note is the new sibling note when the translation was saved.
+export interface NoteTranslation {
+  lang: string;
+  content: string;
+  etag: string;
+  note?: FileItem;
+}
+
+// translateNote translates a note into lang, a language tag such as "ja"
+// or "pt-BR", keeping its Markdown structure. With save, the translation is
+// also created as a new note next to the original. Like summarizeNote, it
+// is only available when a summary model is configured.
+export async function translateNote(
+  noteId: string,
+  lang: string,
+  save?: boolean,
+): Promise<NoteTranslation> {
+  const params = new URLSearchParams({ lang });
+  if (save) params.set("save", "true");
+  const res = await apiFetch(`/notes/${noteId}/translate?${params}`, {
+    method: "POST",
+  });
+  if (!res.ok) return handleError(res, "Failed to translate note");
+  return res.json();
+}
+
+export async function reorderStarred(ids: string[]): Promise<FileItem[]> {
+  const res = await apiFetch("/starred/order", {
+    method: "PATCH",
+    body: JSON.stringify({ ids }),
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to reorder starred files");
+  return res.json();
+}
+
 export async function getFile(fileId: string): Promise<FileItem> {
   const res = await apiFetch(`/notes/${fileId}`);
   if (!res.ok) return handleError(res, "Failed to fetch file");
@@ -184,6 +471,47 @@ export async function starFile(
   return res.json();
 }
 
+export async function setArchived(
+  id: string,
+  archived: boolean,
+): Promise<FileItem> {
+  const res = await apiFetch(`/notes/${id}`, {
+    method: "PATCH",
+    body: JSON.stringify({ archived }),
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to update archived status");
+  return res.json();
+}
+
+// Pass an empty string to clear the color or icon; omitted ones are kept.
+export async function setFolderAppearance(
+  id: string,
+  appearance: { color?: string; icon?: string },
+): Promise<FileItem> {
+  const res = await apiFetch(`/notes/${id}`, {
+    method: "PATCH",
+    body: JSON.stringify(appearance),
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to update folder appearance");
+  return res.json();
+}
+
+// An empty value removes that property; keys not listed are kept.
+export async function setProperties(
+  id: string,
+  properties: Record<string, string>,
+): Promise<FileItem> {
+  const res = await apiFetch(`/notes/${id}`, {
+    method: "PATCH",
+    body: JSON.stringify({ properties }),
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to update properties");
+  return res.json();
+}
+
 export async function deleteFile(fileId: string): Promise<void> {
   const res = await apiFetch(`/notes/${fileId}/delete`, {
     method: "POST",
@@ -191,6 +519,153 @@ export async function deleteFile(fileId: string): Promise<void> {
   if (!res.ok) return handleError(res, "Failed to delete file");
 }
 
+// Comment is a review comment on a note. Replies carry the ID of the
+// top-level comment of their thread in parentId; a deleted top-level
+// comment with replies is kept with deleted set and an empty body.
+export interface Comment {
+  id: string;
+  noteId: string;
+  parentId?: string;
+  authorId: string;
+  authorName?: string;
+  body: string;
+  createdAt: string;
+  deleted?: boolean;
+}
+
+export async function listComments(noteId: string): Promise<Comment[]> {
+  const res = await apiFetch(`/notes/${noteId}/comments`);
+  if (!res.ok) return handleError(res, "Failed to list comments");
+  return res.json();
+}
+
+export async function addComment(
+  noteId: string,
+  body: string,
+  parentId?: string,
+): Promise<Comment> {
+  const res = await apiFetch(`/notes/${noteId}/comments`, {
+    method: "POST",
+    body: JSON.stringify({ body, parentId }),
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to add comment");
+  return res.json();
+}
+
+export async function deleteComment(
+  noteId: string,
+  commentId: string,
+): Promise<void> {
+  const res = await apiFetch(`/notes/${noteId}/comments/${commentId}/delete`, {
+    method: "POST",
+  });
+  if (!res.ok) return handleError(res, "Failed to delete comment");
+}
+
+export interface Draft {
+  noteId: string;
+  content: string;
+  baseEtag?: string;
+  savedAt: string;
+  // stale is set when the note was saved after the draft was started.
+  stale?: boolean;
+}
+
+// Returns null when there is no draft of the note.
+export async function getDraft(noteId: string): Promise<Draft | null> {
+  const res = await apiFetch(`/notes/${noteId}/draft`);
+  if (res.status === 404) return null;
+  if (!res.ok) return handleError(res, "Failed to load draft");
+  return res.json();
+}
+
+export async function saveDraft(
+  noteId: string,
+  content: string,
+  baseEtag?: string,
+  encrypted?: boolean,
+): Promise<Draft> {
+  const res = await apiFetch(`/notes/${noteId}/draft`, {
+    method: "PUT",
+    body: JSON.stringify({ content, baseEtag, encrypted }),
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to save draft");
+  return res.json();
+}
+
+export async function discardDraft(noteId: string): Promise<void> {
+  const res = await apiFetch(`/notes/${noteId}/draft/delete`, {
+    method: "POST",
+  });
+  if (!res.ok) return handleError(res, "Failed to discard draft");
+}
+
+export interface Publication {
+  noteId: string;
+  name: string;
+  content: string;
+  sourceEtag: string;
+  // publishAt is set while the publication is scheduled; publishedAt is
+  // set once it is live.
+  publishAt?: string;
+  publishedAt?: string;
+  // shareToken goes in the public page URL; links last 90 days.
+  shareToken: string;
+  // stale is set when the note was edited after it was published.
+  stale: boolean;
+}
+
+export interface PublicNote {
+  name: string;
+  content: string;
+  // html is content rendered by the server without raw HTML or unsafe URLs.
+  html: string;
+  publishedAt: string;
+}
+
+// Returns null when the note is not published.
+export async function getPublication(
+  noteId: string,
+): Promise<Publication | null> {
+  const res = await apiFetch(`/notes/${noteId}/publish`);
+  if (res.status === 404) return null;
+  if (!res.ok) return handleError(res, "Failed to load publication");
+  return res.json();
+}
+
+// Publishing again replaces the public snapshot with the current content.
+// publishNote publishes the note now, or at publishAt if given. A scheduled
+// publication replaces the current one, which stops being served.
+export async function publishNote(
+  noteId: string,
+  publishAt?: Date,
+): Promise<Publication> {
+  const res = await apiFetch(`/notes/${noteId}/publish`, {
+    method: "POST",
+    body: publishAt
+      ? JSON.stringify({ publishAt: publishAt.toISOString() })
+      : undefined,
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to publish note");
+  return res.json();
+}
+
+export async function unpublishNote(noteId: string): Promise<void> {
+  const res = await apiFetch(`/notes/${noteId}/unpublish`, {
+    method: "POST",
+  });
+  if (!res.ok) return handleError(res, "Failed to unpublish note");
+}
+
+export async function getPublicNote(shareToken: string): Promise<PublicNote> {
+  const res = await apiFetch(`/public/${encodeURIComponent(shareToken)}`);
+  if (!res.ok) return handleError(res, "Failed to load shared note");
+  return res.json();
+}
+
 export interface BreadcrumbItem {
   id: string;
   name: string;
@@ -278,8 +753,175 @@ export async function getUser(): Promise<User> {
   return res.json();
 }
 
-export async function searchFiles(query: string): Promise<FileItem[]> {
-  const res = await apiFetch(`/search?q=${encodeURIComponent(query)}`);
+// AccessToken is a personal access token for scripts and gophdrive-cli.
+// The secret token is only returned once, when the token is created.
+export interface AccessToken {
+  id: string;
+  name: string;
+  createdAt: string;
+  expiresAt: string;
+}
+
+export async function listAccessTokens(): Promise<AccessToken[]> {
+  const res = await apiFetch("/auth/tokens");
+  if (!res.ok) return handleError(res, "Failed to list access tokens");
+  return res.json();
+}
+
+// expiresInDays defaults to 90 on the server and can be at most 365.
+export async function createAccessToken(
+  name: string,
+  expiresInDays?: number,
+): Promise<AccessToken & { token: string }> {
+  const res = await apiFetch("/auth/tokens", {
+    method: "POST",
+    body: JSON.stringify({ name, expiresInDays }),
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to create access token");
+  return res.json();
+}
+
+export async function deleteAccessToken(tokenId: string): Promise<void> {
+  const res = await apiFetch(`/auth/tokens/${tokenId}/delete`, {
+    method: "POST",
+  });
+  if (!res.ok) return handleError(res, "Failed to revoke access token");
+}
+
+// GitRemote is the repository notes are exported to. Its token is never
+// returned.
+export interface GitRemote {
+  provider: "github" | "gitlab";
+  repository: string;
+  branch: string;
+  updatedAt: string;
+  lastExportAt?: string;
+  lastCommit?: string;
+}
+
+// GitExport reports an export: one commit per changed note. Remaining
+// changes are made by the next export; skipped paths are held by more than
+// one note or folder.
+export interface GitExport {
+  commits: { path: string; action: "add" | "update" | "delete"; sha: string }[];
+  unchanged: number;
+  remaining: number;
+  skipped: string[];
+}
+
+export async function getGitRemote(): Promise<GitRemote | null> {
+  const res = await apiFetch("/export/git/remote");
+  if (res.status === 404) return null;
+  if (!res.ok) return handleError(res, "Failed to load Git remote");
+  return res.json();
+}
+
+// Leave token out to keep the current one.
+export async function setGitRemote(remote: {
+  provider: "github" | "gitlab";
+  repository: string;
+  branch?: string;
+  token?: string;
+}): Promise<GitRemote> {
+  const res = await apiFetch("/export/git/remote", {
+    method: "PUT",
+    body: JSON.stringify(remote),
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to save Git remote");
+  return res.json();
+}
+
+export async function deleteGitRemote(): Promise<void> {
+  const res = await apiFetch("/export/git/remote/delete", { method: "POST" });
+  if (!res.ok) return handleError(res, "Failed to remove Git remote");
+}
+
+export async function exportToGit(): Promise<GitExport> {
+  const res = await apiFetch("/export/git", { method: "POST" });
+  if (!res.ok) return handleError(res, "Failed to export to Git");
+  return res.json();
+}
+
+// Backup copies of the user's notes; enabled is false when the server does
+// not mirror notes.
+export interface MirrorStatus {
+  enabled: boolean;
+  synced: number;
+  pending: number;
+  failed: number;
+  lastMirroredAt?: string;
+  failures: {
+    noteId: string;
+    name: string;
+    status: "failed";
+    error?: string;
+    attempts?: number;
+    updatedAt: string;
+  }[];
+}
+
+export async function getMirrorStatus(): Promise<MirrorStatus> {
+  const res = await apiFetch("/mirror/status");
+  if (!res.ok) return handleError(res, "Failed to load mirror status");
+  return res.json();
+}
+
+// A note or folder shared between users. Write permission lets the user it
+// is shared with save the note, or the notes in the folder.
+export interface Share {
+  noteId: string;
+  email: string;
+  ownerName?: string;
+  ownerEmail?: string;
+  name: string;
+  folder?: boolean;
+  permission: "read" | "write";
+  createdAt: string;
+}
+
+export async function listShares(noteId: string): Promise<Share[]> {
+  const res = await apiFetch(`/notes/${noteId}/shares`);
+  if (!res.ok) return handleError(res, "Failed to load shares");
+  return res.json();
+}
+
+export async function shareNote(
+  noteId: string,
+  email: string,
+  permission: Share["permission"],
+): Promise<Share> {
+  const res = await apiFetch(`/notes/${noteId}/shares`, {
+    method: "PUT",
+    body: JSON.stringify({ email, permission }),
+    headers: { "Content-Type": "application/json" },
+  });
+  if (!res.ok) return handleError(res, "Failed to share note");
+  return res.json();
+}
+
+export async function unshareNote(noteId: string, email: string): Promise<void> {
+  const res = await apiFetch(
+    `/notes/${noteId}/shares/${encodeURIComponent(email)}/delete`,
+    { method: "POST" },
+  );
+  if (!res.ok) return handleError(res, "Failed to stop sharing note");
+}
+
+export async function listSharedWithMe(): Promise<Share[]> {
+  const res = await apiFetch("/shared-with-me");
+  if (!res.ok) return handleError(res, "Failed to load shared notes");
+  return res.json();
+}
+
+export async function searchFiles(
+  query: string,
+  property?: string,
+): Promise<FileItem[]> {
+  const params = new URLSearchParams({ q: query });
+  if (property) params.set("property", property);
+  const res = await apiFetch(`/search?${params}`);
   if (!res.ok) return handleError(res, "Failed to search files");
   return res.json();
 }
@@ -1,13 +1,238 @@
+export interface RenderOptions {
+  hardWraps?: boolean;
+  unsafeHTML?: boolean;
+  headingIDs?: boolean;
+  typographer?: boolean;
+  unicodeSlugs?: boolean;
+  baseURL?: string;
+  attachmentURL?: string;
+  theme?: string;
+  darkTheme?: string;
+  inlineStyles?: boolean;
+}
+
+// cursor is a UTF-16 index into text, or -1 when the template has no
+// {{cursor}}.
+export interface TemplateExpansion {
+  text: string;
+  cursor: number;
+}
+
+// Synchronous bridge functions never throw; exactly one of the value and
+// error fields is non-null.
+export interface HTMLResult {
+  html: string | null;
+  error: string | null;
+}
+
+export interface BridgeResult<T> {
+  result: T | null;
+  error: string | null;
+}
+
 declare global {
   interface Window {
     // eslint-disable-next-line @typescript-eslint/no-explicit-any
     Go: any;
-    renderMarkdown: (source: string) => string;
-    checkConflict: (localEtag: string, remoteEtag: string) => boolean;
+    renderMarkdown: (
+      source: string,
+      options?: boolean | RenderOptions,
+    ) => HTMLResult;
+    renderDiff: (oldSource: string, newSource: string) => HTMLResult;
+    renderText: (source: string) => BridgeResult<string>;
+    setRenderOptions: (optionsJSON: string) => BridgeResult<string>;
+    getNoteStats: (source: string) => BridgeResult<string>;
+    extractWikiLinks: (source: string) => BridgeResult<string>;
+    formatMarkdown: (
+      source: string,
+      rulesJSON?: string,
+    ) => BridgeResult<string>;
+    toggleTask: (source: string, index: number) => BridgeResult<string>;
+    extractTasks: (source: string) => BridgeResult<string>;
+    extractLinks: (source: string) => BridgeResult<string>;
+    getSection: (source: string, headingSlug: string) => BridgeResult<string>;
+    extractTOC: (source: string) => BridgeResult<string>;
+    lintMarkdown: (source: string) => BridgeResult<string>;
+    validateMermaid: (source: string) => BridgeResult<string>;
+    setFrontmatterField: (
+      source: string,
+      key: string,
+      valueJSON: string,
+    ) => BridgeResult<string>;
+    removeFrontmatterField: (
+      source: string,
+      key: string,
+    ) => BridgeResult<string>;
+    expandTemplate: (
+      source: string,
+      contextJSON?: string,
+    ) => BridgeResult<TemplateExpansion>;
+    buildIndex: (docsJSON: string) => BridgeResult<number>;
+    search: (query: string, limit?: number) => BridgeResult<string>;
+    deriveKey: (
+      passphrase: string,
+      saltBase64?: string,
+    ) => BridgeResult<string>;
+    encryptData: (keyBase64: string, plaintext: string) => BridgeResult<string>;
+    decryptData: (
+      keyBase64: string,
+      ciphertext: string,
+    ) => BridgeResult<string>;
+    sealNote: (passphrase: string, plaintext: string) => BridgeResult<string>;
+    openNote: (passphrase: string, sealed: string) => BridgeResult<string>;
+    isSealedNote: (content: string) => BridgeResult<boolean>;
+    isProtectedNote: (content: string) => BridgeResult<boolean>;
+    // Protected notes: the key derived from the passphrase stays in Wasm,
+    // keyed by note ID, until lockNote.
+    protectNote: (
+      noteId: string,
+      passphrase: string,
+      plaintext: string,
+    ) => BridgeResult<string>;
+    unlockNote: (
+      noteId: string,
+      passphrase: string,
+      sealed: string,
+    ) => BridgeResult<string>;
+    sealUnlockedNote: (
+      noteId: string,
+      plaintext: string,
+    ) => BridgeResult<string>;
+    isNoteUnlocked: (noteId: string) => BridgeResult<boolean>;
+    lockNote: (noteId: string) => BridgeResult<null>;
+    checkConflict: (
+      localEtag: string,
+      remoteEtag: string,
+    ) => BridgeResult<boolean>;
     createOfflineChange: (
       noteID: string,
       content: string,
-    ) => { noteId: string; content: string; timestamp: number };
+    ) => BridgeResult<{
+      noteId: string;
+      content: string;
+      timestamp: number;
+    }>;
+    mergeNotes: (
+      base: string,
+      local: string,
+      remote: string,
+    ) => BridgeResult<string>;
+    diffNotes: (a: string, b: string) => BridgeResult<string>;
+    queueAdd: (
+      queueJSON: string,
+      noteID: string,
+      content: string,
+      baseEtag: string,
+    ) => BridgeResult<string>;
+    queueRemove: (queueJSON: string, noteID: string) => BridgeResult<string>;
+    queueLength: (queueJSON: string) => BridgeResult<number>;
+    crdtInsert: (
+      state: string,
+      replica: string,
+      pos: number,
+      text: string,
+    ) => BridgeResult<{ state: string; ops: string }>;
+    crdtDelete: (
+      state: string,
+      replica: string,
+      pos: number,
+      count: number,
+    ) => BridgeResult<{ state: string; ops: string }>;
+    crdtApply: (state: string, opsJSON: string) => BridgeResult<string>;
+    crdtMerge: (stateA: string, stateB: string) => BridgeResult<string>;
+    crdtText: (state: string) => BridgeResult<string>;
+
+    // Promise-returning variants run on a goroutine, resolve with the bare
+    // value and reject with an Error.
+    renderMarkdownAsync: (
+      source: string,
+      options?: boolean | RenderOptions,
+    ) => Promise<string>;
+    renderDiffAsync: (oldSource: string, newSource: string) => Promise<string>;
+    renderTextAsync: (source: string) => Promise<string>;
+    setRenderOptionsAsync: (optionsJSON: string) => Promise<string>;
+    getNoteStatsAsync: (source: string) => Promise<string>;
+    extractWikiLinksAsync: (source: string) => Promise<string>;
+    formatMarkdownAsync: (
+      source: string,
+      rulesJSON?: string,
+    ) => Promise<string>;
+    toggleTaskAsync: (source: string, index: number) => Promise<string>;
+    extractTasksAsync: (source: string) => Promise<string>;
+    extractLinksAsync: (source: string) => Promise<string>;
+    getSectionAsync: (source: string, headingSlug: string) => Promise<string>;
+    extractTOCAsync: (source: string) => Promise<string>;
+    lintMarkdownAsync: (source: string) => Promise<string>;
+    validateMermaidAsync: (source: string) => Promise<string>;
+    setFrontmatterFieldAsync: (
+      source: string,
+      key: string,
+      valueJSON: string,
+    ) => Promise<string>;
+    removeFrontmatterFieldAsync: (
+      source: string,
+      key: string,
+    ) => Promise<string>;
+    expandTemplateAsync: (
+      source: string,
+      contextJSON?: string,
+    ) => Promise<TemplateExpansion>;
+    buildIndexAsync: (docsJSON: string) => Promise<number>;
+    searchAsync: (query: string, limit?: number) => Promise<string>;
+    deriveKeyAsync: (
+      passphrase: string,
+      saltBase64?: string,
+    ) => Promise<string>;
+    encryptDataAsync: (keyBase64: string, plaintext: string) => Promise<string>;
+    decryptDataAsync: (
+      keyBase64: string,
+      ciphertext: string,
+    ) => Promise<string>;
+    sealNoteAsync: (passphrase: string, plaintext: string) => Promise<string>;
+    openNoteAsync: (passphrase: string, sealed: string) => Promise<string>;
+    isSealedNoteAsync: (content: string) => Promise<boolean>;
+    isProtectedNoteAsync: (content: string) => Promise<boolean>;
+    protectNoteAsync: (
+      noteId: string,
+      passphrase: string,
+      plaintext: string,
+    ) => Promise<string>;
+    unlockNoteAsync: (
+      noteId: string,
+      passphrase: string,
+      sealed: string,
+    ) => Promise<string>;
+    sealUnlockedNoteAsync: (noteId: string, plaintext: string) => Promise<string>;
+    isNoteUnlockedAsync: (noteId: string) => Promise<boolean>;
+    lockNoteAsync: (noteId: string) => Promise<null>;
+    mergeNotesAsync: (
+      base: string,
+      local: string,
+      remote: string,
+    ) => Promise<string>;
+    diffNotesAsync: (a: string, b: string) => Promise<string>;
+    queueAddAsync: (
+      queueJSON: string,
+      noteID: string,
+      content: string,
+      baseEtag: string,
+    ) => Promise<string>;
+    queueRemoveAsync: (queueJSON: string, noteID: string) => Promise<string>;
+    crdtInsertAsync: (
+      state: string,
+      replica: string,
+      pos: number,
+      text: string,
+    ) => Promise<{ state: string; ops: string }>;
+    crdtDeleteAsync: (
+      state: string,
+      replica: string,
+      pos: number,
+      count: number,
+    ) => Promise<{ state: string; ops: string }>;
+    crdtApplyAsync: (state: string, opsJSON: string) => Promise<string>;
+    crdtMergeAsync: (stateA: string, stateB: string) => Promise<string>;
+    crdtTextAsync: (state: string) => Promise<string>;
   }
 }
 
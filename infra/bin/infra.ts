@@ -33,6 +33,16 @@ const computeStack = new ComputeStack(app, "GophDriveComputeStack", {
   userTokensTable: databaseStack.userTokensTable,
   editingSessionsTable: databaseStack.editingSessionsTable,
   fileStoreTable: databaseStack.fileStoreTable,
+  changeJournalTable: databaseStack.changeJournalTable,
+  commentsTable: databaseStack.commentsTable,
+  draftsTable: databaseStack.draftsTable,
+  publicationsTable: databaseStack.publicationsTable,
+  remindersTable: databaseStack.remindersTable,
+  accessTokensTable: databaseStack.accessTokensTable,
+  gitRemotesTable: databaseStack.gitRemotesTable,
+  sharesTable: databaseStack.sharesTable,
+  mirrorsTable: databaseStack.mirrorsTable,
+  mirrorBucket: databaseStack.mirrorBucket,
   tokenEncryptionKey: securityStack.tokenEncryptionKey,
 });
 
@@ -3,8 +3,11 @@ import { Construct } from "constructs";
 import * as lambda from "aws-cdk-lib/aws-lambda";
 import * as apigateway from "aws-cdk-lib/aws-apigateway";
 import * as dynamodb from "aws-cdk-lib/aws-dynamodb";
+import * as events from "aws-cdk-lib/aws-events";
+import * as targets from "aws-cdk-lib/aws-events-targets";
 import * as iam from "aws-cdk-lib/aws-iam";
 import * as kms from "aws-cdk-lib/aws-kms";
+import * as s3 from "aws-cdk-lib/aws-s3";
 import * as path from "path";
 import { execSync } from "child_process";
 
@@ -12,6 +15,16 @@ interface ComputeStackProps extends cdk.StackProps {
   userTokensTable: dynamodb.Table;
   editingSessionsTable: dynamodb.Table;
   fileStoreTable: dynamodb.Table;
+  changeJournalTable: dynamodb.Table;
+  commentsTable: dynamodb.Table;
+  draftsTable: dynamodb.Table;
+  publicationsTable: dynamodb.Table;
+  remindersTable: dynamodb.Table;
+  accessTokensTable: dynamodb.Table;
+  gitRemotesTable: dynamodb.Table;
+  sharesTable: dynamodb.Table;
+  mirrorsTable: dynamodb.Table;
+  mirrorBucket: s3.IBucket;
   tokenEncryptionKey: kms.Key;
 }
 
@@ -26,9 +39,14 @@ export class ComputeStack extends cdk.Stack {
       runtime: lambda.Runtime.PROVIDED_AL2023,
       handler: "bootstrap",
       architecture: lambda.Architecture.ARM_64,
-      code: lambda.Code.fromAsset(path.join(__dirname, "../../backend"), {
+      // The backend imports ../core, so the asset is the repository root
+      // with everything but those two directories left out.
+      code: lambda.Code.fromAsset(path.join(__dirname, "../.."), {
+        exclude: ["*", "!backend", "!core"],
+        ignoreMode: cdk.IgnoreMode.DOCKER,
         bundling: {
           image: lambda.Runtime.PROVIDED_AL2023.bundlingImage,
+          workingDirectory: "/asset-input/backend",
           command: [
             "bash",
             "-c",
@@ -56,24 +74,143 @@ export class ComputeStack extends cdk.Stack {
         USER_TOKENS_TABLE: props.userTokensTable.tableName,
         EDITING_SESSIONS_TABLE: props.editingSessionsTable.tableName,
         FILE_STORE_TABLE: props.fileStoreTable.tableName,
+        CHANGE_JOURNAL_TABLE: props.changeJournalTable.tableName,
+        COMMENTS_TABLE: props.commentsTable.tableName,
+        DRAFTS_TABLE: props.draftsTable.tableName,
+        PUBLICATIONS_TABLE: props.publicationsTable.tableName,
+        REMINDERS_TABLE: props.remindersTable.tableName,
+        ACCESS_TOKENS_TABLE: props.accessTokensTable.tableName,
+        GIT_REMOTES_TABLE: props.gitRemotesTable.tableName,
+        SHARES_TABLE: props.sharesTable.tableName,
+        MIRRORS_TABLE: props.mirrorsTable.tableName,
+        MIRROR_S3_BUCKET: props.mirrorBucket.bucketName,
         KMS_KEY_ID: props.tokenEncryptionKey.keyId,
         GOOGLE_CLIENT_ID: process.env.GOOGLE_CLIENT_ID || "",
         GOOGLE_CLIENT_SECRET_PARAM: "/gophdrive/google-client-secret",
         JWT_SECRET_PARAM: "/gophdrive/jwt-secret",
         API_GATEWAY_SECRET_PARAM: "/gophdrive/api-gateway-secret",
+        SIGNING_KEYS_PARAM: "/gophdrive/signing-keys",
         FRONTEND_URL: process.env.FRONTEND_URL || "http://localhost:3000",
         GOOGLE_REDIRECT_URL: `${process.env.FRONTEND_URL || "http://localhost:3000"}/api/auth/callback`,
       },
       timeout: cdk.Duration.seconds(30),
       memorySize: 128,
+      // X-Ray segments for each invocation; the backend's own spans nest
+      // under them when the ADOT collector layer is attached.
+      tracing: lambda.Tracing.ACTIVE,
     });
 
+    // Extra browser origins allowed to call the API, e.g. a staging
+    // frontend: comma-separated, wildcard subdomains allowed. The backend
+    // defaults to FRONTEND_URL.
+    const corsAllowedOrigins = process.env.CORS_ALLOWED_ORIGINS;
+    if (corsAllowedOrigins) {
+      backendFunction.addEnvironment("CORS_ALLOWED_ORIGINS", corsAllowedOrigins);
+    }
+
+    // Reminder notifications: a webhook for the deployment and/or email
+    // through an SMTP server. The SMTP password is read from SSM
+    // (/gophdrive/reminder-smtp-password) when a username is set.
+    for (const name of [
+      "REMINDER_WEBHOOK_URL",
+      "REMINDER_SMTP_ADDR",
+      "REMINDER_EMAIL_FROM",
+      "REMINDER_SMTP_USERNAME",
+    ]) {
+      const value = process.env[name];
+      if (value) {
+        backendFunction.addEnvironment(name, value);
+      }
+    }
+
+    // Note summaries, title/tag suggestions and translation (POST
+    // /notes/{id}/summarize, /suggest, /translate) through Amazon Bedrock.
+    // They stay off unless SUMMARY_MODEL_ID names a model or inference
+    // profile the account has been granted access to.
+    // SUMMARY_LIMIT_PER_HOUR caps them per user (default 20).
+    const summaryModelId = process.env.SUMMARY_MODEL_ID;
+    if (summaryModelId) {
+      backendFunction.addEnvironment("SUMMARY_MODEL_ID", summaryModelId);
+      const summaryLimit = process.env.SUMMARY_LIMIT_PER_HOUR;
+      if (summaryLimit) {
+        backendFunction.addEnvironment("SUMMARY_LIMIT_PER_HOUR", summaryLimit);
+      }
+      // Cross-region inference profiles route to the foundation model in
+      // any of their regions.
+      backendFunction.addToRolePolicy(
+        new iam.PolicyStatement({
+          actions: ["bedrock:InvokeModel"],
+          resources: [
+            "arn:aws:bedrock:*::foundation-model/*",
+            `arn:aws:bedrock:${this.region}:${this.account}:inference-profile/*`,
+          ],
+        }),
+      );
+    }
+
+    // ADOT collector layer: receives OTLP spans on localhost:4318 and
+    // forwards them to X-Ray. The layer ARN is region-specific, e.g.
+    // arn:aws:lambda:<region>:901920570463:layer:aws-otel-collector-arm64-ver-<version>:<n>
+    const adotLayerArn = process.env.ADOT_COLLECTOR_LAYER_ARN;
+    if (adotLayerArn) {
+      backendFunction.addLayers(
+        lambda.LayerVersion.fromLayerVersionArn(
+          this,
+          "AdotCollectorLayer",
+          adotLayerArn,
+        ),
+      );
+      backendFunction.addEnvironment(
+        "OTEL_EXPORTER_OTLP_ENDPOINT",
+        "http://localhost:4318",
+      );
+      backendFunction.addEnvironment("OTEL_SERVICE_NAME", "gophdrive-backend");
+    }
+
     // Grant Permissions
     props.userTokensTable.grantReadWriteData(backendFunction);
     props.editingSessionsTable.grantReadWriteData(backendFunction);
     props.fileStoreTable.grantReadWriteData(backendFunction);
+    props.changeJournalTable.grantReadWriteData(backendFunction);
+    props.commentsTable.grantReadWriteData(backendFunction);
+    props.draftsTable.grantReadWriteData(backendFunction);
+    props.publicationsTable.grantReadWriteData(backendFunction);
+    props.remindersTable.grantReadWriteData(backendFunction);
+    props.accessTokensTable.grantReadWriteData(backendFunction);
+    props.gitRemotesTable.grantReadWriteData(backendFunction);
+    props.sharesTable.grantReadWriteData(backendFunction);
+    props.mirrorsTable.grantReadWriteData(backendFunction);
+    props.mirrorBucket.grantReadWrite(backendFunction);
     props.tokenEncryptionKey.grantEncryptDecrypt(backendFunction);
 
+    // Retired token encryption keys, comma-separated key IDs or ARNs: refresh
+    // and Git tokens encrypted under them still decrypt and are re-encrypted
+    // under the current key when next read.
+    const previousKeyIds = (process.env.KMS_PREVIOUS_KEY_IDS ?? "")
+      .split(",")
+      .map((id) => id.trim())
+      .filter((id) => id !== "");
+    if (previousKeyIds.length > 0) {
+      backendFunction.addEnvironment(
+        "KMS_PREVIOUS_KEY_IDS",
+        previousKeyIds.join(","),
+      );
+      backendFunction.addToRolePolicy(
+        new iam.PolicyStatement({
+          actions: ["kms:Decrypt"],
+          resources: previousKeyIds.map((id) =>
+            id.startsWith("arn:")
+              ? id
+              : this.formatArn({
+                  service: "kms",
+                  resource: "key",
+                  resourceName: id,
+                }),
+          ),
+        }),
+      );
+    }
+
     // Grant SSM Parameter Store read access for secrets
     backendFunction.addToRolePolicy(
       new iam.PolicyStatement({
@@ -85,10 +222,33 @@ export class ComputeStack extends cdk.Stack {
       }),
     );
 
+    // Scheduled jobs (releasing scheduled publications, sending reminders). The backend
+    // recognizes EventBridge events; keep the rate in step with
+    // app.ScheduleInterval.
+    new events.Rule(this, "ScheduledJobsRule", {
+      description: "Runs the GophDrive backend's scheduled jobs",
+      schedule: events.Schedule.rate(cdk.Duration.minutes(5)),
+      targets: [new targets.LambdaFunction(backendFunction)],
+    });
+
     // API Gateway
     this.api = new apigateway.RestApi(this, "GophDriveAPI", {
       restApiName: "GophDrive API",
       description: "API for GophDrive Backend",
+      // gzip responses (e.g. /sync/manifest) for clients sending Accept-Encoding
+      minCompressionSize: cdk.Size.kibibytes(1),
+      // Downloads the backend base64-encodes (IsBase64Encoded). API Gateway
+      // decodes them for requests whose Accept header lists one of these
+      // types; any other type would also arrive base64-encoded in request
+      // bodies, so JSON is left out. API Gateway gzips JSON itself.
+      binaryMediaTypes: [
+        "application/zip",
+        "application/pdf",
+        "application/octet-stream",
+      ],
+      deployOptions: {
+        tracingEnabled: true,
+      },
       defaultCorsPreflightOptions: {
         allowOrigins: apigateway.Cors.ALL_ORIGINS,
         allowMethods: apigateway.Cors.ALL_METHODS,
@@ -1,13 +1,25 @@
 import * as cdk from "aws-cdk-lib";
 import * as dynamodb from "aws-cdk-lib/aws-dynamodb";
+import * as s3 from "aws-cdk-lib/aws-s3";
 import { Construct } from "constructs";
 
 /**
  * DatabaseStack
  *
- * Defines the DynamoDB tables for GophDrive:
+ * Defines the DynamoDB tables for GophDrive, and the S3 bucket of note
+ * mirrors:
  * - UserTokens: Stores encrypted OAuth2 refresh tokens per user.
  * - EditingSessions: Manages file-level edit session locks with TTL.
+ * - ChangeJournal: Per-user note modification journal with TTL.
+ * - Comments: Threaded review comments on notes.
+ * - Drafts: Per-user autosave drafts of notes with TTL.
+ * - Publications: Published snapshots of notes for public share pages.
+ * - Reminders: Due dates of open tasks, indexed from saved notes.
+ * - AccessTokens: Personal access tokens for scripts and the CLI, with TTL.
+ * - GitRemotes: Per-user Git remote for exporting notes, with an encrypted token.
+ * - Shares: Notes and folders shared with other users by email address.
+ * - Mirrors: State of each note's backup copy in the mirror bucket.
+ * - MirrorBucket: Backup copies of every user's notes.
  */
 export class DatabaseStack extends cdk.Stack {
   /** UserTokens table — stores encrypted refresh tokens. */
@@ -19,6 +31,36 @@ export class DatabaseStack extends cdk.Stack {
   /** FileStore table — storage for Demo Mode files with TTL. */
   public readonly fileStoreTable: dynamodb.Table;
 
+  /** ChangeJournal table — note modification journal with TTL. */
+  public readonly changeJournalTable: dynamodb.Table;
+
+  /** Comments table — review comments and per-note comment counts. */
+  public readonly commentsTable: dynamodb.Table;
+
+  /** Drafts table — unsaved note drafts with TTL. */
+  public readonly draftsTable: dynamodb.Table;
+
+  /** Publications table — published note snapshots. */
+  public readonly publicationsTable: dynamodb.Table;
+
+  /** Reminders table — task due dates and notification state. */
+  public readonly remindersTable: dynamodb.Table;
+
+  /** AccessTokens table — personal access token descriptions with TTL. */
+  public readonly accessTokensTable: dynamodb.Table;
+
+  /** GitRemotes table — Git export settings with encrypted host tokens. */
+  public readonly gitRemotesTable: dynamodb.Table;
+
+  /** Shares table — notes and folders shared between users. */
+  public readonly sharesTable: dynamodb.Table;
+
+  /** Mirrors table — mirror copy status per note. */
+  public readonly mirrorsTable: dynamodb.Table;
+
+  /** Mirror bucket — backup copies of notes. */
+  public readonly mirrorBucket: s3.Bucket;
+
   constructor(scope: Construct, id: string, props?: cdk.StackProps) {
     super(scope, id, props);
 
@@ -46,6 +88,7 @@ export class DatabaseStack extends cdk.Stack {
     // --------------------------------------------------------------------------
     // PK: file_id (string)
     // Attributes: user_id, expires_at (TTL)
+    // GSI: user_id-index (PK: user_id) for listing a user's own locks.
     // TTL automatically removes expired session locks.
     // ==========================================================================
     this.editingSessionsTable = new dynamodb.Table(
@@ -61,6 +104,13 @@ export class DatabaseStack extends cdk.Stack {
         removalPolicy: cdk.RemovalPolicy.DESTROY,
       },
     );
+    this.editingSessionsTable.addGlobalSecondaryIndex({
+      indexName: "user_id-index",
+      partitionKey: {
+        name: "user_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+    });
 
     // ==========================================================================
     // FileStore Table (for Demo Mode)
@@ -79,6 +129,236 @@ export class DatabaseStack extends cdk.Stack {
       removalPolicy: cdk.RemovalPolicy.DESTROY, // Demo data is ephemeral
     });
 
+    // ==========================================================================
+    // ChangeJournal Table
+    // --------------------------------------------------------------------------
+    // PK: user_id (string), SK: seq (number, nanosecond cursor)
+    // Attributes: note_id, change_type, expires_at (TTL)
+    // Backs GET /sync/changes; entries expire after the retention window.
+    // ==========================================================================
+    this.changeJournalTable = new dynamodb.Table(this, "ChangeJournalTable", {
+      partitionKey: {
+        name: "user_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: "seq",
+        type: dynamodb.AttributeType.NUMBER,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      timeToLiveAttribute: "expires_at",
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+    });
+
+    // ==========================================================================
+    // Comments Table
+    // --------------------------------------------------------------------------
+    // PK: note_id (string), SK: comment_id (string, sorts by creation time)
+    // Attributes: parent_id, author_id, author_name, body, created_at, deleted
+    // The item with SK "#count" holds the note's comment_count.
+    // Comments are user content, so the table is retained like UserTokens.
+    // ==========================================================================
+    this.commentsTable = new dynamodb.Table(this, "CommentsTable", {
+      partitionKey: {
+        name: "note_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: "comment_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      pointInTimeRecoverySpecification: {
+        pointInTimeRecoveryEnabled: true,
+      },
+      removalPolicy: cdk.RemovalPolicy.RETAIN,
+    });
+
+    // ==========================================================================
+    // Drafts Table
+    // --------------------------------------------------------------------------
+    // PK: user_id (string), SK: note_id (string)
+    // Attributes: content, base_etag, saved_at, expires_at (TTL)
+    // Drafts expire a week after their last autosave.
+    // ==========================================================================
+    this.draftsTable = new dynamodb.Table(this, "DraftsTable", {
+      partitionKey: {
+        name: "user_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: "note_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      timeToLiveAttribute: "expires_at",
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+    });
+
+    // ==========================================================================
+    // Publications Table
+    // --------------------------------------------------------------------------
+    // PK: note_id (string)
+    // Attributes: owner_id, name, content, source_etag, published_at
+    // Public share pages serve these snapshots, so the table is retained.
+    // ==========================================================================
+    this.publicationsTable = new dynamodb.Table(this, "PublicationsTable", {
+      partitionKey: {
+        name: "note_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      pointInTimeRecoverySpecification: {
+        pointInTimeRecoveryEnabled: true,
+      },
+      removalPolicy: cdk.RemovalPolicy.RETAIN,
+    });
+
+    // ==========================================================================
+    // Reminders Table
+    // --------------------------------------------------------------------------
+    // PK: user_id (string), SK: reminder_id (string, "<note_id>#<task index>")
+    // Attributes: note_id, note_name, task_index, text, due, email, notified_at
+    // Rebuilt from notes as they are saved, so the table is not retained.
+    // ==========================================================================
+    this.remindersTable = new dynamodb.Table(this, "RemindersTable", {
+      partitionKey: {
+        name: "user_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: "reminder_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+    });
+
+    // ==========================================================================
+    // AccessTokens Table
+    // --------------------------------------------------------------------------
+    // PK: user_id (string), SK: token_id (string)
+    // Attributes: name, email, user_name, created_at, expires_time,
+    // expires_at (TTL)
+    // Deleting a token revokes it, so losing the table only signs out
+    // scripts; it is not retained.
+    // ==========================================================================
+    this.accessTokensTable = new dynamodb.Table(this, "AccessTokensTable", {
+      partitionKey: {
+        name: "user_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: "token_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      timeToLiveAttribute: "expires_at",
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+    });
+
+    // ==========================================================================
+    // GitRemotes Table
+    // --------------------------------------------------------------------------
+    // PK: user_id (string)
+    // Attributes: provider, repository, branch, encrypted_token, updated_at,
+    // last_export_at, last_commit
+    // The exported repositories are the backups; losing the table only
+    // means setting the remote up again, so it is not retained.
+    // ==========================================================================
+    this.gitRemotesTable = new dynamodb.Table(this, "GitRemotesTable", {
+      partitionKey: {
+        name: "user_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+    });
+
+    // ==========================================================================
+    // Shares Table
+    // --------------------------------------------------------------------------
+    // PK: note_id (string), SK: email (string)
+    // Attributes: owner_id, owner_name, owner_email, name, folder, permission,
+    // created_at
+    // GSI: email-index (PK: email) for listing the notes shared with a user.
+    // Items inside shared folders are indexed in the same table, with
+    // note_id "inherited#{id}" and email "{email}#{folder id}".
+    // ==========================================================================
+    this.sharesTable = new dynamodb.Table(this, "SharesTable", {
+      partitionKey: {
+        name: "note_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: "email",
+        type: dynamodb.AttributeType.STRING,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      pointInTimeRecoverySpecification: {
+        pointInTimeRecoveryEnabled: true,
+      },
+      removalPolicy: cdk.RemovalPolicy.RETAIN,
+    });
+    this.sharesTable.addGlobalSecondaryIndex({
+      indexName: "email-index",
+      partitionKey: {
+        name: "email",
+        type: dynamodb.AttributeType.STRING,
+      },
+    });
+
+    // ==========================================================================
+    // Mirrors Table
+    // --------------------------------------------------------------------------
+    // PK: user_id (string), SK: note_id (string)
+    // Attributes: name, hash, mirror_id, mirrored_name, mirrored_hash, status,
+    // error, attempts, updated_at, mirrored_at, unsynced
+    // GSI: unsynced-index (PK: unsynced, SK: updated_at), sparse: only
+    // entries that are not synced have unsynced, so the scheduled retry
+    // reads those instead of scanning every user's entries.
+    // Losing the table only means copying every note again.
+    // ==========================================================================
+    this.mirrorsTable = new dynamodb.Table(this, "MirrorsTable", {
+      partitionKey: {
+        name: "user_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: "note_id",
+        type: dynamodb.AttributeType.STRING,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+    });
+    this.mirrorsTable.addGlobalSecondaryIndex({
+      indexName: "unsynced-index",
+      partitionKey: {
+        name: "unsynced",
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: "updated_at",
+        type: dynamodb.AttributeType.STRING,
+      },
+    });
+
+    // ==========================================================================
+    // Mirror Bucket
+    // --------------------------------------------------------------------------
+    // Keys: {user id}/GophDrive Backup/{note name}.md
+    // The copies are the backups, so the bucket is retained and keeps
+    // overwritten and deleted copies for 30 days.
+    // ==========================================================================
+    this.mirrorBucket = new s3.Bucket(this, "MirrorBucket", {
+      blockPublicAccess: s3.BlockPublicAccess.BLOCK_ALL,
+      encryption: s3.BucketEncryption.S3_MANAGED,
+      enforceSSL: true,
+      versioned: true,
+      lifecycleRules: [{ noncurrentVersionExpiration: cdk.Duration.days(30) }],
+      removalPolicy: cdk.RemovalPolicy.RETAIN,
+    });
+
     // ==========================================================================
     // Outputs
     // ==========================================================================
@@ -96,5 +376,55 @@ export class DatabaseStack extends cdk.Stack {
       value: this.fileStoreTable.tableName,
       description: "DynamoDB table for demo mode files",
     });
+
+    new cdk.CfnOutput(this, "ChangeJournalTableName", {
+      value: this.changeJournalTable.tableName,
+      description: "DynamoDB table for the note change journal",
+    });
+
+    new cdk.CfnOutput(this, "CommentsTableName", {
+      value: this.commentsTable.tableName,
+      description: "DynamoDB table for note comments",
+    });
+
+    new cdk.CfnOutput(this, "DraftsTableName", {
+      value: this.draftsTable.tableName,
+      description: "DynamoDB table for note drafts",
+    });
+
+    new cdk.CfnOutput(this, "PublicationsTableName", {
+      value: this.publicationsTable.tableName,
+      description: "DynamoDB table for published notes",
+    });
+
+    new cdk.CfnOutput(this, "RemindersTableName", {
+      value: this.remindersTable.tableName,
+      description: "DynamoDB table for task reminders",
+    });
+
+    new cdk.CfnOutput(this, "AccessTokensTableName", {
+      value: this.accessTokensTable.tableName,
+      description: "DynamoDB table for personal access tokens",
+    });
+
+    new cdk.CfnOutput(this, "GitRemotesTableName", {
+      value: this.gitRemotesTable.tableName,
+      description: "DynamoDB table for Git export remotes",
+    });
+
+    new cdk.CfnOutput(this, "SharesTableName", {
+      value: this.sharesTable.tableName,
+      description: "DynamoDB table for notes shared between users",
+    });
+
+    new cdk.CfnOutput(this, "MirrorsTableName", {
+      value: this.mirrorsTable.tableName,
+      description: "DynamoDB table for note mirror status",
+    });
+
+    new cdk.CfnOutput(this, "MirrorBucketName", {
+      value: this.mirrorBucket.bucketName,
+      description: "S3 bucket for mirrored copies of notes",
+    });
   }
 }
@@ -2,6 +2,7 @@ import * as cdk from "aws-cdk-lib";
 import { Template, Match } from "aws-cdk-lib/assertions";
 import * as dynamodb from "aws-cdk-lib/aws-dynamodb";
 import * as kms from "aws-cdk-lib/aws-kms";
+import * as s3 from "aws-cdk-lib/aws-s3";
 import { ComputeStack } from "../lib/compute-stack";
 
 describe("ComputeStack", () => {
@@ -26,12 +27,57 @@ describe("ComputeStack", () => {
     const fileStoreTable = new dynamodb.Table(depStack, "FileStore", {
       partitionKey: { name: "pk", type: dynamodb.AttributeType.STRING },
     });
+    const changeJournalTable = new dynamodb.Table(depStack, "ChangeJournal", {
+      partitionKey: { name: "user_id", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "seq", type: dynamodb.AttributeType.NUMBER },
+    });
+    const commentsTable = new dynamodb.Table(depStack, "Comments", {
+      partitionKey: { name: "note_id", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "comment_id", type: dynamodb.AttributeType.STRING },
+    });
+    const draftsTable = new dynamodb.Table(depStack, "Drafts", {
+      partitionKey: { name: "user_id", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "note_id", type: dynamodb.AttributeType.STRING },
+    });
+    const publicationsTable = new dynamodb.Table(depStack, "Publications", {
+      partitionKey: { name: "note_id", type: dynamodb.AttributeType.STRING },
+    });
+    const remindersTable = new dynamodb.Table(depStack, "Reminders", {
+      partitionKey: { name: "user_id", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "reminder_id", type: dynamodb.AttributeType.STRING },
+    });
+    const accessTokensTable = new dynamodb.Table(depStack, "AccessTokens", {
+      partitionKey: { name: "user_id", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "token_id", type: dynamodb.AttributeType.STRING },
+    });
+    const gitRemotesTable = new dynamodb.Table(depStack, "GitRemotes", {
+      partitionKey: { name: "user_id", type: dynamodb.AttributeType.STRING },
+    });
+    const sharesTable = new dynamodb.Table(depStack, "Shares", {
+      partitionKey: { name: "note_id", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "email", type: dynamodb.AttributeType.STRING },
+    });
+    const mirrorsTable = new dynamodb.Table(depStack, "Mirrors", {
+      partitionKey: { name: "user_id", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "note_id", type: dynamodb.AttributeType.STRING },
+    });
+    const mirrorBucket = new s3.Bucket(depStack, "MirrorBucket");
     const tokenEncryptionKey = new kms.Key(depStack, "Key");
 
     const stack = new ComputeStack(app, "TestComputeStack", {
       userTokensTable,
       editingSessionsTable,
       fileStoreTable,
+      changeJournalTable,
+      commentsTable,
+      draftsTable,
+      publicationsTable,
+      remindersTable,
+      accessTokensTable,
+      gitRemotesTable,
+      sharesTable,
+      mirrorsTable,
+      mirrorBucket,
       tokenEncryptionKey,
     });
     template = Template.fromStack(stack);
@@ -47,6 +93,15 @@ describe("ComputeStack", () => {
     });
   });
 
+  test("Lambda and API Gateway have X-Ray tracing enabled", () => {
+    template.hasResourceProperties("AWS::Lambda::Function", {
+      TracingConfig: { Mode: "Active" },
+    });
+    template.hasResourceProperties("AWS::ApiGateway::Stage", {
+      TracingEnabled: true,
+    });
+  });
+
   test("Lambda has required environment variables with SSM param names", () => {
     template.hasResourceProperties("AWS::Lambda::Function", {
       Environment: {
@@ -54,6 +109,16 @@ describe("ComputeStack", () => {
           USER_TOKENS_TABLE: Match.anyValue(),
           EDITING_SESSIONS_TABLE: Match.anyValue(),
           FILE_STORE_TABLE: Match.anyValue(),
+          CHANGE_JOURNAL_TABLE: Match.anyValue(),
+          COMMENTS_TABLE: Match.anyValue(),
+          DRAFTS_TABLE: Match.anyValue(),
+          PUBLICATIONS_TABLE: Match.anyValue(),
+          REMINDERS_TABLE: Match.anyValue(),
+          ACCESS_TOKENS_TABLE: Match.anyValue(),
+          GIT_REMOTES_TABLE: Match.anyValue(),
+          SHARES_TABLE: Match.anyValue(),
+          MIRRORS_TABLE: Match.anyValue(),
+          MIRROR_S3_BUCKET: Match.anyValue(),
           KMS_KEY_ID: Match.anyValue(),
           GOOGLE_CLIENT_SECRET_PARAM: "/gophdrive/google-client-secret",
           JWT_SECRET_PARAM: "/gophdrive/jwt-secret",
@@ -76,6 +141,19 @@ describe("ComputeStack", () => {
     });
   });
 
+  test("Lambda can read and write the mirror bucket", () => {
+    template.hasResourceProperties("AWS::IAM::Policy", {
+      PolicyDocument: {
+        Statement: Match.arrayWith([
+          Match.objectLike({
+            Action: Match.arrayWith(["s3:PutObject"]),
+            Effect: "Allow",
+          }),
+        ]),
+      },
+    });
+  });
+
   test("creates an API Gateway REST API", () => {
     template.resourceCountIs("AWS::ApiGateway::RestApi", 1);
     template.hasResourceProperties("AWS::ApiGateway::RestApi", {
@@ -83,6 +161,22 @@ describe("ComputeStack", () => {
     });
   });
 
+  test("API Gateway compresses responses over 1 KiB", () => {
+    template.hasResourceProperties("AWS::ApiGateway::RestApi", {
+      MinimumCompressionSize: 1024,
+    });
+  });
+
+  test("API Gateway passes only download types through as binary", () => {
+    template.hasResourceProperties("AWS::ApiGateway::RestApi", {
+      BinaryMediaTypes: [
+        "application/zip",
+        "application/pdf",
+        "application/octet-stream",
+      ],
+    });
+  });
+
   test("API Gateway has CORS configuration", () => {
     // CORS preflight creates an OPTIONS method
     template.hasResourceProperties("AWS::ApiGateway::Method", {
@@ -104,6 +198,15 @@ describe("ComputeStack", () => {
     });
   });
 
+  test("runs the scheduled jobs every 5 minutes", () => {
+    template.hasResourceProperties("AWS::Events::Rule", {
+      ScheduleExpression: "rate(5 minutes)",
+      Targets: Match.arrayWith([
+        Match.objectLike({ Arn: Match.anyValue() }),
+      ]),
+    });
+  });
+
   test("outputs API URL", () => {
     template.hasOutput("ApiUrl", {
       Value: Match.anyValue(),
@@ -42,6 +42,18 @@ describe("DatabaseStack", () => {
     });
   });
 
+  test("EditingSessions table has user_id GSI", () => {
+    template.hasResourceProperties("AWS::DynamoDB::Table", {
+      KeySchema: [{ AttributeName: "file_id", KeyType: "HASH" }],
+      GlobalSecondaryIndexes: [
+        Match.objectLike({
+          IndexName: "user_id-index",
+          KeySchema: [{ AttributeName: "user_id", KeyType: "HASH" }],
+        }),
+      ],
+    });
+  });
+
   test("creates FileStore DynamoDB table", () => {
     template.hasResourceProperties("AWS::DynamoDB::Table", {
       KeySchema: [
@@ -58,6 +70,140 @@ describe("DatabaseStack", () => {
     });
   });
 
+  test("creates ChangeJournal DynamoDB table", () => {
+    template.hasResourceProperties("AWS::DynamoDB::Table", {
+      KeySchema: [
+        { AttributeName: "user_id", KeyType: "HASH" },
+        { AttributeName: "seq", KeyType: "RANGE" },
+      ],
+      BillingMode: "PAY_PER_REQUEST",
+      TimeToLiveSpecification: {
+        Enabled: true,
+        AttributeName: "expires_at",
+      },
+    });
+  });
+
+  test("creates Comments DynamoDB table", () => {
+    template.hasResource("AWS::DynamoDB::Table", {
+      Properties: {
+        KeySchema: [
+          { AttributeName: "note_id", KeyType: "HASH" },
+          { AttributeName: "comment_id", KeyType: "RANGE" },
+        ],
+        BillingMode: "PAY_PER_REQUEST",
+      },
+      DeletionPolicy: "Retain",
+    });
+  });
+
+  test("creates Drafts DynamoDB table with TTL", () => {
+    template.hasResourceProperties("AWS::DynamoDB::Table", {
+      KeySchema: [
+        { AttributeName: "user_id", KeyType: "HASH" },
+        { AttributeName: "note_id", KeyType: "RANGE" },
+      ],
+      BillingMode: "PAY_PER_REQUEST",
+      TimeToLiveSpecification: {
+        Enabled: true,
+        AttributeName: "expires_at",
+      },
+    });
+  });
+
+  test("creates Publications DynamoDB table", () => {
+    template.hasResource("AWS::DynamoDB::Table", {
+      Properties: {
+        KeySchema: [{ AttributeName: "note_id", KeyType: "HASH" }],
+        BillingMode: "PAY_PER_REQUEST",
+      },
+      DeletionPolicy: "Retain",
+    });
+  });
+
+  test("creates Reminders DynamoDB table", () => {
+    template.hasResource("AWS::DynamoDB::Table", {
+      Properties: {
+        KeySchema: [
+          { AttributeName: "user_id", KeyType: "HASH" },
+          { AttributeName: "reminder_id", KeyType: "RANGE" },
+        ],
+        BillingMode: "PAY_PER_REQUEST",
+      },
+      DeletionPolicy: "Delete",
+    });
+  });
+
+  test("creates AccessTokens DynamoDB table with TTL", () => {
+    template.hasResource("AWS::DynamoDB::Table", {
+      Properties: {
+        KeySchema: [
+          { AttributeName: "user_id", KeyType: "HASH" },
+          { AttributeName: "token_id", KeyType: "RANGE" },
+        ],
+        BillingMode: "PAY_PER_REQUEST",
+        TimeToLiveSpecification: {
+          Enabled: true,
+          AttributeName: "expires_at",
+        },
+      },
+      DeletionPolicy: "Delete",
+    });
+  });
+
+  test("creates Shares DynamoDB table with an email index", () => {
+    template.hasResource("AWS::DynamoDB::Table", {
+      Properties: {
+        KeySchema: [
+          { AttributeName: "note_id", KeyType: "HASH" },
+          { AttributeName: "email", KeyType: "RANGE" },
+        ],
+        BillingMode: "PAY_PER_REQUEST",
+        GlobalSecondaryIndexes: [
+          Match.objectLike({
+            IndexName: "email-index",
+            KeySchema: [{ AttributeName: "email", KeyType: "HASH" }],
+          }),
+        ],
+      },
+      DeletionPolicy: "Retain",
+    });
+  });
+
+  test("creates Mirrors DynamoDB table with a sparse unsynced index", () => {
+    template.hasResource("AWS::DynamoDB::Table", {
+      Properties: {
+        KeySchema: [
+          { AttributeName: "user_id", KeyType: "HASH" },
+          { AttributeName: "note_id", KeyType: "RANGE" },
+        ],
+        GlobalSecondaryIndexes: [
+          Match.objectLike({
+            IndexName: "unsynced-index",
+            KeySchema: [
+              { AttributeName: "unsynced", KeyType: "HASH" },
+              { AttributeName: "updated_at", KeyType: "RANGE" },
+            ],
+          }),
+        ],
+      },
+      DeletionPolicy: "Delete",
+    });
+  });
+
+  test("creates a private, versioned mirror bucket that is retained", () => {
+    template.hasResource("AWS::S3::Bucket", {
+      Properties: Match.objectLike({
+        VersioningConfiguration: { Status: "Enabled" },
+        PublicAccessBlockConfiguration: Match.objectLike({
+          BlockPublicAcls: true,
+          RestrictPublicBuckets: true,
+        }),
+      }),
+      DeletionPolicy: "Retain",
+    });
+  });
+
   test("UserTokens table has RETAIN removal policy", () => {
     template.hasResource("AWS::DynamoDB::Table", {
       Properties: {
@@ -86,8 +232,8 @@ describe("DatabaseStack", () => {
     });
   });
 
-  test("creates exactly 3 DynamoDB tables", () => {
-    template.resourceCountIs("AWS::DynamoDB::Table", 3);
+  test("creates exactly 12 DynamoDB tables", () => {
+    template.resourceCountIs("AWS::DynamoDB::Table", 12);
   });
 
   test("outputs table names", () => {
@@ -100,5 +246,35 @@ describe("DatabaseStack", () => {
     template.hasOutput("FileStoreTableName", {
       Value: Match.objectLike({ Ref: Match.anyValue() }),
     });
+    template.hasOutput("ChangeJournalTableName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
+    template.hasOutput("CommentsTableName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
+    template.hasOutput("DraftsTableName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
+    template.hasOutput("PublicationsTableName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
+    template.hasOutput("RemindersTableName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
+    template.hasOutput("AccessTokensTableName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
+    template.hasOutput("GitRemotesTableName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
+    template.hasOutput("SharesTableName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
+    template.hasOutput("MirrorsTableName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
+    template.hasOutput("MirrorBucketName", {
+      Value: Match.objectLike({ Ref: Match.anyValue() }),
+    });
   });
 });
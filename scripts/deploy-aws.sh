@@ -36,8 +36,8 @@ export NEXT_PUBLIC_API_URL="/api"
 # ---- SSM Parameter Store: Manage Secrets ----
 echo "Managing secrets in SSM Parameter Store..."
 
-# JWT_SECRET and API_GATEWAY_SECRET: auto-generate if not already in SSM
-for PARAM in "/gophdrive/jwt-secret" "/gophdrive/api-gateway-secret"; do
+# JWT_SECRET, API_GATEWAY_SECRET and SIGNING_KEYS: auto-generate if not already in SSM
+for PARAM in "/gophdrive/jwt-secret" "/gophdrive/api-gateway-secret" "/gophdrive/signing-keys"; do
   if ! aws ssm get-parameter --name "$PARAM" > /dev/null 2>&1; then
     echo "  Creating $PARAM (auto-generated)..."
     aws ssm put-parameter --name "$PARAM" \
@@ -37,8 +37,9 @@ else
     echo "📦 Creating EditingSessions table..."
     $AWS_CMD dynamodb create-table \
         --table-name EditingSessions \
-        --attribute-definitions AttributeName=file_id,AttributeType=S \
+        --attribute-definitions AttributeName=file_id,AttributeType=S AttributeName=user_id,AttributeType=S \
         --key-schema AttributeName=file_id,KeyType=HASH \
+        --global-secondary-indexes "IndexName=user_id-index,KeySchema=[{AttributeName=user_id,KeyType=HASH}],Projection={ProjectionType=ALL}" \
         --billing-mode PAY_PER_REQUEST
 
     $AWS_CMD dynamodb update-time-to-live \
@@ -58,6 +59,128 @@ else
         --billing-mode PAY_PER_REQUEST
 fi
 
+# 2.6 Create ChangeJournal Table
+if table_exists "ChangeJournal"; then
+    echo "✅ Table ChangeJournal already exists."
+else
+    echo "📦 Creating ChangeJournal table..."
+    $AWS_CMD dynamodb create-table \
+        --table-name ChangeJournal \
+        --attribute-definitions AttributeName=user_id,AttributeType=S AttributeName=seq,AttributeType=N \
+        --key-schema AttributeName=user_id,KeyType=HASH AttributeName=seq,KeyType=RANGE \
+        --billing-mode PAY_PER_REQUEST
+
+    $AWS_CMD dynamodb update-time-to-live \
+        --table-name ChangeJournal \
+        --time-to-live-specification Enabled=true,AttributeName=expires_at
+fi
+
+# 2.7 Create Comments Table
+if table_exists "Comments"; then
+    echo "✅ Table Comments already exists."
+else
+    echo "📦 Creating Comments table..."
+    $AWS_CMD dynamodb create-table \
+        --table-name Comments \
+        --attribute-definitions AttributeName=note_id,AttributeType=S AttributeName=comment_id,AttributeType=S \
+        --key-schema AttributeName=note_id,KeyType=HASH AttributeName=comment_id,KeyType=RANGE \
+        --billing-mode PAY_PER_REQUEST
+fi
+
+# 2.8 Create Drafts Table
+if table_exists "Drafts"; then
+    echo "✅ Table Drafts already exists."
+else
+    echo "📦 Creating Drafts table..."
+    $AWS_CMD dynamodb create-table \
+        --table-name Drafts \
+        --attribute-definitions AttributeName=user_id,AttributeType=S AttributeName=note_id,AttributeType=S \
+        --key-schema AttributeName=user_id,KeyType=HASH AttributeName=note_id,KeyType=RANGE \
+        --billing-mode PAY_PER_REQUEST
+
+    $AWS_CMD dynamodb update-time-to-live \
+        --table-name Drafts \
+        --time-to-live-specification Enabled=true,AttributeName=expires_at
+fi
+
+# 2.9 Create Publications Table
+if table_exists "Publications"; then
+    echo "✅ Table Publications already exists."
+else
+    echo "📦 Creating Publications table..."
+    $AWS_CMD dynamodb create-table \
+        --table-name Publications \
+        --attribute-definitions AttributeName=note_id,AttributeType=S \
+        --key-schema AttributeName=note_id,KeyType=HASH \
+        --billing-mode PAY_PER_REQUEST
+fi
+
+# 2.10 Create Reminders Table
+if table_exists "Reminders"; then
+    echo "✅ Table Reminders already exists."
+else
+    echo "📦 Creating Reminders table..."
+    $AWS_CMD dynamodb create-table \
+        --table-name Reminders \
+        --attribute-definitions AttributeName=user_id,AttributeType=S AttributeName=reminder_id,AttributeType=S \
+        --key-schema AttributeName=user_id,KeyType=HASH AttributeName=reminder_id,KeyType=RANGE \
+        --billing-mode PAY_PER_REQUEST
+fi
+
+# 2.11 Create AccessTokens Table
+if table_exists "AccessTokens"; then
+    echo "✅ Table AccessTokens already exists."
+else
+    echo "📦 Creating AccessTokens table..."
+    $AWS_CMD dynamodb create-table \
+        --table-name AccessTokens \
+        --attribute-definitions AttributeName=user_id,AttributeType=S AttributeName=token_id,AttributeType=S \
+        --key-schema AttributeName=user_id,KeyType=HASH AttributeName=token_id,KeyType=RANGE \
+        --billing-mode PAY_PER_REQUEST
+
+    $AWS_CMD dynamodb update-time-to-live \
+        --table-name AccessTokens \
+        --time-to-live-specification Enabled=true,AttributeName=expires_at
+fi
+
+# 2.12 Create GitRemotes Table
+if table_exists "GitRemotes"; then
+    echo "✅ Table GitRemotes already exists."
+else
+    echo "📦 Creating GitRemotes table..."
+    $AWS_CMD dynamodb create-table \
+        --table-name GitRemotes \
+        --attribute-definitions AttributeName=user_id,AttributeType=S \
+        --key-schema AttributeName=user_id,KeyType=HASH \
+        --billing-mode PAY_PER_REQUEST
+fi
+
+# 2.13 Create Mirrors Table
+if table_exists "Mirrors"; then
+    echo "✅ Table Mirrors already exists."
+else
+    echo "📦 Creating Mirrors table..."
+    $AWS_CMD dynamodb create-table \
+        --table-name Mirrors \
+        --attribute-definitions AttributeName=user_id,AttributeType=S AttributeName=note_id,AttributeType=S AttributeName=unsynced,AttributeType=S AttributeName=updated_at,AttributeType=S \
+        --key-schema AttributeName=user_id,KeyType=HASH AttributeName=note_id,KeyType=RANGE \
+        --global-secondary-indexes "IndexName=unsynced-index,KeySchema=[{AttributeName=unsynced,KeyType=HASH},{AttributeName=updated_at,KeyType=RANGE}],Projection={ProjectionType=ALL}" \
+        --billing-mode PAY_PER_REQUEST
+fi
+
+# 2.14 Create Shares Table
+if table_exists "Shares"; then
+    echo "✅ Table Shares already exists."
+else
+    echo "📦 Creating Shares table..."
+    $AWS_CMD dynamodb create-table \
+        --table-name Shares \
+        --attribute-definitions AttributeName=note_id,AttributeType=S AttributeName=email,AttributeType=S \
+        --key-schema AttributeName=note_id,KeyType=HASH AttributeName=email,KeyType=RANGE \
+        --global-secondary-indexes "IndexName=email-index,KeySchema=[{AttributeName=email,KeyType=HASH}],Projection={ProjectionType=ALL}" \
+        --billing-mode PAY_PER_REQUEST
+fi
+
 # 3. Create KMS Key
 echo "🔑 Checking/Creating KMS Key..."
 # Check for existing alias
@@ -79,7 +202,7 @@ ssm_param_exists() {
     $AWS_CMD ssm get-parameter --name "$1" > /dev/null 2>&1
 }
 
-for PARAM in "/gophdrive/jwt-secret" "/gophdrive/api-gateway-secret"; do
+for PARAM in "/gophdrive/jwt-secret" "/gophdrive/api-gateway-secret" "/gophdrive/signing-keys"; do
     if ssm_param_exists "$PARAM"; then
         echo "   ✅ SSM parameter $PARAM already exists."
     else